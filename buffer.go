@@ -0,0 +1,82 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// bufferSizes are the sizes of the pooled copy buffers, from the smallest to the largest.
+var bufferSizes = [...]int{4 << 10, 32 << 10, 256 << 10}
+
+// defaultBufferSize is the size of the buffers copying content of unknown size, which is the size
+// of the buffers of io.Copy.
+const defaultBufferSize = 32 << 10
+
+// bufferPools holds the pooled buffers of each size of bufferSizes. Pointers to the slices are
+// pooled, so that putting them back does not allocate.
+var bufferPools [len(bufferSizes)]sync.Pool
+
+func init() {
+	for i, size := range bufferSizes {
+		size := size
+		bufferPools[i].New = func() any {
+			b := make([]byte, size)
+			return &b
+		}
+	}
+}
+
+// bufferClass returns the index of the pooled buffers fitting content of size bytes: the smallest
+// buffers holding all of it, or else the largest ones. Content of unknown size, a negative size,
+// gets buffers of defaultBufferSize.
+func bufferClass(size int64) int {
+	for i, s := range bufferSizes {
+		if (size < 0 && s == defaultBufferSize) || (size >= 0 && size <= int64(s)) {
+			return i
+		}
+	}
+	return len(bufferSizes) - 1
+}
+
+// Copy copies src to dst until EOF or an error, like io.Copy, with a buffer taken from a shared
+// pool instead of a new one, see CopySized. It returns the number of bytes copied.
+func Copy(dst io.Writer, src io.Reader) (int64, error) {
+	return CopySized(dst, src, -1)
+}
+
+// CopySized copies src, which is expected to yield size bytes, or -1 if unknown, to dst, like
+// io.Copy, with a buffer fitting the size taken from a shared pool, which saves allocating a
+// buffer per copy when gathering many small files. Files are copied to files by the kernel where
+// possible, without a buffer. It returns the number of bytes copied.
+func CopySized(dst io.Writer, src io.Reader, size int64) (int64, error) {
+	if _, ok := src.(*os.File); ok {
+		if _, ok := dst.(*os.File); ok {
+			return io.Copy(dst, src)
+		}
+	}
+
+	class := bufferClass(size)
+	buf := bufferPools[class].Get().(*[]byte)
+	defer bufferPools[class].Put(buf)
+	// The reader and the writer are wrapped so that io.CopyBuffer uses the buffer, as an
+	// io.WriterTo or io.ReaderFrom, e.g. an *os.File, may otherwise fall back to a buffer of its
+	// own.
+	return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, *buf)
+}
@@ -0,0 +1,104 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestBufferClass tests choosing the pooled buffers fitting the size of the content
+func TestBufferClass(t *testing.T) {
+	for size, want := range map[int64]int{-1: 32 << 10, 0: 4 << 10, 100: 4 << 10, 4 << 10: 4 << 10, 4<<10 + 1: 32 << 10, 1 << 20: 256 << 10} {
+		if got := bufferSizes[bufferClass(size)]; got != want {
+			t.Errorf("expected a %d byte buffer for %d bytes, got %d", want, size, got)
+		}
+	}
+}
+
+// TestCopy tests copying content of various sizes to writers and files
+func TestCopy(t *testing.T) {
+	for _, size := range []int{0, 10, 5000, 300 << 10} {
+		data := bytes.Repeat([]byte("x"), size)
+
+		var out bytes.Buffer
+		if n, err := CopySized(&out, bytes.NewReader(data), int64(size)); err != nil || n != int64(size) || !bytes.Equal(out.Bytes(), data) {
+			t.Errorf("unexpected copy of %d bytes: %d, %v", size, n, err)
+		}
+
+		dir := t.TempDir()
+		src := filepath.Join(dir, "src")
+		if err := os.WriteFile(src, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		in, err := os.Open(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst, err := os.Create(filepath.Join(dir, "dst"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := Copy(dst, in); err != nil || n != int64(size) {
+			t.Errorf("unexpected copy of a %d byte file: %d, %v", size, n, err)
+		}
+		in.Close()
+		dst.Close()
+		if got, err := os.ReadFile(filepath.Join(dir, "dst")); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("unexpected content of the copy of a %d byte file: %v", size, err)
+		}
+	}
+}
+
+// TestCopy_Pooled tests that copies reuse the pooled buffers instead of allocating their own
+func TestCopy_Pooled(t *testing.T) {
+	data := strings.Repeat("x", 1000)
+	h := sha256.New()
+	copyFiles := func() {
+		for i := 0; i < 100; i++ {
+			if _, err := CopySized(h, strings.NewReader(data), int64(len(data))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	copyFiles()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	copyFiles()
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 100*1024 {
+		t.Errorf("expected the copies to reuse the pooled buffers, allocated %d bytes", allocated)
+	}
+}
+
+// BenchmarkCopy measures copying small files with pooled buffers
+func BenchmarkCopy(b *testing.B) {
+	data := strings.Repeat("x", 2000)
+	h := sha256.New()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := CopySized(h, strings.NewReader(data), int64(len(data))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"crypto/md5"  // nolint:gosec
+	"crypto/sha1" // nolint:gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"strings"
+)
+
+// checksumAlgorithms maps the names of the supported checksum algorithms to their hash functions.
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// Checksum is the expected digest of gathered content.
+type Checksum struct {
+	// Algorithm is the name of the hash algorithm: "md5", "sha1", "sha256" or "sha512".
+	Algorithm string
+	// Value is the hex encoded digest.
+	Value string
+}
+
+// ParseChecksum parses a checksum in go-getter syntax, "<algorithm>:<hex digest>", e.g.
+// "sha256:2cf24d...". When the algorithm is omitted it is inferred from the length of the digest.
+func ParseChecksum(s string) (Checksum, error) {
+	algorithm, value, found := strings.Cut(s, ":")
+	if !found {
+		value = s
+		switch len(value) {
+		case md5.Size * 2:
+			algorithm = "md5"
+		case sha1.Size * 2:
+			algorithm = "sha1"
+		case sha256.Size * 2:
+			algorithm = "sha256"
+		case sha512.Size * 2:
+			algorithm = "sha512"
+		default:
+			return Checksum{}, fmt.Errorf("unable to determine the algorithm of checksum %s", s)
+		}
+	}
+	c := Checksum{Algorithm: strings.ToLower(algorithm), Value: strings.ToLower(value)}
+	newHash, ok := checksumAlgorithms[c.Algorithm]
+	if !ok {
+		return Checksum{}, fmt.Errorf("unsupported checksum algorithm: %s", algorithm)
+	}
+	if b, err := hex.DecodeString(c.Value); err != nil || len(b) != newHash().Size() {
+		return Checksum{}, fmt.Errorf("invalid %s checksum: %s", c.Algorithm, value)
+	}
+	return c, nil
+}
+
+// String returns the checksum in go-getter syntax.
+func (c Checksum) String() string {
+	return c.Algorithm + ":" + c.Value
+}
+
+// ChecksumMismatchError is returned when gathered content does not match the expected checksum.
+type ChecksumMismatchError struct {
+	// Path is the gathered file that was verified.
+	Path string
+	// Expected is the checksum the content was required to match.
+	Expected Checksum
+	// Actual is the hex encoded digest of the content.
+	Actual string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s: expected %s, got %s:%s", e.Path, e.Expected, e.Expected.Algorithm, e.Actual)
+}
+
+// VerifyFile returns a ChecksumMismatchError if the digest of the file at path does not match
+// the checksum.
+func (c Checksum) VerifyFile(path string) error {
+	newHash, ok := checksumAlgorithms[c.Algorithm]
+	if !ok {
+		return fmt.Errorf("unsupported checksum algorithm: %s", c.Algorithm)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := newHash()
+	if _, err := HashFile(h, f); err != nil {
+		return fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != c.Value {
+		return &ChecksumMismatchError{Path: path, Expected: c, Actual: actual}
+	}
+	return nil
+}
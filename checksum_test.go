@@ -0,0 +1,142 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+// TestParseChecksum tests parsing checksums with and without an algorithm
+func TestParseChecksum(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Checksum
+	}{
+		{input: "sha256:" + helloSHA256, want: Checksum{Algorithm: "sha256", Value: helloSHA256}},
+		{input: "SHA256:" + helloSHA256, want: Checksum{Algorithm: "sha256", Value: helloSHA256}},
+		{input: helloSHA256, want: Checksum{Algorithm: "sha256", Value: helloSHA256}},
+		{input: "5d41402abc4b2a76b9719d911017c592", want: Checksum{Algorithm: "md5", Value: "5d41402abc4b2a76b9719d911017c592"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseChecksum(tt.input)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Errorf("unexpected checksum for %s: %+v", tt.input, got)
+		}
+	}
+
+	for _, input := range []string{"abc", "crc32:abc", "sha256:abc", "sha256:" + helloSHA256[:62] + "zz"} {
+		if _, err := ParseChecksum(input); err == nil {
+			t.Errorf("expected an error for %s", input)
+		}
+	}
+}
+
+// TestChecksum_VerifyFile tests verifying files against a checksum
+func TestChecksum_VerifyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hello.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (Checksum{Algorithm: "sha256", Value: helloSHA256}).VerifyFile(path); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	ctx := ContextWithOptions(context.Background(), GatherOptions{Checksum: "md5:00000000000000000000000000000000"})
+	err := VerifyChecksum(ctx, path)
+	var mismatch *ChecksumMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected a ChecksumMismatchError, got: %v", err)
+	}
+	if mismatch.Actual != "5d41402abc4b2a76b9719d911017c592" || mismatch.Path != path {
+		t.Errorf("unexpected error: %+v", mismatch)
+	}
+
+	if ChecksumVerified(ctx) {
+		t.Error("expected the gather not to be verified")
+	}
+
+	ctx = ContextWithOptions(context.Background(), GatherOptions{Checksum: "sha256:" + helloSHA256})
+	if err := VerifyChecksum(ctx, path); err != nil || !ChecksumVerified(ctx) {
+		t.Errorf("expected the gather to be verified: %v", err)
+	}
+
+	if err := VerifyChecksum(context.Background(), path); err != nil {
+		t.Errorf("unexpected error without a checksum: %v", err)
+	}
+}
+
+// TestChecksumVerifier tests verifying content while it is read
+func TestChecksumVerifier(t *testing.T) {
+	verify := func(ctx context.Context, content string) error {
+		t.Helper()
+		v, err := NewChecksumVerifier(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := io.Copy(io.Discard, v.Reader(strings.NewReader(content))); err != nil {
+			t.Fatal(err)
+		}
+		return v.Verify("hello.txt")
+	}
+
+	ctx := ContextWithOptions(context.Background(), GatherOptions{Checksum: "sha256:" + helloSHA256})
+	var mismatch *ChecksumMismatchError
+	if err := verify(ctx, "bye"); !errors.As(err, &mismatch) || mismatch.Path != "hello.txt" {
+		t.Errorf("expected a ChecksumMismatchError, got: %v", err)
+	}
+	if ChecksumVerified(ctx) {
+		t.Error("expected the gather not to be verified")
+	}
+	if err := verify(ctx, "hello"); err != nil || !ChecksumVerified(ctx) {
+		t.Errorf("expected the gather to be verified: %v", err)
+	}
+
+	if err := verify(context.Background(), "bye"); err != nil {
+		t.Errorf("unexpected error without a checksum: %v", err)
+	}
+	ctx = ContextWithOptions(context.Background(), GatherOptions{Checksum: "abc"})
+	if _, err := NewChecksumVerifier(ctx); err == nil {
+		t.Error("expected an error for an invalid checksum")
+	}
+}
+
+// TestVerifyChecksumDigest tests verifying precomputed digests, e.g. tree hashes
+func TestVerifyChecksumDigest(t *testing.T) {
+	ctx := ContextWithOptions(context.Background(), GatherOptions{Checksum: "sha256:" + helloSHA256})
+	var mismatch *ChecksumMismatchError
+	if err := VerifyChecksumDigest(ctx, "dir", "sha256", strings.Repeat("0", 64)); !errors.As(err, &mismatch) {
+		t.Errorf("expected a ChecksumMismatchError, got: %v", err)
+	}
+	if err := VerifyChecksumDigest(ctx, "dir", "sha512", helloSHA256); err == nil || errors.As(err, &mismatch) {
+		t.Errorf("expected an algorithm error, got: %v", err)
+	}
+	if err := VerifyChecksumDigest(ctx, "dir", "sha256", helloSHA256); err != nil || !ChecksumVerified(ctx) {
+		t.Errorf("expected the gather to be verified: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+module github.com/enterprise-contract/go-gather/cmd/go-gather
+
+go 1.22.5
+
+require (
+	github.com/enterprise-contract/go-gather v0.0.3
+	github.com/enterprise-contract/go-gather/gather v1.0.0
+)
+
+require (
+	cloud.google.com/go/compute/metadata v0.3.0 // indirect
+	dario.cat/mergo v1.0.0 // indirect
+	github.com/Microsoft/go-winio v0.6.2 // indirect
+	github.com/ProtonMail/go-crypto v1.0.0 // indirect
+	github.com/aws/aws-sdk-go-v2 v1.32.7 // indirect
+	github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream v1.6.7 // indirect
+	github.com/aws/aws-sdk-go-v2/config v1.28.7 // indirect
+	github.com/aws/aws-sdk-go-v2/credentials v1.17.48 // indirect
+	github.com/aws/aws-sdk-go-v2/feature/ec2/imds v1.16.22 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/configsources v1.3.26 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/endpoints/v2 v2.6.26 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/ini v1.8.1 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/v4a v1.3.26 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/accept-encoding v1.12.1 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/checksum v1.4.7 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/presigned-url v1.12.7 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/s3shared v1.18.7 // indirect
+	github.com/aws/aws-sdk-go-v2/service/s3 v1.71.1 // indirect
+	github.com/aws/aws-sdk-go-v2/service/sso v1.24.8 // indirect
+	github.com/aws/aws-sdk-go-v2/service/ssooidc v1.28.7 // indirect
+	github.com/aws/aws-sdk-go-v2/service/sts v1.33.3 // indirect
+	github.com/aws/smithy-go v1.22.1 // indirect
+	github.com/chainguard-dev/git-urls v1.0.2 // indirect
+	github.com/cloudflare/circl v1.3.9 // indirect
+	github.com/cyphar/filepath-securejoin v0.2.5 // indirect
+	github.com/emirpasic/gods v1.18.1 // indirect
+	github.com/enterprise-contract/go-gather/expander v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/gather/file v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/gather/forge v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/gather/gdrive v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/gather/git v0.0.5 // indirect
+	github.com/enterprise-contract/go-gather/gather/github v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/gather/gitlab v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/gather/hdfs v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/gather/helm v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/gather/http v0.0.2 // indirect
+	github.com/enterprise-contract/go-gather/gather/k8s v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/gather/oci v0.0.4 // indirect
+	github.com/enterprise-contract/go-gather/gather/rsync v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/gather/s3 v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/gather/sftp v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/gather/smb v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/gather/stdin v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/metadata v0.0.2 // indirect
+	github.com/enterprise-contract/go-gather/metadata/file v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/metadata/gdrive v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/metadata/git v0.0.2 // indirect
+	github.com/enterprise-contract/go-gather/metadata/github v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/metadata/gitlab v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/metadata/hdfs v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/metadata/helm v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/metadata/http v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/metadata/k8s v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/metadata/oci v0.0.3 // indirect
+	github.com/enterprise-contract/go-gather/metadata/rsync v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/metadata/s3 v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/metadata/sftp v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/metadata/smb v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/metadata/stdin v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/saver v0.0.1 // indirect
+	github.com/enterprise-contract/go-gather/saver/file v0.0.1 // indirect
+	github.com/fsnotify/fsnotify v1.7.0 // indirect
+	github.com/geoffgarside/ber v1.1.0 // indirect
+	github.com/go-git/gcfg v1.5.1-0.20230307220236-3a3c6141e376 // indirect
+	github.com/go-git/go-billy/v5 v5.5.0 // indirect
+	github.com/go-git/go-git/v5 v5.12.0 // indirect
+	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
+	github.com/hashicorp/go-uuid v1.0.3 // indirect
+	github.com/hashicorp/hcl v1.0.0 // indirect
+	github.com/hirochachacha/go-smb2 v1.1.0 // indirect
+	github.com/jbenet/go-context v0.0.0-20150711004518-d14ea06fba99 // indirect
+	github.com/jcmturner/aescts/v2 v2.0.0 // indirect
+	github.com/jcmturner/dnsutils/v2 v2.0.0 // indirect
+	github.com/jcmturner/gofork v1.7.6 // indirect
+	github.com/jcmturner/goidentity/v6 v6.0.1 // indirect
+	github.com/jcmturner/gokrb5/v8 v8.4.4 // indirect
+	github.com/jcmturner/rpc/v2 v2.0.3 // indirect
+	github.com/kevinburke/ssh_config v1.2.0 // indirect
+	github.com/kr/fs v0.1.0 // indirect
+	github.com/magiconair/properties v1.8.7 // indirect
+	github.com/mitchellh/mapstructure v1.5.0 // indirect
+	github.com/opencontainers/go-digest v1.0.0 // indirect
+	github.com/opencontainers/image-spec v1.1.0 // indirect
+	github.com/pelletier/go-toml/v2 v2.2.2 // indirect
+	github.com/pjbgf/sha1cd v0.3.0 // indirect
+	github.com/pkg/sftp v1.13.7 // indirect
+	github.com/sagikazarmark/locafero v0.6.0 // indirect
+	github.com/sagikazarmark/slog-shim v0.1.0 // indirect
+	github.com/sergi/go-diff v1.3.2-0.20230802210424-5b0b94c5c0d3 // indirect
+	github.com/skeema/knownhosts v1.2.2 // indirect
+	github.com/sourcegraph/conc v0.3.0 // indirect
+	github.com/spf13/afero v1.11.0 // indirect
+	github.com/spf13/cast v1.6.0 // indirect
+	github.com/spf13/pflag v1.0.5 // indirect
+	github.com/spf13/viper v1.19.0 // indirect
+	github.com/subosito/gotenv v1.6.0 // indirect
+	github.com/xanzy/ssh-agent v0.3.3 // indirect
+	go.uber.org/multierr v1.11.0 // indirect
+	golang.org/x/crypto v0.24.0 // indirect
+	golang.org/x/exp v0.0.0-20240613232115-7f521ea00fb8 // indirect
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/oauth2 v0.24.0 // indirect
+	golang.org/x/sync v0.7.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	golang.org/x/time v0.8.0 // indirect
+	gopkg.in/ini.v1 v1.67.0 // indirect
+	gopkg.in/warnings.v0 v0.1.2 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+	oras.land/oras-go/v2 v2.5.0 // indirect
+)
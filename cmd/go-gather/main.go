@@ -0,0 +1,253 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Command go-gather gathers a source to a destination with the go-gather library and prints the
+// metadata of the gathered content.
+//
+// Usage:
+//
+//	go-gather [flags] SRC DST
+//
+// SRC may be "-" to gather standard input, e.g. to expand a tarball produced by another command.
+// Flags may also follow the arguments. The flags are:
+//
+//	--ref REF            git reference to check out, e.g. a branch, tag or commit
+//	--depth N            depth of the git clone, 0 clones the full history
+//	--checksum ALG:HEX   expected checksum of the gathered content, e.g. sha256:2cf24d...
+//	--archive FORMAT     format of the archive to expand, or "false" to not expand it
+//	--deterministic      normalize the gathered content so that gathers of the same source are identical
+//	--json               print the metadata as JSON
+//	--dry-run            print how the source is parsed and classified without gathering it
+//	--verbose            log diagnostic messages to standard error
+//
+// Example:
+//
+//	go-gather --json git::https://github.com/org/repo.git//policy /tmp/policy --ref v1.0.0
+//	tar -cz policy | go-gather - /tmp/policy
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/gather"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// config holds the command line of the command.
+type config struct {
+	source        string
+	destination   string
+	ref           string
+	depth         int
+	checksum      string
+	archive       string
+	deterministic bool
+	json          bool
+	dryRun        bool
+	verbose       bool
+}
+
+// parseArgs parses the command line args, which may mix flags and arguments.
+func parseArgs(args []string, stderr io.Writer) (*config, error) {
+	c := &config{}
+	fs := flag.NewFlagSet("go-gather", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: go-gather [flags] SRC DST")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&c.ref, "ref", "", "git reference to check out, e.g. a branch, tag or commit")
+	fs.IntVar(&c.depth, "depth", 0, "depth of the git clone, 0 clones the full history")
+	fs.StringVar(&c.checksum, "checksum", "", "expected checksum of the gathered content, e.g. sha256:2cf24d...")
+	fs.StringVar(&c.archive, "archive", "", `format of the archive to expand, or "false" to not expand it`)
+	fs.BoolVar(&c.deterministic, "deterministic", false, "normalize the gathered content so that gathers of the same source are identical")
+	fs.BoolVar(&c.json, "json", false, "print the metadata as JSON")
+	fs.BoolVar(&c.dryRun, "dry-run", false, "print how the source is parsed and classified without gathering it")
+	fs.BoolVar(&c.verbose, "verbose", false, "log diagnostic messages to standard error")
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	switch {
+	case c.dryRun && len(positional) == 1:
+		c.source = positional[0]
+	case len(positional) == 2:
+		c.source, c.destination = positional[0], positional[1]
+	default:
+		fs.Usage()
+		return nil, fmt.Errorf("expected a source and a destination, got %d arguments", len(positional))
+	}
+	if c.depth < 0 {
+		return nil, fmt.Errorf("invalid depth %d", c.depth)
+	}
+	return c, nil
+}
+
+// run runs the command with the command line args and returns its exit code.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	c, err := parseArgs(args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		return 2
+	}
+
+	source, err := c.sourceWithParams()
+	if err != nil {
+		fmt.Fprintln(stderr, "error:", gogather.RedactError(err))
+		return 1
+	}
+	if c.dryRun {
+		err = printSource(stdout, source)
+	} else {
+		err = c.gather(ctx, stdout, stderr, source)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		return 1
+	}
+	return 0
+}
+
+// sourceWithParams returns the source with the ref, depth, checksum and archive flags applied to
+// its parameters.
+func (c *config) sourceWithParams() (string, error) {
+	src, err := gogather.ParseSource(c.source)
+	if err != nil {
+		return "", err
+	}
+
+	base, query, _ := strings.Cut(src.URL, "?")
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the query of %s: %w", gogather.RedactURL(c.source), err)
+	}
+	if c.ref != "" {
+		values.Set("ref", c.ref)
+	}
+	if c.depth > 0 {
+		values.Set("depth", strconv.Itoa(c.depth))
+	}
+	src.URL = base
+	if len(values) > 0 {
+		src.URL += "?" + values.Encode()
+	}
+	if c.checksum != "" {
+		src.Checksum = c.checksum
+	}
+	if c.archive != "" {
+		src.Archive = c.archive
+	}
+	return src.String(), nil
+}
+
+// gather gathers source to the destination and prints the metadata of the gathered content.
+func (c *config) gather(ctx context.Context, stdout, stderr io.Writer, source string) error {
+	var opts []gather.Option
+	if c.verbose {
+		opts = append(opts, gather.WithLogger(slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	}
+	if c.deterministic {
+		opts = append(opts, gather.WithDeterministic())
+	}
+	m, err := gather.Gather(ctx, source, c.destination, opts...)
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return printJSON(stdout, m)
+	}
+	fields := m.Get()
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(stdout, "%s: %v\n", k, fields[k])
+	}
+	return nil
+}
+
+// printSource prints how source is parsed and classified as JSON.
+func printSource(w io.Writer, source string) error {
+	src, err := gogather.ParseSource(source)
+	if err != nil {
+		return err
+	}
+	return printJSON(w, struct {
+		Source   string `json:"source"`
+		Type     string `json:"type"`
+		Forced   string `json:"forced,omitempty"`
+		URL      string `json:"url"`
+		Ref      string `json:"ref,omitempty"`
+		Depth    int    `json:"depth,omitempty"`
+		Subdir   string `json:"subdir,omitempty"`
+		Archive  string `json:"archive,omitempty"`
+		Checksum string `json:"checksum,omitempty"`
+	}{
+		Source:   gogather.RedactURL(source),
+		Type:     src.Type.String(),
+		Forced:   src.Forced,
+		URL:      gogather.RedactURL(src.URL),
+		Ref:      src.Ref,
+		Depth:    src.Depth,
+		Subdir:   src.Subdir,
+		Archive:  src.Archive,
+		Checksum: src.Checksum,
+	})
+}
+
+// printJSON prints v as indented JSON.
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return nil
+}
@@ -18,7 +18,7 @@ package gogather
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -34,6 +34,19 @@ const (
 	HTTPURI
 	FileURI
 	OCIURI
+	S3URI
+	SFTPURI
+	GitHubReleaseURI
+	GitLabURI
+	GoogleDriveURI
+	RsyncURI
+	HelmURI
+	KubernetesURI
+	StdinURI
+	TorrentURI
+	HDFSURI
+	SMBURI
+	ForgeURI
 	Unknown
 )
 
@@ -41,7 +54,7 @@ var getHomeDir = os.UserHomeDir
 
 // String returns the string representation of the URLType
 func (t URIType) String() string {
-	return [...]string{"GitURI", "HTTPURI", "FileURI", "OCIURI", "Unknown"}[t]
+	return [...]string{"GitURI", "HTTPURI", "FileURI", "OCIURI", "S3URI", "SFTPURI", "GitHubReleaseURI", "GitLabURI", "GoogleDriveURI", "RsyncURI", "HelmURI", "KubernetesURI", "StdinURI", "TorrentURI", "HDFSURI", "SMBURI", "ForgeURI", "Unknown"}[t]
 }
 
 // ExpandTilde expands a leading tilde in the file path to the user's home directory
@@ -56,7 +69,38 @@ func ExpandTilde(path string) string {
 	return path
 }
 
-// ClassifyURI classifies the input string as a Git URI, HTTP(S) URI, or file path
+// windowsPathPattern matches Windows paths starting with a drive letter, e.g. "C:\policies" or
+// "C:/policies".
+var windowsPathPattern = regexp.MustCompile(`^[a-zA-Z]:[\\/]`)
+
+// LocalPath returns the local path of the file source, which is a path or a file URL, optionally
+// forced with the "file::" prefix. Windows paths with a drive letter are returned as is, and the
+// drive letter of file URLs like "file:///C:/policies" is kept, returning "C:/policies". The path
+// uses the separator of the operating system.
+func LocalPath(source string) (string, error) {
+	source = strings.TrimPrefix(source, "file::")
+	if windowsPathPattern.MatchString(source) {
+		return filepath.FromSlash(source), nil
+	}
+
+	u, err := url.Parse(source)
+	if err != nil {
+		return "", err
+	}
+	path := u.Path
+	if p := strings.TrimPrefix(path, "/"); windowsPathPattern.MatchString(p) {
+		path = p
+	}
+	return filepath.FromSlash(path), nil
+}
+
+// ScpPattern matches sources in scp notation, "user@host:path", capturing the user, the host and
+// the path, which is relative to the home directory of the user unless it starts with a slash.
+var ScpPattern = regexp.MustCompile(`^([^@/:]+)@([^@/:]+):(.*)$`)
+
+// ClassifyURI classifies the input string as a Git URI, HTTP(S) URI, or file path.
+// Sources its built-in rules do not recognize can be classified by registering a Detector, see
+// RegisterDetector.
 func ClassifyURI(input string) (URIType, error) {
 	// Check for special prefixes first
 	if strings.HasPrefix(input, "file::") {
@@ -73,6 +117,56 @@ func ClassifyURI(input string) (URIType, error) {
 		return OCIURI, nil
 	}
 
+	if strings.HasPrefix(input, "s3::") {
+		return S3URI, nil
+	}
+
+	if strings.HasPrefix(input, "sftp::") || strings.HasPrefix(input, "scp::") {
+		return SFTPURI, nil
+	}
+
+	if strings.HasPrefix(input, "gdrive::") {
+		return GoogleDriveURI, nil
+	}
+
+	if strings.HasPrefix(input, "rsync::") {
+		return RsyncURI, nil
+	}
+
+	if strings.HasPrefix(input, "helm::") {
+		return HelmURI, nil
+	}
+
+	if strings.HasPrefix(input, "k8s::") {
+		return KubernetesURI, nil
+	}
+
+	// "-" names standard input, as it does for most commands
+	if base, _, _ := strings.Cut(input, "?"); base == "-" || strings.HasPrefix(input, "stdin::") {
+		return StdinURI, nil
+	}
+
+	if strings.HasPrefix(input, "torrent::") {
+		return TorrentURI, nil
+	}
+
+	if strings.HasPrefix(input, "hdfs::") {
+		return HDFSURI, nil
+	}
+
+	if strings.HasPrefix(input, "smb::") {
+		return SMBURI, nil
+	}
+
+	if strings.HasPrefix(input, "forge::") {
+		return ForgeURI, nil
+	}
+
+	// Check the registered detectors before the built-in rules
+	if t, ok := detect(input); ok {
+		return t, nil
+	}
+
 	// Check for known git hosting services
 	if strings.HasPrefix(input, "github.com") || strings.HasPrefix(input, "gitlab.com") {
 		return GitURI, nil
@@ -81,22 +175,48 @@ func ClassifyURI(input string) (URIType, error) {
 	// Check for schemes by trying to parse the input as a URL
 	u, err := url.Parse(input)
 	if err != nil {
-		log.Printf("unable to parse input '%s' as URI: %v - this is just a notice, not an error", input, err)
+		slog.Debug("unable to parse input as URI, classifying it by pattern", "input", Redact(input), "error", err)
 	} else if u.Scheme != "" {
 		switch u.Scheme {
 		case "git":
 			return GitURI, nil
 		case "http", "https":
+			// Links to files and folders on Google Drive are gathered with the Drive API
+			if u.Host == "drive.google.com" || u.Host == "docs.google.com" {
+				return GoogleDriveURI, nil
+			}
 			return HTTPURI, nil
 		case "file":
 			return FileURI, nil
 		case "oci":
 			return OCIURI, nil
+		case "s3":
+			return S3URI, nil
+		case "sftp", "scp":
+			return SFTPURI, nil
+		case "github-release":
+			return GitHubReleaseURI, nil
+		case "gitlab":
+			return GitLabURI, nil
+		case "gdrive":
+			return GoogleDriveURI, nil
+		case "rsync", "rsync+ssh":
+			return RsyncURI, nil
+		case "k8s":
+			return KubernetesURI, nil
+		case "stdin":
+			return StdinURI, nil
+		case "magnet":
+			return TorrentURI, nil
+		case "hdfs", "webhdfs", "swebhdfs":
+			return HDFSURI, nil
+		case "smb":
+			return SMBURI, nil
 		}
 	}
 
 	// Regular expression for file paths
-	filePathPattern := regexp.MustCompile(`^(\./|\../|/|[a-zA-Z]:\\|~\/|file://).*`)
+	filePathPattern := regexp.MustCompile(`^(\./|\../|/|[a-zA-Z]:[\\/]|~\/|file://).*`)
 	// Regular expression for Git URIs
 	gitURIPattern := regexp.MustCompile(`^(git@.+|.+/[^/]*\.git(?:/.*|$))`)
 
@@ -116,6 +236,11 @@ func ClassifyURI(input string) (URIType, error) {
 		return GitURI, nil
 	}
 
+	// Check if the input uses the scp notation, e.g. "user@host:path"
+	if ScpPattern.MatchString(input) {
+		return SFTPURI, nil
+	}
+
 	// Check if the input matches any known OCI registry
 	if containsOCIRegistry(input) {
 		return OCIURI, nil
@@ -93,6 +93,10 @@ func TestClassifyURI(t *testing.T) {
 		{input: "http::https://github.com/user/repo.git", expected: HTTPURI},
 		{input: "file::/home/user/file.txt", expected: FileURI},
 		{input: "file:///home/user/file.txt", expected: FileURI},
+		{input: `C:\policies\rules`, expected: FileURI},
+		{input: "c:/policies/rules", expected: FileURI},
+		{input: "file:///C:/policies", expected: FileURI},
+		{input: `C:\repos\policy.git`, expected: GitURI},
 		{input: "/home/user/file.git", expected: GitURI},
 		{input: "https://example.com", expected: HTTPURI},
 		{input: "ftpexamplecom", expected: Unknown},
@@ -101,6 +105,33 @@ func TestClassifyURI(t *testing.T) {
 		{input: "oci::registry.gitlab.com/user/repo:latest", expected: OCIURI},
 		{input: "oci::registry.gitlab.com/user/repo", expected: OCIURI},
 		{input: "oci::registry.gitlab.com/user/repo:1.0.0", expected: OCIURI},
+		{input: "s3://bucket/path/to/file.json", expected: S3URI},
+		{input: "s3::https://minio.example.com/bucket/policies/", expected: S3URI},
+		{input: "sftp://user@example.com/srv/bundle.tar.gz", expected: SFTPURI},
+		{input: "scp://user@example.com:/srv/bundle.tar.gz", expected: SFTPURI},
+		{input: "deploy@example.com:artifacts/bundle.tar.gz", expected: SFTPURI},
+		{input: "git@example.com:org/repo", expected: GitURI},
+		{input: "github-release://org/repo/v1.0.0/bundle.tar.gz", expected: GitHubReleaseURI},
+		{input: "gitlab://gitlab.com/group/project/-/releases/v1.0.0/bundle.tar.gz", expected: GitLabURI},
+		{input: "gdrive://1a2b3c", expected: GoogleDriveURI},
+		{input: "https://drive.google.com/drive/folders/1a2b3c", expected: GoogleDriveURI},
+		{input: "https://docs.google.com/spreadsheets/d/1a2b3c/edit", expected: GoogleDriveURI},
+		{input: "rsync://mirror.example.com/policies/release/", expected: RsyncURI},
+		{input: "rsync+ssh://deploy@example.com/srv/policies/", expected: RsyncURI},
+		{input: "helm::https://charts.example.com/policies?version=1.2.3", expected: HelmURI},
+		{input: "k8s://policies/configmap/rules", expected: KubernetesURI},
+		{input: "-", expected: StdinURI},
+		{input: "-?archive=tar.gz", expected: StdinURI},
+		{input: "stdin://", expected: StdinURI},
+		{input: "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a", expected: TorrentURI},
+		{input: "torrent::https://example.com/policy.torrent", expected: TorrentURI},
+		{input: "hdfs://namenode.example.com/data/policy", expected: HDFSURI},
+		{input: "swebhdfs://namenode.example.com/data/policy", expected: HDFSURI},
+		{input: "hdfs::https://namenode.example.com:9871/data/policy", expected: HDFSURI},
+		{input: "smb://fileserver.example.com/config/policy", expected: SMBURI},
+		{input: "smb://CORP;reader@fileserver.example.com/config", expected: SMBURI},
+		{input: "forge::https://github.com/org/repo?ref=v1.0.0", expected: ForgeURI},
+		{input: "forge::https://gitlab.example.com/group/project?provider=gitlab", expected: ForgeURI},
 		{input: "oci://example.org/user/repo:latest", expected: OCIURI},
 		{input: "quay.io/user/repo:latest", expected: OCIURI},
 		{input: "127.0.0.1:5000", expected: OCIURI},
@@ -140,6 +171,32 @@ func TestClassifyURI_errors(t *testing.T) {
 	}
 }
 
+// TestLocalPath tests determining the local path of file sources
+func TestLocalPath(t *testing.T) {
+	testCases := []struct {
+		source   string
+		expected string
+	}{
+		{source: "/home/user/file.txt", expected: "/home/user/file.txt"},
+		{source: "file::/home/user/file.txt", expected: "/home/user/file.txt"},
+		{source: "file:///home/user/file%20name.txt", expected: "/home/user/file name.txt"},
+		{source: "./policies", expected: "./policies"},
+		{source: `C:\policies\rules`, expected: `C:\policies\rules`},
+		{source: "file::C:/policies", expected: "C:/policies"},
+		{source: "file:///C:/policies", expected: "C:/policies"},
+	}
+
+	for _, tc := range testCases {
+		actual, err := LocalPath(tc.source)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", tc.source, err)
+		}
+		if actual != filepath.FromSlash(tc.expected) {
+			t.Errorf("Expected LocalPath(%s) to return %s, but got %s", tc.source, filepath.FromSlash(tc.expected), actual)
+		}
+	}
+}
+
 // TestValidateFileDestination tests the ValidateFileDestination function.
 func TestValidateFileDestination(t *testing.T) {
 	testCases := []struct {
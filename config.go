@@ -0,0 +1,284 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigEnv is the environment variable naming the YAML file the default config is loaded from.
+const ConfigEnv = "GO_GATHER_CONFIG"
+
+// Config holds package-wide defaults of the gather options, applied to the options of every
+// gather that does not set them explicitly. It is loaded from a YAML file, e.g.:
+//
+//	timeout: 5m
+//	maxSize: 1073741824
+//	cacheDir: /var/cache/go-gather
+//	proxy: http://proxy.example.com:3128
+//	credentials:
+//	  quay.io:
+//	    username: robot
+//	    password: secret
+//	  "*.example.com":
+//	    password: token
+//
+// and from the environment, see ApplyEnv.
+type Config struct {
+	// Timeout is the default of GatherOptions.Timeout.
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxSize is the default of GatherOptions.MaxSize.
+	MaxSize int64 `yaml:"maxSize"`
+	// CacheDir is the default of GatherOptions.CacheDir.
+	CacheDir string `yaml:"cacheDir"`
+	// CacheMaxSize is the default of GatherOptions.CacheMaxSize.
+	CacheMaxSize int64 `yaml:"cacheMaxSize"`
+	// Proxy is the default of GatherOptions.Proxy.
+	Proxy string `yaml:"proxy"`
+	// InsecureSkipTLSVerify is the default of GatherOptions.InsecureSkipTLSVerify.
+	InsecureSkipTLSVerify bool `yaml:"insecureSkipTLSVerify"`
+	// TempDir is the default of GatherOptions.TempDir.
+	TempDir string `yaml:"tempDir"`
+	// Credentials are used for the hosts they list when GatherOptions.Auth is not set.
+	Credentials HostCredentials `yaml:"credentials"`
+}
+
+// HostCredentials is an AuthProvider holding the credentials of hosts. Hosts are matched with or
+// without their port, and keys like "*.example.com" match all subdomains of a domain. The longest
+// matching key wins.
+type HostCredentials map[string]Credentials
+
+// Credentials implements the AuthProvider interface.
+func (h HostCredentials) Credentials(_ context.Context, host string) (*Credentials, error) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+
+	host = strings.ToLower(host)
+	candidates := []string{host}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		candidates = append(candidates, hostname)
+	}
+	for _, candidate := range candidates {
+		for _, k := range keys {
+			if matchHost([]string{k}, candidate) {
+				c := h[k]
+				return &c, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// LoadConfig loads the config from the YAML file at path. Unknown keys are rejected.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	c := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// ApplyEnv overrides the settings of the config with those set in the environment:
+// GO_GATHER_TIMEOUT, e.g. "5m", GO_GATHER_MAX_SIZE, GO_GATHER_CACHE_DIR, GO_GATHER_CACHE_MAX_SIZE,
+// GO_GATHER_TEMP_DIR, GO_GATHER_PROXY and GO_GATHER_INSECURE_SKIP_TLS_VERIFY. GIT_SSL_NO_VERIFY=true, honored by git,
+// also enables InsecureSkipTLSVerify.
+func (c *Config) ApplyEnv() error {
+	if v, ok := os.LookupEnv("GO_GATHER_TIMEOUT"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid GO_GATHER_TIMEOUT: %w", err)
+		}
+		c.Timeout = d
+	}
+	for name, size := range map[string]*int64{"GO_GATHER_MAX_SIZE": &c.MaxSize, "GO_GATHER_CACHE_MAX_SIZE": &c.CacheMaxSize} {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*size = n
+		}
+	}
+	if v, ok := os.LookupEnv("GO_GATHER_CACHE_DIR"); ok {
+		c.CacheDir = v
+	}
+	if v, ok := os.LookupEnv("GO_GATHER_TEMP_DIR"); ok {
+		c.TempDir = v
+	}
+	if v, ok := os.LookupEnv("GO_GATHER_PROXY"); ok {
+		c.Proxy = v
+	}
+	if v, ok := os.LookupEnv("GO_GATHER_INSECURE_SKIP_TLS_VERIFY"); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid GO_GATHER_INSECURE_SKIP_TLS_VERIFY: %w", err)
+		}
+		c.InsecureSkipTLSVerify = b
+	}
+	if os.Getenv("GIT_SSL_NO_VERIFY") == "true" {
+		c.InsecureSkipTLSVerify = true
+	}
+	return nil
+}
+
+// Apply returns o with the settings it does not set taken from the config.
+func (c *Config) Apply(o GatherOptions) GatherOptions {
+	if c == nil {
+		return o
+	}
+	if o.Timeout == 0 {
+		o.Timeout = c.Timeout
+	}
+	if o.MaxSize == 0 {
+		o.MaxSize = c.MaxSize
+	}
+	if o.CacheDir == "" {
+		o.CacheDir = c.CacheDir
+	}
+	if o.CacheMaxSize == 0 {
+		o.CacheMaxSize = c.CacheMaxSize
+	}
+	if o.TempDir == "" {
+		o.TempDir = c.TempDir
+	}
+	if o.Proxy == "" {
+		o.Proxy = c.Proxy
+	}
+	if !o.InsecureSkipTLSVerify {
+		o.InsecureSkipTLSVerify = c.InsecureSkipTLSVerify
+	}
+	if o.Auth == nil && len(c.Credentials) > 0 {
+		o.Auth = c.Credentials
+	}
+	return o
+}
+
+var defaults struct {
+	mu     sync.Mutex
+	loaded bool
+	config *Config
+	err    error
+}
+
+// DefaultConfig returns the package-wide defaults: the config set with SetDefaultConfig or, on
+// first use, the config loaded from the file named by the GO_GATHER_CONFIG environment variable,
+// if set, with the environment applied, see ApplyEnv.
+func DefaultConfig() (*Config, error) {
+	defaults.mu.Lock()
+	defer defaults.mu.Unlock()
+	if !defaults.loaded {
+		defaults.config, defaults.err = loadDefaultConfig()
+		defaults.loaded = true
+	}
+	return defaults.config, defaults.err
+}
+
+// SetDefaultConfig replaces the package-wide defaults with c. A nil c reloads them from the
+// environment on next use.
+func SetDefaultConfig(c *Config) {
+	defaults.mu.Lock()
+	defer defaults.mu.Unlock()
+	defaults.config, defaults.err, defaults.loaded = c, nil, c != nil
+}
+
+// loadDefaultConfig loads the config from the file named by GO_GATHER_CONFIG and the environment.
+func loadDefaultConfig() (*Config, error) {
+	c := &Config{}
+	if path := os.Getenv(ConfigEnv); path != "" {
+		var err error
+		if c, err = LoadConfig(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// applyDefaults returns o with the package-wide defaults applied. Invalid defaults are ignored, see
+// DefaultConfig for surfacing them.
+func applyDefaults(o GatherOptions) GatherOptions {
+	c, err := DefaultConfig()
+	if err != nil {
+		return o
+	}
+	return c.Apply(o)
+}
+
+// Transport returns a copy of base configured for the options, see ConfigureTransport. base is
+// returned as is if the options configure nothing or it is not an *http.Transport.
+func (o GatherOptions) Transport(base http.RoundTripper) (http.RoundTripper, error) {
+	t, ok := base.(*http.Transport)
+	if !ok || (o.Proxy == "" && !o.InsecureSkipTLSVerify && !o.HostPolicy.denyPrivateNetworks()) {
+		return base, nil
+	}
+	t = t.Clone()
+	if err := o.ConfigureTransport(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ConfigureTransport applies the Proxy and InsecureSkipTLSVerify of the options to t, e.g. for
+// clients that build their own transport. If the HostPolicy of the options denies private
+// networks, t also checks the address of every connection it opens, other than to its proxy,
+// replacing its DialContext.
+func (o GatherOptions) ConfigureTransport(t *http.Transport) error {
+	if o.Proxy != "" {
+		u, err := url.Parse(o.Proxy)
+		if err != nil {
+			return fmt.Errorf("invalid proxy %s: %w", RedactURL(o.Proxy), err)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	if o.InsecureSkipTLSVerify {
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		t.TLSClientConfig.InsecureSkipVerify = true // #nosec G402 -- explicitly requested
+	}
+	if o.HostPolicy.denyPrivateNetworks() {
+		denyPrivateDials(t)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestLoadConfig tests loading the config from a YAML file
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `timeout: 5m
+maxSize: 1024
+cacheDir: /var/cache/go-gather
+proxy: http://proxy.example.com:3128
+insecureSkipTLSVerify: true
+credentials:
+  quay.io:
+    username: robot
+    password: secret
+`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Timeout != 5*time.Minute || c.MaxSize != 1024 || c.CacheDir != "/var/cache/go-gather" || c.Proxy != "http://proxy.example.com:3128" || !c.InsecureSkipTLSVerify {
+		t.Errorf("unexpected config: %+v", c)
+	}
+	if creds := c.Credentials["quay.io"]; creds.Username != "robot" || creds.Password != "secret" {
+		t.Errorf("unexpected credentials: %+v", creds)
+	}
+
+	if err := os.WriteFile(path, []byte("unknown: true\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
+
+// TestConfig_ApplyEnv tests overriding the config with the environment
+func TestConfig_ApplyEnv(t *testing.T) {
+	t.Setenv("GO_GATHER_TIMEOUT", "30s")
+	t.Setenv("GO_GATHER_MAX_SIZE", "2048")
+	t.Setenv("GO_GATHER_CACHE_DIR", "/tmp/cache")
+	t.Setenv("GO_GATHER_PROXY", "http://proxy")
+	t.Setenv("GO_GATHER_TEMP_DIR", "/scratch")
+	t.Setenv("GIT_SSL_NO_VERIFY", "true")
+
+	c := &Config{Timeout: time.Minute, CacheMaxSize: 4096}
+	if err := c.ApplyEnv(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Config{Timeout: 30 * time.Second, MaxSize: 2048, CacheDir: "/tmp/cache", CacheMaxSize: 4096, TempDir: "/scratch", Proxy: "http://proxy", InsecureSkipTLSVerify: true}
+	if c.Timeout != want.Timeout || c.MaxSize != want.MaxSize || c.CacheDir != want.CacheDir || c.CacheMaxSize != want.CacheMaxSize || c.TempDir != want.TempDir || c.Proxy != want.Proxy || !c.InsecureSkipTLSVerify {
+		t.Errorf("unexpected config: got %+v, want %+v", c, want)
+	}
+
+	t.Setenv("GO_GATHER_MAX_SIZE", "lots")
+	if err := c.ApplyEnv(); err == nil {
+		t.Error("expected an error for an invalid size")
+	}
+}
+
+// TestConfig_Apply tests that the config only fills the options that are not set
+func TestConfig_Apply(t *testing.T) {
+	c := &Config{Timeout: time.Minute, MaxSize: 1024, CacheDir: "/tmp/cache", Credentials: HostCredentials{"example.com": {Password: "token"}}}
+	o := c.Apply(GatherOptions{MaxSize: 10})
+	if o.Timeout != time.Minute || o.MaxSize != 10 || o.CacheDir != "/tmp/cache" || o.Auth == nil {
+		t.Errorf("unexpected options: %+v", o)
+	}
+
+	auth := HostCredentials{"example.com": {Password: "explicit"}}
+	if creds, err := c.Apply(GatherOptions{Auth: auth}).Credentials(context.Background(), "example.com"); err != nil || creds.Password != "explicit" {
+		t.Errorf("unexpected credentials: %+v, %v", creds, err)
+	}
+	if o := (*Config)(nil).Apply(GatherOptions{MaxSize: 10}); o.MaxSize != 10 || o.Timeout != 0 {
+		t.Errorf("unexpected options: %+v", o)
+	}
+}
+
+// TestHostCredentials tests looking up the credentials of hosts
+func TestHostCredentials(t *testing.T) {
+	h := HostCredentials{
+		"registry.example.com:5000": {Password: "port"},
+		"registry.example.com":      {Password: "host"},
+		"*.example.com":             {Password: "domain"},
+	}
+	tests := map[string]string{
+		"registry.example.com:5000": "port",
+		"registry.example.com:443":  "host",
+		"Registry.Example.com":      "host",
+		"git.example.com":           "domain",
+		"example.org":               "",
+	}
+	for host, want := range tests {
+		creds, err := h.Credentials(context.Background(), host)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := ""; creds != nil {
+			got = creds.Password
+			if got != want {
+				t.Errorf("%s: got %q, want %q", host, got, want)
+			}
+		} else if want != "" {
+			t.Errorf("%s: got no credentials, want %q", host, want)
+		}
+	}
+}
+
+// TestDefaultConfig tests loading the package-wide defaults and applying them to options
+func TestDefaultConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("maxSize: 1024\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(ConfigEnv, path)
+	t.Setenv("GO_GATHER_TIMEOUT", "1m")
+	SetDefaultConfig(nil)
+	t.Cleanup(func() { SetDefaultConfig(nil) })
+
+	c, err := DefaultConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.MaxSize != 1024 || c.Timeout != time.Minute {
+		t.Errorf("unexpected config: %+v", c)
+	}
+	if o := OptionsFromContext(context.Background()); o.MaxSize != 1024 || o.Timeout != time.Minute {
+		t.Errorf("unexpected options: %+v", o)
+	}
+
+	SetDefaultConfig(&Config{CacheDir: "/tmp/cache"})
+	if o := OptionsFromContext(context.Background()); o.CacheDir != "/tmp/cache" || o.MaxSize != 0 {
+		t.Errorf("unexpected options: %+v", o)
+	}
+}
+
+// TestGatherOptions_Transport tests configuring transports with the proxy and TLS settings
+func TestGatherOptions_Transport(t *testing.T) {
+	base := &http.Transport{}
+	if rt, err := (GatherOptions{}).Transport(base); err != nil || rt != base {
+		t.Errorf("expected the base transport, got %v, %v", rt, err)
+	}
+
+	rt, err := GatherOptions{Proxy: "http://proxy.example.com:3128", InsecureSkipTLSVerify: true}.Transport(base)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tr := rt.(*http.Transport)
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	if u, err := tr.Proxy(req); err != nil || u.Host != "proxy.example.com:3128" {
+		t.Errorf("unexpected proxy: %v, %v", u, err)
+	}
+	if tr.TLSClientConfig == nil || !tr.TLSClientConfig.InsecureSkipVerify {
+		t.Errorf("unexpected TLS config: %+v", tr.TLSClientConfig)
+	}
+	if base.TLSClientConfig != nil && base.TLSClientConfig.InsecureSkipVerify {
+		t.Error("base transport modified")
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Detector classifies sources for ClassifyURI, e.g. to recognize the host names of private
+// registries or internal git servers that the built-in rules do not know about.
+type Detector interface {
+	// Detect returns the type of input and true if the detector recognizes input, or false to
+	// leave it to the detectors registered after it and the built-in rules.
+	Detect(input string) (URIType, bool)
+}
+
+// DetectorFunc is a Detector implemented by a function.
+type DetectorFunc func(input string) (URIType, bool)
+
+func (f DetectorFunc) Detect(input string) (URIType, bool) {
+	return f(input)
+}
+
+type namedDetector struct {
+	name     string
+	detector Detector
+}
+
+var (
+	detectorsMu sync.RWMutex
+	detectors   []namedDetector
+)
+
+// RegisterDetector registers the detector d under name. ClassifyURI consults the registered
+// detectors in the order they were registered, after the forced "<protocol>::" prefixes and before
+// its built-in rules. Registering a name that is already registered replaces its detector, keeping
+// its position.
+func RegisterDetector(name string, d Detector) error {
+	if name == "" {
+		return fmt.Errorf("detector name is empty")
+	}
+	if d == nil {
+		return fmt.Errorf("detector %s is nil", name)
+	}
+
+	detectorsMu.Lock()
+	defer detectorsMu.Unlock()
+	if i := slices.IndexFunc(detectors, func(n namedDetector) bool { return n.name == name }); i >= 0 {
+		detectors[i].detector = d
+		return nil
+	}
+	detectors = append(detectors, namedDetector{name: name, detector: d})
+	return nil
+}
+
+// UnregisterDetector removes the detector registered under name, if any.
+func UnregisterDetector(name string) {
+	detectorsMu.Lock()
+	defer detectorsMu.Unlock()
+	detectors = slices.DeleteFunc(detectors, func(n namedDetector) bool { return n.name == name })
+}
+
+// detect classifies input with the registered detectors.
+func detect(input string) (URIType, bool) {
+	detectorsMu.RLock()
+	defer detectorsMu.RUnlock()
+	for _, n := range detectors {
+		if t, ok := n.detector.Detect(input); ok {
+			return t, true
+		}
+	}
+	return Unknown, false
+}
+
+// HostDetector returns a Detector classifying sources on one of hosts as t, whether they have a
+// scheme or not, e.g. HostDetector(OCIURI, "registry.example.com") classifies
+// "registry.example.com/org/bundle:v1". Hosts are matched like the hosts of a HostPolicy, so
+// "*.example.com" matches every host below example.com.
+func HostDetector(t URIType, hosts ...string) Detector {
+	return DetectorFunc(func(input string) (URIType, bool) {
+		host := SourceHost(input)
+		return t, host != "" && matchHost(hosts, host)
+	})
+}
+
+// SchemeDetector returns a Detector classifying sources with one of schemes as t, e.g.
+// SchemeDetector(GitURI, "gitea") classifies "gitea://git.example.com/org/repo".
+func SchemeDetector(t URIType, schemes ...string) Detector {
+	return DetectorFunc(func(input string) (URIType, bool) {
+		u, err := url.Parse(input)
+		if err != nil || u.Scheme == "" {
+			return Unknown, false
+		}
+		return t, slices.ContainsFunc(schemes, func(s string) bool { return strings.EqualFold(s, u.Scheme) })
+	})
+}
+
+// SourceHost returns the lower case host of a source, with or without a forced protocol or a
+// scheme, e.g. "github.com" for "https://github.com/org/repo", "git@github.com:org/repo.git" or
+// "git::github.com/org/repo". It returns an empty string for local paths starting with a slash.
+func SourceHost(input string) string {
+	if m := forcedProtocolPattern.FindStringSubmatch(input); m != nil {
+		input = m[2]
+	}
+	if _, rest, ok := strings.Cut(input, "://"); ok {
+		input = rest
+	}
+	host, _, _ := strings.Cut(input, "/")
+	host, _, _ = strings.Cut(host, "?")
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	} else if h, _, ok := strings.Cut(host, ":"); ok && !strings.Contains(host, "]") {
+		// The path of an scp-like git source, e.g. "git@github.com:org/repo.git".
+		host = h
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
@@ -0,0 +1,98 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import "testing"
+
+// TestRegisterDetector tests classifying sources with registered detectors
+func TestRegisterDetector(t *testing.T) {
+	t.Cleanup(func() {
+		UnregisterDetector("registry")
+		UnregisterDetector("git")
+		UnregisterDetector("scheme")
+	})
+
+	if got, err := ClassifyURI("registry.example.com/org/bundle:v1"); err == nil || got != Unknown {
+		t.Fatalf("expected the source not to be classified, got: %v, %v", got, err)
+	}
+
+	if err := RegisterDetector("registry", HostDetector(OCIURI, "registry.example.com", "*.registry.internal")); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterDetector("git", HostDetector(GitURI, "git.example.com", "*.registry.internal")); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterDetector("scheme", SchemeDetector(GitURI, "gitea")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input string
+		want  URIType
+	}{
+		{input: "registry.example.com/org/bundle:v1", want: OCIURI},
+		{input: "registry.example.com:5000/org/bundle", want: OCIURI},
+		{input: "eu.registry.internal/org/bundle", want: OCIURI},
+		{input: "git.example.com/org/repo", want: GitURI},
+		{input: "git@git.example.com:org/repo", want: GitURI},
+		{input: "https://git.example.com/org/repo", want: GitURI},
+		{input: "gitea://example.com/org/repo", want: GitURI},
+		{input: "http::registry.example.com/file.txt", want: HTTPURI},
+		{input: "https://example.com/file.txt", want: HTTPURI},
+	}
+	for _, tt := range tests {
+		if got, err := ClassifyURI(tt.input); err != nil || got != tt.want {
+			t.Errorf("ClassifyURI(%q) = %v, %v, want %v", tt.input, got, err, tt.want)
+		}
+	}
+
+	// Replacing a detector keeps its position.
+	if err := RegisterDetector("registry", HostDetector(HTTPURI, "registry.example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ClassifyURI("eu.registry.internal/org/bundle"); got != GitURI {
+		t.Errorf("expected the replaced detector to no longer match, got: %v", got)
+	}
+	if got, _ := ClassifyURI("registry.example.com/org/bundle"); got != HTTPURI {
+		t.Errorf("expected the replaced detector to match, got: %v", got)
+	}
+
+	if err := RegisterDetector("", SchemeDetector(GitURI, "x")); err == nil {
+		t.Error("expected an error for an empty name")
+	}
+	if err := RegisterDetector("nil", nil); err == nil {
+		t.Error("expected an error for a nil detector")
+	}
+}
+
+// TestSourceHost tests determining the host of sources
+func TestSourceHost(t *testing.T) {
+	tests := map[string]string{
+		"https://GitHub.com/org/repo":        "github.com",
+		"git@github.com:org/repo.git":        "github.com",
+		"git::github.com/org/repo?ref=main":  "github.com",
+		"oci::quay.io:443/org/bundle:v1":     "quay.io",
+		"https://user:pass@[::1]:8080/x.txt": "::1",
+		"file:///tmp/policy":                 "",
+		"/tmp/policy":                        "",
+	}
+	for source, want := range tests {
+		if got := SourceHost(source); got != want {
+			t.Errorf("SourceHost(%s) = %q, want %q", source, got, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Digests records the SHA256 digests of files while they are written, so that hashing the written
+// content afterwards, e.g. to compute its tree hash, does not have to read the files back, see
+// metadata.TreeHashWithDigests. A recorded digest is only used while the size of the file is the
+// one it was recorded with, so files rewritten after they were recorded must be recorded again.
+// Paths are cleaned before they are recorded or looked up. A nil Digests records nothing. It is
+// safe for concurrent use.
+type Digests struct {
+	mu      sync.Mutex
+	entries map[string]digestEntry
+}
+
+// digestEntry is the digest of a file of the given size.
+type digestEntry struct {
+	size int64
+	sum  []byte
+}
+
+// NewDigests returns an empty Digests.
+func NewDigests() *Digests {
+	return &Digests{entries: map[string]digestEntry{}}
+}
+
+// Record records sum as the SHA256 digest of the size bytes written to the file at path.
+func (d *Digests) Record(path string, size int64, sum []byte) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries[filepath.Clean(path)] = digestEntry{size: size, sum: sum}
+}
+
+// SHA256 returns the recorded SHA256 digest of the regular file at path, and whether one was
+// recorded for its current size.
+func (d *Digests) SHA256(path string) ([]byte, bool) {
+	if d == nil {
+		return nil, false
+	}
+	d.mu.Lock()
+	e, ok := d.entries[filepath.Clean(path)]
+	d.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != e.size {
+		return nil, false
+	}
+	return e.sum, true
+}
+
+// Move records the digests of the files below the directory or the file from as those of the
+// same files below to, after from has been renamed to to.
+func (d *Digests) Move(from, to string) {
+	if d == nil {
+		return
+	}
+	from, to = filepath.Clean(from), filepath.Clean(to)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	moved := map[string]digestEntry{}
+	for path, e := range d.entries {
+		if path == from {
+			moved[to] = e
+		} else if rest, ok := strings.CutPrefix(path, from+string(filepath.Separator)); ok {
+			moved[filepath.Join(to, rest)] = e
+		} else {
+			continue
+		}
+		delete(d.entries, path)
+	}
+	for path, e := range moved {
+		d.entries[path] = e
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestDigests tests recording and looking up the digests of written files
+func TestDigests(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "a.txt")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := []byte("digest")
+
+	d := NewDigests()
+	if _, ok := d.SHA256(path); ok {
+		t.Error("expected no digest before it is recorded")
+	}
+	d.Record(path, 5, sum)
+	if got, ok := d.SHA256(filepath.Join(dir, "sub", ".", "a.txt")); !ok || !bytes.Equal(got, sum) {
+		t.Errorf("unexpected digest: %q, %v", got, ok)
+	}
+
+	if err := os.WriteFile(path, []byte("hello world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.SHA256(path); ok {
+		t.Error("expected no digest for a file of another size")
+	}
+	d.Record(path, 11, sum)
+
+	moved := filepath.Join(dir, "moved")
+	if err := os.Rename(filepath.Join(dir, "sub"), moved); err != nil {
+		t.Fatal(err)
+	}
+	d.Move(filepath.Join(dir, "sub"), moved)
+	if got, ok := d.SHA256(filepath.Join(moved, "a.txt")); !ok || !bytes.Equal(got, sum) {
+		t.Errorf("unexpected digest of the moved file: %q, %v", got, ok)
+	}
+	if _, ok := d.SHA256(path); ok {
+		t.Error("expected no digest for the old path")
+	}
+
+	var nilDigests *Digests
+	nilDigests.Record(path, 5, sum)
+	nilDigests.Move(path, moved)
+	if _, ok := nilDigests.SHA256(path); ok {
+		t.Error("expected no digest from nil digests")
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/bodgit/sevenzip"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// maxSymlinkTargetSize is the maximum length of a symbolic link target read from an archive
+// that stores the target as the content of the link member.
+const maxSymlinkTargetSize = 4096
+
+// SevenZipExpander expands 7-Zip archives (.7z).
+type SevenZipExpander struct {
+	FileSizeLimit int64
+	FilesLimit    int
+	Options       ExtractOptions
+	// MaxCompressionRatio aborts the expansion when the expanded content exceeds this
+	// multiple of the size of the archive. Zero disables the check.
+	MaxCompressionRatio float64
+}
+
+func (s *SevenZipExpander) Expand(dst, src string, dir bool, umask os.FileMode) error {
+	if dir {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+	} else if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	r, err := sevenzip.OpenReader(src)
+	if err != nil {
+		return fmt.Errorf("failed to open 7z file (%s): %w", src, err)
+	}
+	defer r.Close()
+
+	if !dir {
+		return un7z(&r.Reader, info.Size(), dst, src, dir, umask, s.FileSizeLimit, s.FilesLimit, s.MaxCompressionRatio, s.Options)
+	}
+	return s.Options.expandDir(dst, func(dst string) error {
+		return un7z(&r.Reader, info.Size(), dst, src, dir, umask, s.FileSizeLimit, s.FilesLimit, s.MaxCompressionRatio, s.Options)
+	})
+}
+
+// un7z is a helper function that expands a 7-Zip archive of archiveSize bytes to a destination directory
+func un7z(r *sevenzip.Reader, archiveSize int64, dst, src string, dir bool, umask os.FileMode, fileSizeLimit int64, filesLimit int, maxRatio float64, opts ExtractOptions) error {
+	if len(r.File) == 0 {
+		return fmt.Errorf("7z file is empty: %s", src)
+	}
+
+	if filesLimit > 0 && len(r.File) > filesLimit {
+		return fmt.Errorf("7z file contains more files than the %d allowed: %d", filesLimit, len(r.File))
+	}
+
+	filter, err := opts.newMemberFilter()
+	if err != nil {
+		return err
+	}
+
+	members := make([]*sevenzip.File, 0, len(r.File))
+	for _, f := range r.File {
+		if filter.Match(f.Name) {
+			members = append(members, f)
+		}
+	}
+
+	if !dir && len(members) > 1 {
+		return fmt.Errorf("7z file contains more than one file: %s", src)
+	}
+
+	var root string
+	if dir {
+		if root, err = resolvedRoot(dst); err != nil {
+			return err
+		}
+	}
+
+	var fileSize, extracted int64
+
+	for _, f := range members {
+		fPath := dst
+
+		if dir {
+			if containsDotDot(f.Name) {
+				return fmt.Errorf("7z file (%s) would escape destination directory", f.Name)
+			}
+
+			fPath = filepath.Join(dst, f.Name) // nolint:gosec
+		}
+
+		fileInfo := f.FileInfo()
+		isLink := fileInfo.Mode()&fs.ModeSymlink != 0
+		if isLink && opts.Links == SkipLinks {
+			continue
+		}
+
+		if err := opts.visit(Entry{Name: f.Name, Size: fileInfo.Size(), Mode: fileInfo.Mode()}); err != nil {
+			return err
+		}
+
+		fileSize += fileInfo.Size()
+
+		if fileSizeLimit > 0 && fileSize > fileSizeLimit {
+			return sizeLimitError(fileSizeLimit, "7z file size exceeds the %d limit: %d", fileSizeLimit, fileSize)
+		}
+
+		if fileInfo.IsDir() {
+			if !dir {
+				return fmt.Errorf("expected a file (%s), got a directory: %s", src, fPath)
+			}
+
+			if err := checkWithin(root, fPath); err != nil {
+				return err
+			}
+
+			if err := os.MkdirAll(fPath, umask); err != nil {
+				return fmt.Errorf("failed to create directory (%s): %s", fPath, err)
+			}
+
+			continue
+		}
+
+		if isLink {
+			if opts.Links == RejectLinks {
+				return fmt.Errorf("7z file contains a link (%s), which is not allowed", f.Name)
+			}
+			if !dir {
+				return fmt.Errorf("expected a file (%s), got a link: %s", src, f.Name)
+			}
+		}
+
+		destPath := filepath.Dir(fPath)
+		if dir {
+			if err := checkWithin(root, destPath); err != nil {
+				return err
+			}
+		}
+		if _, err := os.Stat(destPath); os.IsNotExist(err) {
+			if err := os.MkdirAll(destPath, umask); err != nil {
+				return fmt.Errorf("failed to create directory (%s): %s", destPath, err)
+			}
+		}
+
+		if isLink {
+			target, err := read7zLinkTarget(f)
+			if err != nil {
+				return err
+			}
+			if err := createSymlink(root, fPath, target); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if dir {
+			if err := removeNonDirectory(fPath); err != nil {
+				return err
+			}
+		}
+
+		n, err := un7zFile(f, fPath, umask, fileSizeLimit, archiveSize, extracted, maxRatio, opts.Digests)
+		if err != nil {
+			return err
+		}
+		extracted += n
+
+		if mTime := f.Modified; mTime.Unix() > 0 && !opts.IgnoreTimes {
+			if err := os.Chtimes(fPath, mTime, mTime); err != nil {
+				return fmt.Errorf("failed to change file times (%s): %s", fPath, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// un7zFile copies a single 7z member to fPath and returns the number of bytes copied. As members of
+// solid archives share compressed streams, the compression ratio is computed from the bytes extracted
+// so far, including those of previous members, and the size of the whole archive.
+func un7zFile(f *sevenzip.File, fPath string, umask os.FileMode, fileSizeLimit, archiveSize, extracted int64, maxRatio float64, digests *gogather.Digests) (int64, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return 0, fmt.Errorf("failed to open 7z member (%s): %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	counter := &countingReader{r: rc}
+	var r io.Reader = counter
+	if maxRatio > 0 {
+		r = &ratioReader{r: counter, compressed: func() int64 { return archiveSize }, decompressed: extracted, limit: maxRatio}
+	}
+
+	if err := copyReader(r, fPath, umask, int64(f.UncompressedSize), fileSizeLimit, digests); err != nil {
+		return 0, err
+	}
+	return counter.n, nil
+}
+
+// read7zLinkTarget reads the target of a symbolic link member, which 7-Zip stores as the content of the member.
+func read7zLinkTarget(f *sevenzip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open 7z member (%s): %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	target, err := io.ReadAll(io.LimitReader(rc, maxSymlinkTargetSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read 7z member (%s): %w", f.Name, err)
+	}
+	if len(target) > maxSymlinkTargetSize {
+		return "", fmt.Errorf("symlink (%s) target exceeds %d bytes", f.Name, maxSymlinkTargetSize)
+	}
+	return string(target), nil
+}
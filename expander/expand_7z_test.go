@@ -0,0 +1,104 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"strings"
+	"testing"
+)
+
+// sample7z is a 7z archive containing policy/main.rego and policy/lib/helper.rego, stored
+// uncompressed, since neither the standard library nor sevenzip provide a 7z writer.
+var sample7z = []byte{
+	0x37, 0x7a, 0xbc, 0xaf, 0x27, 0x1c, 0x00, 0x04, 0x38, 0x83, 0xb6, 0x56, 0x17, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x9b, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1c, 0xb7, 0xf5, 0xd5,
+	0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x20, 0x6d, 0x61, 0x69, 0x6e, 0x70, 0x61, 0x63, 0x6b,
+	0x61, 0x67, 0x65, 0x20, 0x6c, 0x69, 0x62, 0x01, 0x04, 0x06, 0x00, 0x01, 0x09, 0x17, 0x00, 0x07,
+	0x0b, 0x01, 0x00, 0x01, 0x01, 0x00, 0x0c, 0x17, 0x00, 0x08, 0x0d, 0x02, 0x09, 0x0c, 0x0a, 0x01,
+	0xb2, 0xdf, 0xe7, 0x7f, 0xb3, 0x40, 0x71, 0xdc, 0x00, 0x00, 0x05, 0x03, 0x0e, 0x01, 0x80, 0x11,
+	0x5f, 0x00, 0x70, 0x00, 0x6f, 0x00, 0x6c, 0x00, 0x69, 0x00, 0x63, 0x00, 0x79, 0x00, 0x00, 0x00,
+	0x70, 0x00, 0x6f, 0x00, 0x6c, 0x00, 0x69, 0x00, 0x63, 0x00, 0x79, 0x00, 0x2f, 0x00, 0x6d, 0x00,
+	0x61, 0x00, 0x69, 0x00, 0x6e, 0x00, 0x2e, 0x00, 0x72, 0x00, 0x65, 0x00, 0x67, 0x00, 0x6f, 0x00,
+	0x00, 0x00, 0x70, 0x00, 0x6f, 0x00, 0x6c, 0x00, 0x69, 0x00, 0x63, 0x00, 0x79, 0x00, 0x2f, 0x00,
+	0x6c, 0x00, 0x69, 0x00, 0x62, 0x00, 0x2f, 0x00, 0x68, 0x00, 0x65, 0x00, 0x6c, 0x00, 0x70, 0x00,
+	0x65, 0x00, 0x72, 0x00, 0x2e, 0x00, 0x72, 0x00, 0x65, 0x00, 0x67, 0x00, 0x6f, 0x00, 0x00, 0x00,
+	0x15, 0x0e, 0x01, 0x00, 0x10, 0x80, 0xed, 0x41, 0x00, 0x80, 0xa4, 0x81, 0x00, 0x80, 0xa4, 0x81,
+	0x00, 0x00,
+}
+
+// escape7z is a 7z archive containing a member named ../escape.txt.
+var escape7z = []byte{
+	0x37, 0x7a, 0xbc, 0xaf, 0x27, 0x1c, 0x00, 0x04, 0x12, 0x00, 0x5f, 0x79, 0x06, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x48, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x49, 0xb0, 0x24, 0xdf,
+	0x65, 0x73, 0x63, 0x61, 0x70, 0x65, 0x01, 0x04, 0x06, 0x00, 0x01, 0x09, 0x06, 0x00, 0x07, 0x0b,
+	0x01, 0x00, 0x01, 0x01, 0x00, 0x0c, 0x06, 0x00, 0x08, 0x0d, 0x01, 0x0a, 0x01, 0x8e, 0xb0, 0xe8,
+	0x25, 0x00, 0x00, 0x05, 0x01, 0x11, 0x1d, 0x00, 0x2e, 0x00, 0x2e, 0x00, 0x2f, 0x00, 0x65, 0x00,
+	0x73, 0x00, 0x63, 0x00, 0x61, 0x00, 0x70, 0x00, 0x65, 0x00, 0x2e, 0x00, 0x74, 0x00, 0x78, 0x00,
+	0x74, 0x00, 0x00, 0x00, 0x15, 0x06, 0x01, 0x00, 0x00, 0x80, 0xa4, 0x81, 0x00, 0x00,
+}
+
+// TestSevenZipExpander_Expand tests expanding 7z archives
+func TestSevenZipExpander_Expand(t *testing.T) {
+	e, ok := FindExpander("bundle.7z", BaseExpanders(0, 0))
+	if !ok {
+		t.Fatal("no expander found for 7z")
+	}
+	dst, err := expandFixture(t, e, "bundle.7z", sample7z)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	files := listFiles(t, dst)
+	expected := []string{"policy/lib/helper.rego", "policy/main.rego"}
+	if strings.Join(files, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected files: got %v, want %v", files, expected)
+	}
+}
+
+// TestSevenZipExpander_Expand_Errors tests that the safety limits apply to 7z archives
+func TestSevenZipExpander_Expand_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expander *SevenZipExpander
+		expected string
+	}{
+		{"escape", escape7z, &SevenZipExpander{}, "would escape destination directory"},
+		{"files limit", sample7z, &SevenZipExpander{FilesLimit: 2}, "more files than the 2 allowed: 3"},
+		{"size limit", sample7z, &SevenZipExpander{FileSizeLimit: 12}, "size exceeds the 12 limit"},
+		{"corrupt", []byte("not a 7z archive"), &SevenZipExpander{}, "failed to open 7z file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := expandFixture(t, tt.expander, "bundle.7z", tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q, got: %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+// TestSevenZipExpander_Expand_Filters tests that filters are applied to 7z archives
+func TestSevenZipExpander_Expand_Filters(t *testing.T) {
+	e := &SevenZipExpander{Options: ExtractOptions{Exclude: []string{"**/lib/**"}}}
+	dst, err := expandFixture(t, e, "bundle.7z", sample7z)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if files := strings.Join(listFiles(t, dst), ","); files != "policy/main.rego" {
+		t.Errorf("unexpected files extracted: %s", files)
+	}
+}
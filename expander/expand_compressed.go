@@ -0,0 +1,124 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// decompressFile decompresses the single compressed file src. If dir is true, dst is a directory
+// and the decompressed file is written into it, named after src without the ext extension,
+// e.g. "foo.json.gz" becomes "foo.json". Otherwise dst is the path of the decompressed file.
+func decompressFile(dst, src, ext string, dir bool, umask os.FileMode, fileSizeLimit int64, maxRatio float64, newReader func(io.Reader) (io.Reader, error)) error {
+	fPath := dst
+	if dir {
+		name := strings.TrimSuffix(filepath.Base(src), "."+ext)
+		if name == "" || name == filepath.Base(src) {
+			return fmt.Errorf("unable to determine the decompressed file name for %s", src)
+		}
+		fPath = filepath.Join(dst, name)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory (%s): %s", filepath.Dir(fPath), err)
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := limitRatio(newReader, maxRatio)(f)
+	if err != nil {
+		return fmt.Errorf("failed to read %s file (%s): %w", ext, src, err)
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	return copyReader(r, fPath, umask, -1, fileSizeLimit, nil)
+}
+
+// GzipExpander decompresses a single gzip compressed file.
+type GzipExpander struct {
+	FileSizeLimit int64
+	// MaxCompressionRatio aborts the decompression when the decompressed size exceeds
+	// this multiple of the compressed size. Zero disables the check.
+	MaxCompressionRatio float64
+}
+
+func (g *GzipExpander) Expand(dst, src string, dir bool, umask os.FileMode) error {
+	return decompressFile(dst, src, "gz", dir, umask, g.FileSizeLimit, g.MaxCompressionRatio, func(r io.Reader) (io.Reader, error) {
+		return gzip.NewReader(r)
+	})
+}
+
+// Bzip2Expander decompresses a single bzip2 compressed file.
+type Bzip2Expander struct {
+	FileSizeLimit int64
+	// MaxCompressionRatio aborts the decompression when the decompressed size exceeds
+	// this multiple of the compressed size. Zero disables the check.
+	MaxCompressionRatio float64
+}
+
+func (b *Bzip2Expander) Expand(dst, src string, dir bool, umask os.FileMode) error {
+	return decompressFile(dst, src, "bz2", dir, umask, b.FileSizeLimit, b.MaxCompressionRatio, func(r io.Reader) (io.Reader, error) {
+		return bzip2.NewReader(r), nil
+	})
+}
+
+// XzExpander decompresses a single xz compressed file.
+type XzExpander struct {
+	FileSizeLimit int64
+	// MaxCompressionRatio aborts the decompression when the decompressed size exceeds
+	// this multiple of the compressed size. Zero disables the check.
+	MaxCompressionRatio float64
+}
+
+func (x *XzExpander) Expand(dst, src string, dir bool, umask os.FileMode) error {
+	return decompressFile(dst, src, "xz", dir, umask, x.FileSizeLimit, x.MaxCompressionRatio, func(r io.Reader) (io.Reader, error) {
+		return xz.NewReader(r)
+	})
+}
+
+// ZstdExpander decompresses a single zstd compressed file.
+type ZstdExpander struct {
+	FileSizeLimit int64
+	// MaxCompressionRatio aborts the decompression when the decompressed size exceeds
+	// this multiple of the compressed size. Zero disables the check.
+	MaxCompressionRatio float64
+}
+
+func (z *ZstdExpander) Expand(dst, src string, dir bool, umask os.FileMode) error {
+	return decompressFile(dst, src, "zst", dir, umask, z.FileSizeLimit, z.MaxCompressionRatio, func(r io.Reader) (io.Reader, error) {
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	})
+}
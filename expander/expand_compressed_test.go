@@ -0,0 +1,137 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// bzip2HelloWorld is "hello world" compressed with bzip2, since the standard library
+// only provides a bzip2 decompressor.
+var bzip2HelloWorld = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x44, 0xf7, 0x13, 0x78, 0x00, 0x00,
+	0x01, 0x91, 0x80, 0x40, 0x00, 0x06, 0x44, 0x90, 0x80, 0x20, 0x00, 0x22, 0x03, 0x34, 0x84, 0x30,
+	0x21, 0xb6, 0x81, 0x54, 0x27, 0x8b, 0xb9, 0x22, 0x9c, 0x28, 0x48, 0x22, 0x7b, 0x89, 0xbc, 0x00,
+}
+
+// compress compresses data with the writer returned by newWriter.
+func compress(t *testing.T, data []byte, newWriter func(io.Writer) (io.WriteCloser, error)) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := newWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func compressedFixtures(t *testing.T, data []byte) map[string][]byte {
+	return map[string][]byte{
+		"gz": compress(t, data, func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil }),
+		"xz": compress(t, data, func(w io.Writer) (io.WriteCloser, error) { return xz.NewWriter(w) }),
+		"zst": compress(t, data, func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w)
+		}),
+	}
+}
+
+// TestCompressedExpanders_Expand tests decompressing single compressed files
+func TestCompressedExpanders_Expand(t *testing.T) {
+	fixtures := compressedFixtures(t, []byte("hello world"))
+	fixtures["bz2"] = bzip2HelloWorld
+	expanders := BaseExpanders(0, 0)
+
+	for ext, data := range fixtures {
+		t.Run(ext, func(t *testing.T) {
+			tmp := t.TempDir()
+			src := filepath.Join(tmp, "foo.json."+ext)
+			if err := os.WriteFile(src, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			// Expanding into a directory names the file after the source
+			dst := filepath.Join(tmp, "dir")
+			if err := expanders[ext].Expand(dst, src, true, 0644); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			content, err := os.ReadFile(filepath.Join(dst, "foo.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != "hello world" {
+				t.Errorf("unexpected content: got %q, want %q", content, "hello world")
+			}
+
+			// Expanding to a file uses the destination as is
+			dst = filepath.Join(tmp, "file", "bar.json")
+			if err := expanders[ext].Expand(dst, src, false, 0644); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := os.Stat(dst); err != nil {
+				t.Errorf("expected %s to exist: %v", dst, err)
+			}
+		})
+	}
+}
+
+// TestCompressedExpanders_SizeLimit tests that the decompressed size is limited
+func TestCompressedExpanders_SizeLimit(t *testing.T) {
+	fixtures := compressedFixtures(t, bytes.Repeat([]byte("a"), 1024))
+	expanders := BaseExpanders(0, 1023)
+
+	for ext, data := range fixtures {
+		t.Run(ext, func(t *testing.T) {
+			tmp := t.TempDir()
+			src := filepath.Join(tmp, "foo."+ext)
+			if err := os.WriteFile(src, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			err := expanders[ext].Expand(tmp, src, true, 0644)
+			if err == nil || !strings.Contains(err.Error(), "exceeds the 1023 size limit") {
+				t.Errorf("expected size limit error, got: %v", err)
+			}
+		})
+	}
+}
+
+// TestCompressedExpanders_Corrupt tests that corrupt input is reported
+func TestCompressedExpanders_Corrupt(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "foo.gz")
+	if err := os.WriteFile(src, []byte("not gzip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g := &GzipExpander{}
+	if err := g.Expand(tmp, src, true, 0644); err == nil {
+		t.Error("expected an error, but got nil")
+	}
+}
@@ -18,6 +18,8 @@ package expander
 
 import (
 	"archive/tar"
+	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -25,30 +27,50 @@ import (
 	"time"
 )
 
+// tarReadBufferSize is the size of the buffer tarballs are read through, so that the headers and
+// the small members of tarballs do not cost a read each.
+const tarReadBufferSize = 256 << 10
+
 // untar is a helper function that untars a tarball to a destination directory
-func untar(input io.Reader, dst, src string, dir bool, umask os.FileMode, fileSizeLimit int64, filesLimit int) error {
+func untar(input io.Reader, dst, src string, dir bool, umask os.FileMode, fileSizeLimit int64, filesLimit int, opts ExtractOptions) error {
+	filter, err := opts.newMemberFilter()
+	if err != nil {
+		return err
+	}
+
+	var root string
+	if dir {
+		if root, err = resolvedRoot(dst); err != nil {
+			return err
+		}
+	}
+
 	tarReader := tar.NewReader(input)
 	finished := false
+	empty := true
 
-	dirHeaders := []*tar.Header{}
+	// The permissions and times of directories are set once every member was extracted, as
+	// extracting members into them would change their times, or fail if they are read-only. A
+	// directory recorded more than once is only set as last recorded.
+	dirHeaders := map[string]*tar.Header{}
+	dirPaths := []string{}
 	now := time.Now()
 
+	// ready holds the directories that were created and checked to resolve inside the destination
+	// for the members extracted so far, which saves resolving them again for every member. It is
+	// reset whenever a link is extracted, as links can change where the directories resolve to.
+	ready := map[string]bool{}
+
 	var (
 		fileSize   int64
 		filesCount int
 	)
 
 	for {
-		if filesLimit > 0 {
-			filesCount++
-			if filesCount > filesLimit {
-				return fmt.Errorf("tar file contains more files than the %d allowed: %d", filesCount, filesLimit)
-			}
-		}
-
 		header, err := tarReader.Next()
 		if err == io.EOF {
-			if !finished {
+			// An archive whose members were all filtered out is not considered empty.
+			if !finished && (empty || !filter.Active()) {
 				// Empty archive
 				return fmt.Errorf("tar file is empty: %s", src)
 			}
@@ -62,6 +84,18 @@ func untar(input io.Reader, dst, src string, dir bool, umask os.FileMode, fileSi
 		if header.Typeflag == tar.TypeXGlobalHeader || header.Typeflag == tar.TypeXHeader {
 			continue
 		}
+		empty = false
+
+		if filesLimit > 0 {
+			filesCount++
+			if filesCount > filesLimit {
+				return fmt.Errorf("tar file contains more files than the %d allowed: %d", filesLimit, filesCount)
+			}
+		}
+
+		if !filter.Match(header.Name) {
+			continue
+		}
 
 		fPath := dst
 
@@ -73,11 +107,52 @@ func untar(input io.Reader, dst, src string, dir bool, umask os.FileMode, fileSi
 			fPath = filepath.Join(dst, header.Name) // nolint:gosec
 		}
 
+		isLink := header.Typeflag == tar.TypeSymlink || header.Typeflag == tar.TypeLink
+		if isLink && opts.Links == SkipLinks {
+			continue
+		}
+
+		if err := opts.visit(Entry{Name: header.Name, Size: header.Size, Mode: header.FileInfo().Mode(), Linkname: header.Linkname}); err != nil {
+			return err
+		}
+
+		if isLink {
+			clear(ready)
+			if opts.Links == RejectLinks {
+				return fmt.Errorf("tar file contains a link (%s), which is not allowed", header.Name)
+			}
+
+			if !dir {
+				return fmt.Errorf("expected a file (%s), got a link: %s", src, header.Name)
+			}
+
+			if err := checkWithin(root, filepath.Dir(fPath)); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(fPath), umask); err != nil {
+				return fmt.Errorf("failed to create directory (%s): %s", filepath.Dir(fPath), err)
+			}
+
+			if header.Typeflag == tar.TypeSymlink {
+				if err := createSymlink(root, fPath, header.Linkname); err != nil {
+					return err
+				}
+				// Hard links share the owner of their target, so only symlinks need changing.
+				if err := opts.chown(fPath, header.Uid, header.Gid); err != nil {
+					return err
+				}
+			} else if err := createHardlink(dst, root, fPath, header.Linkname); err != nil {
+				return err
+			}
+
+			continue
+		}
+
 		fileInfo := header.FileInfo()
 		fileSize += fileInfo.Size()
 
 		if fileSizeLimit > 0 && fileSize > fileSizeLimit {
-			return fmt.Errorf("tar file size exceeds the %d limit: %d", fileSizeLimit, fileSize)
+			return sizeLimitError(fileSizeLimit, "tar file size exceeds the %d limit: %d", fileSizeLimit, fileSize)
 		}
 
 		if fileInfo.IsDir() {
@@ -85,21 +160,44 @@ func untar(input io.Reader, dst, src string, dir bool, umask os.FileMode, fileSi
 				return fmt.Errorf("expected a file (%s), got a directory: %s", src, fPath)
 			}
 
+			if err := checkWithin(root, fPath); err != nil {
+				return err
+			}
+
 			if err := os.MkdirAll(fPath, umask); err != nil {
 				return fmt.Errorf("failed to create directory (%s): %s", fPath, err)
 			}
 
-			dirHeaders = append(dirHeaders, header)
+			if err := opts.chown(fPath, header.Uid, header.Gid); err != nil {
+				return err
+			}
+
+			ready[fPath] = true
+			if _, ok := dirHeaders[fPath]; !ok {
+				dirPaths = append(dirPaths, fPath)
+			}
+			dirHeaders[fPath] = header
 
 			continue
-		} else {
-			destPath := filepath.Dir(fPath)
+		} else if destPath := filepath.Dir(fPath); !ready[destPath] {
+			if dir {
+				if err := checkWithin(root, destPath); err != nil {
+					return err
+				}
+			}
 
 			if _, err := os.Stat(destPath); os.IsNotExist(err) {
 				if err := os.MkdirAll(destPath, umask); err != nil {
 					return fmt.Errorf("failed to create directory (%s): %s", destPath, err)
 				}
 			}
+			ready[destPath] = true
+		}
+
+		if dir {
+			if err := removeNonDirectory(fPath); err != nil {
+				return err
+			}
 		}
 
 		if !dir && finished {
@@ -108,64 +206,64 @@ func untar(input io.Reader, dst, src string, dir bool, umask os.FileMode, fileSi
 
 		finished = true
 
-		err = copyReader(tarReader, fPath, umask, fileSizeLimit)
+		err = copyReader(tarReader, fPath, umask, header.Size, fileSizeLimit, opts.Digests)
 		if err != nil {
 			return err
 		}
 
-		aTime, mTime := now, now
-
-		if header.AccessTime.Unix() > 0 {
-			aTime = header.AccessTime
-		}
-
-		if header.ModTime.Unix() > 0 {
-			mTime = header.ModTime
+		if err := opts.chown(fPath, header.Uid, header.Gid); err != nil {
+			return err
 		}
 
-		if err := os.Chtimes(fPath, aTime, mTime); err != nil {
+		if err := setTimes(fPath, header, now, opts); err != nil {
 			return fmt.Errorf("failed to change file times (%s): %s", fPath, err)
 		}
 	}
 
-	for _, dirHeader := range dirHeaders {
-		if containsDotDot(dirHeader.Name) {
-			return fmt.Errorf("tar file (%s) would escape destination directory", dirHeader.Name)
-		}
-		path := filepath.Join(dst, dirHeader.Name) // nolint:gosec
+	for _, path := range dirPaths {
+		dirHeader := dirHeaders[path]
 		// Chmod the directory
 		if err := os.Chmod(path, dirHeader.FileInfo().Mode()); err != nil {
 			return fmt.Errorf("failed to change directory permissions (%s): %s", path, err)
 		}
 
-		// Set the access and modification times
-		aTime, mTime := now, now
-
-		if dirHeader.AccessTime.Unix() > 0 {
-			aTime = dirHeader.AccessTime
-		}
-		if dirHeader.ModTime.Unix() > 0 {
-			mTime = dirHeader.ModTime
-		}
-		if err := os.Chtimes(path, aTime, mTime); err != nil {
+		if err := setTimes(path, dirHeader, now, opts); err != nil {
 			return fmt.Errorf("failed to change directory times (%s): %s", path, err)
 		}
 	}
 	return nil
 }
 
-type TarExpander struct {
-	FileSizeLimit int64
-	FilesLimit    int
-}
+// setTimes sets the access and modification times of the member extracted to path to those
+// recorded in its header, or to now where none is recorded. Members are left as they are if the
+// header records no times, as they were written at about now, or if IgnoreTimes is set.
+func setTimes(path string, header *tar.Header, now time.Time, opts ExtractOptions) error {
+	aTime, mTime := now, now
+	recorded := false
 
-func (t *TarExpander) Expand(dst, src string, dir bool, umask os.FileMode) error {
-	if !dir {
-		err := os.MkdirAll(dst, umask)
-		return err
+	if header.AccessTime.Unix() > 0 {
+		aTime, recorded = header.AccessTime, true
 	}
 
-	if err := os.MkdirAll(dst, 0755); err != nil {
+	if header.ModTime.Unix() > 0 {
+		mTime, recorded = header.ModTime, true
+	}
+
+	if !recorded || opts.IgnoreTimes {
+		return nil
+	}
+	return os.Chtimes(path, aTime, mTime)
+}
+
+// expandTar opens the tarball src, wraps it with the reader returned by newReader, if any,
+// and untars it to dst. newReader allows compressed tarballs to be streamed through a
+// decompressor without writing the decompressed tarball to disk.
+func expandTar(dst, src string, dir bool, umask os.FileMode, fileSizeLimit int64, filesLimit int, opts ExtractOptions, newReader func(io.Reader) (io.Reader, error)) error {
+	if dir {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+	} else if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
 		return err
 	}
 
@@ -174,5 +272,55 @@ func (t *TarExpander) Expand(dst, src string, dir bool, umask os.FileMode) error
 		return err
 	}
 	defer f.Close()
-	return untar(f, dst, src, dir, umask, t.FileSizeLimit, t.FilesLimit)
+
+	if !dir {
+		return untarReader(f, dst, src, dir, umask, fileSizeLimit, filesLimit, opts, newReader)
+	}
+	return opts.expandDir(dst, func(dst string) error {
+		return untarReader(f, dst, src, dir, umask, fileSizeLimit, filesLimit, opts, newReader)
+	})
+}
+
+// expandTarStream untars the tarball read from r into the directory dst, wrapping r with the
+// reader returned by newReader, if any. Reading stops with the context error once ctx is done.
+func expandTarStream(ctx context.Context, r io.Reader, dst string, umask os.FileMode, fileSizeLimit int64, filesLimit int, opts ExtractOptions, newReader func(io.Reader) (io.Reader, error)) error {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	return opts.expandDir(dst, func(dst string) error {
+		return untarReader(&contextReader{ctx: ctx, r: r}, dst, streamName, true, umask, fileSizeLimit, filesLimit, opts, newReader)
+	})
+}
+
+// untarReader wraps input with the reader returned by newReader, if any, and untars it to dst.
+func untarReader(input io.Reader, dst, src string, dir bool, umask os.FileMode, fileSizeLimit int64, filesLimit int, opts ExtractOptions, newReader func(io.Reader) (io.Reader, error)) error {
+	input = bufio.NewReaderSize(input, tarReadBufferSize)
+	r := input
+	if newReader != nil {
+		var err error
+		if r, err = newReader(input); err != nil {
+			return fmt.Errorf("failed to read compressed tar file (%s): %w", src, err)
+		}
+		if c, ok := r.(io.Closer); ok {
+			defer c.Close()
+		}
+	}
+
+	return untar(r, dst, src, dir, umask, fileSizeLimit, filesLimit, opts)
+}
+
+type TarExpander struct {
+	FileSizeLimit int64
+	FilesLimit    int
+	Options       ExtractOptions
+}
+
+func (t *TarExpander) Expand(dst, src string, dir bool, umask os.FileMode) error {
+	return expandTar(dst, src, dir, umask, t.FileSizeLimit, t.FilesLimit, t.Options, nil)
+}
+
+// ExpandStream expands the tarball read from r into the directory dst.
+func (t *TarExpander) ExpandStream(ctx context.Context, r io.Reader, dst string, umask os.FileMode) error {
+	return expandTarStream(ctx, r, dst, umask, t.FileSizeLimit, t.FilesLimit, t.Options, nil)
 }
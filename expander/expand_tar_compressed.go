@@ -0,0 +1,136 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// TarGzipExpander expands gzip compressed tarballs (.tar.gz, .tgz). The tarball is
+// decompressed while it is being untarred.
+type TarGzipExpander struct {
+	FileSizeLimit int64
+	FilesLimit    int
+	Options       ExtractOptions
+	// MaxCompressionRatio aborts the expansion when the decompressed size exceeds this
+	// multiple of the compressed size. Zero disables the check.
+	MaxCompressionRatio float64
+}
+
+func (t *TarGzipExpander) Expand(dst, src string, dir bool, umask os.FileMode) error {
+	return expandTar(dst, src, dir, umask, t.FileSizeLimit, t.FilesLimit, t.Options, t.newReader())
+}
+
+// ExpandStream expands the compressed tarball read from r into the directory dst.
+func (t *TarGzipExpander) ExpandStream(ctx context.Context, r io.Reader, dst string, umask os.FileMode) error {
+	return expandTarStream(ctx, r, dst, umask, t.FileSizeLimit, t.FilesLimit, t.Options, t.newReader())
+}
+
+func (t *TarGzipExpander) newReader() func(io.Reader) (io.Reader, error) {
+	return limitRatio(func(r io.Reader) (io.Reader, error) {
+		return gzip.NewReader(r)
+	}, t.MaxCompressionRatio)
+}
+
+// TarBzip2Expander expands bzip2 compressed tarballs (.tar.bz2, .tbz2). The tarball is
+// decompressed while it is being untarred.
+type TarBzip2Expander struct {
+	FileSizeLimit int64
+	FilesLimit    int
+	Options       ExtractOptions
+	// MaxCompressionRatio aborts the expansion when the decompressed size exceeds this
+	// multiple of the compressed size. Zero disables the check.
+	MaxCompressionRatio float64
+}
+
+func (t *TarBzip2Expander) Expand(dst, src string, dir bool, umask os.FileMode) error {
+	return expandTar(dst, src, dir, umask, t.FileSizeLimit, t.FilesLimit, t.Options, t.newReader())
+}
+
+// ExpandStream expands the compressed tarball read from r into the directory dst.
+func (t *TarBzip2Expander) ExpandStream(ctx context.Context, r io.Reader, dst string, umask os.FileMode) error {
+	return expandTarStream(ctx, r, dst, umask, t.FileSizeLimit, t.FilesLimit, t.Options, t.newReader())
+}
+
+func (t *TarBzip2Expander) newReader() func(io.Reader) (io.Reader, error) {
+	return limitRatio(func(r io.Reader) (io.Reader, error) {
+		return bzip2.NewReader(r), nil
+	}, t.MaxCompressionRatio)
+}
+
+// TarXzExpander expands xz compressed tarballs (.tar.xz, .txz). The tarball is
+// decompressed while it is being untarred.
+type TarXzExpander struct {
+	FileSizeLimit int64
+	FilesLimit    int
+	Options       ExtractOptions
+	// MaxCompressionRatio aborts the expansion when the decompressed size exceeds this
+	// multiple of the compressed size. Zero disables the check.
+	MaxCompressionRatio float64
+}
+
+func (t *TarXzExpander) Expand(dst, src string, dir bool, umask os.FileMode) error {
+	return expandTar(dst, src, dir, umask, t.FileSizeLimit, t.FilesLimit, t.Options, t.newReader())
+}
+
+// ExpandStream expands the compressed tarball read from r into the directory dst.
+func (t *TarXzExpander) ExpandStream(ctx context.Context, r io.Reader, dst string, umask os.FileMode) error {
+	return expandTarStream(ctx, r, dst, umask, t.FileSizeLimit, t.FilesLimit, t.Options, t.newReader())
+}
+
+func (t *TarXzExpander) newReader() func(io.Reader) (io.Reader, error) {
+	return limitRatio(func(r io.Reader) (io.Reader, error) {
+		return xz.NewReader(r)
+	}, t.MaxCompressionRatio)
+}
+
+// TarZstdExpander expands zstd compressed tarballs (.tar.zst, .tzst). The tarball is
+// decompressed while it is being untarred.
+type TarZstdExpander struct {
+	FileSizeLimit int64
+	FilesLimit    int
+	Options       ExtractOptions
+	// MaxCompressionRatio aborts the expansion when the decompressed size exceeds this
+	// multiple of the compressed size. Zero disables the check.
+	MaxCompressionRatio float64
+}
+
+func (t *TarZstdExpander) Expand(dst, src string, dir bool, umask os.FileMode) error {
+	return expandTar(dst, src, dir, umask, t.FileSizeLimit, t.FilesLimit, t.Options, t.newReader())
+}
+
+// ExpandStream expands the compressed tarball read from r into the directory dst.
+func (t *TarZstdExpander) ExpandStream(ctx context.Context, r io.Reader, dst string, umask os.FileMode) error {
+	return expandTarStream(ctx, r, dst, umask, t.FileSizeLimit, t.FilesLimit, t.Options, t.newReader())
+}
+
+func (t *TarZstdExpander) newReader() func(io.Reader) (io.Reader, error) {
+	return limitRatio(func(r io.Reader) (io.Reader, error) {
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}, t.MaxCompressionRatio)
+}
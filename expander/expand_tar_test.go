@@ -0,0 +1,450 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// tarEntry describes a member of a tarball created by makeTar.
+type tarEntry struct {
+	name     string
+	content  string
+	typeflag byte
+	linkname string
+	mode     int64
+}
+
+// makeTar returns a tarball containing the given entries, in order.
+func makeTar(t *testing.T, entries ...tarEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, e := range entries {
+		typeflag := e.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+			if strings.HasSuffix(e.name, "/") {
+				typeflag = tar.TypeDir
+			}
+		}
+		mode := e.mode
+		if mode == 0 {
+			mode = 0644
+			if typeflag == tar.TypeDir {
+				mode = 0755
+			}
+		}
+		hdr := &tar.Header{
+			Name:     e.name,
+			Typeflag: typeflag,
+			Linkname: e.linkname,
+			Mode:     mode,
+			Size:     int64(len(e.content)),
+		}
+		if typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			if _, err := w.Write([]byte(e.content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// sampleTarBzip2 is a bzip2 compressed tarball containing policy/main.rego and
+// policy/lib/helper.rego, since the standard library only provides a bzip2 decompressor.
+var sampleTarBzip2 = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xcc, 0xff, 0xbe, 0xb4, 0x00, 0x00,
+	0xa4, 0x7f, 0x80, 0xca, 0x80, 0x08, 0x00, 0x40, 0x01, 0xfd, 0x00, 0x00, 0x10, 0x40, 0x00, 0x7a,
+	0xef, 0xde, 0x20, 0x28, 0x08, 0x20, 0x00, 0x94, 0x09, 0x49, 0x4f, 0xd2, 0x35, 0x1e, 0x50, 0x68,
+	0x37, 0xaa, 0x01, 0xea, 0x79, 0x23, 0xf5, 0x41, 0x28, 0xa0, 0x0d, 0x01, 0xa0, 0x01, 0xa0, 0x07,
+	0xce, 0x18, 0x3c, 0xa2, 0xb9, 0x40, 0x0b, 0xe1, 0x44, 0x41, 0x0a, 0x0e, 0x06, 0x92, 0x82, 0xb6,
+	0x71, 0x84, 0x62, 0x08, 0x28, 0x0a, 0x9d, 0x89, 0x52, 0x7f, 0x4c, 0x89, 0x02, 0x48, 0x80, 0x21,
+	0x9b, 0x28, 0x86, 0xfd, 0xc4, 0x30, 0x38, 0x99, 0xa9, 0x12, 0x04, 0x58, 0xc4, 0x4b, 0x15, 0x2c,
+	0x68, 0x58, 0xf6, 0x40, 0xf8, 0x74, 0x2d, 0xaf, 0x01, 0x8a, 0xdc, 0x14, 0xb8, 0xd1, 0xa9, 0x8a,
+	0xad, 0x0a, 0x1b, 0x07, 0x26, 0xf7, 0xda, 0x4f, 0x41, 0xc7, 0x1c, 0xc8, 0x61, 0x10, 0x3f, 0x8b,
+	0xb9, 0x22, 0x9c, 0x28, 0x48, 0x66, 0x7f, 0xdf, 0x5a, 0x00,
+}
+
+// sampleTar returns a small tarball with a nested directory structure.
+func sampleTar(t *testing.T) []byte {
+	return makeTar(t,
+		tarEntry{name: "policy/"},
+		tarEntry{name: "policy/main.rego", content: "package main"},
+		tarEntry{name: "policy/lib/helper.rego", content: "package lib"},
+	)
+}
+
+// listFiles returns the regular files below root, relative to it and sorted.
+func listFiles(t *testing.T, root string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// expandFixture writes data to a file named name in a temporary directory, expands it
+// with e and returns the destination directory.
+func expandFixture(t *testing.T, e Expander, name string, data []byte) (string, error) {
+	t.Helper()
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, name)
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(tmp, "out")
+	return dst, e.Expand(dst, src, true, 0755)
+}
+
+// TestTarExpanders_Expand tests expanding plain and compressed tarballs
+func TestTarExpanders_Expand(t *testing.T) {
+	tarball := sampleTar(t)
+	fixtures := map[string][]byte{
+		"tar":     tarball,
+		"tar.gz":  compressedFixtures(t, tarball)["gz"],
+		"tar.bz2": sampleTarBzip2,
+		"tbz2":    sampleTarBzip2,
+		"tar.xz":  compressedFixtures(t, tarball)["xz"],
+		"txz":     compressedFixtures(t, tarball)["xz"],
+		"tar.zst": compressedFixtures(t, tarball)["zst"],
+		"tzst":    compressedFixtures(t, tarball)["zst"],
+	}
+	expanders := BaseExpanders(0, 0)
+
+	for ext, data := range fixtures {
+		t.Run(ext, func(t *testing.T) {
+			e, ok := FindExpander("bundle."+ext, expanders)
+			if !ok {
+				t.Fatalf("no expander found for %s", ext)
+			}
+			dst, err := expandFixture(t, e, "bundle."+ext, data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			files := listFiles(t, dst)
+			expected := []string{"policy/lib/helper.rego", "policy/main.rego"}
+			if strings.Join(files, ",") != strings.Join(expected, ",") {
+				t.Errorf("unexpected files: got %v, want %v", files, expected)
+			}
+		})
+	}
+}
+
+// TestTarXzExpander_Expand_Corrupt tests that a tarball that is not xz compressed is rejected
+func TestTarXzExpander_Expand_Corrupt(t *testing.T) {
+	_, err := expandFixture(t, &TarXzExpander{}, "bundle.tar.xz", sampleTar(t))
+	if err == nil || !strings.Contains(err.Error(), "failed to read compressed tar file") {
+		t.Errorf("expected decompression error, got: %v", err)
+	}
+}
+
+// TestTarExpander_Expand_Escape tests that members escaping the destination are rejected
+func TestTarExpander_Expand_Escape(t *testing.T) {
+	data := makeTar(t, tarEntry{name: "../evil.txt", content: "evil"})
+	_, err := expandFixture(t, &TarExpander{}, "bundle.tar", data)
+	if err == nil || !strings.Contains(err.Error(), "would escape destination directory") {
+		t.Errorf("expected escape error, got: %v", err)
+	}
+}
+
+// TestTarExpander_Expand_SizeLimit tests that the total size of the tarball is limited
+func TestTarExpander_Expand_SizeLimit(t *testing.T) {
+	_, err := expandFixture(t, &TarGzipExpander{FileSizeLimit: 10}, "bundle.tgz", compressedFixtures(t, sampleTar(t))["gz"])
+	if err == nil || !strings.Contains(err.Error(), "size exceeds the 10 limit") {
+		t.Errorf("expected size limit error, got: %v", err)
+	}
+	if !errors.Is(err, gogather.ErrTooLarge) {
+		t.Errorf("expected the error to match ErrTooLarge, got: %v", err)
+	}
+}
+
+// TestTarExpander_Expand_FilesLimit tests that the number of members of the tarball is limited
+func TestTarExpander_Expand_FilesLimit(t *testing.T) {
+	// sampleTar contains three members
+	if _, err := expandFixture(t, &TarExpander{FilesLimit: 3}, "bundle.tar", sampleTar(t)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	_, err := expandFixture(t, &TarExpander{FilesLimit: 2}, "bundle.tar", sampleTar(t))
+	if err == nil || !strings.Contains(err.Error(), "more files than the 2 allowed: 3") {
+		t.Errorf("expected files limit error, got: %v", err)
+	}
+}
+
+// TestTarExpander_Expand_Links tests that links inside the destination are recreated
+func TestTarExpander_Expand_Links(t *testing.T) {
+	dst, err := expandFixture(t, &TarExpander{}, "bundle.tar", makeTar(t,
+		tarEntry{name: "policy/main.rego", content: "package main"},
+		tarEntry{name: "policy/alias.rego", typeflag: tar.TypeSymlink, linkname: "main.rego"},
+		tarEntry{name: "lib/up.rego", typeflag: tar.TypeSymlink, linkname: "../policy/main.rego"},
+		tarEntry{name: "copy.rego", typeflag: tar.TypeLink, linkname: "policy/main.rego"},
+		// A regular file replacing a symlink must not be written through the link
+		tarEntry{name: "replaced.rego", typeflag: tar.TypeSymlink, linkname: "policy/main.rego"},
+		tarEntry{name: "replaced.rego", content: "package replaced"},
+	))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, expected := range map[string]string{
+		"policy/alias.rego": "package main",
+		"lib/up.rego":       "package main",
+		"copy.rego":         "package main",
+		"policy/main.rego":  "package main",
+		"replaced.rego":     "package replaced",
+	} {
+		content, err := os.ReadFile(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		if string(content) != expected {
+			t.Errorf("unexpected content for %s: got %q, want %q", name, content, expected)
+		}
+	}
+	if target, err := os.Readlink(filepath.Join(dst, "policy", "alias.rego")); err != nil || target != "main.rego" {
+		t.Errorf("expected policy/alias.rego to be a symlink to main.rego, got %q: %v", target, err)
+	}
+}
+
+// TestTarExpander_Expand_LinkEscape tests that links pointing outside of the destination are rejected
+func TestTarExpander_Expand_LinkEscape(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry tarEntry
+	}{
+		{"relative symlink", tarEntry{name: "evil", typeflag: tar.TypeSymlink, linkname: "../outside"}},
+		{"nested relative symlink", tarEntry{name: "a/evil", typeflag: tar.TypeSymlink, linkname: "../../outside"}},
+		{"absolute symlink", tarEntry{name: "evil", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"}},
+		{"dot dot after a name", tarEntry{name: "evil", typeflag: tar.TypeSymlink, linkname: "a/../../outside"}},
+		{"hard link", tarEntry{name: "evil", typeflag: tar.TypeLink, linkname: "../outside"}},
+		{"absolute hard link", tarEntry{name: "evil", typeflag: tar.TypeLink, linkname: "/etc/passwd"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst, err := expandFixture(t, &TarExpander{}, "bundle.tar", makeTar(t, tt.entry))
+			if err == nil || !strings.Contains(err.Error(), "outside of the destination") && !strings.Contains(err.Error(), "unsupported target") {
+				t.Errorf("expected link escape error, got: %v", err)
+			}
+			if _, err := os.Lstat(filepath.Join(dst, tt.entry.name)); err == nil {
+				t.Error("expected the link not to be created")
+			}
+		})
+	}
+}
+
+// TestTarExpander_Expand_WriteThroughSymlink tests that members are not written through existing symlinks
+func TestTarExpander_Expand_WriteThroughSymlink(t *testing.T) {
+	tmp := t.TempDir()
+	outside := filepath.Join(tmp, "outside")
+	dst := filepath.Join(tmp, "out")
+	if err := os.MkdirAll(outside, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dst, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	src := filepath.Join(tmp, "bundle.tar")
+	if err := os.WriteFile(src, makeTar(t, tarEntry{name: "link/evil.txt", content: "evil"}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := (&TarExpander{}).Expand(dst, src, true, 0755)
+	if err == nil || !strings.Contains(err.Error(), "through a symlink") {
+		t.Errorf("expected symlink escape error, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "evil.txt")); err == nil {
+		t.Error("expected nothing to be written outside of the destination")
+	}
+}
+
+// TestTarExpander_Expand_LinkPolicy tests skipping and rejecting links
+func TestTarExpander_Expand_LinkPolicy(t *testing.T) {
+	data := makeTar(t,
+		tarEntry{name: "main.rego", content: "package main"},
+		tarEntry{name: "alias.rego", typeflag: tar.TypeSymlink, linkname: "main.rego"},
+	)
+
+	dst, err := expandFixture(t, &TarExpander{Options: ExtractOptions{Links: SkipLinks}}, "bundle.tar", data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dst, "alias.rego")); err == nil {
+		t.Error("expected the link to be skipped")
+	}
+
+	_, err = expandFixture(t, &TarExpander{Options: ExtractOptions{Links: RejectLinks}}, "bundle.tar", data)
+	if err == nil || !strings.Contains(err.Error(), "which is not allowed") {
+		t.Errorf("expected link rejection error, got: %v", err)
+	}
+}
+
+// TestTarExpander_Times tests restoring the times recorded in tarballs, unless IgnoreTimes is set
+func TestTarExpander_Times(t *testing.T) {
+	first := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: first},
+		{Name: "dir/a.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 3, ModTime: first},
+		{Name: "dir/b.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 3},
+		{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: last},
+	} {
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			if _, err := w.Write([]byte("foo")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := func(t *testing.T, path string) time.Time {
+		t.Helper()
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.ModTime()
+	}
+
+	t.Run("restored", func(t *testing.T) {
+		start := time.Now().Add(-time.Minute)
+		dst, err := expandFixture(t, &TarExpander{}, "bundle.tar", buf.Bytes())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := modTime(t, filepath.Join(dst, "dir", "a.txt")); !got.Equal(first) {
+			t.Errorf("unexpected time of the file: %s", got)
+		}
+		if got := modTime(t, filepath.Join(dst, "dir", "b.txt")); got.Before(start) {
+			t.Errorf("expected the file without a time to keep the time it was extracted at: %s", got)
+		}
+		if got := modTime(t, filepath.Join(dst, "dir")); !got.Equal(last) {
+			t.Errorf("expected the time the directory was last recorded with: %s", got)
+		}
+	})
+
+	t.Run("ignored", func(t *testing.T) {
+		start := time.Now().Add(-time.Minute)
+		dst, err := expandFixture(t, &TarExpander{Options: ExtractOptions{IgnoreTimes: true}}, "bundle.tar", buf.Bytes())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, name := range []string{"dir", filepath.Join("dir", "a.txt")} {
+			if got := modTime(t, filepath.Join(dst, name)); got.Before(start) {
+				t.Errorf("expected %s to keep the time it was extracted at: %s", name, got)
+			}
+		}
+	})
+}
+
+// benchmarkTar returns a tarball of files members in 100 directories, with their times set, like
+// the tarballs of source repositories.
+func benchmarkTar(b *testing.B, files int) []byte {
+	b.Helper()
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for d := 0; d < 100; d++ {
+		if err := w.WriteHeader(&tar.Header{Name: fmt.Sprintf("dir%d/", d), Typeflag: tar.TypeDir, Mode: 0755, ModTime: modTime}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	content := []byte(strings.Repeat("x", 1000))
+	for i := 0; i < files; i++ {
+		hdr := &tar.Header{Name: fmt.Sprintf("dir%d/file%d.txt", i%100, i), Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content)), ModTime: modTime}
+		if err := w.WriteHeader(hdr); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := w.Write(content); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// BenchmarkTarExpander_Expand measures expanding tarballs of many small files, with and without
+// restoring their times
+func BenchmarkTarExpander_Expand(b *testing.B) {
+	for _, files := range []int{1000, 10000} {
+		b.Run(fmt.Sprintf("files=%d", files), func(b *testing.B) {
+			src := filepath.Join(b.TempDir(), "bundle.tar")
+			if err := os.WriteFile(src, benchmarkTar(b, files), 0644); err != nil {
+				b.Fatal(err)
+			}
+			for _, ignoreTimes := range []bool{false, true} {
+				e := &TarExpander{Options: ExtractOptions{IgnoreTimes: ignoreTimes}}
+				b.Run(fmt.Sprintf("ignoreTimes=%v", ignoreTimes), func(b *testing.B) {
+					for i := 0; i < b.N; i++ {
+						b.StopTimer()
+						dst := filepath.Join(b.TempDir(), "out")
+						b.StartTimer()
+						if err := e.Expand(dst, src, true, 0755); err != nil {
+							b.Fatal(err)
+						}
+					}
+				})
+			}
+		})
+	}
+}
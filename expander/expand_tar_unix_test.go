@@ -0,0 +1,102 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build unix
+
+package expander
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// ownedTar returns a tarball with a directory, file and symlink owned by uid and gid.
+func ownedTar(t *testing.T, uid, gid int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755, Uid: uid, Gid: gid},
+		{Name: "dir/file.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 5, Uid: uid, Gid: gid},
+		{Name: "dir/link", Typeflag: tar.TypeSymlink, Linkname: "file.txt", Uid: uid, Gid: gid},
+	} {
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			if _, err := w.Write([]byte("hello")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func owner(t *testing.T, path string) (int, int) {
+	t.Helper()
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := info.Sys().(*syscall.Stat_t)
+	return int(st.Uid), int(st.Gid)
+}
+
+// TestTarExpander_Expand_PreserveOwner tests changing the owner of extracted members
+func TestTarExpander_Expand_PreserveOwner(t *testing.T) {
+	opts := ExtractOptions{
+		PreserveOwner: true,
+		OwnerMapper: func(uid, gid int) (int, int) {
+			return uid + 100000, gid + 100000
+		},
+	}
+	dst, err := expandFixture(t, &TarExpander{Options: opts}, "bundle.tar", ownedTar(t, 1234, 5678))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if os.Geteuid() != 0 {
+		// Without the privilege to change ownership the members are still extracted.
+		if _, err := os.Stat(filepath.Join(dst, "dir", "file.txt")); err != nil {
+			t.Errorf("expected file to be extracted: %v", err)
+		}
+		return
+	}
+
+	for _, name := range []string{"dir", "dir/file.txt", "dir/link"} {
+		if uid, gid := owner(t, filepath.Join(dst, name)); uid != 101234 || gid != 105678 {
+			t.Errorf("unexpected owner for %s: got %d:%d, want 101234:105678", name, uid, gid)
+		}
+	}
+}
+
+// TestTarExpander_Expand_DropOwner tests that ownership is not preserved by default
+func TestTarExpander_Expand_DropOwner(t *testing.T) {
+	dst, err := expandFixture(t, &TarExpander{}, "bundle.tar", ownedTar(t, 1234, 5678))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if uid, _ := owner(t, filepath.Join(dst, "dir", "file.txt")); uid != os.Geteuid() {
+		t.Errorf("unexpected owner: got %d, want %d", uid, os.Geteuid())
+	}
+}
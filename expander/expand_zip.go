@@ -0,0 +1,163 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// unzip is a helper function that unzips a zip archive to a destination directory
+func unzip(zipReader *zip.Reader, dst, src string, dir bool, umask os.FileMode, fileSizeLimit int64, filesLimit int, maxRatio float64, password string, opts ExtractOptions) error {
+	if len(zipReader.File) == 0 {
+		return fmt.Errorf("zip file is empty: %s", src)
+	}
+
+	if filesLimit > 0 && len(zipReader.File) > filesLimit {
+		return fmt.Errorf("zip file contains more files than the %d allowed: %d", filesLimit, len(zipReader.File))
+	}
+
+	filter, err := opts.newMemberFilter()
+	if err != nil {
+		return err
+	}
+
+	members := make([]*zip.File, 0, len(zipReader.File))
+	for _, f := range zipReader.File {
+		if filter.Match(f.Name) {
+			members = append(members, f)
+		}
+	}
+
+	if !dir && len(members) > 1 {
+		return fmt.Errorf("zip file contains more than one file: %s", src)
+	}
+
+	var fileSize int64
+
+	for _, f := range members {
+		fPath := dst
+
+		if dir {
+			if containsDotDot(f.Name) {
+				return fmt.Errorf("zip file (%s) would escape destination directory", f.Name)
+			}
+
+			fPath = filepath.Join(dst, f.Name) // nolint:gosec
+		}
+
+		fileInfo := f.FileInfo()
+		if err := opts.visit(Entry{Name: f.Name, Size: fileInfo.Size(), Mode: fileInfo.Mode()}); err != nil {
+			return err
+		}
+
+		fileSize += fileInfo.Size()
+
+		if fileSizeLimit > 0 && fileSize > fileSizeLimit {
+			return sizeLimitError(fileSizeLimit, "zip file size exceeds the %d limit: %d", fileSizeLimit, fileSize)
+		}
+
+		if fileInfo.IsDir() {
+			if !dir {
+				return fmt.Errorf("expected a file (%s), got a directory: %s", src, fPath)
+			}
+
+			if err := os.MkdirAll(fPath, umask); err != nil {
+				return fmt.Errorf("failed to create directory (%s): %s", fPath, err)
+			}
+
+			continue
+		}
+
+		destPath := filepath.Dir(fPath)
+		if _, err := os.Stat(destPath); os.IsNotExist(err) {
+			if err := os.MkdirAll(destPath, umask); err != nil {
+				return fmt.Errorf("failed to create directory (%s): %s", destPath, err)
+			}
+		}
+
+		if err := unzipFile(f, fPath, umask, fileSizeLimit, maxRatio, password, opts.Digests); err != nil {
+			return err
+		}
+
+		if mTime := f.Modified; mTime.Unix() > 0 && !opts.IgnoreTimes {
+			if err := os.Chtimes(fPath, mTime, mTime); err != nil {
+				return fmt.Errorf("failed to change file times (%s): %s", fPath, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// unzipFile copies a single zip member to fPath, decrypting it with password if it is encrypted. If
+// maxRatio is greater than 0, copying fails once the member decompresses beyond maxRatio times its
+// compressed size.
+func unzipFile(f *zip.File, fPath string, umask os.FileMode, fileSizeLimit int64, maxRatio float64, password string, digests *gogather.Digests) error {
+	srcF, err := openZipMember(f, password)
+	if err != nil {
+		return fmt.Errorf("failed to open zip member (%s): %w", f.Name, err)
+	}
+	defer srcF.Close()
+
+	var r io.Reader = srcF
+	if maxRatio > 0 {
+		r = &ratioReader{r: srcF, compressed: func() int64 { return int64(f.CompressedSize64) }, limit: maxRatio}
+	}
+
+	return copyReader(r, fPath, umask, int64(f.UncompressedSize64), fileSizeLimit, digests)
+}
+
+// ZipExpander expands zip archives.
+type ZipExpander struct {
+	FileSizeLimit int64
+	FilesLimit    int
+	Options       ExtractOptions
+	// MaxCompressionRatio aborts the expansion when a member decompresses beyond this
+	// multiple of its compressed size. Zero disables the check.
+	MaxCompressionRatio float64
+	// Password decrypts members encrypted with ZipCrypto or WinZip AES encryption.
+	Password string
+}
+
+func (z *ZipExpander) Expand(dst, src string, dir bool, umask os.FileMode) error {
+	if dir {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+	} else if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	zipReader, err := zip.OpenReader(src)
+	if err != nil {
+		return fmt.Errorf("failed to open zip file (%s): %w", src, err)
+	}
+	defer zipReader.Close()
+
+	if !dir {
+		return unzip(&zipReader.Reader, dst, src, dir, umask, z.FileSizeLimit, z.FilesLimit, z.MaxCompressionRatio, z.Password, z.Options)
+	}
+	return z.Options.expandDir(dst, func(dst string) error {
+		return unzip(&zipReader.Reader, dst, src, dir, umask, z.FileSizeLimit, z.FilesLimit, z.MaxCompressionRatio, z.Password, z.Options)
+	})
+}
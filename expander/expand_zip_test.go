@@ -0,0 +1,136 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeZip creates a zip archive at path containing the given members. Members with a
+// trailing slash are created as directories.
+func writeZip(t *testing.T, path string, members map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for name, content := range members {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasSuffix(name, "/") {
+			continue
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestZipExpander_Expand tests expanding a zip archive into a directory
+func TestZipExpander_Expand(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "archive.zip")
+	writeZip(t, src, map[string]string{
+		"dir/":           "",
+		"dir/file.txt":   "hello",
+		"other/file.txt": "world",
+	})
+
+	dst := filepath.Join(tmp, "out")
+	z := &ZipExpander{}
+	if err := z.Expand(dst, src, true, 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, expected := range map[string]string{"dir/file.txt": "hello", "other/file.txt": "world"} {
+		content, err := os.ReadFile(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatalf("expected %s to exist: %v", name, err)
+		}
+		if string(content) != expected {
+			t.Errorf("unexpected content for %s: got %q, want %q", name, content, expected)
+		}
+	}
+}
+
+// TestZipExpander_Expand_SingleFile tests expanding a zip archive with a single member to a file
+func TestZipExpander_Expand_SingleFile(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "archive.zip")
+	writeZip(t, src, map[string]string{"file.txt": "hello"})
+
+	dst := filepath.Join(tmp, "out", "file.txt")
+	z := &ZipExpander{}
+	if err := z.Expand(dst, src, false, 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "hello" {
+		t.Errorf("unexpected content: got %q, want %q", content, "hello")
+	}
+
+	writeZip(t, src, map[string]string{"a.txt": "a", "b.txt": "b"})
+	if err := z.Expand(dst, src, false, 0644); err == nil || !strings.Contains(err.Error(), "more than one file") {
+		t.Errorf("expected more than one file error, got: %v", err)
+	}
+}
+
+// TestZipExpander_Expand_Errors tests the protections applied while expanding a zip archive
+func TestZipExpander_Expand_Errors(t *testing.T) {
+	tmp := t.TempDir()
+
+	tests := []struct {
+		name     string
+		members  map[string]string
+		expander *ZipExpander
+		expected string
+	}{
+		{"escape", map[string]string{"../evil.txt": "evil"}, &ZipExpander{}, "would escape destination directory"},
+		{"files limit", map[string]string{"a.txt": "a", "b.txt": "b"}, &ZipExpander{FilesLimit: 1}, "more files than the 1 allowed"},
+		{"size limit", map[string]string{"a.txt": "aaaa", "b.txt": "bbbb"}, &ZipExpander{FileSizeLimit: 5}, "size exceeds the 5 limit"},
+		{"empty", map[string]string{}, &ZipExpander{}, "zip file is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := filepath.Join(tmp, tt.name+".zip")
+			writeZip(t, src, tt.members)
+			err := tt.expander.Expand(filepath.Join(tmp, tt.name), src, true, 0755)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q, got: %v", tt.expected, err)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(tmp, "evil.txt")); err == nil {
+		t.Error("expected escaping member not to be written")
+	}
+}
@@ -17,22 +17,70 @@
 package expander
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"strings"
+
+	gogather "github.com/enterprise-contract/go-gather"
 )
 
+// SizeLimitError is returned when the content expanded from an archive exceeds the file size limit
+// of the expander.
+type SizeLimitError struct {
+	Limit int64
+	msg   string
+}
+
+// sizeLimitError returns a SizeLimitError for limit described by format and args.
+func sizeLimitError(limit int64, format string, args ...any) error {
+	return &SizeLimitError{Limit: limit, msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *SizeLimitError) Error() string {
+	return e.msg
+}
+
+// Is reports whether target is gogather.ErrTooLarge.
+func (e *SizeLimitError) Is(target error) bool {
+	return target == gogather.ErrTooLarge
+}
+
 // Expander is an interface which defines the methods that an expander must implement in order expand a type
 type Expander interface {
 	Expand(src, dst string, dir bool, mode os.FileMode) error
 }
 
-// BaseExpanders creates the set of base expanders that are used to expand the different types of files
+// BaseExpanders creates the set of registered expanders that are used to expand the different types of files,
+// limited to filesLimit members and fileSizeLimit bytes of expanded content.
+// The map is keyed by the file extension, without the leading dot, that the expander handles.
 func BaseExpanders(filesLimit int, fileSizeLimit int64) map[string]Expander {
-	return map[string]Expander{
-		"tar": &TarExpander{},
+	expanders := make(map[string]Expander)
+	for _, format := range Formats() {
+		e, err := NewExpander(format, WithFilesLimit(filesLimit), WithFileSizeLimit(fileSizeLimit))
+		if err != nil {
+			continue
+		}
+		expanders[format] = e
+	}
+	return expanders
+}
+
+// FindExpander returns the expander for the path from the given set of expanders, matched by the
+// longest file extension, so that e.g. "bundle.tar.gz" prefers "tar.gz" over "gz".
+func FindExpander(path string, expanders map[string]Expander) (Expander, bool) {
+	var (
+		match    Expander
+		matchExt string
+	)
+	for ext, e := range expanders {
+		if strings.HasSuffix(path, "."+ext) && len(ext) > len(matchExt) {
+			match, matchExt = e, ext
+		}
 	}
+	return match, match != nil
 }
 
 // containsDotDot checks if the filepath value v contains a ".." entry.
@@ -52,8 +100,11 @@ func containsDotDot(v string) bool {
 
 func isSlash(r rune) bool { return r == '/' || r == '\\' }
 
-// copyReader copies a reader to a file. If fileSizeLimit is greater than 0, it will limit the size of the file.
-func copyReader(src io.Reader, dst string, mode os.FileMode, fileSizeLimit int64) error {
+// copyReader copies a reader, expected to yield size bytes or -1 if unknown, to a file, with a
+// pooled buffer fitting the size, recording the digest of the file in digests, if set. If
+// fileSizeLimit is greater than 0, it will fail when the reader yields more than fileSizeLimit
+// bytes.
+func copyReader(src io.Reader, dst string, mode os.FileMode, size, fileSizeLimit int64, digests *gogather.Digests) error {
 	dstF, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
 	if err != nil {
 		return fmt.Errorf("failed to open file %s: %w", dst, err)
@@ -61,13 +112,26 @@ func copyReader(src io.Reader, dst string, mode os.FileMode, fileSizeLimit int64
 	defer dstF.Close()
 
 	if fileSizeLimit > 0 {
-		src = io.LimitReader(src, fileSizeLimit)
+		// Read one byte past the limit so that exceeding it can be told apart from reaching it.
+		src = io.LimitReader(src, fileSizeLimit+1)
 	}
 
-	_, err = io.Copy(dstF, src)
+	var h hash.Hash
+	if digests != nil {
+		h = sha256.New()
+		src = io.TeeReader(src, h)
+	}
+	n, err := gogather.CopySized(dstF, src, size)
 	if err != nil {
 		return fmt.Errorf("failed to copy file %s: %w", dst, err)
 	}
 
+	if fileSizeLimit > 0 && n > fileSizeLimit {
+		return sizeLimitError(fileSizeLimit, "file %s exceeds the %d size limit", dst, fileSizeLimit)
+	}
+	if h != nil {
+		digests.Record(dst, n, h.Sum(nil))
+	}
+
 	return os.Chmod(dst, mode)
 }
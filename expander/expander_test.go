@@ -0,0 +1,58 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"testing"
+)
+
+// TestFindExpander tests looking up expanders by file extension
+func TestFindExpander(t *testing.T) {
+	expanders := BaseExpanders(10, 1024)
+
+	tests := []struct {
+		path     string
+		expected Expander
+	}{
+		{"/tmp/bundle.tar", expanders["tar"]},
+		{"/tmp/bundle.zip", expanders["zip"]},
+		{"/tmp/bundle.json", nil},
+		{"/tmp/tar", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			e, ok := FindExpander(tt.path, expanders)
+			if ok != (tt.expected != nil) || e != tt.expected {
+				t.Errorf("unexpected expander for %s: got %T, want %T", tt.path, e, tt.expected)
+			}
+		})
+	}
+}
+
+// TestBaseExpanders_Limits tests that BaseExpanders wires the limits through to the expanders
+func TestBaseExpanders_Limits(t *testing.T) {
+	expanders := BaseExpanders(10, 1024)
+
+	tar := expanders["tar"].(*TarExpander)
+	if tar.FilesLimit != 10 || tar.FileSizeLimit != 1024 {
+		t.Errorf("unexpected tar limits: %+v", tar)
+	}
+	zip := expanders["zip"].(*ZipExpander)
+	if zip.FilesLimit != 10 || zip.FileSizeLimit != 1024 {
+		t.Errorf("unexpected zip limits: %+v", zip)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// expandDir expands an archive into the directory dst using expand. If FlattenSingleRoot is set, the
+// archive is expanded into a staging directory inside dst first, and its content is then moved into dst,
+// without the top-level directory when that is the only member at the root of the archive.
+func (o ExtractOptions) expandDir(dst string, expand func(dst string) error) error {
+	if !o.FlattenSingleRoot {
+		return expand(dst)
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	staging, err := os.MkdirTemp(dst, ".expand-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if err := expand(staging); err != nil {
+		return err
+	}
+
+	content := staging
+	if root, ok := singleRoot(staging); ok {
+		// The directory is removed once its content is moved, which requires it to be writable.
+		if err := os.Chmod(root, 0700); err != nil {
+			return fmt.Errorf("failed to change directory permissions (%s): %w", root, err)
+		}
+		content = root
+	}
+
+	entries, err := os.ReadDir(content)
+	if err != nil {
+		return fmt.Errorf("failed to read staging directory: %w", err)
+	}
+	for _, e := range entries {
+		from, to := filepath.Join(content, e.Name()), filepath.Join(dst, e.Name())
+		if err := os.Rename(from, to); err != nil {
+			return fmt.Errorf("failed to move %s to %s: %w", e.Name(), dst, err)
+		}
+		o.Digests.Move(from, to)
+	}
+	return nil
+}
+
+// singleRoot returns the directory that is the only entry of dir, if any. A directory containing
+// symbolic links whose targets refer to it through its parent is not returned, as flattening it
+// would leave those links pointing outside of the destination.
+func singleRoot(dir string) (string, bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 || !entries[0].IsDir() {
+		return "", false
+	}
+	root := filepath.Join(dir, entries[0].Name())
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		depth := 0
+		if rel != "." {
+			depth = len(strings.FieldsFunc(rel, isSlash))
+		}
+		// Only leading ".." elements are permitted in link targets, see createSymlink.
+		for _, e := range strings.FieldsFunc(target, isSlash) {
+			if e != ".." {
+				break
+			}
+			if depth--; depth < 0 {
+				return fmt.Errorf("symlink (%s) refers to outside of %s", path, root)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", false
+	}
+	return root, true
+}
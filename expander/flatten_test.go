@@ -0,0 +1,134 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// TestExtractOptions_FlattenSingleRoot tests stripping the top-level directory of archives
+func TestExtractOptions_FlattenSingleRoot(t *testing.T) {
+	opts := ExtractOptions{FlattenSingleRoot: true}
+
+	tests := []struct {
+		name     string
+		entries  []tarEntry
+		expected string
+	}{
+		{
+			"single root",
+			[]tarEntry{
+				{name: "project-1.0/"},
+				{name: "project-1.0/main.rego", content: "package main"},
+				{name: "project-1.0/lib/helper.rego", content: "package lib"},
+			},
+			"lib/helper.rego,main.rego",
+		},
+		{
+			"single root without directory entry",
+			[]tarEntry{{name: "project-1.0/main.rego", content: "package main"}},
+			"main.rego",
+		},
+		{
+			"multiple roots",
+			[]tarEntry{
+				{name: "a/main.rego", content: "package main"},
+				{name: "b/main.rego", content: "package main"},
+			},
+			"a/main.rego,b/main.rego",
+		},
+		{
+			"single file",
+			[]tarEntry{{name: "main.rego", content: "package main"}},
+			"main.rego",
+		},
+		{
+			"link through the root",
+			[]tarEntry{
+				{name: "project/main.rego", content: "package main"},
+				{name: "project/link.rego", typeflag: '2', linkname: "../project/main.rego"},
+			},
+			"project/link.rego,project/main.rego",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst, err := expandFixture(t, &TarExpander{Options: opts}, "bundle.tar", makeTar(t, tt.entries...))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if files := strings.Join(listFiles(t, dst), ","); files != tt.expected {
+				t.Errorf("unexpected files: got %s, want %s", files, tt.expected)
+			}
+		})
+	}
+}
+
+// TestExtractOptions_FlattenSingleRoot_Zip tests stripping the top-level directory of a zip archive
+// into a destination that already has content
+func TestExtractOptions_FlattenSingleRoot_Zip(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "bundle.zip")
+	writeZip(t, src, map[string]string{"project/main.rego": "package main"})
+
+	dst := filepath.Join(tmp, "out")
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dst, "existing.txt"), []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	z := &ZipExpander{Options: ExtractOptions{FlattenSingleRoot: true}}
+	if err := z.Expand(dst, src, true, 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entries, err := os.ReadDir(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if got := strings.Join(names, ","); got != "existing.txt,main.rego" {
+		t.Errorf("unexpected entries: %s", got)
+	}
+}
+
+// TestExtractOptions_Digests tests recording the digests of extracted files
+func TestExtractOptions_Digests(t *testing.T) {
+	digests := gogather.NewDigests()
+	opts := ExtractOptions{FlattenSingleRoot: true, Digests: digests}
+	data := makeTar(t, tarEntry{name: "project-1.0/main.rego", content: "package main"})
+	dst, err := expandFixture(t, &TarExpander{Options: opts}, "bundle.tar", data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := sha256.Sum256([]byte("package main"))
+	if got, ok := digests.SHA256(filepath.Join(dst, "main.rego")); !ok || !bytes.Equal(got, want[:]) {
+		t.Errorf("unexpected digest: %x, %v", got, ok)
+	}
+}
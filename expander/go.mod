@@ -1,3 +1,22 @@
 module github.com/enterprise-contract/go-gather/expander
 
 go 1.22.5
+
+require (
+	github.com/bodgit/sevenzip v1.6.0
+	github.com/enterprise-contract/go-gather v0.0.3
+	github.com/klauspost/compress v1.18.0
+	github.com/ulikunitz/xz v0.5.12
+)
+
+require (
+	github.com/andybalholm/brotli v1.1.1 // indirect
+	github.com/bodgit/plumbing v1.3.0 // indirect
+	github.com/bodgit/windows v1.0.1 // indirect
+	github.com/hashicorp/errwrap v1.1.0 // indirect
+	github.com/hashicorp/go-multierror v1.1.1 // indirect
+	github.com/hashicorp/golang-lru/v2 v2.0.7 // indirect
+	github.com/pierrec/lz4/v4 v4.1.21 // indirect
+	go4.org v0.0.0-20200411211856-f5505b9728dd // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
@@ -0,0 +1,176 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LinkPolicy controls how symbolic and hard links found in archives are handled.
+type LinkPolicy int
+
+const (
+	// AllowLinks recreates links whose targets stay inside the destination directory
+	// and fails on links that would point outside of it.
+	AllowLinks LinkPolicy = iota
+	// SkipLinks silently ignores link entries.
+	SkipLinks
+	// RejectLinks fails the expansion when a link entry is encountered.
+	RejectLinks
+)
+
+// String returns the string representation of the LinkPolicy
+func (p LinkPolicy) String() string {
+	switch p {
+	case AllowLinks:
+		return "AllowLinks"
+	case SkipLinks:
+		return "SkipLinks"
+	case RejectLinks:
+		return "RejectLinks"
+	}
+	return fmt.Sprintf("LinkPolicy(%d)", int(p))
+}
+
+// isWithin reports whether path is root or is located below it. Both paths must be clean.
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// resolvedRoot returns the destination directory with symbolic links evaluated, so that it can be
+// compared against other resolved paths.
+func resolvedRoot(dst string) (string, error) {
+	root, err := filepath.EvalSymlinks(dst)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve destination directory (%s): %w", dst, err)
+	}
+	return filepath.Abs(root)
+}
+
+// checkWithin verifies that path, or its deepest existing ancestor, resolves to a location inside root.
+// This prevents writing through a previously extracted symbolic link to outside of the destination.
+func checkWithin(root, path string) error {
+	existing := path
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if !isWithin(root, resolved) {
+				return fmt.Errorf("archive member (%s) would escape destination directory through a symlink", path)
+			}
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to resolve path (%s): %w", existing, err)
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return nil
+		}
+		existing = parent
+	}
+}
+
+// removeNonDirectory removes whatever is at path, unless it is a directory, so that an archive member can
+// replace it. In particular this ensures that a member is never written through an existing symbolic link.
+func removeNonDirectory(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("archive member (%s) would replace a directory", path)
+	}
+	return os.Remove(path)
+}
+
+// createSymlink creates the symbolic link fPath pointing at target after validating that the target
+// stays inside root. Absolute targets are rejected, and ".." elements are only permitted at the start
+// of the target where they are evaluated against the resolved location of the link, so that the
+// check cannot be side-stepped by climbing out of a directory reached through another link.
+func createSymlink(root, fPath, target string) error {
+	if target == "" || filepath.IsAbs(target) || strings.HasPrefix(target, "/") || strings.HasPrefix(target, "\\") {
+		return fmt.Errorf("symlink (%s) has a target outside of the destination directory: %s", fPath, target)
+	}
+
+	elems := strings.FieldsFunc(target, isSlash)
+	leading := 0
+	for leading < len(elems) && elems[leading] == ".." {
+		leading++
+	}
+	for _, e := range elems[leading:] {
+		if e == ".." {
+			return fmt.Errorf("symlink (%s) has an unsupported target: %s", fPath, target)
+		}
+	}
+
+	parent, err := filepath.EvalSymlinks(filepath.Dir(fPath))
+	if err != nil {
+		return fmt.Errorf("failed to resolve directory (%s): %w", filepath.Dir(fPath), err)
+	}
+	if resolved := filepath.Join(parent, filepath.Join(elems...)); !isWithin(root, resolved) {
+		return fmt.Errorf("symlink (%s) has a target outside of the destination directory: %s", fPath, target)
+	}
+
+	if err := removeNonDirectory(fPath); err != nil {
+		return err
+	}
+	if err := os.Symlink(target, fPath); err != nil {
+		return fmt.Errorf("failed to create symlink (%s): %w", fPath, err)
+	}
+	return nil
+}
+
+// createHardlink creates the hard link fPath to the previously extracted archive member target, which
+// is relative to the root of the archive. The target must resolve to a regular file inside root.
+func createHardlink(dst, root, fPath, target string) error {
+	if target == "" || filepath.IsAbs(target) || containsDotDot(target) {
+		return fmt.Errorf("hard link (%s) has a target outside of the destination directory: %s", fPath, target)
+	}
+
+	resolved, err := filepath.EvalSymlinks(filepath.Join(dst, target)) // nolint:gosec
+	if err != nil {
+		return fmt.Errorf("failed to resolve hard link target (%s): %w", target, err)
+	}
+	if !isWithin(root, resolved) {
+		return fmt.Errorf("hard link (%s) has a target outside of the destination directory: %s", fPath, target)
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return fmt.Errorf("failed to stat hard link target (%s): %w", target, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("hard link (%s) target is not a regular file: %s", fPath, target)
+	}
+
+	if err := removeNonDirectory(fPath); err != nil {
+		return err
+	}
+	if err := os.Link(resolved, fPath); err != nil {
+		return fmt.Errorf("failed to create hard link (%s): %w", fPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSymlinkHops is the maximum number of symbolic links followed when resolving a path in a MemFS.
+const maxSymlinkHops = 40
+
+// MemFS is an in-memory filesystem that archives can be expanded into with ExpandTo, allowing their
+// content to be inspected through the fs.FS interfaces without writing to disk. It is safe for
+// concurrent use.
+type MemFS struct {
+	mu    sync.RWMutex
+	files map[string]*memFile
+}
+
+// memFile is a file, directory or symbolic link in a MemFS.
+type memFile struct {
+	name    string
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+	target  string
+}
+
+// NewMemFS creates an empty in-memory filesystem.
+func NewMemFS() *MemFS {
+	return &MemFS{files: map[string]*memFile{
+		".": {name: ".", mode: fs.ModeDir | 0755, modTime: time.Now()},
+	}}
+}
+
+// MkdirAll creates the directory name, along with any necessary parents.
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mkdirAll(name, perm)
+}
+
+func (m *MemFS) mkdirAll(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	dirs := []string{"."}
+	if name != "." {
+		elems := strings.Split(name, "/")
+		for i := range elems {
+			dirs = append(dirs, strings.Join(elems[:i+1], "/"))
+		}
+	}
+	for _, dir := range dirs {
+		f, ok := m.files[dir]
+		if !ok {
+			m.files[dir] = &memFile{name: path.Base(dir), mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+		} else if !f.mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: fmt.Errorf("not a directory")}
+		}
+	}
+	return nil
+}
+
+// WriteFile creates or replaces the file name with the content read from r, creating any missing
+// parent directories.
+func (m *MemFS) WriteFile(name string, r io.Reader, perm fs.FileMode, modTime time.Time) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.prepare("write", name); err != nil {
+		return err
+	}
+	m.files[name] = &memFile{name: path.Base(name), data: data, mode: perm.Perm(), modTime: modTime}
+	return nil
+}
+
+// Symlink creates name as a symbolic link to target, creating any missing parent directories.
+func (m *MemFS) Symlink(target, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.prepare("symlink", name); err != nil {
+		return err
+	}
+	m.files[name] = &memFile{name: path.Base(name), mode: fs.ModeSymlink | 0777, modTime: time.Now(), target: target}
+	return nil
+}
+
+// prepare creates the parent directories of name and checks that name is not a directory.
+func (m *MemFS) prepare(op, name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if err := m.mkdirAll(path.Dir(name), 0755); err != nil {
+		return err
+	}
+	if f, ok := m.files[name]; ok && f.mode.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: fmt.Errorf("is a directory")}
+	}
+	return nil
+}
+
+// resolve returns the file at name, following symbolic links.
+func (m *MemFS) resolve(op, name string) (string, *memFile, error) {
+	if !fs.ValidPath(name) {
+		return "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	for hops := 0; hops <= maxSymlinkHops; hops++ {
+		f, ok := m.files[name]
+		if !ok {
+			return "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		if f.mode&fs.ModeSymlink == 0 {
+			return name, f, nil
+		}
+		if path.IsAbs(f.target) {
+			return "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		target := path.Join(path.Dir(name), f.target)
+		if !fs.ValidPath(target) {
+			return "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		name = target
+	}
+	return "", nil, &fs.PathError{Op: op, Path: name, Err: fmt.Errorf("too many levels of symbolic links")}
+}
+
+// Open opens the named file, following symbolic links.
+func (m *MemFS) Open(name string) (fs.File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	resolved, f, err := m.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	info := &memFileInfo{f: f, name: path.Base(name)}
+	if f.mode.IsDir() {
+		entries, err := m.readDir(resolved)
+		if err != nil {
+			return nil, err
+		}
+		return &memDir{info: info, entries: entries}, nil
+	}
+	return &memOpenFile{info: info, Reader: bytes.NewReader(f.data)}, nil
+}
+
+// ReadFile returns the content of the named file.
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, f, err := m.resolve("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	if f.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fmt.Errorf("is a directory")}
+	}
+	return bytes.Clone(f.data), nil
+}
+
+// ReadDir returns the entries of the named directory, sorted by name.
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	resolved, f, err := m.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !f.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("not a directory")}
+	}
+	return m.readDir(resolved)
+}
+
+func (m *MemFS) readDir(dir string) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	for p, f := range m.files {
+		if p != "." && path.Dir(p) == dir {
+			entries = append(entries, &memFileInfo{f: f, name: f.name})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// Stat returns the file info of the named file, following symbolic links.
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, f, err := m.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return &memFileInfo{f: f, name: path.Base(name)}, nil
+}
+
+// Readlink returns the target of the named symbolic link.
+func (m *MemFS) Readlink(name string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	f, ok := m.files[name]
+	if !ok {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrNotExist}
+	}
+	if f.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.target, nil
+}
+
+// memFileInfo describes a memFile, implementing both fs.FileInfo and fs.DirEntry.
+type memFileInfo struct {
+	f    *memFile
+	name string
+}
+
+func (i *memFileInfo) Name() string               { return i.name }
+func (i *memFileInfo) Size() int64                { return int64(len(i.f.data)) }
+func (i *memFileInfo) Mode() fs.FileMode          { return i.f.mode }
+func (i *memFileInfo) ModTime() time.Time         { return i.f.modTime }
+func (i *memFileInfo) IsDir() bool                { return i.f.mode.IsDir() }
+func (i *memFileInfo) Sys() any                   { return nil }
+func (i *memFileInfo) Type() fs.FileMode          { return i.f.mode.Type() }
+func (i *memFileInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+// memOpenFile is an open regular file of a MemFS.
+type memOpenFile struct {
+	*bytes.Reader
+	info *memFileInfo
+}
+
+func (f *memOpenFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memOpenFile) Close() error               { return nil }
+
+// memDir is an open directory of a MemFS.
+type memDir struct {
+	info    *memFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fmt.Errorf("is a directory")}
+}
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries := d.entries[d.offset:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		if n < len(entries) {
+			entries = entries[:n]
+		}
+	}
+	d.offset += len(entries)
+	return entries, nil
+}
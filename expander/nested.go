@@ -0,0 +1,171 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// NestedExpander expands an archive and then the archives found inside of it, e.g. a tar.gz bundle
+// wrapped in an outer tar, replacing each nested archive with its expanded content. Nested archives
+// are expanded into a directory named after the archive without its extension, while compressed
+// single files are decompressed next to the compressed file.
+type NestedExpander struct {
+	// Expander expands the outer archive.
+	Expander Expander
+	// MaxDepth is the number of levels of nested archives that are expanded. Zero only expands
+	// the outer archive.
+	MaxDepth int
+	// TotalSizeLimit is the maximum cumulative size of the content expanded across all levels.
+	// Zero means no limit.
+	TotalSizeLimit int64
+	// Options configures the expanders of the nested archives.
+	Options []Option
+}
+
+// NestingSizeError is returned when the content expanded from nested archives exceeds the
+// cumulative size limit.
+type NestingSizeError struct {
+	Limit int64
+	Size  int64
+}
+
+func (e *NestingSizeError) Error() string {
+	return fmt.Sprintf("expanded content of nested archives exceeds the %d size limit: %d", e.Limit, e.Size)
+}
+
+// Is reports whether target is gogather.ErrTooLarge.
+func (e *NestingSizeError) Is(target error) bool {
+	return target == gogather.ErrTooLarge
+}
+
+func (n *NestedExpander) Expand(dst, src string, dir bool, umask os.FileMode) error {
+	if !dir || n.MaxDepth <= 0 {
+		return n.Expander.Expand(dst, src, dir, umask)
+	}
+
+	existing, err := regularFiles(dst, nil)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := n.Expander.Expand(dst, src, dir, umask); err != nil {
+		return err
+	}
+
+	pending, err := regularFiles(dst, existing)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, size := range pending {
+		total += size
+	}
+	if err := n.checkSize(total); err != nil {
+		return err
+	}
+
+	for depth := 1; depth <= n.MaxDepth && len(pending) > 0; depth++ {
+		next := map[string]int64{}
+		for path := range pending {
+			produced, err := n.expandNested(path, umask, total)
+			if err != nil {
+				return err
+			}
+			for p, size := range produced {
+				next[p] = size
+				total += size
+			}
+			if err := n.checkSize(total); err != nil {
+				return err
+			}
+		}
+		pending = next
+	}
+	return nil
+}
+
+// expandNested expands the file at path if it is an archive, and returns the regular files produced
+// along with their sizes. total is the size of the content expanded so far.
+func (n *NestedExpander) expandNested(path string, umask os.FileMode, total int64) (map[string]int64, error) {
+	factory, format := lookupFormat(path)
+	if factory == nil {
+		return nil, nil
+	}
+
+	opts := n.Options
+	if n.TotalSizeLimit > 0 {
+		opts = append(opts[:len(opts):len(opts)], WithFileSizeLimit(n.TotalSizeLimit-total))
+	}
+	e := factory(newConfig(opts...))
+
+	// Compressed single files are decompressed next to the compressed file, archives into a
+	// directory named after them.
+	target := path[:len(path)-len(format)-1]
+	dir := true
+	switch e.(type) {
+	case *GzipExpander, *Bzip2Expander, *XzExpander, *ZstdExpander:
+		dir = false
+	}
+	if _, err := os.Lstat(target); err == nil {
+		return nil, fmt.Errorf("failed to expand nested archive (%s): %s already exists", path, target)
+	}
+	if err := e.Expand(target, path, dir, umask); err != nil {
+		return nil, fmt.Errorf("failed to expand nested archive (%s): %w", path, err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return nil, fmt.Errorf("failed to remove nested archive (%s): %w", path, err)
+	}
+	return regularFiles(target, nil)
+}
+
+func (n *NestedExpander) checkSize(total int64) error {
+	if n.TotalSizeLimit > 0 && total > n.TotalSizeLimit {
+		return &NestingSizeError{Limit: n.TotalSizeLimit, Size: total}
+	}
+	return nil
+}
+
+// regularFiles returns the regular files at or below root, excluding those in exclude, along with their
+// sizes. Symbolic links are not followed.
+func regularFiles(root string, exclude map[string]int64) (map[string]int64, error) {
+	files := map[string]int64{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if _, ok := exclude[path]; ok {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[path] = info.Size()
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,99 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// nestedTar returns a tarball containing a README and inner.tar.gz, which contains sampleTar and
+// data.json.gz.
+func nestedTar(t *testing.T) []byte {
+	inner := makeTar(t,
+		tarEntry{name: "policy.tar", content: string(sampleTar(t))},
+		tarEntry{name: "data.json.gz", content: string(compressedFixtures(t, []byte("{}"))["gz"])},
+	)
+	return makeTar(t,
+		tarEntry{name: "README.md", content: "readme"},
+		tarEntry{name: "inner.tar.gz", content: string(compressedFixtures(t, inner)["gz"])},
+	)
+}
+
+// TestNestedExpander_Expand tests expanding archives inside archives up to the maximum depth
+func TestNestedExpander_Expand(t *testing.T) {
+	tests := []struct {
+		depth    int
+		expected string
+	}{
+		{0, "README.md,inner.tar.gz"},
+		{1, "README.md,inner/data.json.gz,inner/policy.tar"},
+		{2, "README.md,inner/data.json,inner/policy/policy/lib/helper.rego,inner/policy/policy/main.rego"},
+		{5, "README.md,inner/data.json,inner/policy/policy/lib/helper.rego,inner/policy/policy/main.rego"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Repeat("n", tt.depth), func(t *testing.T) {
+			e := &NestedExpander{Expander: &TarExpander{}, MaxDepth: tt.depth}
+			dst, err := expandFixture(t, e, "bundle.tar", nestedTar(t))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if files := strings.Join(listFiles(t, dst), ","); files != tt.expected {
+				t.Errorf("unexpected files: got %s, want %s", files, tt.expected)
+			}
+		})
+	}
+}
+
+// TestNestedExpander_Expand_TotalSizeLimit tests that the cumulative size of all levels is limited
+func TestNestedExpander_Expand_TotalSizeLimit(t *testing.T) {
+	// The outer archive alone exceeds the limit
+	e := &NestedExpander{Expander: &TarExpander{}, MaxDepth: 2, TotalSizeLimit: 10}
+	_, err := expandFixture(t, e, "bundle.tar", nestedTar(t))
+	var sizeErr *NestingSizeError
+	if !errors.As(err, &sizeErr) || sizeErr.Limit != 10 {
+		t.Errorf("expected a nesting size error, got: %v", err)
+	}
+
+	// The nested archives are limited to what remains
+	e = &NestedExpander{Expander: &TarExpander{}, MaxDepth: 2, TotalSizeLimit: 1024}
+	_, err = expandFixture(t, e, "bundle.tar", nestedTar(t))
+	if err == nil || !strings.Contains(err.Error(), "failed to expand nested archive") || !strings.Contains(err.Error(), "exceeds the") {
+		t.Errorf("expected a size limit error, got: %v", err)
+	}
+}
+
+// TestNestedExpander_Expand_Exists tests that a nested archive does not replace existing content
+func TestNestedExpander_Expand_Exists(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "bundle.tar")
+	if err := os.WriteFile(src, makeTar(t,
+		tarEntry{name: "inner/"},
+		tarEntry{name: "inner.tar", content: string(sampleTar(t))},
+	), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &NestedExpander{Expander: &TarExpander{}, MaxDepth: 1}
+	err := e.Expand(filepath.Join(tmp, "out"), src, true, 0755)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("expected an already exists error, got: %v", err)
+	}
+}
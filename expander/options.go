@@ -0,0 +1,115 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// ExtractOptions holds the settings shared by the archive expanders that control
+// which members are extracted and how.
+type ExtractOptions struct {
+	// Include lists glob patterns of archive members to extract. When empty, every
+	// member is extracted. Patterns are matched against the slash separated member
+	// name: "*" matches within a single path element, "**" matches across elements
+	// and "?" matches a single character, e.g. "policies/**.rego".
+	Include []string
+	// Exclude lists glob patterns of archive members to skip, using the same syntax
+	// as Include. Exclusions take precedence over inclusions.
+	Exclude []string
+	// Links controls how symbolic and hard link members are handled. By default links
+	// are recreated when their targets stay inside the destination directory.
+	Links LinkPolicy
+	// PreserveOwner changes the owner of extracted members to the user and group
+	// recorded in the archive. This requires the process to have the privilege to
+	// change file ownership; without it, or on platforms that do not support it,
+	// ownership is left as is. Only tar archives record ownership.
+	PreserveOwner bool
+	// OwnerMapper, if set, maps the user and group IDs recorded in the archive to
+	// the IDs to assign when PreserveOwner is set, e.g. to shift IDs into the range
+	// of a user namespace.
+	OwnerMapper func(uid, gid int) (int, int)
+	// OnEntry, if set, is called for each archive member before it is extracted, e.g. to
+	// log a manifest of the extracted content or to enforce a custom policy. Returning
+	// an error aborts the expansion.
+	OnEntry func(Entry) error
+	// FlattenSingleRoot strips the top-level directory of an archive when every member
+	// is located below it, e.g. "project-1.0/main.rego" is extracted as "main.rego".
+	// This only applies when expanding into a directory.
+	FlattenSingleRoot bool
+	// Digests, if set, records the SHA256 digests of the regular files extracted from tar,
+	// zip and 7z archives while they are written, so that the tree hash of the expanded
+	// content can be computed without reading them back, see metadata.TreeHashWithDigests.
+	Digests *gogather.Digests
+	// IgnoreTimes leaves extracted members with the time they were extracted at instead of
+	// restoring the times recorded in the archive, which saves a system call per member, e.g.
+	// when the times are normalized afterwards.
+	IgnoreTimes bool
+}
+
+// Entry describes an archive member that is about to be extracted.
+type Entry struct {
+	// Name is the name of the member as recorded in the archive.
+	Name string
+	// Size is the uncompressed size of the member in bytes.
+	Size int64
+	// Mode holds the permission and type bits of the member, e.g. fs.ModeDir,
+	// fs.ModeSymlink or fs.ModeSetuid.
+	Mode fs.FileMode
+	// Linkname is the target of a link member.
+	Linkname string
+}
+
+// visit passes the entry to the OnEntry callback, if set.
+func (o ExtractOptions) visit(e Entry) error {
+	if o.OnEntry == nil {
+		return nil
+	}
+	if err := o.OnEntry(e); err != nil {
+		return fmt.Errorf("archive member (%s) rejected: %w", e.Name, err)
+	}
+	return nil
+}
+
+// chown changes the owner of the extracted member at path to uid and gid, as recorded in the archive,
+// when PreserveOwner is set. Symbolic links themselves are changed rather than their targets. Failures
+// caused by the process lacking the privilege to change ownership are ignored.
+func (o ExtractOptions) chown(path string, uid, gid int) error {
+	if !o.PreserveOwner {
+		return nil
+	}
+	if o.OwnerMapper != nil {
+		uid, gid = o.OwnerMapper(uid, gid)
+	}
+	if err := os.Lchown(path, uid, gid); err != nil {
+		if errors.Is(err, fs.ErrPermission) || errors.Is(err, errors.ErrUnsupported) {
+			return nil
+		}
+		return fmt.Errorf("failed to change owner (%s): %w", path, err)
+	}
+	return nil
+}
+
+// newMemberFilter compiles the Include and Exclude patterns of the options.
+func (o ExtractOptions) newMemberFilter() (*gogather.PathFilter, error) {
+	return gogather.NewPathFilter(o.Include, o.Exclude)
+}
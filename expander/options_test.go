@@ -0,0 +1,151 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMemberFilter_Match tests matching archive member names against include and exclude patterns
+func TestMemberFilter_Match(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     ExtractOptions
+		member   string
+		expected bool
+	}{
+		{"no filters", ExtractOptions{}, "anything/at/all.txt", true},
+		{"double star across directories", ExtractOptions{Include: []string{"policies/**.rego"}}, "policies/a/b/main.rego", true},
+		{"double star direct child", ExtractOptions{Include: []string{"policies/**.rego"}}, "policies/main.rego", true},
+		{"double star wrong extension", ExtractOptions{Include: []string{"policies/**.rego"}}, "policies/a/data.json", false},
+		{"double star slash zero directories", ExtractOptions{Include: []string{"a/**/b.txt"}}, "a/b.txt", true},
+		{"single star stays in element", ExtractOptions{Include: []string{"policies/*.rego"}}, "policies/a/main.rego", false},
+		{"question mark", ExtractOptions{Include: []string{"v?.txt"}}, "v1.txt", true},
+		{"leading dot slash", ExtractOptions{Include: []string{"policies/*"}}, "./policies/main.rego", true},
+		{"directory member", ExtractOptions{Include: []string{"policies"}}, "policies/", true},
+		{"literal metacharacters", ExtractOptions{Include: []string{"a+b.txt"}}, "aab.txt", false},
+		{"exclude", ExtractOptions{Exclude: []string{"**_test.rego"}}, "policies/main_test.rego", false},
+		{"exclude wins over include", ExtractOptions{Include: []string{"**.rego"}, Exclude: []string{"**_test.rego"}}, "main_test.rego", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := tt.opts.newMemberFilter()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := f.Match(tt.member); got != tt.expected {
+				t.Errorf("match(%q) = %v, want %v", tt.member, got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := (ExtractOptions{Include: []string{""}}).newMemberFilter(); err == nil {
+		t.Error("expected an error for an empty pattern, but got nil")
+	}
+}
+
+// TestExtractOptions_Filters tests that filters are applied when expanding tar and zip archives
+func TestExtractOptions_Filters(t *testing.T) {
+	opts := ExtractOptions{Include: []string{"policy/**.rego"}, Exclude: []string{"**/lib/**"}}
+
+	dst, err := expandFixture(t, &TarExpander{Options: opts}, "bundle.tar", makeTar(t,
+		tarEntry{name: "README.md", content: "readme"},
+		tarEntry{name: "policy/main.rego", content: "package main"},
+		tarEntry{name: "policy/lib/helper.rego", content: "package lib"},
+	))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if files := strings.Join(listFiles(t, dst), ","); files != "policy/main.rego" {
+		t.Errorf("unexpected files extracted from tar: %s", files)
+	}
+
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "bundle.zip")
+	writeZip(t, src, map[string]string{
+		"README.md":              "readme",
+		"policy/main.rego":       "package main",
+		"policy/lib/helper.rego": "package lib",
+	})
+	z := &ZipExpander{Options: opts}
+	if err := z.Expand(filepath.Join(tmp, "out"), src, true, 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if files := strings.Join(listFiles(t, filepath.Join(tmp, "out")), ","); files != "policy/main.rego" {
+		t.Errorf("unexpected files extracted from zip: %s", files)
+	}
+
+	// Nothing matching is not an error
+	_, err = expandFixture(t, &TarExpander{Options: ExtractOptions{Include: []string{"*.json"}}}, "bundle.tar", sampleTar(t))
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestExtractOptions_OnEntry tests that the entry callback sees every extracted member and can abort the expansion
+func TestExtractOptions_OnEntry(t *testing.T) {
+	var names []string
+	opts := ExtractOptions{Exclude: []string{"README.md"}, OnEntry: func(e Entry) error {
+		names = append(names, fmt.Sprintf("%s:%d:%v", e.Name, e.Size, e.Mode.IsDir()))
+		return nil
+	}}
+	_, err := expandFixture(t, &TarExpander{Options: opts}, "bundle.tar", makeTar(t,
+		tarEntry{name: "README.md", content: "readme"},
+		tarEntry{name: "policy/"},
+		tarEntry{name: "policy/main.rego", content: "package main"},
+	))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(names, ","); got != "policy/:0:true,policy/main.rego:12:false" {
+		t.Errorf("unexpected entries: %s", got)
+	}
+
+	// Rejecting setuid files
+	errSetuid := errors.New("setuid files are not allowed")
+	opts = ExtractOptions{OnEntry: func(e Entry) error {
+		if e.Mode&fs.ModeSetuid != 0 {
+			return errSetuid
+		}
+		return nil
+	}}
+	dst, err := expandFixture(t, &TarExpander{Options: opts}, "bundle.tar", makeTar(t,
+		tarEntry{name: "ok.sh", content: "echo ok"},
+		tarEntry{name: "suid.sh", content: "echo root", mode: 04755},
+	))
+	if !errors.Is(err, errSetuid) {
+		t.Errorf("expected the callback error, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "suid.sh")); !os.IsNotExist(err) {
+		t.Errorf("expected the rejected member not to be extracted: %v", err)
+	}
+
+	// Zip members are visited as well
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "bundle.zip")
+	writeZip(t, src, map[string]string{"a.txt": "a"})
+	z := &ZipExpander{Options: ExtractOptions{OnEntry: func(e Entry) error { return errSetuid }}}
+	if err := z.Expand(filepath.Join(tmp, "out"), src, true, 0755); !errors.Is(err, errSetuid) {
+		t.Errorf("expected the callback error, got: %v", err)
+	}
+}
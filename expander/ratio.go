@@ -0,0 +1,102 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"fmt"
+	"io"
+)
+
+// DefaultMaxCompressionRatio is the decompressed-to-compressed size ratio above which BaseExpanders
+// aborts an expansion. Legitimate content rarely compresses beyond this, while decompression bombs
+// are built to far exceed it.
+const DefaultMaxCompressionRatio = 200
+
+// ratioGracePeriod is the number of decompressed bytes that may be produced before the compression
+// ratio is enforced, so that small, highly compressible files do not trip the limit.
+const ratioGracePeriod = 1 << 20
+
+// CompressionRatioError is returned when the decompressed size of the input grows beyond the
+// configured multiple of its compressed size, which indicates a decompression bomb.
+type CompressionRatioError struct {
+	Compressed   int64
+	Decompressed int64
+	Limit        float64
+}
+
+func (e *CompressionRatioError) Error() string {
+	return fmt.Sprintf("compression ratio exceeds the %g limit: %d bytes decompressed from %d bytes", e.Limit, e.Decompressed, e.Compressed)
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ratioReader fails reads of decompressed data once the ratio of decompressed to compressed
+// bytes exceeds limit. compressed reports the number of compressed bytes consumed so far.
+type ratioReader struct {
+	r            io.Reader
+	compressed   func() int64
+	decompressed int64
+	limit        float64
+}
+
+func (r *ratioReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.decompressed += int64(n)
+	if r.decompressed > ratioGracePeriod {
+		compressed := r.compressed()
+		if compressed < 1 {
+			compressed = 1
+		}
+		if float64(r.decompressed)/float64(compressed) > r.limit {
+			return n, &CompressionRatioError{Compressed: compressed, Decompressed: r.decompressed, Limit: r.limit}
+		}
+	}
+	return n, err
+}
+
+// limitRatio wraps the decompressor returned by newReader so that reading from it fails once the
+// compression ratio exceeds limit. A limit of zero or less disables the check.
+func limitRatio(newReader func(io.Reader) (io.Reader, error), limit float64) func(io.Reader) (io.Reader, error) {
+	if limit <= 0 {
+		return newReader
+	}
+	return func(r io.Reader) (io.Reader, error) {
+		counter := &countingReader{r: r}
+		d, err := newReader(counter)
+		if err != nil {
+			return nil, err
+		}
+		rr := &ratioReader{r: d, compressed: func() int64 { return counter.n }, limit: limit}
+		if c, ok := d.(io.Closer); ok {
+			return struct {
+				io.Reader
+				io.Closer
+			}{rr, c}, nil
+		}
+		return rr, nil
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCompressionRatio tests that highly compressed input is rejected by the base expanders
+func TestCompressionRatio(t *testing.T) {
+	// 8 MiB of zeros compresses far beyond the default ratio
+	bomb := bytes.Repeat([]byte{0}, 8<<20)
+	fixtures := compressedFixtures(t, bomb)
+	expanders := BaseExpanders(0, 0)
+
+	for ext, data := range fixtures {
+		t.Run(ext, func(t *testing.T) {
+			tmp := t.TempDir()
+			src := filepath.Join(tmp, "bomb."+ext)
+			if err := os.WriteFile(src, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var ratioErr *CompressionRatioError
+			if err := expanders[ext].Expand(filepath.Join(tmp, "out"), src, true, 0644); !errors.As(err, &ratioErr) {
+				t.Fatalf("expected a compression ratio error, got: %v", err)
+			}
+			if ratioErr.Limit != DefaultMaxCompressionRatio {
+				t.Errorf("unexpected limit: got %g, want %d", ratioErr.Limit, DefaultMaxCompressionRatio)
+			}
+		})
+	}
+
+	t.Run("tar.gz", func(t *testing.T) {
+		data := compressedFixtures(t, makeTar(t, tarEntry{name: "bomb", content: string(bomb)}))["gz"]
+		var ratioErr *CompressionRatioError
+		if _, err := expandFixture(t, BaseExpanders(0, 0)["tar.gz"], "bomb.tar.gz", data); !errors.As(err, &ratioErr) {
+			t.Fatalf("expected a compression ratio error, got: %v", err)
+		}
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		tmp := t.TempDir()
+		src := filepath.Join(tmp, "bomb.zip")
+		writeZip(t, src, map[string]string{"bomb": string(bomb)})
+		var ratioErr *CompressionRatioError
+		if err := BaseExpanders(0, 0)["zip"].Expand(filepath.Join(tmp, "out"), src, true, 0644); !errors.As(err, &ratioErr) {
+			t.Fatalf("expected a compression ratio error, got: %v", err)
+		}
+	})
+}
+
+// TestCompressionRatio_Disabled tests that a zero ratio disables the check
+func TestCompressionRatio_Disabled(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "bomb.gz")
+	if err := os.WriteFile(src, compressedFixtures(t, bytes.Repeat([]byte{0}, 8<<20))["gz"], 0644); err != nil {
+		t.Fatal(err)
+	}
+	g := &GzipExpander{}
+	if err := g.Expand(tmp, src, true, 0644); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestCompressionRatio_GracePeriod tests that small, highly compressible files are not rejected
+func TestCompressionRatio_GracePeriod(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "zeros.gz")
+	if err := os.WriteFile(src, compressedFixtures(t, bytes.Repeat([]byte{0}, 512<<10))["gz"], 0644); err != nil {
+		t.Fatal(err)
+	}
+	g := &GzipExpander{MaxCompressionRatio: DefaultMaxCompressionRatio}
+	if err := g.Expand(tmp, src, true, 0644); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Config holds the settings an expander is created with by NewExpander.
+type Config struct {
+	// FilesLimit is the maximum number of members an archive may contain. Zero means no limit.
+	FilesLimit int
+	// FileSizeLimit is the maximum total size of the expanded content. Zero means no limit.
+	FileSizeLimit int64
+	// MaxCompressionRatio is the maximum ratio of decompressed to compressed size.
+	// Zero disables the check.
+	MaxCompressionRatio float64
+	// Options controls which archive members are extracted and how.
+	Options ExtractOptions
+	// Password decrypts the members of encrypted archives.
+	Password string
+}
+
+// Option configures the expander created by NewExpander.
+type Option func(*Config)
+
+// WithFilesLimit limits the number of members an archive may contain.
+func WithFilesLimit(limit int) Option {
+	return func(c *Config) {
+		c.FilesLimit = limit
+	}
+}
+
+// WithFileSizeLimit limits the total size of the expanded content.
+func WithFileSizeLimit(limit int64) Option {
+	return func(c *Config) {
+		c.FileSizeLimit = limit
+	}
+}
+
+// WithMaxCompressionRatio sets the maximum ratio of decompressed to compressed size. A ratio of
+// zero disables the check.
+func WithMaxCompressionRatio(ratio float64) Option {
+	return func(c *Config) {
+		c.MaxCompressionRatio = ratio
+	}
+}
+
+// WithExtractOptions sets the options controlling which archive members are extracted and how.
+func WithExtractOptions(opts ExtractOptions) Option {
+	return func(c *Config) {
+		c.Options = opts
+	}
+}
+
+// WithPassword sets the password used to decrypt the members of encrypted archives.
+func WithPassword(password string) Option {
+	return func(c *Config) {
+		c.Password = password
+	}
+}
+
+// Factory creates an expander configured with the given settings.
+type Factory func(c Config) Expander
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{
+		"tar": func(c Config) Expander {
+			return &TarExpander{FileSizeLimit: c.FileSizeLimit, FilesLimit: c.FilesLimit, Options: c.Options}
+		},
+		"tar.gz":  tarGzipFactory,
+		"tgz":     tarGzipFactory,
+		"tar.bz2": tarBzip2Factory,
+		"tbz2":    tarBzip2Factory,
+		"tar.xz":  tarXzFactory,
+		"txz":     tarXzFactory,
+		"tar.zst": tarZstdFactory,
+		"tzst":    tarZstdFactory,
+		"zip": func(c Config) Expander {
+			return &ZipExpander{FileSizeLimit: c.FileSizeLimit, FilesLimit: c.FilesLimit, Options: c.Options, MaxCompressionRatio: c.MaxCompressionRatio, Password: c.Password}
+		},
+		"7z": func(c Config) Expander {
+			return &SevenZipExpander{FileSizeLimit: c.FileSizeLimit, FilesLimit: c.FilesLimit, Options: c.Options, MaxCompressionRatio: c.MaxCompressionRatio}
+		},
+		"gz": func(c Config) Expander {
+			return &GzipExpander{FileSizeLimit: c.FileSizeLimit, MaxCompressionRatio: c.MaxCompressionRatio}
+		},
+		"bz2": func(c Config) Expander {
+			return &Bzip2Expander{FileSizeLimit: c.FileSizeLimit, MaxCompressionRatio: c.MaxCompressionRatio}
+		},
+		"xz": func(c Config) Expander {
+			return &XzExpander{FileSizeLimit: c.FileSizeLimit, MaxCompressionRatio: c.MaxCompressionRatio}
+		},
+		"zst": func(c Config) Expander {
+			return &ZstdExpander{FileSizeLimit: c.FileSizeLimit, MaxCompressionRatio: c.MaxCompressionRatio}
+		},
+	}
+)
+
+func tarGzipFactory(c Config) Expander {
+	return &TarGzipExpander{FileSizeLimit: c.FileSizeLimit, FilesLimit: c.FilesLimit, Options: c.Options, MaxCompressionRatio: c.MaxCompressionRatio}
+}
+
+func tarBzip2Factory(c Config) Expander {
+	return &TarBzip2Expander{FileSizeLimit: c.FileSizeLimit, FilesLimit: c.FilesLimit, Options: c.Options, MaxCompressionRatio: c.MaxCompressionRatio}
+}
+
+func tarXzFactory(c Config) Expander {
+	return &TarXzExpander{FileSizeLimit: c.FileSizeLimit, FilesLimit: c.FilesLimit, Options: c.Options, MaxCompressionRatio: c.MaxCompressionRatio}
+}
+
+func tarZstdFactory(c Config) Expander {
+	return &TarZstdExpander{FileSizeLimit: c.FileSizeLimit, FilesLimit: c.FilesLimit, Options: c.Options, MaxCompressionRatio: c.MaxCompressionRatio}
+}
+
+// RegisterExpander registers the factory for the archive format, identified by its file extension
+// without the leading dot, e.g. "tar.gz". Registering a format that is already registered replaces
+// its factory, which allows the built-in expanders to be overridden.
+func RegisterExpander(format string, factory Factory) error {
+	format = strings.TrimPrefix(strings.ToLower(format), ".")
+	if format == "" {
+		return fmt.Errorf("archive format is empty")
+	}
+	if factory == nil {
+		return fmt.Errorf("factory for archive format %s is nil", format)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[format] = factory
+	return nil
+}
+
+// Formats returns the registered archive formats, sorted.
+func Formats() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	formats := make([]string, 0, len(registry))
+	for format := range registry {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// NewExpander creates an expander for the archive format, identified by its file extension without
+// the leading dot, e.g. "tar.gz". The compression ratio is limited to DefaultMaxCompressionRatio
+// unless configured otherwise.
+func NewExpander(format string, opts ...Option) (Expander, error) {
+	format = strings.TrimPrefix(strings.ToLower(format), ".")
+
+	registryMu.RLock()
+	factory, ok := registry[format]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported archive format: %s", format)
+	}
+
+	return factory(newConfig(opts...)), nil
+}
+
+// NewExpanderForPath creates an expander for the archive at path, chosen by the longest registered
+// format that path ends with, so that e.g. "bundle.tar.gz" prefers "tar.gz" over "gz". It reports
+// false if path is not a recognized archive.
+func NewExpanderForPath(path string, opts ...Option) (Expander, bool) {
+	factory, _ := lookupFormat(path)
+	if factory == nil {
+		return nil, false
+	}
+
+	return factory(newConfig(opts...)), true
+}
+
+// lookupFormat returns the factory and the longest registered format matching the extension of path.
+func lookupFormat(path string) (Factory, string) {
+	lower := strings.ToLower(path)
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var (
+		factory Factory
+		match   string
+	)
+	for format, f := range registry {
+		if strings.HasSuffix(lower, "."+format) && len(format) > len(match) {
+			factory, match = f, format
+		}
+	}
+	return factory, match
+}
+
+func newConfig(opts ...Option) Config {
+	c := Config{MaxCompressionRatio: DefaultMaxCompressionRatio}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
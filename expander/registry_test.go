@@ -0,0 +1,118 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestNewExpander tests creating expanders by format with options
+func TestNewExpander(t *testing.T) {
+	e, err := NewExpander(".TGZ", WithFilesLimit(5), WithFileSizeLimit(100), WithExtractOptions(ExtractOptions{Links: SkipLinks}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tgz, ok := e.(*TarGzipExpander)
+	if !ok {
+		t.Fatalf("unexpected expander: %T", e)
+	}
+	if tgz.FilesLimit != 5 || tgz.FileSizeLimit != 100 || tgz.Options.Links != SkipLinks {
+		t.Errorf("unexpected settings: %+v", tgz)
+	}
+	if tgz.MaxCompressionRatio != DefaultMaxCompressionRatio {
+		t.Errorf("unexpected compression ratio: got %g, want %d", tgz.MaxCompressionRatio, DefaultMaxCompressionRatio)
+	}
+
+	e, err = NewExpander("gz", WithMaxCompressionRatio(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gz := e.(*GzipExpander); gz.MaxCompressionRatio != 0 {
+		t.Errorf("unexpected compression ratio: got %g, want 0", gz.MaxCompressionRatio)
+	}
+
+	if _, err := NewExpander("rar"); err == nil || !strings.Contains(err.Error(), "unsupported archive format: rar") {
+		t.Errorf("expected unsupported format error, got: %v", err)
+	}
+}
+
+// TestNewExpanderForPath tests creating expanders by the extension of a path
+func TestNewExpanderForPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected Expander
+	}{
+		{"/tmp/bundle.tar.gz", &TarGzipExpander{}},
+		{"/tmp/bundle.TAR.GZ", &TarGzipExpander{}},
+		{"/tmp/data.json.gz", &GzipExpander{}},
+		{"/tmp/bundle.zip", &ZipExpander{}},
+		{"/tmp/bundle.json", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			e, ok := NewExpanderForPath(tt.path)
+			if ok != (tt.expected != nil) {
+				t.Fatalf("unexpected result for %s: %v", tt.path, ok)
+			}
+			if ok && fmt.Sprintf("%T", e) != fmt.Sprintf("%T", tt.expected) {
+				t.Errorf("unexpected expander for %s: got %T, want %T", tt.path, e, tt.expected)
+			}
+		})
+	}
+}
+
+type testExpander struct {
+	config Config
+}
+
+func (e *testExpander) Expand(dst, src string, dir bool, umask os.FileMode) error {
+	return nil
+}
+
+// TestRegisterExpander tests registering a custom expander
+func TestRegisterExpander(t *testing.T) {
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "test")
+		registryMu.Unlock()
+	})
+
+	if err := RegisterExpander(".test", func(c Config) Expander { return &testExpander{config: c} }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	e, ok := NewExpanderForPath("/tmp/bundle.test", WithFilesLimit(7))
+	if !ok {
+		t.Fatal("expected the registered expander to be found")
+	}
+	if te, ok := e.(*testExpander); !ok || te.config.FilesLimit != 7 {
+		t.Errorf("unexpected expander: %#v", e)
+	}
+	if _, ok := BaseExpanders(0, 0)["test"]; !ok {
+		t.Error("expected BaseExpanders to include the registered expander")
+	}
+
+	if err := RegisterExpander("", func(c Config) Expander { return nil }); err == nil {
+		t.Error("expected an error for an empty format")
+	}
+	if err := RegisterExpander("test", nil); err == nil {
+		t.Error("expected an error for a nil factory")
+	}
+}
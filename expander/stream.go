@@ -0,0 +1,47 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// streamName identifies archives read from a stream in error messages.
+const streamName = "<stream>"
+
+// StreamExpander is implemented by expanders that can expand an archive while it is being read,
+// e.g. while it is being downloaded, without first writing the archive to disk. Archive formats
+// that require random access, such as zip, cannot be expanded from a stream.
+type StreamExpander interface {
+	ExpandStream(ctx context.Context, r io.Reader, dst string, umask os.FileMode) error
+}
+
+// contextReader fails reads with the context error once the context is done, so that expanding a
+// stream that never ends, or ends slowly, can be cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,85 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestStreamExpanders_ExpandStream tests expanding plain and compressed tarballs from a reader
+func TestStreamExpanders_ExpandStream(t *testing.T) {
+	tarball := sampleTar(t)
+	fixtures := map[string][]byte{
+		"tar":     tarball,
+		"tar.gz":  compressedFixtures(t, tarball)["gz"],
+		"tar.bz2": sampleTarBzip2,
+		"tar.xz":  compressedFixtures(t, tarball)["xz"],
+		"tar.zst": compressedFixtures(t, tarball)["zst"],
+	}
+	expanders := BaseExpanders(0, 0)
+
+	for ext, data := range fixtures {
+		t.Run(ext, func(t *testing.T) {
+			e, ok := expanders[ext].(StreamExpander)
+			if !ok {
+				t.Fatalf("expander for %s does not support streaming", ext)
+			}
+			dst := filepath.Join(t.TempDir(), "out")
+			if err := e.ExpandStream(context.Background(), bytes.NewReader(data), dst, 0755); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			files := listFiles(t, dst)
+			expected := []string{"policy/lib/helper.rego", "policy/main.rego"}
+			if strings.Join(files, ",") != strings.Join(expected, ",") {
+				t.Errorf("unexpected files: got %v, want %v", files, expected)
+			}
+		})
+	}
+}
+
+// TestStreamExpanders_Zip tests that zip archives, which need random access, cannot be streamed
+func TestStreamExpanders_Zip(t *testing.T) {
+	if _, ok := BaseExpanders(0, 0)["zip"].(StreamExpander); ok {
+		t.Error("expected the zip expander not to support streaming")
+	}
+}
+
+// TestTarExpander_ExpandStream_Cancelled tests that expanding a stream stops when the context is done
+func TestTarExpander_ExpandStream_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	e := &TarExpander{}
+	err := e.ExpandStream(ctx, bytes.NewReader(sampleTar(t)), t.TempDir(), 0755)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a context cancelled error, got: %v", err)
+	}
+}
+
+// TestTarExpander_ExpandStream_Empty tests that an empty stream is rejected
+func TestTarExpander_ExpandStream_Empty(t *testing.T) {
+	e := &TarExpander{}
+	err := e.ExpandStream(context.Background(), bytes.NewReader(nil), t.TempDir(), 0755)
+	if err == nil || !strings.Contains(err.Error(), "tar file is empty: <stream>") {
+		t.Errorf("expected an empty tar file error, got: %v", err)
+	}
+}
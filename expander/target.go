@@ -0,0 +1,389 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/bodgit/sevenzip"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// Target is a destination that archives can be expanded into instead of the local disk, e.g. a
+// MemFS. Names are slash separated and relative to the root of the target. A Target that also
+// implements fs.ReadFileFS supports hard link members.
+type Target interface {
+	MkdirAll(name string, perm fs.FileMode) error
+	WriteFile(name string, r io.Reader, perm fs.FileMode, modTime time.Time) error
+	Symlink(target, name string) error
+}
+
+// TargetExpander is implemented by expanders that can expand an archive into a Target.
+type TargetExpander interface {
+	ExpandTo(t Target, src string) error
+}
+
+// member is an archive member to be expanded into a Target.
+type member struct {
+	name     string
+	linkname string
+	hardlink bool
+	mode     fs.FileMode
+	size     int64
+	modTime  time.Time
+	open     func() (io.ReadCloser, error)
+}
+
+// expandToTarget expands the members returned by next into t, applying the same checks and limits
+// as expanding onto disk. next returns io.EOF when there are no more members. kind names the archive
+// format in error messages.
+func expandToTarget(t Target, src, kind string, next func() (*member, error), fileSizeLimit int64, filesLimit int, opts ExtractOptions) error {
+	filter, err := opts.newMemberFilter()
+	if err != nil {
+		return err
+	}
+
+	var (
+		fileSize   int64
+		filesCount int
+		links      = map[string]bool{}
+	)
+
+	for {
+		m, err := next()
+		if err == io.EOF {
+			if filesCount == 0 {
+				return fmt.Errorf("%s file is empty: %s", kind, src)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		filesCount++
+		if filesLimit > 0 && filesCount > filesLimit {
+			return fmt.Errorf("%s file contains more files than the %d allowed: %d", kind, filesLimit, filesCount)
+		}
+
+		if !filter.Match(m.name) {
+			continue
+		}
+		if containsDotDot(m.name) {
+			return fmt.Errorf("%s file (%s) would escape destination directory", kind, m.name)
+		}
+		name := gogather.NormalizePath(m.name)
+		if name == "" {
+			continue
+		}
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if links[dir] {
+				return fmt.Errorf("archive member (%s) would escape destination directory through a symlink", m.name)
+			}
+		}
+
+		isLink := m.hardlink || m.mode&fs.ModeSymlink != 0
+		if isLink && opts.Links == SkipLinks {
+			continue
+		}
+
+		if err := opts.visit(Entry{Name: m.name, Size: m.size, Mode: m.mode, Linkname: m.linkname}); err != nil {
+			return err
+		}
+
+		if isLink {
+			if opts.Links == RejectLinks {
+				return fmt.Errorf("%s file contains a link (%s), which is not allowed", kind, m.name)
+			}
+			if err := targetLink(t, name, m); err != nil {
+				return err
+			}
+			links[name] = !m.hardlink
+			continue
+		}
+
+		fileSize += m.size
+		if fileSizeLimit > 0 && fileSize > fileSizeLimit {
+			return sizeLimitError(fileSizeLimit, "%s file size exceeds the %d limit: %d", kind, fileSizeLimit, fileSize)
+		}
+
+		if m.mode.IsDir() {
+			if err := t.MkdirAll(name, m.mode.Perm()|0700); err != nil {
+				return fmt.Errorf("failed to create directory (%s): %w", name, err)
+			}
+			continue
+		}
+		if !m.mode.IsRegular() {
+			continue
+		}
+
+		if err := targetFile(t, name, m, fileSizeLimit); err != nil {
+			return err
+		}
+	}
+}
+
+// targetFile writes the regular file member m to name in t.
+func targetFile(t Target, name string, m *member, fileSizeLimit int64) error {
+	rc, err := m.open()
+	if err != nil {
+		return fmt.Errorf("failed to open archive member (%s): %w", m.name, err)
+	}
+	defer rc.Close()
+
+	var r io.Reader = rc
+	if fileSizeLimit > 0 {
+		r = &sizeLimitReader{r: rc, name: name, limit: fileSizeLimit}
+	}
+	if err := t.WriteFile(name, r, m.mode.Perm(), m.modTime); err != nil {
+		return fmt.Errorf("failed to write file (%s): %w", name, err)
+	}
+	return nil
+}
+
+// targetLink creates the link member m at name in t, after validating that its target stays inside
+// the target, using the same rules as for links expanded onto disk.
+func targetLink(t Target, name string, m *member) error {
+	target := m.linkname
+	if target == "" || path.IsAbs(target) || strings.HasPrefix(target, "\\") {
+		return fmt.Errorf("link (%s) has a target outside of the destination directory: %s", m.name, target)
+	}
+
+	if m.hardlink {
+		if containsDotDot(target) {
+			return fmt.Errorf("link (%s) has a target outside of the destination directory: %s", m.name, target)
+		}
+		rfs, ok := t.(fs.ReadFileFS)
+		if !ok {
+			return fmt.Errorf("hard link (%s) is not supported by the target", m.name)
+		}
+		data, err := rfs.ReadFile(gogather.NormalizePath(target))
+		if err != nil {
+			return fmt.Errorf("failed to read hard link target (%s): %w", target, err)
+		}
+		return t.WriteFile(name, bytes.NewReader(data), m.mode.Perm(), m.modTime)
+	}
+
+	elems := strings.FieldsFunc(target, isSlash)
+	leading := 0
+	for leading < len(elems) && elems[leading] == ".." {
+		leading++
+	}
+	for _, e := range elems[leading:] {
+		if e == ".." {
+			return fmt.Errorf("symlink (%s) has an unsupported target: %s", m.name, target)
+		}
+	}
+	if resolved := path.Join(path.Dir(name), strings.Join(elems, "/")); !fs.ValidPath(resolved) {
+		return fmt.Errorf("symlink (%s) has a target outside of the destination directory: %s", m.name, target)
+	}
+
+	if err := t.Symlink(target, name); err != nil {
+		return fmt.Errorf("failed to create symlink (%s): %w", name, err)
+	}
+	return nil
+}
+
+// sizeLimitReader fails once more than limit bytes have been read.
+type sizeLimitReader struct {
+	r     io.Reader
+	name  string
+	limit int64
+	n     int64
+}
+
+func (s *sizeLimitReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.n += int64(n)
+	if s.n > s.limit {
+		return n, sizeLimitError(s.limit, "file %s exceeds the %d size limit", s.name, s.limit)
+	}
+	return n, err
+}
+
+// expandTarTo expands the tarball src into t, wrapping it with the reader returned by newReader, if any.
+func expandTarTo(t Target, src string, fileSizeLimit int64, filesLimit int, opts ExtractOptions, newReader func(io.Reader) (io.Reader, error)) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if newReader != nil {
+		if r, err = newReader(f); err != nil {
+			return fmt.Errorf("failed to read compressed tar file (%s): %w", src, err)
+		}
+		if c, ok := r.(io.Closer); ok {
+			defer c.Close()
+		}
+	}
+
+	tarReader := tar.NewReader(r)
+	return expandToTarget(t, src, "tar", func() (*member, error) {
+		for {
+			header, err := tarReader.Next()
+			if err != nil {
+				return nil, err
+			}
+			if header.Typeflag == tar.TypeXGlobalHeader || header.Typeflag == tar.TypeXHeader {
+				continue
+			}
+			return &member{
+				name:     header.Name,
+				linkname: header.Linkname,
+				hardlink: header.Typeflag == tar.TypeLink,
+				mode:     header.FileInfo().Mode(),
+				size:     header.Size,
+				modTime:  header.ModTime,
+				open:     func() (io.ReadCloser, error) { return io.NopCloser(tarReader), nil },
+			}, nil
+		}
+	}, fileSizeLimit, filesLimit, opts)
+}
+
+// ExpandTo expands the tarball src into t.
+func (t *TarExpander) ExpandTo(target Target, src string) error {
+	return expandTarTo(target, src, t.FileSizeLimit, t.FilesLimit, t.Options, nil)
+}
+
+// ExpandTo expands the compressed tarball src into t.
+func (t *TarGzipExpander) ExpandTo(target Target, src string) error {
+	return expandTarTo(target, src, t.FileSizeLimit, t.FilesLimit, t.Options, t.newReader())
+}
+
+// ExpandTo expands the compressed tarball src into t.
+func (t *TarBzip2Expander) ExpandTo(target Target, src string) error {
+	return expandTarTo(target, src, t.FileSizeLimit, t.FilesLimit, t.Options, t.newReader())
+}
+
+// ExpandTo expands the compressed tarball src into t.
+func (t *TarXzExpander) ExpandTo(target Target, src string) error {
+	return expandTarTo(target, src, t.FileSizeLimit, t.FilesLimit, t.Options, t.newReader())
+}
+
+// ExpandTo expands the compressed tarball src into t.
+func (t *TarZstdExpander) ExpandTo(target Target, src string) error {
+	return expandTarTo(target, src, t.FileSizeLimit, t.FilesLimit, t.Options, t.newReader())
+}
+
+// ExpandTo expands the zip archive src into t.
+func (z *ZipExpander) ExpandTo(t Target, src string) error {
+	zipReader, err := zip.OpenReader(src)
+	if err != nil {
+		return fmt.Errorf("failed to open zip file (%s): %w", src, err)
+	}
+	defer zipReader.Close()
+
+	files := zipReader.File
+	return expandToTarget(t, src, "zip", func() (*member, error) {
+		if len(files) == 0 {
+			return nil, io.EOF
+		}
+		f := files[0]
+		files = files[1:]
+		info := f.FileInfo()
+		return &member{
+			name:    f.Name,
+			mode:    info.Mode(),
+			size:    info.Size(),
+			modTime: f.Modified,
+			open: func() (io.ReadCloser, error) {
+				rc, err := openZipMember(f, z.Password)
+				if err != nil || z.MaxCompressionRatio <= 0 {
+					return rc, err
+				}
+				return struct {
+					io.Reader
+					io.Closer
+				}{&ratioReader{r: rc, compressed: func() int64 { return int64(f.CompressedSize64) }, limit: z.MaxCompressionRatio}, rc}, nil
+			},
+		}, nil
+	}, z.FileSizeLimit, z.FilesLimit, z.Options)
+}
+
+// ExpandTo expands the 7z archive src into t.
+func (s *SevenZipExpander) ExpandTo(t Target, src string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	r, err := sevenzip.OpenReader(src)
+	if err != nil {
+		return fmt.Errorf("failed to open 7z file (%s): %w", src, err)
+	}
+	defer r.Close()
+
+	var extracted int64
+	files := r.File
+	return expandToTarget(t, src, "7z", func() (*member, error) {
+		if len(files) == 0 {
+			return nil, io.EOF
+		}
+		f := files[0]
+		files = files[1:]
+		fileInfo := f.FileInfo()
+		m := &member{
+			name:    f.Name,
+			mode:    fileInfo.Mode(),
+			size:    fileInfo.Size(),
+			modTime: f.Modified,
+			open: func() (io.ReadCloser, error) {
+				rc, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				counter := &countingReader{r: rc}
+				var r io.Reader = counter
+				if s.MaxCompressionRatio > 0 {
+					r = &ratioReader{r: counter, compressed: func() int64 { return info.Size() }, decompressed: extracted, limit: s.MaxCompressionRatio}
+				}
+				return struct {
+					io.Reader
+					io.Closer
+				}{r, closerFunc(func() error {
+					extracted += counter.n
+					return rc.Close()
+				})}, nil
+			},
+		}
+		if fileInfo.Mode()&fs.ModeSymlink != 0 {
+			target, err := read7zLinkTarget(f)
+			if err != nil {
+				return nil, err
+			}
+			m.linkname = target
+		}
+		return m, nil
+	}, s.FileSizeLimit, s.FilesLimit, s.Options)
+}
+
+// closerFunc adapts a function to io.Closer.
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
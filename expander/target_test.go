@@ -0,0 +1,164 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// TestMemFS tests that MemFS implements the fs.FS interfaces correctly
+func TestMemFS(t *testing.T) {
+	m := NewMemFS()
+	if err := m.MkdirAll("policy/empty", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFile("policy/main.rego", strings.NewReader("package main"), 0644, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFile("data/data.json", strings.NewReader("{}"), 0644, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Symlink("../policy/main.rego", "data/link.rego"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fstest.TestFS(m, "policy/main.rego", "policy/empty", "data/data.json", "data/link.rego"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := fs.ReadFile(m, "data/link.rego")
+	if err != nil || string(content) != "package main" {
+		t.Errorf("unexpected content of the symlink: %q, %v", content, err)
+	}
+
+	// Writing through a symlink is not possible
+	if err := m.WriteFile("data/link.rego/file", strings.NewReader(""), 0644, time.Now()); err == nil {
+		t.Error("expected writing below a symlink to fail")
+	}
+	if err := m.MkdirAll("policy/main.rego", 0755); err == nil {
+		t.Error("expected creating a directory over a file to fail")
+	}
+}
+
+// TestTargetExpanders_ExpandTo tests expanding archives into an in-memory filesystem
+func TestTargetExpanders_ExpandTo(t *testing.T) {
+	tarball := sampleTar(t)
+	fixtures := map[string][]byte{
+		"tar":     tarball,
+		"tar.gz":  compressedFixtures(t, tarball)["gz"],
+		"tar.bz2": sampleTarBzip2,
+		"tar.xz":  compressedFixtures(t, tarball)["xz"],
+		"tar.zst": compressedFixtures(t, tarball)["zst"],
+		"7z":      sample7z,
+	}
+	expanders := BaseExpanders(0, 0)
+
+	for ext, data := range fixtures {
+		t.Run(ext, func(t *testing.T) {
+			src := filepath.Join(t.TempDir(), "bundle."+ext)
+			if err := os.WriteFile(src, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			e, ok := expanders[ext].(TargetExpander)
+			if !ok {
+				t.Fatalf("expander for %s does not support targets", ext)
+			}
+
+			m := NewMemFS()
+			if err := e.ExpandTo(m, src); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			content, err := m.ReadFile("policy/lib/helper.rego")
+			if err != nil || string(content) != "package lib" {
+				t.Errorf("unexpected content: %q, %v", content, err)
+			}
+		})
+	}
+
+	t.Run("zip", func(t *testing.T) {
+		src := filepath.Join(t.TempDir(), "bundle.zip")
+		writeZip(t, src, map[string]string{"policy/main.rego": "package main"})
+		m := NewMemFS()
+		if err := (&ZipExpander{}).ExpandTo(m, src); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if content, err := m.ReadFile("policy/main.rego"); err != nil || string(content) != "package main" {
+			t.Errorf("unexpected content: %q, %v", content, err)
+		}
+	})
+}
+
+// TestTarExpander_ExpandTo_Errors tests that the safety checks apply when expanding into a target
+func TestTarExpander_ExpandTo_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []tarEntry
+		expander *TarExpander
+		expected string
+	}{
+		{"escape", []tarEntry{{name: "../evil.txt", content: "evil"}}, &TarExpander{}, "would escape destination directory"},
+		{"symlink escape", []tarEntry{{name: "link", typeflag: '2', linkname: "../../etc"}}, &TarExpander{}, "target outside of the destination directory"},
+		{"write through symlink", []tarEntry{
+			{name: "link", typeflag: '2', linkname: "dir"},
+			{name: "link/file.txt", content: "evil"},
+		}, &TarExpander{}, "through a symlink"},
+		{"size limit", []tarEntry{{name: "a.txt", content: "0123456789"}}, &TarExpander{FileSizeLimit: 5}, "size exceeds the 5 limit"},
+		{"files limit", []tarEntry{{name: "a.txt"}, {name: "b.txt"}}, &TarExpander{FilesLimit: 1}, "more files than the 1 allowed: 2"},
+		{"empty", nil, &TarExpander{}, "tar file is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := filepath.Join(t.TempDir(), "bundle.tar")
+			if err := os.WriteFile(src, makeTar(t, tt.entries...), 0644); err != nil {
+				t.Fatal(err)
+			}
+			err := tt.expander.ExpandTo(NewMemFS(), src)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q, got: %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+// TestTarExpander_ExpandTo_Links tests that links are recreated in the target
+func TestTarExpander_ExpandTo_Links(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "bundle.tar")
+	if err := os.WriteFile(src, makeTar(t,
+		tarEntry{name: "policy/main.rego", content: "package main"},
+		tarEntry{name: "policy/link.rego", typeflag: '2', linkname: "main.rego"},
+		tarEntry{name: "copy.rego", typeflag: '1', linkname: "policy/main.rego"},
+	), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMemFS()
+	if err := (&TarExpander{}).ExpandTo(m, src); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if target, err := m.Readlink("policy/link.rego"); err != nil || target != "main.rego" {
+		t.Errorf("unexpected symlink target: %q, %v", target, err)
+	}
+	if content, err := m.ReadFile("copy.rego"); err != nil || string(content) != "package main" {
+		t.Errorf("unexpected hard link content: %q, %v", content, err)
+	}
+}
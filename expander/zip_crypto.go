@@ -0,0 +1,304 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1" // nolint:gosec
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	// zipFlagEncrypted is the general purpose flag bit marking an encrypted zip member.
+	zipFlagEncrypted = 0x1
+	// zipFlagDataDescriptor is the general purpose flag bit marking a member whose CRC-32 and sizes
+	// follow its data.
+	zipFlagDataDescriptor = 0x8
+	// zipMethodAES is the compression method of members encrypted with WinZip AES encryption.
+	zipMethodAES = 99
+	// zipExtraAES is the ID of the extra field describing WinZip AES encryption.
+	zipExtraAES = 0x9901
+	// zipAESAuthCodeSize is the size of the authentication code following AES encrypted data.
+	zipAESAuthCodeSize = 10
+	// zipCryptoHeaderSize is the size of the encryption header preceding ZipCrypto encrypted data.
+	zipCryptoHeaderSize = 12
+)
+
+// ZipPasswordError is returned when an encrypted zip member cannot be decrypted, either because no
+// password was supplied or because the password is wrong.
+type ZipPasswordError struct {
+	Name    string
+	Missing bool
+}
+
+func (e *ZipPasswordError) Error() string {
+	if e.Missing {
+		return fmt.Sprintf("zip member (%s) is encrypted and no password was supplied", e.Name)
+	}
+	return fmt.Sprintf("incorrect password for zip member (%s)", e.Name)
+}
+
+// openZipMember opens the zip member f, decrypting it with password if it is encrypted using either
+// ZipCrypto or WinZip AES encryption.
+func openZipMember(f *zip.File, password string) (io.ReadCloser, error) {
+	if f.Flags&zipFlagEncrypted == 0 {
+		return f.Open()
+	}
+	if password == "" {
+		return nil, &ZipPasswordError{Name: f.Name, Missing: true}
+	}
+
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	if f.Method == zipMethodAES {
+		return openZipAES(f, raw, password)
+	}
+	return openZipCrypto(f, raw, password)
+}
+
+// decompressZipMember returns a reader decompressing r using the zip compression method.
+func decompressZipMember(name string, method uint16, r io.Reader) (io.ReadCloser, error) {
+	switch method {
+	case zip.Store:
+		return io.NopCloser(r), nil
+	case zip.Deflate:
+		return flate.NewReader(r), nil
+	}
+	return nil, fmt.Errorf("zip member (%s) uses an unsupported compression method: %d", name, method)
+}
+
+// openZipCrypto opens the member f encrypted with the traditional PKWARE encryption, also known as ZipCrypto.
+func openZipCrypto(f *zip.File, raw io.Reader, password string) (io.ReadCloser, error) {
+	header := make([]byte, zipCryptoHeaderSize)
+	if _, err := io.ReadFull(raw, header); err != nil {
+		return nil, fmt.Errorf("failed to read encryption header of zip member (%s): %w", f.Name, err)
+	}
+
+	keys := newZipCryptoKeys(password)
+	keys.decrypt(header)
+
+	// The last byte of the header verifies the password against the CRC-32, or against the
+	// modification time when the CRC-32 is only known after the data.
+	check := byte(f.CRC32 >> 24)
+	if f.Flags&zipFlagDataDescriptor != 0 {
+		check = byte(f.ModifiedTime >> 8) // nolint:staticcheck
+	}
+	if header[zipCryptoHeaderSize-1] != check {
+		return nil, &ZipPasswordError{Name: f.Name}
+	}
+
+	r, err := decompressZipMember(f.Name, f.Method, &zipCryptoReader{r: raw, keys: keys})
+	if err != nil {
+		return nil, err
+	}
+	return &crcReader{ReadCloser: r, name: f.Name, want: f.CRC32, hash: crc32.NewIEEE()}, nil
+}
+
+// zipCryptoKeys holds the state of the ZipCrypto cipher.
+type zipCryptoKeys [3]uint32
+
+func newZipCryptoKeys(password string) *zipCryptoKeys {
+	k := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for i := 0; i < len(password); i++ {
+		k.update(password[i])
+	}
+	return k
+}
+
+func (k *zipCryptoKeys) update(b byte) {
+	k[0] = crc32.IEEETable[byte(k[0])^b] ^ (k[0] >> 8)
+	k[1] = (k[1]+(k[0]&0xff))*134775813 + 1
+	k[2] = crc32.IEEETable[byte(k[2])^byte(k[1]>>24)] ^ (k[2] >> 8)
+}
+
+func (k *zipCryptoKeys) decrypt(p []byte) {
+	for i := range p {
+		t := k[2] | 2
+		p[i] ^= byte((t * (t ^ 1)) >> 8)
+		k.update(p[i])
+	}
+}
+
+// zipCryptoReader decrypts ZipCrypto encrypted data.
+type zipCryptoReader struct {
+	r    io.Reader
+	keys *zipCryptoKeys
+}
+
+func (z *zipCryptoReader) Read(p []byte) (int, error) {
+	n, err := z.r.Read(p)
+	z.keys.decrypt(p[:n])
+	return n, err
+}
+
+// crcReader verifies the CRC-32 of the data read once it is fully read.
+type crcReader struct {
+	io.ReadCloser
+	name string
+	want uint32
+	hash hash.Hash32
+}
+
+func (c *crcReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.hash.Write(p[:n])
+	if err == io.EOF && c.hash.Sum32() != c.want {
+		return n, fmt.Errorf("zip member (%s) failed the checksum verification: %w", c.name, zip.ErrChecksum)
+	}
+	return n, err
+}
+
+// openZipAES opens the member f encrypted with WinZip AES encryption.
+func openZipAES(f *zip.File, raw io.Reader, password string) (io.ReadCloser, error) {
+	strength, method, err := zipAESExtra(f)
+	if err != nil {
+		return nil, err
+	}
+	keyLen := 8 * (int(strength) + 1)
+	saltLen := keyLen / 2
+
+	overhead := uint64(saltLen + 2 + zipAESAuthCodeSize)
+	if f.CompressedSize64 < overhead {
+		return nil, fmt.Errorf("zip member (%s) is too short to be AES encrypted", f.Name)
+	}
+
+	header := make([]byte, saltLen+2)
+	if _, err := io.ReadFull(raw, header); err != nil {
+		return nil, fmt.Errorf("failed to read encryption header of zip member (%s): %w", f.Name, err)
+	}
+	salt, verifier := header[:saltLen], header[saltLen:]
+
+	key := pbkdf2SHA1([]byte(password), salt, 1000, 2*keyLen+2)
+	if !bytes.Equal(key[2*keyLen:], verifier) {
+		return nil, &ZipPasswordError{Name: f.Name}
+	}
+
+	block, err := aes.NewCipher(key[:keyLen])
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha1.New, key[keyLen:2*keyLen])
+	data := io.LimitReader(raw, int64(f.CompressedSize64-overhead)) // nolint:gosec
+	decrypted := &zipAESReader{r: io.TeeReader(data, mac), block: block, raw: raw, mac: mac, name: f.Name}
+
+	return decompressZipMember(f.Name, method, decrypted)
+}
+
+// zipAESExtra returns the key strength and the actual compression method from the AES extra field of f.
+func zipAESExtra(f *zip.File) (byte, uint16, error) {
+	extra := f.Extra
+	for len(extra) >= 4 {
+		id, size := binary.LittleEndian.Uint16(extra), int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+		if size > len(extra) {
+			break
+		}
+		if id == zipExtraAES && size >= 7 {
+			strength := extra[4]
+			if strength < 1 || strength > 3 {
+				return 0, 0, fmt.Errorf("zip member (%s) uses an unsupported AES key strength: %d", f.Name, strength)
+			}
+			return strength, binary.LittleEndian.Uint16(extra[5:]), nil
+		}
+		extra = extra[size:]
+	}
+	return 0, 0, fmt.Errorf("zip member (%s) is missing the AES encryption extra field", f.Name)
+}
+
+// zipAESReader decrypts WinZip AES encrypted data, which uses AES in counter mode with a little
+// endian counter starting at 1, and verifies the authentication code that follows the data.
+type zipAESReader struct {
+	r       io.Reader
+	block   cipher.Block
+	counter [aes.BlockSize]byte
+	stream  [aes.BlockSize]byte
+	used    int
+	raw     io.Reader
+	mac     hash.Hash
+	name    string
+}
+
+func (z *zipAESReader) Read(p []byte) (int, error) {
+	n, err := z.r.Read(p)
+	for i := 0; i < n; i++ {
+		if z.used == 0 || z.used == aes.BlockSize {
+			for j := range z.counter {
+				z.counter[j]++
+				if z.counter[j] != 0 {
+					break
+				}
+			}
+			z.block.Encrypt(z.stream[:], z.counter[:])
+			z.used = 0
+		}
+		p[i] ^= z.stream[z.used]
+		z.used++
+	}
+	if err == io.EOF {
+		code := make([]byte, zipAESAuthCodeSize)
+		if _, rerr := io.ReadFull(z.raw, code); rerr != nil {
+			return n, fmt.Errorf("failed to read authentication code of zip member (%s): %w", z.name, rerr)
+		}
+		if !hmac.Equal(code, z.mac.Sum(nil)[:zipAESAuthCodeSize]) {
+			return n, fmt.Errorf("zip member (%s) failed the authentication: %w", z.name, errZipAuthentication)
+		}
+	}
+	return n, err
+}
+
+// errZipAuthentication is wrapped by the error returned when AES encrypted data fails authentication.
+var errZipAuthentication = errors.New("authentication code mismatch")
+
+// pbkdf2SHA1 derives a key of keyLen bytes from password and salt using PBKDF2 with HMAC-SHA1.
+func pbkdf2SHA1(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha1.New, password)
+	var (
+		key   []byte
+		block [4]byte
+	)
+	for i := uint32(1); len(key) < keyLen; i++ {
+		binary.BigEndian.PutUint32(block[:], i)
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(block[:])
+		u := prf.Sum(nil)
+		t := bytes.Clone(u)
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
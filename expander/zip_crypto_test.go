@@ -0,0 +1,178 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package expander
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1" // nolint:gosec
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// deflate compresses data using the zip Deflate method.
+func deflate(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// zipCryptoEncrypt encrypts the deflated content with ZipCrypto, prefixed by the encryption header.
+func zipCryptoEncrypt(t *testing.T, content []byte, password string) []byte {
+	plain := append([]byte("random head"), byte(crc32.ChecksumIEEE(content)>>24))
+	plain = append(plain, deflate(t, content)...)
+
+	keys := newZipCryptoKeys(password)
+	encrypted := make([]byte, len(plain))
+	for i, b := range plain {
+		k := keys[2] | 2
+		encrypted[i] = b ^ byte((k*(k^1))>>8)
+		keys.update(b)
+	}
+	return encrypted
+}
+
+// zipAESEncrypt encrypts the deflated content with WinZip AES-256 encryption.
+func zipAESEncrypt(t *testing.T, content []byte, password string) []byte {
+	salt := []byte("0123456789abcdef")
+	key := pbkdf2SHA1([]byte(password), salt, 1000, 2*32+2)
+	block, err := aes.NewCipher(key[:32])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := deflate(t, content)
+	r := &zipAESReader{r: bytes.NewReader(data), block: block}
+	encrypted := make([]byte, len(data))
+	if _, err := r.Read(encrypted); err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha1.New, key[32:64])
+	mac.Write(encrypted)
+
+	out := append(append(salt, key[64:]...), encrypted...)
+	return append(out, mac.Sum(nil)[:zipAESAuthCodeSize]...)
+}
+
+// writeEncryptedZip writes a zip archive to path with the single member name, encrypted with
+// WinZip AES if useAES is set, or ZipCrypto otherwise.
+func writeEncryptedZip(t *testing.T, path, name string, content []byte, password string, useAES bool) {
+	t.Helper()
+	header := &zip.FileHeader{
+		Name:               name,
+		Method:             zip.Deflate,
+		Flags:              zipFlagEncrypted,
+		CRC32:              crc32.ChecksumIEEE(content),
+		UncompressedSize64: uint64(len(content)),
+	}
+	data := zipCryptoEncrypt(t, content, password)
+	if useAES {
+		data = zipAESEncrypt(t, content, password)
+		header.Method = zipMethodAES
+		header.CRC32 = 0
+		// AE-2, vendor "AE", AES-256, deflated
+		header.Extra = []byte{0x01, 0x99, 0x07, 0x00, 0x02, 0x00, 'A', 'E', 0x03, 0x08, 0x00}
+	}
+	header.CompressedSize64 = uint64(len(data))
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	fw, err := w.CreateRaw(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestZipExpander_Expand_Encrypted tests expanding zip archives encrypted with ZipCrypto and WinZip AES
+func TestZipExpander_Expand_Encrypted(t *testing.T) {
+	for name, useAES := range map[string]bool{"ZipCrypto": false, "AES": true} {
+		t.Run(name, func(t *testing.T) {
+			tmp := t.TempDir()
+			src := filepath.Join(tmp, "bundle.zip")
+			writeEncryptedZip(t, src, "policy/main.rego", []byte("package main"), "s3cret", useAES)
+
+			// Correct password
+			dst := filepath.Join(tmp, "out")
+			z := &ZipExpander{Password: "s3cret"}
+			if err := z.Expand(dst, src, true, 0755); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			content, err := os.ReadFile(filepath.Join(dst, "policy", "main.rego"))
+			if err != nil || string(content) != "package main" {
+				t.Errorf("unexpected content: %q, %v", content, err)
+			}
+
+			// Missing password
+			var passwordErr *ZipPasswordError
+			err = (&ZipExpander{}).Expand(filepath.Join(tmp, "missing"), src, true, 0755)
+			if !errors.As(err, &passwordErr) || !passwordErr.Missing {
+				t.Errorf("expected a missing password error, got: %v", err)
+			}
+
+			// Wrong password
+			err = (&ZipExpander{Password: "wrong"}).Expand(filepath.Join(tmp, "wrong"), src, true, 0755)
+			if !errors.As(err, &passwordErr) || passwordErr.Missing || passwordErr.Name != "policy/main.rego" {
+				t.Errorf("expected an incorrect password error, got: %v", err)
+			}
+		})
+	}
+}
+
+// TestPbkdf2SHA1 tests the key derivation against the RFC 6070 test vectors
+func TestPbkdf2SHA1(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		iterations     int
+		expected       []byte
+	}{
+		{"password", "salt", 1, []byte{0x0c, 0x60, 0xc8, 0x0f, 0x96, 0x1f, 0x0e, 0x71, 0xf3, 0xa9, 0xb5, 0x24, 0xaf, 0x60, 0x12, 0x06, 0x2f, 0xe0, 0x37, 0xa6}},
+		{"password", "salt", 4096, []byte{0x4b, 0x00, 0x79, 0x01, 0xb7, 0x65, 0x48, 0x9a, 0xbe, 0xad, 0x49, 0xd9, 0x26, 0xf7, 0x21, 0xd0, 0x65, 0xa4, 0x29, 0xc1}},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, []byte{
+			0x3d, 0x2e, 0xec, 0x4f, 0xe4, 0x1c, 0x84, 0x9b, 0x80, 0xc8, 0xd8, 0x36, 0x62, 0xc0, 0xe4, 0x4a,
+			0x8b, 0x29, 0x1a, 0x96, 0x4c, 0xf2, 0xf0, 0x70, 0x38,
+		}},
+	}
+	for _, tt := range tests {
+		if got := pbkdf2SHA1([]byte(tt.password), []byte(tt.salt), tt.iterations, len(tt.expected)); !bytes.Equal(got, tt.expected) {
+			t.Errorf("unexpected key for %s/%s/%d: %x", tt.password, tt.salt, tt.iterations, got)
+		}
+	}
+}
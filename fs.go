@@ -0,0 +1,97 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WriteFS is a filesystem gathered content can be written to instead of the local disk, e.g. to
+// gather into memory in tests or serverless environments. Names are slash separated paths
+// relative to the root of the filesystem, see fs.ValidPath. expander.MemFS implements it.
+type WriteFS interface {
+	// MkdirAll creates the directory name, along with any necessary parents.
+	MkdirAll(name string, perm fs.FileMode) error
+	// WriteFile creates or replaces the file name with the content read from r, creating any
+	// missing parent directories.
+	WriteFile(name string, r io.Reader, perm fs.FileMode, modTime time.Time) error
+	// Symlink creates name as a symbolic link to target, creating any missing parent
+	// directories.
+	Symlink(target, name string) error
+}
+
+// OSFS is a WriteFS writing to the directory Dir of the local disk.
+type OSFS struct {
+	Dir string
+}
+
+// path returns the local path of name, which must not escape Dir.
+func (o OSFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(o.Dir, filepath.FromSlash(name)), nil
+}
+
+// MkdirAll implements the WriteFS interface.
+func (o OSFS) MkdirAll(name string, perm fs.FileMode) error {
+	p, err := o.path("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(p, perm)
+}
+
+// WriteFile implements the WriteFS interface.
+func (o OSFS) WriteFile(name string, r io.Reader, perm fs.FileMode, modTime time.Time) error {
+	p, err := o.path("write", name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", p, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(p, modTime, modTime)
+}
+
+// Symlink implements the WriteFS interface.
+func (o OSFS) Symlink(target, name string) error {
+	p, err := o.path("symlink", name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return os.Symlink(target, p)
+}
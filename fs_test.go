@@ -0,0 +1,65 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestOSFS tests writing files, directories and links to the local disk
+func TestOSFS(t *testing.T) {
+	dir := t.TempDir()
+	o := OSFS{Dir: dir}
+	modTime := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := o.MkdirAll("a/b", 0755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := o.WriteFile("a/c/file.txt", strings.NewReader("test content"), 0600, modTime); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := o.Symlink("c/file.txt", "a/link"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if info, err := os.Stat(filepath.Join(dir, "a", "b")); err != nil || !info.IsDir() {
+		t.Errorf("expected a directory, got %v, %v", info, err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "a", "c", "file.txt"))
+	if err != nil || info.Mode().Perm() != 0600 || !info.ModTime().Equal(modTime) {
+		t.Errorf("unexpected file: %v, %v", info, err)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "a", "link")); err != nil || string(content) != "test content" {
+		t.Errorf("unexpected link content: %q, %v", content, err)
+	}
+}
+
+// TestOSFS_InvalidPath tests that names cannot escape the directory
+func TestOSFS_InvalidPath(t *testing.T) {
+	o := OSFS{Dir: t.TempDir()}
+	for _, name := range []string{"../file.txt", "/file.txt", "a/../../file.txt"} {
+		if err := o.WriteFile(name, strings.NewReader(""), 0600, time.Now()); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("expected an invalid path error for %s, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/gather/file"
+	"github.com/enterprise-contract/go-gather/metadata"
+	fileMetadata "github.com/enterprise-contract/go-gather/metadata/file"
+	gitMetadata "github.com/enterprise-contract/go-gather/metadata/git"
+	httpMetadata "github.com/enterprise-contract/go-gather/metadata/http"
+	ociMetadata "github.com/enterprise-contract/go-gather/metadata/oci"
+)
+
+// commitPattern matches a full git commit hash.
+var commitPattern = regexp.MustCompile(`^(?:[0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// Cache is a content cache keyed by pinned sources, e.g. a git source with a commit as its ref, an
+// OCI reference with a digest, or a source with a checksum. Gathered content is stored under the
+// pinned URL of its metadata, so that later gathers of the pinned URL are served from the cache.
+type Cache struct {
+	// Dir is the directory holding the cached content.
+	Dir string
+	// MaxSize bounds the size of the cached content in bytes. When it is exceeded, the least
+	// recently used entries are evicted. Zero means no limit.
+	MaxSize int64
+}
+
+// cacheEntry describes the content of a cache entry, persisted to its entry file.
+type cacheEntry struct {
+	// Key is the pinned source the entry was stored for.
+	Key string `json:"key"`
+	// Path is the location of the gathered content relative to the destination of the gather,
+	// e.g. the name of a file the HTTP gatherer downloaded into a destination directory.
+	Path string `json:"path"`
+	// Metadata is the metadata returned by the gather.
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+const (
+	cacheEntryFile   = "entry.json"
+	cacheContentName = "content"
+)
+
+// CachingGatherer is a Gatherer that serves pinned sources from a Cache and stores the content it
+// gathers with the wrapped Gatherer in it. Lookups are skipped when the gather options set
+// NoCache, and the cache is not used at all when they filter the gathered content.
+type CachingGatherer struct {
+	Gatherer Gatherer
+	Cache    *Cache
+}
+
+// NewCachingGatherer returns a CachingGatherer wrapping g with the cache c.
+func NewCachingGatherer(g Gatherer, c *Cache) *CachingGatherer {
+	return &CachingGatherer{Gatherer: g, Cache: c}
+}
+
+// Gather populates the destination from the cache if the source is pinned and cached, and
+// gathers it with the wrapped Gatherer otherwise. Failing to store the gathered content in the
+// cache does not fail the gather.
+func (c *CachingGatherer) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
+	o := gogather.OptionsFromContext(ctx)
+	if len(o.Include) > 0 || len(o.Exclude) > 0 {
+		return c.Gatherer.Gather(ctx, source, destination)
+	}
+
+	if key, ok := pinnedKey(source); ok && !o.NoCache {
+		m, err := c.Cache.restore(ctx, key, source, destination)
+		if err != nil {
+			return nil, err
+		}
+		if o.Metrics != nil {
+			o.Metrics.ObserveCacheLookup(m != nil)
+		}
+		if m != nil {
+			o.Log().Debug("served source from cache", "source", gogather.RedactURL(source), "destination", destination)
+			return m, nil
+		}
+	}
+
+	m, err := c.Gatherer.Gather(ctx, source, destination)
+	if err != nil {
+		return m, err
+	}
+	if err := c.Cache.store(source, destination, m); err != nil {
+		o.Log().Warn("failed to cache gathered content", "source", gogather.RedactURL(source), "error", err)
+	}
+	return m, nil
+}
+
+// pinnedKey returns the cache key of source if it is pinned to an immutable identity.
+func pinnedKey(source string) (string, bool) {
+	src, err := gogather.ParseSource(source)
+	if err != nil {
+		return "", false
+	}
+	key := src.String()
+	if src.Checksum != "" || strings.Contains(src.URL, "@sha256:") || strings.Contains(src.URL, "@sha512:") {
+		return key, true
+	}
+	if commitPattern.MatchString(src.Ref) {
+		return key, true
+	}
+	return "", false
+}
+
+// entryDir returns the directory of the cache entry for key.
+func (c *Cache) entryDir(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:]))
+}
+
+// restore copies the content cached for key to destination and returns its metadata, updated for
+// this gather, or nil if nothing is cached for key.
+func (c *Cache) restore(ctx context.Context, key, source, destination string) (metadata.Metadata, error) {
+	startedAt := time.Now()
+	dir := c.entryDir(key)
+	data, err := os.ReadFile(filepath.Join(dir, cacheEntryFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode cache entry: %w", err)
+	}
+	m, err := metadata.Unmarshal(entry.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode cached metadata: %w", err)
+	}
+
+	target := filepath.Join(destination, entry.Path)
+	if err := copyContent(ctx, filepath.Join(dir, cacheContentName), target); err != nil {
+		return nil, fmt.Errorf("failed to restore cached content: %w", err)
+	}
+	if err := gogather.CountWrittenDir(ctx, target); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	_ = os.Chtimes(dir, now, now)
+
+	fields := m.Get()
+	gatherer, _ := fields["gatherer"].(string)
+	resolved, _ := fields["resolvedURI"].(string)
+	return setCommon(m, metadata.NewCommon(gatherer, gogather.RedactURL(source), resolved, target, startedAt)), nil
+}
+
+// store copies the content gathered to destination into the cache, under the pinned URL of its
+// metadata and under source itself if it is pinned, and evicts entries if the cache grows beyond
+// MaxSize.
+func (c *Cache) store(source, destination string, m metadata.Metadata) error {
+	keys := map[string]bool{}
+	if key, ok := pinnedKey(source); ok {
+		keys[key] = true
+	}
+	if pinned, err := m.GetPinnedURL(source); err == nil {
+		if key, ok := pinnedKey(pinned); ok {
+			keys[key] = true
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	content, _ := m.Get()["destination"].(string)
+	if content == "" {
+		content = destination
+	}
+	rel, err := filepath.Rel(destination, content)
+	if err != nil || !filepath.IsLocal(rel) && rel != "." {
+		return fmt.Errorf("gathered content %s is outside the destination %s", content, destination)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	for key := range keys {
+		if err := c.storeEntry(key, content, cacheEntry{Key: key, Path: rel, Metadata: data}); err != nil {
+			return err
+		}
+	}
+	return c.evict()
+}
+
+// storeEntry stores content as the cache entry for key. The entry is assembled in a temporary
+// directory and moved into place, so that concurrent gathers never observe a partial entry.
+func (c *Cache) storeEntry(key, content string, entry cacheEntry) error {
+	dir := c.entryDir(key)
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+
+	tmp, err := os.MkdirTemp(c.Dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := copyContent(context.Background(), content, filepath.Join(tmp, cacheContentName)); err != nil {
+		return fmt.Errorf("failed to copy content to the cache: %w", err)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, cacheEntryFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp, dir); err != nil && !errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("failed to store cache entry: %w", err)
+	}
+	return nil
+}
+
+// evict removes the least recently used entries until the cache fits in MaxSize.
+func (c *Cache) evict() error {
+	if c.MaxSize <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to list cache entries: %w", err)
+	}
+
+	type usage struct {
+		path string
+		used time.Time
+		size int64
+	}
+	var (
+		usages []usage
+		total  int64
+	)
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(c.Dir, e.Name())
+		size, err := dirSize(path)
+		if err != nil {
+			return err
+		}
+		usages = append(usages, usage{path: path, used: info.ModTime(), size: size})
+		total += size
+	}
+
+	sort.Slice(usages, func(i, j int) bool { return usages[i].used.Before(usages[j].used) })
+	for _, u := range usages {
+		if total <= c.MaxSize {
+			break
+		}
+		if err := os.RemoveAll(u.path); err != nil {
+			return fmt.Errorf("failed to evict cache entry: %w", err)
+		}
+		total -= u.size
+	}
+	return nil
+}
+
+// dirSize returns the size of the regular files below dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to determine the size of %s: %w", dir, err)
+	}
+	return size, nil
+}
+
+// copyContent copies the file or directory at source to destination as is, without expanding
+// archives or counting the copied data towards the gather carried by ctx.
+func copyContent(ctx context.Context, source, destination string) error {
+	ctx = gogather.ContextWithOptions(ctx, gogather.GatherOptions{Archive: "false"})
+	_, err := (&file.FileGatherer{}).Gather(ctx, source, destination)
+	return err
+}
+
+// setCommon returns m with its common fields replaced by c.
+func setCommon(m metadata.Metadata, c metadata.Common) metadata.Metadata {
+	switch m := m.(type) {
+	case *fileMetadata.FileMetadata:
+		m.Common = c
+	case *fileMetadata.DirectoryMetadata:
+		m.Common = c
+	case *gitMetadata.GitMetadata:
+		m.Common = c
+	case *ociMetadata.OCIMetadata:
+		m.Common = c
+	case *httpMetadata.HTTPMetadata:
+		m.Common = c
+	case httpMetadata.HTTPMetadata:
+		m.Common = c
+		return m
+	}
+	return m
+}
@@ -0,0 +1,129 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+	ociMetadata "github.com/enterprise-contract/go-gather/metadata/oci"
+)
+
+// TestPinnedKey tests recognizing sources pinned to an immutable identity
+func TestPinnedKey(t *testing.T) {
+	commit := strings.Repeat("a", 40)
+	tests := []struct {
+		source string
+		pinned bool
+	}{
+		{source: "git::https://example.com/org/repo?ref=" + commit, pinned: true},
+		{source: "git::https://example.com/org/repo?ref=main", pinned: false},
+		{source: "oci::registry.io/repo@sha256:" + strings.Repeat("b", 64), pinned: true},
+		{source: "oci::registry.io/repo:latest", pinned: false},
+		{source: "https://example.com/file.txt?checksum=sha256:abc", pinned: true},
+		{source: "/tmp/file.txt", pinned: false},
+	}
+	for _, tt := range tests {
+		if _, pinned := pinnedKey(tt.source); pinned != tt.pinned {
+			t.Errorf("expected %s to be pinned: %t", tt.source, tt.pinned)
+		}
+	}
+}
+
+// TestGather_Cache tests serving pinned sources from the content cache
+func TestGather_Cache(t *testing.T) {
+	ctx := context.Background()
+	cache := t.TempDir()
+	source := filepath.Join(writeSourceDir(t), "main.rego")
+	pinned := source + "?checksum=sha256:512843855fcc92a51c810b1b58e0731c01eac9a6a23c157bfa02aad71edffbe7"
+
+	if _, err := Gather(ctx, pinned, filepath.Join(t.TempDir(), "main.rego"), WithCache(cache, 0)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.Remove(source); err != nil {
+		t.Fatal(err)
+	}
+
+	destination := filepath.Join(t.TempDir(), "main.rego")
+	m, err := Gather(ctx, pinned, destination, WithCache(cache, 0))
+	if err != nil {
+		t.Fatalf("expected the source to be served from the cache: %v", err)
+	}
+	if b, err := os.ReadFile(destination); err != nil || string(b) != "package main" {
+		t.Errorf("unexpected content: %q, %v", b, err)
+	}
+	if fields := m.Get(); fields["destination"] != destination || fields["sourceURI"] != pinned {
+		t.Errorf("unexpected metadata: %v", fields)
+	}
+
+	if _, err := Gather(ctx, pinned, filepath.Join(t.TempDir(), "main.rego"), WithCache(cache, 0), WithNoCache()); err == nil {
+		t.Error("expected the cache to be bypassed")
+	}
+}
+
+// digestGatherer writes a file of the given size and returns OCI metadata with a digest derived
+// from the source.
+type digestGatherer struct {
+	size  int
+	calls int
+}
+
+func (g *digestGatherer) Gather(_ context.Context, source, destination string) (metadata.Metadata, error) {
+	g.calls++
+	if err := os.MkdirAll(destination, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(destination, "layer"), make([]byte, g.size), 0600); err != nil {
+		return nil, err
+	}
+	digest := "sha256:" + strings.Repeat(source[len(source)-1:], 64)
+	return &ociMetadata.OCIMetadata{Common: metadata.Common{Destination: destination, Gatherer: "oci"}, Digest: digest}, nil
+}
+
+// TestCachingGatherer tests caching under the pinned URL and evicting least recently used entries
+func TestCachingGatherer(t *testing.T) {
+	ctx := context.Background()
+	g := &digestGatherer{size: 1000}
+	c := NewCachingGatherer(g, &Cache{Dir: t.TempDir(), MaxSize: 1500})
+
+	// The content of an unpinned source is cached under its pinned URL.
+	if _, err := c.Gather(ctx, "oci::registry.io/repo-a", t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pinnedA := "oci::registry.io/repo-a@sha256:" + strings.Repeat("a", 64)
+	m, err := c.Gather(ctx, pinnedA, filepath.Join(t.TempDir(), "out"))
+	if err != nil || g.calls != 1 {
+		t.Fatalf("expected a cache hit, got %d calls (%v)", g.calls, err)
+	}
+	if om, ok := m.(*ociMetadata.OCIMetadata); !ok || om.Digest != "sha256:"+strings.Repeat("a", 64) || om.SourceURI != pinnedA {
+		t.Errorf("unexpected metadata: %+v", m)
+	}
+
+	// Storing another entry evicts the least recently used one.
+	time.Sleep(10 * time.Millisecond)
+	if _, err := c.Gather(ctx, "oci::registry.io/repo-b", t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.Gather(ctx, pinnedA, filepath.Join(t.TempDir(), "out")); err != nil || g.calls != 3 {
+		t.Errorf("expected the entry to be evicted, got %d calls (%v)", g.calls, err)
+	}
+}
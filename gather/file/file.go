@@ -21,13 +21,18 @@ package file
 
 import (
 	"context"
+	"crypto"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"net/url"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -37,48 +42,279 @@ import (
 	"github.com/enterprise-contract/go-gather/metadata"
 	"github.com/enterprise-contract/go-gather/metadata/file"
 	"github.com/enterprise-contract/go-gather/saver"
+	"golang.org/x/sync/errgroup"
 )
 
+// DestinationInsideSourceError is returned when the destination of a gather is the
+// source itself or is located inside the source directory tree. Copying in that case
+// would either recurse forever or silently duplicate content.
+type DestinationInsideSourceError struct {
+	Source      string
+	Destination string
+}
+
+func (e *DestinationInsideSourceError) Error() string {
+	if e.Source == e.Destination {
+		return fmt.Sprintf("destination %s is the same as the source", e.Destination)
+	}
+	return fmt.Sprintf("destination %s is inside the source directory %s", e.Destination, e.Source)
+}
+
 // FileGatherer is a struct that implements the Gatherer interface
 // and provides methods for gathering files and directories.
-type FileGatherer struct{}
+type FileGatherer struct {
+	// ExpanderOptions configures the expanders used for archive sources, e.g. to limit
+	// the number of files or the size of the expanded content.
+	ExpanderOptions []expander.Option
+	// Inventory attaches an inventory of the gathered files to the metadata of gathered
+	// directories.
+	Inventory bool
+	// Logger receives diagnostic messages. When nil, the logger of the gather options is used.
+	Logger *slog.Logger
+}
 
 // Gather copies a file or directory from the source path to the destination path.
 // It returns the metadata of the gathered file or directory and any error encountered.
-func (f *FileGatherer) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
-	source = strings.TrimPrefix(source, "file::")
+func (f *FileGatherer) Gather(ctx context.Context, source, destination string) (m metadata.Metadata, err error) {
+	defer func() {
+		err = utils.RedactError(err)
+		utils.FinishProgress(ctx, err)
+	}()
+	startedAt := time.Now()
+	if err := utils.CheckHost(ctx, "file", ""); err != nil {
+		return nil, err
+	}
+	utils.Logger(ctx, f.Logger).Debug("gathering file", "source", source, "destination", destination)
+	digests := utils.NewDigests()
+	m, err = f.gather(ctx, source, destination, digests)
+	if err != nil {
+		return m, err
+	}
 
-	// Parse the source URI
-	src, err := url.Parse(source)
+	var resolved string
+	if src, err := utils.LocalPath(source); err == nil {
+		if path, err := resolvePath(src); err == nil {
+			resolved = "file::" + path
+		}
+	}
+	setCommon(m, metadata.NewCommon("file", source, resolved, destination, startedAt))
+	if err := f.finishDirectory(ctx, m, destination, digests); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Validate checks that the file or directory at source exists without reading it.
+func (f *FileGatherer) Validate(ctx context.Context, source string) error {
+	if err := utils.CheckHost(ctx, "file", ""); err != nil {
+		return err
+	}
+	src, err := utils.LocalPath(source)
+	if err != nil {
+		return fmt.Errorf("failed to parse source URI: %w", err)
+	}
+	path, err := resolvePath(src)
+	if err != nil {
+		return fmt.Errorf("failed to resolve source path: %w", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("failed to determine source kind: %w", err)
+	}
+	return nil
+}
+
+// Resolve describes the file or directory at source without copying it, identifying files by their
+// SHA256 digest and directories by their metadata.TreeHash.
+func (f *FileGatherer) Resolve(ctx context.Context, source string) (metadata.Metadata, error) {
+	startedAt := time.Now()
+	if err := utils.CheckHost(ctx, "file", ""); err != nil {
+		return nil, err
+	}
+	src, err := utils.LocalPath(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source URI: %w", err)
+	}
+	path, err := resolvePath(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve source path: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine source kind: %w", err)
+	}
+
+	common := metadata.NewCommon("file", source, "file::"+path, "", startedAt)
+	if info.IsDir() {
+		treeHash, err := metadata.TreeHash(path)
+		if err != nil {
+			return nil, err
+		}
+		return &file.DirectoryMetadata{Common: common, Path: path, Timestamp: info.ModTime(), TreeHash: treeHash}, nil
+	}
+	sha, err := getFileSha(path)
+	if err != nil {
+		return nil, err
+	}
+	return &file.FileMetadata{Common: common, Size: info.Size(), Path: path, Timestamp: info.ModTime(), SHA: sha}, nil
+}
 
+// gather copies or expands source to destination, recording the digests of the copied files in
+// digests.
+func (f *FileGatherer) gather(ctx context.Context, source, destination string, digests *utils.Digests) (metadata.Metadata, error) {
+	// Parse the source URI
+	src, err := utils.LocalPath(source)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse source URI: %w", err)
 	}
 
 	// Determine if we have a file or directory
-	sourceKind, err := os.Stat(src.Path)
+	sourceKind, err := os.Stat(src)
 	if err != nil {
 		return nil, fmt.Errorf("failed to determine source kind: %w", err)
 	}
 
-	// Determine if we have a tar file as the src. If so, we need to untar it.
-	if strings.HasSuffix(src.Path, ".tar") {
-		dst, err := url.Parse(destination)
+	// Determine if we have an archive as the src. If so, we need to expand it.
+	e, ok, err := f.expanderFor(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	if ok || sourceKind.IsDir() {
+		utils.StartProgress(ctx, -1, -1)
+	} else {
+		utils.StartProgress(ctx, sourceKind.Size(), 1)
+	}
+	// Archives are verified before they are expanded, files while they are copied and
+	// directories once they are copied.
+	if ok {
+		if err := utils.VerifyChecksum(ctx, src); err != nil {
+			return nil, err
+		}
+		dst, err := utils.LocalPath(destination)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse destination URI: %w", err)
 		}
 
-		t := &expander.TarExpander{
-			FilesLimit:    0,
-			FileSizeLimit: 0,
+		err = e.Expand(dst, src, true, 0755)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand archive: %w", err)
+		}
+		utils.Logger(ctx, f.Logger).Debug("expanded archive", "source", src, "destination", dst)
+		if err := utils.CountWrittenDir(ctx, dst); err != nil {
+			return nil, err
+		}
+
+		info, err := os.Stat(dst)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get file info: %w", err)
+		}
+
+		return &file.DirectoryMetadata{
+			Path:      dst,
+			Timestamp: info.ModTime(),
+		}, nil
+	}
+
+	dst, err := utils.LocalPath(destination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse destination URI: %w", err)
+	}
+	if err := checkDestinationOutsideSource(src, dst, sourceKind.IsDir()); err != nil {
+		return nil, err
+	}
+
+	// If it's a directory, call copyDirectory, otherwise call copyFile
+	if sourceKind.IsDir() {
+		return f.copyDirectory(ctx, src, destination, digests)
+	} else {
+		return f.copyFile(ctx, src, destination)
+	}
+}
+
+// GatherFrom copies a file or directory rooted at root in fsys to the destination path.
+// It allows sources that do not live on the local filesystem, such as an embed.FS or an
+// in-memory fs.FS, to be materialized using the same saver and metadata machinery as Gather.
+// It returns the metadata of the gathered file or directory and any error encountered.
+func (f *FileGatherer) GatherFrom(ctx context.Context, fsys fs.FS, root, destination string) (m metadata.Metadata, err error) {
+	defer func() { utils.FinishProgress(ctx, err) }()
+	startedAt := time.Now()
+	digests := utils.NewDigests()
+	m, err = f.gatherFrom(ctx, fsys, root, destination, digests)
+	if err != nil {
+		return m, err
+	}
+
+	setCommon(m, metadata.NewCommon("file", root, "", destination, startedAt))
+	if err := f.finishDirectory(ctx, m, destination, digests); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// expanderOptions returns the ExpanderOptions extended with the filters and the size limit of the
+// gather options carried by ctx.
+func (f *FileGatherer) expanderOptions(ctx context.Context) []expander.Option {
+	o := utils.OptionsFromContext(ctx)
+	return append(slices.Clone(f.ExpanderOptions), func(c *expander.Config) {
+		c.Options.Include = append(c.Options.Include, o.Include...)
+		c.Options.Exclude = append(c.Options.Exclude, o.Exclude...)
+		if o.MaxSize > 0 && (c.FileSizeLimit <= 0 || c.FileSizeLimit > o.MaxSize) {
+			c.FileSizeLimit = o.MaxSize
+		}
+	})
+}
+
+// expanderFor returns the expander for the archive at path, and whether path is an archive. The
+// format is determined by the extension of path unless the Archive gather option overrides it.
+func (f *FileGatherer) expanderFor(ctx context.Context, path string) (expander.Expander, bool, error) {
+	switch archive := utils.OptionsFromContext(ctx).Archive; archive {
+	case "":
+		e, ok := expander.NewExpanderForPath(path, f.expanderOptions(ctx)...)
+		return e, ok, nil
+	case "false":
+		return nil, false, nil
+	default:
+		e, err := expander.NewExpander(archive, f.expanderOptions(ctx)...)
+		if err != nil {
+			return nil, false, err
 		}
+		return e, true, nil
+	}
+}
+
+// gatherFrom copies root in fsys to destination, recording the digests of the copied files in
+// digests.
+func (f *FileGatherer) gatherFrom(ctx context.Context, fsys fs.FS, root, destination string, digests *utils.Digests) (metadata.Metadata, error) {
+	if fsys == nil {
+		return nil, fmt.Errorf("source filesystem is nil")
+	}
+	if root == "" {
+		root = "."
+	}
+
+	dst, err := utils.LocalPath(destination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse destination URI: %w", err)
+	}
+
+	// Determine if we have a file or directory
+	sourceKind, err := fs.Stat(fsys, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine source kind: %w", err)
+	}
+
+	if sourceKind.IsDir() {
+		utils.StartProgress(ctx, -1, -1)
+	} else {
+		utils.StartProgress(ctx, sourceKind.Size(), 1)
+	}
 
-		err = t.Expand(dst.Path, src.Path, true, 0755)
+	if !sourceKind.IsDir() {
+		fileSha, err := saveFromFS(ctx, fsys, root, dst, digests)
 		if err != nil {
-			return nil, fmt.Errorf("failed to expand tar file: %w", err)
+			return nil, err
 		}
 
-		info, err := os.Stat(destination)
+		info, err := os.Stat(dst)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get file info: %w", err)
 		}
@@ -87,19 +323,95 @@ func (f *FileGatherer) Gather(ctx context.Context, source, destination string) (
 			Size:      info.Size(),
 			Path:      destination,
 			Timestamp: info.ModTime(),
+			SHA:       fileSha,
 		}, nil
 	}
 
-	// If it's a directory, call copyDirectory, otherwise call copyFile
-	if sourceKind.IsDir() {
-		return f.copyDirectory(ctx, src.Path, destination)
-	} else {
-		return f.copyFile(ctx, src.Path, destination)
+	var size int64
+	err = fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to walk path: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		relPath, err := filepath.Rel(filepath.FromSlash(root), filepath.FromSlash(path))
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+
+		destPath := filepath.Join(dst, relPath)
+		if d.IsDir() {
+			if err := os.MkdirAll(destPath, 0755); err != nil {
+				return fmt.Errorf("failed to create directory: %w", err)
+			}
+			return nil
+		}
+
+		if _, err := saveFromFS(ctx, fsys, path, destPath, digests); err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("failed to get file info: %w", err)
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy directory: %w", err)
+	}
+
+	return &file.DirectoryMetadata{
+		Size:      size,
+		Path:      dst,
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// saveFromFS opens the named file in fsys and saves its contents to destination using the file saver.
+// It returns the hex encoded SHA256 digest of the contents, which is recorded in digests.
+func saveFromFS(ctx context.Context, fsys fs.FS, name, destination string, digests *utils.Digests) (string, error) {
+	srcFile, err := fsys.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to open source file: %w", err)
 	}
+	defer srcFile.Close()
+
+	digest, err := saveFile(ctx, utils.WrapReader(ctx, srcFile), destination, digests)
+	if err != nil {
+		return "", err
+	}
+	utils.CountItems(ctx, 1)
+	return digest, nil
+}
+
+// saveFile saves data to destination using the file saver, computing the SHA256 digest of the data
+// while it is saved. It returns the hex encoded digest, which is recorded in digests.
+func saveFile(ctx context.Context, data io.Reader, destination string, digests *utils.Digests) (string, error) {
+	s, err := saver.NewSaver("file")
+	if err != nil {
+		return "", fmt.Errorf("failed to create saver: %w", err)
+	}
+
+	checksum := saver.NewChecksumSaver(s, crypto.SHA256)
+	result, err := saver.SaveWithResult(ctx, checksum, data, destination)
+	if err != nil {
+		return "", fmt.Errorf("failed to save file: %w", err)
+	}
+	digest, _ := checksum.Digest(destination)
+	if sum, err := hex.DecodeString(digest); err == nil {
+		digests.Record(destination, result.Size, sum)
+	}
+	return digest, nil
 }
 
 func (f *FileGatherer) copyFile(ctx context.Context, source, destination string) (metadata.Metadata, error) {
-	src, err := url.Parse(source)
+	src, err := utils.LocalPath(source)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse source URI: %w", err)
 	}
@@ -110,7 +422,7 @@ func (f *FileGatherer) copyFile(ctx context.Context, source, destination string)
 	}
 
 	// Open the source file.
-	srcFile, err := os.Open(filepath.Clean(src.Path))
+	srcFile, err := os.Open(filepath.Clean(src))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open source file: %w", err)
 	}
@@ -128,137 +440,288 @@ func (f *FileGatherer) copyFile(ctx context.Context, source, destination string)
 		return nil, fmt.Errorf("destination URI is not a file")
 	}
 
-	// Parse the destination URI.
-	destFile, err := url.Parse(destination)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse destination URI: %w", err)
-	}
-
 	// Create the appropriate Saver to handle storing the data.
-	saver, err := saver.NewSaver("file")
+	s, err := saver.NewSaver("file")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create saver: %w", err)
 	}
 
-	// Save the file to the destination.
-	if err := saver.Save(ctx, srcFile, destination); err != nil {
-		return nil, fmt.Errorf("failed to save file: %w", err)
-	}
-
-	// Get the file info
-	info, err := os.Stat(destFile.Path)
+	// Save the file to the destination, calculating its SHA256 hash and verifying it against the
+	// checksum of the gather options on the way.
+	verifier, err := utils.NewChecksumVerifier(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get file info: %w", err)
+		return nil, err
 	}
-
-	// Calculate the SHA256 hash of the file
-	fileSha, err := getFileSha(destFile.Path)
+	checksum := saver.NewChecksumSaver(s, crypto.SHA256)
+	result, err := saver.SaveWithResult(ctx, checksum, verifier.Reader(utils.WrapReader(ctx, srcFile)), destination)
 	if err != nil {
-		return nil, fmt.Errorf("failed to calculate file SHA: %w", err)
+		return nil, fmt.Errorf("failed to save file: %w", err)
 	}
+	if err := verifier.Verify(destination); err != nil {
+		if dst, err := utils.LocalPath(destination); err == nil {
+			_ = os.Remove(dst)
+		}
+		return nil, err
+	}
+	fileSha, _ := checksum.Digest(destination)
+	utils.CountItems(ctx, 1)
+	utils.Logger(ctx, f.Logger).Debug("saved file", "destination", destination, "size", result.Size)
 
 	return &file.FileMetadata{
-		Size:      info.Size(),
+		Size:      result.Size,
 		Path:      destination,
-		Timestamp: info.ModTime(),
+		Timestamp: time.Now(),
 		SHA:       fileSha,
 	}, nil
 }
 
+// maxConcurrentCopies limits the number of files copied concurrently by copyDirectory
+// to avoid overwhelming system resources.
+const maxConcurrentCopies = 10
+
+// CopyDirectoryError is returned when one or more entries could not be copied while
+// copying a directory. It records every error encountered, ordered by path, along with
+// how far the copy progressed before it finished.
+type CopyDirectoryError struct {
+	// Copied is the number of files that were copied successfully.
+	Copied int
+	// Failed is the number of entries that could not be copied.
+	Failed int
+	// Errs holds the errors encountered, sorted by the path they relate to.
+	Errs []error
+}
+
+func (e *CopyDirectoryError) Error() string {
+	return fmt.Sprintf("failed to copy directory (%d copied, %d failed): %v", e.Copied, e.Failed, errors.Join(e.Errs...))
+}
+
+// Unwrap returns the errors encountered so that errors.Is and errors.As can inspect them.
+func (e *CopyDirectoryError) Unwrap() []error {
+	return e.Errs
+}
+
+// pathError associates an error with the path it was encountered for.
+type pathError struct {
+	path string
+	err  error
+}
+
 // copyDirectory copies a directory from the source path to the destination path.
 // It walks through the directory tree, creates the corresponding directories in the destination path,
 // and copies each file in the directory to the destination path.
-// It limits the number of concurrent operations to 10 to avoid overwhelming system resources.
+// It limits the number of concurrent operations to maxConcurrentCopies to avoid overwhelming system resources.
+// A failure to copy one file does not stop the others; all errors are collected and returned,
+// together with progress information, as a *CopyDirectoryError.
 // It returns the metadata of the copied directory and any error encountered.
-func (f *FileGatherer) copyDirectory(ctx context.Context, source, destination string) (metadata.Metadata, error) {
-	src, err := url.Parse(source)
+func (f *FileGatherer) copyDirectory(ctx context.Context, source, destination string, digests *utils.Digests) (metadata.Metadata, error) {
+	src, err := utils.LocalPath(source)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse source URI: %w", err)
 	}
-	dst, err := url.Parse(destination)
+	dst, err := utils.LocalPath(destination)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse destination URI: %w", err)
 	}
 
-	errChan := make(chan error, 100) // Increased buffer size
-	done := make(chan bool)
-	semaphore := make(chan struct{}, 10) // Limit to 10 concurrent operations
+	var (
+		mu     sync.Mutex
+		copied int
+		failed []pathError
+	)
+	record := func(path string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			failed = append(failed, pathError{path: path, err: err})
+			return
+		}
+		copied++
+	}
 
-	var wg sync.WaitGroup // Using a WaitGroup to manage concurrency
+	g := new(errgroup.Group)
+	g.SetLimit(maxConcurrentCopies)
 
-	go func() {
-		defer close(done)
-		err = filepath.Walk(src.Path, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return fmt.Errorf("failed to walk path: %w", err)
+	walkErr := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Record the failure and carry on with the rest of the tree. Returning the
+			// error here would abandon every sibling that has not been visited yet.
+			record(path, fmt.Errorf("failed to walk path: %w", err))
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
 			}
+			return nil
+		}
 
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			default:
-			}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
-			relPath, err := filepath.Rel(src.Path, path)
-			if err != nil {
-				return fmt.Errorf("failed to get relative path: %w", err)
-			}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			record(path, fmt.Errorf("failed to get relative path: %w", err))
+			return nil
+		}
 
-			destPath := filepath.Join(dst.Path, relPath)
-			if info.IsDir() {
-				if err := os.MkdirAll(destPath, 0755); err != nil {
-					return fmt.Errorf("failed to create directory: %w", err)
-				}
-			} else {
-				semaphore <- struct{}{}
-				wg.Add(1)
-				go func() {
-					defer func() {
-						<-semaphore
-						wg.Done()
-					}()
-					srcFile, err := os.Open(filepath.Clean(path))
-					if err != nil {
-						errChan <- err
-						return
-					}
-					defer srcFile.Close()
-
-					saver, err := saver.NewSaver("file")
-					if err != nil {
-						errChan <- err
-						return
-					}
-
-					if err := saver.Save(ctx, srcFile, destPath); err != nil {
-						errChan <- err
-						return
-					}
-				}()
+		destPath := filepath.Join(dst, relPath)
+		if info.IsDir() {
+			if err := os.MkdirAll(destPath, 0755); err != nil {
+				record(path, fmt.Errorf("failed to create directory: %w", err))
+				return filepath.SkipDir
 			}
 			return nil
-		})
-		if err != nil {
-			errChan <- err
 		}
 
-		wg.Wait()      // Wait for all goroutines to finish
-		close(errChan) // Close the channel safely after all sends are done
-	}()
+		g.Go(func() error {
+			record(path, copyToDestination(ctx, path, destPath, digests))
+			return nil
+		})
+		return nil
+	})
+	// The goroutines never return an error; they are recorded in failed instead.
+	_ = g.Wait()
 
-	// Handle errors and completion
-	for err := range errChan {
-		if err != nil {
-			return nil, fmt.Errorf("failed to copy directory: %w", err)
+	if walkErr != nil {
+		record(src, walkErr)
+	}
+
+	if len(failed) > 0 {
+		sort.SliceStable(failed, func(i, j int) bool { return failed[i].path < failed[j].path })
+		errs := make([]error, 0, len(failed))
+		for _, pe := range failed {
+			errs = append(errs, fmt.Errorf("%s: %w", pe.path, pe.err))
+		}
+		return nil, &CopyDirectoryError{
+			Copied: copied,
+			Failed: len(failed),
+			Errs:   errs,
 		}
 	}
-	<-done
+	utils.Logger(ctx, f.Logger).Debug("copied directory", "source", src, "destination", dst, "files", copied)
+
 	return &file.DirectoryMetadata{
-		Path:      dst.Path,
+		Path:      dst,
 		Timestamp: time.Now(),
 	}, nil
 }
 
+// setCommon sets the common fields of the file or directory metadata m.
+func setCommon(m metadata.Metadata, c metadata.Common) {
+	switch m := m.(type) {
+	case *file.FileMetadata:
+		m.Common = c
+	case *file.DirectoryMetadata:
+		m.Common = c
+	}
+}
+
+// finishDirectory removes the files filtered out by the gather options from the destination of a
+// gathered directory, records the tree hash of the destination in the directory metadata, verifies
+// it against the checksum of the gather options unless the gather has been verified already, e.g.
+// by the digest of an expanded archive, and attaches its inventory when Inventory is set.
+func (f *FileGatherer) finishDirectory(ctx context.Context, m metadata.Metadata, destination string, digests *utils.Digests) error {
+	dm, ok := m.(*file.DirectoryMetadata)
+	if !ok {
+		return nil
+	}
+	dst, err := utils.LocalPath(destination)
+	if err != nil {
+		return fmt.Errorf("failed to parse destination URI: %w", err)
+	}
+	if err := utils.OptionsFromContext(ctx).Prune(dst); err != nil {
+		return err
+	}
+	if dm.TreeHash, err = metadata.TreeHashWithDigests(dst, digests.SHA256); err != nil {
+		return err
+	}
+	// Expanded archives have been verified by the digest of the archive.
+	if !utils.ChecksumVerified(ctx) {
+		if err := utils.VerifyChecksumDigest(ctx, dst, "sha256", dm.TreeHash); err != nil {
+			return err
+		}
+	}
+	if f.Inventory {
+		dm.Inventory, err = metadata.NewInventory(dst)
+	}
+	return err
+}
+
+// copyToDestination copies the file at source to destination using the file saver, recording its
+// digest in digests.
+func copyToDestination(ctx context.Context, source, destination string, digests *utils.Digests) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	srcFile, err := os.Open(filepath.Clean(source))
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close()
+
+	if _, err := saveFile(ctx, utils.WrapReader(ctx, srcFile), destination, digests); err != nil {
+		return err
+	}
+	utils.CountItems(ctx, 1)
+	return nil
+}
+
+// checkDestinationOutsideSource returns a DestinationInsideSourceError if the destination path
+// resolves to the source path or, when the source is a directory, to a path inside of it.
+// Symbolic links are resolved for the longest existing prefix of each path so that aliases
+// of the source tree are detected as well.
+func checkDestinationOutsideSource(source, destination string, isDir bool) error {
+	src, err := resolvePath(source)
+	if err != nil {
+		return fmt.Errorf("failed to resolve source path: %w", err)
+	}
+	dst, err := resolvePath(utils.ExpandTilde(destination))
+	if err != nil {
+		return fmt.Errorf("failed to resolve destination path: %w", err)
+	}
+
+	if src == dst {
+		return &DestinationInsideSourceError{Source: src, Destination: dst}
+	}
+	if !isDir {
+		return nil
+	}
+
+	rel, err := filepath.Rel(src, dst)
+	if err != nil {
+		return nil
+	}
+	if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return &DestinationInsideSourceError{Source: src, Destination: dst}
+	}
+	return nil
+}
+
+// resolvePath returns the absolute, cleaned form of path with symbolic links evaluated for
+// the longest prefix of the path that exists.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	existing, rest := abs, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return abs, nil
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+}
+
 // getFileSha calculates the SHA256 hash of a file located at the given path.
 // It returns the hexadecimal representation of the hash and any error encountered.
 // If the file cannot be opened or an error occurs while calculating the hash, an empty string and the error are returned.
@@ -271,7 +734,7 @@ func getFileSha(path string) (string, error) {
 	defer file.Close()
 
 	hasher := sha256.New()
-	if _, err := io.Copy(hasher, file); err != nil {
+	if _, err := utils.HashFile(hasher, file); err != nil {
 		return "", fmt.Errorf("failed to calculate file SHA: %w", err)
 	}
 
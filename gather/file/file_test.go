@@ -17,11 +17,23 @@
 package file
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/expander"
+	"github.com/enterprise-contract/go-gather/metadata"
+	"github.com/enterprise-contract/go-gather/metadata/file"
 )
 
 func TestFileGatherer_Gather(t *testing.T) {
@@ -66,7 +78,7 @@ func TestFileGatherer_Gather(t *testing.T) {
 
 	// Test when the source is a directory
 	sourceDir := tempDir
-	destinationDir := filepath.Join(tempDir, "destination_dir")
+	destinationDir := filepath.Join(t.TempDir(), "destination_dir")
 	_, err = gatherer.Gather(context.Background(), sourceDir, fmt.Sprintf("%s%s", "file://", destinationDir))
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
@@ -78,6 +90,114 @@ func TestFileGatherer_Gather(t *testing.T) {
 	}
 }
 
+// TestFileGatherer_Gather_Common tests that the common metadata fields are populated.
+func TestFileGatherer_Gather_Common(t *testing.T) {
+	srcDir := t.TempDir()
+	source := filepath.Join(srcDir, "source.txt")
+	if err := os.WriteFile(source, []byte("test content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	destination := filepath.Join(t.TempDir(), "destination.txt")
+
+	gatherer := &FileGatherer{}
+	m, err := gatherer.Gather(context.Background(), "file::"+source, destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fm, ok := m.(*file.FileMetadata)
+	if !ok {
+		t.Fatalf("unexpected metadata type: %T", m)
+	}
+	resolved, err := resolvePath(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fm.Gatherer != "file" || fm.SourceURI != "file::"+source || fm.ResolvedURI != "file::"+resolved || fm.Destination != destination {
+		t.Errorf("unexpected common fields: %+v", fm.Common)
+	}
+	if fm.StartedAt.IsZero() || fm.Duration <= 0 {
+		t.Errorf("unexpected timing: started at %s, took %s", fm.StartedAt, fm.Duration)
+	}
+}
+
+// TestFileGatherer_Gather_Inventory tests attaching an inventory to directory metadata
+func TestFileGatherer_Gather_Inventory(t *testing.T) {
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("foo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	destination := filepath.Join(t.TempDir(), "destination")
+
+	gatherer := &FileGatherer{Inventory: true}
+	m, err := gatherer.Gather(context.Background(), "file::"+srcDir, destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dm, ok := m.(*file.DirectoryMetadata)
+	if !ok {
+		t.Fatalf("unexpected metadata type: %T", m)
+	}
+	if dm.Inventory == nil || len(dm.Inventory.Files) != 1 || dm.Inventory.Files[0].Path != "a.txt" || dm.Inventory.Files[0].Size != 3 {
+		t.Errorf("unexpected inventory: %+v", dm.Inventory)
+	}
+	if _, ok := m.Get()["inventory"]; !ok {
+		t.Error("expected the inventory in the metadata fields")
+	}
+	if expected, err := metadata.TreeHash(srcDir); err != nil || dm.TreeHash != expected {
+		t.Errorf("expected tree hash %s, got %s (%v)", expected, dm.TreeHash, err)
+	}
+}
+
+// TestFileGatherer_Gather_Checksum tests verifying a file against the checksum option while it is
+// copied
+func TestFileGatherer_Gather_Checksum(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "hello.txt")
+	if err := os.WriteFile(src, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	destination := filepath.Join(t.TempDir(), "hello.txt")
+	gatherer := &FileGatherer{}
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Checksum: "sha256:" + strings.Repeat("0", 64)})
+	var mismatch *gogather.ChecksumMismatchError
+	if _, err := gatherer.Gather(ctx, "file::"+src, destination); !errors.As(err, &mismatch) {
+		t.Fatalf("expected a ChecksumMismatchError, got: %v", err)
+	}
+	if _, err := os.Stat(destination); !os.IsNotExist(err) {
+		t.Errorf("expected the destination to be removed: %v", err)
+	}
+
+	ctx = gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Checksum: "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"})
+	m, err := gatherer.Gather(ctx, "file::"+src, destination)
+	if err != nil || !gogather.ChecksumVerified(ctx) {
+		t.Fatalf("expected the gather to be verified: %v", err)
+	}
+	if sha := m.(*file.FileMetadata).SHA; sha != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("unexpected SHA: %s", sha)
+	}
+}
+
+// TestFileGatherer_Gather_Logger tests that the saves are logged to the logger of the gatherer
+func TestFileGatherer_Gather_Logger(t *testing.T) {
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("foo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	gatherer := &FileGatherer{Logger: slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))}
+	if _, err := gatherer.Gather(context.Background(), "file::"+srcDir, filepath.Join(t.TempDir(), "destination")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, msg := range []string{"gathering file", "copied directory"} {
+		if !strings.Contains(logs.String(), msg) {
+			t.Errorf("expected %q to be logged:\n%s", msg, logs.String())
+		}
+	}
+}
+
 func TestFileGatherer_Gather_Error(t *testing.T) {
 	// Create a FileGatherer instance
 	gatherer := &FileGatherer{}
@@ -91,6 +211,129 @@ func TestFileGatherer_Gather_Error(t *testing.T) {
 	}
 }
 
+// writeZip writes a zip archive to path containing the named members.
+func writeZip(t *testing.T, path string, names ...string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for _, name := range names {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte("package main")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestFileGatherer_Gather_Zip tests that zip archives are expanded into the destination
+func TestFileGatherer_Gather_Zip(t *testing.T) {
+	tmp := t.TempDir()
+	source := filepath.Join(tmp, "bundle.zip")
+	writeZip(t, source, "policy/main.rego")
+
+	gatherer := &FileGatherer{}
+	destination := filepath.Join(tmp, "destination")
+	m, err := gatherer.Gather(context.Background(), source, "file://"+destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(destination, "policy", "main.rego")); err != nil {
+		t.Errorf("expected archive member to be expanded: %v", err)
+	}
+	treeHash, err := metadata.TreeHash(destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dm, ok := m.(*file.DirectoryMetadata); !ok || dm.Path != destination || dm.TreeHash != treeHash {
+		t.Errorf("expected the metadata of the expanded directory, got %+v", m)
+	}
+
+	// The archive is verified by its digest, and the expanded directory is filtered.
+	writeZip(t, source, "policy/main.rego", "README.md")
+	data, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{
+		Checksum: fmt.Sprintf("sha256:%x", sha256.Sum256(data)),
+		Include:  []string{"policy/**"},
+	})
+	destination = filepath.Join(tmp, "filtered")
+	if _, err := gatherer.Gather(ctx, source, destination); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(destination, "README.md")); !os.IsNotExist(err) {
+		t.Errorf("expected README.md to be filtered out: %v", err)
+	}
+}
+
+// TestFileGatherer_Gather_ExpanderOptions tests that the expander options are honored
+func TestFileGatherer_Gather_ExpanderOptions(t *testing.T) {
+	tmp := t.TempDir()
+	source := filepath.Join(tmp, "bundle.zip")
+	writeZip(t, source, "policy/main.rego", "policy/lib.rego")
+
+	gatherer := &FileGatherer{ExpanderOptions: []expander.Option{expander.WithFilesLimit(1)}}
+	_, err := gatherer.Gather(context.Background(), source, "file://"+filepath.Join(tmp, "destination"))
+	if err == nil || !strings.Contains(err.Error(), "more files than the 1 allowed") {
+		t.Errorf("expected files limit error, got: %v", err)
+	}
+}
+
+// TestFileGatherer_Gather_DestinationInsideSource tests that gathering a directory into itself fails
+func TestFileGatherer_Gather_DestinationInsideSource(t *testing.T) {
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(source, link); err != nil {
+		t.Fatal(err)
+	}
+
+	gatherer := &FileGatherer{}
+	tests := []struct {
+		name        string
+		source      string
+		destination string
+	}{
+		{"identical directory", source, source},
+		{"nested directory", source, filepath.Join(source, "nested", "destination")},
+		{"nested directory with file scheme", source, "file://" + filepath.Join(source, "destination")},
+		{"nested directory through symlink", link, filepath.Join(source, "destination")},
+		{"identical file", filepath.Join(source, "file.txt"), filepath.Join(source, "file.txt")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := gatherer.Gather(context.Background(), tt.source, tt.destination)
+			var insideErr *DestinationInsideSourceError
+			if !errors.As(err, &insideErr) {
+				t.Fatalf("expected DestinationInsideSourceError, got: %v", err)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(source, "nested")); err == nil {
+		t.Error("expected nothing to be written to the source directory")
+	}
+
+	// A sibling directory sharing the source's name as a prefix is not inside the source
+	sibling := source + "-copy"
+	defer os.RemoveAll(sibling)
+	if _, err := gatherer.Gather(context.Background(), source, sibling); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
 // TestFileGatherer_URLParseError tests the error handling of the URL parsing
 func TestFileGatherer_Gather_URLParseError(t *testing.T) {
 	// Create a FileGatherer instance
@@ -265,7 +508,7 @@ func TestFileGatherer_copyDirectory_Source_URIParseError(t *testing.T) {
 	// Test when url.Parse returns an error
 	source := ":"
 	destination := "destination_dir"
-	_, err := gatherer.copyDirectory(context.Background(), source, destination)
+	_, err := gatherer.copyDirectory(context.Background(), source, destination, nil)
 	if err == nil {
 		t.Error("expected an error, but got nil")
 	}
@@ -282,7 +525,7 @@ func TestFileGatherer_copyDirectory_Destination_URIParseError(t *testing.T) {
 	// Test when url.Parse returns an error
 	source := "source_dir"
 	destination := ":"
-	_, err := gatherer.copyDirectory(context.Background(), source, destination)
+	_, err := gatherer.copyDirectory(context.Background(), source, destination, nil)
 	if err == nil {
 		t.Error("expected an error, but got nil")
 	}
@@ -292,6 +535,68 @@ func TestFileGatherer_copyDirectory_Destination_URIParseError(t *testing.T) {
 	}
 }
 
+// TestFileGatherer_copyDirectory_MultipleErrors tests that every failure is reported along with progress information
+func TestFileGatherer_copyDirectory_MultipleErrors(t *testing.T) {
+	source := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(source, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Dangling symlinks cannot be opened, so copying them fails.
+	for _, name := range []string{"z-broken", "m-broken"} {
+		if err := os.Symlink(filepath.Join(source, "missing"), filepath.Join(source, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gatherer := &FileGatherer{}
+	destination := t.TempDir()
+	_, err := gatherer.copyDirectory(context.Background(), source, destination, nil)
+
+	var copyErr *CopyDirectoryError
+	if !errors.As(err, &copyErr) {
+		t.Fatalf("expected CopyDirectoryError, got: %v", err)
+	}
+	if copyErr.Copied != 3 || copyErr.Failed != 2 {
+		t.Errorf("unexpected progress: copied %d, failed %d", copyErr.Copied, copyErr.Failed)
+	}
+	if len(copyErr.Errs) != 2 {
+		t.Fatalf("expected 2 errors, got %d", len(copyErr.Errs))
+	}
+	// Errors are ordered by path regardless of the order the copies finished in.
+	for i, name := range []string{"m-broken", "z-broken"} {
+		if !strings.HasPrefix(copyErr.Errs[i].Error(), filepath.Join(source, name)) {
+			t.Errorf("unexpected error at index %d: %v", i, copyErr.Errs[i])
+		}
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected wrapped errors to be inspectable, got: %v", err)
+	}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if _, err := os.Stat(filepath.Join(destination, name)); err != nil {
+			t.Errorf("expected %s to be copied: %v", name, err)
+		}
+	}
+}
+
+// TestFileGatherer_copyDirectory_ContextCancelled tests that a cancelled context stops the copy
+func TestFileGatherer_copyDirectory_ContextCancelled(t *testing.T) {
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	gatherer := &FileGatherer{}
+	_, err := gatherer.copyDirectory(ctx, source, t.TempDir(), nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got: %v", err)
+	}
+}
+
 // TestFileGatherer_getFileSha tests the getFileSha method
 func TestFileGatherer_getFileSha(t *testing.T) {
 	// Create a temporary directory for testing
@@ -371,3 +676,122 @@ func TestPinnedUrlRoundtrip(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestFileGatherer_GatherFrom_File tests gathering a single file from an fs.FS
+func TestFileGatherer_GatherFrom_File(t *testing.T) {
+	fsys := fstest.MapFS{
+		"policy/main.rego": &fstest.MapFile{Data: []byte("package main")},
+	}
+
+	gatherer := &FileGatherer{}
+	destination := filepath.Join(t.TempDir(), "main.rego")
+	m, err := gatherer.GatherFrom(context.Background(), fsys, "policy/main.rego", destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(destination)
+	if err != nil {
+		t.Fatalf("destination file does not exist: %v", err)
+	}
+	if string(content) != "package main" {
+		t.Errorf("unexpected content: got %q, want %q", content, "package main")
+	}
+	if m.Get()["sha"] == "" {
+		t.Error("expected sha to be non-empty, but got empty")
+	}
+}
+
+// TestFileGatherer_GatherFrom_Directory tests gathering a directory tree from an fs.FS
+func TestFileGatherer_GatherFrom_Directory(t *testing.T) {
+	fsys := fstest.MapFS{
+		"policy/main.rego":       &fstest.MapFile{Data: []byte("package main")},
+		"policy/lib/helper.rego": &fstest.MapFile{Data: []byte("package lib")},
+		"other/ignored.txt":      &fstest.MapFile{Data: []byte("ignored")},
+	}
+
+	gatherer := &FileGatherer{}
+	destination := filepath.Join(t.TempDir(), "destination")
+	m, err := gatherer.GatherFrom(context.Background(), fsys, "policy", "file://"+destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, name := range []string{"main.rego", filepath.Join("lib", "helper.rego")} {
+		if _, err := os.Stat(filepath.Join(destination, name)); err != nil {
+			t.Errorf("destination file %s does not exist: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(destination, "ignored.txt")); err == nil {
+		t.Error("expected file outside of root not to be copied")
+	}
+	if got, expected := m.Get()["size"], int64(len("package main")+len("package lib")); got != expected {
+		t.Errorf("unexpected size: got %v, want %v", got, expected)
+	}
+}
+
+// TestFileGatherer_GatherFrom_Error tests the error handling of GatherFrom
+func TestFileGatherer_GatherFrom_Error(t *testing.T) {
+	gatherer := &FileGatherer{}
+
+	_, err := gatherer.GatherFrom(context.Background(), nil, ".", t.TempDir())
+	if err == nil || err.Error() != "source filesystem is nil" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = gatherer.GatherFrom(context.Background(), fstest.MapFS{}, "missing", t.TempDir())
+	if err == nil {
+		t.Error("expected an error, but got nil")
+	}
+}
+
+// TestFileGatherer_Resolve tests describing files and directories without copying them
+func TestFileGatherer_Resolve(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.rego"), []byte("package main"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	gatherer := &FileGatherer{}
+
+	m, err := gatherer.Resolve(context.Background(), filepath.Join(dir, "main.rego"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fm, ok := m.(*file.FileMetadata); !ok || fm.SHA != "512843855fcc92a51c810b1b58e0731c01eac9a6a23c157bfa02aad71edffbe7" || fm.Size != int64(len("package main")) || fm.Destination != "" {
+		t.Errorf("unexpected metadata: %+v", m)
+	}
+
+	m, err = gatherer.Resolve(context.Background(), "file::"+dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	treeHash, err := metadata.TreeHash(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dm, ok := m.(*file.DirectoryMetadata); !ok || dm.TreeHash != treeHash {
+		t.Errorf("unexpected metadata: %+v", m)
+	}
+
+	if _, err := gatherer.Resolve(context.Background(), filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error, but got nil")
+	}
+}
+
+// TestFileGatherer_Validate tests checking that files and directories exist
+func TestFileGatherer_Validate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.rego"), []byte("package main"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	gatherer := &FileGatherer{}
+
+	for _, source := range []string{filepath.Join(dir, "main.rego"), "file::" + dir} {
+		if err := gatherer.Validate(context.Background(), source); err != nil {
+			t.Errorf("%s: unexpected error: %v", source, err)
+		}
+	}
+	if err := gatherer.Validate(context.Background(), filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error, but got nil")
+	}
+}
@@ -8,6 +8,11 @@ require (
 	github.com/enterprise-contract/go-gather/metadata v0.0.3-0.20241015082844-9df651247f12
 	github.com/enterprise-contract/go-gather/metadata/file v0.0.2-0.20241015082844-9df651247f12
 	github.com/enterprise-contract/go-gather/saver v0.0.2
+	github.com/fsnotify/fsnotify v1.7.0
+	golang.org/x/sync v0.7.0
 )
 
-require github.com/enterprise-contract/go-gather/saver/file v0.0.1 // indirect
+require (
+	github.com/enterprise-contract/go-gather/saver/file v0.0.1 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+)
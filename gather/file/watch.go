@@ -0,0 +1,173 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	utils "github.com/enterprise-contract/go-gather"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watch gathers the source to the destination and then keeps the destination in sync
+// with the source until the context is cancelled. Files and directories created, written,
+// removed or renamed in the source are mirrored to the destination as they change.
+// This is intended for local development loops, e.g. editing policies while a tool
+// consumes the gathered copy.
+//
+// Watch blocks until the context is cancelled, in which case it returns nil, or until
+// the initial gather or the underlying watcher fails.
+func (f *FileGatherer) Watch(ctx context.Context, source, destination string) error {
+	src, err := utils.LocalPath(source)
+	if err != nil {
+		return fmt.Errorf("failed to parse source URI: %w", err)
+	}
+	dst, err := utils.LocalPath(destination)
+	if err != nil {
+		return fmt.Errorf("failed to parse destination URI: %w", err)
+	}
+
+	if _, err := f.Gather(ctx, source, destination); err != nil {
+		return fmt.Errorf("failed to perform initial gather: %w", err)
+	}
+
+	sourceKind, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("failed to determine source kind: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if sourceKind.IsDir() {
+		err = watchTree(watcher, src)
+	} else {
+		// Editors commonly replace files rather than writing them in place, which drops
+		// a watch on the file itself. Watching the parent directory survives that.
+		err = watcher.Add(filepath.Dir(src))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to watch source: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("failed to watch source: %w", err)
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if sourceKind.IsDir() {
+				err = f.syncDirectoryEvent(ctx, watcher, event, src, dst)
+			} else if filepath.Clean(event.Name) == filepath.Clean(src) {
+				err = f.syncFileEvent(ctx, event, source, destination)
+			}
+			if err != nil {
+				utils.Logger(ctx, f.Logger).Warn("failed to sync watched source", "path", event.Name, "error", err)
+			}
+		}
+	}
+}
+
+// syncFileEvent mirrors a change to a single source file to the destination.
+func (f *FileGatherer) syncFileEvent(ctx context.Context, event fsnotify.Event, source, destination string) error {
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		dst, err := utils.LocalPath(destination)
+		if err != nil {
+			return fmt.Errorf("failed to parse destination URI: %w", err)
+		}
+		return removeIfExists(dst)
+	}
+	if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) {
+		_, err := f.Gather(ctx, source, destination)
+		return err
+	}
+	return nil
+}
+
+// syncDirectoryEvent mirrors a change within the source directory tree to the destination tree.
+func (f *FileGatherer) syncDirectoryEvent(ctx context.Context, watcher *fsnotify.Watcher, event fsnotify.Event, source, destination string) error {
+	relPath, err := filepath.Rel(source, event.Name)
+	if err != nil {
+		return fmt.Errorf("failed to get relative path: %w", err)
+	}
+	destPath := filepath.Join(destination, relPath)
+
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		return removeIfExists(destPath)
+	}
+	if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+		return nil
+	}
+
+	info, err := os.Lstat(event.Name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// The entry was removed again before we got to it.
+			return nil
+		}
+		return fmt.Errorf("failed to stat %s: %w", event.Name, err)
+	}
+
+	if info.IsDir() {
+		// New directories need watching too, and may already contain entries
+		// that were created before the watch was in place.
+		if err := watchTree(watcher, event.Name); err != nil {
+			return err
+		}
+		_, err := f.copyDirectory(ctx, event.Name, destPath, nil)
+		return err
+	}
+	return copyToDestination(ctx, event.Name, destPath, nil)
+}
+
+// watchTree adds a watch for root and every directory below it.
+func watchTree(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if err := watcher.Add(path); err != nil {
+				return fmt.Errorf("failed to watch %s: %w", path, err)
+			}
+		}
+		return nil
+	})
+}
+
+// removeIfExists removes path and any children, ignoring paths that do not exist.
+func removeIfExists(path string) error {
+	if err := os.RemoveAll(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitFor polls until condition returns true or the timeout expires.
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func fileContains(path, content string) func() bool {
+	return func() bool {
+		b, err := os.ReadFile(path)
+		return err == nil && string(b) == content
+	}
+}
+
+func fileMissing(path string) func() bool {
+	return func() bool {
+		_, err := os.Stat(path)
+		return os.IsNotExist(err)
+	}
+}
+
+// TestFileGatherer_Watch_Directory tests that changes to a source directory are mirrored to the destination
+func TestFileGatherer_Watch_Directory(t *testing.T) {
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "existing.txt"), []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	destination := filepath.Join(t.TempDir(), "destination")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	gatherer := &FileGatherer{}
+	go func() {
+		done <- gatherer.Watch(ctx, source, destination)
+	}()
+
+	waitFor(t, "initial gather", fileContains(filepath.Join(destination, "existing.txt"), "existing"))
+
+	// Modify an existing file
+	if err := os.WriteFile(filepath.Join(source, "existing.txt"), []byte("modified"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "modified file", fileContains(filepath.Join(destination, "existing.txt"), "modified"))
+
+	// Create a new directory with a file inside it
+	if err := os.MkdirAll(filepath.Join(source, "nested"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "nested", "new.txt"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "new nested file", fileContains(filepath.Join(destination, "nested", "new.txt"), "new"))
+
+	// Remove a file
+	if err := os.Remove(filepath.Join(source, "existing.txt")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "removed file", fileMissing(filepath.Join(destination, "existing.txt")))
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch did not return after the context was cancelled")
+	}
+}
+
+// TestFileGatherer_Watch_File tests that changes to a single source file are mirrored to the destination
+func TestFileGatherer_Watch_File(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "source.txt")
+	if err := os.WriteFile(source, []byte("one"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A sibling of the source must not be synced.
+	sibling := filepath.Join(filepath.Dir(source), "sibling.txt")
+	destination := filepath.Join(t.TempDir(), "destination.txt")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gatherer := &FileGatherer{}
+	go func() {
+		// Forced sources are local paths too.
+		_ = gatherer.Watch(ctx, "file::"+source, destination)
+	}()
+
+	waitFor(t, "initial gather", fileContains(destination, "one"))
+
+	if err := os.WriteFile(sibling, []byte("sibling"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(source, []byte("two"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "modified file", fileContains(destination, "two"))
+
+	if err := os.Remove(source); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "removed file", fileMissing(destination))
+}
+
+// TestFileGatherer_Watch_Error tests that Watch fails when the initial gather fails
+func TestFileGatherer_Watch_Error(t *testing.T) {
+	gatherer := &FileGatherer{}
+	err := gatherer.Watch(context.Background(), "nonexistent_file", t.TempDir())
+	if err == nil {
+		t.Error("expected an error, but got nil")
+	}
+}
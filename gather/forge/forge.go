@@ -0,0 +1,439 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package forge provides functionality for gathering snapshots of git repositories hosted on
+// GitHub, GitLab or Bitbucket. It includes an implementation of the Gatherer interface,
+// ForgeGatherer, which resolves a ref of the repository to its commit with the API of the forge,
+// downloads the archive of the commit and expands it into the destination directory. Unlike
+// cloning the repository, this only transfers the files of a single commit, which is much faster
+// for large repositories.
+//
+// Sources are of the form forge::https://github.com/org/repo?ref=v1.0.0, naming the repository
+// like its clone URL. Without the ref parameter, the default branch of the repository is
+// gathered. The forge is inferred from the hosts github.com, gitlab.com and bitbucket.org; the
+// provider parameter names it for other hosts, e.g. provider=gitlab for a self-hosted GitLab
+// instance.
+//
+// Example usage:
+//
+//	g := &forge.ForgeGatherer{}
+//	m, err := g.Gather(context.Background(), "forge::https://github.com/org/policies?ref=v1.0.0", "/tmp/policies")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/expander"
+	"github.com/enterprise-contract/go-gather/metadata"
+	gitMetadata "github.com/enterprise-contract/go-gather/metadata/git"
+)
+
+// The forges whose archive APIs are supported, as named by the provider parameter of sources.
+const (
+	GitHub    = "github"
+	GitLab    = "gitlab"
+	Bitbucket = "bitbucket"
+)
+
+// ForgeGatherer downloads snapshots of repositories with the archive APIs of GitHub, GitLab and
+// Bitbucket. The zero value sends anonymous requests, unless credentials are found for the host
+// of the repository or in the GITHUB_TOKEN, GITLAB_TOKEN or BITBUCKET_TOKEN environment variable
+// of the forge.
+type ForgeGatherer struct {
+	// Token authenticates the requests to the forge, e.g. to download the archives of private
+	// repositories. If empty, the credentials the gather options provide for the host of the
+	// repository are used, and then the environment variable of the forge. Bitbucket
+	// credentials with a username are sent as basic authentication, e.g. for app passwords.
+	Token string
+	// Client sends the requests. It is configured for the gather options, see
+	// gogather.HTTPClient.
+	Client http.Client
+	// ExpanderOptions configures the expander of the archives, e.g. to limit the number of files
+	// they may contain.
+	ExpanderOptions []expander.Option
+	// Logger receives diagnostic messages. When nil, the logger of the gather options is used.
+	Logger *slog.Logger
+}
+
+// repository is the subset of a repository, or project, of the forge APIs that is used.
+type repository struct {
+	// DefaultBranch is set by GitHub and GitLab.
+	DefaultBranch string `json:"default_branch"`
+	// MainBranch is set by Bitbucket.
+	MainBranch struct {
+		Name string `json:"name"`
+	} `json:"mainbranch"`
+}
+
+// commit is the subset of a commit of the forge APIs that is used.
+type commit struct {
+	// SHA is set by GitHub.
+	SHA string `json:"sha"`
+	// ID is set by GitLab.
+	ID string `json:"id"`
+	// Hash is set by Bitbucket.
+	Hash string `json:"hash"`
+}
+
+// Gather resolves the ref of the repository of source to its commit, downloads the archive of the
+// commit and expands it into the destination directory, stripping the directory the archives of
+// all three forges hold. The Include and Exclude patterns of the gather options select the files
+// to expand. The returned GitMetadata records the commit, so that GetPinnedURL pins source.
+func (g *ForgeGatherer) Gather(ctx context.Context, source, destination string) (_ metadata.Metadata, err error) {
+	defer func() {
+		err = gogather.RedactError(err)
+		gogather.FinishProgress(ctx, err)
+	}()
+	startedAt := time.Now()
+
+	loc, err := parseSource(source)
+	if err != nil {
+		return nil, err
+	}
+	digests := gogather.NewDigests()
+	e, err := expander.NewExpander("tar.gz", g.expanderOptions(ctx, digests)...)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := loc.ref
+	if ref == "" {
+		if ref, err = g.defaultBranch(ctx, loc); err != nil {
+			return nil, err
+		}
+	}
+	sha, err := g.resolve(ctx, loc, ref)
+	if err != nil {
+		return nil, err
+	}
+	m := &gitMetadata.GitMetadata{LatestCommit: sha}
+
+	tmp, err := gogather.MkdirTemp(ctx, "forge-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	archive := filepath.Join(tmp, sha+".tar.gz")
+	if err := g.download(ctx, loc, sha, archive); err != nil {
+		return nil, err
+	}
+
+	if err := e.Expand(destination, archive, true, 0755); err != nil {
+		return nil, fmt.Errorf("failed to expand archive of %s: %w", loc.repo, err)
+	}
+	if err := gogather.CountWrittenDir(ctx, destination); err != nil {
+		return nil, err
+	}
+	if m.TreeHash, err = metadata.TreeHashWithDigests(destination, digests.SHA256); err != nil {
+		return nil, err
+	}
+	if err := gogather.VerifyChecksumDigest(ctx, destination, "sha256", m.TreeHash); err != nil {
+		return nil, err
+	}
+
+	resolved, _ := m.GetPinnedURL(gogather.RedactURL(source))
+	m.Common = metadata.NewCommon(gitMetadata.Type, gogather.RedactURL(source), resolved, destination, startedAt)
+	return m, nil
+}
+
+// expanderOptions returns the ExpanderOptions extended with the filters and the size limit of the
+// gather options carried by ctx, recording the digests of the expanded files in digests. The
+// directory the archives hold, named after the repository and the commit, is stripped.
+func (g *ForgeGatherer) expanderOptions(ctx context.Context, digests *gogather.Digests) []expander.Option {
+	o := gogather.OptionsFromContext(ctx)
+	return append(slices.Clone(g.ExpanderOptions), func(c *expander.Config) {
+		c.Options.Digests = digests
+		c.Options.Include = append(c.Options.Include, o.Include...)
+		c.Options.Exclude = append(c.Options.Exclude, o.Exclude...)
+		c.Options.FlattenSingleRoot = true
+		if o.MaxSize > 0 && (c.FileSizeLimit <= 0 || c.FileSizeLimit > o.MaxSize) {
+			c.FileSizeLimit = o.MaxSize
+		}
+	})
+}
+
+// defaultBranch returns the name of the default branch of the repository of loc.
+func (g *ForgeGatherer) defaultBranch(ctx context.Context, loc *location) (string, error) {
+	gogather.Logger(ctx, g.Logger).Debug("looking up default branch", "provider", loc.provider, "repository", loc.repo)
+	r := &repository{}
+	if err := g.getJSON(ctx, loc, loc.repositoryURL(), r); err != nil {
+		return "", fmt.Errorf("failed to look up repository %s: %w", loc.repo, err)
+	}
+	branch := r.DefaultBranch
+	if loc.provider == Bitbucket {
+		branch = r.MainBranch.Name
+	}
+	if branch == "" {
+		return "", fmt.Errorf("repository %s has no default branch", loc.repo)
+	}
+	return branch, nil
+}
+
+// resolve returns the commit ref of the repository of loc points to.
+func (g *ForgeGatherer) resolve(ctx context.Context, loc *location, ref string) (string, error) {
+	gogather.Logger(ctx, g.Logger).Debug("resolving ref", "provider", loc.provider, "repository", loc.repo, "ref", ref)
+	c := &commit{}
+	if err := g.getJSON(ctx, loc, loc.commitURL(ref), c); err != nil {
+		return "", fmt.Errorf("failed to resolve ref %s of %s: %w", ref, loc.repo, err)
+	}
+	sha := map[string]string{GitHub: c.SHA, GitLab: c.ID, Bitbucket: c.Hash}[loc.provider]
+	if sha == "" {
+		return "", fmt.Errorf("failed to resolve ref %s of %s: no commit in the response", ref, loc.repo)
+	}
+	return sha, nil
+}
+
+// getJSON decodes the JSON document at u into v.
+func (g *ForgeGatherer) getJSON(ctx context.Context, loc *location, u string, v any) error {
+	resp, err := g.get(ctx, loc, u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errors.New("not found")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &gogather.StatusError{StatusCode: resp.StatusCode}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// download writes the tar.gz archive of the commit sha of the repository of loc to path.
+func (g *ForgeGatherer) download(ctx context.Context, loc *location, sha, path string) error {
+	u := loc.archiveURL(sha)
+	gogather.Logger(ctx, g.Logger).Debug("downloading archive", "url", gogather.RedactURL(u), "destination", path)
+	resp, err := g.get(ctx, loc, u)
+	if err != nil {
+		return fmt.Errorf("failed to download archive of %s: %w", loc.repo, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download archive of %s: %w", loc.repo, &gogather.StatusError{StatusCode: resp.StatusCode})
+	}
+	// Only the expanded files count as written, see gogather.CountWrittenDir.
+	gogather.StartProgress(ctx, -1, -1)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	_, err = gogather.CopySized(f, resp.Body, resp.ContentLength)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download archive of %s: %w", loc.repo, err)
+	}
+	return nil
+}
+
+// get sends a GET request for u. Requests to the host of the repository of loc and to the host of
+// its API are authenticated with the credentials of the gatherer; archives may be served from other
+// hosts, e.g. codeload.github.com, which do not receive them. The client is configured for the
+// gather options, see gogather.HTTPClient.
+func (g *ForgeGatherer) get(ctx context.Context, loc *location, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if err := gogather.CheckHost(ctx, req.URL.Scheme, req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Go-Gather")
+	if loc.provider == GitHub {
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	}
+	if api, err := url.Parse(loc.api); err == nil && (req.URL.Host == api.Host || req.URL.Host == loc.host) {
+		if err := g.authenticate(ctx, loc, req); err != nil {
+			return nil, err
+		}
+	}
+
+	client, err := gogather.HTTPClient(ctx, &g.Client)
+	if err != nil {
+		return nil, err
+	}
+	checkRedirect := client.CheckRedirect
+	client.CheckRedirect = func(r *http.Request, via []*http.Request) error {
+		// The client only drops the header on redirects to other domains, not to other ports.
+		if r.URL.Host != via[0].URL.Host {
+			r.Header.Del("Authorization")
+		}
+		return checkRedirect(r, via)
+	}
+	return client.Do(req)
+}
+
+// authenticate sets the Authorization header of req: the Token of the gatherer, the credentials the
+// gather options provide for the host of the repository of loc, or the token of the environment
+// variable of the forge, e.g. GITHUB_TOKEN. The header is dropped on redirects to other hosts.
+func (g *ForgeGatherer) authenticate(ctx context.Context, loc *location, req *http.Request) error {
+	if g.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.Token)
+		return nil
+	}
+	creds, err := gogather.OptionsFromContext(ctx).Credentials(ctx, loc.hostname)
+	if err != nil {
+		return err
+	}
+	switch {
+	case creds != nil && loc.provider == Bitbucket && creds.Username != "":
+		req.SetBasicAuth(creds.Username, creds.Password)
+	case creds != nil:
+		req.Header.Set("Authorization", "Bearer "+creds.Password)
+	default:
+		if token := os.Getenv(strings.ToUpper(loc.provider) + "_TOKEN"); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	return nil
+}
+
+// location identifies a repository on a forge.
+type location struct {
+	// provider is the forge, one of GitHub, GitLab or Bitbucket.
+	provider string
+	// scheme, host and hostname are those of the URL of the repository.
+	scheme, host, hostname string
+	// repo is the path of the repository, e.g. "org/repo", or "group/subgroup/project" on GitLab.
+	repo string
+	// ref is the ref to gather, empty for the default branch.
+	ref string
+	// api is the URL of the REST API of the forge.
+	api string
+}
+
+// repositoryURL returns the API URL of the repository.
+func (l *location) repositoryURL() string {
+	switch l.provider {
+	case GitHub:
+		return fmt.Sprintf("%s/repos/%s", l.api, l.repo)
+	case GitLab:
+		return fmt.Sprintf("%s/projects/%s", l.api, url.PathEscape(l.repo))
+	default:
+		return fmt.Sprintf("%s/repositories/%s", l.api, l.repo)
+	}
+}
+
+// commitURL returns the API URL of the commit ref points to.
+func (l *location) commitURL(ref string) string {
+	switch l.provider {
+	case GitHub:
+		return fmt.Sprintf("%s/commits/%s", l.repositoryURL(), url.PathEscape(ref))
+	case GitLab:
+		return fmt.Sprintf("%s/repository/commits/%s", l.repositoryURL(), url.PathEscape(ref))
+	default:
+		return fmt.Sprintf("%s/commit/%s", l.repositoryURL(), url.PathEscape(ref))
+	}
+}
+
+// archiveURL returns the URL of the tar.gz archive of the commit sha.
+func (l *location) archiveURL(sha string) string {
+	switch l.provider {
+	case GitHub:
+		return fmt.Sprintf("%s/tarball/%s", l.repositoryURL(), sha)
+	case GitLab:
+		return fmt.Sprintf("%s/repository/archive.tar.gz?sha=%s", l.repositoryURL(), sha)
+	default:
+		// Bitbucket serves archives from the web interface rather than the API.
+		return fmt.Sprintf("%s://%s/%s/get/%s.tar.gz", l.scheme, l.host, l.repo, sha)
+	}
+}
+
+// parseSource parses a source of the form forge::https://host/org/repo?ref=ref&provider=provider.
+// The ".git" suffix of the repository is ignored, and the provider is inferred from the host if
+// the parameter is not set.
+func parseSource(source string) (*location, error) {
+	u, err := url.Parse(strings.TrimPrefix(source, "forge::"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source %s: %w", source, err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("unsupported forge source: %s", source)
+	}
+	q := u.Query()
+	for k := range q {
+		if k != "ref" && k != "provider" {
+			return nil, fmt.Errorf("unsupported parameter %s of %s", k, source)
+		}
+	}
+	loc := &location{
+		provider: q.Get("provider"),
+		scheme:   u.Scheme,
+		host:     u.Host,
+		hostname: u.Hostname(),
+		repo:     strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git"),
+		ref:      q.Get("ref"),
+	}
+	if loc.provider == "" {
+		switch loc.hostname {
+		case "github.com":
+			loc.provider = GitHub
+		case "gitlab.com":
+			loc.provider = GitLab
+		case "bitbucket.org":
+			loc.provider = Bitbucket
+		default:
+			return nil, fmt.Errorf("unable to infer the forge of %s, set the provider parameter", source)
+		}
+	}
+
+	parts := strings.Split(loc.repo, "/")
+	invalid := slices.Contains(parts, "") || u.Host == "" || u.User != nil
+	switch loc.provider {
+	case GitHub:
+		loc.api = fmt.Sprintf("%s://%s/api/v3", u.Scheme, u.Host)
+		if loc.hostname == "github.com" {
+			loc.api = "https://api.github.com"
+		}
+		invalid = invalid || len(parts) != 2
+	case GitLab:
+		loc.api = fmt.Sprintf("%s://%s/api/v4", u.Scheme, u.Host)
+		invalid = invalid || len(parts) < 2
+	case Bitbucket:
+		loc.api = fmt.Sprintf("%s://%s/!api/2.0", u.Scheme, u.Host)
+		if loc.hostname == "bitbucket.org" {
+			loc.api = "https://api.bitbucket.org/2.0"
+		}
+		invalid = invalid || len(parts) != 2
+	default:
+		return nil, fmt.Errorf("unsupported provider %s of %s", loc.provider, source)
+	}
+	if invalid {
+		return nil, fmt.Errorf("source must be of the form forge::https://host/org/repo: %s", source)
+	}
+	return loc, nil
+}
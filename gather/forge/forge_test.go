@@ -0,0 +1,310 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package forge
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	gitMetadata "github.com/enterprise-contract/go-gather/metadata/git"
+)
+
+const (
+	mainCommit = "1111111111111111111111111111111111111111"
+	tagCommit  = "2222222222222222222222222222222222222222"
+)
+
+// newArchive returns a gzip compressed tar archive of the commit, holding its files below a
+// directory named after the repository and the commit, like the archives of the forges.
+func newArchive(t *testing.T, commit string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	root := "repo-" + commit[:7] + "/"
+	files := [][2]string{
+		{root + "README.md", "commit " + commit + "\n"},
+		{root + "policy/main.rego", "package main\n"},
+	}
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f[0], Mode: 0644, Size: int64(len(f[1])), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newServer starts a fake forge serving the APIs of GitHub, for org/repo, GitLab, for
+// group/sub/project, and Bitbucket, for ws/repo. The default branch of every repository points to
+// mainCommit and the tag v1 to tagCommit. Requests must be authenticated with the token secret, or
+// as reader with the password secret on Bitbucket. GitHub redirects the downloads of archives to a
+// separate storage server, which rejects requests carrying credentials.
+func newServer(t *testing.T) *httptest.Server {
+	archives := map[string][]byte{mainCommit: newArchive(t, mainCommit), tagCommit: newArchive(t, tagCommit)}
+	commits := map[string]string{"main": mainCommit, "trunk": mainCommit, "v1": tagCommit}
+
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if a, ok := archives[strings.TrimPrefix(r.URL.Path, "/")]; ok {
+			w.Write(a)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(storage.Close)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := r.URL.EscapedPath()
+		if user, password, ok := r.BasicAuth(); strings.HasPrefix(p, "/ws/") || strings.HasPrefix(p, "/!api/") {
+			if !ok || user != "reader" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		} else if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var ref string
+		switch {
+		case p == "/api/v3/repos/org/repo", p == "/api/v4/projects/group%2Fsub%2Fproject":
+			w.Write([]byte(`{"default_branch": "main"}`))
+			return
+		case p == "/!api/2.0/repositories/ws/repo":
+			w.Write([]byte(`{"mainbranch": {"name": "trunk"}}`))
+			return
+		case strings.HasPrefix(p, "/api/v3/repos/org/repo/commits/"):
+			ref = strings.TrimPrefix(p, "/api/v3/repos/org/repo/commits/")
+			if c, ok := commits[ref]; ok {
+				w.Write([]byte(`{"sha": "` + c + `"}`))
+				return
+			}
+		case strings.HasPrefix(p, "/api/v4/projects/group%2Fsub%2Fproject/repository/commits/"):
+			ref = strings.TrimPrefix(p, "/api/v4/projects/group%2Fsub%2Fproject/repository/commits/")
+			if c, ok := commits[ref]; ok {
+				w.Write([]byte(`{"id": "` + c + `"}`))
+				return
+			}
+		case strings.HasPrefix(p, "/!api/2.0/repositories/ws/repo/commit/"):
+			ref = strings.TrimPrefix(p, "/!api/2.0/repositories/ws/repo/commit/")
+			if c, ok := commits[ref]; ok {
+				w.Write([]byte(`{"hash": "` + c + `"}`))
+				return
+			}
+		case strings.HasPrefix(p, "/api/v3/repos/org/repo/tarball/"):
+			http.Redirect(w, r, storage.URL+"/"+strings.TrimPrefix(p, "/api/v3/repos/org/repo/tarball/"), http.StatusFound)
+			return
+		case p == "/api/v4/projects/group%2Fsub%2Fproject/repository/archive.tar.gz":
+			if a, ok := archives[r.URL.Query().Get("sha")]; ok {
+				w.Write(a)
+				return
+			}
+		case strings.HasPrefix(p, "/ws/repo/get/"):
+			if a, ok := archives[strings.TrimSuffix(strings.TrimPrefix(p, "/ws/repo/get/"), ".tar.gz")]; ok {
+				w.Write(a)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// withAuth returns a context carrying gather options with the credentials of the fake forge.
+func withAuth(o gogather.GatherOptions) context.Context {
+	o.Auth = gogather.HostCredentials{"127.0.0.1": {Username: "reader", Password: "secret"}}
+	return gogather.ContextWithOptions(context.Background(), o)
+}
+
+// TestForgeGatherer_Gather tests gathering snapshots of the repositories of the different forges
+func TestForgeGatherer_Gather(t *testing.T) {
+	srv := newServer(t)
+
+	tests := []struct {
+		name     string
+		source   string
+		commit   string
+		resolved string
+	}{
+		{
+			name:     "github tag",
+			source:   "forge::" + srv.URL + "/org/repo.git?provider=github&ref=v1",
+			commit:   tagCommit,
+			resolved: "forge::" + srv.URL + "/org/repo.git?provider=github&ref=" + tagCommit,
+		},
+		{
+			name:     "github default branch",
+			source:   "forge::" + srv.URL + "/org/repo?provider=github",
+			commit:   mainCommit,
+			resolved: "forge::" + srv.URL + "/org/repo?provider=github&ref=" + mainCommit,
+		},
+		{
+			name:     "gitlab subgroup",
+			source:   "forge::" + srv.URL + "/group/sub/project?ref=v1&provider=gitlab",
+			commit:   tagCommit,
+			resolved: "forge::" + srv.URL + "/group/sub/project?provider=gitlab&ref=" + tagCommit,
+		},
+		{
+			name:     "bitbucket main branch",
+			source:   "forge::" + srv.URL + "/ws/repo?provider=bitbucket",
+			commit:   mainCommit,
+			resolved: "forge::" + srv.URL + "/ws/repo?provider=bitbucket&ref=" + mainCommit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			destination := filepath.Join(t.TempDir(), "repo")
+			m, err := (&ForgeGatherer{}).Gather(withAuth(gogather.GatherOptions{}), tt.source, destination)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if data, err := os.ReadFile(filepath.Join(destination, "README.md")); err != nil || string(data) != "commit "+tt.commit+"\n" {
+				t.Fatalf("unexpected content: %q, %v", data, err)
+			}
+			if _, err := os.Stat(filepath.Join(destination, "policy", "main.rego")); err != nil {
+				t.Errorf("expected the policy to be expanded: %v", err)
+			}
+
+			gm, ok := m.(*gitMetadata.GitMetadata)
+			if !ok {
+				t.Fatalf("unexpected metadata type: %T", m)
+			}
+			if gm.LatestCommit != tt.commit || gm.TreeHash == "" {
+				t.Errorf("unexpected metadata: %+v", gm)
+			}
+			if gm.SourceURI != tt.source || gm.ResolvedURI != tt.resolved || gm.Destination != destination {
+				t.Errorf("unexpected common metadata: %+v", gm.Common)
+			}
+		})
+	}
+}
+
+// TestForgeGatherer_Gather_Token tests authenticating with the token of the gatherer
+func TestForgeGatherer_Gather_Token(t *testing.T) {
+	srv := newServer(t)
+	source := "forge::" + srv.URL + "/org/repo?provider=github&ref=v1"
+
+	var status *gogather.StatusError
+	if _, err := (&ForgeGatherer{}).Gather(context.Background(), source, t.TempDir()); !errors.As(err, &status) || status.StatusCode != http.StatusUnauthorized || status.Retryable() {
+		t.Fatalf("expected a status error, got %v", err)
+	}
+	if _, err := (&ForgeGatherer{Token: "secret"}).Gather(context.Background(), source, t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// TestForgeGatherer_Gather_Filter tests selecting the files to expand with the gather options
+func TestForgeGatherer_Gather_Filter(t *testing.T) {
+	srv := newServer(t)
+	destination := t.TempDir()
+
+	if _, err := (&ForgeGatherer{}).Gather(withAuth(gogather.GatherOptions{Include: []string{"**.rego"}}), "forge::"+srv.URL+"/ws/repo?provider=bitbucket", destination); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(destination, "policy", "main.rego")); err != nil {
+		t.Errorf("expected the policy to be expanded: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(destination, "README.md")); !os.IsNotExist(err) {
+		t.Errorf("expected the readme to be excluded: %v", err)
+	}
+}
+
+// TestForgeGatherer_Gather_Errors tests the failures of gathering snapshots
+func TestForgeGatherer_Gather_Errors(t *testing.T) {
+	srv := newServer(t)
+	g := &ForgeGatherer{}
+	source := "forge::" + srv.URL + "/group/sub/project?provider=gitlab"
+
+	if _, err := g.Gather(withAuth(gogather.GatherOptions{}), source+"&ref=missing", t.TempDir()); err == nil || !strings.Contains(err.Error(), "failed to resolve ref missing of group/sub/project: not found") {
+		t.Errorf("expected the ref not to be found, got %v", err)
+	}
+
+	if _, err := g.Gather(withAuth(gogather.GatherOptions{MaxSize: 10}), source, t.TempDir()); !errors.Is(err, gogather.ErrTooLarge) {
+		t.Errorf("expected the archive to be too large, got %v", err)
+	}
+
+	var mismatch *gogather.ChecksumMismatchError
+	if _, err := g.Gather(withAuth(gogather.GatherOptions{Checksum: "sha256:" + strings.Repeat("0", 64)}), source, t.TempDir()); !errors.As(err, &mismatch) {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+
+	var denied *gogather.HostDeniedError
+	if _, err := g.Gather(withAuth(gogather.GatherOptions{HostPolicy: &gogather.HostPolicy{DeniedHosts: []string{"127.0.0.1"}}}), source, t.TempDir()); !errors.As(err, &denied) {
+		t.Errorf("expected the host policy to deny the forge, got %v", err)
+	}
+}
+
+// TestParseSource tests parsing forge sources
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		source   string
+		provider string
+		repo     string
+		ref      string
+		api      string
+		err      string
+	}{
+		{source: "forge::https://github.com/org/repo.git?ref=v1.0.0", provider: GitHub, repo: "org/repo", ref: "v1.0.0", api: "https://api.github.com"},
+		{source: "forge::https://github.example.com/org/repo?provider=github", provider: GitHub, repo: "org/repo", api: "https://github.example.com/api/v3"},
+		{source: "forge::https://gitlab.com/group/sub/project?ref=main", provider: GitLab, repo: "group/sub/project", ref: "main", api: "https://gitlab.com/api/v4"},
+		{source: "forge::https://bitbucket.org/ws/repo", provider: Bitbucket, repo: "ws/repo", api: "https://api.bitbucket.org/2.0"},
+		{source: "forge::https://git.example.com/org/repo", err: "unable to infer the forge of forge::https://git.example.com/org/repo, set the provider parameter"},
+		{source: "forge::https://git.example.com/org/repo?provider=gitea", err: "unsupported provider gitea of forge::https://git.example.com/org/repo?provider=gitea"},
+		{source: "forge::https://github.com/org/repo/tree/main", err: "source must be of the form forge::https://host/org/repo: forge::https://github.com/org/repo/tree/main"},
+		{source: "forge::https://github.com/org/repo?depth=1", err: "unsupported parameter depth of forge::https://github.com/org/repo?depth=1"},
+		{source: "forge::ssh://github.com/org/repo", err: "unsupported forge source: forge::ssh://github.com/org/repo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			l, err := parseSource(tt.source)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if l.provider != tt.provider || l.repo != tt.repo || l.ref != tt.ref || l.api != tt.api {
+				t.Errorf("unexpected location: %+v", l)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/enterprise-contract/go-gather/gather/forge
+
+go 1.22.5
+
+require (
+	github.com/enterprise-contract/go-gather v0.0.3
+	github.com/enterprise-contract/go-gather/expander v0.0.1
+	github.com/enterprise-contract/go-gather/metadata v0.0.2
+	github.com/enterprise-contract/go-gather/metadata/git v0.0.2
+)
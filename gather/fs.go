@@ -0,0 +1,221 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/expander"
+	"github.com/enterprise-contract/go-gather/metadata"
+	fileMetadata "github.com/enterprise-contract/go-gather/metadata/file"
+	gitMetadata "github.com/enterprise-contract/go-gather/metadata/git"
+	httpMetadata "github.com/enterprise-contract/go-gather/metadata/http"
+	ociMetadata "github.com/enterprise-contract/go-gather/metadata/oci"
+)
+
+// FSGatherer is a Gatherer that writes the content gathered by the wrapped Gatherer to a
+// gogather.WriteFS instead of the local disk. The wrapped Gatherer gathers into a temporary
+// directory, whose content is then copied to the destination within FS, a slash separated path,
+// and removed. The destination of the returned metadata is the destination within FS.
+type FSGatherer struct {
+	Gatherer Gatherer
+	FS       gogather.WriteFS
+}
+
+// NewFSGatherer returns an FSGatherer wrapping g and writing to fsys.
+func NewFSGatherer(g Gatherer, fsys gogather.WriteFS) *FSGatherer {
+	return &FSGatherer{Gatherer: g, FS: fsys}
+}
+
+// Gather gathers the source using the wrapped Gatherer and copies what it wrote to FS.
+func (f *FSGatherer) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
+	if !fs.ValidPath(destination) {
+		return nil, fmt.Errorf("invalid destination %s: expected a slash separated path relative to the root of the filesystem", destination)
+	}
+
+	tmp, err := gogather.MkdirTemp(ctx, "go-gather-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	local := filepath.Join(tmp, "content")
+	m, err := f.Gatherer.Gather(ctx, source, local)
+	if err != nil {
+		return nil, err
+	}
+
+	content, _ := m.Get()["destination"].(string)
+	if content == "" {
+		content = local
+	}
+	rel, err := filepath.Rel(local, content)
+	if err != nil || !filepath.IsLocal(rel) && rel != "." {
+		return nil, fmt.Errorf("gathered content %s is outside the destination %s", content, local)
+	}
+	target := path.Join(destination, filepath.ToSlash(rel))
+	e := hookEvent(gogather.BeforeSave, source, destination, m)
+	e.Path = content
+	if err := gogather.RunHooks(ctx, e); err != nil {
+		return nil, err
+	}
+	if err := copyToFS(f.FS, content, target); err != nil {
+		return nil, fmt.Errorf("failed to write gathered content to %s: %w", target, err)
+	}
+	return setDestination(m, target), nil
+}
+
+// ErrDryRunUnsupported is returned by GatherFS for dry runs, which return no content.
+var ErrDryRunUnsupported = errors.New("dry runs are not supported by GatherFS")
+
+// fsContent is the path GatherFS gathers to within its in-memory filesystem.
+const fsContent = "content"
+
+// GatherFS gathers source into memory and returns the gathered content as a read-only filesystem,
+// for consumers that only read the content and would otherwise have to manage a destination
+// directory. A gathered directory is the root of the filesystem, and a gathered file is the only
+// file at its root, named after the last element of the path of the source. The options are those
+// of Gather; the destination of the returned metadata is the path of the content within the
+// filesystem. The content is held in memory, so gathers of large sources should be bounded with
+// WithMaxSize. Dry runs are rejected, as they gather no content, see ErrDryRunUnsupported.
+func GatherFS(ctx context.Context, source string, opts ...Option) (fs.FS, metadata.Metadata, error) {
+	o := gogather.OptionsFromContext(ctx)
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.DryRun {
+		return nil, nil, ErrDryRunUnsupported
+	}
+
+	mem := expander.NewMemFS()
+	m, err := Gather(ctx, source, fsContent, append(slices.Clone(opts), WithFS(mem))...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, _ := m.Get()["destination"].(string)
+	info, err := mem.Stat(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find gathered content: %w", err)
+	}
+	if info.IsDir() {
+		sub, err := fs.Sub(mem, content)
+		if err != nil {
+			return nil, nil, err
+		}
+		return sub, setDestination(m, "."), nil
+	}
+
+	// A file is moved to a filesystem of its own, as the root of a filesystem is a directory.
+	data, err := mem.ReadFile(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	name := fileName(source)
+	file := expander.NewMemFS()
+	if err := file.WriteFile(name, bytes.NewReader(data), info.Mode().Perm(), info.ModTime()); err != nil {
+		return nil, nil, err
+	}
+	return file, setDestination(m, name), nil
+}
+
+// fileName returns the last element of the path of source, or of its subdirectory, as the name of
+// a file gathered from it, or fsContent if it has none, e.g. for standard input.
+func fileName(source string) string {
+	p := source
+	if src, err := gogather.ParseSource(source); err == nil {
+		p = src.BaseURL
+		if src.Subdir != "" {
+			p = src.Subdir
+		} else if u, err := url.Parse(p); err == nil && u.Host != "" {
+			p = u.Path
+		}
+	}
+	name := path.Base(filepath.ToSlash(p))
+	if name == "." || name == "-" || !fs.ValidPath(name) {
+		return fsContent
+	}
+	return name
+}
+
+// copyToFS copies the file or directory src of the local disk to dst within fsys.
+func copyToFS(fsys gogather.WriteFS, src, dst string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		name := path.Join(dst, filepath.ToSlash(rel))
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return fsys.MkdirAll(name, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return fsys.Symlink(target, name)
+		case d.Type().IsRegular():
+			r, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			return fsys.WriteFile(name, r, info.Mode().Perm(), info.ModTime())
+		}
+		return nil
+	})
+}
+
+// setDestination returns m with its destination, and path for files and directories, replaced by
+// destination.
+func setDestination(m metadata.Metadata, destination string) metadata.Metadata {
+	switch m := m.(type) {
+	case *fileMetadata.FileMetadata:
+		m.Destination, m.Path = destination, destination
+	case *fileMetadata.DirectoryMetadata:
+		m.Destination, m.Path = destination, destination
+	case *gitMetadata.GitMetadata:
+		m.Destination = destination
+	case *ociMetadata.OCIMetadata:
+		m.Destination = destination
+	case *httpMetadata.HTTPMetadata:
+		m.Destination = destination
+	case httpMetadata.HTTPMetadata:
+		m.Destination = destination
+		return m
+	}
+	return m
+}
@@ -0,0 +1,163 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	fileMetadata "github.com/enterprise-contract/go-gather/metadata/file"
+)
+
+// memoryFS is a gogather.WriteFS keeping the content of files in memory.
+type memoryFS struct {
+	mu    sync.Mutex
+	files map[string]string
+	dirs  map[string]bool
+}
+
+func newMemoryFS() *memoryFS {
+	return &memoryFS{files: map[string]string{}, dirs: map[string]bool{}}
+}
+
+func (m *memoryFS) MkdirAll(name string, _ fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dirs[name] = true
+	return nil
+}
+
+func (m *memoryFS) WriteFile(name string, r io.Reader, _ fs.FileMode, _ time.Time) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[name] = string(data)
+	return nil
+}
+
+func (m *memoryFS) Symlink(target, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[name] = "-> " + target
+	return nil
+}
+
+// TestGather_WithFS tests gathering directories and files into a filesystem other than the local
+// disk
+func TestGather_WithFS(t *testing.T) {
+	source := writeSourceDir(t)
+	fsys := newMemoryFS()
+
+	m, err := Gather(context.Background(), source, "policy/dir", WithFS(fsys))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d, ok := m.(*fileMetadata.DirectoryMetadata); !ok || d.Destination != "policy/dir" || d.Path != "policy/dir" {
+		t.Errorf("unexpected metadata: %+v", m)
+	}
+	if !fsys.dirs["policy/dir"] {
+		t.Errorf("expected the destination directory to be created, got %v", fsys.dirs)
+	}
+	want := map[string]string{"policy/dir/main.rego": "package main", "policy/dir/README.md": "# Policy"}
+	for name, content := range want {
+		if fsys.files[name] != content {
+			t.Errorf("unexpected content of %s: %q", name, fsys.files[name])
+		}
+	}
+
+	m, err = Gather(context.Background(), filepath.Join(source, "main.rego"), "policy/main.rego", WithFS(fsys))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f, ok := m.(*fileMetadata.FileMetadata); !ok || f.Destination != "policy/main.rego" {
+		t.Errorf("unexpected metadata: %+v", m)
+	}
+	if fsys.files["policy/main.rego"] != "package main" {
+		t.Errorf("unexpected content: %q", fsys.files["policy/main.rego"])
+	}
+}
+
+// TestFSGatherer_InvalidDestination tests that destinations must be paths within the filesystem
+func TestFSGatherer_InvalidDestination(t *testing.T) {
+	g := NewFSGatherer(&digestGatherer{size: 3}, newMemoryFS())
+	if _, err := g.Gather(context.Background(), "a", "/tmp/out"); err == nil {
+		t.Error("expected an error for an absolute destination")
+	}
+}
+
+// TestGatherFS tests gathering directories and files into an in-memory filesystem
+func TestGatherFS(t *testing.T) {
+	source := writeSourceDir(t)
+
+	fsys, m, err := GatherFS(context.Background(), source)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := fstest.TestFS(fsys, "main.rego", "README.md"); err != nil {
+		t.Error(err)
+	}
+	if d, ok := m.(*fileMetadata.DirectoryMetadata); !ok || d.Destination != "." {
+		t.Errorf("unexpected metadata: %+v", m)
+	}
+
+	fsys, m, err = GatherFS(context.Background(), "file::"+filepath.Join(source, "main.rego"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, err := fs.ReadFile(fsys, "main.rego"); err != nil || string(data) != "package main" {
+		t.Errorf("unexpected content: %q, %v", data, err)
+	}
+	if entries, err := fs.ReadDir(fsys, "."); err != nil || len(entries) != 1 {
+		t.Errorf("expected the file to be the only entry: %v, %v", entries, err)
+	}
+	if f, ok := m.(*fileMetadata.FileMetadata); !ok || f.Destination != "main.rego" {
+		t.Errorf("unexpected metadata: %+v", m)
+	}
+
+	if _, _, err := GatherFS(context.Background(), filepath.Join(source, "missing.rego")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a missing source to fail, got %v", err)
+	}
+	if fsys, m, err := GatherFS(context.Background(), source, WithDryRun()); fsys != nil || m != nil || !errors.Is(err, ErrDryRunUnsupported) {
+		t.Errorf("expected a dry run to be rejected, got %v, %v, %v", fsys, m, err)
+	}
+}
+
+// TestFileName tests naming gathered files after their sources
+func TestFileName(t *testing.T) {
+	for source, want := range map[string]string{
+		"https://example.com/policy/data.json?checksum=sha256:abc": "data.json",
+		"git::https://github.com/org/repo.git//policy/main.rego":   "main.rego",
+		"/tmp/bundle.tar.gz":  "bundle.tar.gz",
+		"-":                   "content",
+		"https://example.com": "content",
+	} {
+		if got := fileName(source); got != want {
+			t.Errorf("expected %s for %s, got %s", want, source, got)
+		}
+	}
+}
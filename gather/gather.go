@@ -23,12 +23,27 @@ package gather
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sync"
+	"time"
 
 	gogather "github.com/enterprise-contract/go-gather"
 	"github.com/enterprise-contract/go-gather/gather/file"
+	"github.com/enterprise-contract/go-gather/gather/forge"
+	"github.com/enterprise-contract/go-gather/gather/gdrive"
 	"github.com/enterprise-contract/go-gather/gather/git"
+	"github.com/enterprise-contract/go-gather/gather/github"
+	"github.com/enterprise-contract/go-gather/gather/gitlab"
+	"github.com/enterprise-contract/go-gather/gather/hdfs"
+	"github.com/enterprise-contract/go-gather/gather/helm"
 	"github.com/enterprise-contract/go-gather/gather/http"
+	"github.com/enterprise-contract/go-gather/gather/k8s"
 	"github.com/enterprise-contract/go-gather/gather/oci"
+	"github.com/enterprise-contract/go-gather/gather/rsync"
+	"github.com/enterprise-contract/go-gather/gather/s3"
+	"github.com/enterprise-contract/go-gather/gather/sftp"
+	"github.com/enterprise-contract/go-gather/gather/smb"
+	"github.com/enterprise-contract/go-gather/gather/stdin"
 	"github.com/enterprise-contract/go-gather/metadata"
 )
 
@@ -37,24 +52,165 @@ type Gatherer interface {
 	Gather(ctx context.Context, source, destination string) (metadata metadata.Metadata, err error)
 }
 
+// gathererFunc is a Gatherer implemented by a function.
+type gathererFunc func(ctx context.Context, source, destination string) (metadata.Metadata, error)
+
+func (f gathererFunc) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
+	return f(ctx, source, destination)
+}
+
 // protocolHandlers maps URL schemes to their corresponding Gatherer implementations.
 var protocolHandlers = map[string]Gatherer{
-	"FileURI": &file.FileGatherer{},
-	"GitURI":  &git.GitGatherer{},
-	"HTTPURI": &http.HTTPGatherer{},
-	"OCIURI":  &oci.OCIGatherer{},
+	"FileURI":          &file.FileGatherer{},
+	"GitURI":           &git.GitGatherer{},
+	"HTTPURI":          &http.HTTPGatherer{},
+	"OCIURI":           &oci.OCIGatherer{},
+	"S3URI":            &s3.S3Gatherer{},
+	"SFTPURI":          &sftp.SFTPGatherer{},
+	"GitHubReleaseURI": &github.GitHubReleaseGatherer{},
+	"GitLabURI":        &gitlab.GitLabGatherer{},
+	"GoogleDriveURI":   &gdrive.GoogleDriveGatherer{},
+	"RsyncURI":         &rsync.RsyncGatherer{},
+	"HelmURI":          &helm.HelmGatherer{},
+	"KubernetesURI":    &k8s.KubernetesGatherer{},
+	"StdinURI":         &stdin.StdinGatherer{},
+	"HDFSURI":          &hdfs.HDFSGatherer{},
+	"SMBURI":           &smb.SMBGatherer{},
+	"ForgeURI":         &forge.ForgeGatherer{},
+}
+
+// protocolHandlersMu guards protocolHandlers against concurrent registrations.
+var protocolHandlersMu sync.RWMutex
+
+// RegisterGatherer registers g as the Gatherer of the sources of type t, e.g. to enable the opt-in
+// gatherers that are not registered by default, such as the BitTorrent gatherer of the
+// gather/torrent module, or to replace a built-in gatherer with a configured one.
+func RegisterGatherer(t gogather.URIType, g Gatherer) error {
+	if t == gogather.Unknown {
+		return fmt.Errorf("cannot register a gatherer for unknown sources")
+	}
+	if g == nil {
+		return fmt.Errorf("gatherer for %s is nil", t)
+	}
+
+	protocolHandlersMu.Lock()
+	defer protocolHandlersMu.Unlock()
+	protocolHandlers[t.String()] = g
+	return nil
 }
 
 // Gather determines the protocol from the source URI and uses the appropriate Gatherer to perform the operation.
-// It returns the gathered metadata and an error, if any.
-func Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
-	srcProtocol, err := gogather.ClassifyURI(source)
+// Sources may use the syntax of hashicorp/go-getter, see gogather.ParseSource: a forced protocol
+// prefix, a "//" separated subdirectory to keep, and the archive and checksum query parameters.
+// The options are passed to the Gatherer through the context, see gogather.OptionsFromContext.
+// It returns the gathered metadata and an error, if any, with the credentials of the URLs it quotes
+// redacted, see gogather.RedactError. When the FS option is set, the destination is a path within
+// it, see FSGatherer. A dry run returns no metadata once the source has been
+// classified. Unless the KeepPartial option is set, failed gathers are rolled back, see
+// RollbackGatherer. The Deterministic option normalizes the gathered content, see
+// gogather.Normalize.
+func Gather(ctx context.Context, source, destination string, opts ...Option) (metadata.Metadata, error) {
+	o, src, srcProtocol, gatherer, err := prepare(ctx, source, opts)
+	if err != nil {
+		return nil, err
+	}
+	o.Log().Debug("classified source", "source", gogather.RedactURL(source), "protocol", srcProtocol.String(), "subdir", src.Subdir, "archive", o.Archive, "dryRun", o.DryRun)
+	if o.DryRun {
+		return nil, nil
+	}
+
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+	ctx = gogather.ContextWithOptions(ctx, o)
+	if err := gogather.RunHooks(ctx, hookEvent(gogather.BeforeResolve, source, destination, nil)); err != nil {
+		return nil, err
+	}
+	var g Gatherer = gathererFunc(func(ctx context.Context, source, local string) (metadata.Metadata, error) {
+		m, err := gatherSource(ctx, gatherer, srcProtocol, source, src, local)
+		if err != nil {
+			return m, err
+		}
+		// The content may be staged, so the event names the final destination and the
+		// path of the downloaded content.
+		e := hookEvent(gogather.AfterDownload, source, local, m)
+		e.Destination = destination
+		return m, gogather.RunHooks(ctx, e)
+	})
+	if o.Checksum != "" {
+		g = NewVerifyingGatherer(g)
+	}
+	if o.CacheDir != "" {
+		g = NewCachingGatherer(g, &Cache{Dir: o.CacheDir, MaxSize: o.CacheMaxSize})
+	}
+	if o.FS != nil {
+		// The content is normalized before it is copied to FS, which keeps its times and modes.
+		if o.Deterministic {
+			g = &normalizingGatherer{Gatherer: g}
+		}
+		g = NewFSGatherer(g, o.FS)
+	} else {
+		if len(o.Hooks[gogather.BeforeSave]) > 0 {
+			g = &stagingGatherer{Gatherer: g, Destination: destination}
+		}
+		if o.Deterministic {
+			g = &normalizingGatherer{Gatherer: g}
+		}
+	}
+	if o.FS == nil && !o.KeepPartial {
+		g = NewRollbackGatherer(g)
+	}
+	// The hooks see the destination once the content is in place, or rolled back.
+	if len(o.Hooks[gogather.AfterComplete]) > 0 {
+		g = &completionGatherer{Gatherer: g}
+	}
+	startedAt := time.Now()
+	m, err := g.Gather(ctx, source, destination)
+	if o.Metrics != nil {
+		o.Metrics.ObserveGather(srcProtocol, err, gogather.Written(ctx), time.Since(startedAt))
+	}
+	return m, gogather.RedactError(err)
+}
+
+// prepare applies opts, and then the package-wide defaults, to the gather options carried by ctx,
+// parses source, taking its archive and checksum parameters into the options, and classifies it. It
+// returns the options, the parsed source, its protocol and the Gatherer handling it.
+func prepare(ctx context.Context, source string, opts []Option) (gogather.GatherOptions, *gogather.Source, gogather.URIType, Gatherer, error) {
+	o := gogather.OptionsFromContext(ctx)
+	o.Include = slices.Clone(o.Include)
+	o.Exclude = slices.Clone(o.Exclude)
+	for _, opt := range opts {
+		opt(&o)
+	}
+	config, err := gogather.DefaultConfig()
+	if err != nil {
+		return o, nil, gogather.Unknown, nil, err
+	}
+	o = config.Apply(o)
+
+	src, err := gogather.ParseSource(source)
 	if err != nil {
-		return nil, fmt.Errorf("failed to classify source URI: %w", err)
+		return o, nil, gogather.Unknown, nil, err
+	}
+	if src.Archive != "" {
+		o.Archive = src.Archive
+	}
+	if src.Checksum != "" {
+		o.Checksum = src.Checksum
+	}
+	if o.Checksum != "" {
+		if _, err := gogather.ParseChecksum(o.Checksum); err != nil {
+			return o, nil, gogather.Unknown, nil, err
+		}
 	}
 
-	if gatherer, ok := protocolHandlers[srcProtocol.String()]; ok {
-		return gatherer.Gather(ctx, source, destination)
+	protocolHandlersMu.RLock()
+	gatherer, ok := protocolHandlers[src.Type.String()]
+	protocolHandlersMu.RUnlock()
+	if !ok {
+		return o, nil, gogather.Unknown, nil, fmt.Errorf("unsupported source protocol: %s", src.Type)
 	}
-	return nil, fmt.Errorf("unsupported source protocol: %s", srcProtocol)
+	return o, src, src.Type, gatherer, nil
 }
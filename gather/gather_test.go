@@ -21,6 +21,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	gogather "github.com/enterprise-contract/go-gather"
@@ -114,6 +115,71 @@ func TestGather(t *testing.T) {
 	})
 }
 
+// TestProtocolHandlers tests that every supported protocol is routed to a gatherer
+func TestProtocolHandlers(t *testing.T) {
+	for _, uriType := range []gogather.URIType{gogather.GitURI, gogather.HTTPURI, gogather.FileURI, gogather.OCIURI, gogather.S3URI, gogather.SFTPURI, gogather.GitHubReleaseURI, gogather.GitLabURI, gogather.GoogleDriveURI, gogather.RsyncURI, gogather.HelmURI, gogather.KubernetesURI, gogather.StdinURI, gogather.HDFSURI, gogather.SMBURI, gogather.ForgeURI} {
+		if _, ok := protocolHandlers[uriType.String()]; !ok {
+			t.Errorf("no gatherer registered for %s", uriType)
+		}
+	}
+	// The BitTorrent gatherer connects to arbitrary peers, so it must be registered explicitly.
+	if _, ok := protocolHandlers[gogather.TorrentURI.String()]; ok {
+		t.Errorf("gatherer registered for %s by default", gogather.TorrentURI)
+	}
+
+	for source, expected := range map[string]gogather.URIType{
+		"git::https://example.com/org/repo.git": gogather.GitURI,
+		"https://example.com/file.txt":          gogather.HTTPURI,
+		"file::/tmp/file.txt":                   gogather.FileURI,
+		"oci::registry.io/org/repo:latest":      gogather.OCIURI,
+		"s3://bucket/policies/":                 gogather.S3URI,
+		"deploy@example.com:policies/":          gogather.SFTPURI,
+		"github-release://org/repo/v1/a.zip":    gogather.GitHubReleaseURI,
+		"gitlab://host/g/p/-/releases/v1/a.zip": gogather.GitLabURI,
+		"gdrive://1a2b3c":                       gogather.GoogleDriveURI,
+		"rsync://mirror.example.com/policies/":  gogather.RsyncURI,
+		"helm::https://charts.example.com/a":    gogather.HelmURI,
+		"k8s://policies/configmap/rules":        gogather.KubernetesURI,
+		"-":                                     gogather.StdinURI,
+		"magnet:?xt=urn:btih:c12fe1c06bba254a":  gogather.TorrentURI,
+		"hdfs://namenode.example.com/data":      gogather.HDFSURI,
+		"smb://fileserver.example.com/config":   gogather.SMBURI,
+		"forge::https://github.com/org/repo":    gogather.ForgeURI,
+	} {
+		uriType, err := gogather.ClassifyURI(source)
+		if err != nil || uriType != expected {
+			t.Errorf("expected %s to be classified as %s, got %s (%v)", source, expected, uriType, err)
+		}
+	}
+}
+
+// TestRegisterGatherer tests enabling an opt-in protocol by registering its gatherer
+func TestRegisterGatherer(t *testing.T) {
+	defer func() {
+		protocolHandlersMu.Lock()
+		delete(protocolHandlers, gogather.TorrentURI.String())
+		protocolHandlersMu.Unlock()
+	}()
+	source := "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+	if _, err := Gather(context.Background(), source, t.TempDir()); err == nil || !strings.Contains(err.Error(), "unsupported source protocol") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := RegisterGatherer(gogather.TorrentURI, &mockGatherer{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := Gather(context.Background(), source, t.TempDir()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := RegisterGatherer(gogather.Unknown, &mockGatherer{}); err == nil {
+		t.Error("expected an error registering a gatherer for unknown sources")
+	}
+	if err := RegisterGatherer(gogather.TorrentURI, nil); err == nil {
+		t.Error("expected an error registering a nil gatherer")
+	}
+}
+
 type mockGatherer struct{}
 
 func (m *mockGatherer) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
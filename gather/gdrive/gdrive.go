@@ -0,0 +1,584 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package gdrive provides functionality for gathering files and folders from Google Drive. It
+// includes an implementation of the Gatherer interface, GoogleDriveGatherer, which downloads a
+// file, or recursively the files of a folder, with the Google Drive API.
+//
+// Sources are either of the form gdrive://<id>, where id is the ID of the file or folder, or
+// links to it, e.g. https://drive.google.com/file/d/<id>/view,
+// https://drive.google.com/drive/folders/<id> or https://docs.google.com/document/d/<id>/edit.
+// Google Docs, Sheets, Slides and Drawings cannot be downloaded as is, they are exported in the
+// format named by the "format" query parameter of gdrive:// sources, e.g. gdrive://<id>?format=csv,
+// or else in their format of ExportFormats.
+//
+// Example usage:
+//
+//	g := &gdrive.GoogleDriveGatherer{CredentialsFile: "/etc/gather/service-account.json"}
+//	m, err := g.Gather(context.Background(), "https://drive.google.com/drive/folders/1a2b3c", "/tmp/config")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package gdrive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+	gdriveMetadata "github.com/enterprise-contract/go-gather/metadata/gdrive"
+)
+
+const (
+	// DefaultEndpoint is the Google Drive API endpoint used when none is configured.
+	DefaultEndpoint = "https://www.googleapis.com"
+	// readOnlyScope is the OAuth2 scope requested for service accounts and Application Default
+	// Credentials.
+	readOnlyScope = "https://www.googleapis.com/auth/drive.readonly"
+	// folderType is the MIME type of folders.
+	folderType = "application/vnd.google-apps.folder"
+	// shortcutType is the MIME type of shortcuts to other files or folders.
+	shortcutType = "application/vnd.google-apps.shortcut"
+	// nativePrefix is the prefix of the MIME types of the files of Google Docs, Sheets, Slides
+	// and other Google Workspace applications, which can only be exported.
+	nativePrefix = "application/vnd.google-apps."
+	// fileFields are the fields of the files requested from the API.
+	fileFields = "id,name,mimeType,size,sha256Checksum,modifiedTime,version,shortcutDetails(targetId)"
+)
+
+// DefaultExportFormats maps the MIME types of Google Workspace files to the extension of the
+// format they are exported in when neither the source nor ExportFormats select one.
+var DefaultExportFormats = map[string]string{
+	"application/vnd.google-apps.document":     "docx",
+	"application/vnd.google-apps.spreadsheet":  "xlsx",
+	"application/vnd.google-apps.presentation": "pptx",
+	"application/vnd.google-apps.drawing":      "svg",
+	"application/vnd.google-apps.script":       "json",
+}
+
+// exportTypes maps the extensions of the supported export formats to their MIME types. Not every
+// format applies to every type of file, e.g. only Sheets can be exported as csv.
+var exportTypes = map[string]string{
+	"csv":  "text/csv",
+	"docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"html": "text/html",
+	"json": "application/vnd.google-apps.script+json",
+	"md":   "text/markdown",
+	"odp":  "application/vnd.oasis.opendocument.presentation",
+	"ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	"odt":  "application/vnd.oasis.opendocument.text",
+	"pdf":  "application/pdf",
+	"png":  "image/png",
+	"pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	"svg":  "image/svg+xml",
+	"tsv":  "text/tab-separated-values",
+	"txt":  "text/plain",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// idPattern matches the IDs of files and folders.
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// GoogleDriveGatherer downloads files and folders from Google Drive, including shared drives. The
+// zero value authenticates requests with Application Default Credentials, e.g. the service account
+// key file named by the GOOGLE_APPLICATION_CREDENTIALS environment variable.
+type GoogleDriveGatherer struct {
+	// TokenSource provides the OAuth2 tokens used to authenticate requests, e.g.
+	// oauth2.StaticTokenSource for an access token obtained elsewhere. If nil, CredentialsFile
+	// or Application Default Credentials are used.
+	TokenSource oauth2.TokenSource
+	// CredentialsFile is the path of a service account key file, used when TokenSource is nil.
+	// The files and folders to gather must be shared with the service account.
+	CredentialsFile string
+	// ExportFormats maps the MIME types of Google Workspace files to the extension of the format
+	// they are exported in, e.g. "application/vnd.google-apps.spreadsheet" to "csv", overriding
+	// DefaultExportFormats.
+	ExportFormats map[string]string
+	// Endpoint overrides the Google Drive API endpoint. Defaults to DefaultEndpoint.
+	Endpoint string
+	// Logger receives diagnostic messages. When nil, the logger of the gather options is used.
+	Logger *slog.Logger
+}
+
+// file is the subset of a file of the Google Drive API that is used.
+type file struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	MimeType        string    `json:"mimeType"`
+	Size            int64     `json:"size,string"`
+	SHA256Checksum  string    `json:"sha256Checksum"`
+	ModifiedTime    time.Time `json:"modifiedTime"`
+	Version         int64     `json:"version,string"`
+	ShortcutDetails *struct {
+		TargetID string `json:"targetId"`
+	} `json:"shortcutDetails"`
+}
+
+// entry is a file to download: the file, its path relative to the destination and the MIME type
+// it is exported as, if any.
+type entry struct {
+	file       *file
+	path       string
+	exportType string
+}
+
+func (g *GoogleDriveGatherer) Gather(ctx context.Context, source, destination string) (_ metadata.Metadata, err error) {
+	defer func() {
+		err = gogather.RedactError(err)
+		gogather.FinishProgress(ctx, err)
+	}()
+	startedAt := time.Now()
+
+	loc, err := parseSource(source)
+	if err != nil {
+		return nil, err
+	}
+	client, err := g.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := g.file(ctx, client, loc.id)
+	if err != nil {
+		return nil, err
+	}
+	if f.ShortcutDetails != nil {
+		target, err := g.file(ctx, client, f.ShortcutDetails.TargetID)
+		if err != nil {
+			return nil, err
+		}
+		target.Name = f.Name
+		f = target
+	}
+
+	m := &gdriveMetadata.GoogleDriveMetadata{ID: f.ID, Name: f.Name, MimeType: f.MimeType, Version: f.Version, ModifiedTime: f.ModifiedTime}
+	if f.MimeType == folderType {
+		destination, err = g.gatherFolder(ctx, client, f, loc.format, destination, m)
+	} else {
+		destination, err = g.gatherFile(ctx, client, f, loc.format, destination, m)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, _ := m.GetPinnedURL(gogather.RedactURL(source))
+	m.Common = metadata.NewCommon(gdriveMetadata.Type, gogather.RedactURL(source), resolved, destination, startedAt)
+	return m, nil
+}
+
+func (g *GoogleDriveGatherer) gatherFile(ctx context.Context, client *http.Client, f *file, format, destination string, m *gdriveMetadata.GoogleDriveMetadata) (string, error) {
+	e, err := g.entry(f, "", format)
+	if err != nil {
+		return "", err
+	}
+	if e == nil {
+		return "", fmt.Errorf("file %s of type %s cannot be downloaded", f.Name, f.MimeType)
+	}
+	if strings.HasSuffix(destination, string(os.PathSeparator)) || strings.HasSuffix(destination, "/") {
+		destination = filepath.Join(destination, e.path)
+	} else if fi, err := os.Stat(destination); err == nil && fi.IsDir() {
+		destination = filepath.Join(destination, e.path)
+	}
+
+	if err := gogather.CheckWritten(ctx, f.Size); err != nil {
+		return "", err
+	}
+	if e.exportType == "" {
+		gogather.StartProgress(ctx, f.Size, 1)
+	} else {
+		gogather.StartProgress(ctx, -1, 1)
+	}
+	if m.Size, m.SHA256, err = g.download(ctx, client, e, destination); err != nil {
+		return "", err
+	}
+	if err := gogather.VerifyChecksum(ctx, destination); err != nil {
+		_ = os.Remove(destination)
+		return "", err
+	}
+	m.ExportMimeType = e.exportType
+	return destination, nil
+}
+
+func (g *GoogleDriveGatherer) gatherFolder(ctx context.Context, client *http.Client, f *file, format, destination string, m *gdriveMetadata.GoogleDriveMetadata) (string, error) {
+	opts := gogather.OptionsFromContext(ctx)
+	filter, err := gogather.NewPathFilter(opts.Include, opts.Exclude)
+	if err != nil {
+		return "", err
+	}
+
+	var entries []*entry
+	if err := g.walk(ctx, client, f.ID, "", format, map[string]bool{f.ID: true}, func(e *entry) {
+		if filter.Match(e.path) {
+			entries = append(entries, e)
+		}
+	}); err != nil {
+		return "", err
+	}
+	var total int64
+	for _, e := range entries {
+		total += e.file.Size
+	}
+	if err := gogather.CheckWritten(ctx, total); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(destination, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	gogather.StartProgress(ctx, -1, len(entries))
+	for _, e := range entries {
+		n, _, err := g.download(ctx, client, e, filepath.Join(destination, filepath.FromSlash(e.path)))
+		if err != nil {
+			return "", err
+		}
+		m.Size += n
+		m.Files = append(m.Files, gdriveMetadata.File{ID: e.file.ID, Path: e.path, MimeType: e.file.MimeType, ExportMimeType: e.exportType, Version: e.file.Version})
+	}
+
+	if m.TreeHash, err = metadata.TreeHash(destination); err != nil {
+		return "", err
+	}
+	if err := gogather.VerifyChecksumDigest(ctx, destination, "sha256", m.TreeHash); err != nil {
+		return "", err
+	}
+	return destination, nil
+}
+
+// walk calls fn for every file below the folder with the given id, whose path relative to the
+// folder gathered is dir. Shortcuts are followed; visited holds the IDs of the folders being
+// walked, so that shortcuts to them are not followed again. Files that can neither be downloaded
+// nor exported, e.g. Google Forms, are skipped.
+func (g *GoogleDriveGatherer) walk(ctx context.Context, client *http.Client, id, dir, format string, visited map[string]bool, fn func(*entry)) error {
+	children, err := g.list(ctx, client, id)
+	if err != nil {
+		return err
+	}
+	log := gogather.Logger(ctx, g.Logger)
+	names := map[string]bool{}
+	for _, f := range children {
+		if f.ShortcutDetails != nil {
+			target, err := g.file(ctx, client, f.ShortcutDetails.TargetID)
+			if err != nil {
+				return err
+			}
+			target.Name = f.Name
+			f = target
+		}
+		if f.MimeType == folderType {
+			if visited[f.ID] {
+				log.Debug("skipping shortcut to a folder being gathered", "folder", path.Join(dir, f.Name))
+				continue
+			}
+			name, err := fileName(f.Name, "")
+			if err != nil {
+				return err
+			}
+			if names[name] {
+				return fmt.Errorf("folder %s contains more than one file named %s", dir, name)
+			}
+			names[name] = true
+			visited[f.ID] = true
+			err = g.walk(ctx, client, f.ID, path.Join(dir, name), format, visited, fn)
+			delete(visited, f.ID)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		e, err := g.entry(f, dir, format)
+		if err != nil {
+			return err
+		}
+		if e == nil {
+			log.Debug("skipping file that cannot be downloaded", "file", path.Join(dir, f.Name), "mimeType", f.MimeType)
+			continue
+		}
+		if names[path.Base(e.path)] {
+			return fmt.Errorf("folder %s contains more than one file named %s", dir, path.Base(e.path))
+		}
+		names[path.Base(e.path)] = true
+		fn(e)
+	}
+	return nil
+}
+
+// entry returns the entry of f in the directory dir, exporting Google Workspace files in format, or
+// else in their format of ExportFormats or DefaultExportFormats. It returns nil if f can neither be
+// downloaded nor exported.
+func (g *GoogleDriveGatherer) entry(f *file, dir, format string) (*entry, error) {
+	if !strings.HasPrefix(f.MimeType, nativePrefix) {
+		name, err := fileName(f.Name, "")
+		if err != nil {
+			return nil, err
+		}
+		return &entry{file: f, path: path.Join(dir, name)}, nil
+	}
+
+	ext := format
+	if ext == "" {
+		if ext = g.ExportFormats[f.MimeType]; ext == "" {
+			ext = DefaultExportFormats[f.MimeType]
+		}
+	}
+	if ext == "" {
+		return nil, nil
+	}
+	exportType, ok := exportTypes[ext]
+	if !ok {
+		return nil, fmt.Errorf("unsupported export format: %s", ext)
+	}
+	name, err := fileName(f.Name, ext)
+	if err != nil {
+		return nil, err
+	}
+	return &entry{file: f, path: path.Join(dir, name), exportType: exportType}, nil
+}
+
+// fileName returns the local name of a file named name, with the extension ext appended unless
+// name already has it. Slashes, which Drive allows in names, are replaced by underscores.
+func fileName(name, ext string) (string, error) {
+	name = strings.ReplaceAll(name, "/", "_")
+	if name == "" || name == "." || name == ".." || !filepath.IsLocal(name) {
+		return "", fmt.Errorf("invalid file name: %q", name)
+	}
+	if ext != "" && !strings.HasSuffix(strings.ToLower(name), "."+ext) {
+		name += "." + ext
+	}
+	return name, nil
+}
+
+// file returns the file with the given id.
+func (g *GoogleDriveGatherer) file(ctx context.Context, client *http.Client, id string) (*file, error) {
+	resp, err := g.get(ctx, client, "/drive/v3/files/"+url.PathEscape(id), url.Values{"fields": {fileFields}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file %s: %w", id, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("file %s not found", id)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get file %s: %w", id, &gogather.StatusError{StatusCode: resp.StatusCode})
+	}
+	f := &file{}
+	if err := json.NewDecoder(resp.Body).Decode(f); err != nil {
+		return nil, fmt.Errorf("failed to decode file %s: %w", id, err)
+	}
+	return f, nil
+}
+
+// list returns the files of the folder with the given id, following the pages of the listing.
+// Trashed files are left out.
+func (g *GoogleDriveGatherer) list(ctx context.Context, client *http.Client, id string) ([]*file, error) {
+	var files []*file
+	query := url.Values{
+		"q":                         {fmt.Sprintf("'%s' in parents and trashed = false", id)},
+		"fields":                    {"nextPageToken,files(" + fileFields + ")"},
+		"orderBy":                   {"name"},
+		"pageSize":                  {"1000"},
+		"includeItemsFromAllDrives": {"true"},
+	}
+	for {
+		resp, err := g.get(ctx, client, "/drive/v3/files", query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list folder %s: %w", id, err)
+		}
+		var page struct {
+			NextPageToken string  `json:"nextPageToken"`
+			Files         []*file `json:"files"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			err = &gogather.StatusError{StatusCode: resp.StatusCode}
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&page)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list folder %s: %w", id, err)
+		}
+		files = append(files, page.Files...)
+		if page.NextPageToken == "" {
+			return files, nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// download writes the content of the file of e, exported if e has an export type, to path,
+// returning its size and hex encoded SHA256 digest. The digest of a downloaded file is checked
+// against the digest recorded by Drive.
+func (g *GoogleDriveGatherer) download(ctx context.Context, client *http.Client, e *entry, path string) (int64, string, error) {
+	gogather.Logger(ctx, g.Logger).Debug("downloading file", "id", e.file.ID, "name", e.file.Name, "exportType", e.exportType, "destination", path)
+	endpoint, query := "/drive/v3/files/"+url.PathEscape(e.file.ID), url.Values{"alt": {"media"}}
+	if e.exportType != "" {
+		endpoint, query = endpoint+"/export", url.Values{"mimeType": {e.exportType}}
+	}
+	resp, err := g.get(ctx, client, endpoint, query)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to download %s: %w", e.file.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", fmt.Errorf("failed to download %s: %w", e.file.Name, &gogather.StatusError{StatusCode: resp.StatusCode})
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create file: %w", err)
+	}
+	h := sha256.New()
+	n, err := gogather.CopySized(io.MultiWriter(out, h), gogather.WrapReader(ctx, resp.Body), resp.ContentLength)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to download %s: %w", e.file.Name, err)
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+	if e.exportType == "" && e.file.SHA256Checksum != "" && e.file.SHA256Checksum != digest {
+		_ = os.Remove(path)
+		return 0, "", fmt.Errorf("digest of %s does not match the digest recorded by Google Drive: %s != %s", e.file.Name, digest, e.file.SHA256Checksum)
+	}
+	gogather.CountItems(ctx, 1)
+	return n, digest, nil
+}
+
+// get sends a GET request for the API endpoint with the query, including the files of shared
+// drives, after checking the host of the API against the host policy of the gather options.
+func (g *GoogleDriveGatherer) get(ctx context.Context, client *http.Client, endpoint string, query url.Values) (*http.Response, error) {
+	base := g.Endpoint
+	if base == "" {
+		base = DefaultEndpoint
+	}
+	query.Set("supportsAllDrives", "true")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(base, "/")+endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if err := gogather.CheckHost(ctx, req.URL.Scheme, req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Go-Gather")
+	return client.Do(req)
+}
+
+// client returns an HTTP client that authenticates requests with the configured token source, the
+// service account key file, or Application Default Credentials. It is configured for the gather
+// options, see gogather.HTTPClient, and so is the client obtaining the tokens.
+func (g *GoogleDriveGatherer) client(ctx context.Context) (*http.Client, error) {
+	base, err := gogather.HTTPClient(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, base)
+
+	ts := g.TokenSource
+	if ts == nil && g.CredentialsFile != "" {
+		data, err := os.ReadFile(g.CredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read credentials file: %w", err)
+		}
+		creds, err := google.CredentialsFromJSON(ctx, data, readOnlyScope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse credentials file: %w", err)
+		}
+		ts = creds.TokenSource
+	}
+	if ts == nil {
+		if ts, err = google.DefaultTokenSource(ctx, readOnlyScope); err != nil {
+			return nil, fmt.Errorf("failed to find default credentials: %w", err)
+		}
+	}
+
+	client := oauth2.NewClient(ctx, ts)
+	client.CheckRedirect = base.CheckRedirect
+	return client, nil
+}
+
+// location identifies a file or folder, and the format to export Google Workspace files in.
+type location struct {
+	id, format string
+}
+
+// linkPatterns match the paths of links to files and folders, capturing the ID.
+var linkPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^/file/d/([^/]+)`),
+	regexp.MustCompile(`^/drive/(?:u/\d+/)?folders/([^/]+)`),
+	regexp.MustCompile(`^/(?:document|spreadsheets|presentation|drawings)/d/([^/]+)`),
+}
+
+// parseSource parses a source of the form gdrive://<id>?format=<ext>, or a link to a file or
+// folder on drive.google.com or docs.google.com.
+func parseSource(source string) (*location, error) {
+	u, err := url.Parse(strings.TrimPrefix(source, "gdrive::"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source %s: %w", source, err)
+	}
+
+	loc := &location{}
+	switch {
+	case u.Scheme == "gdrive":
+		loc.id = u.Host + u.Path
+		for key := range u.Query() {
+			if key != "format" {
+				return nil, fmt.Errorf("unsupported parameters of %s", source)
+			}
+		}
+		loc.format = u.Query().Get("format")
+		if _, ok := exportTypes[loc.format]; loc.format != "" && !ok {
+			return nil, fmt.Errorf("unsupported export format: %s", loc.format)
+		}
+	case (u.Scheme == "https" || u.Scheme == "http") && (u.Host == "drive.google.com" || u.Host == "docs.google.com"):
+		// Links to files and folders also come with parameters of the web interface, e.g.
+		// "usp=sharing", which are ignored.
+		for _, p := range linkPatterns {
+			if m := p.FindStringSubmatch(u.Path); m != nil {
+				loc.id = m[1]
+			}
+		}
+		if loc.id == "" && (u.Path == "/open" || u.Path == "/uc") {
+			loc.id = u.Query().Get("id")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported Google Drive source: %s", source)
+	}
+
+	if !idPattern.MatchString(loc.id) {
+		return nil, fmt.Errorf("source must be of the form gdrive://<id> or a link to a file or folder: %s", source)
+	}
+	return loc, nil
+}
@@ -0,0 +1,275 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gdrive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	gdriveMetadata "github.com/enterprise-contract/go-gather/metadata/gdrive"
+)
+
+// digest is the SHA256 digest of "test data".
+const digest = "916f0027a575074ce72a331777c3478d6513f786a591bd892da1a577bf2335f9"
+
+// files are the files of the fake Google Drive API, by ID: the folder root, holding the file
+// a.txt, the document Notes, the form Survey, the folder sub and a shortcut to root, the folder
+// sub, holding the file b.txt, and the file broken.txt, the recorded digest of which does not
+// match its content.
+var files = map[string]string{
+	"root":   `{"id": "root", "name": "root", "mimeType": "application/vnd.google-apps.folder", "version": "7", "modifiedTime": "2024-01-02T03:04:05Z"}`,
+	"a":      `{"id": "a", "name": "a.txt", "mimeType": "text/plain", "size": "9", "version": "2", "sha256Checksum": "` + digest + `"}`,
+	"notes":  `{"id": "notes", "name": "Notes", "mimeType": "application/vnd.google-apps.document", "version": "3"}`,
+	"survey": `{"id": "survey", "name": "Survey", "mimeType": "application/vnd.google-apps.form", "version": "1"}`,
+	"sub":    `{"id": "sub", "name": "sub", "mimeType": "application/vnd.google-apps.folder", "version": "1"}`,
+	"loop":   `{"id": "loop", "name": "loop", "mimeType": "application/vnd.google-apps.shortcut", "shortcutDetails": {"targetId": "root"}}`,
+	"b":      `{"id": "b", "name": "b.txt", "mimeType": "text/plain", "size": "9", "version": "1", "sha256Checksum": "` + digest + `"}`,
+	"broken": `{"id": "broken", "name": "broken.txt", "mimeType": "text/plain", "size": "9", "version": "1", "sha256Checksum": "abc"}`,
+}
+
+// newServer starts a fake Google Drive API serving files. Requests must be authenticated with
+// token. The listing of root is split into two pages.
+func newServer(t *testing.T, token string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("supportsAllDrives") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch {
+		case r.URL.Path == "/drive/v3/files" && q.Get("q") == "'root' in parents and trashed = false":
+			if q.Get("pageToken") == "" {
+				fmt.Fprintf(w, `{"nextPageToken": "next", "files": [%s, %s]}`, files["a"], files["notes"])
+				return
+			}
+			fmt.Fprintf(w, `{"files": [%s, %s, %s]}`, files["survey"], files["sub"], files["loop"])
+		case r.URL.Path == "/drive/v3/files" && q.Get("q") == "'sub' in parents and trashed = false":
+			fmt.Fprintf(w, `{"files": [%s]}`, files["b"])
+		case r.URL.Path == "/drive/v3/files/notes/export":
+			fmt.Fprintf(w, "notes as %s", q.Get("mimeType"))
+		case strings.HasPrefix(r.URL.Path, "/drive/v3/files/"):
+			f, ok := files[strings.TrimPrefix(r.URL.Path, "/drive/v3/files/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if q.Get("alt") == "media" {
+				fmt.Fprint(w, "test data")
+				return
+			}
+			fmt.Fprint(w, f)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newGatherer returns a gatherer for the fake Google Drive API authenticated with token.
+func newGatherer(srv *httptest.Server, token string) *GoogleDriveGatherer {
+	return &GoogleDriveGatherer{Endpoint: srv.URL, TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})}
+}
+
+// TestGoogleDriveGatherer_Gather tests downloading a file
+func TestGoogleDriveGatherer_Gather(t *testing.T) {
+	g := newGatherer(newServer(t, "token"), "token")
+	dir := t.TempDir()
+
+	source := "https://drive.google.com/file/d/a/view?usp=sharing"
+	m, err := g.Gather(context.Background(), source, dir+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	destination := filepath.Join(dir, "a.txt")
+	if data, err := os.ReadFile(destination); err != nil || string(data) != "test data" {
+		t.Fatalf("unexpected content: %q, %v", data, err)
+	}
+
+	gm := m.(*gdriveMetadata.GoogleDriveMetadata)
+	if gm.Destination != destination || gm.ID != "a" || gm.Name != "a.txt" || gm.Version != 2 || gm.Size != 9 || gm.SHA256 != digest || gm.ExportMimeType != "" {
+		t.Errorf("unexpected metadata: %+v", gm)
+	}
+	if gm.ResolvedURI != source+"&checksum=sha256:"+digest {
+		t.Errorf("unexpected resolved URI: %s", gm.ResolvedURI)
+	}
+}
+
+// TestGoogleDriveGatherer_Gather_Export tests exporting a Google Docs document
+func TestGoogleDriveGatherer_Gather_Export(t *testing.T) {
+	g := newGatherer(newServer(t, "token"), "token")
+	dir := t.TempDir()
+
+	m, err := g.Gather(context.Background(), "gdrive://notes?format=pdf", dir+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	destination := filepath.Join(dir, "Notes.pdf")
+	if data, err := os.ReadFile(destination); err != nil || string(data) != "notes as application/pdf" {
+		t.Fatalf("unexpected content: %q, %v", data, err)
+	}
+	if gm := m.(*gdriveMetadata.GoogleDriveMetadata); gm.Destination != destination || gm.ExportMimeType != "application/pdf" || gm.SHA256 == "" {
+		t.Errorf("unexpected metadata: %+v", gm)
+	}
+
+	g.ExportFormats = map[string]string{"application/vnd.google-apps.document": "txt"}
+	destination = filepath.Join(dir, "notes.txt")
+	if _, err := g.Gather(context.Background(), "https://docs.google.com/document/d/notes/edit", destination); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, err := os.ReadFile(destination); err != nil || string(data) != "notes as text/plain" {
+		t.Errorf("unexpected content: %q, %v", data, err)
+	}
+}
+
+// TestGoogleDriveGatherer_Gather_Folder tests downloading a folder recursively, exporting the
+// documents it holds and skipping files that cannot be exported and shortcuts to the folder itself
+func TestGoogleDriveGatherer_Gather_Folder(t *testing.T) {
+	g := newGatherer(newServer(t, "token"), "token")
+	destination := filepath.Join(t.TempDir(), "config")
+
+	m, err := g.Gather(context.Background(), "https://drive.google.com/drive/u/0/folders/root", destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"a.txt":      "test data",
+		"Notes.docx": "notes as application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"sub/b.txt":  "test data",
+	}
+	for name, content := range expected {
+		if data, err := os.ReadFile(filepath.Join(destination, name)); err != nil || string(data) != content {
+			t.Errorf("unexpected content of %s: %q, %v", name, data, err)
+		}
+	}
+	entries, _ := os.ReadDir(destination)
+	if len(entries) != 3 {
+		t.Errorf("unexpected entries: %v", entries)
+	}
+
+	gm := m.(*gdriveMetadata.GoogleDriveMetadata)
+	if gm.Destination != destination || gm.ID != "root" || gm.Version != 7 || len(gm.Files) != 3 || gm.TreeHash == "" || gm.SHA256 != "" {
+		t.Errorf("unexpected metadata: %+v", gm)
+	}
+	if gm.Files[2] != (gdriveMetadata.File{ID: "b", Path: "sub/b.txt", MimeType: "text/plain", Version: 1}) {
+		t.Errorf("unexpected file: %+v", gm.Files[2])
+	}
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Include: []string{"sub/**"}})
+	destination = filepath.Join(t.TempDir(), "config")
+	if m, err = g.Gather(ctx, "gdrive://root", destination); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gm := m.(*gdriveMetadata.GoogleDriveMetadata); len(gm.Files) != 1 || gm.Files[0].Path != "sub/b.txt" {
+		t.Errorf("unexpected files: %+v", gm.Files)
+	}
+}
+
+// TestGoogleDriveGatherer_Gather_Errors tests failing downloads
+func TestGoogleDriveGatherer_Gather_Errors(t *testing.T) {
+	srv := newServer(t, "token")
+	g := newGatherer(srv, "token")
+	dir := t.TempDir()
+
+	if _, err := g.Gather(context.Background(), "gdrive://missing", dir); err == nil || err.Error() != "file missing not found" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := g.Gather(context.Background(), "gdrive://survey", dir); err == nil || err.Error() != "file Survey of type application/vnd.google-apps.form cannot be downloaded" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	destination := filepath.Join(dir, "broken.txt")
+	_, err := g.Gather(context.Background(), "gdrive://broken", destination)
+	if err == nil || !strings.HasPrefix(err.Error(), "digest of broken.txt does not match") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(destination); !os.IsNotExist(err) {
+		t.Errorf("expected the file to be removed: %v", err)
+	}
+
+	var status *gogather.StatusError
+	_, err = newGatherer(srv, "wrong").Gather(context.Background(), "gdrive://a", dir)
+	if !errors.As(err, &status) || status.StatusCode != http.StatusUnauthorized || status.Retryable() {
+		t.Errorf("expected a status error, got %v", err)
+	}
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{MaxSize: 5})
+	if _, err := g.Gather(ctx, "gdrive://a", dir); !errors.Is(err, gogather.ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+
+	ctx = gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{HostPolicy: &gogather.HostPolicy{DeniedHosts: []string{"127.0.0.1"}}})
+	var denied *gogather.HostDeniedError
+	if _, err := g.Gather(ctx, "gdrive://a", dir); !errors.As(err, &denied) {
+		t.Errorf("expected the host policy to deny the API, got %v", err)
+	}
+}
+
+// TestParseSource tests parsing Google Drive sources
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected location
+		err      string
+	}{
+		{source: "gdrive://1a2b3c", expected: location{id: "1a2b3c"}},
+		{source: "gdrive::gdrive://1a2b3c?format=csv", expected: location{id: "1a2b3c", format: "csv"}},
+		{source: "https://drive.google.com/file/d/1a2b3c/view?usp=sharing", expected: location{id: "1a2b3c"}},
+		{source: "https://drive.google.com/drive/folders/1a2b3c", expected: location{id: "1a2b3c"}},
+		{source: "https://drive.google.com/drive/u/1/folders/1a2b3c?usp=drive_link", expected: location{id: "1a2b3c"}},
+		{source: "https://drive.google.com/open?id=1a2b3c", expected: location{id: "1a2b3c"}},
+		{source: "https://drive.google.com/uc?id=1a2b3c&export=download", expected: location{id: "1a2b3c"}},
+		{source: "https://docs.google.com/spreadsheets/d/1a2b3c/edit#gid=0", expected: location{id: "1a2b3c"}},
+		{source: "gdrive://1a2b3c?format=exe", err: "unsupported export format: exe"},
+		{source: "gdrive://1a2b3c?ref=main", err: "unsupported parameters of gdrive://1a2b3c?ref=main"},
+		{source: "gdrive://1a2b/3c", err: "source must be of the form gdrive://<id> or a link to a file or folder: gdrive://1a2b/3c"},
+		{source: "https://drive.google.com/drive/my-drive", err: "source must be of the form gdrive://<id> or a link to a file or folder: https://drive.google.com/drive/my-drive"},
+		{source: "https://example.com/file/d/1a2b3c", err: "unsupported Google Drive source: https://example.com/file/d/1a2b3c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			l, err := parseSource(tt.source)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *l != tt.expected {
+				t.Errorf("unexpected location: got %+v, want %+v", *l, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+module github.com/enterprise-contract/go-gather/gather/gdrive
+
+go 1.22.5
+
+require (
+	github.com/enterprise-contract/go-gather v0.0.3
+	github.com/enterprise-contract/go-gather/metadata v0.0.2
+	github.com/enterprise-contract/go-gather/metadata/gdrive v0.0.1
+	golang.org/x/oauth2 v0.24.0
+)
+
+require (
+	cloud.google.com/go/compute/metadata v0.3.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -20,31 +20,59 @@
 package git
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	giturls "github.com/chainguard-dev/git-urls"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitclient "github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"golang.org/x/sync/errgroup"
 
 	gogather "github.com/enterprise-contract/go-gather"
 	"github.com/enterprise-contract/go-gather/metadata"
 	gitMetadata "github.com/enterprise-contract/go-gather/metadata/git"
 )
 
+func init() {
+	// go-git sends the requests of every clone with the client installed for their protocol, so
+	// the proxy, TLS settings and host policy are those of the gather options carried by the
+	// context of each request, see proxyOptions.
+	client := githttp.NewClient(&http.Client{
+		Transport:     gogather.ContextTransport(http.DefaultTransport),
+		CheckRedirect: gogather.CheckRedirect,
+	})
+	gitclient.InstallProtocol("http", client)
+	gitclient.InstallProtocol("https", client)
+}
+
 // GitGatherer is a struct that implements the Gatherer interface
 // and provides methods for gathering git repositories.
 type GitGatherer struct {
 	// Authenticator is an SSHAuthenticator that provides authentication for SSH connections.
 	Authenticator SSHAuthenticator
+	// Inventory attaches an inventory of the files of the cloned repository to the metadata.
+	Inventory bool
+	// Logger receives diagnostic messages, including the progress reported by the remote while
+	// cloning. When nil, the logger of the gather options is used.
+	Logger *slog.Logger
 }
 
 // SSHAuthenticator represents an interface for authenticating SSH connections.
@@ -65,30 +93,46 @@ func (r *RealSSHAuthenticator) NewSSHAgentAuth(user string) (transport.AuthMetho
 
 // Gather clones a Git repository from the given source URI into the specified destination directory,
 // and returns the metadata of the cloned repository.
-func (g *GitGatherer) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
-	// Process our providied source URL to get the source URL, ref, subdir, and depth
-	src, ref, subdir, depth, err := processUrl(source)
+func (g *GitGatherer) Gather(ctx context.Context, source, destination string) (_ metadata.Metadata, err error) {
+	defer func() {
+		err = gogather.RedactError(err)
+		gogather.FinishProgress(ctx, err)
+	}()
+	startedAt := time.Now()
+
+	// Parse our provided source to get the URL to clone, the ref, subdir, and depth
+	s, u, err := parseSource(source)
 	if err != nil {
 		return nil, fmt.Errorf("failed to process URL: %w", err)
 	}
+	src, ref, subdir := u.String(), s.Ref, s.Subdir
+
+	// Check the repository host against the host policy before cloning
+	if err := gogather.CheckHost(ctx, u.Scheme, u.Hostname()); err != nil {
+		return nil, err
+	}
 
 	// Initialize the clone options for the git repository
+	opts := gogather.OptionsFromContext(ctx)
 	cloneOpts := &git.CloneOptions{
-		URL:             src,
-		InsecureSkipTLS: os.Getenv("GIT_SSL_NO_VERIFY") == "true",
+		URL:          src,
+		ProxyOptions: proxyOptions(opts, u.Scheme),
 	}
 
+	if cloneOpts.Auth, err = httpAuth(ctx, src); err != nil {
+		return nil, err
+	}
+	log := gogather.Logger(ctx, g.Logger)
+	log.Debug("cloning repository", "url", gogather.RedactURL(src), "ref", ref, "destination", destination)
+	cloneOpts.Progress = &progressWriter{log: log}
+	gogather.StartProgress(ctx, -1, -1)
+
 	// If we have a ref and it isn't a hash, set the reference name in the clone options
 	if len(ref) > 0 && !plumbing.IsHash(ref) {
 		cloneOpts.ReferenceName = plumbing.ReferenceName(ref)
 	}
 
-	if depth != "" {
-		cloneOpts.Depth, err = strconv.Atoi(depth)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse depth: %w", err)
-		}
-	}
+	cloneOpts.Depth = s.Depth
 
 	// Initialize the git repository and worktree
 	r := &git.Repository{}
@@ -98,9 +142,9 @@ func (g *GitGatherer) Gather(ctx context.Context, source, destination string) (m
 	var tmpDir string
 
 	if subdir != "" {
-		tmpDir, err = os.MkdirTemp("", "git-repo-")
+		tmpDir, err = gogather.MkdirTemp(ctx, "git-repo-")
 		if err != nil {
-			return nil, fmt.Errorf("error creating temporary directory: %w", err)
+			return nil, err
 		}
 		defer os.RemoveAll(tmpDir)
 
@@ -143,12 +187,24 @@ func (g *GitGatherer) Gather(ctx context.Context, source, destination string) (m
 			return nil, fmt.Errorf("path %s does not exist in the repository", subdir)
 		}
 		path := filepath.Join(tmpDir, subdir)
-		err = copyDir(path, destination)
+		err = moveDir(ctx, path, destination)
 		if err != nil {
 			return nil, fmt.Errorf("error copying directory: %w", err)
 		}
 	}
 
+	log.Debug("cloned repository", "url", gogather.RedactURL(src), "destination", destination)
+
+	if err := opts.Prune(destination); err != nil {
+		return nil, err
+	}
+	// The files moved out of a subdirectory were counted while they were moved.
+	if subdir == "" {
+		if err := gogather.CountWrittenDir(ctx, destination); err != nil {
+			return nil, err
+		}
+	}
+
 	head, err := r.Head()
 	if err != nil {
 		return nil, fmt.Errorf("determining the HEAD reference: %w", err)
@@ -157,61 +213,295 @@ func (g *GitGatherer) Gather(ctx context.Context, source, destination string) (m
 	m := &gitMetadata.GitMetadata{
 		LatestCommit: head.Hash().String(),
 	}
+	// Sources that cannot be pinned are left unresolved.
+	redacted := gogather.RedactURL(source)
+	resolved, _ := m.GetPinnedURL(redacted)
+	m.Common = metadata.NewCommon("git", redacted, resolved, destination, startedAt)
+	if m.TreeHash, err = metadata.TreeHash(destination); err != nil {
+		return nil, err
+	}
+	if err := gogather.VerifyChecksumDigest(ctx, destination, "sha256", m.TreeHash); err != nil {
+		return nil, err
+	}
+	if g.Inventory {
+		if m.Inventory, err = metadata.NewInventory(destination); err != nil {
+			return nil, err
+		}
+	}
 	return m, nil
 }
 
-// copyDir copies the contents of the src directory to dst directory
-func copyDir(src string, dst string) error {
-	src = filepath.Clean(src)
-	dst = filepath.Clean(dst)
+// Resolve resolves the ref of the repository at source to the commit it points to, listing the
+// references of the remote like git ls-remote, without cloning it. Sources without a ref resolve
+// to the commit of the remote HEAD, and refs that are already commits are returned as is. The
+// returned metadata has the commit set, so that GetPinnedURL pins source.
+func (g *GitGatherer) Resolve(ctx context.Context, source string) (_ metadata.Metadata, err error) {
+	defer func() { err = gogather.RedactError(err) }()
+	startedAt := time.Now()
 
-	srcInfo, err := os.Stat(src)
+	s, u, err := parseSource(source)
 	if err != nil {
-		return fmt.Errorf("error getting source directory info: %w", err)
+		return nil, fmt.Errorf("failed to process URL: %w", err)
+	}
+	ref := s.Ref
+	if err := gogather.CheckHost(ctx, u.Scheme, u.Hostname()); err != nil {
+		return nil, err
 	}
 
-	if !srcInfo.IsDir() {
-		return fmt.Errorf("%s is not a directory", src)
+	commit := ref
+	if !plumbing.IsHash(ref) {
+		refs, err := g.listRefs(ctx, u, ref)
+		if err != nil {
+			return nil, err
+		}
+		if commit, err = resolveRef(refs, ref); err != nil {
+			return nil, err
+		}
 	}
 
-	_, err = os.Stat(dst)
+	m := &gitMetadata.GitMetadata{LatestCommit: commit}
+	redacted := gogather.RedactURL(source)
+	resolved, _ := m.GetPinnedURL(redacted)
+	m.Common = metadata.NewCommon("git", redacted, resolved, "", startedAt)
+	return m, nil
+}
+
+// Validate checks that the repository at source can be cloned without cloning it: the references
+// of the remote are listed, which fails if it cannot be reached or rejects the credentials, and
+// the ref of source, unless it is a commit, must be among them.
+func (g *GitGatherer) Validate(ctx context.Context, source string) (err error) {
+	defer func() { err = gogather.RedactError(err) }()
+
+	s, u, err := parseSource(source)
 	if err != nil {
-		if os.IsNotExist(err) {
-			err = os.MkdirAll(dst, srcInfo.Mode())
-			if err != nil {
-				return err
+		return fmt.Errorf("failed to process URL: %w", err)
+	}
+	ref := s.Ref
+	if err := gogather.CheckHost(ctx, u.Scheme, u.Hostname()); err != nil {
+		return err
+	}
+
+	refs, err := g.listRefs(ctx, u, ref)
+	if err != nil {
+		return err
+	}
+	if !plumbing.IsHash(ref) {
+		_, err = resolveRef(refs, ref)
+	}
+	return err
+}
+
+// proxyOptions returns the proxy of the gather options for a remote with scheme. It only applies to
+// SSH remotes: requests to HTTP remotes are sent through the proxy by the client installed for
+// them, whose transport go-git could not configure.
+func proxyOptions(opts gogather.GatherOptions, scheme string) transport.ProxyOptions {
+	if scheme == "http" || scheme == "https" {
+		return transport.ProxyOptions{}
+	}
+	return transport.ProxyOptions{URL: opts.Proxy}
+}
+
+// listRefs lists the references of the remote repository at u like git ls-remote.
+func (g *GitGatherer) listRefs(ctx context.Context, u *url.URL, ref string) ([]*plumbing.Reference, error) {
+	src := u.String()
+	auth, err := httpAuth(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	gogather.Logger(ctx, g.Logger).Debug("listing references", "url", gogather.RedactURL(src), "ref", ref)
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{src}})
+	opts := gogather.OptionsFromContext(ctx)
+	refs, err := remote.ListContext(ctx, &git.ListOptions{
+		Auth:          auth,
+		ProxyOptions:  proxyOptions(opts, u.Scheme),
+		PeelingOption: git.AppendPeeled,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing references: %w", err)
+	}
+	return refs, nil
+}
+
+// resolveRef returns the commit ref points to among the references listed by a remote. The ref is
+// looked up as a full reference name, then as a branch and as a tag, preferring the commit an
+// annotated tag points to over the tag itself. An empty ref resolves the remote HEAD.
+func resolveRef(refs []*plumbing.Reference, ref string) (string, error) {
+	byName := map[plumbing.ReferenceName]*plumbing.Reference{}
+	for _, r := range refs {
+		byName[r.Name()] = r
+	}
+
+	names := []plumbing.ReferenceName{plumbing.HEAD}
+	if ref != "" {
+		names = []plumbing.ReferenceName{plumbing.ReferenceName(ref), plumbing.NewBranchReferenceName(ref), plumbing.NewTagReferenceName(ref)}
+	}
+	for _, name := range names {
+		// Follow symbolic references, e.g. HEAD pointing to the default branch.
+		for i := 0; i < 10; i++ {
+			r, ok := byName[name]
+			if !ok {
+				break
 			}
-		} else {
-			return err
+			if r.Type() == plumbing.SymbolicReference {
+				name = r.Target()
+				continue
+			}
+			if peeled, ok := byName[name+"^{}"]; ok {
+				r = peeled
+			}
+			return r.Hash().String(), nil
 		}
 	}
+	if ref == "" {
+		return "", fmt.Errorf("remote HEAD not found")
+	}
+	return "", fmt.Errorf("ref %s not found", ref)
+}
 
-	entries, err := os.ReadDir(src)
+// httpAuth returns the authentication for cloning the repository at src over HTTP(S) with the
+// credentials the gather options provide for its host, or nil if there are none.
+func httpAuth(ctx context.Context, src string) (transport.AuthMethod, error) {
+	u, err := url.Parse(src)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil, nil
+	}
+	creds, err := gogather.OptionsFromContext(ctx).Credentials(ctx, u.Hostname())
 	if err != nil {
-		return err
+		return nil, err
 	}
+	if creds == nil {
+		return nil, nil
+	}
+	if creds.Username == "" {
+		return &githttp.TokenAuth{Token: creds.Password}, nil
+	}
+	return &githttp.BasicAuth{Username: creds.Username, Password: creds.Password}, nil
+}
 
-	for _, entry := range entries {
-		srcPath := filepath.Join(src, entry.Name())
-		dstPath := filepath.Join(dst, entry.Name())
+// maxConcurrentCopies limits the number of files transferred concurrently by transferDir to avoid
+// overwhelming system resources.
+const maxConcurrentCopies = 10
 
-		if entry.IsDir() {
-			err = copyDir(srcPath, dstPath)
-			if err != nil {
-				return err
+// copyDir copies the contents of the src directory to dst directory. Up to maxConcurrentCopies
+// files are copied concurrently, and the copied files are counted in the progress of ctx. The
+// first error encountered stops the copy.
+func copyDir(ctx context.Context, src string, dst string) error {
+	return transferDir(ctx, src, dst, copyFile)
+}
+
+// moveDir moves the contents of the src directory, e.g. a subdirectory of a scratch clone, to dst
+// directory, which avoids copying the files when both are on the same filesystem: src is renamed
+// to dst if dst does not exist and src only holds directories and regular files, otherwise the
+// regular files are renamed one by one. The files that cannot be renamed, e.g. because dst is on
+// another filesystem, and the other file types are copied, see copyDir. src is left incomplete.
+func moveDir(ctx context.Context, src string, dst string) error {
+	src = filepath.Clean(src)
+	dst = filepath.Clean(dst)
+
+	if _, err := os.Lstat(dst); errors.Is(err, fs.ErrNotExist) && holdsOnlyFiles(src) {
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(src, dst); err == nil {
+			return gogather.CountWrittenDir(ctx, dst)
+		}
+	}
+
+	// Once a rename failed because dst is on another filesystem, the others would fail too.
+	var crossDevice atomic.Bool
+	return transferDir(ctx, src, dst, func(ctx context.Context, src, dst string) error {
+		if crossDevice.Load() {
+			return copyFile(ctx, src, dst)
+		}
+		info, err := os.Lstat(src)
+		if err != nil || !info.Mode().IsRegular() {
+			return copyFile(ctx, src, dst)
+		}
+		if err := os.Rename(src, dst); err != nil {
+			if errors.Is(err, syscall.EXDEV) {
+				crossDevice.Store(true)
 			}
-		} else {
-			err = copyFile(srcPath, dstPath)
+			return copyFile(ctx, src, dst)
+		}
+		if err := gogather.CountWritten(ctx, info.Size()); err != nil {
+			return err
+		}
+		gogather.CountItems(ctx, 1)
+		return nil
+	})
+}
+
+// holdsOnlyFiles reports whether the tree at dir only holds directories and regular files.
+func holdsOnlyFiles(dir string) bool {
+	found := false
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			found = true
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return err == nil && !found
+}
+
+// transferDir transfers the files below the src directory to the same paths below dst directory
+// with transfer, creating the directories of dst on the way. Up to maxConcurrentCopies files are
+// transferred concurrently. The first error encountered stops the transfer.
+func transferDir(ctx context.Context, src string, dst string, transfer func(ctx context.Context, src, dst string) error) error {
+	src = filepath.Clean(src)
+	dst = filepath.Clean(dst)
+
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("error getting source directory info: %w", err)
+	}
+
+	if !srcInfo.IsDir() {
+		return fmt.Errorf("%s is not a directory", src)
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxConcurrentCopies)
+
+	walkErr := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := gctx.Err(); err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		dstPath := filepath.Join(dst, relPath)
+
+		if d.IsDir() {
+			info, err := d.Info()
 			if err != nil {
 				return err
 			}
+			return os.MkdirAll(dstPath, info.Mode().Perm())
 		}
+
+		g.Go(func() error {
+			return transfer(gctx, path, dstPath)
+		})
+		return nil
+	})
+	// An error of a copy cancels the walk, so it is the cause of the walk error.
+	if err := g.Wait(); err != nil {
+		return err
 	}
-	return nil
+	return walkErr
 }
 
-// copyFile copies a file from src to dst
-func copyFile(src string, dst string) error {
+// copyFile copies a file from src to dst, counting it in the progress of ctx
+func copyFile(ctx context.Context, src string, dst string) error {
 	srcFile, err := os.Open(src)
 	if err != nil {
 		return err
@@ -224,7 +514,7 @@ func copyFile(src string, dst string) error {
 	}
 	defer dstFile.Close()
 
-	_, err = io.Copy(dstFile, srcFile)
+	_, err = gogather.Copy(dstFile, gogather.WrapReader(ctx, srcFile))
 	if err != nil {
 		return err
 	}
@@ -233,20 +523,11 @@ func copyFile(src string, dst string) error {
 	if err != nil {
 		return err
 	}
-	return os.Chmod(dst, srcInfo.Mode())
-}
-
-// extractKeyFromQuery extracts the value of the specified key from the query parameters and extracts a subdir, if present.
-func extractKeyFromQuery(q url.Values, key string, subdir *string) string {
-	value := q.Get(key)
-	if strings.Contains(value, "//") {
-		parts := strings.SplitN(value, "//", 2)
-		*subdir = parts[1]
-		q.Del(key)
-		return parts[0]
-	}
-	q.Del(key)
-	return value
+	if err := os.Chmod(dst, srcInfo.Mode()); err != nil {
+		return err
+	}
+	gogather.CountItems(ctx, 1)
+	return nil
 }
 
 // getGitCloneOptions returns the clone options for the git repository.
@@ -276,53 +557,50 @@ func getCloneOptions(source string, auth SSHAuthenticator) (*git.CloneOptions, e
 	return cloneOpts, nil
 }
 
-// processUrl processes the raw URL and returns the source URL, ref, subdir, and depth.
-func processUrl(rawURL string) (src, ref, subdir, depth string, err error) {
-	// Check if the URL is a git URL and if it is not a SSH URL, convert it to HTTPS
-	t, err := gogather.ClassifyURI(rawURL)
+// parseSource parses source with gogather.ParseSource and returns it along with the URL of the
+// repository to clone: sources without a scheme are cloned over HTTPS, scp-like sources over SSH,
+// and the path ends with ".git". Query parameters other than ref and depth are kept.
+func parseSource(source string) (*gogather.Source, *url.URL, error) {
+	s, err := gogather.ParseSource(source)
 	if err != nil {
-		return src, ref, subdir, depth, fmt.Errorf("failed to classify URI: %w", err)
-	}
-
-	// Check if the rawURL contains "::" and split it to get the actual URL if it does
-	if strings.Contains(rawURL, "::") {
-		rawURL = strings.Split(rawURL, "::")[1]
+		return nil, nil, err
 	}
 
-	if t == gogather.GitURI && !strings.Contains(rawURL, "git@") && !strings.Contains(rawURL, "://") {
+	rawURL := s.BaseURL
+	if s.Type == gogather.GitURI && !strings.Contains(rawURL, "git@") && !strings.Contains(rawURL, "://") {
 		rawURL = "https://" + rawURL
 	}
 
-	// Parse the raw URL with the gitUrls package. This will format the URL correctly
-	parsedURL, err := giturls.Parse(rawURL)
+	// Parse the URL with the gitUrls package. This will format the URL correctly
+	u, err := giturls.Parse(rawURL)
 	if err != nil {
-		return src, ref, subdir, depth, fmt.Errorf("failed to parse URL: %w", err)
-	}
-
-	// Parse the URL again with the url package to extract the query parameters, etc.
-	u, err := url.Parse(parsedURL.String())
-	if err != nil {
-		return src, ref, subdir, depth, fmt.Errorf("failed to reparse URL: %w", err)
-	}
-
-	// Extract the ref, subdir, and depth from the query parameters
-	q := u.Query()
-	ref = extractKeyFromQuery(q, "ref", &subdir)
-	depth = extractKeyFromQuery(q, "depth", &subdir)
-	u.RawQuery = q.Encode()
-
-	// If the path contains "//", split it to get the actual path and subdir
-	if strings.Contains(u.Path, "//") {
-		parts := strings.SplitN(u.Path, "//", 2)
-		u.Path = parts[0]
-		subdir = parts[1]
+		return nil, nil, fmt.Errorf("failed to parse URL: %w", err)
 	}
 
 	// If the path does not end with ".git", append it
 	if !strings.HasSuffix(u.Path, ".git") {
 		u.Path += ".git"
 	}
+	u.RawQuery = s.QueryParams.Encode()
+	return s, u, nil
+}
+
+// progressWriter logs the progress messages a git remote sends while cloning, one line at a time.
+type progressWriter struct {
+	log *slog.Logger
+	buf []byte
+}
 
-	// Return the URL, ref, subdir, and depth
-	return u.String(), ref, subdir, depth, nil
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			return len(p), nil
+		}
+		if line := strings.TrimSpace(string(w.buf[:i])); line != "" {
+			w.log.Debug("clone progress", "message", line)
+		}
+		w.buf = w.buf[i+1:]
+	}
 }
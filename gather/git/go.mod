@@ -9,6 +9,7 @@ require (
 	github.com/enterprise-contract/go-gather/metadata/git v0.0.2
 	github.com/go-git/go-git/v5 v5.12.0
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/sync v0.7.0
 )
 
 require (
@@ -0,0 +1,331 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package github provides functionality for gathering the assets of GitHub releases. It includes
+// an implementation of the Gatherer interface, GitHubReleaseGatherer, which looks up an asset of a
+// release with the GitHub REST API and downloads it to a destination path.
+//
+// Sources are of the form github-release://owner/repo/tag/asset, e.g.
+// github-release://org/policies/v1.0.0/bundle.tar.gz. The tag "latest" selects the latest release
+// of the repository.
+//
+// Example usage:
+//
+//	g := &github.GitHubReleaseGatherer{Token: os.Getenv("GITHUB_TOKEN")}
+//	m, err := g.Gather(context.Background(), "github-release://org/policies/v1.0.0/bundle.tar.gz", "/tmp/bundle.tar.gz")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package github
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+	githubMetadata "github.com/enterprise-contract/go-gather/metadata/github"
+)
+
+// DefaultBaseURL is the URL of the GitHub REST API.
+const DefaultBaseURL = "https://api.github.com"
+
+// GitHubReleaseGatherer downloads the assets of GitHub releases. The zero value uses the public
+// GitHub API, authenticated with the token of the GITHUB_TOKEN environment variable, if set.
+type GitHubReleaseGatherer struct {
+	// BaseURL is the URL of the GitHub REST API, e.g. https://github.example.com/api/v3 for
+	// GitHub Enterprise Server. If empty, DefaultBaseURL is used.
+	BaseURL string
+	// Token authenticates the requests, e.g. to download the assets of private repositories. If
+	// empty, the credentials the gather options provide for the host of the API are used, and
+	// then the GITHUB_TOKEN environment variable.
+	Token string
+	// Client sends the requests. Its transport is configured with the proxy and TLS settings of
+	// the gather options.
+	Client http.Client
+	// Logger receives diagnostic messages. When nil, the logger of the gather options is used.
+	Logger *slog.Logger
+}
+
+// release is the subset of a release of the GitHub API that is used.
+type release struct {
+	ID      int64   `json:"id"`
+	TagName string  `json:"tag_name"`
+	Assets  []asset `json:"assets"`
+}
+
+// asset is the subset of a release asset of the GitHub API that is used.
+type asset struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	// Digest is the digest GitHub computed for the asset, e.g. "sha256:<hex>". It is not set
+	// for assets uploaded before GitHub started recording digests.
+	Digest string `json:"digest"`
+}
+
+// sha256 returns the hex encoded SHA256 digest GitHub recorded for the asset, if any.
+func (a *asset) sha256() string {
+	if digest, ok := strings.CutPrefix(a.Digest, "sha256:"); ok {
+		return digest
+	}
+	return ""
+}
+
+func (g *GitHubReleaseGatherer) Gather(ctx context.Context, source, destination string) (_ metadata.Metadata, err error) {
+	defer func() {
+		err = gogather.RedactError(err)
+		gogather.FinishProgress(ctx, err)
+	}()
+	startedAt := time.Now()
+
+	loc, err := parseSource(source)
+	if err != nil {
+		return nil, err
+	}
+	rel, a, err := g.lookup(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(destination, string(os.PathSeparator)) || strings.HasSuffix(destination, "/") {
+		destination = filepath.Join(destination, a.Name)
+	} else if fi, err := os.Stat(destination); err == nil && fi.IsDir() {
+		destination = filepath.Join(destination, a.Name)
+	}
+	if err := gogather.CheckWritten(ctx, a.Size); err != nil {
+		return nil, err
+	}
+	gogather.StartProgress(ctx, a.Size, 1)
+
+	digest, err := g.download(ctx, a, destination)
+	if err != nil {
+		return nil, err
+	}
+	if expected := a.sha256(); expected != "" && expected != digest {
+		_ = os.Remove(destination)
+		return nil, fmt.Errorf("digest of asset %s does not match the digest recorded by GitHub: %s != %s", a.Name, digest, expected)
+	}
+	if err := gogather.VerifyChecksum(ctx, destination); err != nil {
+		_ = os.Remove(destination)
+		return nil, err
+	}
+	gogather.CountItems(ctx, 1)
+
+	m := newMetadata(loc, rel, a)
+	m.SHA256 = digest
+	resolved, _ := m.GetPinnedURL(gogather.RedactURL(source))
+	m.Common = metadata.NewCommon(githubMetadata.Type, gogather.RedactURL(source), resolved, destination, startedAt)
+	return m, nil
+}
+
+// Resolve looks up the asset of source without downloading it. The digest of the asset, and with
+// it the ResolvedURI of the metadata, is only set if GitHub recorded a digest for the asset.
+func (g *GitHubReleaseGatherer) Resolve(ctx context.Context, source string) (_ metadata.Metadata, err error) {
+	defer func() { err = gogather.RedactError(err) }()
+	startedAt := time.Now()
+
+	loc, err := parseSource(source)
+	if err != nil {
+		return nil, err
+	}
+	rel, a, err := g.lookup(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	m := newMetadata(loc, rel, a)
+	m.SHA256 = a.sha256()
+	resolved, _ := m.GetPinnedURL(gogather.RedactURL(source))
+	m.Common = metadata.NewCommon(githubMetadata.Type, gogather.RedactURL(source), resolved, "", startedAt)
+	return m, nil
+}
+
+func newMetadata(loc *location, rel *release, a *asset) *githubMetadata.GitHubReleaseMetadata {
+	return &githubMetadata.GitHubReleaseMetadata{
+		Owner:       loc.owner,
+		Repository:  loc.repo,
+		Tag:         rel.TagName,
+		ReleaseID:   rel.ID,
+		Asset:       a.Name,
+		AssetID:     a.ID,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+	}
+}
+
+// lookup returns the release of loc and its asset.
+func (g *GitHubReleaseGatherer) lookup(ctx context.Context, loc *location) (*release, *asset, error) {
+	base := g.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	endpoint := fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s", strings.TrimSuffix(base, "/"), url.PathEscape(loc.owner), url.PathEscape(loc.repo), url.PathEscape(loc.tag))
+	if loc.tag == "latest" {
+		endpoint = fmt.Sprintf("%s/repos/%s/%s/releases/latest", strings.TrimSuffix(base, "/"), url.PathEscape(loc.owner), url.PathEscape(loc.repo))
+	}
+
+	gogather.Logger(ctx, g.Logger).Debug("looking up release", "owner", loc.owner, "repo", loc.repo, "tag", loc.tag)
+	resp, err := g.get(ctx, endpoint, "application/vnd.github+json")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to look up release %s of %s/%s: %w", loc.tag, loc.owner, loc.repo, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil, fmt.Errorf("release %s of %s/%s not found", loc.tag, loc.owner, loc.repo)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("failed to look up release %s of %s/%s: %w", loc.tag, loc.owner, loc.repo, &gogather.StatusError{StatusCode: resp.StatusCode})
+	}
+
+	rel := &release{}
+	if err := json.NewDecoder(resp.Body).Decode(rel); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode release %s of %s/%s: %w", loc.tag, loc.owner, loc.repo, err)
+	}
+	for i := range rel.Assets {
+		if rel.Assets[i].Name == loc.asset {
+			return rel, &rel.Assets[i], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("release %s of %s/%s has no asset %s", rel.TagName, loc.owner, loc.repo, loc.asset)
+}
+
+// download writes the content of a to path, returning its hex encoded SHA256 digest.
+func (g *GitHubReleaseGatherer) download(ctx context.Context, a *asset, path string) (string, error) {
+	gogather.Logger(ctx, g.Logger).Debug("downloading asset", "asset", a.Name, "url", a.URL, "destination", path)
+	resp, err := g.get(ctx, a.URL, "application/octet-stream")
+	if err != nil {
+		return "", fmt.Errorf("failed to download asset %s: %w", a.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download asset %s: %w", a.Name, &gogather.StatusError{StatusCode: resp.StatusCode})
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	h := sha256.New()
+	_, err = gogather.CopySized(io.MultiWriter(f, h), gogather.WrapReader(ctx, resp.Body), resp.ContentLength)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to download asset %s: %w", a.Name, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// get sends a GET request for u accepting the media type, authenticated with the token of the
+// gatherer, with the client configured for the gather options, see gogather.HTTPClient, which
+// checks every redirect, e.g. to the storage the assets are served from. The token is not sent to
+// the hosts redirected to.
+func (g *GitHubReleaseGatherer) get(ctx context.Context, u, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if err := gogather.CheckHost(ctx, req.URL.Scheme, req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", "Go-Gather")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	token, err := g.token(ctx, req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client, err := gogather.HTTPClient(ctx, &g.Client)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// token returns the token authenticating the requests to host: the Token of the gatherer, the
+// password of the credentials the gather options provide for host, or the GITHUB_TOKEN environment
+// variable.
+func (g *GitHubReleaseGatherer) token(ctx context.Context, host string) (string, error) {
+	if g.Token != "" {
+		return g.Token, nil
+	}
+	creds, err := gogather.OptionsFromContext(ctx).Credentials(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	if creds != nil {
+		return creds.Password, nil
+	}
+	return os.Getenv("GITHUB_TOKEN"), nil
+}
+
+// location identifies an asset of a release.
+type location struct {
+	owner, repo, tag, asset string
+}
+
+// parseSource parses a source of the form github-release://owner/repo/tag/asset. Tags may contain
+// slashes, the asset is the last element of the path.
+func parseSource(source string) (*location, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source %s: %w", source, err)
+	}
+	if u.Scheme != "github-release" {
+		return nil, fmt.Errorf("unsupported GitHub release source: %s", source)
+	}
+	if u.RawQuery != "" {
+		return nil, fmt.Errorf("unsupported parameters of %s", source)
+	}
+
+	parts := strings.Split(u.Host+u.Path, "/")
+	if len(parts) < 4 || u.User != nil {
+		return nil, fmt.Errorf("source must be of the form github-release://owner/repo/tag/asset: %s", source)
+	}
+	loc := &location{
+		owner: parts[0],
+		repo:  parts[1],
+		tag:   strings.Join(parts[2:len(parts)-1], "/"),
+		asset: parts[len(parts)-1],
+	}
+	for _, p := range parts {
+		if p == "" {
+			return nil, fmt.Errorf("source must be of the form github-release://owner/repo/tag/asset: %s", source)
+		}
+	}
+	return loc, nil
+}
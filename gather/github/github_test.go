@@ -0,0 +1,209 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	githubMetadata "github.com/enterprise-contract/go-gather/metadata/github"
+)
+
+// digest is the SHA256 digest of "test data".
+const digest = "916f0027a575074ce72a331777c3478d6513f786a591bd892da1a577bf2335f9"
+
+// newServer starts a fake GitHub API serving the release v1.0.0 of org/repo, which is also the
+// latest release, with the assets bundle.tar.gz and broken.txt, the recorded digest of which does
+// not match its content. Requests must be authenticated with token. Assets are served from a
+// separate storage path the API redirects to.
+func newServer(t *testing.T, token string) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/storage/") {
+			fmt.Fprint(w, "test data")
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/repos/org/repo/releases/tags/v1.0.0", "/repos/org/repo/releases/latest":
+			fmt.Fprintf(w, `{"id": 1, "tag_name": "v1.0.0", "assets": [
+				{"id": 2, "name": "bundle.tar.gz", "url": "%[1]s/repos/org/repo/releases/assets/2", "content_type": "application/gzip", "size": 9, "digest": "sha256:%[2]s"},
+				{"id": 3, "name": "broken.txt", "url": "%[1]s/repos/org/repo/releases/assets/3", "size": 9, "digest": "sha256:abc"}
+			]}`, srv.URL, digest)
+		case "/repos/org/repo/releases/assets/2", "/repos/org/repo/releases/assets/3":
+			if r.Header.Get("Accept") != "application/octet-stream" {
+				w.WriteHeader(http.StatusNotAcceptable)
+				return
+			}
+			http.Redirect(w, r, "/storage/"+filepath.Base(r.URL.Path), http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestGitHubReleaseGatherer_Gather tests downloading an asset of a release
+func TestGitHubReleaseGatherer_Gather(t *testing.T) {
+	srv := newServer(t, "token")
+	dir := t.TempDir()
+
+	g := &GitHubReleaseGatherer{BaseURL: srv.URL, Token: "token"}
+	source := "github-release://org/repo/v1.0.0/bundle.tar.gz"
+	m, err := g.Gather(context.Background(), source, dir+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	destination := filepath.Join(dir, "bundle.tar.gz")
+	if data, err := os.ReadFile(destination); err != nil || string(data) != "test data" {
+		t.Fatalf("unexpected content: %q, %v", data, err)
+	}
+
+	gm := m.(*githubMetadata.GitHubReleaseMetadata)
+	if gm.Destination != destination || gm.Owner != "org" || gm.Repository != "repo" || gm.Tag != "v1.0.0" || gm.ReleaseID != 1 || gm.AssetID != 2 || gm.Size != 9 || gm.SHA256 != digest {
+		t.Errorf("unexpected metadata: %+v", gm)
+	}
+	if gm.ResolvedURI != source+"?checksum=sha256:"+digest {
+		t.Errorf("unexpected resolved URI: %s", gm.ResolvedURI)
+	}
+}
+
+// TestGitHubReleaseGatherer_Gather_Latest tests downloading an asset of the latest release,
+// authenticated with the credentials of the gather options
+func TestGitHubReleaseGatherer_Gather_Latest(t *testing.T) {
+	srv := newServer(t, "secret")
+	t.Setenv("GITHUB_TOKEN", "")
+	destination := filepath.Join(t.TempDir(), "bundle.tar.gz")
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{
+		Auth: gogather.HostCredentials{"127.0.0.1": {Password: "secret"}},
+	})
+	m, err := (&GitHubReleaseGatherer{BaseURL: srv.URL}).Gather(ctx, "github-release://org/repo/latest/bundle.tar.gz", destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gm := m.(*githubMetadata.GitHubReleaseMetadata); gm.Tag != "v1.0.0" || gm.Destination != destination {
+		t.Errorf("unexpected metadata: %+v", gm)
+	}
+
+	t.Setenv("GITHUB_TOKEN", "secret")
+	if _, err := (&GitHubReleaseGatherer{BaseURL: srv.URL}).Gather(context.Background(), "github-release://org/repo/latest/bundle.tar.gz", destination); err != nil {
+		t.Errorf("unexpected error with the token of the environment: %v", err)
+	}
+}
+
+// TestGitHubReleaseGatherer_Gather_Errors tests failing downloads
+func TestGitHubReleaseGatherer_Gather_Errors(t *testing.T) {
+	srv := newServer(t, "token")
+	g := &GitHubReleaseGatherer{BaseURL: srv.URL, Token: "token"}
+	dir := t.TempDir()
+
+	_, err := g.Gather(context.Background(), "github-release://org/repo/v2.0.0/bundle.tar.gz", dir)
+	if err == nil || err.Error() != "release v2.0.0 of org/repo not found" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = g.Gather(context.Background(), "github-release://org/repo/v1.0.0/missing.zip", dir)
+	if err == nil || err.Error() != "release v1.0.0 of org/repo has no asset missing.zip" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	destination := filepath.Join(dir, "broken.txt")
+	_, err = g.Gather(context.Background(), "github-release://org/repo/v1.0.0/broken.txt", destination)
+	if err == nil || !strings.HasPrefix(err.Error(), "digest of asset broken.txt does not match") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(destination); !os.IsNotExist(err) {
+		t.Errorf("expected the asset to be removed: %v", err)
+	}
+
+	var status *gogather.StatusError
+	_, err = (&GitHubReleaseGatherer{BaseURL: srv.URL, Token: "wrong"}).Gather(context.Background(), "github-release://org/repo/v1.0.0/bundle.tar.gz", dir)
+	if !errors.As(err, &status) || status.StatusCode != http.StatusUnauthorized || status.Retryable() {
+		t.Errorf("expected a status error, got %v", err)
+	}
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{MaxSize: 5})
+	if _, err := g.Gather(ctx, "github-release://org/repo/v1.0.0/bundle.tar.gz", dir); !errors.Is(err, gogather.ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+
+	ctx = gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{HostPolicy: &gogather.HostPolicy{DeniedHosts: []string{"127.0.0.1"}}})
+	var denied *gogather.HostDeniedError
+	if _, err := g.Gather(ctx, "github-release://org/repo/v1.0.0/bundle.tar.gz", dir); !errors.As(err, &denied) {
+		t.Errorf("expected the host policy to deny the API, got %v", err)
+	}
+}
+
+// TestGitHubReleaseGatherer_Resolve tests resolving an asset to the digest recorded by GitHub
+func TestGitHubReleaseGatherer_Resolve(t *testing.T) {
+	srv := newServer(t, "token")
+	source := "github-release://org/repo/latest/bundle.tar.gz"
+	m, err := (&GitHubReleaseGatherer{BaseURL: srv.URL, Token: "token"}).Resolve(context.Background(), source)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	gm := m.(*githubMetadata.GitHubReleaseMetadata)
+	if gm.Tag != "v1.0.0" || gm.SHA256 != digest || gm.ResolvedURI != source+"?checksum=sha256:"+digest {
+		t.Errorf("unexpected metadata: %+v", gm)
+	}
+}
+
+// TestParseSource tests parsing GitHub release sources
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected location
+		err      string
+	}{
+		{source: "github-release://org/repo/v1.0.0/bundle.tar.gz", expected: location{owner: "org", repo: "repo", tag: "v1.0.0", asset: "bundle.tar.gz"}},
+		{source: "github-release://org/repo/release/2024/bundle.zip", expected: location{owner: "org", repo: "repo", tag: "release/2024", asset: "bundle.zip"}},
+		{source: "github-release://org/repo/v1.0.0", err: "source must be of the form github-release://owner/repo/tag/asset: github-release://org/repo/v1.0.0"},
+		{source: "github-release://org/repo/v1.0.0/", err: "source must be of the form github-release://owner/repo/tag/asset: github-release://org/repo/v1.0.0/"},
+		{source: "github-release://org/repo/v1.0.0/bundle.zip?ref=main", err: "unsupported parameters of github-release://org/repo/v1.0.0/bundle.zip?ref=main"},
+		{source: "https://github.com/org/repo/releases/download/v1.0.0/bundle.zip", err: "unsupported GitHub release source: https://github.com/org/repo/releases/download/v1.0.0/bundle.zip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			l, err := parseSource(tt.source)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *l != tt.expected {
+				t.Errorf("unexpected location: got %+v, want %+v", *l, tt.expected)
+			}
+		})
+	}
+}
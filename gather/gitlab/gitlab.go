@@ -0,0 +1,315 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package gitlab provides functionality for gathering files published by GitLab projects. It
+// includes an implementation of the Gatherer interface, GitLabGatherer, which downloads an asset
+// of a release or a file of a generic package with the GitLab REST API.
+//
+// Sources name the host of the GitLab instance and the path of the project, followed by the path
+// of the file below "/-/", like the URLs of the GitLab web interface:
+//
+//	gitlab://gitlab.com/group/project/-/releases/v1.0.0/bundle.tar.gz
+//	gitlab://gitlab.com/group/project/-/packages/generic/policies/1.0.0/bundle.tar.gz
+//
+// Example usage:
+//
+//	g := &gitlab.GitLabGatherer{Token: os.Getenv("GITLAB_TOKEN")}
+//	m, err := g.Gather(context.Background(), "gitlab://gitlab.com/group/project/-/releases/v1.0.0/bundle.tar.gz", "/tmp/bundle.tar.gz")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package gitlab
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+	gitlabMetadata "github.com/enterprise-contract/go-gather/metadata/gitlab"
+)
+
+// GitLabGatherer downloads release assets and generic package files from GitLab instances. The
+// zero value uses the instance named by the host of the source over HTTPS, authenticated with the
+// token of the GITLAB_TOKEN environment variable, if set.
+type GitLabGatherer struct {
+	// BaseURLs maps the hosts of sources to the URLs of self-hosted instances that are not
+	// served over HTTPS at the root of the host, e.g. "gitlab.example.com" to
+	// "https://example.com/gitlab".
+	BaseURLs map[string]string
+	// Token is a personal, project or group access token authenticating the requests to the
+	// instance, e.g. to download the files of private projects. If empty, the password of the
+	// credentials the gather options provide for the host of the instance is used, and then the
+	// GITLAB_TOKEN environment variable.
+	Token string
+	// Client sends the requests. Its transport is configured with the proxy and TLS settings of
+	// the gather options.
+	Client http.Client
+	// Logger receives diagnostic messages. When nil, the logger of the gather options is used.
+	Logger *slog.Logger
+}
+
+// release is the subset of a release of the GitLab API that is used.
+type release struct {
+	TagName string `json:"tag_name"`
+	Assets  struct {
+		Links []link `json:"links"`
+	} `json:"assets"`
+}
+
+// link is the subset of a release asset link of the GitLab API that is used.
+type link struct {
+	Name           string `json:"name"`
+	URL            string `json:"url"`
+	DirectAssetURL string `json:"direct_asset_url"`
+}
+
+func (g *GitLabGatherer) Gather(ctx context.Context, source, destination string) (_ metadata.Metadata, err error) {
+	defer func() {
+		err = gogather.RedactError(err)
+		gogather.FinishProgress(ctx, err)
+	}()
+	startedAt := time.Now()
+
+	loc, err := parseSource(source)
+	if err != nil {
+		return nil, err
+	}
+	api, err := g.apiURL(loc.host)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &gitlabMetadata.GitLabMetadata{Project: loc.project, File: loc.file}
+	var download string
+	if loc.pkg != "" {
+		m.Package, m.Version = loc.pkg, loc.version
+		download = fmt.Sprintf("%s/projects/%s/packages/generic/%s/%s/%s", api, url.PathEscape(loc.project), url.PathEscape(loc.pkg), url.PathEscape(loc.version), url.PathEscape(loc.file))
+	} else {
+		rel, l, err := g.lookup(ctx, api, loc)
+		if err != nil {
+			return nil, err
+		}
+		m.Tag = rel.TagName
+		download = l.DirectAssetURL
+		if download == "" {
+			download = l.URL
+		}
+	}
+
+	if strings.HasSuffix(destination, string(os.PathSeparator)) || strings.HasSuffix(destination, "/") {
+		destination = filepath.Join(destination, loc.file)
+	} else if fi, err := os.Stat(destination); err == nil && fi.IsDir() {
+		destination = filepath.Join(destination, loc.file)
+	}
+
+	if m.Size, m.SHA256, err = g.download(ctx, api, download, loc.file, destination); err != nil {
+		return nil, err
+	}
+	if err := gogather.VerifyChecksum(ctx, destination); err != nil {
+		_ = os.Remove(destination)
+		return nil, err
+	}
+	gogather.CountItems(ctx, 1)
+
+	resolved, _ := m.GetPinnedURL(gogather.RedactURL(source))
+	m.Common = metadata.NewCommon(gitlabMetadata.Type, gogather.RedactURL(source), resolved, destination, startedAt)
+	return m, nil
+}
+
+// apiURL returns the URL of the REST API of the instance serving host.
+func (g *GitLabGatherer) apiURL(host string) (string, error) {
+	base, ok := g.BaseURLs[host]
+	if !ok {
+		base = "https://" + host
+	}
+	u, err := url.Parse(base)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid URL of the GitLab instance of %s: %s", host, base)
+	}
+	return strings.TrimSuffix(base, "/") + "/api/v4", nil
+}
+
+// lookup returns the release of loc and the link of its asset.
+func (g *GitLabGatherer) lookup(ctx context.Context, api string, loc *location) (*release, *link, error) {
+	gogather.Logger(ctx, g.Logger).Debug("looking up release", "project", loc.project, "tag", loc.tag)
+	resp, err := g.get(ctx, api, fmt.Sprintf("%s/projects/%s/releases/%s", api, url.PathEscape(loc.project), url.PathEscape(loc.tag)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to look up release %s of %s: %w", loc.tag, loc.project, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil, fmt.Errorf("release %s of %s not found", loc.tag, loc.project)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("failed to look up release %s of %s: %w", loc.tag, loc.project, &gogather.StatusError{StatusCode: resp.StatusCode})
+	}
+
+	rel := &release{}
+	if err := json.NewDecoder(resp.Body).Decode(rel); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode release %s of %s: %w", loc.tag, loc.project, err)
+	}
+	for i, l := range rel.Assets.Links {
+		if l.Name == loc.file {
+			return rel, &rel.Assets.Links[i], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("release %s of %s has no asset %s", rel.TagName, loc.project, loc.file)
+}
+
+// download writes the file at u to path, returning its size and hex encoded SHA256 digest.
+func (g *GitLabGatherer) download(ctx context.Context, api, u, name, path string) (int64, string, error) {
+	gogather.Logger(ctx, g.Logger).Debug("downloading file", "url", gogather.RedactURL(u), "destination", path)
+	resp, err := g.get(ctx, api, u)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, "", fmt.Errorf("%s not found", name)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", fmt.Errorf("failed to download %s: %w", name, &gogather.StatusError{StatusCode: resp.StatusCode})
+	}
+	if resp.ContentLength > 0 {
+		if err := gogather.CheckWritten(ctx, resp.ContentLength); err != nil {
+			return 0, "", err
+		}
+	}
+	gogather.StartProgress(ctx, resp.ContentLength, 1)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create file: %w", err)
+	}
+	h := sha256.New()
+	n, err := gogather.CopySized(io.MultiWriter(f, h), gogather.WrapReader(ctx, resp.Body), resp.ContentLength)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// get sends a GET request for u. Requests to the host of the instance serving api are
+// authenticated with the token of the gatherer; release assets may link to other hosts, which do
+// not receive it. The client is configured for the gather options, see gogather.HTTPClient.
+func (g *GitLabGatherer) get(ctx context.Context, api, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if err := gogather.CheckHost(ctx, req.URL.Scheme, req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Go-Gather")
+	if instance, err := url.Parse(api); err == nil && instance.Host == req.URL.Host {
+		token, err := g.token(ctx, instance.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		if token != "" {
+			req.Header.Set("PRIVATE-TOKEN", token)
+		}
+	}
+
+	client, err := gogather.HTTPClient(ctx, &g.Client)
+	if err != nil {
+		return nil, err
+	}
+	checkRedirect := client.CheckRedirect
+	client.CheckRedirect = func(r *http.Request, via []*http.Request) error {
+		// Unlike Authorization, the token header is not dropped by the client on redirects.
+		if r.URL.Host != via[0].URL.Host {
+			r.Header.Del("PRIVATE-TOKEN")
+		}
+		return checkRedirect(r, via)
+	}
+	return client.Do(req)
+}
+
+// token returns the token authenticating the requests to host: the Token of the gatherer, the
+// password of the credentials the gather options provide for host, or the GITLAB_TOKEN environment
+// variable.
+func (g *GitLabGatherer) token(ctx context.Context, host string) (string, error) {
+	if g.Token != "" {
+		return g.Token, nil
+	}
+	creds, err := gogather.OptionsFromContext(ctx).Credentials(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	if creds != nil {
+		return creds.Password, nil
+	}
+	return os.Getenv("GITLAB_TOKEN"), nil
+}
+
+// location identifies a release asset, with tag set, or a generic package file, with pkg and
+// version set, of a project.
+type location struct {
+	host, project, tag, pkg, version, file string
+}
+
+// parseSource parses a source of the form gitlab://host/project/-/releases/tag/asset or
+// gitlab://host/project/-/packages/generic/package/version/file. Projects may be nested in
+// subgroups and tags may contain slashes.
+func parseSource(source string) (*location, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source %s: %w", source, err)
+	}
+	if u.Scheme != "gitlab" {
+		return nil, fmt.Errorf("unsupported GitLab source: %s", source)
+	}
+	if u.RawQuery != "" {
+		return nil, fmt.Errorf("unsupported parameters of %s", source)
+	}
+	invalid := fmt.Errorf("source must be of the form gitlab://host/project/-/releases/tag/asset or gitlab://host/project/-/packages/generic/package/version/file: %s", source)
+
+	project, rest, ok := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/-/")
+	parts := strings.Split(rest, "/")
+	if !ok || u.Host == "" || u.User != nil || project == "" || slices.Contains(parts, "") {
+		return nil, invalid
+	}
+	loc := &location{host: u.Host, project: project, file: parts[len(parts)-1]}
+	switch {
+	case parts[0] == "releases" && len(parts) >= 3:
+		loc.tag = strings.Join(parts[1:len(parts)-1], "/")
+	case parts[0] == "packages" && len(parts) == 5 && parts[1] == "generic":
+		loc.pkg, loc.version = parts[2], parts[3]
+	default:
+		return nil, invalid
+	}
+	return loc, nil
+}
@@ -0,0 +1,190 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	gitlabMetadata "github.com/enterprise-contract/go-gather/metadata/gitlab"
+)
+
+// digest is the SHA256 digest of "test data".
+const digest = "916f0027a575074ce72a331777c3478d6513f786a591bd892da1a577bf2335f9"
+
+// newServer starts a fake GitLab instance requiring token, serving the release v1.0.0 of
+// group/sub/project and the generic package policies 1.0.0 of the project. The asset of the
+// release redirects to a separate storage server, which fails the test if it receives the token.
+func newServer(t *testing.T, token string) *httptest.Server {
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "" {
+			t.Error("token sent to the storage server")
+		}
+		fmt.Fprint(w, "test data")
+	}))
+	t.Cleanup(storage.Close)
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/group%2Fsub%2Fproject/releases/v1.0.0":
+			fmt.Fprintf(w, `{"tag_name": "v1.0.0", "assets": {"links": [
+				{"name": "bundle.tar.gz", "url": "%[1]s/uploads/bundle.tar.gz", "direct_asset_url": "%[1]s/group/sub/project/-/releases/v1.0.0/downloads/bundle.tar.gz"}
+			]}}`, srv.URL)
+		case "/group/sub/project/-/releases/v1.0.0/downloads/bundle.tar.gz":
+			http.Redirect(w, r, storage.URL+"/bundle.tar.gz", http.StatusFound)
+		case "/api/v4/projects/group%2Fsub%2Fproject/packages/generic/policies/1.0.0/bundle.tar.gz":
+			fmt.Fprint(w, "test data")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestGitLabGatherer_Gather tests downloading the asset of a release
+func TestGitLabGatherer_Gather(t *testing.T) {
+	srv := newServer(t, "token")
+	dir := t.TempDir()
+
+	g := &GitLabGatherer{BaseURLs: map[string]string{"gitlab.example.com": srv.URL}, Token: "token"}
+	source := "gitlab://gitlab.example.com/group/sub/project/-/releases/v1.0.0/bundle.tar.gz"
+	m, err := g.Gather(context.Background(), source, dir+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	destination := filepath.Join(dir, "bundle.tar.gz")
+	if data, err := os.ReadFile(destination); err != nil || string(data) != "test data" {
+		t.Fatalf("unexpected content: %q, %v", data, err)
+	}
+
+	gm := m.(*gitlabMetadata.GitLabMetadata)
+	if gm.Destination != destination || gm.Project != "group/sub/project" || gm.Tag != "v1.0.0" || gm.File != "bundle.tar.gz" || gm.Size != 9 || gm.SHA256 != digest {
+		t.Errorf("unexpected metadata: %+v", gm)
+	}
+	if gm.ResolvedURI != source+"?checksum=sha256:"+digest {
+		t.Errorf("unexpected resolved URI: %s", gm.ResolvedURI)
+	}
+}
+
+// TestGitLabGatherer_Gather_Package tests downloading the file of a generic package, authenticated
+// with the credentials of the gather options
+func TestGitLabGatherer_Gather_Package(t *testing.T) {
+	srv := newServer(t, "secret")
+	t.Setenv("GITLAB_TOKEN", "")
+	destination := filepath.Join(t.TempDir(), "bundle.tar.gz")
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{
+		Auth: gogather.HostCredentials{"127.0.0.1": {Password: "secret"}},
+	})
+	g := &GitLabGatherer{BaseURLs: map[string]string{"gitlab.example.com": srv.URL}}
+	m, err := g.Gather(ctx, "gitlab://gitlab.example.com/group/sub/project/-/packages/generic/policies/1.0.0/bundle.tar.gz", destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gm := m.(*gitlabMetadata.GitLabMetadata); gm.Package != "policies" || gm.Version != "1.0.0" || gm.Tag != "" || gm.SHA256 != digest {
+		t.Errorf("unexpected metadata: %+v", gm)
+	}
+
+	t.Setenv("GITLAB_TOKEN", "secret")
+	if _, err := g.Gather(context.Background(), "gitlab://gitlab.example.com/group/sub/project/-/packages/generic/policies/1.0.0/bundle.tar.gz", destination); err != nil {
+		t.Errorf("unexpected error with the token of the environment: %v", err)
+	}
+}
+
+// TestGitLabGatherer_Gather_Errors tests failing downloads
+func TestGitLabGatherer_Gather_Errors(t *testing.T) {
+	srv := newServer(t, "token")
+	g := &GitLabGatherer{BaseURLs: map[string]string{"gitlab.example.com": srv.URL}, Token: "token"}
+	dir := t.TempDir()
+
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{source: "gitlab://gitlab.example.com/group/sub/project/-/releases/v2.0.0/bundle.tar.gz", err: "release v2.0.0 of group/sub/project not found"},
+		{source: "gitlab://gitlab.example.com/group/sub/project/-/releases/v1.0.0/missing.zip", err: "release v1.0.0 of group/sub/project has no asset missing.zip"},
+		{source: "gitlab://gitlab.example.com/group/sub/project/-/packages/generic/policies/2.0.0/bundle.tar.gz", err: "bundle.tar.gz not found"},
+	}
+	for _, tt := range tests {
+		if _, err := g.Gather(context.Background(), tt.source, dir); err == nil || err.Error() != tt.err {
+			t.Errorf("unexpected error for %s: got %v, want %s", tt.source, err, tt.err)
+		}
+	}
+
+	var status *gogather.StatusError
+	g.Token = "wrong"
+	_, err := g.Gather(context.Background(), "gitlab://gitlab.example.com/group/sub/project/-/packages/generic/policies/1.0.0/bundle.tar.gz", dir)
+	if !errors.As(err, &status) || status.StatusCode != http.StatusUnauthorized || status.Retryable() {
+		t.Errorf("expected a status error, got %v", err)
+	}
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{HostPolicy: &gogather.HostPolicy{DeniedHosts: []string{"127.0.0.1"}}})
+	var denied *gogather.HostDeniedError
+	if _, err := g.Gather(ctx, "gitlab://gitlab.example.com/group/sub/project/-/releases/v1.0.0/bundle.tar.gz", dir); !errors.As(err, &denied) {
+		t.Errorf("expected the host policy to deny the instance, got %v", err)
+	}
+}
+
+// TestParseSource tests parsing GitLab sources
+func TestParseSource(t *testing.T) {
+	invalid := "source must be of the form gitlab://host/project/-/releases/tag/asset or gitlab://host/project/-/packages/generic/package/version/file: "
+	tests := []struct {
+		source   string
+		expected location
+		err      string
+	}{
+		{source: "gitlab://gitlab.com/group/project/-/releases/v1.0.0/bundle.tar.gz", expected: location{host: "gitlab.com", project: "group/project", tag: "v1.0.0", file: "bundle.tar.gz"}},
+		{source: "gitlab://gitlab.example.com:8443/a/b/c/-/releases/release/2024/bundle.zip", expected: location{host: "gitlab.example.com:8443", project: "a/b/c", tag: "release/2024", file: "bundle.zip"}},
+		{source: "gitlab://gitlab.com/group/project/-/packages/generic/policies/1.0.0/bundle.tar.gz", expected: location{host: "gitlab.com", project: "group/project", pkg: "policies", version: "1.0.0", file: "bundle.tar.gz"}},
+		{source: "gitlab://gitlab.com/group/project/-/releases/v1.0.0", err: invalid + "gitlab://gitlab.com/group/project/-/releases/v1.0.0"},
+		{source: "gitlab://gitlab.com/group/project/-/packages/maven/policies/1.0.0/bundle.jar", err: invalid + "gitlab://gitlab.com/group/project/-/packages/maven/policies/1.0.0/bundle.jar"},
+		{source: "gitlab://gitlab.com/group/project/releases/v1.0.0/bundle.zip", err: invalid + "gitlab://gitlab.com/group/project/releases/v1.0.0/bundle.zip"},
+		{source: "gitlab://gitlab.com/-/releases/v1.0.0/bundle.zip", err: invalid + "gitlab://gitlab.com/-/releases/v1.0.0/bundle.zip"},
+		{source: "gitlab://gitlab.com/group/project/-/releases/v1.0.0/bundle.zip?ref=main", err: "unsupported parameters of gitlab://gitlab.com/group/project/-/releases/v1.0.0/bundle.zip?ref=main"},
+		{source: "https://gitlab.com/group/project", err: "unsupported GitLab source: https://gitlab.com/group/project"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			l, err := parseSource(tt.source)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *l != tt.expected {
+				t.Errorf("unexpected location: got %+v, want %+v", *l, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+module github.com/enterprise-contract/go-gather/gather/gitlab
+
+go 1.22.5
+
+require (
+	github.com/enterprise-contract/go-gather v0.0.3
+	github.com/enterprise-contract/go-gather/metadata v0.0.2
+	github.com/enterprise-contract/go-gather/metadata/gitlab v0.0.1
+)
+
+require gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -0,0 +1,584 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package hdfs provides functionality for gathering files and directories from the Hadoop
+// Distributed File System. It includes an implementation of the Gatherer interface, HDFSGatherer,
+// which downloads a file, or a directory recursively, to a destination path through the WebHDFS
+// REST API of the namenode.
+//
+// Sources are of the form hdfs://[user@]namenode[:port]/path or webhdfs://namenode[:port]/path,
+// which connect to the WebHDFS endpoint of the namenode over HTTP, on port 9870 by default, or
+// swebhdfs://namenode[:port]/path, which connects over HTTPS, on port 9871 by default. The
+// endpoint may also be given as a URL, e.g. hdfs::https://namenode.example.com:50470/path.
+// Requests are authenticated with a delegation token, passed with the delegation parameter of the
+// source, with Kerberos, or else with the user name of the source, as the namenode requires.
+//
+// Example usage:
+//
+//	g := &hdfs.HDFSGatherer{Kerberos: &hdfs.Kerberos{Principal: "etl@EXAMPLE.COM", Keytab: "/etc/security/etl.keytab"}}
+//	m, err := g.Gather(context.Background(), "hdfs://namenode.example.com/data/reference", "/tmp/reference")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package hdfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+	hdfsMetadata "github.com/enterprise-contract/go-gather/metadata/hdfs"
+)
+
+const (
+	// DefaultPort is the port of the WebHDFS endpoint of the namenode when served over HTTP.
+	DefaultPort = "9870"
+	// DefaultSecurePort is the port of the WebHDFS endpoint of the namenode when served over HTTPS.
+	DefaultSecurePort = "9871"
+)
+
+// RemoteError is returned when WebHDFS responds with an unexpected status. Exception and Message
+// hold the Java exception the namenode or datanode reported, if any.
+type RemoteError struct {
+	StatusCode int
+	// Exception is the simple class name of the exception, e.g. "FileNotFoundException".
+	Exception string
+	// Message is the message of the exception.
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	if e.Exception == "" {
+		return fmt.Sprintf("response code error: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("response code error: %d: %s: %s", e.StatusCode, e.Exception, e.Message)
+}
+
+// Retryable reports whether the request may succeed when retried, i.e. whether the status is 408
+// Request Timeout, 429 Too Many Requests or a server error, or the namenode is in standby or asked
+// for the request to be retried.
+func (e *RemoteError) Retryable() bool {
+	switch e.Exception {
+	case "StandbyException", "RetriableException":
+		return true
+	}
+	return e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// HDFSGatherer downloads files and directories from HDFS through WebHDFS.
+type HDFSGatherer struct {
+	// Client is the HTTP client the requests are sent with. It is configured for the gather
+	// options, see gogather.HTTPClient.
+	Client http.Client
+	// User is the user requests are made as when the namenode uses simple authentication and the
+	// source names no user. Defaults to the HADOOP_USER_NAME environment variable.
+	User string
+	// DelegationToken authenticates the requests when the source has no delegation parameter.
+	DelegationToken string
+	// Kerberos, if set, authenticates the requests with SPNEGO when no delegation token is given.
+	Kerberos *Kerberos
+	// Logger receives diagnostic messages. When nil, the logger of the gather options is used.
+	Logger *slog.Logger
+}
+
+// Kerberos configures the Kerberos authentication of WebHDFS requests.
+type Kerberos struct {
+	// Config is the path of the krb5.conf file. Defaults to the KRB5_CONFIG environment variable,
+	// or else /etc/krb5.conf.
+	Config string
+	// Principal is the principal to log in as, e.g. "etl@EXAMPLE.COM". The realm defaults to the
+	// default realm of the configuration. If empty, the tickets of the credential cache are used.
+	Principal string
+	// Keytab is the path of the keytab holding the key of Principal. If empty, Principal logs in
+	// with the password the Auth provider of the gather options has for the namenode.
+	Keytab string
+	// CCache is the path of the credential cache used when Principal is empty. Defaults to the
+	// KRB5CCNAME environment variable, or else /tmp/krb5cc_<uid>.
+	CCache string
+	// ServicePrincipal is the principal of the WebHDFS service. Defaults to HTTP/<namenode host>.
+	ServicePrincipal string
+}
+
+// location is a parsed HDFS source.
+type location struct {
+	// endpoint is the URL of the WebHDFS endpoint of the namenode, without a path.
+	endpoint *url.URL
+	path     string
+	user     string
+	// delegation is the delegation token of the source, if any.
+	delegation string
+}
+
+// auth holds how the WebHDFS requests of a gather are authenticated: with a delegation token,
+// with Kerberos, or else as the user, if any.
+type auth struct {
+	delegation string
+	kerberos   *client.Client
+	spn        string
+	user       string
+}
+
+// fileStatus is the status of a file or directory as reported by WebHDFS.
+type fileStatus struct {
+	PathSuffix       string `json:"pathSuffix"`
+	Type             string `json:"type"`
+	Length           int64  `json:"length"`
+	ModificationTime int64  `json:"modificationTime"`
+}
+
+// Gather downloads the file at source to destination, or, if source is a directory, every file
+// below it into the destination directory that is selected by the Include and Exclude patterns of
+// the gather options. Symbolic links are skipped. A file is saved in the destination under its
+// base name if the destination is an existing directory or ends with a separator. The namenode,
+// and the datanodes reads are redirected to, are checked against the host policy of the gather
+// options.
+func (g *HDFSGatherer) Gather(ctx context.Context, source, destination string) (_ metadata.Metadata, err error) {
+	defer func() {
+		err = gogather.RedactError(err)
+		gogather.FinishProgress(ctx, err)
+	}()
+	startedAt := time.Now()
+
+	loc, err := parseSource(source)
+	if err != nil {
+		return nil, err
+	}
+	a, err := g.authenticate(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	if a.kerberos != nil {
+		defer a.kerberos.Destroy()
+	}
+
+	st, err := g.status(ctx, loc, a, loc.path)
+	if err != nil {
+		return nil, err
+	}
+	m := &hdfsMetadata.HDFSMetadata{Path: loc.path, ModifiedTime: time.UnixMilli(st.ModificationTime).UTC()}
+	switch st.Type {
+	case "DIRECTORY":
+		destination, err = g.gatherDir(ctx, loc, a, destination, m)
+	case "FILE":
+		destination, err = g.gatherFile(ctx, loc, a, st, destination, m)
+	default:
+		err = fmt.Errorf("%s is neither a file nor a directory: %s", loc.path, st.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, _ := m.GetPinnedURL(gogather.RedactURL(source))
+	m.Common = metadata.NewCommon(hdfsMetadata.Type, gogather.RedactURL(source), resolved, destination, startedAt)
+	return m, nil
+}
+
+// gatherFile downloads the file with the status st to destination, returning the path it was
+// saved to.
+func (g *HDFSGatherer) gatherFile(ctx context.Context, loc *location, a *auth, st *fileStatus, destination string, m *hdfsMetadata.HDFSMetadata) (string, error) {
+	if strings.HasSuffix(destination, string(os.PathSeparator)) || strings.HasSuffix(destination, "/") {
+		destination = filepath.Join(destination, path.Base(loc.path))
+	} else if fi, err := os.Stat(destination); err == nil && fi.IsDir() {
+		destination = filepath.Join(destination, path.Base(loc.path))
+	}
+	if err := gogather.CheckWritten(ctx, st.Length); err != nil {
+		return "", err
+	}
+
+	gogather.StartProgress(ctx, st.Length, 1)
+	h := sha256.New()
+	if err := g.download(ctx, loc, a, loc.path, st.Length, destination, h); err != nil {
+		return "", err
+	}
+	if err := gogather.VerifyChecksum(ctx, destination); err != nil {
+		_ = os.Remove(destination)
+		return "", err
+	}
+	m.Files, m.Size, m.SHA256 = 1, st.Length, hex.EncodeToString(h.Sum(nil))
+	return destination, nil
+}
+
+// gatherDir downloads the files below the directory at the path of loc into the destination
+// directory.
+func (g *HDFSGatherer) gatherDir(ctx context.Context, loc *location, a *auth, destination string, m *hdfsMetadata.HDFSMetadata) (string, error) {
+	opts := gogather.OptionsFromContext(ctx)
+	filter, err := gogather.NewPathFilter(opts.Include, opts.Exclude)
+	if err != nil {
+		return "", err
+	}
+
+	files := map[string]int64{}
+	var names []string
+	var total int64
+	var walk func(dir, rel string) error
+	walk = func(dir, rel string) error {
+		statuses, err := g.list(ctx, loc, a, dir)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			name := path.Join(rel, st.PathSuffix)
+			switch st.Type {
+			case "DIRECTORY":
+				if err := walk(path.Join(dir, st.PathSuffix), name); err != nil {
+					return err
+				}
+			case "FILE":
+				if filter.Match(name) {
+					files[name] = st.Length
+					names = append(names, name)
+					total += st.Length
+				}
+			default:
+				gogather.Logger(ctx, g.Logger).Debug("skipping file that is not a regular file", "path", path.Join(dir, st.PathSuffix), "type", st.Type)
+			}
+		}
+		return nil
+	}
+	if err := walk(loc.path, ""); err != nil {
+		return "", err
+	}
+	if err := gogather.CheckWritten(ctx, total); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(destination, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	gogather.StartProgress(ctx, total, len(names))
+	for _, name := range names {
+		local := filepath.FromSlash(name)
+		if !filepath.IsLocal(local) {
+			return "", fmt.Errorf("file %s escapes the destination directory", name)
+		}
+		if err := g.download(ctx, loc, a, path.Join(loc.path, name), files[name], filepath.Join(destination, local), nil); err != nil {
+			return "", err
+		}
+	}
+	m.Files, m.Size = len(names), total
+
+	if m.TreeHash, err = metadata.TreeHash(destination); err != nil {
+		return "", err
+	}
+	if err := gogather.VerifyChecksumDigest(ctx, destination, "sha256", m.TreeHash); err != nil {
+		return "", err
+	}
+	return destination, nil
+}
+
+// download saves the file at the HDFS path p, of the given length, to the local path, also writing
+// its content to h, if set. Reads are redirected by the namenode to a datanode holding the file.
+func (g *HDFSGatherer) download(ctx context.Context, loc *location, a *auth, p string, length int64, local string, h hash.Hash) error {
+	gogather.Logger(ctx, g.Logger).Debug("downloading file", "path", p, "destination", local)
+	resp, err := g.get(ctx, loc, a, "OPEN", p)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", p, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to open %s: %w", p, remoteError(resp))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	dst, err := os.Create(local)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	var w io.Writer = dst
+	if h != nil {
+		w = io.MultiWriter(dst, h)
+	}
+	n, err := gogather.CopySized(w, gogather.WrapReader(ctx, resp.Body), length)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n != length {
+		err = fmt.Errorf("read %d of %d bytes", n, length)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to download %s: %w", p, err)
+	}
+	gogather.CountItems(ctx, 1)
+	return nil
+}
+
+// status returns the status of the file or directory at the HDFS path p.
+func (g *HDFSGatherer) status(ctx context.Context, loc *location, a *auth, p string) (*fileStatus, error) {
+	var body struct {
+		FileStatus fileStatus `json:"FileStatus"`
+	}
+	if err := g.getJSON(ctx, loc, a, "GETFILESTATUS", p, &body); err != nil {
+		return nil, fmt.Errorf("failed to get status of %s: %w", p, err)
+	}
+	return &body.FileStatus, nil
+}
+
+// list returns the statuses of the entries of the directory at the HDFS path p.
+func (g *HDFSGatherer) list(ctx context.Context, loc *location, a *auth, p string) ([]fileStatus, error) {
+	var body struct {
+		FileStatuses struct {
+			FileStatus []fileStatus `json:"FileStatus"`
+		} `json:"FileStatuses"`
+	}
+	if err := g.getJSON(ctx, loc, a, "LISTSTATUS", p, &body); err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", p, err)
+	}
+	for _, st := range body.FileStatuses.FileStatus {
+		if st.PathSuffix == "" || strings.Contains(st.PathSuffix, "/") || st.PathSuffix == "." || st.PathSuffix == ".." {
+			return nil, fmt.Errorf("invalid entry of %s: %q", p, st.PathSuffix)
+		}
+	}
+	return body.FileStatuses.FileStatus, nil
+}
+
+// getJSON sends the WebHDFS operation op for the HDFS path p and decodes the response into v.
+func (g *HDFSGatherer) getJSON(ctx context.Context, loc *location, a *auth, op, p string, v any) error {
+	resp, err := g.get(ctx, loc, a, op, p)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return remoteError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// get sends a GET request for the WebHDFS operation op on the HDFS path p to the namenode, after
+// checking its host against the host policy of the gather options. Redirects, e.g. of reads to
+// datanodes, are followed after checking their hosts as well.
+func (g *HDFSGatherer) get(ctx context.Context, loc *location, a *auth, op, p string) (*http.Response, error) {
+	u := *loc.endpoint
+	u.Path = "/webhdfs/v1" + p
+	q := url.Values{"op": {op}}
+	if a.delegation != "" {
+		q.Set("delegation", a.delegation)
+	} else if a.kerberos == nil && a.user != "" {
+		q.Set("user.name", a.user)
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if err := gogather.CheckHost(ctx, req.URL.Scheme, req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Go-Gather")
+	if a.kerberos != nil {
+		if err := spnego.SetSPNEGOHeader(a.kerberos, req, a.spn); err != nil {
+			return nil, fmt.Errorf("failed to authenticate with Kerberos: %w", err)
+		}
+	}
+
+	client, err := gogather.HTTPClient(ctx, &g.Client)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// remoteError returns the RemoteError of the response, decoding the exception reported in its
+// body, if any.
+func remoteError(resp *http.Response) error {
+	var body struct {
+		RemoteException struct {
+			Exception string `json:"exception"`
+			Message   string `json:"message"`
+		} `json:"RemoteException"`
+	}
+	e := &RemoteError{StatusCode: resp.StatusCode}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body); err == nil {
+		e.Exception, e.Message = body.RemoteException.Exception, body.RemoteException.Message
+	}
+	return e
+}
+
+// authenticate determines how the requests of the gather of loc are authenticated. A delegation
+// token is taken from the source, from DelegationToken, or from credentials of the gather options
+// that have a password but no user name. Without one, Kerberos is used if configured, or else the
+// user name of the source, User, the user name of the credentials or HADOOP_USER_NAME, in turn.
+func (g *HDFSGatherer) authenticate(ctx context.Context, loc *location) (*auth, error) {
+	creds, err := gogather.OptionsFromContext(ctx).Credentials(ctx, loc.endpoint.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if creds == nil {
+		creds = &gogather.Credentials{}
+	}
+
+	a := &auth{delegation: loc.delegation}
+	if a.delegation == "" {
+		a.delegation = g.DelegationToken
+	}
+	if a.delegation == "" && creds.Username == "" {
+		a.delegation = creds.Password
+	}
+	if a.delegation != "" {
+		return a, nil
+	}
+
+	if g.Kerberos != nil {
+		if a.kerberos, err = g.Kerberos.login(creds); err != nil {
+			return nil, err
+		}
+		a.spn = g.Kerberos.ServicePrincipal
+		if a.spn == "" {
+			a.spn = "HTTP/" + loc.endpoint.Hostname()
+		}
+		return a, nil
+	}
+
+	for _, user := range []string{loc.user, g.User, creds.Username, os.Getenv("HADOOP_USER_NAME")} {
+		if user != "" {
+			a.user = user
+			break
+		}
+	}
+	return a, nil
+}
+
+// login returns a Kerberos client logged in as the configured principal, with the password of
+// creds if there is no keytab, or else holding the tickets of the credential cache.
+func (k *Kerberos) login(creds *gogather.Credentials) (*client.Client, error) {
+	confPath := k.Config
+	if confPath == "" {
+		confPath = os.Getenv("KRB5_CONFIG")
+	}
+	if confPath == "" {
+		confPath = "/etc/krb5.conf"
+	}
+	conf, err := config.Load(confPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Kerberos configuration %s: %w", confPath, err)
+	}
+
+	if k.Principal == "" {
+		ccPath := k.CCache
+		if ccPath == "" {
+			ccPath = strings.TrimPrefix(os.Getenv("KRB5CCNAME"), "FILE:")
+		}
+		if ccPath == "" {
+			ccPath = fmt.Sprintf("/tmp/krb5cc_%d", os.Getuid())
+		}
+		cc, err := credentials.LoadCCache(ccPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Kerberos credential cache %s: %w", ccPath, err)
+		}
+		cl, err := client.NewFromCCache(cc, conf, client.DisablePAFXFAST(true))
+		if err != nil {
+			return nil, fmt.Errorf("failed to use Kerberos credential cache %s: %w", ccPath, err)
+		}
+		return cl, nil
+	}
+
+	user, realm, _ := strings.Cut(k.Principal, "@")
+	if realm == "" {
+		realm = conf.LibDefaults.DefaultRealm
+	}
+	var cl *client.Client
+	if k.Keytab != "" {
+		kt, err := keytab.Load(k.Keytab)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load keytab %s: %w", k.Keytab, err)
+		}
+		cl = client.NewWithKeytab(user, realm, kt, conf, client.DisablePAFXFAST(true))
+	} else {
+		if creds.Password == "" {
+			return nil, fmt.Errorf("no keytab or password for Kerberos principal %s", k.Principal)
+		}
+		cl = client.NewWithPassword(user, realm, creds.Password, conf, client.DisablePAFXFAST(true))
+	}
+	if err := cl.Login(); err != nil {
+		return nil, fmt.Errorf("failed to log in as %s: %w", k.Principal, err)
+	}
+	return cl, nil
+}
+
+// parseSource parses an HDFS source into the WebHDFS endpoint of its namenode and the path of the
+// file or directory.
+func parseSource(source string) (*location, error) {
+	s := strings.TrimPrefix(source, "hdfs::")
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source %s: %w", gogather.RedactURL(source), err)
+	}
+
+	scheme, port := "", ""
+	switch u.Scheme {
+	case "hdfs", "webhdfs":
+		scheme, port = "http", DefaultPort
+	case "swebhdfs":
+		scheme, port = "https", DefaultSecurePort
+	case "http", "https":
+		if s != source {
+			scheme = u.Scheme
+		}
+	}
+	if scheme == "" {
+		return nil, fmt.Errorf("unsupported HDFS source: %s", gogather.RedactURL(source))
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("source must be of the form hdfs://namenode/path: %s", gogather.RedactURL(source))
+	}
+	for key := range u.Query() {
+		if key != "delegation" {
+			return nil, fmt.Errorf("unsupported parameters of %s", gogather.RedactURL(source))
+		}
+	}
+
+	host := u.Host
+	if u.Port() == "" && port != "" {
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	loc := &location{
+		endpoint:   &url.URL{Scheme: scheme, Host: host},
+		path:       path.Clean("/" + u.Path),
+		user:       u.User.Username(),
+		delegation: u.Query().Get("delegation"),
+	}
+	return loc, nil
+}
@@ -0,0 +1,303 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package hdfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	hdfsMetadata "github.com/enterprise-contract/go-gather/metadata/hdfs"
+)
+
+// newServer starts a fake WebHDFS endpoint serving the files below, with reads redirected to a
+// fake datanode at /datanode. The link /data/link is reported as a symbolic link. Requests that
+// carry neither the user name "etl" nor the delegation token "token" are rejected. It returns the
+// server and the recorded query of each namenode request.
+func newServer(t *testing.T) (*httptest.Server, *[]string) {
+	files := map[string]string{
+		"/data/a.rego":          "package a\n",
+		"/data/nested/b.rego":   "package b\n",
+		"/data/nested/c.txt":    "notes\n",
+		"/data/other/large.bin": strings.Repeat("x", 100),
+	}
+	var queries []string
+	remote := func(w http.ResponseWriter, status int, exception, message string) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{"RemoteException": map[string]string{"exception": exception, "message": message}})
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/datanode/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(files[strings.TrimPrefix(r.URL.Path, "/datanode")]))
+	})
+	mux.HandleFunc("/webhdfs/v1/", func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		q := r.URL.Query()
+		if q.Get("user.name") != "etl" && q.Get("delegation") != "token" {
+			remote(w, http.StatusForbidden, "SecurityException", "Failed to obtain user group information")
+			return
+		}
+		p := path.Clean(strings.TrimPrefix(r.URL.Path, "/webhdfs/v1"))
+		if p == "/standby" {
+			remote(w, http.StatusForbidden, "StandbyException", "Operation category READ is not supported in state standby")
+			return
+		}
+
+		status := func(name, p string) map[string]any {
+			if content, ok := files[p]; ok {
+				return map[string]any{"pathSuffix": name, "type": "FILE", "length": len(content), "modificationTime": 1704110400000}
+			}
+			return map[string]any{"pathSuffix": name, "type": "DIRECTORY", "length": 0, "modificationTime": 1704110400000}
+		}
+		var children []map[string]any
+		for name := range files {
+			if rel, ok := strings.CutPrefix(name, p+"/"); ok {
+				child, _, _ := strings.Cut(rel, "/")
+				if !containsChild(children, child) {
+					children = append(children, status(child, path.Join(p, child)))
+				}
+			}
+		}
+		if p == "/data" {
+			children = append(children, map[string]any{"pathSuffix": "link", "type": "SYMLINK", "length": 0})
+		}
+		sort.Slice(children, func(i, j int) bool { return children[i]["pathSuffix"].(string) < children[j]["pathSuffix"].(string) })
+		_, isFile := files[p]
+		if !isFile && len(children) == 0 {
+			remote(w, http.StatusNotFound, "FileNotFoundException", "File does not exist: "+p)
+			return
+		}
+
+		switch q.Get("op") {
+		case "GETFILESTATUS":
+			json.NewEncoder(w).Encode(map[string]any{"FileStatus": status("", p)})
+		case "LISTSTATUS":
+			json.NewEncoder(w).Encode(map[string]any{"FileStatuses": map[string]any{"FileStatus": children}})
+		case "OPEN":
+			http.Redirect(w, r, "/datanode"+p, http.StatusTemporaryRedirect)
+		default:
+			remote(w, http.StatusBadRequest, "IllegalArgumentException", "Invalid value for webhdfs parameter \"op\"")
+		}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, &queries
+}
+
+func containsChild(children []map[string]any, name string) bool {
+	for _, c := range children {
+		if c["pathSuffix"] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// TestHDFSGatherer_Gather_File tests downloading a file as the user of the source
+func TestHDFSGatherer_Gather_File(t *testing.T) {
+	srv, queries := newServer(t)
+	dir := t.TempDir()
+
+	source := "hdfs::" + strings.Replace(srv.URL, "http://", "http://etl@", 1) + "/data/nested/b.rego"
+	m, err := (&HDFSGatherer{}).Gather(context.Background(), source, dir+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	destination := filepath.Join(dir, "b.rego")
+	if data, err := os.ReadFile(destination); err != nil || string(data) != "package b\n" {
+		t.Fatalf("unexpected content: %q, %v", data, err)
+	}
+
+	sum := sha256.Sum256([]byte("package b\n"))
+	hm := m.(*hdfsMetadata.HDFSMetadata)
+	if hm.Destination != destination || hm.Path != "/data/nested/b.rego" || hm.Files != 1 || hm.Size != 10 || hm.SHA256 != hex.EncodeToString(sum[:]) || hm.ModifiedTime.Unix() != 1704110400 {
+		t.Errorf("unexpected metadata: %+v", hm)
+	}
+	if hm.ResolvedURI != gogather.RedactURL(source)+"?checksum=sha256:"+hm.SHA256 {
+		t.Errorf("unexpected resolved URI: %s", hm.ResolvedURI)
+	}
+	for _, q := range *queries {
+		if !strings.Contains(q, "user.name=etl") {
+			t.Errorf("expected the requests to be made as etl: %s", q)
+		}
+	}
+}
+
+// TestHDFSGatherer_Gather_Directory tests downloading the files of a directory selected by the
+// filters, authenticated with a delegation token
+func TestHDFSGatherer_Gather_Directory(t *testing.T) {
+	srv, queries := newServer(t)
+	destination := filepath.Join(t.TempDir(), "data")
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Exclude: []string{"other/**"}})
+	m, err := (&HDFSGatherer{User: "etl"}).Gather(ctx, "hdfs::"+srv.URL+"/data?delegation=token", destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, content := range map[string]string{"a.rego": "package a\n", "nested/b.rego": "package b\n", "nested/c.txt": "notes\n"} {
+		if data, err := os.ReadFile(filepath.Join(destination, name)); err != nil || string(data) != content {
+			t.Errorf("unexpected content of %s: %q, %v", name, data, err)
+		}
+	}
+	for _, name := range []string{"other", "link"} {
+		if _, err := os.Stat(filepath.Join(destination, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be skipped: %v", name, err)
+		}
+	}
+
+	hm := m.(*hdfsMetadata.HDFSMetadata)
+	if hm.Destination != destination || hm.Path != "/data" || hm.Files != 3 || hm.Size != 26 || hm.TreeHash == "" || hm.SHA256 != "" {
+		t.Errorf("unexpected metadata: %+v", hm)
+	}
+	for _, q := range *queries {
+		if !strings.Contains(q, "delegation=token") || strings.Contains(q, "user.name") {
+			t.Errorf("expected the requests to carry the delegation token only: %s", q)
+		}
+	}
+}
+
+// TestHDFSGatherer_Gather_Credentials tests authenticating with a delegation token of the Auth
+// provider of the gather options
+func TestHDFSGatherer_Gather_Credentials(t *testing.T) {
+	srv, _ := newServer(t)
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Auth: gogather.HostCredentials{"127.0.0.1": {Password: "token"}}})
+	if _, err := (&HDFSGatherer{}).Gather(ctx, "hdfs::"+srv.URL+"/data/a.rego", filepath.Join(t.TempDir(), "a.rego")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestHDFSGatherer_Gather_Errors tests failing gathers
+func TestHDFSGatherer_Gather_Errors(t *testing.T) {
+	srv, _ := newServer(t)
+	g := &HDFSGatherer{User: "etl"}
+	dir := t.TempDir()
+
+	var remote *RemoteError
+	_, err := g.Gather(context.Background(), "hdfs::"+srv.URL+"/missing", dir)
+	if !errors.As(err, &remote) || remote.StatusCode != http.StatusNotFound || remote.Exception != "FileNotFoundException" || remote.Retryable() {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	_, err = g.Gather(context.Background(), "hdfs::"+srv.URL+"/standby", dir)
+	if !errors.As(err, &remote) || !remote.Retryable() {
+		t.Errorf("expected a retryable error, got %v", err)
+	}
+
+	_, err = (&HDFSGatherer{}).Gather(context.Background(), "hdfs::"+srv.URL+"/data", dir)
+	if !errors.As(err, &remote) || remote.StatusCode != http.StatusForbidden || remote.Exception != "SecurityException" {
+		t.Errorf("expected a security error, got %v", err)
+	}
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{MaxSize: 50})
+	if _, err := g.Gather(ctx, "hdfs::"+srv.URL+"/data", filepath.Join(dir, "data")); !errors.Is(err, gogather.ErrTooLarge) {
+		t.Errorf("expected the size limit to be exceeded, got %v", err)
+	}
+
+	var mismatch *gogather.ChecksumMismatchError
+	ctx = gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Checksum: "sha256:" + strings.Repeat("0", 64)})
+	if _, err := g.Gather(ctx, "hdfs::"+srv.URL+"/data/a.rego", filepath.Join(dir, "a.rego")); !errors.As(err, &mismatch) {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.rego")); !os.IsNotExist(err) {
+		t.Errorf("expected the file to be removed: %v", err)
+	}
+
+	var denied *gogather.HostDeniedError
+	ctx = gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{HostPolicy: &gogather.HostPolicy{DeniedHosts: []string{"127.0.0.1"}}})
+	if _, err := g.Gather(ctx, "hdfs::"+srv.URL+"/data/a.rego", dir); !errors.As(err, &denied) {
+		t.Errorf("expected the host policy to deny the namenode, got %v", err)
+	}
+}
+
+// TestHDFSGatherer_Gather_Kerberos tests failing to set up Kerberos authentication
+func TestHDFSGatherer_Gather_Kerberos(t *testing.T) {
+	srv, _ := newServer(t)
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "krb5.conf")
+	if err := os.WriteFile(conf, []byte("[libdefaults]\n  default_realm = EXAMPLE.COM\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		kerberos *Kerberos
+		err      string
+	}{
+		{name: "config", kerberos: &Kerberos{Config: filepath.Join(dir, "missing.conf")}, err: "failed to load Kerberos configuration " + filepath.Join(dir, "missing.conf")},
+		{name: "ccache", kerberos: &Kerberos{Config: conf, CCache: filepath.Join(dir, "krb5cc")}, err: "failed to load Kerberos credential cache " + filepath.Join(dir, "krb5cc")},
+		{name: "keytab", kerberos: &Kerberos{Config: conf, Principal: "etl", Keytab: filepath.Join(dir, "etl.keytab")}, err: "failed to load keytab " + filepath.Join(dir, "etl.keytab")},
+		{name: "password", kerberos: &Kerberos{Config: conf, Principal: "etl@EXAMPLE.COM"}, err: "no keytab or password for Kerberos principal etl@EXAMPLE.COM"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&HDFSGatherer{Kerberos: tt.kerberos}).Gather(context.Background(), "hdfs::"+srv.URL+"/data", dir)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("unexpected error: got %v, want %s", err, tt.err)
+			}
+		})
+	}
+}
+
+// TestParseSource tests parsing HDFS sources
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		source     string
+		endpoint   string
+		path       string
+		user       string
+		delegation string
+		err        string
+	}{
+		{source: "hdfs://namenode.example.com/data/reference", endpoint: "http://namenode.example.com:9870", path: "/data/reference"},
+		{source: "hdfs://etl@namenode.example.com:50070/data/", endpoint: "http://namenode.example.com:50070", path: "/data", user: "etl"},
+		{source: "webhdfs://namenode.example.com/data?delegation=abc", endpoint: "http://namenode.example.com:9870", path: "/data", delegation: "abc"},
+		{source: "swebhdfs://namenode.example.com/data", endpoint: "https://namenode.example.com:9871", path: "/data"},
+		{source: "hdfs::https://namenode.example.com/data", endpoint: "https://namenode.example.com", path: "/data"},
+		{source: "hdfs://namenode.example.com", endpoint: "http://namenode.example.com:9870", path: "/"},
+		{source: "https://namenode.example.com/data", err: "unsupported HDFS source: https://namenode.example.com/data"},
+		{source: "hdfs:///data", err: "source must be of the form hdfs://namenode/path: hdfs:///data"},
+		{source: "hdfs://namenode.example.com/data?ref=main", err: "unsupported parameters of hdfs://namenode.example.com/data?ref=main"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			l, err := parseSource(tt.source)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if l.endpoint.String() != tt.endpoint || l.path != tt.path || l.user != tt.user || l.delegation != tt.delegation {
+				t.Errorf("unexpected location: got %s %s %s %s", l.endpoint, l.path, l.user, l.delegation)
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+module github.com/enterprise-contract/go-gather/gather/helm
+
+go 1.22.5
+
+require (
+	github.com/enterprise-contract/go-gather v0.0.3
+	github.com/enterprise-contract/go-gather/expander v0.0.1
+	github.com/enterprise-contract/go-gather/metadata v0.0.2
+	github.com/enterprise-contract/go-gather/metadata/helm v0.0.1
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,432 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package helm provides functionality for gathering charts from Helm chart repositories. It
+// includes an implementation of the Gatherer interface, HelmGatherer, which looks a chart up in the
+// index of the repository, downloads the chart archive, verifies its digest and expands it into
+// the destination directory.
+//
+// Sources are of the form helm::https://charts.example.com/path/chart?version=1.2.3, where
+// https://charts.example.com/path is the URL of the repository, serving its index.yaml, and chart
+// is the name of the chart. Without the version parameter, the latest version that is not a
+// prerelease is gathered.
+//
+// Example usage:
+//
+//	g := &helm.HelmGatherer{}
+//	m, err := g.Gather(context.Background(), "helm::https://charts.example.com/policies?version=1.2.3", "/tmp/policies")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package helm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/expander"
+	"github.com/enterprise-contract/go-gather/metadata"
+	helmMetadata "github.com/enterprise-contract/go-gather/metadata/helm"
+)
+
+// HelmGatherer downloads charts from Helm chart repositories.
+type HelmGatherer struct {
+	// Client is the HTTP client the index and the charts are downloaded with. It is configured
+	// for the gather options, see gogather.HTTPClient.
+	Client http.Client
+	// ExpanderOptions configures the expander of the chart archives, e.g. to limit the number of
+	// files they may contain.
+	ExpanderOptions []expander.Option
+	// Logger receives diagnostic messages. When nil, the logger of the gather options is used.
+	Logger *slog.Logger
+}
+
+// index is the subset of the index.yaml of a chart repository that is used.
+type index struct {
+	Entries map[string][]*chartVersion `yaml:"entries"`
+}
+
+// chartVersion is a version of a chart listed in the index of a chart repository.
+type chartVersion struct {
+	Name       string   `yaml:"name"`
+	Version    string   `yaml:"version"`
+	AppVersion string   `yaml:"appVersion"`
+	Digest     string   `yaml:"digest"`
+	URLs       []string `yaml:"urls"`
+}
+
+// Gather downloads the chart of source and expands it into the destination directory, stripping the
+// directory named after the chart that chart archives hold. The Include and Exclude patterns of the
+// gather options select the files of the chart to expand. When the Archive gather option is "false",
+// the chart archive is saved as is instead, under its own name if the destination is an existing
+// directory or ends with a separator. Requests to the repository are authenticated with the
+// credentials of the source, or else those the Auth provider of the gather options has for its host.
+func (g *HelmGatherer) Gather(ctx context.Context, source, destination string) (_ metadata.Metadata, err error) {
+	defer func() {
+		err = gogather.RedactError(err)
+		gogather.FinishProgress(ctx, err)
+	}()
+	startedAt := time.Now()
+
+	loc, err := parseSource(source)
+	if err != nil {
+		return nil, err
+	}
+	cv, chartURL, err := g.lookup(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	m := &helmMetadata.HelmMetadata{
+		Repository: gogather.RedactURL(loc.repository.String()),
+		Chart:      loc.chart,
+		Version:    cv.Version,
+		AppVersion: cv.AppVersion,
+		URL:        gogather.RedactURL(chartURL.String()),
+	}
+
+	opts := gogather.OptionsFromContext(ctx)
+	if opts.Archive == "false" {
+		if strings.HasSuffix(destination, string(os.PathSeparator)) || strings.HasSuffix(destination, "/") {
+			destination = filepath.Join(destination, path.Base(chartURL.Path))
+		} else if fi, err := os.Stat(destination); err == nil && fi.IsDir() {
+			destination = filepath.Join(destination, path.Base(chartURL.Path))
+		}
+		if m.Size, m.SHA256, err = g.download(ctx, loc, cv, chartURL, destination, true); err != nil {
+			return nil, err
+		}
+	} else {
+		if destination, err = g.expand(ctx, loc, cv, chartURL, destination, m); err != nil {
+			return nil, err
+		}
+	}
+
+	resolved, _ := m.GetPinnedURL(gogather.RedactURL(source))
+	m.Common = metadata.NewCommon(helmMetadata.Type, gogather.RedactURL(source), resolved, destination, startedAt)
+	return m, nil
+}
+
+// expand downloads the chart archive to a scratch directory and expands it into the destination
+// directory, in the format named by the Archive gather option, if set, or else as a gzip
+// compressed tar archive.
+func (g *HelmGatherer) expand(ctx context.Context, loc *location, cv *chartVersion, chartURL *url.URL, destination string, m *helmMetadata.HelmMetadata) (string, error) {
+	format := gogather.OptionsFromContext(ctx).Archive
+	if format == "" {
+		format = "tar.gz"
+	}
+	digests := gogather.NewDigests()
+	e, err := expander.NewExpander(format, g.expanderOptions(ctx, digests)...)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := gogather.MkdirTemp(ctx, "helm-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	archive := filepath.Join(tmp, path.Base(chartURL.Path))
+	if m.Size, m.SHA256, err = g.download(ctx, loc, cv, chartURL, archive, false); err != nil {
+		return "", err
+	}
+
+	if err := e.Expand(destination, archive, true, 0755); err != nil {
+		return "", fmt.Errorf("failed to expand chart: %w", err)
+	}
+	if err := gogather.CountWrittenDir(ctx, destination); err != nil {
+		return "", err
+	}
+	if m.TreeHash, err = metadata.TreeHashWithDigests(destination, digests.SHA256); err != nil {
+		return "", err
+	}
+	return destination, nil
+}
+
+// expanderOptions returns the ExpanderOptions extended with the filters and the size limit of the
+// gather options carried by ctx, recording the digests of the expanded files in digests. The
+// directory chart archives hold is stripped.
+func (g *HelmGatherer) expanderOptions(ctx context.Context, digests *gogather.Digests) []expander.Option {
+	o := gogather.OptionsFromContext(ctx)
+	return append(slices.Clone(g.ExpanderOptions), func(c *expander.Config) {
+		c.Options.Digests = digests
+		c.Options.Include = append(c.Options.Include, o.Include...)
+		c.Options.Exclude = append(c.Options.Exclude, o.Exclude...)
+		c.Options.FlattenSingleRoot = true
+		if o.MaxSize > 0 && (c.FileSizeLimit <= 0 || c.FileSizeLimit > o.MaxSize) {
+			c.FileSizeLimit = o.MaxSize
+		}
+	})
+}
+
+// lookup returns the version of the chart of loc listed in the index of its repository, and the
+// URL of its archive.
+func (g *HelmGatherer) lookup(ctx context.Context, loc *location) (*chartVersion, *url.URL, error) {
+	indexURL := loc.repository.JoinPath("index.yaml")
+	resp, err := g.get(ctx, loc, indexURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get index of %s: %w", gogather.RedactURL(loc.repository.String()), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("failed to get index of %s: %w", gogather.RedactURL(loc.repository.String()), &gogather.StatusError{StatusCode: resp.StatusCode})
+	}
+	var idx index
+	if err := yaml.NewDecoder(resp.Body).Decode(&idx); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode index of %s: %w", gogather.RedactURL(loc.repository.String()), err)
+	}
+
+	versions := idx.Entries[loc.chart]
+	if len(versions) == 0 {
+		return nil, nil, fmt.Errorf("chart %s not found in the index of %s", loc.chart, gogather.RedactURL(loc.repository.String()))
+	}
+	var cv *chartVersion
+	for _, v := range versions {
+		if loc.version != "" {
+			if strings.TrimPrefix(v.Version, "v") == strings.TrimPrefix(loc.version, "v") {
+				cv = v
+				break
+			}
+			continue
+		}
+		if _, pre, ok := parseVersion(v.Version); ok && pre == "" && (cv == nil || compareVersions(v.Version, cv.Version) > 0) {
+			cv = v
+		}
+	}
+	if cv == nil && loc.version != "" {
+		return nil, nil, fmt.Errorf("version %s of chart %s not found", loc.version, loc.chart)
+	}
+	if cv == nil {
+		return nil, nil, fmt.Errorf("chart %s has no released version", loc.chart)
+	}
+	if len(cv.URLs) == 0 {
+		return nil, nil, fmt.Errorf("version %s of chart %s has no URL", cv.Version, loc.chart)
+	}
+
+	// Relative URLs are relative to the repository, even if its URL does not end with a slash.
+	base := *loc.repository
+	base.Path = strings.TrimSuffix(base.Path, "/") + "/"
+	chartURL, err := base.Parse(cv.URLs[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse URL of chart %s: %w", loc.chart, err)
+	}
+	return cv, chartURL, nil
+}
+
+// download saves the archive of the chart version at chartURL to path, verifying it against the
+// digest recorded in the index, if any, and against the checksum of the gather options. Bytes are
+// counted as written to the destination if count is set. It returns the size of the archive and
+// its hex encoded SHA256 digest.
+func (g *HelmGatherer) download(ctx context.Context, loc *location, cv *chartVersion, chartURL *url.URL, path string, count bool) (int64, string, error) {
+	gogather.Logger(ctx, g.Logger).Debug("downloading chart", "chart", loc.chart, "version", cv.Version, "url", gogather.RedactURL(chartURL.String()), "destination", path)
+	resp, err := g.get(ctx, loc, chartURL)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to download chart %s: %w", loc.chart, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", fmt.Errorf("failed to download chart %s: %w", loc.chart, &gogather.StatusError{StatusCode: resp.StatusCode})
+	}
+	if err := gogather.CheckWritten(ctx, resp.ContentLength); err != nil {
+		return 0, "", err
+	}
+
+	var body io.Reader = resp.Body
+	if count {
+		gogather.StartProgress(ctx, resp.ContentLength, 1)
+		body = gogather.WrapReader(ctx, resp.Body)
+	} else {
+		gogather.StartProgress(ctx, -1, -1)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create file: %w", err)
+	}
+	h := sha256.New()
+	n, err := gogather.CopySized(io.MultiWriter(out, h), body, resp.ContentLength)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to download chart %s: %w", loc.chart, err)
+	}
+
+	digest := hex.EncodeToString(h.Sum(nil))
+	if cv.Digest != "" && !strings.EqualFold(strings.TrimPrefix(cv.Digest, "sha256:"), digest) {
+		_ = os.Remove(path)
+		return 0, "", fmt.Errorf("digest of chart %s does not match the digest recorded in the index: %s != %s", loc.chart, digest, cv.Digest)
+	}
+	if err := gogather.VerifyChecksum(ctx, path); err != nil {
+		_ = os.Remove(path)
+		return 0, "", err
+	}
+	if count {
+		gogather.CountItems(ctx, 1)
+	}
+	return n, digest, nil
+}
+
+// get sends a GET request for u after checking its host against the host policy of the gather
+// options. Requests to the host of the repository are authenticated with the credentials of the
+// source or of the gather options; the client drops them on redirects to other hosts.
+func (g *HelmGatherer) get(ctx context.Context, loc *location, u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if err := gogather.CheckHost(ctx, req.URL.Scheme, req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Go-Gather")
+	if req.URL.Host == loc.repository.Host && req.URL.User == nil {
+		creds, err := gogather.OptionsFromContext(ctx).Credentials(ctx, loc.repository.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		if creds != nil {
+			req.SetBasicAuth(creds.Username, creds.Password)
+		}
+	}
+
+	client, err := gogather.HTTPClient(ctx, &g.Client)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// parseVersion parses a semantic version, with an optional "v" prefix, returning its major, minor
+// and patch numbers, its prerelease and whether it is valid. Build metadata is ignored.
+func parseVersion(v string) ([]int, string, bool) {
+	v, _, _ = strings.Cut(strings.TrimPrefix(v, "v"), "+")
+	core, pre, _ := strings.Cut(v, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return nil, "", false
+	}
+	nums := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, "", false
+		}
+		nums[i] = n
+	}
+	return nums, pre, true
+}
+
+// compareVersions compares the semantic versions a and b, returning -1, 0 or 1. Invalid versions
+// are lower than valid ones.
+func compareVersions(a, b string) int {
+	na, pa, oka := parseVersion(a)
+	nb, pb, okb := parseVersion(b)
+	switch {
+	case !oka || !okb:
+		return boolCompare(oka, okb)
+	case slices.Compare(na, nb) != 0:
+		return slices.Compare(na, nb)
+	case pa == pb:
+		return 0
+	case pa == "":
+		return 1
+	case pb == "":
+		return -1
+	}
+	// Prerelease identifiers are compared in turn: numerically if both are numbers, with numbers
+	// lower than other identifiers, and lexically otherwise.
+	ia, ib := strings.Split(pa, "."), strings.Split(pb, ".")
+	for i := 0; i < len(ia) && i < len(ib); i++ {
+		x, errx := strconv.Atoi(ia[i])
+		y, erry := strconv.Atoi(ib[i])
+		switch {
+		case errx == nil && erry == nil:
+			if c := x - y; c != 0 {
+				return max(-1, min(1, c))
+			}
+		case errx == nil:
+			return -1
+		case erry == nil:
+			return 1
+		default:
+			if c := strings.Compare(ia[i], ib[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return max(-1, min(1, len(ia)-len(ib)))
+}
+
+// boolCompare orders false before true.
+func boolCompare(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
+// location identifies a chart: the URL of its repository, its name and the requested version.
+type location struct {
+	repository     *url.URL
+	chart, version string
+}
+
+// parseSource parses a source of the form helm::https://charts.example.com/path/chart?version=1.2.3.
+func parseSource(source string) (*location, error) {
+	u, err := url.Parse(strings.TrimPrefix(source, "helm::"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source %s: %w", gogather.RedactURL(source), err)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("unsupported Helm source: %s", gogather.RedactURL(source))
+	}
+	for key := range u.Query() {
+		if key != "version" {
+			return nil, fmt.Errorf("unsupported parameters of %s", gogather.RedactURL(source))
+		}
+	}
+
+	loc := &location{version: u.Query().Get("version")}
+	p := strings.TrimSuffix(u.Path, "/")
+	loc.chart = path.Base(p)
+	if u.Host == "" || p == "" || loc.chart == "." || loc.chart == ".." {
+		return nil, fmt.Errorf("source must be of the form helm::https://host/path/chart: %s", gogather.RedactURL(source))
+	}
+	loc.repository = &url.URL{Scheme: u.Scheme, User: u.User, Host: u.Host, Path: path.Dir(p)}
+	if loc.repository.Path == "/" {
+		loc.repository.Path = ""
+	}
+	return loc, nil
+}
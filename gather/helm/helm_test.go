@@ -0,0 +1,271 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	helmMetadata "github.com/enterprise-contract/go-gather/metadata/helm"
+)
+
+// newChart returns a gzip compressed tar archive of the chart policies with the given version.
+func newChart(t *testing.T, version string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	// The archive must be the same for every call, so the files are written in a fixed order.
+	files := [][2]string{
+		{"policies/Chart.yaml", "name: policies\nversion: " + version + "\n"},
+		{"policies/templates/config.yaml", "kind: ConfigMap\n"},
+	}
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f[0], Mode: 0644, Size: int64(len(f[1])), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newServer starts a fake chart repository at /charts, listing the versions 1.0.0, the recorded
+// digest of which does not match its archive, 1.2.3 and the prerelease 2.0.0-rc.1 of the chart
+// policies. The archive of 1.2.3 is served from a separate storage path. Requests to the repository
+// must be authenticated as reader with the password secret. It returns the server and the digest
+// of the archive of 1.2.3.
+func newServer(t *testing.T) (*httptest.Server, string) {
+	charts := map[string][]byte{}
+	digests := map[string]string{}
+	for _, v := range []string{"1.0.0", "1.2.3", "2.0.0-rc.1"} {
+		charts[v] = newChart(t, v)
+		sum := sha256.Sum256(charts[v])
+		digests[v] = hex.EncodeToString(sum[:])
+	}
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/storage/policies-1.2.3.tgz" {
+			w.Write(charts["1.2.3"])
+			return
+		}
+		if user, password, ok := r.BasicAuth(); !ok || user != "reader" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/charts/index.yaml":
+			fmt.Fprintf(w, `apiVersion: v1
+entries:
+  policies:
+  - name: policies
+    version: 2.0.0-rc.1
+    digest: %[2]s
+    urls: [policies-2.0.0-rc.1.tgz]
+  - name: policies
+    version: 1.0.0
+    digest: abc
+    urls: [policies-1.0.0.tgz]
+  - name: policies
+    version: 1.2.3
+    appVersion: "2.0"
+    digest: %[3]s
+    urls: [%[1]s/storage/policies-1.2.3.tgz]
+`, srv.URL, digests["2.0.0-rc.1"], digests["1.2.3"])
+		case "/charts/policies-1.0.0.tgz":
+			w.Write(charts["1.0.0"])
+		case "/charts/policies-2.0.0-rc.1.tgz":
+			w.Write(charts["2.0.0-rc.1"])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, digests["1.2.3"]
+}
+
+// withAuth returns a context carrying gather options with the credentials of the fake repository.
+func withAuth(o gogather.GatherOptions) context.Context {
+	o.Auth = gogather.HostCredentials{"127.0.0.1": {Username: "reader", Password: "secret"}}
+	return gogather.ContextWithOptions(context.Background(), o)
+}
+
+// TestHelmGatherer_Gather tests expanding the latest version of a chart
+func TestHelmGatherer_Gather(t *testing.T) {
+	srv, digest := newServer(t)
+	destination := filepath.Join(t.TempDir(), "policies")
+
+	source := "helm::" + srv.URL + "/charts/policies"
+	m, err := (&HelmGatherer{}).Gather(withAuth(gogather.GatherOptions{}), source, destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(destination, "Chart.yaml")); err != nil || string(data) != "name: policies\nversion: 1.2.3\n" {
+		t.Fatalf("unexpected content: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(destination, "templates", "config.yaml")); err != nil {
+		t.Errorf("expected the templates to be expanded: %v", err)
+	}
+
+	hm := m.(*helmMetadata.HelmMetadata)
+	if hm.Destination != destination || hm.Repository != srv.URL+"/charts" || hm.Chart != "policies" || hm.Version != "1.2.3" || hm.AppVersion != "2.0" || hm.URL != srv.URL+"/storage/policies-1.2.3.tgz" || hm.SHA256 != digest || hm.TreeHash == "" {
+		t.Errorf("unexpected metadata: %+v", hm)
+	}
+	if hm.ResolvedURI != source+"?version=1.2.3&checksum=sha256:"+digest {
+		t.Errorf("unexpected resolved URI: %s", hm.ResolvedURI)
+	}
+}
+
+// TestHelmGatherer_Gather_Archive tests saving the archive of a chart version, authenticated with
+// the credentials of the source
+func TestHelmGatherer_Gather_Archive(t *testing.T) {
+	srv, _ := newServer(t)
+	dir := t.TempDir()
+
+	u, _ := url.Parse(srv.URL)
+	source := fmt.Sprintf("helm::http://reader:secret@%s/charts/policies?version=2.0.0-rc.1", u.Host)
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Archive: "false"})
+	m, err := (&HelmGatherer{}).Gather(ctx, source, dir+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	destination := filepath.Join(dir, "policies-2.0.0-rc.1.tgz")
+	if data, err := os.ReadFile(destination); err != nil || !bytes.Equal(data, newChart(t, "2.0.0-rc.1")) {
+		t.Fatalf("unexpected content: %v", err)
+	}
+	hm := m.(*helmMetadata.HelmMetadata)
+	if hm.Destination != destination || hm.Version != "2.0.0-rc.1" || hm.TreeHash != "" || strings.Contains(hm.SourceURI, "secret") || strings.Contains(hm.Repository, "secret") {
+		t.Errorf("unexpected metadata: %+v", hm)
+	}
+}
+
+// TestHelmGatherer_Gather_Errors tests failing downloads
+func TestHelmGatherer_Gather_Errors(t *testing.T) {
+	srv, _ := newServer(t)
+	g := &HelmGatherer{}
+	dir := t.TempDir()
+	ctx := withAuth(gogather.GatherOptions{})
+
+	if _, err := g.Gather(ctx, "helm::"+srv.URL+"/charts/missing", dir); err == nil || err.Error() != "chart missing not found in the index of "+srv.URL+"/charts" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := g.Gather(ctx, "helm::"+srv.URL+"/charts/policies?version=3.0.0", dir); err == nil || err.Error() != "version 3.0.0 of chart policies not found" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err := g.Gather(ctx, "helm::"+srv.URL+"/charts/policies?version=1.0.0", dir)
+	if err == nil || !strings.HasPrefix(err.Error(), "digest of chart policies does not match the digest recorded in the index") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected nothing to be expanded: %v", entries)
+	}
+
+	var status *gogather.StatusError
+	_, err = g.Gather(context.Background(), "helm::"+srv.URL+"/charts/policies", dir)
+	if !errors.As(err, &status) || status.StatusCode != http.StatusUnauthorized || status.Retryable() {
+		t.Errorf("expected a status error, got %v", err)
+	}
+
+	var mismatch *gogather.ChecksumMismatchError
+	if _, err := g.Gather(withAuth(gogather.GatherOptions{Checksum: "sha256:" + strings.Repeat("0", 64)}), "helm::"+srv.URL+"/charts/policies", dir); !errors.As(err, &mismatch) {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+
+	var denied *gogather.HostDeniedError
+	if _, err := g.Gather(withAuth(gogather.GatherOptions{HostPolicy: &gogather.HostPolicy{DeniedHosts: []string{"127.0.0.1"}}}), "helm::"+srv.URL+"/charts/policies", dir); !errors.As(err, &denied) {
+		t.Errorf("expected the host policy to deny the repository, got %v", err)
+	}
+}
+
+// TestCompareVersions tests ordering semantic versions
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "1.2.3", b: "1.2.3", expected: 0},
+		{a: "v1.2.3", b: "1.2.3+build.1", expected: 0},
+		{a: "1.10.0", b: "1.9.0", expected: 1},
+		{a: "1.0.0-rc.1", b: "1.0.0", expected: -1},
+		{a: "1.0.0-rc.2", b: "1.0.0-rc.10", expected: -1},
+		{a: "1.0.0-alpha", b: "1.0.0-1", expected: 1},
+		{a: "1.0.0-rc.1.1", b: "1.0.0-rc.1", expected: 1},
+		{a: "latest", b: "0.0.1", expected: -1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.expected {
+			t.Errorf("compareVersions(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+// TestParseSource tests parsing Helm sources
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		source     string
+		repository string
+		chart      string
+		version    string
+		err        string
+	}{
+		{source: "helm::https://charts.example.com/policies?version=1.2.3", repository: "https://charts.example.com", chart: "policies", version: "1.2.3"},
+		{source: "helm::https://example.com/helm/stable/policies/", repository: "https://example.com/helm/stable", chart: "policies"},
+		{source: "helm::https://charts.example.com", err: "source must be of the form helm::https://host/path/chart: helm::https://charts.example.com"},
+		{source: "helm::https://charts.example.com/policies?ref=main", err: "unsupported parameters of helm::https://charts.example.com/policies?ref=main"},
+		{source: "helm::oci://registry.example.com/charts/policies", err: "unsupported Helm source: helm::oci://registry.example.com/charts/policies"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			l, err := parseSource(tt.source)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if l.repository.String() != tt.repository || l.chart != tt.chart || l.version != tt.version {
+				t.Errorf("unexpected location: got %s %s %s", l.repository, l.chart, l.version)
+			}
+		})
+	}
+}
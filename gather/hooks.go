@@ -0,0 +1,105 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// hookEvent returns the event of stage for the gather of source to destination. The path of the
+// content is taken from m, if any.
+func hookEvent(stage gogather.HookStage, source, destination string, m metadata.Metadata) gogather.HookEvent {
+	e := gogather.HookEvent{Stage: stage, Source: gogather.RedactURL(source), Destination: destination}
+	if m != nil {
+		e.Metadata = m
+		e.Path, _ = m.Get()["destination"].(string)
+		if e.Path == "" {
+			e.Path = destination
+		}
+	}
+	return e
+}
+
+// stagingGatherer gathers the source with the wrapped Gatherer to a scratch directory, calls the
+// BeforeSave hooks with the staged content and only then copies it to the destination.
+type stagingGatherer struct {
+	Gatherer Gatherer
+	// Destination is the destination of the gather reported to the hooks, which differs from the
+	// one the content is copied to when the gather is staged by a RollbackGatherer.
+	Destination string
+}
+
+func (s *stagingGatherer) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
+	tmp, err := gogather.MkdirTemp(ctx, "go-gather-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	local := filepath.Join(tmp, "content")
+	m, err := s.Gatherer.Gather(ctx, source, local)
+	if err != nil {
+		return nil, err
+	}
+
+	content, _ := m.Get()["destination"].(string)
+	if content == "" {
+		content = local
+	}
+	rel, err := filepath.Rel(local, content)
+	if err != nil || !filepath.IsLocal(rel) && rel != "." {
+		return nil, fmt.Errorf("gathered content %s is outside the destination %s", content, local)
+	}
+	e := hookEvent(gogather.BeforeSave, source, s.Destination, m)
+	e.Path = content
+	if err := gogather.RunHooks(ctx, e); err != nil {
+		return nil, err
+	}
+
+	target := filepath.Join(destination, rel)
+	if err := copyContent(ctx, content, target); err != nil {
+		return nil, fmt.Errorf("failed to save gathered content to %s: %w", target, err)
+	}
+	return setDestination(m, target), nil
+}
+
+// completionGatherer calls the AfterComplete hooks once the wrapped Gatherer finishes.
+type completionGatherer struct {
+	Gatherer Gatherer
+}
+
+func (c *completionGatherer) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
+	m, err := c.Gatherer.Gather(ctx, source, destination)
+	if err != nil {
+		e := hookEvent(gogather.AfterComplete, source, destination, nil)
+		e.Err = err
+		if herr := gogather.RunHooks(ctx, e); herr != nil {
+			gogather.OptionsFromContext(ctx).Log().Warn("hook of failed gather failed", "source", e.Source, "error", herr)
+		}
+		return m, err
+	}
+	if err := gogather.RunHooks(ctx, hookEvent(gogather.AfterComplete, source, destination, m)); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,95 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// TestGather_Hooks tests calling the hooks at each stage of a gather
+func TestGather_Hooks(t *testing.T) {
+	src := writeSourceDir(t)
+	destination := filepath.Join(t.TempDir(), "out")
+
+	var events []gogather.HookEvent
+	record := func(_ context.Context, e gogather.HookEvent) error {
+		events = append(events, e)
+		return nil
+	}
+	opts := []Option{
+		WithHook(gogather.BeforeResolve, record),
+		WithHook(gogather.AfterDownload, record),
+		WithHook(gogather.BeforeSave, func(ctx context.Context, e gogather.HookEvent) error {
+			// The content is staged, not saved yet.
+			if _, err := os.Stat(filepath.Join(e.Path, "main.rego")); err != nil {
+				return err
+			}
+			if _, err := os.Stat(destination); !os.IsNotExist(err) {
+				return errors.New("destination written before the before-save hooks")
+			}
+			return record(ctx, e)
+		}),
+		WithHook(gogather.AfterComplete, record),
+	}
+	if _, err := Gather(context.Background(), src, destination, opts...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var stages []string
+	for _, e := range events {
+		stages = append(stages, e.Stage.String())
+		if e.Source != src || e.Destination != destination {
+			t.Errorf("unexpected %s event: %+v", e.Stage, e)
+		}
+	}
+	if got := strings.Join(stages, ","); got != "before-resolve,after-download,before-save,after-complete" {
+		t.Fatalf("unexpected stages: %s", got)
+	}
+	if last := events[3]; last.Path != destination || last.Metadata == nil || last.Err != nil {
+		t.Errorf("unexpected completion event: %+v", last)
+	}
+}
+
+// TestGather_HookRejects tests that failing hooks fail the gather before it writes the destination
+func TestGather_HookRejects(t *testing.T) {
+	errRejected := errors.New("rejected")
+	var completed error
+	destination := filepath.Join(t.TempDir(), "out")
+	_, err := Gather(context.Background(), writeSourceDir(t), destination,
+		WithHook(gogather.BeforeSave, func(context.Context, gogather.HookEvent) error { return errRejected }),
+		WithHook(gogather.AfterComplete, func(_ context.Context, e gogather.HookEvent) error {
+			completed = e.Err
+			return nil
+		}),
+	)
+	if !errors.Is(err, errRejected) {
+		t.Fatalf("expected the hook error, got %v", err)
+	}
+	if !errors.Is(completed, errRejected) {
+		t.Errorf("expected the after-complete hooks to receive the error, got %v", completed)
+	}
+	if _, err := os.Stat(destination); !os.IsNotExist(err) {
+		t.Errorf("expected no destination: %v", err)
+	}
+}
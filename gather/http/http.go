@@ -37,9 +37,15 @@ package http
 
 import (
 	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -54,6 +60,8 @@ var Transport http.RoundTripper = http.DefaultTransport
 
 type HTTPGatherer struct {
 	Client http.Client
+	// Logger receives diagnostic messages. When nil, the logger of the gather options is used.
+	Logger *slog.Logger
 }
 
 func NewHTTPGatherer() *HTTPGatherer {
@@ -64,7 +72,12 @@ func NewHTTPGatherer() *HTTPGatherer {
 	}
 }
 
-func (h *HTTPGatherer) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
+func (h *HTTPGatherer) Gather(ctx context.Context, source, destination string) (_ metadata.Metadata, err error) {
+	defer func() {
+		err = gogather.RedactError(err)
+		gogather.FinishProgress(ctx, err)
+	}()
+	startedAt := time.Now()
 
 	// Parse source
 	src, err := url.Parse(source)
@@ -77,6 +90,10 @@ func (h *HTTPGatherer) Gather(ctx context.Context, source, destination string) (
 		return nil, fmt.Errorf("no source scheme provided")
 	}
 
+	if err := gogather.CheckHost(ctx, src.Scheme, src.Hostname()); err != nil {
+		return nil, err
+	}
+
 	// Get the source filename
 	sourceFileName := filepath.Base(src.Path)
 
@@ -103,17 +120,15 @@ func (h *HTTPGatherer) Gather(ctx context.Context, source, destination string) (
 	}
 
 	// Create a new HTTP request
-	req, err := http.NewRequestWithContext(ctx, "GET", source, nil)
+	req, err := newRequest(ctx, "GET", source)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+		return nil, err
 	}
 
-	req.Header.Set("User-Agent", "Go-Gather")
-
-	h.Client.Transport = Transport
-
 	// Send the HTTP request
-	resp, err := h.Client.Do(req)
+	log := gogather.Logger(ctx, h.Logger)
+	log.Debug("downloading file", "source", gogather.RedactURL(source), "destination", destination)
+	resp, err := h.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error downloading file: %w", err)
 	}
@@ -121,8 +136,14 @@ func (h *HTTPGatherer) Gather(ctx context.Context, source, destination string) (
 
 	// Check if the response was successful
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("response code error: %d", resp.StatusCode)
+		return nil, &gogather.StatusError{StatusCode: resp.StatusCode}
+	}
+	if resp.ContentLength > 0 {
+		if err := gogather.CheckWritten(ctx, resp.ContentLength); err != nil {
+			return nil, err
+		}
 	}
+	gogather.StartProgress(ctx, resp.ContentLength, 1)
 	// Determine the destination type
 	scheme, err := gogather.ClassifyURI(destination)
 	if err != nil {
@@ -135,12 +156,19 @@ func (h *HTTPGatherer) Gather(ctx context.Context, source, destination string) (
 		return nil, fmt.Errorf("error creating saver: %w", err)
 	}
 
-	// Save the downloaded file
-	err = s.Save(ctx, resp.Body, destination)
+	// Save the downloaded file, computing its digest and verifying it against the checksum of the
+	// gather options on the way
+	verifier, err := gogather.NewChecksumVerifier(ctx)
+	if err != nil {
+		return nil, err
+	}
+	checksum := saver.NewChecksumSaver(s, crypto.SHA256)
+	body := verifier.Reader(gogather.WrapReader(ctx, resp.Body))
+	err = checksum.Save(ctx, body, destination)
 	if err != nil {
 		if strings.Contains(err.Error(), "is a directory") {
 			destination = filepath.Join(destination, filepath.Base(src.Path))
-			err = s.Save(ctx, resp.Body, destination)
+			err = checksum.Save(ctx, body, destination)
 			if err != nil {
 				return nil, fmt.Errorf("error saving file: %w", err)
 			}
@@ -149,12 +177,130 @@ func (h *HTTPGatherer) Gather(ctx context.Context, source, destination string) (
 		}
 	}
 
+	if err := verifier.Verify(destination); err != nil {
+		_ = os.Remove(destination)
+		return nil, err
+	}
+	gogather.CountItems(ctx, 1)
+	log.Debug("saved file", "destination", destination, "url", gogather.RedactURL(resp.Request.URL.String()))
+
 	// Return the metadata of the downloaded file
 	m := httpMetadata.HTTPMetadata{
+		Common:        metadata.NewCommon("http", gogather.RedactURL(source), gogather.RedactURL(resp.Request.URL.String()), destination, startedAt),
+		StatusCode:    resp.StatusCode,
+		ContentLength: resp.ContentLength,
+		Headers:       resp.Header,
+	}
+	m.SHA256, _ = checksum.Digest(destination)
+	return m, nil
+}
+
+// Resolve sends a HEAD request for source, following redirects, and returns the metadata of the
+// response without downloading the file. The digest of the file is taken from the Repr-Digest or
+// Digest response header, or from a sha256 checksum in the gather options, so that GetPinnedURL
+// pins source, and the ResolvedURI of the metadata is the pinned URL the request was answered for.
+// When neither is available the digest is left unset, and only the response headers, e.g. ETag and
+// Last-Modified, identify the file.
+func (h *HTTPGatherer) Resolve(ctx context.Context, source string) (_ metadata.Metadata, err error) {
+	defer func() { err = gogather.RedactError(err) }()
+	startedAt := time.Now()
+
+	src, err := url.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing source URI: %w", err)
+	}
+	if src.Scheme == "" {
+		return nil, fmt.Errorf("no source scheme provided")
+	}
+	if err := gogather.CheckHost(ctx, src.Scheme, src.Hostname()); err != nil {
+		return nil, err
+	}
+
+	req, err := newRequest(ctx, "HEAD", source)
+	if err != nil {
+		return nil, err
+	}
+	gogather.Logger(ctx, h.Logger).Debug("resolving file", "source", gogather.RedactURL(source))
+	resp, err := h.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving file: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &gogather.StatusError{StatusCode: resp.StatusCode}
+	}
+
+	m := httpMetadata.HTTPMetadata{
+		Common:        metadata.NewCommon("http", gogather.RedactURL(source), gogather.RedactURL(resp.Request.URL.String()), "", startedAt),
 		StatusCode:    resp.StatusCode,
 		ContentLength: resp.ContentLength,
-		Destination:   destination,
 		Headers:       resp.Header,
+		SHA256:        headerDigest(resp.Header),
+	}
+	if m.SHA256 == "" {
+		if c, err := gogather.ParseChecksum(gogather.OptionsFromContext(ctx).Checksum); err == nil && c.Algorithm == "sha256" {
+			m.SHA256 = c.Value
+		}
+	}
+	if pinned, err := m.GetPinnedURL(m.ResolvedURI); err == nil {
+		m.ResolvedURI = pinned
 	}
 	return m, nil
 }
+
+// newRequest returns a request for source, authenticated with the credentials the gather options
+// provide for its host.
+func newRequest(ctx context.Context, method, source string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "Go-Gather")
+
+	opts := gogather.OptionsFromContext(ctx)
+	creds, err := opts.Credentials(ctx, req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if creds != nil {
+		if creds.Username == "" {
+			req.Header.Set("Authorization", "Bearer "+creds.Password)
+		} else {
+			req.SetBasicAuth(creds.Username, creds.Password)
+		}
+	}
+	return req, nil
+}
+
+// do sends req with the Client of the gatherer, configured for the gather options, see
+// gogather.HTTPClient.
+func (h *HTTPGatherer) do(req *http.Request) (*http.Response, error) {
+	h.Client.Transport = Transport
+
+	client, err := gogather.HTTPClient(req.Context(), &h.Client)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// headerDigest returns the hex encoded SHA256 digest of the content reported by the Repr-Digest
+// (RFC 9530) or Digest (RFC 3230) header, or an empty string if neither reports one.
+func headerDigest(header http.Header) string {
+	for _, name := range []string{"Repr-Digest", "Digest"} {
+		for _, value := range header.Values(name) {
+			for _, field := range strings.Split(value, ",") {
+				algorithm, digest, ok := strings.Cut(strings.TrimSpace(field), "=")
+				if !ok || !strings.EqualFold(algorithm, "sha-256") {
+					continue
+				}
+				sum, err := base64.StdEncoding.DecodeString(strings.Trim(digest, ":"))
+				if err == nil && len(sum) == sha256.Size {
+					return hex.EncodeToString(sum)
+				}
+			}
+		}
+	}
+	return ""
+}
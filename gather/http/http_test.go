@@ -18,6 +18,8 @@ package http
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	h "net/http"
 	"net/http/httptest"
@@ -28,6 +30,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	gogather "github.com/enterprise-contract/go-gather"
 	"github.com/enterprise-contract/go-gather/metadata/http"
 )
 
@@ -308,3 +311,165 @@ func TestHTTPGatherer_Gather_ClassifyURI_Error(t *testing.T) {
 	}
 	assert.EqualError(t, err, "error determining destination type: unsupported protocol: foo")
 }
+
+// TestHTTPGatherer_Gather_Common tests that the common metadata fields are populated.
+func TestHTTPGatherer_Gather_Common(t *testing.T) {
+	mockServer := httptest.NewServer(h.HandlerFunc(func(w h.ResponseWriter, r *h.Request) {
+		if r.URL.Path == "/old.txt" {
+			h.Redirect(w, r, "/new.txt", h.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "Hello, World!")
+	}))
+	defer mockServer.Close()
+
+	source := mockServer.URL + "/old.txt"
+	destination := filepath.Join(t.TempDir(), "file.txt")
+
+	m, err := NewHTTPGatherer().Gather(context.Background(), source, destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hm := m.(http.HTTPMetadata)
+	assert.Equal(t, "http", hm.Gatherer)
+	assert.Equal(t, source, hm.SourceURI)
+	assert.Equal(t, mockServer.URL+"/new.txt", hm.ResolvedURI)
+	assert.Equal(t, destination, hm.Destination)
+	assert.False(t, hm.StartedAt.IsZero())
+	assert.Positive(t, hm.Duration)
+	// SHA256 of "Hello, World!"
+	assert.Equal(t, "dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f", hm.SHA256)
+}
+
+// TestHTTPGatherer_Gather_Options tests that the gather options carried by the context are honored
+func TestHTTPGatherer_Gather_Options(t *testing.T) {
+	mockServer := httptest.NewServer(h.HandlerFunc(func(w h.ResponseWriter, r *h.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			w.WriteHeader(h.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "Hello, World!")
+	}))
+	defer mockServer.Close()
+
+	auth := gogather.AuthProviderFunc(func(_ context.Context, host string) (*gogather.Credentials, error) {
+		return &gogather.Credentials{Username: "user", Password: "secret"}, nil
+	})
+
+	var written int64
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{
+		Auth:     auth,
+		Progress: gogather.ProgressFunc(func(n int64) { written = n }),
+	})
+	_, err := NewHTTPGatherer().Gather(ctx, mockServer.URL+"/file.txt", filepath.Join(t.TempDir(), "file.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len("Hello, World!")), written)
+
+	// The Content-Length of the response exceeds the limit, so nothing is downloaded.
+	ctx = gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Auth: auth, MaxSize: 5})
+	destination := filepath.Join(t.TempDir(), "file.txt")
+	_, err = NewHTTPGatherer().Gather(ctx, mockServer.URL+"/file.txt", destination)
+	var sizeErr *gogather.MaxSizeError
+	assert.ErrorAs(t, err, &sizeErr)
+	assert.ErrorIs(t, err, gogather.ErrTooLarge)
+	assert.NoFileExists(t, destination)
+}
+
+// TestHTTPGatherer_Gather_Checksum tests verifying the downloaded file against the checksum option
+func TestHTTPGatherer_Gather_Checksum(t *testing.T) {
+	mockServer := httptest.NewServer(h.HandlerFunc(func(w h.ResponseWriter, r *h.Request) {
+		fmt.Fprint(w, "Hello, World!")
+	}))
+	defer mockServer.Close()
+
+	digest := "sha256:dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f"
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Checksum: digest})
+	_, err := NewHTTPGatherer().Gather(ctx, mockServer.URL+"/file.txt", filepath.Join(t.TempDir(), "file.txt"))
+	assert.NoError(t, err)
+
+	destination := filepath.Join(t.TempDir(), "file.txt")
+	ctx = gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Checksum: "md5:00000000000000000000000000000000"})
+	_, err = NewHTTPGatherer().Gather(ctx, mockServer.URL+"/file.txt", destination)
+	var mismatch *gogather.ChecksumMismatchError
+	assert.ErrorAs(t, err, &mismatch)
+	assert.NoFileExists(t, destination)
+}
+
+// TestHTTPGatherer_Gather_HostPolicy tests denying hosts and redirects with the host policy
+func TestHTTPGatherer_Gather_HostPolicy(t *testing.T) {
+	requests := 0
+	mockServer := httptest.NewServer(h.HandlerFunc(func(w h.ResponseWriter, r *h.Request) {
+		requests++
+		h.Redirect(w, r, "http://169.254.169.254/latest/meta-data", h.StatusFound)
+	}))
+	defer mockServer.Close()
+
+	var denied *gogather.HostDeniedError
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{HostPolicy: &gogather.HostPolicy{DenyPrivateNetworks: true}})
+	_, err := NewHTTPGatherer().Gather(ctx, mockServer.URL+"/file.txt", filepath.Join(t.TempDir(), "file.txt"))
+	assert.ErrorAs(t, err, &denied)
+	assert.Equal(t, 0, requests)
+
+	ctx = gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{HostPolicy: &gogather.HostPolicy{DeniedHosts: []string{"169.254.169.254"}}})
+	_, err = NewHTTPGatherer().Gather(ctx, mockServer.URL+"/file.txt", filepath.Join(t.TempDir(), "file.txt"))
+	assert.ErrorAs(t, err, &denied)
+	assert.Equal(t, "169.254.169.254", denied.Host)
+	assert.Equal(t, 1, requests)
+}
+
+// TestHTTPGatherer_Gather_Redaction tests that credentials in the source do not end up in the
+// metadata or in errors
+func TestHTTPGatherer_Gather_Redaction(t *testing.T) {
+	mockServer := httptest.NewServer(h.HandlerFunc(func(w h.ResponseWriter, r *h.Request) {
+		fmt.Fprint(w, "Hello, World!")
+	}))
+
+	source := mockServer.URL + "/file.txt?token=secret"
+	m, err := NewHTTPGatherer().Gather(context.Background(), source, filepath.Join(t.TempDir(), "file.txt"))
+	assert.NoError(t, err)
+	hm := m.(http.HTTPMetadata)
+	assert.Equal(t, mockServer.URL+"/file.txt?token=REDACTED", hm.SourceURI)
+	assert.Equal(t, mockServer.URL+"/file.txt?token=REDACTED", hm.ResolvedURI)
+	pinned, err := hm.GetPinnedURL(hm.SourceURI)
+	assert.NoError(t, err)
+	assert.NotContains(t, pinned, "secret")
+
+	mockServer.Close()
+	_, err = NewHTTPGatherer().Gather(context.Background(), source, filepath.Join(t.TempDir(), "file.txt"))
+	assert.ErrorContains(t, err, "token=REDACTED")
+	assert.NotContains(t, err.Error(), "secret")
+}
+
+// TestHTTPGatherer_Resolve tests resolving a file with a HEAD request without downloading it
+func TestHTTPGatherer_Resolve(t *testing.T) {
+	sum := sha256.Sum256([]byte("Hello, World!"))
+	var methods []string
+	mockServer := httptest.NewServer(h.HandlerFunc(func(w h.ResponseWriter, r *h.Request) {
+		methods = append(methods, r.Method)
+		w.Header().Set("ETag", `"v1"`)
+		if r.URL.Path == "/digest.txt" {
+			w.Header().Set("Repr-Digest", "sha-512=:AAAA:, sha-256=:"+base64.StdEncoding.EncodeToString(sum[:])+":")
+		}
+	}))
+	defer mockServer.Close()
+
+	m, err := NewHTTPGatherer().Resolve(context.Background(), mockServer.URL+"/digest.txt")
+	assert.NoError(t, err)
+	hm := m.(http.HTTPMetadata)
+	assert.Equal(t, "dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f", hm.SHA256)
+	assert.Equal(t, "http::"+mockServer.URL+"/digest.txt?checksum=sha256:dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f", hm.ResolvedURI)
+	assert.Equal(t, `"v1"`, h.Header(hm.Headers).Get("ETag"))
+	assert.Equal(t, []string{h.MethodHead}, methods)
+
+	m, err = NewHTTPGatherer().Resolve(context.Background(), mockServer.URL+"/file.txt")
+	assert.NoError(t, err)
+	hm = m.(http.HTTPMetadata)
+	assert.Empty(t, hm.SHA256)
+	assert.Equal(t, mockServer.URL+"/file.txt", hm.ResolvedURI)
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Checksum: "sha256:dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f"})
+	m, err = NewHTTPGatherer().Resolve(ctx, mockServer.URL+"/file.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f", m.(http.HTTPMetadata).SHA256)
+}
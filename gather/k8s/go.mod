@@ -0,0 +1,10 @@
+module github.com/enterprise-contract/go-gather/gather/k8s
+
+go 1.22.5
+
+require (
+	github.com/enterprise-contract/go-gather v0.0.3
+	github.com/enterprise-contract/go-gather/metadata v0.0.2
+	github.com/enterprise-contract/go-gather/metadata/k8s v0.0.1
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,338 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package k8s provides functionality for gathering the keys of Kubernetes ConfigMaps and Secrets.
+// It includes an implementation of the Gatherer interface, KubernetesGatherer, which reads an
+// object from the API server of a cluster and materializes its keys as files.
+//
+// Sources are of the form k8s://namespace/configmap/name, or k8s://namespace/secret/name for
+// Secrets, optionally followed by /key to gather a single key. The context parameter selects the
+// kubeconfig context to use, e.g. k8s://policies/configmap/rules?context=production. The cluster is
+// reached with the kubeconfig the KUBECONFIG environment variable or ~/.kube/config point to, or,
+// in a pod, with the credentials of its service account.
+//
+// Example usage:
+//
+//	g := &k8s.KubernetesGatherer{}
+//	m, err := g.Gather(context.Background(), "k8s://policies/configmap/rules", "/tmp/rules")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package k8s
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+	k8sMetadata "github.com/enterprise-contract/go-gather/metadata/k8s"
+)
+
+// KubernetesGatherer materializes the keys of Kubernetes ConfigMaps and Secrets as files.
+type KubernetesGatherer struct {
+	// Kubeconfig is the path of the kubeconfig file to use. When empty, the first existing file
+	// listed by the KUBECONFIG environment variable is used, or else ~/.kube/config. When none
+	// exists, the service account of the pod the process runs in is used.
+	Kubeconfig string
+	// Context is the kubeconfig context to use, unless the source names one. When empty, the
+	// current context is used.
+	Context string
+	// Logger receives diagnostic messages. When nil, the logger of the gather options is used.
+	Logger *slog.Logger
+}
+
+// object is the subset of a ConfigMap or Secret that is used. The values of Data are base64
+// encoded for Secrets, as are the ones of BinaryData, which only ConfigMaps have.
+type object struct {
+	Metadata struct {
+		UID             string `json:"uid"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Data       map[string]string `json:"data"`
+	BinaryData map[string][]byte `json:"binaryData"`
+}
+
+// keyPattern matches valid keys of ConfigMaps and Secrets.
+var keyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// Gather reads the ConfigMap or Secret of source and materializes its keys as files named after
+// them in the destination directory. The Include and Exclude patterns of the gather options select
+// the keys to materialize. When the source names a key, only that key is materialized, as the
+// destination file, or under its own name if the destination is an existing directory or ends
+// with a separator. Files materialized from Secrets are only readable by their owner.
+func (g *KubernetesGatherer) Gather(ctx context.Context, source, destination string) (_ metadata.Metadata, err error) {
+	defer func() {
+		err = gogather.RedactError(err)
+		gogather.FinishProgress(ctx, err)
+	}()
+	startedAt := time.Now()
+
+	loc, err := parseSource(source)
+	if err != nil {
+		return nil, err
+	}
+	kubeContext := loc.context
+	if kubeContext == "" {
+		kubeContext = g.Context
+	}
+	cfg, err := loadConfig(ctx, g.Kubeconfig, kubeContext)
+	if err != nil {
+		return nil, err
+	}
+	files, obj, err := g.get(ctx, cfg, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &k8sMetadata.KubernetesMetadata{
+		Server:          gogather.RedactURL(cfg.server.String()),
+		Namespace:       loc.namespace,
+		Kind:            loc.kind,
+		Name:            loc.name,
+		UID:             obj.Metadata.UID,
+		ResourceVersion: obj.Metadata.ResourceVersion,
+	}
+	perm := os.FileMode(0644)
+	if loc.kind == "Secret" {
+		perm = 0600
+	}
+
+	if loc.key != "" {
+		data, ok := files[loc.key]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found in %s %s/%s", loc.key, loc.kind, loc.namespace, loc.name)
+		}
+		if strings.HasSuffix(destination, string(os.PathSeparator)) || strings.HasSuffix(destination, "/") {
+			destination = filepath.Join(destination, loc.key)
+		} else if fi, err := os.Stat(destination); err == nil && fi.IsDir() {
+			destination = filepath.Join(destination, loc.key)
+		}
+		if err := g.write(ctx, destination, data, perm, 1); err != nil {
+			return nil, err
+		}
+		if err := gogather.VerifyChecksum(ctx, destination); err != nil {
+			_ = os.Remove(destination)
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		m.Keys, m.Size, m.SHA256 = []string{loc.key}, int64(len(data)), hex.EncodeToString(sum[:])
+	} else {
+		filter, err := gogather.NewPathFilter(gogather.OptionsFromContext(ctx).Include, gogather.OptionsFromContext(ctx).Exclude)
+		if err != nil {
+			return nil, err
+		}
+		for key := range files {
+			if !filter.Match(key) {
+				delete(files, key)
+			}
+		}
+		for key, data := range files {
+			m.Keys = append(m.Keys, key)
+			m.Size += int64(len(data))
+		}
+		slices.Sort(m.Keys)
+		if err := gogather.CheckWritten(ctx, m.Size); err != nil {
+			return nil, err
+		}
+		gogather.StartProgress(ctx, m.Size, len(m.Keys))
+		if err := os.MkdirAll(destination, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+		for _, key := range m.Keys {
+			if err := g.write(ctx, filepath.Join(destination, key), files[key], perm, -1); err != nil {
+				return nil, err
+			}
+		}
+		if m.TreeHash, err = metadata.TreeHash(destination); err != nil {
+			return nil, err
+		}
+		if err := gogather.VerifyChecksumDigest(ctx, destination, "sha256", m.TreeHash); err != nil {
+			return nil, err
+		}
+	}
+
+	resolved, _ := m.GetPinnedURL(gogather.RedactURL(source))
+	m.Common = metadata.NewCommon(k8sMetadata.Type, gogather.RedactURL(source), resolved, destination, startedAt)
+	return m, nil
+}
+
+// write writes data to the file at path with the permissions perm, counting it as written. If
+// items is not negative, the progress of the gather is started with that number of items first.
+func (g *KubernetesGatherer) write(ctx context.Context, path string, data []byte, perm os.FileMode, items int) error {
+	if items >= 0 {
+		if err := gogather.CheckWritten(ctx, int64(len(data))); err != nil {
+			return err
+		}
+		gogather.StartProgress(ctx, int64(len(data)), items)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	// WriteFile leaves the permissions of existing files as they are.
+	if err := os.Chmod(path, perm); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := gogather.CountWritten(ctx, int64(len(data))); err != nil {
+		return err
+	}
+	gogather.CountItems(ctx, 1)
+	return nil
+}
+
+// get reads the object of loc from the API server of cfg, returning the content of its keys along
+// with the object.
+func (g *KubernetesGatherer) get(ctx context.Context, cfg *restConfig, loc *location) (map[string][]byte, *object, error) {
+	if err := gogather.CheckHost(ctx, cfg.server.Scheme, cfg.server.Hostname()); err != nil {
+		return nil, nil, err
+	}
+	resource := "configmaps"
+	if loc.kind == "Secret" {
+		resource = "secrets"
+	}
+	u := cfg.server.JoinPath("api", "v1", "namespaces", loc.namespace, resource, loc.name)
+	gogather.Logger(ctx, g.Logger).Debug("reading object", "kind", loc.kind, "namespace", loc.namespace, "name", loc.name, "server", gogather.RedactURL(cfg.server.String()))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "Go-Gather")
+	if cfg.token != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.token)
+	} else if cfg.username != "" {
+		req.SetBasicAuth(cfg.username, cfg.password)
+	}
+
+	client, err := newClient(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get %s %s/%s: %w", loc.kind, loc.namespace, loc.name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil, fmt.Errorf("%s %s/%s not found", loc.kind, loc.namespace, loc.name)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("failed to get %s %s/%s: %w", loc.kind, loc.namespace, loc.name, &gogather.StatusError{StatusCode: resp.StatusCode})
+	}
+	var obj object
+	if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode %s %s/%s: %w", loc.kind, loc.namespace, loc.name, err)
+	}
+
+	files := make(map[string][]byte, len(obj.Data)+len(obj.BinaryData))
+	for key, value := range obj.Data {
+		if loc.kind != "Secret" {
+			files[key] = []byte(value)
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode key %s of %s %s/%s: %w", key, loc.kind, loc.namespace, loc.name, err)
+		}
+		files[key] = data
+	}
+	for key, data := range obj.BinaryData {
+		files[key] = data
+	}
+	for key := range files {
+		// Keys are validated by the API server; this guards the destination against other servers.
+		if !keyPattern.MatchString(key) || key == "." || key == ".." {
+			return nil, nil, fmt.Errorf("invalid key %q in %s %s/%s", key, loc.kind, loc.namespace, loc.name)
+		}
+	}
+	return files, &obj, nil
+}
+
+// newClient returns an HTTP client connecting to the API server of cfg, configured for the gather
+// options, see gogather.HTTPClient.
+func newClient(ctx context.Context, cfg *restConfig) (*http.Client, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = cfg.tls
+	if cfg.proxy != "" {
+		u, err := url.Parse(cfg.proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %s: %w", gogather.RedactURL(cfg.proxy), err)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	return gogather.HTTPClient(ctx, &http.Client{Transport: t})
+}
+
+// location identifies the object, and optionally the key of it, of a source, along with the
+// kubeconfig context to read it with.
+type location struct {
+	namespace, kind, name, key string
+	context                    string
+}
+
+// parseSource parses a source of the form k8s://namespace/configmap/name/key?context=name.
+func parseSource(source string) (*location, error) {
+	u, err := url.Parse(strings.TrimPrefix(source, "k8s::"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source %s: %w", source, err)
+	}
+	if u.Scheme != "k8s" {
+		return nil, fmt.Errorf("unsupported Kubernetes source: %s", source)
+	}
+	for key := range u.Query() {
+		if key != "context" {
+			return nil, fmt.Errorf("unsupported parameters of %s", source)
+		}
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Host == "" || len(parts) < 2 || len(parts) > 3 || slices.Contains(parts, "") {
+		return nil, fmt.Errorf("source must be of the form k8s://namespace/configmap/name[/key]: %s", source)
+	}
+	loc := &location{namespace: u.Host, name: parts[1], context: u.Query().Get("context")}
+	switch strings.ToLower(parts[0]) {
+	case "configmap", "configmaps", "cm":
+		loc.kind = "ConfigMap"
+	case "secret", "secrets":
+		loc.kind = "Secret"
+	default:
+		return nil, fmt.Errorf("unsupported kind %s of %s, expected configmap or secret", parts[0], source)
+	}
+	if len(parts) == 3 {
+		loc.key = parts[2]
+		if !keyPattern.MatchString(loc.key) || loc.key == "." || loc.key == ".." {
+			return nil, fmt.Errorf("invalid key %s of %s", loc.key, source)
+		}
+	}
+	return loc, nil
+}
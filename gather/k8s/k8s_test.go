@@ -0,0 +1,269 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	k8sMetadata "github.com/enterprise-contract/go-gather/metadata/k8s"
+)
+
+// newServer starts a fake API server holding the ConfigMap policies/rules, with the keys a.rego
+// and b.bin, and the Secret policies/creds, with the key password. Requests must carry the bearer
+// token secret-token.
+func newServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/namespaces/policies/configmaps/rules":
+			w.Write([]byte(`{"kind":"ConfigMap","metadata":{"name":"rules","uid":"1234","resourceVersion":"42"},` +
+				`"data":{"a.rego":"package a\n"},"binaryData":{"b.bin":"` + base64.StdEncoding.EncodeToString([]byte{0, 1, 2}) + `"}}`))
+		case "/api/v1/namespaces/policies/secrets/creds":
+			w.Write([]byte(`{"kind":"Secret","metadata":{"name":"creds","uid":"5678","resourceVersion":"7"},` +
+				`"data":{"password":"` + base64.StdEncoding.EncodeToString([]byte("hunter2")) + `"}}`))
+		case "/api/v1/namespaces/policies/configmaps/evil":
+			w.Write([]byte(`{"data":{"../escape":"x"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// caData returns the base64 encoded PEM certificate of srv.
+func caData(srv *httptest.Server) string {
+	return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+}
+
+// writeKubeconfig writes a kubeconfig with the current context test, connecting to srv as the
+// user given in YAML, and returns its path.
+func writeKubeconfig(t *testing.T, srv *httptest.Server, user string) string {
+	path := filepath.Join(t.TempDir(), "config")
+	config := `apiVersion: v1
+kind: Config
+current-context: test
+clusters:
+- name: test
+  cluster:
+    server: ` + srv.URL + `
+    certificate-authority-data: ` + caData(srv) + `
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+users:
+- name: test
+  user:
+    ` + user + "\n"
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestKubernetesGatherer_Gather tests materializing the keys of a ConfigMap
+func TestKubernetesGatherer_Gather(t *testing.T) {
+	srv := newServer(t)
+	g := &KubernetesGatherer{Kubeconfig: writeKubeconfig(t, srv, "token: secret-token")}
+	destination := filepath.Join(t.TempDir(), "rules")
+
+	m, err := g.Gather(context.Background(), "k8s://policies/configmap/rules", destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(destination, "a.rego")); err != nil || string(data) != "package a\n" {
+		t.Errorf("unexpected content: %q, %v", data, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(destination, "b.bin")); err != nil || string(data) != "\x00\x01\x02" {
+		t.Errorf("unexpected content: %q, %v", data, err)
+	}
+	km := m.(*k8sMetadata.KubernetesMetadata)
+	if km.Kind != "ConfigMap" || km.UID != "1234" || km.ResourceVersion != "42" || km.Size != 13 || len(km.Keys) != 2 || km.Keys[0] != "a.rego" || km.TreeHash == "" {
+		t.Errorf("unexpected metadata: %+v", km)
+	}
+	if km.Server != srv.URL || km.ResolvedURI != "k8s://policies/configmap/rules?checksum=sha256:"+km.TreeHash {
+		t.Errorf("unexpected metadata: %+v", km)
+	}
+}
+
+// TestKubernetesGatherer_Gather_Include tests selecting the keys to materialize
+func TestKubernetesGatherer_Gather_Include(t *testing.T) {
+	srv := newServer(t)
+	g := &KubernetesGatherer{Kubeconfig: writeKubeconfig(t, srv, "token: secret-token")}
+	destination := filepath.Join(t.TempDir(), "rules")
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Include: []string{"*.rego"}})
+	m, err := g.Gather(ctx, "k8s://policies/cm/rules", destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(destination, "b.bin")); !os.IsNotExist(err) {
+		t.Errorf("expected b.bin to be excluded, got %v", err)
+	}
+	if km := m.(*k8sMetadata.KubernetesMetadata); len(km.Keys) != 1 || km.Size != 10 {
+		t.Errorf("unexpected metadata: %+v", km)
+	}
+}
+
+// TestKubernetesGatherer_Gather_Key tests materializing a single key of a Secret
+func TestKubernetesGatherer_Gather_Key(t *testing.T) {
+	srv := newServer(t)
+	g := &KubernetesGatherer{Kubeconfig: writeKubeconfig(t, srv, "token: secret-token")}
+	dir := t.TempDir()
+
+	m, err := g.Gather(context.Background(), "k8s::k8s://policies/secret/creds/password", dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	destination := filepath.Join(dir, "password")
+	if data, err := os.ReadFile(destination); err != nil || string(data) != "hunter2" {
+		t.Fatalf("unexpected content: %q, %v", data, err)
+	}
+	if fi, err := os.Stat(destination); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("unexpected mode: %v, %v", fi.Mode(), err)
+	}
+	km := m.(*k8sMetadata.KubernetesMetadata)
+	if km.Kind != "Secret" || km.Destination != destination || km.SHA256 != "f52fbd32b2b3b86ff88ef6c490628285f482af15ddcb29541f94bcf526a3f6c7" {
+		t.Errorf("unexpected metadata: %+v", km)
+	}
+}
+
+// TestKubernetesGatherer_Gather_InCluster tests authenticating with the service account of a pod
+func TestKubernetesGatherer_Gather_InCluster(t *testing.T) {
+	srv := newServer(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("secret-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(filepath.Join(dir, "ca.crt"), ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer func(d string) { serviceAccountDir = d }(serviceAccountDir)
+	serviceAccountDir = dir
+	u, _ := url.Parse(srv.URL)
+	t.Setenv("KUBECONFIG", filepath.Join(dir, "missing"))
+	t.Setenv("KUBERNETES_SERVICE_HOST", u.Hostname())
+	t.Setenv("KUBERNETES_SERVICE_PORT", u.Port())
+
+	destination := filepath.Join(t.TempDir(), "a.rego")
+	if _, err := (&KubernetesGatherer{}).Gather(context.Background(), "k8s://policies/configmap/rules/a.rego", destination); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, err := os.ReadFile(destination); err != nil || string(data) != "package a\n" {
+		t.Errorf("unexpected content: %q, %v", data, err)
+	}
+
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	if _, err := (&KubernetesGatherer{}).Gather(context.Background(), "k8s://policies/configmap/rules", t.TempDir()); err == nil {
+		t.Error("expected an error without a kubeconfig outside of a cluster")
+	}
+}
+
+// TestKubernetesGatherer_Gather_Errors tests the failures of gathering objects
+func TestKubernetesGatherer_Gather_Errors(t *testing.T) {
+	srv := newServer(t)
+	g := &KubernetesGatherer{Kubeconfig: writeKubeconfig(t, srv, "token: secret-token")}
+	u, _ := url.Parse(srv.URL)
+
+	tests := []struct {
+		name   string
+		source string
+		opts   gogather.GatherOptions
+	}{
+		{name: "missing object", source: "k8s://policies/configmap/missing"},
+		{name: "missing key", source: "k8s://policies/configmap/rules/c.rego"},
+		{name: "invalid key", source: "k8s://policies/configmap/evil"},
+		{name: "checksum mismatch", source: "k8s://policies/configmap/rules", opts: gogather.GatherOptions{Checksum: "sha256:abc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := gogather.ContextWithOptions(context.Background(), tt.opts)
+			if _, err := g.Gather(ctx, tt.source, filepath.Join(t.TempDir(), "out")); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{MaxSize: 5})
+	if _, err := g.Gather(ctx, "k8s://policies/configmap/rules", t.TempDir()); !errors.Is(err, gogather.ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	var hostErr *gogather.HostDeniedError
+	ctx = gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{HostPolicy: &gogather.HostPolicy{DeniedHosts: []string{u.Hostname()}}})
+	if _, err := g.Gather(ctx, "k8s://policies/configmap/rules", t.TempDir()); !errors.As(err, &hostErr) {
+		t.Errorf("expected HostDeniedError, got %v", err)
+	}
+	var statusErr *gogather.StatusError
+	g.Kubeconfig = writeKubeconfig(t, srv, "token: wrong")
+	if _, err := g.Gather(context.Background(), "k8s://policies/configmap/rules", t.TempDir()); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized || statusErr.Retryable() {
+		t.Errorf("expected StatusError, got %v", err)
+	}
+}
+
+// TestParseSource tests parsing Kubernetes sources
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected *location
+		wantErr  bool
+	}{
+		{source: "k8s://ns/configmap/name", expected: &location{namespace: "ns", kind: "ConfigMap", name: "name"}},
+		{source: "k8s::k8s://ns/secrets/name/tls.crt", expected: &location{namespace: "ns", kind: "Secret", name: "name", key: "tls.crt"}},
+		{source: "k8s://ns/cm/name?context=prod", expected: &location{namespace: "ns", kind: "ConfigMap", name: "name", context: "prod"}},
+		{source: "k8s://ns/pod/name", wantErr: true},
+		{source: "k8s://ns/configmap", wantErr: true},
+		{source: "k8s://ns/configmap/name/key/extra", wantErr: true},
+		{source: "k8s:///configmap/name", wantErr: true},
+		{source: "k8s://ns/configmap/name/..", wantErr: true},
+		{source: "k8s://ns/configmap/name?ref=main", wantErr: true},
+		{source: "https://ns/configmap/name", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			l, err := parseSource(tt.source)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", l)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *l != *tt.expected {
+				t.Errorf("unexpected location: got %+v, want %+v", l, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// serviceAccountDir is the directory the token and the CA certificate of the service account of
+// a pod are mounted in.
+var serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// restConfig holds the URL of an API server and the credentials to authenticate to it with.
+type restConfig struct {
+	server             *url.URL
+	tls                *tls.Config
+	token              string
+	username, password string
+	proxy              string
+}
+
+// kubeconfig is the subset of a kubeconfig file that is used.
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string  `yaml:"name"`
+		Cluster cluster `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User user   `yaml:"user"`
+	} `yaml:"users"`
+}
+
+type cluster struct {
+	Server                   string `yaml:"server"`
+	CertificateAuthority     string `yaml:"certificate-authority"`
+	CertificateAuthorityData string `yaml:"certificate-authority-data"`
+	InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+	TLSServerName            string `yaml:"tls-server-name"`
+	ProxyURL                 string `yaml:"proxy-url"`
+}
+
+type user struct {
+	ClientCertificate     string `yaml:"client-certificate"`
+	ClientCertificateData string `yaml:"client-certificate-data"`
+	ClientKey             string `yaml:"client-key"`
+	ClientKeyData         string `yaml:"client-key-data"`
+	Token                 string `yaml:"token"`
+	TokenFile             string `yaml:"tokenFile"`
+	Username              string `yaml:"username"`
+	Password              string `yaml:"password"`
+	Exec                  *struct {
+		APIVersion string   `yaml:"apiVersion"`
+		Command    string   `yaml:"command"`
+		Args       []string `yaml:"args"`
+		Env        []struct {
+			Name  string `yaml:"name"`
+			Value string `yaml:"value"`
+		} `yaml:"env"`
+	} `yaml:"exec"`
+	AuthProvider *struct {
+		Name string `yaml:"name"`
+	} `yaml:"auth-provider"`
+}
+
+// loadConfig returns the configuration of the API server to connect to: the one of the given
+// context, or else the current context, of the kubeconfig file at path, if set; of the first
+// existing file listed by the KUBECONFIG environment variable, or of ~/.kube/config. When none of
+// them exists, the service account of the pod the process runs in is used.
+func loadConfig(ctx context.Context, path, context string) (*restConfig, error) {
+	if path == "" {
+		path = findKubeconfig()
+	}
+	if path == "" {
+		if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+			return nil, errors.New("no kubeconfig found and not running in a cluster")
+		}
+		return inClusterConfig()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubeconfig: %w", err)
+	}
+	var kc kubeconfig
+	if err := yaml.Unmarshal(data, &kc); err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig %s: %w", path, err)
+	}
+	return kc.config(ctx, filepath.Dir(path), context)
+}
+
+// findKubeconfig returns the path of the first existing kubeconfig file listed by the KUBECONFIG
+// environment variable, or else of ~/.kube/config if it exists, or an empty string.
+func findKubeconfig() string {
+	paths := filepath.SplitList(os.Getenv("KUBECONFIG"))
+	if home, err := os.UserHomeDir(); err == nil && len(paths) == 0 {
+		paths = append(paths, filepath.Join(home, ".kube", "config"))
+	}
+	for _, p := range paths {
+		if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+			return p
+		}
+	}
+	return ""
+}
+
+// inClusterConfig returns the configuration of the API server of the cluster the process runs in,
+// authenticating with the token of the service account of its pod.
+func inClusterConfig() (*restConfig, error) {
+	token, err := os.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA certificate: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("failed to parse service account CA certificate")
+	}
+	host := net.JoinHostPort(os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT"))
+	return &restConfig{
+		server: &url.URL{Scheme: "https", Host: host},
+		tls:    &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		token:  strings.TrimSpace(string(token)),
+	}, nil
+}
+
+// config returns the configuration of the API server of the named context, or of the current
+// context if name is empty. Relative paths are relative to dir, the directory of the kubeconfig.
+func (kc *kubeconfig) config(ctx context.Context, dir, name string) (*restConfig, error) {
+	if name == "" {
+		name = kc.CurrentContext
+	}
+	if name == "" {
+		return nil, errors.New("kubeconfig has no current context")
+	}
+	var clusterName, userName string
+	found := false
+	for _, c := range kc.Contexts {
+		if c.Name == name {
+			clusterName, userName, found = c.Context.Cluster, c.Context.User, true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("context %s not found in kubeconfig", name)
+	}
+
+	var cl *cluster
+	for i := range kc.Clusters {
+		if kc.Clusters[i].Name == clusterName {
+			cl = &kc.Clusters[i].Cluster
+		}
+	}
+	if cl == nil {
+		return nil, fmt.Errorf("cluster %s of context %s not found in kubeconfig", clusterName, name)
+	}
+	var u user
+	for _, v := range kc.Users {
+		if v.Name == userName {
+			u = v.User
+		}
+	}
+
+	server, err := url.Parse(cl.Server)
+	if err != nil || server.Host == "" {
+		return nil, fmt.Errorf("invalid server of cluster %s: %s", clusterName, cl.Server)
+	}
+	cfg := &restConfig{
+		server:   server,
+		tls:      &tls.Config{ServerName: cl.TLSServerName, InsecureSkipVerify: cl.InsecureSkipTLSVerify, MinVersion: tls.VersionTLS12}, // #nosec G402 -- set by the kubeconfig
+		token:    u.Token,
+		username: u.Username,
+		password: u.Password,
+		proxy:    cl.ProxyURL,
+	}
+
+	ca, err := readData(cl.CertificateAuthorityData, cl.CertificateAuthority, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate of cluster %s: %w", clusterName, err)
+	}
+	if ca != nil {
+		cfg.tls.RootCAs = x509.NewCertPool()
+		if !cfg.tls.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("failed to parse CA certificate of cluster %s", clusterName)
+		}
+	}
+
+	if u.TokenFile != "" && cfg.token == "" {
+		token, err := os.ReadFile(resolvePath(u.TokenFile, dir))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token of user %s: %w", userName, err)
+		}
+		cfg.token = strings.TrimSpace(string(token))
+	}
+	cert, err := readData(u.ClientCertificateData, u.ClientCertificate, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client certificate of user %s: %w", userName, err)
+	}
+	key, err := readData(u.ClientKeyData, u.ClientKey, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client key of user %s: %w", userName, err)
+	}
+
+	if u.Exec != nil {
+		status, err := runExec(ctx, u, dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get credentials of user %s: %w", userName, err)
+		}
+		if status.Token != "" {
+			cfg.token = status.Token
+		}
+		if status.ClientCertificateData != "" {
+			cert, key = []byte(status.ClientCertificateData), []byte(status.ClientKeyData)
+		}
+	} else if u.AuthProvider != nil && cfg.token == "" && cert == nil {
+		return nil, fmt.Errorf("auth provider %s of user %s is not supported, use a credential plugin", u.AuthProvider.Name, userName)
+	}
+
+	if cert != nil {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate of user %s: %w", userName, err)
+		}
+		cfg.tls.Certificates = []tls.Certificate{pair}
+	}
+	return cfg, nil
+}
+
+// execStatus is the status of the ExecCredential a credential plugin prints.
+type execStatus struct {
+	Token                 string `json:"token"`
+	ClientCertificateData string `json:"clientCertificateData"`
+	ClientKeyData         string `json:"clientKeyData"`
+}
+
+// runExec runs the credential plugin of u, e.g. the one of a managed Kubernetes service, and
+// returns the credentials it prints.
+func runExec(ctx context.Context, u user, dir string) (*execStatus, error) {
+	apiVersion := u.Exec.APIVersion
+	if apiVersion == "" {
+		apiVersion = "client.authentication.k8s.io/v1"
+	}
+	info, err := json.Marshal(map[string]any{
+		"apiVersion": apiVersion,
+		"kind":       "ExecCredential",
+		"spec":       map[string]any{"interactive": false},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Commands given as relative paths, rather than names looked up in PATH, are relative to the
+	// directory of the kubeconfig.
+	command := u.Exec.Command
+	if strings.ContainsRune(command, os.PathSeparator) {
+		command = resolvePath(command, dir)
+	}
+	cmd := exec.CommandContext(ctx, command, u.Exec.Args...)
+	cmd.Env = append(os.Environ(), "KUBERNETES_EXEC_INFO="+string(info))
+	for _, e := range u.Exec.Env {
+		cmd.Env = append(cmd.Env, e.Name+"="+e.Value)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("credential plugin %s failed: %w: %s", u.Exec.Command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var credential struct {
+		Status *execStatus `json:"status"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &credential); err != nil {
+		return nil, fmt.Errorf("failed to decode the output of credential plugin %s: %w", u.Exec.Command, err)
+	}
+	if credential.Status == nil {
+		return nil, fmt.Errorf("credential plugin %s returned no credentials", u.Exec.Command)
+	}
+	return credential.Status, nil
+}
+
+// readData returns the base64 encoded data, if set, or else the content of the file at path,
+// relative to dir, if set, or else nil.
+func readData(data, path, dir string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if path != "" {
+		return os.ReadFile(resolvePath(path, dir))
+	}
+	return nil, nil
+}
+
+// resolvePath returns path, joined to dir unless it is absolute.
+func resolvePath(path, dir string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,115 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"context"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLoadConfig_Exec tests authenticating with the token of a credential plugin
+func TestLoadConfig_Exec(t *testing.T) {
+	srv := newServer(t)
+	path := writeKubeconfig(t, srv, `exec:
+      apiVersion: client.authentication.k8s.io/v1
+      command: ./plugin.sh
+      args: [secret-token]
+      env:
+      - name: PREFIX
+        value: '{"status":{"token":"'`)
+	plugin := "#!/bin/sh\ncase \"$KUBERNETES_EXEC_INFO\" in *ExecCredential*) ;; *) exit 1;; esac\necho \"$PREFIX$1\\\"}}\"\n"
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), "plugin.sh"), []byte(plugin), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(context.Background(), path, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.token != "secret-token" || cfg.server.String() != srv.URL || cfg.tls.RootCAs == nil {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+// TestLoadConfig_Files tests reading the CA certificate and the token from files relative to the
+// kubeconfig
+func TestLoadConfig_Files(t *testing.T) {
+	srv := newServer(t)
+	dir := t.TempDir()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(filepath.Join(dir, "ca.crt"), ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("secret-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := `current-context: other
+clusters:
+- name: test
+  cluster:
+    server: ` + srv.URL + `
+    certificate-authority: ca.crt
+    tls-server-name: example.com
+contexts:
+- name: test
+  context: {cluster: test, user: test}
+users:
+- name: test
+  user: {tokenFile: token}
+`
+	path := filepath.Join(dir, "config")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KUBECONFIG", filepath.Join(dir, "missing")+string(os.PathListSeparator)+path)
+
+	cfg, err := loadConfig(context.Background(), "", "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.token != "secret-token" || cfg.tls.ServerName != "example.com" || cfg.tls.RootCAs == nil {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+// TestLoadConfig_Errors tests the failures of loading kubeconfigs
+func TestLoadConfig_Errors(t *testing.T) {
+	srv := newServer(t)
+	tests := []struct {
+		name    string
+		user    string
+		context string
+		wantErr string
+	}{
+		{name: "unknown context", user: "token: x", context: "missing", wantErr: "context missing not found"},
+		{name: "auth provider", user: "auth-provider: {name: gcp}", wantErr: "auth provider gcp"},
+		{name: "failing plugin", user: "exec: {command: false}", wantErr: "credential plugin false failed"},
+		{name: "missing token file", user: "tokenFile: missing", wantErr: "failed to read token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfig(context.Background(), writeKubeconfig(t, srv, tt.user), tt.context)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
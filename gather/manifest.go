@@ -0,0 +1,196 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest describes a batch of sources to gather, e.g. the policies a bundle is composed of:
+//
+//	concurrency: 4
+//	sources:
+//	  - name: release
+//	    source: git::https://github.com/org/policy.git//release?ref=main
+//	    destination: policy/release
+//	  - name: data
+//	    source: oci::quay.io/org/data:latest
+//	    destination: data
+//	    timeout: 1m
+type Manifest struct {
+	// Concurrency is the number of sources gathered at once. Zero or one gathers them in order.
+	Concurrency int `json:"concurrency,omitempty" yaml:"concurrency"`
+	// Sources are the entries of the manifest.
+	Sources []ManifestEntry `json:"sources" yaml:"sources"`
+}
+
+// ManifestEntry describes a source of a Manifest and the options it is gathered with, which
+// override those passed to GatherManifest.
+type ManifestEntry struct {
+	// Name identifies the entry in errors and in the lockfile. It defaults to the source.
+	Name string `json:"name,omitempty" yaml:"name"`
+	// Source is the source to gather, see Gather.
+	Source string `json:"source" yaml:"source"`
+	// Destination is the path the source is gathered to.
+	Destination string `json:"destination" yaml:"destination"`
+	// Timeout bounds the duration of the gather, see WithTimeout.
+	Timeout time.Duration `json:"timeout,omitempty" yaml:"timeout"`
+	// MaxSize limits the bytes written by the gather, see WithMaxSize.
+	MaxSize int64 `json:"maxSize,omitempty" yaml:"maxSize"`
+	// Checksum is the expected checksum of the gathered content, see WithChecksum.
+	Checksum string `json:"checksum,omitempty" yaml:"checksum"`
+	// Include lists glob patterns of the paths to keep, see WithInclude.
+	Include []string `json:"include,omitempty" yaml:"include"`
+	// Exclude lists glob patterns of the paths to drop, see WithExclude.
+	Exclude []string `json:"exclude,omitempty" yaml:"exclude"`
+}
+
+// name returns the name identifying the entry.
+func (e ManifestEntry) name() string {
+	if e.Name != "" {
+		return e.Name
+	}
+	return gogather.RedactURL(e.Source)
+}
+
+// options returns the gather options set by the entry.
+func (e ManifestEntry) options() []Option {
+	var opts []Option
+	if e.Timeout > 0 {
+		opts = append(opts, WithTimeout(e.Timeout))
+	}
+	if e.MaxSize > 0 {
+		opts = append(opts, WithMaxSize(e.MaxSize))
+	}
+	if e.Checksum != "" {
+		opts = append(opts, WithChecksum(e.Checksum))
+	}
+	if len(e.Include) > 0 {
+		opts = append(opts, WithInclude(e.Include...))
+	}
+	if len(e.Exclude) > 0 {
+		opts = append(opts, WithExclude(e.Exclude...))
+	}
+	return opts
+}
+
+// LoadManifest loads the manifest from the YAML or JSON file at path. Unknown keys are rejected.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	m := &Manifest{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(m); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	for i, e := range m.Sources {
+		if e.Source == "" || e.Destination == "" {
+			return nil, fmt.Errorf("manifest entry %d: source and destination are required", i)
+		}
+	}
+	return m, nil
+}
+
+// Lockfile records the sources of a Manifest pinned to the content they were gathered with, e.g.
+// git refs pinned to commits and OCI tags to digests.
+type Lockfile struct {
+	Sources []LockEntry `json:"sources" yaml:"sources"`
+}
+
+// LockEntry records a gathered entry of a Manifest.
+type LockEntry struct {
+	// Name is the name of the manifest entry.
+	Name string `json:"name" yaml:"name"`
+	// Source is the source of the manifest entry.
+	Source string `json:"source" yaml:"source"`
+	// Pinned is the source pinned to the gathered content, or the source itself if it cannot be
+	// pinned.
+	Pinned string `json:"pinned" yaml:"pinned"`
+	// Destination is the path the source was gathered to.
+	Destination string `json:"destination" yaml:"destination"`
+}
+
+// Write encodes the lockfile as indented JSON to w.
+func (l *Lockfile) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(l); err != nil {
+		return fmt.Errorf("failed to encode lockfile: %w", err)
+	}
+	return nil
+}
+
+// GatherManifest gathers the sources of the manifest with Gather, up to its Concurrency at once,
+// each with opts followed by the options of its entry. It returns the lockfile of the sources
+// that were gathered, in the order of the manifest, and the errors of the others, if any.
+func GatherManifest(ctx context.Context, m *Manifest, opts ...Option) (*Lockfile, error) {
+	concurrency := m.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	entries := make([]*LockEntry, len(m.Sources))
+	errs := make([]error, len(m.Sources))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, e := range m.Sources {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, e ManifestEntry) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			md, err := Gather(ctx, e.Source, e.Destination, append(append([]Option{}, opts...), e.options()...)...)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", e.name(), err)
+				return
+			}
+			pinned, err := md.GetPinnedURL(e.Source)
+			if err != nil || pinned == "" {
+				pinned = e.Source
+			}
+			entries[i] = &LockEntry{Name: e.name(), Source: gogather.RedactURL(e.Source), Pinned: gogather.RedactURL(pinned), Destination: e.Destination}
+		}(i, e)
+	}
+	wg.Wait()
+
+	l := &Lockfile{Sources: []LockEntry{}}
+	for _, e := range entries {
+		if e != nil {
+			l.Sources = append(l.Sources, *e)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return l, fmt.Errorf("failed to gather manifest: %w", err)
+	}
+	return l, nil
+}
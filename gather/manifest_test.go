@@ -0,0 +1,108 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLoadManifest tests loading manifests from YAML and JSON files
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "manifest.yaml")
+	data := `concurrency: 2
+sources:
+  - name: policy
+    source: git::https://example.com/org/policy.git?ref=main
+    destination: policy
+    timeout: 1m
+    include: ["**.rego"]
+`
+	if err := os.WriteFile(yamlPath, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadManifest(yamlPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.Concurrency != 2 || len(m.Sources) != 1 {
+		t.Fatalf("unexpected manifest: %+v", m)
+	}
+	if e := m.Sources[0]; e.Name != "policy" || e.Destination != "policy" || e.Timeout != time.Minute || len(e.Include) != 1 {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+
+	jsonPath := filepath.Join(dir, "manifest.json")
+	if err := os.WriteFile(jsonPath, []byte(`{"sources": [{"source": "/src", "destination": "dst", "maxSize": 1024}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := LoadManifest(jsonPath); err != nil || len(m.Sources) != 1 || m.Sources[0].MaxSize != 1024 {
+		t.Errorf("unexpected manifest: %+v, %v", m, err)
+	}
+
+	for name, data := range map[string]string{
+		"unknown key":         "sources: []\nunknown: true\n",
+		"missing destination": "sources: [{source: /src}]\n",
+	} {
+		if err := os.WriteFile(yamlPath, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadManifest(yamlPath); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// TestGatherManifest tests gathering the sources of a manifest and recording them in a lockfile
+func TestGatherManifest(t *testing.T) {
+	out := t.TempDir()
+	sources := []ManifestEntry{
+		{Name: "a", Source: writeSourceDir(t), Destination: filepath.Join(out, "a")},
+		{Source: writeSourceDir(t), Destination: filepath.Join(out, "b"), Include: []string{"*.rego"}},
+		{Name: "missing", Source: filepath.Join(t.TempDir(), "missing"), Destination: filepath.Join(out, "c")},
+	}
+
+	l, err := GatherManifest(context.Background(), &Manifest{Concurrency: 2, Sources: sources})
+	if err == nil || !strings.Contains(err.Error(), "missing:") {
+		t.Errorf("expected the error of the missing source, got %v", err)
+	}
+	if len(l.Sources) != 2 || l.Sources[0].Name != "a" || l.Sources[1].Name != sources[1].Source {
+		t.Fatalf("unexpected lockfile: %+v", l)
+	}
+	if got, want := l.Sources[0].Pinned, "file::"+sources[0].Source; got != want {
+		t.Errorf("unexpected pinned source: got %s, want %s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(out, "b", "README.md")); !os.IsNotExist(err) {
+		t.Errorf("expected the entry options to be applied: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := l.Write(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded Lockfile
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || len(decoded.Sources) != 2 {
+		t.Errorf("unexpected lockfile encoding: %s, %v", buf.String(), err)
+	}
+}
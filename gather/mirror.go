@@ -0,0 +1,111 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// GatherMirrors gathers one logical artifact from sources, a prioritized list of equivalent
+// sources, e.g. a registry and its mirrors or the mirrors of a git repository. The sources are
+// gathered with Gather in order until one succeeds; the SourceURI of the returned metadata holds
+// the source that served the gather. Content gathered from a source must have the identity the
+// sources pin, e.g. a commit or digest, otherwise the next source is tried. Sources pinning
+// different identities are rejected.
+func GatherMirrors(ctx context.Context, sources []string, destination string, opts ...Option) (metadata.Metadata, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("no sources to gather")
+	}
+	want, err := mirrorsIdentity(sources)
+	if err != nil {
+		return nil, err
+	}
+	o := gogather.OptionsFromContext(ctx)
+	o.Include = slices.Clone(o.Include)
+	o.Exclude = slices.Clone(o.Exclude)
+	for _, opt := range opts {
+		opt(&o)
+	}
+	log := o.Log()
+
+	var errs []error
+	for _, source := range sources {
+		m, err := Gather(ctx, source, destination, opts...)
+		if err == nil && want != "" {
+			if got := gatheredIdentity(m, source); got != want {
+				err = fmt.Errorf("gathered content %s does not match %s", got, want)
+			}
+		}
+		if err == nil {
+			return m, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		log.Warn("failed to gather source, trying the next one", "source", gogather.RedactURL(source), "error", err)
+		errs = append(errs, fmt.Errorf("%s: %w", gogather.RedactURL(source), err))
+	}
+	return nil, fmt.Errorf("failed to gather any of the sources: %w", errors.Join(errs...))
+}
+
+// mirrorsIdentity returns the identity pinned by sources, or "" if none is pinned.
+func mirrorsIdentity(sources []string) (string, error) {
+	var want string
+	for _, source := range sources {
+		id := sourceIdentity(source)
+		if id == "" {
+			continue
+		}
+		if want != "" && id != want {
+			return "", fmt.Errorf("sources pin different content: %s and %s", want, id)
+		}
+		want = id
+	}
+	return want, nil
+}
+
+// gatheredIdentity returns the identity of the content of m gathered from source.
+func gatheredIdentity(m metadata.Metadata, source string) string {
+	pinned, err := m.GetPinnedURL(source)
+	if err != nil {
+		return ""
+	}
+	return sourceIdentity(pinned)
+}
+
+// sourceIdentity returns the immutable identity source is pinned to, a commit ref or the digest of
+// an OCI reference, or "" if it is not pinned to one.
+func sourceIdentity(source string) string {
+	src, err := gogather.ParseSource(source)
+	if err != nil {
+		return ""
+	}
+	if commitPattern.MatchString(src.Ref) {
+		return src.Ref
+	}
+	if i := strings.LastIndex(src.URL, "@sha"); i != -1 {
+		return src.URL[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGatherMirrors tests failing over to the next of equivalent sources
+func TestGatherMirrors(t *testing.T) {
+	dir := writeSourceDir(t)
+	missing := filepath.Join(t.TempDir(), "missing")
+	destination := filepath.Join(t.TempDir(), "dst")
+
+	m, err := GatherMirrors(context.Background(), []string{missing, dir}, destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := m.Get()["sourceURI"]; got != dir {
+		t.Errorf("expected the gather to be served by %s, got %v", dir, got)
+	}
+	if _, err := os.Stat(filepath.Join(destination, "main.rego")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = GatherMirrors(context.Background(), []string{missing, missing + "2"}, filepath.Join(t.TempDir(), "dst"))
+	if err == nil || !strings.Contains(err.Error(), missing+"2") {
+		t.Errorf("expected the errors of all sources, got %v", err)
+	}
+	if _, err := GatherMirrors(context.Background(), nil, destination); err == nil {
+		t.Error("expected an error without sources")
+	}
+}
+
+// TestMirrorsIdentity tests determining the identity equivalent sources pin
+func TestMirrorsIdentity(t *testing.T) {
+	commit := strings.Repeat("a", 40)
+	digest := "sha256:" + strings.Repeat("b", 64)
+
+	tests := []struct {
+		sources []string
+		want    string
+		wantErr bool
+	}{
+		{[]string{"git::https://example.com/repo.git?ref=main", "git::https://mirror.example.com/repo.git?ref=main"}, "", false},
+		{[]string{"git::https://example.com/repo.git?ref=" + commit, "git::https://mirror.example.com/repo.git?ref=" + commit}, commit, false},
+		{[]string{"oci::registry.io/repo@" + digest, "oci::mirror.io/repo:latest"}, digest, false},
+		{[]string{"oci::registry.io/repo@" + digest, "oci::mirror.io/repo@sha256:" + strings.Repeat("c", 64)}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := mirrorsIdentity(tt.sources)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%v: got %q, %v, want %q", tt.sources, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+	fileMetadata "github.com/enterprise-contract/go-gather/metadata/file"
+)
+
+// normalizingGatherer normalizes the content gathered by the wrapped Gatherer, see
+// gogather.Normalize, so that gathers of the same pinned source produce identical trees.
+type normalizingGatherer struct {
+	Gatherer Gatherer
+}
+
+func (n *normalizingGatherer) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
+	m, err := n.Gatherer.Gather(ctx, source, destination)
+	if err != nil {
+		return m, err
+	}
+
+	content, _ := m.Get()["destination"].(string)
+	if content == "" {
+		content = destination
+	}
+	if err := gogather.Normalize(content); err != nil {
+		return nil, err
+	}
+	// The timestamps of files and directories are those of the content, which were normalized.
+	t, err := gogather.NormalizedTime()
+	if err != nil {
+		return nil, err
+	}
+	switch m := m.(type) {
+	case *fileMetadata.FileMetadata:
+		m.Timestamp = t
+	case *fileMetadata.DirectoryMetadata:
+		m.Timestamp = t
+	}
+	return m, nil
+}
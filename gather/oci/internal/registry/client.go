@@ -17,6 +17,7 @@
 package registry
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/spf13/viper"
@@ -30,7 +31,10 @@ import (
 
 /* This code is sourced from the open-policy-agent/conftest project. */
 
-func SetupClient(repository *remote.Repository, transport http.RoundTripper) error {
+// SetupClient configures the client of the repository to send its requests with httpClient,
+// retrying failed ones. Credentials are looked up with credential, if set, and in the Docker
+// credential store otherwise.
+func SetupClient(repository *remote.Repository, httpClient *http.Client, credential auth.CredentialFunc) error {
 	registry := repository.Reference.Host()
 
 	// If `--tls=false` was provided or accessing the registry via loopback with
@@ -40,9 +44,8 @@ func SetupClient(repository *remote.Repository, transport http.RoundTripper) err
 		repository.PlainHTTP = true
 	}
 
-	httpClient := &http.Client{
-		Transport: retry.NewTransport(transport),
-	}
+	retrying := *httpClient
+	retrying.Transport = retry.NewTransport(httpClient.Transport)
 
 	store, err := credentials.NewStoreFromDocker(credentials.StoreOptions{
 		AllowPlaintextPut:        true,
@@ -52,9 +55,21 @@ func SetupClient(repository *remote.Repository, transport http.RoundTripper) err
 		return err
 	}
 
+	cred := credentials.Credential(store)
+	if credential != nil {
+		storeCred := cred
+		cred = func(ctx context.Context, hostport string) (auth.Credential, error) {
+			c, err := credential(ctx, hostport)
+			if err != nil || c != auth.EmptyCredential {
+				return c, err
+			}
+			return storeCred(ctx, hostport)
+		}
+	}
+
 	client := &auth.Client{
-		Client:     httpClient,
-		Credential: credentials.Credential(store),
+		Client:     &retrying,
+		Credential: cred,
 		Cache:      auth.NewCache(),
 	}
 	client.SetUserAgent("conftest")
@@ -17,21 +17,27 @@
 // Package oci provides functionality for gathering files or directories from OCI (Open Container Initiative) sources.
 // It includes an implementation of the Gatherer interface, OCIGatherer, which allows copying files or directories from an OCI source to a destination path.
 // The Gather method in OCIGatherer takes a source path and a destination path, and returns the metadata of the gathered file or directory and any error encountered.
-// This package also includes a helper function, ociURLParse, for parsing the source URI.
+// This package also includes a helper function, ociReference, for parsing the source URI.
 package oci
 
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"oras.land/oras-go/v2"
 	"oras.land/oras-go/v2/content/file"
 	"oras.land/oras-go/v2/registry"
 	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
 
+	gogather "github.com/enterprise-contract/go-gather"
 	r "github.com/enterprise-contract/go-gather/gather/oci/internal/registry"
 	"github.com/enterprise-contract/go-gather/metadata"
 	"github.com/enterprise-contract/go-gather/metadata/oci"
@@ -43,23 +49,129 @@ var orasCopy = oras.Copy
 
 // OCIGatherer is a struct that implements the Gatherer interface
 // and provides methods for gathering from OCI.
-type OCIGatherer struct{}
+type OCIGatherer struct {
+	// Inventory attaches an inventory of the files of the pulled artifact to the metadata.
+	Inventory bool
+	// Logger receives diagnostic messages, including the registry requests and the layers
+	// pulled. When nil, the logger of the gather options is used.
+	Logger *slog.Logger
+}
 
 // Gather copies a file or directory from the source path to the destination path.
 // It returns the metadata of the gathered file or directory and any error encountered.
 // Portions of this file are derivative from the open-policy-agent/conftest project.
-func (f *OCIGatherer) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
+func (f *OCIGatherer) Gather(ctx context.Context, source, destination string) (_ metadata.Metadata, err error) {
+	defer func() {
+		err = gogather.RedactError(err)
+		gogather.FinishProgress(ctx, err)
+	}()
+	startedAt := time.Now()
+	origSource := source
+
+	opts := gogather.OptionsFromContext(ctx)
+	log := gogather.Logger(ctx, f.Logger)
+	src, repo, err := repository(ctx, source, log)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the destination directory
+	if err := os.MkdirAll(destination, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Create the file store
+	fileStore, err := file.New(destination)
+	if err != nil {
+		return nil, fmt.Errorf("file store: %w", err)
+	}
+	defer fileStore.Close()
+
+	// Copy the artifact to the file store
+	log.Debug("pulling artifact", "reference", repo, "destination", destination)
+	gogather.StartProgress(ctx, -1, -1)
+	copyOpts := oras.DefaultCopyOptions
+	copyOpts.PreCopy = func(ctx context.Context, desc ocispec.Descriptor) error {
+		return gogather.CheckWritten(ctx, desc.Size)
+	}
+	copyOpts.PostCopy = func(ctx context.Context, desc ocispec.Descriptor) error {
+		log.Debug("pulled content", "digest", desc.Digest.String(), "mediaType", desc.MediaType, "size", desc.Size)
+		gogather.CountItems(ctx, 1)
+		return gogather.CountWritten(ctx, desc.Size)
+	}
+	a, err := orasCopy(ctx, src, repo, fileStore, "", copyOpts)
+	if err != nil {
+		return nil, fmt.Errorf("pulling policy: %w", err)
+	}
+	log.Debug("pulled artifact", "reference", repo, "digest", a.Digest.String())
+	if err := opts.Prune(destination); err != nil {
+		return nil, err
+	}
+	if opts.Checksum != "" {
+		treeHash, err := metadata.TreeHash(destination)
+		if err != nil {
+			return nil, err
+		}
+		if err := gogather.VerifyChecksumDigest(ctx, destination, "sha256", treeHash); err != nil {
+			return nil, err
+		}
+	}
+
+	m := &oci.OCIMetadata{Digest: a.Digest.String()}
+	// Sources that cannot be pinned are left unresolved.
+	resolved, _ := m.GetPinnedURL(repo)
+	m.Common = metadata.NewCommon("oci", gogather.RedactURL(origSource), resolved, destination, startedAt)
+	if f.Inventory {
+		if m.Inventory, err = metadata.NewInventory(destination); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Resolve resolves the reference of the artifact at source to its digest, requesting only its
+// manifest descriptor from the registry, without pulling it. The returned metadata has the digest
+// set, so that GetPinnedURL pins source.
+func (f *OCIGatherer) Resolve(ctx context.Context, source string) (_ metadata.Metadata, err error) {
+	defer func() { err = gogather.RedactError(err) }()
+	startedAt := time.Now()
+
+	log := gogather.Logger(ctx, f.Logger)
+	src, repo, err := repository(ctx, source, log)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("resolving artifact", "reference", repo)
+	desc, err := src.Resolve(ctx, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve reference: %w", err)
+	}
+
+	m := &oci.OCIMetadata{Digest: desc.Digest.String()}
+	resolved, _ := m.GetPinnedURL(repo)
+	m.Common = metadata.NewCommon("oci", gogather.RedactURL(source), resolved, "", startedAt)
+	return m, nil
+}
+
+// repository returns the client for the repository of source, set up with the credentials of the
+// gather options, and the reference of the artifact, which defaults to the "latest" tag. The
+// registry is checked against the host policy of the gather options, and the HTTP client is
+// configured for them, see gogather.HTTPClient.
+func repository(ctx context.Context, source string, log *slog.Logger) (*remote.Repository, string, error) {
 	if strings.Contains(source, "localhost") {
 		source = strings.ReplaceAll(source, "localhost", "127.0.0.1")
 	}
 
 	// Parse the source URI
-	repo := ociURLParse(source)
+	repo, err := ociReference(source)
+	if err != nil {
+		return nil, "", err
+	}
 
 	// Get the artifact reference
 	ref, err := registry.ParseReference(repo)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse reference: %w", err)
+		return nil, "", fmt.Errorf("failed to parse reference: %w", err)
 	}
 
 	// If the reference is empty, set it to "latest"
@@ -71,43 +183,85 @@ func (f *OCIGatherer) Gather(ctx context.Context, source, destination string) (m
 	// Create the repository client
 	src, err := remote.NewRepository(repo)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create repository client: %w", err)
+		return nil, "", fmt.Errorf("failed to create repository client: %w", err)
 	}
 
 	// Setup the client for the repository
-	if err := r.SetupClient(src, Transport); err != nil {
-		return nil, fmt.Errorf("failed to setup repository client: %w", err)
+	client, err := gogather.HTTPClient(ctx, &http.Client{Transport: Transport})
+	if err != nil {
+		return nil, "", err
 	}
-
-	// Create the destination directory
-	if err := os.MkdirAll(destination, os.ModePerm); err != nil {
-		return nil, fmt.Errorf("failed to create directory: %w", err)
+	client.Transport = &loggingTransport{next: client.Transport, log: log}
+	if err := r.SetupClient(src, client, credentialFunc(gogather.OptionsFromContext(ctx))); err != nil {
+		return nil, "", fmt.Errorf("failed to setup repository client: %w", err)
 	}
 
-	// Create the file store
-	fileStore, err := file.New(destination)
-	if err != nil {
-		return nil, fmt.Errorf("file store: %w", err)
+	// Check the registry host against the host policy before contacting it
+	scheme, host := "https", ref.Registry
+	if src.PlainHTTP {
+		scheme = "http"
 	}
-	defer fileStore.Close()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if err := gogather.CheckHost(ctx, scheme, host); err != nil {
+		return nil, "", err
+	}
+	return src, repo, nil
+}
 
-	// Copy the artifact to the file store
-	a, err := orasCopy(ctx, src, repo, fileStore, "", oras.DefaultCopyOptions)
-	if err != nil {
-		return nil, fmt.Errorf("pulling policy: %w", err)
+// credentialFunc returns the function looking up registry credentials from the Auth provider of
+// the gather options, or nil if there is none.
+func credentialFunc(opts gogather.GatherOptions) auth.CredentialFunc {
+	if opts.Auth == nil {
+		return nil
+	}
+	return func(ctx context.Context, hostport string) (auth.Credential, error) {
+		creds, err := opts.Credentials(ctx, hostport)
+		if err != nil || creds == nil {
+			return auth.EmptyCredential, err
+		}
+		if creds.Username == "" {
+			return auth.Credential{AccessToken: creds.Password}, nil
+		}
+		return auth.Credential{Username: creds.Username, Password: creds.Password}, nil
 	}
+}
 
-	return &oci.OCIMetadata{Digest: a.Digest.String()}, nil
+// loggingTransport logs the requests sent to the registry. Failed requests are logged as warnings,
+// as the retrying transport of the repository client retries them.
+type loggingTransport struct {
+	next http.RoundTripper
+	log  *slog.Logger
 }
 
-func ociURLParse(source string) string {
-	if strings.Contains(source, "::") {
-		source = strings.Split(source, "::")[1]
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.log.Warn("registry request failed", "method", req.Method, "url", gogather.RedactURL(req.URL.String()), "error", gogather.RedactError(err))
+		return resp, err
 	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		t.log.Warn("registry request failed", "method", req.Method, "url", gogather.RedactURL(req.URL.String()), "status", resp.StatusCode)
+	} else {
+		t.log.Debug("registry request", "method", req.Method, "url", gogather.RedactURL(req.URL.String()), "status", resp.StatusCode)
+	}
+	return resp, nil
+}
 
-	scheme, src, found := strings.Cut(source, "://")
-	if !found {
-		src = scheme
+// ociReference returns the artifact reference of source, i.e. the source without a forced protocol,
+// scheme or query, e.g. "registry.io/org/bundle:v1" for "oci::https://registry.io/org/bundle:v1".
+func ociReference(source string) (string, error) {
+	// Force the OCI protocol so that references like "registry.io/org/bundle" are not classified as HTTP
+	if !strings.HasPrefix(source, "oci::") {
+		source = "oci::" + source
+	}
+	src, err := gogather.ParseSource(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse source: %w", err)
+	}
+	if _, ref, ok := strings.Cut(src.BaseURL, "://"); ok {
+		return ref, nil
 	}
-	return src
+	return src.BaseURL, nil
 }
@@ -18,7 +18,10 @@ package oci
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
@@ -26,7 +29,10 @@ import (
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
 	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/registry/remote/auth"
 
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
 	"github.com/enterprise-contract/go-gather/metadata/oci"
 )
 
@@ -59,7 +65,8 @@ func TestGetRegistryURL(t *testing.T) {
 	}
 }
 
-func TestOCIURLParse(t *testing.T) {
+// TestOCIReference tests determining the artifact reference of sources
+func TestOCIReference(t *testing.T) {
 	testCases := []struct {
 		source   string
 		expected string
@@ -68,12 +75,14 @@ func TestOCIURLParse(t *testing.T) {
 		{source: "https://docker.io/library/alpine", expected: "docker.io/library/alpine"},
 		{source: "alpine", expected: "alpine"},
 		{source: "https://example.com/image:tag", expected: "example.com/image:tag"},
+		{source: "oci::quay.io/org/bundle@sha256:abc", expected: "quay.io/org/bundle@sha256:abc"},
+		{source: "oci::https://quay.io/org/bundle:v1?token=a::b", expected: "quay.io/org/bundle:v1"},
 	}
 
 	for _, tc := range testCases {
-		actual := ociURLParse(tc.source)
-		if actual != tc.expected {
-			t.Errorf("Expected ociURLParse(%s) to return %s, but got %s", tc.source, tc.expected, actual)
+		actual, err := ociReference(tc.source)
+		if err != nil || actual != tc.expected {
+			t.Errorf("Expected ociReference(%s) to return %s, but got %s, %v", tc.source, tc.expected, actual, err)
 		}
 	}
 }
@@ -208,3 +217,107 @@ func TestOCIGatherer_Gather_ErorrCreatingNewRepository(t *testing.T) {
 	}
 
 }
+
+// TestCredentialFunc tests looking up registry credentials from the gather options
+func TestCredentialFunc(t *testing.T) {
+	assert.Nil(t, credentialFunc(gogather.GatherOptions{}))
+
+	cred := credentialFunc(gogather.GatherOptions{Auth: gogather.AuthProviderFunc(func(_ context.Context, host string) (*gogather.Credentials, error) {
+		switch host {
+		case "registry.io":
+			return &gogather.Credentials{Username: "user", Password: "secret"}, nil
+		case "token.io":
+			return &gogather.Credentials{Password: "token"}, nil
+		}
+		return nil, nil
+	})})
+
+	c, err := cred(context.Background(), "registry.io")
+	assert.NoError(t, err)
+	assert.Equal(t, auth.Credential{Username: "user", Password: "secret"}, c)
+
+	c, err = cred(context.Background(), "token.io")
+	assert.NoError(t, err)
+	assert.Equal(t, auth.Credential{AccessToken: "token"}, c)
+
+	c, err = cred(context.Background(), "other.io")
+	assert.NoError(t, err)
+	assert.Equal(t, auth.EmptyCredential, c)
+}
+
+// TestOCIGatherer_Gather_MaxSize tests that the size of the pulled content is limited
+func TestOCIGatherer_Gather_MaxSize(t *testing.T) {
+	orasCopy = func(ctx context.Context, _ oras.ReadOnlyTarget, _ string, _ oras.Target, _ string, opts oras.CopyOptions) (ocispec.Descriptor, error) {
+		if err := opts.PostCopy(ctx, ocispec.Descriptor{Size: 1024}); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		return ocispec.Descriptor{Digest: "sha256:abc"}, nil
+	}
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{MaxSize: 100})
+	_, err := (&OCIGatherer{}).Gather(ctx, "example.com/org/repo", t.TempDir())
+	var sizeErr *gogather.MaxSizeError
+	assert.ErrorAs(t, err, &sizeErr)
+}
+
+// TestOCIGatherer_Gather_Checksum tests verifying the pulled content against the checksum option
+func TestOCIGatherer_Gather_Checksum(t *testing.T) {
+	orasCopy = func(_ context.Context, _ oras.ReadOnlyTarget, _ string, _ oras.Target, _ string, _ oras.CopyOptions) (ocispec.Descriptor, error) {
+		return ocispec.Descriptor{Digest: "sha256:abc"}, nil
+	}
+
+	treeHash, err := metadata.TreeHash(t.TempDir())
+	assert.NoError(t, err)
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Checksum: "sha256:" + treeHash})
+	_, err = (&OCIGatherer{}).Gather(ctx, "example.com/org/repo", t.TempDir())
+	assert.NoError(t, err)
+	assert.True(t, gogather.ChecksumVerified(ctx))
+
+	ctx = gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Checksum: "sha256:" + strings.Repeat("0", 64)})
+	_, err = (&OCIGatherer{}).Gather(ctx, "example.com/org/repo", t.TempDir())
+	var mismatch *gogather.ChecksumMismatchError
+	assert.ErrorAs(t, err, &mismatch)
+}
+
+// TestOCIGatherer_Resolve tests resolving a tag to its digest with a request for the manifest
+func TestOCIGatherer_Resolve(t *testing.T) {
+	digest := "sha256:6c3c624b58dbbcd3c0dd82b4c53f04194d1247c6eebdaab7c610cf7d66709b3b"
+	var methods []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		if r.URL.Path != "/v2/org/repo/manifests/v1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", ocispec.MediaTypeImageManifest)
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Content-Length", "2")
+	}))
+	defer mockServer.Close()
+
+	host := strings.TrimPrefix(mockServer.URL, "http://")
+	m, err := (&OCIGatherer{}).Resolve(context.Background(), "oci::"+host+"/org/repo:v1")
+	assert.NoError(t, err)
+	assert.Equal(t, digest, m.(*oci.OCIMetadata).Digest)
+	assert.Equal(t, "oci::"+host+"/org/repo:v1@"+digest, m.Get()["resolvedURI"])
+	assert.Equal(t, []string{http.MethodHead}, methods)
+
+	_, err = (&OCIGatherer{}).Resolve(context.Background(), "oci::"+host+"/org/repo:missing")
+	assert.ErrorContains(t, err, "failed to resolve reference")
+}
+
+// TestOCIGatherer_Resolve_HostPolicy tests checking the redirects of registries against the host
+// policy
+func TestOCIGatherer_Resolve_HostPolicy(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254"+r.URL.Path, http.StatusFound)
+	}))
+	defer mockServer.Close()
+
+	host := strings.TrimPrefix(mockServer.URL, "http://")
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{HostPolicy: &gogather.HostPolicy{DeniedHosts: []string{"169.254.169.254"}}})
+	var denied *gogather.HostDeniedError
+	_, err := (&OCIGatherer{}).Resolve(ctx, "oci::"+host+"/org/repo:v1")
+	assert.True(t, errors.As(err, &denied), "expected the redirect to be denied, got %v", err)
+}
@@ -0,0 +1,168 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"log/slog"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// Option configures a gather performed by Gather. Options override the gather options carried by
+// the context passed to Gather, see gogather.ContextWithOptions.
+type Option func(*gogather.GatherOptions)
+
+// WithTimeout bounds the duration of the gather.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *gogather.GatherOptions) {
+		o.Timeout = timeout
+	}
+}
+
+// WithMaxSize limits the number of bytes the gather may write to the destination.
+func WithMaxSize(size int64) Option {
+	return func(o *gogather.GatherOptions) {
+		o.MaxSize = size
+	}
+}
+
+// WithProgress sets the receiver of the progress reports of the gather. Use gogather.ProgressFunc
+// to receive only the number of bytes written so far.
+func WithProgress(progress gogather.Progress) Option {
+	return func(o *gogather.GatherOptions) {
+		o.Progress = progress
+	}
+}
+
+// WithLogger sets the logger receiving diagnostic messages.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *gogather.GatherOptions) {
+		o.Logger = logger
+	}
+}
+
+// WithAuth sets the provider of the credentials for the hosts contacted by the gather.
+func WithAuth(auth gogather.AuthProvider) Option {
+	return func(o *gogather.GatherOptions) {
+		o.Auth = auth
+	}
+}
+
+// WithInclude adds glob patterns of the paths, relative to the destination, to keep.
+func WithInclude(patterns ...string) Option {
+	return func(o *gogather.GatherOptions) {
+		o.Include = append(o.Include, patterns...)
+	}
+}
+
+// WithExclude adds glob patterns of the paths, relative to the destination, to drop.
+func WithExclude(patterns ...string) Option {
+	return func(o *gogather.GatherOptions) {
+		o.Exclude = append(o.Exclude, patterns...)
+	}
+}
+
+// WithDryRun validates the source without gathering it.
+func WithDryRun() Option {
+	return func(o *gogather.GatherOptions) {
+		o.DryRun = true
+	}
+}
+
+// WithCache serves pinned sources from the content cache in dir, and stores gathered content in
+// it, evicting the least recently used entries once it grows beyond maxSize bytes. A maxSize of
+// zero means no limit.
+func WithCache(dir string, maxSize int64) Option {
+	return func(o *gogather.GatherOptions) {
+		o.CacheDir = dir
+		o.CacheMaxSize = maxSize
+	}
+}
+
+// WithNoCache bypasses lookups in the content cache, so that the source is gathered again.
+func WithNoCache() Option {
+	return func(o *gogather.GatherOptions) {
+		o.NoCache = true
+	}
+}
+
+// WithChecksum sets the expected checksum of the gathered content, e.g. "sha256:2cf24d...", see
+// gogather.ParseChecksum. Files are verified by their content and directories by their sha256
+// tree hash. The checksum parameter of the source takes precedence.
+func WithChecksum(checksum string) Option {
+	return func(o *gogather.GatherOptions) {
+		o.Checksum = checksum
+	}
+}
+
+// WithHostPolicy restricts the schemes and hosts the gatherers may contact, see
+// gogather.HostPolicy. It replaces any policy carried by the context.
+func WithHostPolicy(p *gogather.HostPolicy) Option {
+	return func(o *gogather.GatherOptions) {
+		o.HostPolicy = p
+	}
+}
+
+// WithFS writes the gathered content to fsys instead of the local disk, with the destination
+// being a slash separated path within it, see FSGatherer.
+func WithFS(fsys gogather.WriteFS) Option {
+	return func(o *gogather.GatherOptions) {
+		o.FS = fsys
+	}
+}
+
+// WithMetrics sets the receiver of the measurements of the gather, e.g. its duration and the bytes
+// it wrote, see gogather.Metrics.
+func WithMetrics(m gogather.Metrics) Option {
+	return func(o *gogather.GatherOptions) {
+		o.Metrics = m
+	}
+}
+
+// WithTempDir sets the directory gatherers create their scratch directories in, e.g. a dedicated
+// scratch volume instead of the default directory for temporary files.
+func WithTempDir(dir string) Option {
+	return func(o *gogather.GatherOptions) {
+		o.TempDir = dir
+	}
+}
+
+// WithKeepPartial keeps whatever a failed gather wrote to the destination instead of rolling the
+// destination back, e.g. to inspect it.
+func WithKeepPartial() Option {
+	return func(o *gogather.GatherOptions) {
+		o.KeepPartial = true
+	}
+}
+
+// WithHook registers hook to be called at stage of the gather, see gogather.HookStage. Hooks
+// registered for the same stage are called in order.
+func WithHook(stage gogather.HookStage, hook gogather.Hook) Option {
+	return func(o *gogather.GatherOptions) {
+		o.Hooks = o.Hooks.With(stage, hook)
+	}
+}
+
+// WithDeterministic normalizes the times and permissions of the gathered content and removes its
+// volatile files, so that gathers of the same pinned source produce identical trees, see
+// gogather.Normalize.
+func WithDeterministic() Option {
+	return func(o *gogather.GatherOptions) {
+		o.Deterministic = true
+	}
+}
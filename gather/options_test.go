@@ -0,0 +1,265 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+func writeSourceDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range map[string]string{"main.rego": "package main", "README.md": "# Policy"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// recordingMetrics records the measurements it receives.
+type recordingMetrics struct {
+	gathers []string
+	lookups []bool
+}
+
+func (m *recordingMetrics) ObserveGather(protocol gogather.URIType, err error, bytes int64, _ time.Duration) {
+	outcome := "<nil>"
+	if err != nil {
+		outcome = "error"
+	}
+	m.gathers = append(m.gathers, fmt.Sprintf("%s:%s:%d", protocol, outcome, bytes))
+}
+
+func (m *recordingMetrics) ObserveCacheLookup(hit bool) {
+	m.lookups = append(m.lookups, hit)
+}
+
+// recordingProgress records the progress reported to it.
+type recordingProgress struct {
+	mu     sync.Mutex
+	starts int
+	bytes  int64
+	items  int
+	dones  []error
+}
+
+func (p *recordingProgress) Start(int64, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.starts++
+}
+
+func (p *recordingProgress) Update(bytes int64, items int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bytes, p.items = bytes, items
+}
+
+func (p *recordingProgress) Done(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dones = append(p.dones, err)
+}
+
+// TestGather_Options tests that the options are honored by the gatherers
+func TestGather_Options(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("IncludeExclude", func(t *testing.T) {
+		destination := filepath.Join(t.TempDir(), "out")
+		if _, err := Gather(ctx, writeSourceDir(t), destination, WithInclude("*.rego", "*.md"), WithExclude("README.md")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(destination, "main.rego")); err != nil {
+			t.Errorf("expected main.rego to be gathered: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(destination, "README.md")); !os.IsNotExist(err) {
+			t.Errorf("expected README.md to be excluded: %v", err)
+		}
+	})
+
+	t.Run("MaxSizeAndProgress", func(t *testing.T) {
+		var written int64
+		destination := filepath.Join(t.TempDir(), "out")
+		if _, err := Gather(ctx, writeSourceDir(t), destination, WithProgress(gogather.ProgressFunc(func(n int64) { written = n }))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if written != int64(len("package main")+len("# Policy")) {
+			t.Errorf("unexpected number of bytes written: %d", written)
+		}
+
+		_, err := Gather(ctx, writeSourceDir(t), filepath.Join(t.TempDir(), "out"), WithMaxSize(10))
+		var sizeErr *gogather.MaxSizeError
+		if !errors.As(err, &sizeErr) {
+			t.Errorf("expected a MaxSizeError, got: %v", err)
+		}
+		if !errors.Is(err, gogather.ErrTooLarge) {
+			t.Errorf("expected ErrTooLarge, got: %v", err)
+		}
+	})
+
+	t.Run("ProgressReports", func(t *testing.T) {
+		p := &recordingProgress{}
+		if _, err := Gather(ctx, writeSourceDir(t), filepath.Join(t.TempDir(), "out"), WithProgress(p)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p.starts != 1 || p.items != 2 || p.bytes != int64(len("package main")+len("# Policy")) || len(p.dones) != 1 || p.dones[0] != nil {
+			t.Errorf("unexpected progress: %+v", p)
+		}
+
+		p = &recordingProgress{}
+		if _, err := Gather(ctx, filepath.Join(t.TempDir(), "missing"), filepath.Join(t.TempDir(), "out"), WithProgress(p)); err == nil {
+			t.Fatal("expected an error")
+		}
+		if len(p.dones) != 1 || p.dones[0] == nil {
+			t.Errorf("expected the failure to be reported, got: %v", p.dones)
+		}
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		destination := filepath.Join(t.TempDir(), "out")
+		m, err := Gather(ctx, writeSourceDir(t), destination, WithDryRun())
+		if err != nil || m != nil {
+			t.Fatalf("expected no metadata and no error, got: %v, %v", m, err)
+		}
+		if _, err := os.Stat(destination); !os.IsNotExist(err) {
+			t.Errorf("expected nothing to be gathered: %v", err)
+		}
+	})
+
+	t.Run("ContextDefaults", func(t *testing.T) {
+		ctx := gogather.ContextWithOptions(ctx, gogather.GatherOptions{DryRun: true, Exclude: []string{"*.md"}})
+		if m, err := Gather(ctx, writeSourceDir(t), filepath.Join(t.TempDir(), "out")); err != nil || m != nil {
+			t.Errorf("expected the context options to apply, got: %v, %v", m, err)
+		}
+	})
+
+	t.Run("HostPolicy", func(t *testing.T) {
+		var denied *gogather.HostDeniedError
+		policy := &gogather.HostPolicy{AllowedSchemes: []string{"https"}}
+		if _, err := Gather(ctx, writeSourceDir(t), filepath.Join(t.TempDir(), "out"), WithHostPolicy(policy)); !errors.As(err, &denied) {
+			t.Errorf("expected a HostDeniedError, got: %v", err)
+		}
+
+		ctx := gogather.ContextWithOptions(ctx, gogather.GatherOptions{HostPolicy: policy})
+		if _, err := Gather(ctx, writeSourceDir(t), filepath.Join(t.TempDir(), "out")); !errors.As(err, &denied) {
+			t.Errorf("expected the context policy to apply, got: %v", err)
+		}
+		if _, err := Gather(ctx, writeSourceDir(t), filepath.Join(t.TempDir(), "out"), WithHostPolicy(nil)); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		metrics := &recordingMetrics{}
+		source := filepath.Join(writeSourceDir(t), "main.rego") + "?checksum=sha256:512843855fcc92a51c810b1b58e0731c01eac9a6a23c157bfa02aad71edffbe7"
+		cache := t.TempDir()
+		for i := 0; i < 2; i++ {
+			if _, err := Gather(ctx, source, filepath.Join(t.TempDir(), "main.rego"), WithCache(cache, 0), WithMetrics(metrics)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if _, err := Gather(ctx, "/does/not/exist", filepath.Join(t.TempDir(), "out"), WithMetrics(metrics)); err == nil {
+			t.Fatal("expected an error")
+		}
+
+		want := []string{"FileURI:<nil>:12", "FileURI:<nil>:12", "FileURI:error:0"}
+		if strings.Join(metrics.gathers, ",") != strings.Join(want, ",") {
+			t.Errorf("unexpected gathers: %v", metrics.gathers)
+		}
+		if len(metrics.lookups) != 2 || metrics.lookups[0] || !metrics.lookups[1] {
+			t.Errorf("expected a cache miss and a hit, got: %v", metrics.lookups)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		_, err := Gather(ctx, writeSourceDir(t), filepath.Join(t.TempDir(), "out"), WithTimeout(1))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the deadline to be exceeded, got: %v", err)
+		}
+	})
+}
+
+// TestWithTempDir tests staging content in the configured temporary directory and removing it
+func TestWithTempDir(t *testing.T) {
+	src := writeSourceDir(t)
+	if err := os.Mkdir(filepath.Join(src, "policy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "policy", "main.rego"), []byte("package main"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tmp := filepath.Join(t.TempDir(), "scratch")
+	destination := filepath.Join(t.TempDir(), "out")
+	if _, err := Gather(context.Background(), "file::"+src+"//policy", destination, WithTempDir(tmp)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(destination, "main.rego")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if entries, err := os.ReadDir(tmp); err != nil || len(entries) != 0 {
+		t.Errorf("expected the staging directory to be created in and removed from %s: %v, %v", tmp, entries, err)
+	}
+}
+
+// TestWithDeterministic tests normalizing the gathered content on the local disk and in an FS
+func TestWithDeterministic(t *testing.T) {
+	t.Setenv(gogather.SourceDateEpochEnv, "1700000000")
+	src := writeSourceDir(t)
+	if err := os.MkdirAll(filepath.Join(src, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, ".git", "HEAD"), []byte("ref: refs/heads/main"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	destination := filepath.Join(t.TempDir(), "out")
+	m, err := Gather(context.Background(), src, destination, WithDeterministic())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ts, _ := m.Get()["timestamp"].(time.Time); ts.Unix() != 1700000000 {
+		t.Errorf("expected the normalized timestamp, got %v", m.Get()["timestamp"])
+	}
+
+	fsys := t.TempDir()
+	if _, err := Gather(context.Background(), src, "out", WithDeterministic(), WithFS(gogather.OSFS{Dir: fsys})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, dir := range []string{destination, filepath.Join(fsys, "out")} {
+		info, err := os.Stat(filepath.Join(dir, "main.rego"))
+		if err != nil || info.ModTime().Unix() != 1700000000 {
+			t.Errorf("expected the time of %s to be normalized: %v, %v", dir, info, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); !os.IsNotExist(err) {
+			t.Errorf("expected the version control metadata to be removed from %s: %v", dir, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter limits the rate of the gathers of the RateLimitingGatherers sharing it, either for
+// all of them together or for each host separately. It is safe for concurrent use.
+type RateLimiter struct {
+	// RequestsPerSecond is the maximum number of gathers started per second. Zero or less
+	// disables the limit.
+	RequestsPerSecond float64
+	// BytesPerSecond is the maximum number of bytes written to the destinations per second. Zero
+	// or less disables the limit.
+	BytesPerSecond int
+	// PerHost applies the limits to the gathers of each host separately, see gogather.SourceHost,
+	// rather than to all gathers together.
+	PerHost bool
+
+	mu       sync.Mutex
+	limiters map[string]*hostLimiters
+}
+
+// hostLimiters holds the limiters of a host, or of all hosts.
+type hostLimiters struct {
+	requests *rate.Limiter
+	bytes    *rate.Limiter
+}
+
+// NewRateLimiter returns a RateLimiter limiting all gathers together to requestsPerSecond gathers
+// and bytesPerSecond bytes per second.
+func NewRateLimiter(requestsPerSecond float64, bytesPerSecond int) *RateLimiter {
+	return &RateLimiter{RequestsPerSecond: requestsPerSecond, BytesPerSecond: bytesPerSecond}
+}
+
+// limitersFor returns the limiters of the gathers of source.
+func (l *RateLimiter) limitersFor(source string) *hostLimiters {
+	var host string
+	if l.PerHost {
+		host = gogather.SourceHost(source)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if h, ok := l.limiters[host]; ok {
+		return h
+	}
+	h := &hostLimiters{}
+	if l.RequestsPerSecond > 0 {
+		h.requests = rate.NewLimiter(rate.Limit(l.RequestsPerSecond), 1)
+	}
+	if l.BytesPerSecond > 0 {
+		h.bytes = rate.NewLimiter(rate.Limit(l.BytesPerSecond), l.BytesPerSecond)
+	}
+	if l.limiters == nil {
+		l.limiters = map[string]*hostLimiters{}
+	}
+	l.limiters[host] = h
+	return h
+}
+
+// waitBytes waits until the limiter allows n bytes to be written. Writes larger than the burst of the
+// limiter, which is one second's worth of data, wait for one burst at a time.
+func waitBytes(ctx context.Context, limiter *rate.Limiter, n int64) error {
+	burst := int64(limiter.Burst())
+	for n > 0 {
+		chunk := min(n, burst)
+		if err := limiter.WaitN(ctx, int(chunk)); err != nil {
+			return fmt.Errorf("failed to wait for the rate limit: %w", err)
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+// RateLimitingGatherer is a Gatherer that waits for the limits of its RateLimiter before starting a
+// gather with the wrapped Gatherer, and while the gather writes to the destination. Gatherers that
+// cannot observe the data while writing it, e.g. the git gatherer, are throttled once they are
+// done, delaying the gathers that share the limiter.
+type RateLimitingGatherer struct {
+	Gatherer Gatherer
+	Limiter  *RateLimiter
+}
+
+// NewRateLimitingGatherer returns a RateLimitingGatherer wrapping g with the limits of l. Wrap
+// several gatherers with the same RateLimiter to share the limits between them.
+func NewRateLimitingGatherer(g Gatherer, l *RateLimiter) *RateLimitingGatherer {
+	return &RateLimitingGatherer{Gatherer: g, Limiter: l}
+}
+
+// Gather gathers the source using the wrapped Gatherer within the rate limits. It fails if ctx is
+// done while it waits for the limits.
+func (r *RateLimitingGatherer) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
+	limiters := r.Limiter.limitersFor(source)
+	if limiters.requests != nil {
+		if err := limiters.requests.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("failed to wait for the rate limit: %w", err)
+		}
+	}
+	if limiters.bytes != nil {
+		ctx = gogather.ContextWithThrottle(ctx, func(ctx context.Context, n int64) error {
+			return waitBytes(ctx, limiters.bytes, n)
+		})
+	}
+	return r.Gatherer.Gather(ctx, source, destination)
+}
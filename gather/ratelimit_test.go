@@ -0,0 +1,94 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+	ociMetadata "github.com/enterprise-contract/go-gather/metadata/oci"
+)
+
+// writingGatherer reports size bytes as written.
+type writingGatherer struct {
+	size int64
+}
+
+func (g *writingGatherer) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
+	if err := gogather.CountWritten(ctx, g.size); err != nil {
+		return nil, err
+	}
+	return &ociMetadata.OCIMetadata{Common: metadata.Common{SourceURI: source, Destination: destination}}, nil
+}
+
+// TestRateLimitingGatherer_Requests tests limiting the rate gathers start at, globally and per host
+func TestRateLimitingGatherer_Requests(t *testing.T) {
+	ctx := context.Background()
+	l := NewRateLimiter(20, 0)
+	g := NewRateLimitingGatherer(&writingGatherer{}, l)
+
+	start := time.Now()
+	for _, source := range []string{"https://a.example.com/x", "https://b.example.com/x", "https://a.example.com/y"} {
+		if _, err := g.Gather(ctx, source, "out"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("expected three gathers to take at least 100ms, took %s", elapsed)
+	}
+
+	l = &RateLimiter{RequestsPerSecond: 1, PerHost: true}
+	g = NewRateLimitingGatherer(&writingGatherer{}, l)
+	start = time.Now()
+	for _, source := range []string{"https://a.example.com/x", "git::b.example.com/x", "oci::c.example.com/x"} {
+		if _, err := g.Gather(ctx, source, "out"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected gathers of different hosts not to wait for each other, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := g.Gather(ctx, "https://a.example.com/y", "out"); err == nil {
+		t.Error("expected an error when the context is done before the limit allows the gather")
+	}
+}
+
+// TestRateLimitingGatherer_Bytes tests limiting the rate gathers write at
+func TestRateLimitingGatherer_Bytes(t *testing.T) {
+	l := NewRateLimiter(0, 1000)
+
+	start := time.Now()
+	if _, err := NewRateLimitingGatherer(&writingGatherer{size: 1200}, l).Gather(context.Background(), "a", "out"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("expected writing 1200 bytes to take at least 200ms, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := NewRateLimitingGatherer(&writingGatherer{size: 1000}, l).Gather(ctx, "a", "out")
+	if err == nil {
+		t.Errorf("expected the limit to be shared and to fail with the context, got: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// Resolver is implemented by Gatherers that can resolve a source to the identity of its content
+// without gathering it.
+type Resolver interface {
+	// Resolve returns the metadata of source with the fields identifying its content set, e.g. a
+	// commit or digest, so that GetPinnedURL pins source, without writing anything.
+	Resolve(ctx context.Context, source string) (metadata.Metadata, error)
+}
+
+// Resolve classifies source like Gather and resolves it to the identity of its content without
+// downloading it or writing anything: git refs are resolved to commits by listing the references
+// of the remote, OCI references to digests by requesting their manifest descriptor, HTTP sources
+// with a HEAD request and local files by hashing them. The ResolvedURI of the returned metadata
+// holds source pinned to that identity, e.g. for generating lockfiles; it is left empty when the
+// source cannot be pinned, and holds the URL the HTTP request was answered for when the server
+// reports no digest and source has no sha256 checksum.
+func Resolve(ctx context.Context, source string, opts ...Option) (metadata.Metadata, error) {
+	startedAt := time.Now()
+	o, src, srcProtocol, gatherer, err := prepare(ctx, source, opts)
+	if err != nil {
+		return nil, err
+	}
+	resolver, ok := gatherer.(Resolver)
+	if !ok {
+		return nil, fmt.Errorf("the %s gatherer cannot resolve sources", srcProtocol)
+	}
+	o.Log().Debug("resolving source", "source", gogather.RedactURL(source), "protocol", srcProtocol.String())
+
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+	ctx = gogather.ContextWithOptions(ctx, o)
+	if err := gogather.RunHooks(ctx, hookEvent(gogather.BeforeResolve, source, "", nil)); err != nil {
+		return nil, err
+	}
+	m, err := resolver.Resolve(ctx, baseSource(srcProtocol, src))
+	if err != nil {
+		return nil, gogather.RedactError(err)
+	}
+
+	fields := m.Get()
+	gathererName, _ := fields["gatherer"].(string)
+	resolved, _ := fields["resolvedURI"].(string)
+	if r, err := gogather.ParseSource(resolved); err == nil && resolved != "" {
+		r.Subdir, r.Archive = src.Subdir, src.Archive
+		if r.Checksum == "" {
+			r.Checksum = src.Checksum
+		}
+		resolved = r.String()
+	}
+	return setCommon(m, metadata.NewCommon(gathererName, gogather.RedactURL(source), resolved, "", startedAt)), nil
+}
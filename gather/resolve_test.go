@@ -0,0 +1,68 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// TestResolve tests resolving sources to pinned URLs without gathering them
+func TestResolve(t *testing.T) {
+	dir := writeSourceDir(t)
+	m, err := Resolve(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	treeHash, err := metadata.TreeHash(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Get()["tree_hash"]; got != treeHash {
+		t.Errorf("unexpected tree hash: %v", got)
+	}
+
+	digest := "dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("unexpected %s request", r.Method)
+		}
+		w.Header().Set("Digest", "SHA-256=3/1gIbsr1bCvZ2KQgJ7DpTGR3YHH9wpLKGiKNiGCmG8=")
+	}))
+	defer server.Close()
+
+	m, err = Resolve(context.Background(), server.URL+"/bundle.zip//policy?archive=zip")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := fmt.Sprintf("http::%s/bundle.zip//policy?archive=zip&checksum=sha256%%3A%s", server.URL, digest)
+	if got := m.Get()["resolvedURI"]; got != want {
+		t.Errorf("unexpected resolved URI: got %v, want %v", got, want)
+	}
+	if got := m.Get()["sourceURI"]; got != server.URL+"/bundle.zip//policy?archive=zip" {
+		t.Errorf("unexpected source URI: %v", got)
+	}
+
+	if _, err := Resolve(context.Background(), "ftp://example.com/file"); err == nil {
+		t.Error("expected an error for an unsupported source")
+	}
+}
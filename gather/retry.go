@@ -0,0 +1,115 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// DefaultRetryDelay is the delay before the first retry when the RetryPolicy sets none.
+const DefaultRetryDelay = time.Second
+
+// RetryPolicy configures how a RetryingGatherer retries failed gathers.
+type RetryPolicy struct {
+	// Retries is the number of times a failed gather is retried.
+	Retries int
+	// Delay is the delay before the first retry. Defaults to DefaultRetryDelay.
+	Delay time.Duration
+	// Multiplier is the factor the delay grows by after each retry. Values below 1 default to 2.
+	Multiplier float64
+	// MaxDelay bounds the delay between retries. Zero means no bound.
+	MaxDelay time.Duration
+	// Retryable reports whether a gather that failed with err may succeed when retried. Defaults
+	// to IsRetryable.
+	Retryable func(err error) bool
+}
+
+// RetryingGatherer is a Gatherer that retries the gathers of the wrapped Gatherer that fail with
+// errors its RetryPolicy classifies as retryable, waiting with an exponential backoff between the
+// attempts.
+type RetryingGatherer struct {
+	Gatherer Gatherer
+	Policy   RetryPolicy
+}
+
+// WithRetry returns a RetryingGatherer wrapping g with the retry policy.
+func WithRetry(g Gatherer, policy RetryPolicy) *RetryingGatherer {
+	return &RetryingGatherer{Gatherer: g, Policy: policy}
+}
+
+// Gather gathers the source using the wrapped Gatherer, retrying failed attempts. It returns the
+// error of the last attempt, or the error of the context if it is done while waiting to retry.
+func (r *RetryingGatherer) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
+	retryable := r.Policy.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	delay := r.Policy.Delay
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	multiplier := r.Policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	for attempt := 0; ; attempt++ {
+		m, err := r.Gatherer.Gather(ctx, source, destination)
+		if err == nil || attempt >= r.Policy.Retries || ctx.Err() != nil || !retryable(err) {
+			return m, err
+		}
+		if r.Policy.MaxDelay > 0 && delay > r.Policy.MaxDelay {
+			delay = r.Policy.MaxDelay
+		}
+		gogather.Logger(ctx, nil).Info("retrying gather", "source", gogather.RedactURL(source), "attempt", attempt+1, "delay", delay, "error", gogather.RedactError(err))
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = time.Duration(float64(delay) * multiplier)
+	}
+}
+
+// IsRetryable reports whether a gather that failed with err may succeed when retried. Errors
+// providing a Retryable() bool method, e.g. the *gogather.StatusError of the HTTP based gatherers,
+// decide for themselves. Otherwise network errors, connections that were reset, refused or closed
+// early are retryable, while cancellations and all other errors, e.g. checksum mismatches or denied
+// hosts, are not.
+func IsRetryable(err error) bool {
+	var r interface{ Retryable() bool }
+	if errors.As(err, &r) {
+		return r.Retryable()
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
@@ -0,0 +1,123 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+	ociMetadata "github.com/enterprise-contract/go-gather/metadata/oci"
+)
+
+// failingGatherer fails with the errors in order, and succeeds once they are exhausted.
+type failingGatherer struct {
+	errs  []error
+	calls int
+}
+
+func (g *failingGatherer) Gather(_ context.Context, source, destination string) (metadata.Metadata, error) {
+	g.calls++
+	if len(g.errs) > 0 {
+		err := g.errs[0]
+		g.errs = g.errs[1:]
+		return nil, err
+	}
+	return &ociMetadata.OCIMetadata{Common: metadata.Common{SourceURI: source, Destination: destination}}, nil
+}
+
+// TestRetryingGatherer tests retrying gathers that fail with retryable errors
+func TestRetryingGatherer(t *testing.T) {
+	tests := []struct {
+		name    string
+		errs    []error
+		retries int
+		calls   int
+		wantErr bool
+	}{
+		{name: "success", calls: 1},
+		{name: "transient", errs: []error{io.ErrUnexpectedEOF, &gogather.StatusError{StatusCode: 503}}, retries: 3, calls: 3},
+		{name: "exhausted", errs: []error{syscall.ECONNRESET, syscall.ECONNRESET}, retries: 1, calls: 2, wantErr: true},
+		{name: "permanent", errs: []error{&gogather.StatusError{StatusCode: 404}}, retries: 3, calls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &failingGatherer{errs: tt.errs}
+			_, err := WithRetry(g, RetryPolicy{Retries: tt.retries, Delay: time.Millisecond}).Gather(context.Background(), "a", "b")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if g.calls != tt.calls {
+				t.Errorf("expected %d attempts, got %d", tt.calls, g.calls)
+			}
+		})
+	}
+}
+
+// TestRetryingGatherer_Retryable tests classifying errors with the retry policy
+func TestRetryingGatherer_Retryable(t *testing.T) {
+	permanent := errors.New("permanent")
+	g := &failingGatherer{errs: []error{errors.New("flaky"), permanent}}
+	_, err := WithRetry(g, RetryPolicy{
+		Retries:   3,
+		Delay:     time.Millisecond,
+		Retryable: func(err error) bool { return err != permanent },
+	}).Gather(context.Background(), "a", "b")
+	if err != permanent || g.calls != 2 {
+		t.Errorf("unexpected result after %d attempts: %v", g.calls, err)
+	}
+}
+
+// TestRetryingGatherer_Canceled tests that retries stop when the context is done
+func TestRetryingGatherer_Canceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	g := &failingGatherer{errs: []error{io.ErrUnexpectedEOF, io.ErrUnexpectedEOF}}
+	_, err := WithRetry(g, RetryPolicy{Retries: 1, Delay: time.Hour}).Gather(ctx, "a", "b")
+	if !errors.Is(err, context.DeadlineExceeded) || g.calls != 1 {
+		t.Errorf("unexpected result after %d attempts: %v", g.calls, err)
+	}
+}
+
+// TestIsRetryable tests the default classification of errors
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: fmt.Errorf("error downloading file: %w", syscall.ECONNREFUSED), want: true},
+		{err: gogather.RedactError(fmt.Errorf("failed: %w", &gogather.StatusError{StatusCode: 429})), want: true},
+		{err: &gogather.StatusError{StatusCode: 500}, want: true},
+		{err: &gogather.StatusError{StatusCode: 403}, want: false},
+		{err: context.Canceled, want: false},
+		{err: &gogather.ChecksumMismatchError{}, want: false},
+		{err: errors.New("invalid source"), want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// RollbackGatherer is a Gatherer that rolls back the destination when the wrapped Gatherer fails
+// or is canceled midway, so that callers never observe partially written content. Destinations
+// that did not exist are removed. Existing destinations are gathered to a staging directory next
+// to them, created like the destination, i.e. as an empty directory or as an empty file with the
+// same mode, and the staged content is renamed into place once the gather succeeds: existing
+// directories are updated in place, and files and directories are replaced. Failed gathers leave
+// existing destinations untouched. As gatherers only see the staged destination, removing the
+// files of an existing destination, e.g. rsync with Delete, only applies to the staged content.
+// Symbolic links to destinations are followed. Destinations that are not local paths are left as
+// is.
+type RollbackGatherer struct {
+	Gatherer Gatherer
+}
+
+// NewRollbackGatherer returns a RollbackGatherer wrapping g.
+func NewRollbackGatherer(g Gatherer) *RollbackGatherer {
+	return &RollbackGatherer{Gatherer: g}
+}
+
+// Gather gathers the source using the wrapped Gatherer and rolls the destination back if it fails.
+func (r *RollbackGatherer) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
+	path, ok := localDestination(destination)
+	if !ok {
+		return r.Gatherer.Gather(ctx, source, destination)
+	}
+	log := gogather.OptionsFromContext(ctx).Log()
+
+	if _, err := os.Lstat(path); errors.Is(err, fs.ErrNotExist) {
+		m, err := r.Gatherer.Gather(ctx, source, destination)
+		if err != nil {
+			if rerr := os.RemoveAll(path); rerr != nil {
+				log.Warn("failed to remove partial destination", "destination", path, "error", rerr)
+			}
+		}
+		return m, err
+	}
+
+	target, tmp, staged, err := stage(path)
+	if err != nil {
+		log.Warn("failed to stage gather, it is not rolled back on failure", "destination", path, "error", err)
+		return r.Gatherer.Gather(ctx, source, destination)
+	}
+	defer os.RemoveAll(tmp)
+
+	stagedDestination := staged
+	if strings.HasSuffix(destination, "/") || strings.HasSuffix(destination, string(os.PathSeparator)) {
+		stagedDestination += string(os.PathSeparator)
+	}
+	m, err := r.Gatherer.Gather(ctx, source, stagedDestination)
+	if err != nil {
+		return m, err
+	}
+	if err := moveContent(staged, target); err != nil {
+		return nil, fmt.Errorf("failed to move gathered content to %s: %w", path, err)
+	}
+
+	if m == nil {
+		return nil, nil
+	}
+	content, _ := m.Get()["destination"].(string)
+	if rel, err := filepath.Rel(staged, content); err == nil && (filepath.IsLocal(rel) || rel == ".") {
+		m = setDestination(m, filepath.Join(path, rel))
+	}
+	return m, nil
+}
+
+// stage creates a staging directory next to the existing destination path, with symbolic links
+// resolved, on the same filesystem so that the staged content can be renamed into place. It returns
+// the resolved path, the staging directory and the staged destination within it, which is created
+// like the resolved path: as an empty directory, or as an empty file, with its mode.
+func stage(path string) (string, string, string, error) {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", "", "", err
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return "", "", "", err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(target), ".go-gather-")
+	if err != nil {
+		return "", "", "", err
+	}
+	staged := filepath.Join(tmp, filepath.Base(target))
+	if info.IsDir() {
+		err = os.Mkdir(staged, info.Mode().Perm())
+	} else {
+		var f *os.File
+		if f, err = os.OpenFile(staged, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm()); err == nil {
+			err = f.Close()
+		}
+	}
+	if err == nil {
+		// The mode was masked by the umask.
+		err = os.Chmod(staged, info.Mode().Perm())
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		return "", "", "", err
+	}
+	return target, tmp, staged, nil
+}
+
+// moveContent moves the gathered content at src to dst by renaming it. Directories that exist at
+// both are merged, and any other entry of dst is replaced.
+func moveContent(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	existing, err := os.Lstat(dst)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return os.Rename(src, dst)
+	case err != nil:
+		return err
+	case info.IsDir() && existing.IsDir():
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := moveContent(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	case info.IsDir() || existing.IsDir():
+		// Directories cannot be renamed over files, nor anything over directories.
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+	return os.Rename(src, dst)
+}
+
+// localDestination returns the local path of destination, and whether it is one.
+func localDestination(destination string) (string, bool) {
+	if t, err := gogather.ClassifyURI(destination); err != nil || t != gogather.FileURI {
+		return "", false
+	}
+	path, err := gogather.LocalPath(destination)
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
@@ -0,0 +1,134 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+	fileMetadata "github.com/enterprise-contract/go-gather/metadata/file"
+)
+
+// TestGather_Rollback tests that failed gathers remove or restore their destinations
+func TestGather_Rollback(t *testing.T) {
+	destination := filepath.Join(t.TempDir(), "out")
+	if _, err := Gather(context.Background(), writeSourceDir(t), destination, WithMaxSize(10)); err == nil {
+		t.Fatal("expected an error, but got nil")
+	}
+	if _, err := os.Stat(destination); !os.IsNotExist(err) {
+		t.Errorf("expected the destination to be removed: %v", err)
+	}
+
+	// Existing destinations are restored.
+	destination = t.TempDir()
+	if err := os.WriteFile(filepath.Join(destination, "previous.rego"), []byte("package previous"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Gather(context.Background(), writeSourceDir(t), destination, WithMaxSize(10)); err == nil {
+		t.Fatal("expected an error, but got nil")
+	}
+	entries, err := os.ReadDir(destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "previous.rego" {
+		t.Errorf("expected the destination to be restored, got %v", entries)
+	}
+
+	// Successful gathers update existing destinations in place.
+	if _, err := Gather(context.Background(), writeSourceDir(t), destination); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, name := range []string{"previous.rego", "main.rego"} {
+		if _, err := os.Stat(filepath.Join(destination, name)); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+// TestRollbackGatherer_Staged tests that existing destinations are only updated once the gather
+// succeeds, and that the staged content is moved into place
+func TestRollbackGatherer_Staged(t *testing.T) {
+	parent := t.TempDir()
+	destination := filepath.Join(parent, "out")
+	if err := os.MkdirAll(filepath.Join(destination, "policy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"previous.rego": "package previous", "policy/main.rego": "package old"} {
+		if err := os.WriteFile(filepath.Join(destination, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var staged string
+	write := func(fail bool) Gatherer {
+		return gathererFunc(func(_ context.Context, _, local string) (metadata.Metadata, error) {
+			staged = local
+			if err := os.MkdirAll(filepath.Join(local, "policy"), 0755); err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(filepath.Join(local, "policy", "main.rego"), []byte("package main"), 0600); err != nil {
+				return nil, err
+			}
+			if fail {
+				return nil, errors.New("failed")
+			}
+			return &fileMetadata.DirectoryMetadata{Common: metadata.Common{Destination: local}, Path: local}, nil
+		})
+	}
+
+	if _, err := NewRollbackGatherer(write(true)).Gather(context.Background(), "src", destination); err == nil {
+		t.Fatal("expected an error, but got nil")
+	}
+	if staged == destination || filepath.Dir(filepath.Dir(staged)) != parent {
+		t.Errorf("expected the gather to be staged next to the destination, got %s", staged)
+	}
+	if data, err := os.ReadFile(filepath.Join(destination, "policy", "main.rego")); err != nil || string(data) != "package old" {
+		t.Errorf("expected the destination to be left untouched, got %q, %v", data, err)
+	}
+
+	m, err := NewRollbackGatherer(write(false)).Gather(context.Background(), "src", destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d, ok := m.(*fileMetadata.DirectoryMetadata); !ok || d.Destination != destination || d.Path != destination {
+		t.Errorf("unexpected metadata: %+v", m)
+	}
+	for name, content := range map[string]string{"previous.rego": "package previous", "policy/main.rego": "package main"} {
+		if data, err := os.ReadFile(filepath.Join(destination, name)); err != nil || string(data) != content {
+			t.Errorf("unexpected content of %s: %q, %v", name, data, err)
+		}
+	}
+	if entries, err := os.ReadDir(parent); err != nil || len(entries) != 1 {
+		t.Errorf("expected the staging directories to be removed, got %v, %v", entries, err)
+	}
+}
+
+// TestGather_KeepPartial tests keeping the content of failed gathers
+func TestGather_KeepPartial(t *testing.T) {
+	destination := filepath.Join(t.TempDir(), "out")
+	if _, err := Gather(context.Background(), writeSourceDir(t), destination, WithMaxSize(10), WithKeepPartial()); err == nil {
+		t.Fatal("expected an error, but got nil")
+	}
+	if _, err := os.Stat(destination); err != nil {
+		t.Errorf("expected the partial destination to be kept: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build unix
+
+package gather
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestGather_Rollback_ModesAndSymlinks tests that failed gathers leave the modes and symbolic links
+// of existing destinations as they were, and that successful gathers keep the mode of replaced
+// files and write through symbolic links
+func TestGather_Rollback_ModesAndSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.sh")
+	if err := os.Symlink("script.sh", link); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(writeSourceDir(t), "main.rego")
+
+	if _, err := Gather(context.Background(), source, link, WithMaxSize(3)); err == nil {
+		t.Fatal("expected an error, but got nil")
+	}
+	if target, err := os.Readlink(link); err != nil || target != "script.sh" {
+		t.Errorf("expected the symbolic link to be kept, got %q, %v", target, err)
+	}
+	if data, err := os.ReadFile(script); err != nil || string(data) != "#!/bin/sh" {
+		t.Errorf("expected the file to be left untouched, got %q, %v", data, err)
+	}
+
+	if _, err := Gather(context.Background(), source, link); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if target, err := os.Readlink(link); err != nil || target != "script.sh" {
+		t.Errorf("expected the symbolic link to be kept, got %q, %v", target, err)
+	}
+	info, err := os.Stat(script)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("expected the mode of the file to be kept, got %v", info.Mode())
+	}
+	if data, err := os.ReadFile(script); err != nil || string(data) != "package main" {
+		t.Errorf("unexpected content: %q, %v", data, err)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 2 {
+		t.Errorf("expected the staging directories to be removed, got %v, %v", entries, err)
+	}
+}
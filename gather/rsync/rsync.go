@@ -0,0 +1,449 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package rsync provides functionality for gathering files and directories with rsync. It
+// includes an implementation of the Gatherer interface, RsyncGatherer, which runs the rsync
+// executable to synchronize a file, or a directory recursively, into a destination path.
+//
+// Sources are of the form rsync://[user@]host[:port]/module/path, for modules served by an rsync
+// daemon, or rsync+ssh://[user@]host[:port]/path, for rsync running over SSH. Sources ending with a
+// slash, and sources naming a whole module, are directories; the other sources are files. Since
+// rsync only transfers the parts of the files that changed, gathering a large directory again into
+// the same destination is fast.
+//
+// Example usage:
+//
+//	g := &rsync.RsyncGatherer{Delete: true}
+//	m, err := g.Gather(context.Background(), "rsync://mirror.example.com/policies/release/", "/var/lib/policies")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package rsync
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+	rsyncMetadata "github.com/enterprise-contract/go-gather/metadata/rsync"
+)
+
+const (
+	// DefaultBinary is the rsync executable run when none is configured, looked up in PATH.
+	DefaultBinary = "rsync"
+	// DefaultSSHCommand is the remote shell rsync runs over SSH with when none is configured.
+	// Batch mode makes ssh fail rather than prompt for a password or passphrase.
+	DefaultSSHCommand = "ssh -o BatchMode=yes"
+	// DefaultTimeout is the I/O timeout of the transfer when none is configured.
+	DefaultTimeout = 60 * time.Second
+)
+
+// ExitError is returned when rsync exits with a non-zero status.
+type ExitError struct {
+	// Code is the exit status of rsync.
+	Code int
+	// Stderr is the error output of rsync.
+	Stderr string
+}
+
+func (e *ExitError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("rsync exited with status %d", e.Code)
+	}
+	return fmt.Sprintf("rsync exited with status %d: %s", e.Code, e.Stderr)
+}
+
+// Retryable reports whether the transfer may succeed when retried, i.e. whether rsync failed to
+// start the protocol with the server (5), the connection failed (10, 12), timed out (30, 35) or
+// the transfer was only partial because files vanished (24).
+func (e *ExitError) Retryable() bool {
+	switch e.Code {
+	case 5, 10, 12, 24, 30, 35:
+		return true
+	}
+	return false
+}
+
+// RsyncGatherer synchronizes files and directories with rsync, from rsync daemons or over SSH.
+// The rsync executable must be installed.
+type RsyncGatherer struct {
+	// Binary is the path of the rsync executable. Defaults to DefaultBinary.
+	Binary string
+	// SSHCommand is the remote shell rsync runs over SSH with, e.g.
+	// "ssh -i /etc/gather/id_ed25519 -o BatchMode=yes". Defaults to DefaultSSHCommand.
+	SSHCommand string
+	// Delete removes the files of a destination directory that do not exist in the source, so
+	// that the destination mirrors the source. Only enable it for destinations that hold nothing
+	// but the gathered content.
+	Delete bool
+	// Args are additional arguments passed to rsync, e.g. "--compress" or "--bwlimit=10m".
+	Args []string
+	// Timeout is the I/O timeout of the transfer: rsync fails when no data is exchanged for this
+	// long. Defaults to DefaultTimeout.
+	Timeout time.Duration
+	// Logger receives diagnostic messages. When nil, the logger of the gather options is used.
+	Logger *slog.Logger
+}
+
+// Gather synchronizes the file at source to destination, or, if source is a directory, the files
+// below it into the destination directory that are selected by the Include and Exclude patterns of
+// the gather options. A file is saved in the destination under its base name if the destination is
+// an existing directory or ends with a separator. The server is checked against the host policy of
+// the gather options with the scheme of the source. The password for an rsync daemon is taken from
+// the source, or else from the Auth provider of the gather options.
+func (g *RsyncGatherer) Gather(ctx context.Context, source, destination string) (_ metadata.Metadata, err error) {
+	defer func() {
+		err = gogather.RedactError(err)
+		gogather.FinishProgress(ctx, err)
+	}()
+	startedAt := time.Now()
+
+	loc, err := parseSource(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := gogather.CheckHost(ctx, loc.scheme, loc.host); err != nil {
+		return nil, err
+	}
+
+	m := &rsyncMetadata.RsyncMetadata{Host: loc.host, Module: loc.module, Path: loc.path}
+	if loc.dir() {
+		if err := os.MkdirAll(destination, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+	} else {
+		if strings.HasSuffix(destination, string(os.PathSeparator)) || strings.HasSuffix(destination, "/") {
+			destination = filepath.Join(destination, path.Base(loc.path))
+		} else if fi, err := os.Stat(destination); err == nil && fi.IsDir() {
+			destination = filepath.Join(destination, path.Base(loc.path))
+		}
+		if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
+	gogather.StartProgress(ctx, -1, -1)
+	if err := g.run(ctx, loc, destination, m); err != nil {
+		return nil, err
+	}
+
+	if loc.dir() {
+		opts := gogather.OptionsFromContext(ctx)
+		if err := opts.Prune(destination); err != nil {
+			return nil, err
+		}
+		if m.Size, err = dirSize(destination); err != nil {
+			return nil, err
+		}
+		if m.TreeHash, err = metadata.TreeHash(destination); err != nil {
+			return nil, err
+		}
+		if err := gogather.VerifyChecksumDigest(ctx, destination, "sha256", m.TreeHash); err != nil {
+			return nil, err
+		}
+	} else {
+		if m.Size, m.SHA256, err = fileDigest(destination); err != nil {
+			return nil, err
+		}
+		if err := gogather.VerifyChecksum(ctx, destination); err != nil {
+			_ = os.Remove(destination)
+			return nil, err
+		}
+	}
+
+	resolved, _ := m.GetPinnedURL(gogather.RedactURL(source))
+	m.Common = metadata.NewCommon(rsyncMetadata.Type, gogather.RedactURL(source), resolved, destination, startedAt)
+	return m, nil
+}
+
+// run runs rsync to synchronize the content of loc to destination. The files rsync reports as
+// received are counted as written, and rsync is stopped once the gather exceeds the MaxSize of the
+// gather options.
+func (g *RsyncGatherer) run(ctx context.Context, loc *location, destination string, m *rsyncMetadata.RsyncMetadata) error {
+	args, env, err := g.command(ctx, loc, destination)
+	if err != nil {
+		return err
+	}
+	binary := g.Binary
+	if binary == "" {
+		binary = DefaultBinary
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Env = append(os.Environ(), env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to run rsync: %w", err)
+	}
+
+	gogather.Logger(ctx, g.Logger).Debug("running rsync", "args", args)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to run rsync: %w", err)
+	}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		// Lines are of the form "<itemized changes> <size> <name>", where the itemized changes
+		// of received files start with ">f", see the --itemize-changes option of rsync.
+		fields := strings.SplitN(scanner.Text(), " ", 3)
+		if len(fields) != 3 || !strings.HasPrefix(fields[0], ">f") {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		m.Transferred++
+		if err := gogather.CountWritten(ctx, size); err != nil {
+			cancel(err)
+			break
+		}
+		gogather.CountItems(ctx, 1)
+	}
+	_, _ = io.Copy(io.Discard, stdout)
+
+	err = cmd.Wait()
+	if cause := context.Cause(ctx); cause != nil {
+		return cause
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Code: exitErr.ExitCode(), Stderr: strings.TrimSpace(stderr.String())}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to run rsync: %w", err)
+	}
+	return nil
+}
+
+// command returns the arguments rsync is run with to synchronize the content of loc to
+// destination, and the environment variables it needs in addition to those of the process.
+func (g *RsyncGatherer) command(ctx context.Context, loc *location, destination string) ([]string, []string, error) {
+	opts := gogather.OptionsFromContext(ctx)
+	timeout := g.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	seconds := strconv.Itoa(int((timeout + time.Second - 1) / time.Second))
+	args := []string{"--recursive", "--times", "--protect-args", "--timeout=" + seconds, "--out-format=%i %l %n"}
+	var env []string
+
+	creds, err := opts.Credentials(ctx, loc.host)
+	if err != nil {
+		return nil, nil, err
+	}
+	username, password := loc.user, loc.password
+	if creds != nil {
+		if username == "" {
+			username = creds.Username
+		}
+		if password == "" {
+			password = creds.Password
+		}
+	}
+
+	var remote string
+	switch loc.scheme {
+	case "rsync":
+		args = append(args, "--contimeout="+seconds)
+		// rsync does not decode the URLs of daemons, so the decoded path is passed as is.
+		host := loc.host
+		if loc.port != "" {
+			host = net.JoinHostPort(loc.host, loc.port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		if username != "" {
+			host = username + "@" + host
+		}
+		remote = "rsync://" + host + "/" + loc.module + "/" + loc.path
+		if password != "" {
+			env = append(env, "RSYNC_PASSWORD="+password)
+		}
+		// rsync connects to daemons through an HTTP proxy supporting the CONNECT method.
+		if opts.Proxy != "" {
+			p, err := url.Parse(opts.Proxy)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse proxy URL: %w", err)
+			}
+			env = append(env, "RSYNC_PROXY="+p.Host)
+		}
+	case "rsync+ssh":
+		shell := g.SSHCommand
+		if shell == "" {
+			shell = DefaultSSHCommand
+		}
+		if loc.port != "" {
+			shell += " -p " + loc.port
+		}
+		args = append(args, "--rsh="+shell)
+		host := loc.host
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		if username != "" {
+			host = username + "@" + host
+		}
+		remote = host + ":" + loc.path
+	}
+
+	if loc.dir() {
+		if g.Delete {
+			args = append(args, "--delete", "--delete-excluded")
+		}
+		args = append(args, filterArgs(opts.Include, opts.Exclude)...)
+		destination = strings.TrimSuffix(destination, string(os.PathSeparator)) + string(os.PathSeparator)
+	}
+	args = append(args, g.Args...)
+	return append(args, "--", remote, destination), env, nil
+}
+
+// filterArgs translates the Include and Exclude patterns of the gather options into filter rules
+// of rsync, anchored at the source directory, so that only the selected files are transferred.
+// Since the patterns of rsync differ in details, e.g. "a/**/b" does not match "a/b", the
+// destination is pruned with the patterns afterwards.
+func filterArgs(include, exclude []string) []string {
+	var args []string
+	for _, p := range exclude {
+		args = append(args, "--exclude="+rsyncPattern(p))
+	}
+	if len(include) == 0 {
+		return args
+	}
+	for _, p := range include {
+		args = append(args, "--include="+rsyncPattern(p))
+		if strings.Contains(p, "**/") {
+			args = append(args, "--include="+rsyncPattern(strings.ReplaceAll(p, "**/", "")))
+		}
+	}
+	// Descend into every directory, leaving out those left empty, and skip the other files.
+	return append(args, "--include=*/", "--exclude=*", "--prune-empty-dirs")
+}
+
+// rsyncPattern returns the rsync pattern matching the paths the glob pattern p matches.
+func rsyncPattern(p string) string {
+	return "/" + strings.NewReplacer(`\`, `\\`, "[", `\[`).Replace(gogather.NormalizePath(p))
+}
+
+// dirSize returns the total size of the regular files below dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d os.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to determine the size of %s: %w", dir, err)
+	}
+	return size, nil
+}
+
+// fileDigest returns the size and the hex encoded SHA256 digest of the file at path.
+func fileDigest(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// location identifies a file or directory on a server: the path within a module of an rsync
+// daemon, or the path on a host reached over SSH.
+type location struct {
+	scheme, user, password, host, port, module, path string
+}
+
+// dir reports whether the location is a directory, i.e. whether its path ends with a slash or it
+// is a whole module.
+func (l *location) dir() bool {
+	return strings.HasSuffix(l.path, "/") || (l.scheme == "rsync" && l.path == "")
+}
+
+// parseSource parses a source of the form rsync://[user@]host[:port]/module/path or
+// rsync+ssh://[user@]host[:port]/path, optionally forced with the "rsync::" prefix.
+func parseSource(source string) (*location, error) {
+	u, err := url.Parse(strings.TrimPrefix(source, "rsync::"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source %s: %w", source, err)
+	}
+	if u.Scheme != "rsync" && u.Scheme != "rsync+ssh" {
+		return nil, fmt.Errorf("unsupported rsync source: %s", source)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("unsupported parameters of %s", source)
+	}
+
+	loc := &location{scheme: u.Scheme, host: u.Hostname(), port: u.Port()}
+	if u.User != nil {
+		loc.user = u.User.Username()
+		loc.password, _ = u.User.Password()
+	}
+	// Hosts and users starting with a dash would be taken as options by ssh.
+	if loc.host == "" || strings.HasPrefix(loc.host, "-") || strings.HasPrefix(loc.user, "-") {
+		return nil, fmt.Errorf("invalid host of %s", source)
+	}
+
+	p := strings.TrimPrefix(u.Path, "/")
+	if u.Scheme == "rsync" {
+		loc.module, loc.path, _ = strings.Cut(p, "/")
+		if loc.module == "" {
+			return nil, fmt.Errorf("source must be of the form rsync://host/module/path: %s", source)
+		}
+	} else {
+		if p == "" {
+			return nil, fmt.Errorf("source must be of the form rsync+ssh://host/path: %s", source)
+		}
+		loc.path = u.Path
+	}
+	if slices.Contains(strings.Split(loc.path, "/"), "..") {
+		return nil, fmt.Errorf("path of %s must not contain ..", source)
+	}
+	return loc, nil
+}
@@ -0,0 +1,444 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package s3 provides functionality for gathering objects from Amazon S3 and S3 compatible object
+// storage. It includes an implementation of the Gatherer interface, S3Gatherer, which downloads a
+// single object, or every object below a prefix, to a destination path.
+//
+// Sources are of the form s3://bucket/key for a single object and s3://bucket/prefix/, ending
+// with a slash, for the objects below a prefix. Objects of S3 compatible services are addressed
+// with their endpoint, e.g. s3::https://minio.example.com/bucket/key. The "version" query
+// parameter selects a version of an object and the "region" parameter the region of the bucket.
+//
+// Example usage:
+//
+//	g := &s3.S3Gatherer{Region: "us-east-1"}
+//	m, err := g.Gather(context.Background(), "s3://bucket/policies/", "/tmp/policies")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package s3
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+	s3Metadata "github.com/enterprise-contract/go-gather/metadata/s3"
+)
+
+// defaultRegion is the region used when neither the source, the gatherer nor the environment
+// set one.
+const defaultRegion = "us-east-1"
+
+// S3Gatherer downloads objects from S3 buckets. The zero value signs requests with SigV4 using the
+// credentials of the default credential chain, i.e. the environment, the shared configuration
+// and credentials files, and the container or instance role. Its fields take precedence over the
+// AWS environment, e.g. to download from an S3 compatible service such as MinIO or Ceph RGW.
+type S3Gatherer struct {
+	// Region is the AWS region of the buckets. If empty, the region is taken from the "region"
+	// parameter of the source, the environment or the shared configuration.
+	Region string
+	// Endpoint overrides the S3 endpoint, e.g. to use an S3 compatible service such as MinIO.
+	// Sources of the form s3::https://endpoint/bucket/key set their own endpoint.
+	Endpoint string
+	// UsePathStyle addresses buckets as part of the path rather than the host name, which most
+	// S3 compatible services require. Sources that set their own endpoint always use it.
+	UsePathStyle bool
+	// Credentials provides the credentials used to sign requests. If nil, the default
+	// credential chain is used.
+	Credentials aws.CredentialsProvider
+	// Anonymous sends unsigned requests, e.g. to download from public buckets without looking up
+	// credentials. It takes precedence over Credentials.
+	Anonymous bool
+	// Logger receives diagnostic messages. When nil, the logger of the gather options is used.
+	Logger *slog.Logger
+}
+
+// Gather downloads the object at source to destination, or, if source is a prefix, every object
+// below the prefix into the destination directory, keeping the path of the objects relative to the
+// prefix. An object is saved in the destination under the base name of its key if the destination
+// is an existing directory or ends with a separator. It returns S3Metadata recording the ETag and
+// version of the objects.
+func (s *S3Gatherer) Gather(ctx context.Context, source, destination string) (_ metadata.Metadata, err error) {
+	defer func() {
+		err = gogather.RedactError(err)
+		gogather.FinishProgress(ctx, err)
+	}()
+	startedAt := time.Now()
+
+	loc, err := parseSource(source)
+	if err != nil {
+		return nil, err
+	}
+	client, err := s.client(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	var m *s3Metadata.S3Metadata
+	if loc.isPrefix() {
+		m, err = s.gatherPrefix(ctx, client, loc, destination)
+	} else {
+		m, err = s.gatherObject(ctx, client, loc, destination)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Objects of buckets without versioning cannot be pinned and are left unresolved.
+	resolved, _ := m.GetPinnedURL(source)
+	m.Common = metadata.NewCommon("s3", gogather.RedactURL(source), resolved, m.Destination, startedAt)
+	return m, nil
+}
+
+// Resolve requests the metadata of the object at source, or lists the objects below the prefix,
+// without downloading them. The returned metadata has the version of the object set, if versioning
+// is enabled for the bucket, so that GetPinnedURL pins source.
+func (s *S3Gatherer) Resolve(ctx context.Context, source string) (_ metadata.Metadata, err error) {
+	defer func() { err = gogather.RedactError(err) }()
+	startedAt := time.Now()
+
+	loc, err := parseSource(source)
+	if err != nil {
+		return nil, err
+	}
+	client, err := s.client(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	gogather.Logger(ctx, s.Logger).Debug("resolving objects", "source", loc.String())
+	m := &s3Metadata.S3Metadata{Bucket: loc.bucket, Key: loc.key}
+	if loc.isPrefix() {
+		objects, err := list(ctx, client, loc)
+		if err != nil {
+			return nil, err
+		}
+		m.Objects = objects
+		for _, o := range objects {
+			m.Size += o.Size
+		}
+	} else {
+		out, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(loc.bucket), Key: aws.String(loc.key), VersionId: loc.versionID()})
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", loc, err)
+		}
+		m.ETag, m.VersionID, m.Size = aws.ToString(out.ETag), aws.ToString(out.VersionId), aws.ToInt64(out.ContentLength)
+	}
+
+	resolved, _ := m.GetPinnedURL(source)
+	m.Common = metadata.NewCommon("s3", gogather.RedactURL(source), resolved, "", startedAt)
+	return m, nil
+}
+
+// gatherObject downloads the single object of loc to destination.
+func (s *S3Gatherer) gatherObject(ctx context.Context, client *s3.Client, loc *location, destination string) (*s3Metadata.S3Metadata, error) {
+	if strings.HasSuffix(destination, string(os.PathSeparator)) || strings.HasSuffix(destination, "/") {
+		destination = filepath.Join(destination, path.Base(loc.key))
+	} else if fi, err := os.Stat(destination); err == nil && fi.IsDir() {
+		destination = filepath.Join(destination, path.Base(loc.key))
+	}
+
+	gogather.StartProgress(ctx, -1, 1)
+	o, err := s.download(ctx, client, loc.bucket, loc.key, loc.version, destination)
+	if err != nil {
+		return nil, err
+	}
+	if err := gogather.VerifyChecksum(ctx, destination); err != nil {
+		_ = os.Remove(destination)
+		return nil, err
+	}
+	return &s3Metadata.S3Metadata{
+		Common:    metadata.Common{Destination: destination},
+		Bucket:    loc.bucket,
+		Key:       loc.key,
+		ETag:      o.ETag,
+		VersionID: o.VersionID,
+		Size:      o.Size,
+	}, nil
+}
+
+// gatherPrefix downloads the objects below the prefix of loc that are selected by the Include and
+// Exclude patterns of the gather options into the destination directory.
+func (s *S3Gatherer) gatherPrefix(ctx context.Context, client *s3.Client, loc *location, destination string) (*s3Metadata.S3Metadata, error) {
+	opts := gogather.OptionsFromContext(ctx)
+	filter, err := gogather.NewPathFilter(opts.Include, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	listed, err := list(ctx, client, loc)
+	if err != nil {
+		return nil, err
+	}
+	var objects []s3Metadata.Object
+	var total int64
+	for _, o := range listed {
+		if filter.Match(strings.TrimPrefix(o.Key, loc.key)) {
+			objects = append(objects, o)
+			total += o.Size
+		}
+	}
+	if err := gogather.CheckWritten(ctx, total); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(destination, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	m := &s3Metadata.S3Metadata{Common: metadata.Common{Destination: destination}, Bucket: loc.bucket, Key: loc.key}
+	gogather.StartProgress(ctx, total, len(objects))
+	for _, o := range objects {
+		rel := filepath.FromSlash(strings.TrimPrefix(o.Key, loc.key))
+		if !filepath.IsLocal(rel) {
+			return nil, fmt.Errorf("object %s escapes the destination directory", o.Key)
+		}
+		downloaded, err := s.download(ctx, client, loc.bucket, o.Key, "", filepath.Join(destination, rel))
+		if err != nil {
+			return nil, err
+		}
+		m.Objects = append(m.Objects, downloaded)
+		m.Size += downloaded.Size
+	}
+
+	if opts.Checksum != "" {
+		treeHash, err := metadata.TreeHash(destination)
+		if err != nil {
+			return nil, err
+		}
+		if err := gogather.VerifyChecksumDigest(ctx, destination, "sha256", treeHash); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// download saves the object of bucket with key, in the given version or the latest if empty, to
+// the file at path.
+func (s *S3Gatherer) download(ctx context.Context, client *s3.Client, bucket, key, version, path string) (s3Metadata.Object, error) {
+	o := s3Metadata.Object{Key: key}
+	uri := (&location{bucket: bucket, key: key}).String()
+	gogather.Logger(ctx, s.Logger).Debug("downloading object", "source", uri, "version", version, "destination", path)
+
+	input := &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}
+	if version != "" {
+		input.VersionId = aws.String(version)
+	}
+	out, err := client.GetObject(ctx, input)
+	if err != nil {
+		return o, fmt.Errorf("failed to download %s: %w", uri, err)
+	}
+	defer out.Body.Close()
+	if size := aws.ToInt64(out.ContentLength); size > 0 {
+		if err := gogather.CheckWritten(ctx, size); err != nil {
+			return o, err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return o, fmt.Errorf("failed to create directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return o, fmt.Errorf("failed to create file: %w", err)
+	}
+	n, err := gogather.Copy(f, gogather.WrapReader(ctx, out.Body))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return o, fmt.Errorf("failed to download %s: %w", uri, err)
+	}
+	gogather.CountItems(ctx, 1)
+
+	o.ETag, o.VersionID, o.Size = aws.ToString(out.ETag), aws.ToString(out.VersionId), n
+	return o, nil
+}
+
+// list lists the objects below the prefix of loc, following the continuation tokens of the
+// paginated listing. Keys ending with a slash, which S3 consoles create as folder markers, are
+// skipped.
+func list(ctx context.Context, client *s3.Client, loc *location) ([]s3Metadata.Object, error) {
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(loc.bucket)}
+	if loc.key != "" {
+		input.Prefix = aws.String(loc.key)
+	}
+
+	var objects []s3Metadata.Object
+	pages := s3.NewListObjectsV2Paginator(client, input)
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", loc, err)
+		}
+		for _, c := range page.Contents {
+			key := aws.ToString(c.Key)
+			if strings.HasSuffix(key, "/") {
+				continue
+			}
+			objects = append(objects, s3Metadata.Object{Key: key, ETag: aws.ToString(c.ETag), Size: aws.ToInt64(c.Size)})
+		}
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("no objects found at %s", loc)
+	}
+	return objects, nil
+}
+
+// client creates an S3 client for loc using the default configuration with the options of the
+// gatherer applied, sending requests with the proxy and TLS settings of the gather options. The
+// endpoint is checked against the host policy of the gather options: the host of custom endpoints
+// with their scheme, and otherwise the bucket with the "s3" scheme.
+func (s *S3Gatherer) client(ctx context.Context, loc *location) (*s3.Client, error) {
+	endpoint := loc.endpoint
+	if endpoint == "" {
+		endpoint = s.Endpoint
+	}
+	if endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse endpoint: %w", err)
+		}
+		if err := gogather.CheckHost(ctx, u.Scheme, u.Hostname()); err != nil {
+			return nil, err
+		}
+	} else if err := gogather.CheckHost(ctx, "s3", loc.bucket); err != nil {
+		return nil, err
+	}
+
+	// The proxy and TLS settings are applied to the transport of the SDK, which also adds the CA
+	// bundle of the AWS configuration, if any.
+	o := gogather.OptionsFromContext(ctx)
+	if err := o.ConfigureTransport(&http.Transport{}); err != nil {
+		return nil, err
+	}
+	httpClient := awshttp.NewBuildableClient().WithTransportOptions(func(t *http.Transport) {
+		_ = o.ConfigureTransport(t)
+	})
+	opts := []func(*config.LoadOptions) error{config.WithHTTPClient(httpClient)}
+	if region := loc.region; region != "" {
+		opts = append(opts, config.WithRegion(region))
+	} else if s.Region != "" {
+		opts = append(opts, config.WithRegion(s.Region))
+	}
+	switch {
+	case s.Anonymous:
+		opts = append(opts, config.WithCredentialsProvider(aws.AnonymousCredentials{}))
+	case s.Credentials != nil:
+		opts = append(opts, config.WithCredentialsProvider(s.Credentials))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
+	}
+
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+		o.UsePathStyle = s.UsePathStyle || loc.endpoint != ""
+	}), nil
+}
+
+// location identifies the object, or the prefix of the objects, of an S3 source.
+type location struct {
+	bucket string
+	// key is the key of the object, or the prefix of the objects, ending with a slash. It is
+	// empty for every object of the bucket.
+	key string
+	// version is the version of the object to download, or empty for the latest.
+	version string
+	// region is the region of the bucket, if set by the source.
+	region string
+	// endpoint is the endpoint of S3 compatible sources, e.g. "https://minio.example.com", or
+	// empty for the endpoint of the gatherer.
+	endpoint string
+}
+
+// isPrefix reports whether the location is a prefix rather than a single object.
+func (l *location) isPrefix() bool {
+	return l.key == "" || strings.HasSuffix(l.key, "/")
+}
+
+// versionID returns the version of the location for requests, nil for the latest.
+func (l *location) versionID() *string {
+	if l.version == "" {
+		return nil
+	}
+	return aws.String(l.version)
+}
+
+func (l *location) String() string {
+	return "s3://" + l.bucket + "/" + l.key
+}
+
+// parseSource parses an S3 source, either s3://bucket/key or, for S3 compatible services,
+// s3::https://endpoint/bucket/key, optionally forced with the "s3::" prefix.
+func parseSource(source string) (*location, error) {
+	forced := strings.HasPrefix(source, "s3::")
+	u, err := url.Parse(strings.TrimPrefix(source, "s3::"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source URI: %w", err)
+	}
+
+	l := &location{}
+	switch {
+	case u.Scheme == "s3":
+		l.bucket, l.key = u.Host, strings.TrimPrefix(u.Path, "/")
+	case forced && (u.Scheme == "http" || u.Scheme == "https"):
+		l.endpoint = u.Scheme + "://" + u.Host
+		l.bucket, l.key, _ = strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	default:
+		return nil, fmt.Errorf("unsupported S3 source: %s", source)
+	}
+	if l.bucket == "" {
+		return nil, fmt.Errorf("source must be of the form s3://bucket/key: %s", source)
+	}
+
+	for key, values := range u.Query() {
+		switch key {
+		case "version":
+			l.version = values[0]
+		case "region":
+			l.region = values[0]
+		default:
+			return nil, fmt.Errorf("unsupported parameter %s of %s", key, source)
+		}
+	}
+	if l.version != "" && l.isPrefix() {
+		return nil, fmt.Errorf("a version can only be selected for an object: %s", source)
+	}
+	return l, nil
+}
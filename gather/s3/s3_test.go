@@ -0,0 +1,327 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	s3Metadata "github.com/enterprise-contract/go-gather/metadata/s3"
+)
+
+// fakeS3 is a minimal S3 compatible server using path style addressing. It serves the objects of
+// a single bucket, listing at most two keys per page, and the versions of the objects. It counts
+// the requests that are not signed.
+type fakeS3 struct {
+	objects  map[string]string
+	versions map[string]map[string]string
+	lists    int
+	unsigned int
+}
+
+func newFakeS3(t *testing.T, objects map[string]string) (*fakeS3, *httptest.Server) {
+	f := &fakeS3{objects: objects, versions: map[string]map[string]string{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") == "" {
+		f.unsigned++
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != "bucket" {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "<Error><Code>NoSuchBucket</Code><Message>The specified bucket does not exist</Message></Error>")
+		return
+	}
+	q := r.URL.Query()
+
+	if key == "" && q.Get("list-type") == "2" {
+		f.lists++
+		var keys []string
+		for k := range f.objects {
+			if strings.HasPrefix(k, q.Get("prefix")) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		start, _ := strconv.Atoi(q.Get("continuation-token"))
+		end := min(start+2, len(keys))
+		fmt.Fprint(w, "<ListBucketResult><Name>bucket</Name>")
+		for _, k := range keys[start:end] {
+			fmt.Fprintf(w, `<Contents><Key>%s</Key><ETag>"etag-%s"</ETag><Size>%d</Size></Contents>`, k, k, len(f.objects[k]))
+		}
+		if end < len(keys) {
+			fmt.Fprintf(w, "<IsTruncated>true</IsTruncated><NextContinuationToken>%d</NextContinuationToken>", end)
+		}
+		fmt.Fprintf(w, "<KeyCount>%d</KeyCount></ListBucketResult>", end-start)
+		return
+	}
+
+	content, ok := f.objects[key]
+	version := "latest"
+	if v := q.Get("versionId"); v != "" {
+		content, ok = f.versions[key][v]
+		version = v
+	}
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		if r.Method != http.MethodHead {
+			fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist</Message></Error>")
+		}
+		return
+	}
+	w.Header().Set("ETag", `"etag-`+key+`"`)
+	w.Header().Set("X-Amz-Version-Id", version)
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	if r.Method != http.MethodHead {
+		fmt.Fprint(w, content)
+	}
+}
+
+func newTestGatherer(endpoint string) *S3Gatherer {
+	return &S3Gatherer{
+		Region:       "us-east-1",
+		Endpoint:     endpoint,
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider("key", "secret", ""),
+	}
+}
+
+// TestS3Gatherer_Gather tests downloading a single object
+func TestS3Gatherer_Gather(t *testing.T) {
+	_, srv := newFakeS3(t, map[string]string{"path/to/file.json": `{"a": 1}`})
+	dir := t.TempDir()
+
+	m, err := newTestGatherer(srv.URL).Gather(context.Background(), "s3://bucket/path/to/file.json", dir+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	destination := filepath.Join(dir, "file.json")
+	if data, err := os.ReadFile(destination); err != nil || string(data) != `{"a": 1}` {
+		t.Fatalf("unexpected content: %q, %v", data, err)
+	}
+
+	s3m := m.(*s3Metadata.S3Metadata)
+	if s3m.Destination != destination || s3m.Bucket != "bucket" || s3m.Key != "path/to/file.json" || s3m.ETag != `"etag-path/to/file.json"` || s3m.VersionID != "latest" || s3m.Size != 8 {
+		t.Errorf("unexpected metadata: %+v", s3m)
+	}
+	if s3m.ResolvedURI != "s3://bucket/path/to/file.json?version=latest" {
+		t.Errorf("unexpected resolved URI: %s", s3m.ResolvedURI)
+	}
+}
+
+// TestS3Gatherer_Gather_Version tests downloading a version of an object
+func TestS3Gatherer_Gather_Version(t *testing.T) {
+	f, srv := newFakeS3(t, map[string]string{"file.txt": "new"})
+	f.versions["file.txt"] = map[string]string{"v1": "old"}
+	destination := filepath.Join(t.TempDir(), "file.txt")
+
+	m, err := newTestGatherer(srv.URL).Gather(context.Background(), "s3://bucket/file.txt?version=v1", destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(destination); string(data) != "old" {
+		t.Errorf("unexpected content: %q", data)
+	}
+	if v := m.(*s3Metadata.S3Metadata).VersionID; v != "v1" {
+		t.Errorf("unexpected version: %s", v)
+	}
+}
+
+// TestS3Gatherer_Gather_Prefix tests downloading the objects below a prefix across several pages
+// of the listing
+func TestS3Gatherer_Gather_Prefix(t *testing.T) {
+	f, srv := newFakeS3(t, map[string]string{
+		"policies/":                 "",
+		"policies/main.rego":        "package main",
+		"policies/lib/util.rego":    "package lib",
+		"policies/lib/data.json":    "{}",
+		"policies/README.md":        "# Policies",
+		"policies/release/rel.rego": "package release",
+		"other/file.txt":            "other",
+	})
+	destination := filepath.Join(t.TempDir(), "policies")
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Exclude: []string{"*.md"}})
+
+	m, err := newTestGatherer(srv.URL).Gather(ctx, "s3://bucket/policies/", destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.lists != 3 {
+		t.Errorf("expected the listing to take 3 pages, got %d", f.lists)
+	}
+
+	for name, content := range map[string]string{"main.rego": "package main", "lib/util.rego": "package lib", "lib/data.json": "{}", "release/rel.rego": "package release"} {
+		if data, err := os.ReadFile(filepath.Join(destination, name)); err != nil || string(data) != content {
+			t.Errorf("unexpected content of %s: %q, %v", name, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(destination, "README.md")); !os.IsNotExist(err) {
+		t.Errorf("expected excluded object to be skipped: %v", err)
+	}
+
+	s3m := m.(*s3Metadata.S3Metadata)
+	if s3m.Destination != destination || s3m.Key != "policies/" || len(s3m.Objects) != 4 || s3m.Size != 40 {
+		t.Errorf("unexpected metadata: %+v", s3m)
+	}
+	if s3m.ResolvedURI != "" {
+		t.Errorf("expected a prefix to be left unresolved, got %s", s3m.ResolvedURI)
+	}
+}
+
+// TestS3Gatherer_Gather_Endpoint tests sources that set the endpoint of an S3 compatible service
+func TestS3Gatherer_Gather_Endpoint(t *testing.T) {
+	_, srv := newFakeS3(t, map[string]string{"file.txt": "content"})
+	destination := filepath.Join(t.TempDir(), "file.txt")
+
+	g := newTestGatherer("")
+	g.UsePathStyle = false
+	if _, err := g.Gather(context.Background(), "s3::"+srv.URL+"/bucket/file.txt", destination); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(destination); string(data) != "content" {
+		t.Errorf("unexpected content: %q", data)
+	}
+}
+
+// TestS3Gatherer_Gather_Anonymous tests sending unsigned requests to public buckets
+func TestS3Gatherer_Gather_Anonymous(t *testing.T) {
+	f, srv := newFakeS3(t, map[string]string{"public/a.txt": "a", "public/b.txt": "b"})
+	destination := filepath.Join(t.TempDir(), "public")
+
+	g := newTestGatherer(srv.URL)
+	g.Anonymous = true
+	if _, err := g.Gather(context.Background(), "s3://bucket/public/", destination); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(destination, "b.txt")); string(data) != "b" {
+		t.Errorf("unexpected content: %q", data)
+	}
+	if f.unsigned != 3 {
+		t.Errorf("expected the listing and both downloads to be unsigned, got %d unsigned requests", f.unsigned)
+	}
+
+	f.unsigned = 0
+	g.Anonymous = false
+	if _, err := g.Gather(context.Background(), "s3://bucket/public/a.txt", filepath.Join(destination, "signed.txt")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.unsigned != 0 {
+		t.Errorf("expected the requests to be signed, got %d unsigned requests", f.unsigned)
+	}
+}
+
+// TestS3Gatherer_Gather_Errors tests failing downloads
+func TestS3Gatherer_Gather_Errors(t *testing.T) {
+	_, srv := newFakeS3(t, map[string]string{"file.txt": "content", "dir/large.bin": strings.Repeat("x", 100)})
+	g := newTestGatherer(srv.URL)
+	dir := t.TempDir()
+
+	_, err := g.Gather(context.Background(), "s3://bucket/missing.txt", filepath.Join(dir, "missing.txt"))
+	if err == nil || !strings.Contains(err.Error(), "failed to download s3://bucket/missing.txt") || !strings.Contains(err.Error(), "NoSuchKey") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = g.Gather(context.Background(), "s3://bucket/empty/", filepath.Join(dir, "empty"))
+	if err == nil || err.Error() != "no objects found at s3://bucket/empty/" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{MaxSize: 10})
+	if _, err := g.Gather(ctx, "s3://bucket/dir/", filepath.Join(dir, "large")); !errors.Is(err, gogather.ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+
+	ctx = gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{HostPolicy: &gogather.HostPolicy{AllowedSchemes: []string{"https"}}})
+	var denied *gogather.HostDeniedError
+	if _, err := g.Gather(ctx, "s3://bucket/file.txt", filepath.Join(dir, "file.txt")); !errors.As(err, &denied) {
+		t.Errorf("expected the host policy to deny the endpoint, got %v", err)
+	}
+}
+
+// TestS3Gatherer_Resolve tests resolving objects without downloading them
+func TestS3Gatherer_Resolve(t *testing.T) {
+	_, srv := newFakeS3(t, map[string]string{"dir/a.txt": "a", "dir/b.txt": "bb", "dir/c.txt": "ccc"})
+	g := newTestGatherer(srv.URL)
+
+	m, err := g.Resolve(context.Background(), "s3://bucket/dir/b.txt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s3m := m.(*s3Metadata.S3Metadata); s3m.ETag != `"etag-dir/b.txt"` || s3m.Size != 2 || s3m.ResolvedURI != "s3://bucket/dir/b.txt?version=latest" || s3m.Destination != "" {
+		t.Errorf("unexpected metadata: %+v", s3m)
+	}
+
+	m, err = g.Resolve(context.Background(), "s3://bucket/dir/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s3m := m.(*s3Metadata.S3Metadata); len(s3m.Objects) != 3 || s3m.Size != 6 {
+		t.Errorf("unexpected metadata: %+v", s3m)
+	}
+}
+
+// TestParseSource tests parsing S3 sources
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected location
+		err      string
+	}{
+		{source: "s3://bucket/path/to/key", expected: location{bucket: "bucket", key: "path/to/key"}},
+		{source: "s3::s3://bucket/prefix/?region=eu-west-1", expected: location{bucket: "bucket", key: "prefix/", region: "eu-west-1"}},
+		{source: "s3://bucket", expected: location{bucket: "bucket"}},
+		{source: "s3://bucket/key?version=v1", expected: location{bucket: "bucket", key: "key", version: "v1"}},
+		{source: "s3::https://minio.example.com:9000/bucket/key", expected: location{bucket: "bucket", key: "key", endpoint: "https://minio.example.com:9000"}},
+		{source: "https://minio.example.com/bucket/key", err: "unsupported S3 source: https://minio.example.com/bucket/key"},
+		{source: "s3::https://minio.example.com/", err: "source must be of the form s3://bucket/key: s3::https://minio.example.com/"},
+		{source: "s3://bucket/prefix/?version=v1", err: "a version can only be selected for an object: s3://bucket/prefix/?version=v1"},
+		{source: "s3://bucket/key?ref=main", err: "unsupported parameter ref of s3://bucket/key?ref=main"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			l, err := parseSource(tt.source)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *l != tt.expected {
+				t.Errorf("unexpected location: got %+v, want %+v", *l, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+module github.com/enterprise-contract/go-gather/gather/sftp
+
+go 1.22.5
+
+require (
+	github.com/enterprise-contract/go-gather v0.0.3
+	github.com/enterprise-contract/go-gather/metadata v0.0.2
+	github.com/enterprise-contract/go-gather/metadata/sftp v0.0.1
+	github.com/pkg/sftp v1.13.7
+	golang.org/x/crypto v0.24.0
+)
+
+require (
+	github.com/kr/fs v0.1.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
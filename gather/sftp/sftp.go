@@ -0,0 +1,441 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package sftp provides functionality for gathering files and directories from servers over SFTP.
+// It includes an implementation of the Gatherer interface, SFTPGatherer, which downloads a file,
+// or a directory recursively, to a destination path.
+//
+// Sources are of the form sftp://user@host[:port]/path, or use the scp notation, either as a URL,
+// scp://user@host:/path, or bare, user@host:path, where a path not starting with a slash is
+// relative to the home directory of the user. Connections are authenticated with the configured
+// private keys or, when none are configured, with the keys held by the SSH agent.
+//
+// Example usage:
+//
+//	g := &sftp.SFTPGatherer{KeyFile: "/home/user/.ssh/id_ed25519"}
+//	m, err := g.Gather(context.Background(), "deploy@example.com:artifacts/bundle.tar.gz", "/tmp/bundle.tar.gz")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package sftp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+	sftpMetadata "github.com/enterprise-contract/go-gather/metadata/sftp"
+)
+
+// DefaultTimeout is the time allowed for establishing the SSH connection when none is configured.
+const DefaultTimeout = 30 * time.Second
+
+// SFTPGatherer downloads files and directories from servers over SFTP.
+type SFTPGatherer struct {
+	// Signers holds the private keys used to authenticate. If empty, the key in KeyFile is
+	// used, and if that is not set either, the keys held by the SSH agent are used.
+	Signers []ssh.Signer
+	// KeyFile is the path of a PEM encoded private key used to authenticate.
+	KeyFile string
+	// Passphrase decrypts KeyFile when it is encrypted.
+	Passphrase string
+	// HostKeyCallback verifies the host key of the server. If nil, the host key is verified
+	// against the known_hosts file of the current user.
+	HostKeyCallback ssh.HostKeyCallback
+	// Timeout is the time allowed for establishing the SSH connection. Defaults to
+	// DefaultTimeout.
+	Timeout time.Duration
+	// Logger receives diagnostic messages. When nil, the logger of the gather options is used.
+	Logger *slog.Logger
+}
+
+// Gather downloads the file at source to destination, or, if source is a directory, every file
+// below it into the destination directory that is selected by the Include and Exclude patterns of
+// the gather options. A file is saved in the destination under its base name if the destination is
+// an existing directory or ends with a separator. The server is checked against the host policy
+// of the gather options with the "sftp" scheme, whichever notation the source uses. Password
+// authentication is offered as well when the Auth provider of the gather options has credentials
+// for the server.
+func (g *SFTPGatherer) Gather(ctx context.Context, source, destination string) (_ metadata.Metadata, err error) {
+	defer func() {
+		err = gogather.RedactError(err)
+		gogather.FinishProgress(ctx, err)
+	}()
+	startedAt := time.Now()
+
+	loc, err := parseSource(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := gogather.CheckHost(ctx, "sftp", loc.host); err != nil {
+		return nil, err
+	}
+
+	client, err := g.connect(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	fi, err := client.Stat(loc.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", loc.path, err)
+	}
+	m := &sftpMetadata.SFTPMetadata{Path: loc.path, ModTime: fi.ModTime()}
+	if fi.IsDir() {
+		destination, err = g.gatherDir(ctx, client.Client, loc.path, destination, m)
+	} else {
+		destination, err = g.gatherFile(ctx, client.Client, loc.path, destination, m)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, _ := m.GetPinnedURL(gogather.RedactURL(source))
+	m.Common = metadata.NewCommon("sftp", gogather.RedactURL(source), resolved, destination, startedAt)
+	return m, nil
+}
+
+// gatherFile downloads the file at remote to destination, returning the path it was saved to.
+func (g *SFTPGatherer) gatherFile(ctx context.Context, client *sftp.Client, remote, destination string, m *sftpMetadata.SFTPMetadata) (string, error) {
+	if strings.HasSuffix(destination, string(os.PathSeparator)) || strings.HasSuffix(destination, "/") {
+		destination = filepath.Join(destination, path.Base(remote))
+	} else if fi, err := os.Stat(destination); err == nil && fi.IsDir() {
+		destination = filepath.Join(destination, path.Base(remote))
+	}
+
+	gogather.StartProgress(ctx, -1, 1)
+	h := sha256.New()
+	n, err := g.download(ctx, client, remote, destination, h)
+	if err != nil {
+		return "", err
+	}
+	if err := gogather.VerifyChecksum(ctx, destination); err != nil {
+		_ = os.Remove(destination)
+		return "", err
+	}
+	m.Size, m.SHA256 = n, hex.EncodeToString(h.Sum(nil))
+	return destination, nil
+}
+
+// gatherDir downloads the files below the directory at remote into the destination directory.
+// Files other than regular files, e.g. symbolic links, are skipped.
+func (g *SFTPGatherer) gatherDir(ctx context.Context, client *sftp.Client, remote, destination string, m *sftpMetadata.SFTPMetadata) (string, error) {
+	opts := gogather.OptionsFromContext(ctx)
+	filter, err := gogather.NewPathFilter(opts.Include, opts.Exclude)
+	if err != nil {
+		return "", err
+	}
+
+	log := gogather.Logger(ctx, g.Logger)
+	var files []string
+	var total int64
+	walker := client.Walk(remote)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return "", fmt.Errorf("failed to list %s: %w", walker.Path(), err)
+		}
+		if walker.Path() == remote || walker.Stat().IsDir() {
+			continue
+		}
+		rel := strings.TrimPrefix(walker.Path(), strings.TrimSuffix(remote, "/")+"/")
+		if !walker.Stat().Mode().IsRegular() {
+			log.Debug("skipping file that is not a regular file", "path", walker.Path(), "mode", walker.Stat().Mode())
+			continue
+		}
+		if filter.Match(rel) {
+			files = append(files, rel)
+			total += walker.Stat().Size()
+		}
+	}
+	if err := gogather.CheckWritten(ctx, total); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(destination, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	gogather.StartProgress(ctx, total, len(files))
+	for _, rel := range files {
+		local := filepath.FromSlash(rel)
+		if !filepath.IsLocal(local) {
+			return "", fmt.Errorf("file %s escapes the destination directory", rel)
+		}
+		n, err := g.download(ctx, client, path.Join(remote, rel), filepath.Join(destination, local), nil)
+		if err != nil {
+			return "", err
+		}
+		m.Size += n
+	}
+
+	if m.TreeHash, err = metadata.TreeHash(destination); err != nil {
+		return "", err
+	}
+	if err := gogather.VerifyChecksumDigest(ctx, destination, "sha256", m.TreeHash); err != nil {
+		return "", err
+	}
+	return destination, nil
+}
+
+// download saves the file at remote to the local path, also writing its content to h, if set. It
+// returns the number of bytes downloaded.
+func (g *SFTPGatherer) download(ctx context.Context, client *sftp.Client, remote, local string, h hash.Hash) (int64, error) {
+	gogather.Logger(ctx, g.Logger).Debug("downloading file", "path", remote, "destination", local)
+	src, err := client.Open(remote)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %w", remote, err)
+	}
+	defer src.Close()
+	if fi, err := src.Stat(); err == nil {
+		if err := gogather.CheckWritten(ctx, fi.Size()); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+	dst, err := os.Create(local)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file: %w", err)
+	}
+	var w io.Writer = dst
+	if h != nil {
+		w = io.MultiWriter(dst, h)
+	}
+	n, err := io.Copy(w, gogather.WrapReader(ctx, src))
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		return 0, fmt.Errorf("failed to download %s: %w", remote, err)
+	}
+	gogather.CountItems(ctx, 1)
+	return n, nil
+}
+
+// client is an SFTP session along with the SSH connection it runs on.
+type client struct {
+	*sftp.Client
+	ssh  *ssh.Client
+	stop func() bool
+}
+
+func (c *client) Close() error {
+	c.stop()
+	c.Client.Close()
+	return c.ssh.Close()
+}
+
+// connect establishes the SSH connection to the server of loc and starts an SFTP session on it.
+// Closing the connection aborts a transfer in progress when the context is cancelled.
+func (g *SFTPGatherer) connect(ctx context.Context, loc *location) (*client, error) {
+	config, closeAgent, err := g.clientConfig(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAgent()
+
+	addr := net.JoinHostPort(loc.host, "22")
+	if loc.port != "" {
+		addr = net.JoinHostPort(loc.host, loc.port)
+	}
+	conn, err := (&net.Dialer{Timeout: config.Timeout}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to establish SSH connection to %s: %w", addr, err)
+	}
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
+	stop := context.AfterFunc(ctx, func() { sshClient.Close() })
+
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		stop()
+		sshClient.Close()
+		return nil, fmt.Errorf("failed to start SFTP session: %w", err)
+	}
+	return &client{Client: sftpClient, ssh: sshClient, stop: stop}, nil
+}
+
+// clientConfig builds the SSH client configuration for the server of loc. The returned function
+// closes the connection to the SSH agent, if one was opened.
+func (g *SFTPGatherer) clientConfig(ctx context.Context, loc *location) (*ssh.ClientConfig, func(), error) {
+	nop := func() {}
+
+	creds, err := gogather.OptionsFromContext(ctx).Credentials(ctx, loc.host)
+	if err != nil {
+		return nil, nop, err
+	}
+
+	username := loc.user
+	if username == "" && creds != nil {
+		username = creds.Username
+	}
+	if username == "" {
+		u, err := user.Current()
+		if err != nil {
+			return nil, nop, fmt.Errorf("failed to determine user name: %w", err)
+		}
+		username = u.Username
+	}
+
+	hostKeyCallback := g.HostKeyCallback
+	if hostKeyCallback == nil {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nop, fmt.Errorf("failed to locate known_hosts: %w", err)
+		}
+		if hostKeyCallback, err = knownhosts.New(filepath.Join(home, ".ssh", "known_hosts")); err != nil {
+			return nil, nop, fmt.Errorf("failed to load known_hosts: %w", err)
+		}
+	}
+
+	var auth []ssh.AuthMethod
+	keys, closeAgent, err := g.authMethod()
+	if err == nil {
+		auth = append(auth, keys)
+	}
+	if creds != nil && creds.Password != "" {
+		auth = append(auth, ssh.Password(creds.Password))
+	}
+	if len(auth) == 0 {
+		return nil, nop, err
+	}
+
+	timeout := g.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &ssh.ClientConfig{
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         timeout,
+	}, closeAgent, nil
+}
+
+// authMethod returns the public key authentication method using the configured keys, or the SSH
+// agent when no keys are configured. The returned function closes the connection to the SSH agent.
+func (g *SFTPGatherer) authMethod() (ssh.AuthMethod, func(), error) {
+	nop := func() {}
+
+	if len(g.Signers) > 0 {
+		return ssh.PublicKeys(g.Signers...), nop, nil
+	}
+
+	if g.KeyFile != "" {
+		key, err := os.ReadFile(g.KeyFile)
+		if err != nil {
+			return nil, nop, fmt.Errorf("failed to read private key: %w", err)
+		}
+		var signer ssh.Signer
+		if g.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(g.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, nop, fmt.Errorf("failed to parse private key (%s): %w", g.KeyFile, err)
+		}
+		return ssh.PublicKeys(signer), nop, nil
+	}
+
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, nop, errors.New("no private key configured and SSH_AUTH_SOCK is not set")
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, nop, fmt.Errorf("failed to connect to SSH agent: %w", err)
+	}
+	return ssh.PublicKeysCallback(agent.NewClient(conn).Signers), func() { conn.Close() }, nil
+}
+
+// location identifies a file or directory on a server.
+type location struct {
+	user string
+	host string
+	port string
+	// path is the path of the file or directory, relative to the home directory of the user
+	// unless it starts with a slash.
+	path string
+}
+
+// parseSource parses an SFTP source: an sftp:// or scp:// URL, or the bare scp notation
+// user@host:path, optionally forced with the "sftp::" or "scp::" prefix. The scp:// URLs may
+// separate the path from the host with a colon, as in scp://user@host:/path or
+// scp://user@host:relative/path.
+func parseSource(source string) (*location, error) {
+	for _, prefix := range []string{"sftp::", "scp::"} {
+		source = strings.TrimPrefix(source, prefix)
+	}
+
+	if m := gogather.ScpPattern.FindStringSubmatch(source); m != nil {
+		return newLocation(m[1], m[2], "", m[3], source)
+	}
+
+	u, err := url.Parse(source)
+	if err != nil {
+		rest, ok := strings.CutPrefix(source, "scp://")
+		if m := gogather.ScpPattern.FindStringSubmatch(rest); ok && m != nil {
+			return newLocation(m[1], m[2], "", m[3], source)
+		}
+		return nil, fmt.Errorf("failed to parse source URI: %w", err)
+	}
+	if u.Scheme != "sftp" && u.Scheme != "scp" {
+		return nil, fmt.Errorf("unsupported SFTP source: %s", gogather.RedactURL(source))
+	}
+	if u.RawQuery != "" {
+		return nil, fmt.Errorf("unsupported parameters of %s", gogather.RedactURL(source))
+	}
+	return newLocation(u.User.Username(), u.Hostname(), u.Port(), u.Path, source)
+}
+
+// newLocation returns the location of path on the server, rejecting incomplete sources.
+func newLocation(user, host, port, path, source string) (*location, error) {
+	if host == "" || path == "" || strings.Contains(path, "?") {
+		return nil, fmt.Errorf("source must be of the form sftp://user@host/path or user@host:path: %s", gogather.RedactURL(source))
+	}
+	return &location{user: user, host: host, port: port, path: path}, nil
+}
@@ -0,0 +1,286 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	sftpMetadata "github.com/enterprise-contract/go-gather/metadata/sftp"
+)
+
+// newSigner generates an ed25519 private key and returns its signer.
+func newSigner(t *testing.T) ssh.Signer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	return signer
+}
+
+// startServer starts an SSH server that serves the sftp subsystem from the local filesystem to
+// clients authenticating as user "test" with the authorized key or the password "secret". It
+// returns the server address and a host key callback accepting the server.
+func startServer(t *testing.T, authorized ssh.PublicKey) (string, ssh.HostKeyCallback) {
+	hostKey := newSigner(t)
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "test" && bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unauthorized")
+		},
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "test" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unauthorized")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(conn, config)
+		}
+	}()
+
+	return l.Addr().String(), ssh.FixedHostKey(hostKey.PublicKey())
+}
+
+func serveConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					go func() {
+						server, err := sftp.NewServer(channel)
+						if err == nil {
+							server.Serve()
+						}
+						channel.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// writeFiles writes the files, keyed by their slash separated path, below dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestSFTPGatherer_Gather tests downloading a single file
+func TestSFTPGatherer_Gather(t *testing.T) {
+	signer := newSigner(t)
+	addr, hostKeyCallback := startServer(t, signer.PublicKey())
+	remote := t.TempDir()
+	writeFiles(t, remote, map[string]string{"bundle.tar.gz": "test data"})
+	dir := t.TempDir()
+
+	g := &SFTPGatherer{Signers: []ssh.Signer{signer}, HostKeyCallback: hostKeyCallback}
+	source := "sftp://test@" + addr + remote + "/bundle.tar.gz"
+	m, err := g.Gather(context.Background(), source, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	destination := filepath.Join(dir, "bundle.tar.gz")
+	if data, err := os.ReadFile(destination); err != nil || string(data) != "test data" {
+		t.Fatalf("unexpected content: %q, %v", data, err)
+	}
+
+	sm := m.(*sftpMetadata.SFTPMetadata)
+	digest := "916f0027a575074ce72a331777c3478d6513f786a591bd892da1a577bf2335f9"
+	if sm.Destination != destination || sm.Path != remote+"/bundle.tar.gz" || sm.Size != 9 || sm.SHA256 != digest {
+		t.Errorf("unexpected metadata: %+v", sm)
+	}
+	if sm.ResolvedURI != source+"?checksum=sha256:"+digest {
+		t.Errorf("unexpected resolved URI: %s", sm.ResolvedURI)
+	}
+}
+
+// TestSFTPGatherer_Gather_Directory tests downloading a directory in scp notation
+func TestSFTPGatherer_Gather_Directory(t *testing.T) {
+	signer := newSigner(t)
+	addr, hostKeyCallback := startServer(t, signer.PublicKey())
+	remote := t.TempDir()
+	writeFiles(t, remote, map[string]string{
+		"policies/main.rego":     "package main",
+		"policies/lib/util.rego": "package lib",
+		"policies/README.md":     "# Policies",
+	})
+	destination := filepath.Join(t.TempDir(), "policies")
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Exclude: []string{"*.md"}})
+
+	g := &SFTPGatherer{Signers: []ssh.Signer{signer}, HostKeyCallback: hostKeyCallback}
+	m, err := g.Gather(ctx, "scp://test@"+addr+remote+"/policies", destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, content := range map[string]string{"main.rego": "package main", "lib/util.rego": "package lib"} {
+		if data, err := os.ReadFile(filepath.Join(destination, name)); err != nil || string(data) != content {
+			t.Errorf("unexpected content of %s: %q, %v", name, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(destination, "README.md")); !os.IsNotExist(err) {
+		t.Errorf("expected excluded file to be skipped: %v", err)
+	}
+	if sm := m.(*sftpMetadata.SFTPMetadata); sm.Destination != destination || sm.Size != 23 || sm.TreeHash == "" {
+		t.Errorf("unexpected metadata: %+v", sm)
+	}
+}
+
+// TestSFTPGatherer_Gather_Password tests authenticating with the credentials of the gather options
+func TestSFTPGatherer_Gather_Password(t *testing.T) {
+	addr, hostKeyCallback := startServer(t, newSigner(t).PublicKey())
+	remote := t.TempDir()
+	writeFiles(t, remote, map[string]string{"file.txt": "test data"})
+	destination := filepath.Join(t.TempDir(), "file.txt")
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{
+		Auth: gogather.HostCredentials{"127.0.0.1": {Username: "test", Password: "secret"}},
+	})
+	g := &SFTPGatherer{HostKeyCallback: hostKeyCallback}
+	if _, err := g.Gather(ctx, "sftp://"+addr+remote+"/file.txt", destination); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(destination); string(data) != "test data" {
+		t.Errorf("unexpected content: %q", data)
+	}
+
+	_, err := g.Gather(context.Background(), "sftp://"+addr+remote+"/file.txt", destination)
+	if err == nil || err.Error() != "no private key configured and SSH_AUTH_SOCK is not set" {
+		t.Errorf("unexpected error without credentials: %v", err)
+	}
+}
+
+// TestSFTPGatherer_Gather_Errors tests failing downloads
+func TestSFTPGatherer_Gather_Errors(t *testing.T) {
+	signer := newSigner(t)
+	addr, hostKeyCallback := startServer(t, signer.PublicKey())
+	remote := t.TempDir()
+	writeFiles(t, remote, map[string]string{"large/file.bin": strings.Repeat("x", 100)})
+	g := &SFTPGatherer{Signers: []ssh.Signer{signer}, HostKeyCallback: hostKeyCallback}
+	dir := t.TempDir()
+
+	_, err := g.Gather(context.Background(), "sftp://test@"+addr+remote+"/missing.txt", dir)
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to stat "+remote+"/missing.txt") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{MaxSize: 10})
+	if _, err := g.Gather(ctx, "sftp://test@"+addr+remote+"/large", filepath.Join(dir, "large")); !errors.Is(err, gogather.ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+
+	ctx = gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{HostPolicy: &gogather.HostPolicy{DeniedHosts: []string{"127.0.0.1"}}})
+	var denied *gogather.HostDeniedError
+	if _, err := g.Gather(ctx, "scp://test@"+addr+remote+"/large", dir); !errors.As(err, &denied) || denied.Scheme != "sftp" {
+		t.Errorf("expected the host policy to deny the server, got %v", err)
+	}
+}
+
+// TestParseSource tests parsing SFTP sources in URL and scp notation
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected location
+		err      string
+	}{
+		{source: "sftp://user@example.com/srv/file.txt", expected: location{user: "user", host: "example.com", path: "/srv/file.txt"}},
+		{source: "sftp::sftp://example.com:2222/srv/dir", expected: location{host: "example.com", port: "2222", path: "/srv/dir"}},
+		{source: "scp://user@example.com:/srv/file.txt", expected: location{user: "user", host: "example.com", path: "/srv/file.txt"}},
+		{source: "scp://user@example.com:22/srv/file.txt", expected: location{user: "user", host: "example.com", port: "22", path: "/srv/file.txt"}},
+		{source: "scp://user@example.com:artifacts/file.txt", expected: location{user: "user", host: "example.com", path: "artifacts/file.txt"}},
+		{source: "user@example.com:artifacts/file.txt", expected: location{user: "user", host: "example.com", path: "artifacts/file.txt"}},
+		{source: "scp::user@example.com:/srv/file.txt", expected: location{user: "user", host: "example.com", path: "/srv/file.txt"}},
+		{source: "user@example.com:", err: "source must be of the form sftp://user@host/path or user@host:path: user@example.com:"},
+		{source: "sftp://example.com", err: "source must be of the form sftp://user@host/path or user@host:path: sftp://example.com"},
+		{source: "sftp://example.com/file?ref=main", err: "unsupported parameters of sftp://example.com/file?ref=main"},
+		{source: "https://example.com/file", err: "unsupported SFTP source: https://example.com/file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			l, err := parseSource(tt.source)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *l != tt.expected {
+				t.Errorf("unexpected location: got %+v, want %+v", *l, tt.expected)
+			}
+		})
+	}
+}
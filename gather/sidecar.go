@@ -0,0 +1,157 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// SidecarName is the name of the file the metadata of a gathered directory is persisted to.
+// The metadata of a gathered file is persisted next to it, to the file name with this suffix.
+const SidecarName = ".gather-metadata.json"
+
+// SidecarGatherer is a Gatherer that persists the metadata returned by the wrapped Gatherer as
+// JSON next to the destination, so that tools inspecting the destination later can recover the
+// pinned source without gathering it again.
+type SidecarGatherer struct {
+	Gatherer Gatherer
+	// SkipUnchanged skips gathers into destinations whose sidecar records a prior gather of the
+	// same content: the source is resolved to its identity, e.g. a commit, digest or checksum,
+	// and when the sidecar pins the source to the same identity, its metadata is returned with
+	// Unchanged set instead of gathering the source again.
+	SkipUnchanged bool
+}
+
+// NewSidecarGatherer returns a SidecarGatherer wrapping g.
+func NewSidecarGatherer(g Gatherer) *SidecarGatherer {
+	return &SidecarGatherer{Gatherer: g}
+}
+
+// Gather gathers the source using the wrapped Gatherer and writes its metadata to the sidecar
+// file of the destination.
+func (s *SidecarGatherer) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
+	if s.SkipUnchanged {
+		if m := s.unchanged(ctx, source, destination); m != nil {
+			return m, nil
+		}
+	}
+
+	m, err := s.Gatherer.Gather(ctx, source, destination)
+	if err != nil {
+		return m, err
+	}
+
+	path, err := SidecarPath(destination)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write metadata: %w", err)
+	}
+	return m, nil
+}
+
+// unchanged returns the metadata of the sidecar of destination, with Unchanged set, if it pins
+// source to the identity source currently resolves to, and nil otherwise. Sources are resolved by
+// the wrapped Gatherer if it is a Resolver, and by Resolve otherwise.
+func (s *SidecarGatherer) unchanged(ctx context.Context, source, destination string) metadata.Metadata {
+	startedAt := time.Now()
+	log := gogather.OptionsFromContext(ctx).Log()
+	prior, err := ReadSidecar(destination)
+	if err != nil {
+		return nil
+	}
+	pinned, err := prior.GetPinnedURL(source)
+	if err != nil {
+		return nil
+	}
+	priorKey, ok := pinnedKey(pinned)
+	if !ok {
+		return nil
+	}
+
+	current := source
+	if _, ok := pinnedKey(source); !ok {
+		var m metadata.Metadata
+		if r, ok := s.Gatherer.(Resolver); ok {
+			m, err = r.Resolve(ctx, source)
+		} else {
+			m, err = Resolve(ctx, source)
+		}
+		if err == nil {
+			current, err = m.GetPinnedURL(source)
+		}
+		if err != nil {
+			log.Debug("failed to resolve source, gathering it again", "source", gogather.RedactURL(source), "error", err)
+			return nil
+		}
+	}
+	if key, ok := pinnedKey(current); !ok || key != priorKey {
+		return nil
+	}
+
+	log.Debug("destination is up to date", "source", gogather.RedactURL(source), "destination", destination)
+	fields := prior.Get()
+	gatherer, _ := fields["gatherer"].(string)
+	resolved, _ := fields["resolvedURI"].(string)
+	content, _ := fields["destination"].(string)
+	common := metadata.NewCommon(gatherer, gogather.RedactURL(source), resolved, content, startedAt)
+	common.Unchanged = true
+	return setCommon(prior, common)
+}
+
+// SidecarPath returns the path of the sidecar metadata file of the gathered destination: the
+// SidecarName file inside a directory, or the file name with the SidecarName suffix otherwise.
+func SidecarPath(destination string) (string, error) {
+	dst, err := gogather.LocalPath(destination)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse destination URI: %w", err)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		return "", fmt.Errorf("failed to determine destination kind: %w", err)
+	}
+	if info.IsDir() {
+		return filepath.Join(dst, SidecarName), nil
+	}
+	return dst + SidecarName, nil
+}
+
+// ReadSidecar returns the metadata persisted by a SidecarGatherer for the destination.
+func ReadSidecar(destination string) (metadata.Metadata, error) {
+	path, err := SidecarPath(destination)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	return metadata.Unmarshal(data)
+}
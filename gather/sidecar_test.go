@@ -0,0 +1,149 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/enterprise-contract/go-gather/gather/file"
+	"github.com/enterprise-contract/go-gather/metadata"
+	ociMetadata "github.com/enterprise-contract/go-gather/metadata/oci"
+)
+
+// TestSidecarGatherer tests persisting and reading back the metadata of gathered files and directories
+func TestSidecarGatherer(t *testing.T) {
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("foo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		source      string
+		destination string
+		sidecar     string
+	}{
+		{"Directory", srcDir, filepath.Join(t.TempDir(), "dir"), filepath.Join("dir", SidecarName)},
+		{"File", filepath.Join(srcDir, "a.txt"), filepath.Join(t.TempDir(), "b.txt"), "b.txt" + SidecarName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewSidecarGatherer(&file.FileGatherer{})
+			m, err := g.Gather(context.Background(), tt.source, tt.destination)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			path, err := SidecarPath(tt.destination)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if expected := filepath.Join(filepath.Dir(tt.destination), tt.sidecar); path != expected {
+				t.Errorf("expected sidecar path %s, got %s", expected, path)
+			}
+			if forced, err := SidecarPath("file::" + tt.destination); err != nil || forced != path {
+				t.Errorf("expected the forced destination to have the sidecar path %s, got %s, %v", path, forced, err)
+			}
+
+			read, err := ReadSidecar(tt.destination)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, want := read.Get()["sourceURI"], m.Get()["sourceURI"]; got != want {
+				t.Errorf("expected source %v, got %v", want, got)
+			}
+			if got, want := read.Get()["path"], m.Get()["path"]; got != want {
+				t.Errorf("expected path %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+// TestReadSidecar_Missing tests reading the metadata of a destination without a sidecar file
+func TestReadSidecar_Missing(t *testing.T) {
+	if _, err := ReadSidecar(t.TempDir()); err == nil {
+		t.Error("expected an error, but got nil")
+	}
+}
+
+// resolvingGatherer writes a file and returns OCI metadata with its digest, which it also resolves
+// sources to.
+type resolvingGatherer struct {
+	digest string
+	calls  int
+}
+
+func (g *resolvingGatherer) Gather(_ context.Context, source, destination string) (metadata.Metadata, error) {
+	g.calls++
+	if err := os.MkdirAll(destination, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(destination, "layer"), []byte(g.digest), 0600); err != nil {
+		return nil, err
+	}
+	return &ociMetadata.OCIMetadata{Common: metadata.Common{Destination: destination, Gatherer: "oci"}, Digest: g.digest}, nil
+}
+
+func (g *resolvingGatherer) Resolve(_ context.Context, source string) (metadata.Metadata, error) {
+	return &ociMetadata.OCIMetadata{Common: metadata.Common{Gatherer: "oci"}, Digest: g.digest}, nil
+}
+
+// TestSidecarGatherer_SkipUnchanged tests skipping gathers of content the destination already holds
+func TestSidecarGatherer_SkipUnchanged(t *testing.T) {
+	ctx := context.Background()
+	destination := t.TempDir()
+	r := &resolvingGatherer{digest: "sha256:" + strings.Repeat("a", 64)}
+	g := &SidecarGatherer{Gatherer: r, SkipUnchanged: true}
+
+	m, err := g.Gather(ctx, "oci::registry.io/repo:latest", destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.calls != 1 || m.Get()["unchanged"] != nil {
+		t.Fatalf("expected a gather, got %d calls and %v", r.calls, m.Get())
+	}
+
+	m, err = g.Gather(ctx, "oci::registry.io/repo:latest", destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.calls != 1 || m.Get()["unchanged"] != true {
+		t.Errorf("expected the gather to be skipped, got %d calls and %v", r.calls, m.Get())
+	}
+	if got := m.Get()["digest"]; got != r.digest {
+		t.Errorf("unexpected digest: %v", got)
+	}
+
+	// Sources pinned to the recorded digest are not resolved.
+	if _, err := g.Gather(ctx, "oci::registry.io/repo@"+r.digest, destination); err != nil || r.calls != 1 {
+		t.Errorf("expected the gather to be skipped, got %d calls and %v", r.calls, err)
+	}
+
+	r.digest = "sha256:" + strings.Repeat("b", 64)
+	m, err = g.Gather(ctx, "oci::registry.io/repo:latest", destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.calls != 2 || m.Get()["unchanged"] != nil {
+		t.Errorf("expected a gather of the changed source, got %d calls and %v", r.calls, m.Get())
+	}
+}
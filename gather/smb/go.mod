@@ -0,0 +1,15 @@
+module github.com/enterprise-contract/go-gather/gather/smb
+
+go 1.22.5
+
+require (
+	github.com/enterprise-contract/go-gather v0.0.3
+	github.com/enterprise-contract/go-gather/metadata v0.0.2
+	github.com/enterprise-contract/go-gather/metadata/smb v0.0.1
+	github.com/hirochachacha/go-smb2 v1.1.0
+)
+
+require (
+	github.com/geoffgarside/ber v1.1.0 // indirect
+	golang.org/x/crypto v0.24.0 // indirect
+)
@@ -0,0 +1,404 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package smb provides functionality for gathering files and directories from SMB/CIFS file
+// shares, e.g. Windows file shares or Samba servers. It includes an implementation of the Gatherer
+// interface, SMBGatherer, which downloads a file, or a directory recursively, to a destination
+// path over SMB 2 or 3.
+//
+// Sources are of the form smb://[[domain;]user[:password]@]server[:port]/share/path, where share
+// is the name of the share and path the file or directory within it. Sessions are authenticated
+// with NTLMv2, as the user of the source, or else with the credentials the Auth provider of the
+// gather options has for the server, or else as the guest account.
+//
+// Example usage:
+//
+//	g := &smb.SMBGatherer{Domain: "CORP"}
+//	m, err := g.Gather(context.Background(), "smb://fileserver.example.com/config/policies", "/tmp/policies")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package smb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hirochachacha/go-smb2"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+	smbMetadata "github.com/enterprise-contract/go-gather/metadata/smb"
+)
+
+// DefaultTimeout is the time allowed for establishing the connection when none is configured.
+const DefaultTimeout = 30 * time.Second
+
+// SMBGatherer downloads files and directories from SMB file shares.
+type SMBGatherer struct {
+	// Domain is the domain the user is authenticated in when neither the source nor the user
+	// name of the credentials, in the DOMAIN\user form, names one.
+	Domain string
+	// Workstation is the name of the workstation reported to the server.
+	Workstation string
+	// Timeout is the time allowed for establishing the connection. Defaults to DefaultTimeout.
+	Timeout time.Duration
+	// Logger receives diagnostic messages. When nil, the logger of the gather options is used.
+	Logger *slog.Logger
+}
+
+// location is a parsed SMB source.
+type location struct {
+	host     string
+	port     string
+	domain   string
+	user     string
+	password *string
+	share    string
+	// path is the slash separated path of the file or directory within the share, or "." for
+	// the root of the share.
+	path string
+}
+
+// Gather downloads the file at source to destination, or, if source is a directory, every file
+// below it into the destination directory that is selected by the Include and Exclude patterns of
+// the gather options. Files other than regular files, e.g. symbolic links, are skipped. A file is
+// saved in the destination under its base name if the destination is an existing directory or
+// ends with a separator. The server is checked against the host policy of the gather options
+// with the "smb" scheme.
+func (g *SMBGatherer) Gather(ctx context.Context, source, destination string) (_ metadata.Metadata, err error) {
+	defer func() {
+		err = gogather.RedactError(err)
+		gogather.FinishProgress(ctx, err)
+	}()
+	startedAt := time.Now()
+
+	loc, err := parseSource(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := gogather.CheckHost(ctx, "smb", loc.host); err != nil {
+		return nil, err
+	}
+
+	c, err := g.connect(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	m := &smbMetadata.SMBMetadata{Share: loc.share, Path: loc.path}
+	if destination, err = g.gatherFS(ctx, c.share.DirFS("."), loc.path, destination, m); err != nil {
+		return nil, err
+	}
+
+	resolved, _ := m.GetPinnedURL(gogather.RedactURL(source))
+	m.Common = metadata.NewCommon(smbMetadata.Type, gogather.RedactURL(source), resolved, destination, startedAt)
+	return m, nil
+}
+
+// gatherFS downloads the file or directory at p of the mounted share fsys to destination,
+// returning the path it was saved to.
+func (g *SMBGatherer) gatherFS(ctx context.Context, fsys fs.FS, p, destination string, m *smbMetadata.SMBMetadata) (string, error) {
+	fi, err := fs.Stat(fsys, p)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat %s: %w", p, err)
+	}
+	m.ModTime = fi.ModTime()
+	if fi.IsDir() {
+		return g.gatherDir(ctx, fsys, p, destination, m)
+	}
+	return g.gatherFile(ctx, fsys, p, destination, m)
+}
+
+// gatherFile downloads the file at p to destination, returning the path it was saved to.
+func (g *SMBGatherer) gatherFile(ctx context.Context, fsys fs.FS, p, destination string, m *smbMetadata.SMBMetadata) (string, error) {
+	if strings.HasSuffix(destination, string(os.PathSeparator)) || strings.HasSuffix(destination, "/") {
+		destination = filepath.Join(destination, path.Base(p))
+	} else if fi, err := os.Stat(destination); err == nil && fi.IsDir() {
+		destination = filepath.Join(destination, path.Base(p))
+	}
+
+	gogather.StartProgress(ctx, -1, 1)
+	h := sha256.New()
+	n, err := g.download(ctx, fsys, p, destination, h)
+	if err != nil {
+		return "", err
+	}
+	if err := gogather.VerifyChecksum(ctx, destination); err != nil {
+		_ = os.Remove(destination)
+		return "", err
+	}
+	m.Files, m.Size, m.SHA256 = 1, n, hex.EncodeToString(h.Sum(nil))
+	return destination, nil
+}
+
+// gatherDir downloads the files below the directory at p into the destination directory.
+func (g *SMBGatherer) gatherDir(ctx context.Context, fsys fs.FS, p, destination string, m *smbMetadata.SMBMetadata) (string, error) {
+	opts := gogather.OptionsFromContext(ctx)
+	filter, err := gogather.NewPathFilter(opts.Include, opts.Exclude)
+	if err != nil {
+		return "", err
+	}
+
+	log := gogather.Logger(ctx, g.Logger)
+	var files []string
+	var total int64
+	err = fs.WalkDir(fsys, p, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %w", name, err)
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			log.Debug("skipping file that is not a regular file", "path", name, "mode", d.Type())
+			return nil
+		}
+		rel := name
+		if p != "." {
+			rel = strings.TrimPrefix(name, p+"/")
+		}
+		if !filter.Match(rel) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", name, err)
+		}
+		files = append(files, rel)
+		total += fi.Size()
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := gogather.CheckWritten(ctx, total); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(destination, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	gogather.StartProgress(ctx, total, len(files))
+	for _, rel := range files {
+		local := filepath.FromSlash(rel)
+		if !filepath.IsLocal(local) {
+			return "", fmt.Errorf("file %s escapes the destination directory", rel)
+		}
+		n, err := g.download(ctx, fsys, path.Join(p, rel), filepath.Join(destination, local), nil)
+		if err != nil {
+			return "", err
+		}
+		m.Size += n
+	}
+	m.Files = len(files)
+
+	if m.TreeHash, err = metadata.TreeHash(destination); err != nil {
+		return "", err
+	}
+	if err := gogather.VerifyChecksumDigest(ctx, destination, "sha256", m.TreeHash); err != nil {
+		return "", err
+	}
+	return destination, nil
+}
+
+// download saves the file at p to the local path, also writing its content to h, if set. It
+// returns the number of bytes downloaded.
+func (g *SMBGatherer) download(ctx context.Context, fsys fs.FS, p, local string, h hash.Hash) (int64, error) {
+	gogather.Logger(ctx, g.Logger).Debug("downloading file", "path", p, "destination", local)
+	src, err := fsys.Open(p)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %w", p, err)
+	}
+	defer src.Close()
+	if fi, err := src.Stat(); err == nil {
+		if err := gogather.CheckWritten(ctx, fi.Size()); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+	dst, err := os.Create(local)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file: %w", err)
+	}
+	var w io.Writer = dst
+	if h != nil {
+		w = io.MultiWriter(dst, h)
+	}
+	n, err := io.Copy(w, gogather.WrapReader(ctx, src))
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		return 0, fmt.Errorf("failed to download %s: %w", p, err)
+	}
+	gogather.CountItems(ctx, 1)
+	return n, nil
+}
+
+// client is a mounted share along with the session and the connection it runs on.
+type client struct {
+	share   *smb2.Share
+	session *smb2.Session
+	conn    net.Conn
+	stop    func() bool
+}
+
+func (c *client) Close() error {
+	c.stop()
+	_ = c.share.Umount()
+	_ = c.session.Logoff()
+	return c.conn.Close()
+}
+
+// connect establishes an authenticated session with the server of loc and mounts its share. The
+// connection is closed when ctx is done.
+func (g *SMBGatherer) connect(ctx context.Context, loc *location) (*client, error) {
+	initiator, err := g.initiator(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := g.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	addr := net.JoinHostPort(loc.host, loc.port)
+	conn, err := (&net.Dialer{Timeout: timeout}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	session, err := (&smb2.Dialer{Initiator: initiator}).DialContext(dialCtx, conn)
+	if err != nil {
+		stop()
+		conn.Close()
+		return nil, fmt.Errorf("failed to establish SMB session with %s: %w", addr, err)
+	}
+	share, err := session.WithContext(ctx).Mount(loc.share)
+	if err != nil {
+		stop()
+		_ = session.Logoff()
+		conn.Close()
+		return nil, fmt.Errorf("failed to mount share %s: %w", loc.share, err)
+	}
+	return &client{share: share.WithContext(ctx), session: session, conn: conn, stop: stop}, nil
+}
+
+// initiator returns the NTLM initiator authenticating the session as the user of the source, or
+// else as the user of the credentials of the gather options for the server, or else as the guest
+// account. The domain is taken from the user name, if it names one, or else from Domain.
+func (g *SMBGatherer) initiator(ctx context.Context, loc *location) (*smb2.NTLMInitiator, error) {
+	i := &smb2.NTLMInitiator{Domain: g.Domain, Workstation: g.Workstation}
+	if loc.user != "" {
+		i.User = loc.user
+		if loc.domain != "" {
+			i.Domain = loc.domain
+		}
+		if loc.password != nil {
+			i.Password = *loc.password
+			return i, nil
+		}
+	}
+
+	creds, err := gogather.OptionsFromContext(ctx).Credentials(ctx, loc.host)
+	if err != nil {
+		return nil, err
+	}
+	if creds != nil && (i.User == "" || strings.EqualFold(userName(creds.Username), i.User)) {
+		if i.User == "" {
+			i.User = creds.Username
+			if domain, user, ok := strings.Cut(creds.Username, `\`); ok {
+				i.Domain, i.User = domain, user
+			}
+		}
+		i.Password = creds.Password
+	}
+	if i.User == "" {
+		i.User = "Guest"
+	}
+	return i, nil
+}
+
+// userName returns the user name without the domain of the DOMAIN\user form.
+func userName(name string) string {
+	if _, user, ok := strings.Cut(name, `\`); ok {
+		return user
+	}
+	return name
+}
+
+// parseSource parses an SMB source into the server, the credentials, the share and the path
+// within the share.
+func parseSource(source string) (*location, error) {
+	u, err := url.Parse(strings.TrimPrefix(source, "smb::"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source %s: %w", gogather.RedactURL(source), err)
+	}
+	if u.Scheme != "smb" {
+		return nil, fmt.Errorf("unsupported SMB source: %s", gogather.RedactURL(source))
+	}
+	if u.RawQuery != "" {
+		return nil, fmt.Errorf("unsupported parameters of %s", gogather.RedactURL(source))
+	}
+
+	share, p, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if u.Hostname() == "" || share == "" {
+		return nil, fmt.Errorf("source must be of the form smb://server/share/path: %s", gogather.RedactURL(source))
+	}
+	p = path.Clean("/" + p)[1:]
+	if p == "" {
+		p = "."
+	}
+
+	loc := &location{host: u.Hostname(), port: u.Port(), share: share, path: p}
+	if loc.port == "" {
+		loc.port = "445"
+	}
+	if u.User != nil {
+		loc.user = u.User.Username()
+		if domain, user, ok := strings.Cut(loc.user, ";"); ok {
+			loc.domain, loc.user = domain, user
+		}
+		if password, ok := u.User.Password(); ok {
+			loc.password = &password
+		}
+	}
+	return loc, nil
+}
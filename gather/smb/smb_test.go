@@ -0,0 +1,223 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package smb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	smbMetadata "github.com/enterprise-contract/go-gather/metadata/smb"
+)
+
+// newShare returns the content of a fake mounted share, with a symbolic link in the policies
+// directory.
+func newShare() fstest.MapFS {
+	modTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	return fstest.MapFS{
+		"policies":                  {Mode: fs.ModeDir | 0755, ModTime: modTime},
+		"policies/main.rego":        {Data: []byte("package main\n"), ModTime: modTime},
+		"policies/lib/util.rego":    {Data: []byte("package lib\n")},
+		"policies/lib/README.md":    {Data: []byte("# lib\n")},
+		"policies/large/blob.bin":   {Data: []byte(strings.Repeat("x", 100))},
+		"policies/link":             {Data: []byte("main.rego"), Mode: fs.ModeSymlink},
+		"settings/environment.yaml": {Data: []byte("env: prod\n"), ModTime: modTime},
+	}
+}
+
+// TestSMBGatherer_gatherFS_File tests downloading a file of a share
+func TestSMBGatherer_gatherFS_File(t *testing.T) {
+	dir := t.TempDir()
+	m := &smbMetadata.SMBMetadata{}
+	destination, err := (&SMBGatherer{}).gatherFS(context.Background(), newShare(), "settings/environment.yaml", dir+"/", m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if destination != filepath.Join(dir, "environment.yaml") {
+		t.Errorf("unexpected destination: %s", destination)
+	}
+	if data, err := os.ReadFile(destination); err != nil || string(data) != "env: prod\n" {
+		t.Fatalf("unexpected content: %q, %v", data, err)
+	}
+	sum := sha256.Sum256([]byte("env: prod\n"))
+	if m.Files != 1 || m.Size != 10 || m.SHA256 != hex.EncodeToString(sum[:]) || m.ModTime.Year() != 2024 || m.TreeHash != "" {
+		t.Errorf("unexpected metadata: %+v", m)
+	}
+}
+
+// TestSMBGatherer_gatherFS_Directory tests downloading the files of a directory selected by the
+// filters, skipping symbolic links
+func TestSMBGatherer_gatherFS_Directory(t *testing.T) {
+	destination := filepath.Join(t.TempDir(), "policies")
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Exclude: []string{"large/**", "**.md"}})
+	m := &smbMetadata.SMBMetadata{}
+	if _, err := (&SMBGatherer{}).gatherFS(ctx, newShare(), "policies", destination, m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, content := range map[string]string{"main.rego": "package main\n", "lib/util.rego": "package lib\n"} {
+		if data, err := os.ReadFile(filepath.Join(destination, name)); err != nil || string(data) != content {
+			t.Errorf("unexpected content of %s: %q, %v", name, data, err)
+		}
+	}
+	for _, name := range []string{"link", "large", "lib/README.md"} {
+		if _, err := os.Lstat(filepath.Join(destination, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be skipped: %v", name, err)
+		}
+	}
+	if m.Files != 2 || m.Size != 25 || m.TreeHash == "" || m.SHA256 != "" {
+		t.Errorf("unexpected metadata: %+v", m)
+	}
+
+	root := filepath.Join(t.TempDir(), "share")
+	if _, err := (&SMBGatherer{}).gatherFS(context.Background(), newShare(), ".", root, &smbMetadata.SMBMetadata{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "settings", "environment.yaml")); err != nil {
+		t.Errorf("expected the root of the share to be downloaded: %v", err)
+	}
+}
+
+// TestSMBGatherer_gatherFS_Errors tests failing downloads
+func TestSMBGatherer_gatherFS_Errors(t *testing.T) {
+	g := &SMBGatherer{}
+	dir := t.TempDir()
+
+	if _, err := g.gatherFS(context.Background(), newShare(), "missing", dir, &smbMetadata.SMBMetadata{}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{MaxSize: 50})
+	if _, err := g.gatherFS(ctx, newShare(), "policies", filepath.Join(dir, "policies"), &smbMetadata.SMBMetadata{}); !errors.Is(err, gogather.ErrTooLarge) {
+		t.Errorf("expected the size limit to be exceeded, got %v", err)
+	}
+
+	var mismatch *gogather.ChecksumMismatchError
+	destination := filepath.Join(dir, "environment.yaml")
+	ctx = gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Checksum: "sha256:" + strings.Repeat("0", 64)})
+	if _, err := g.gatherFS(ctx, newShare(), "settings/environment.yaml", destination, &smbMetadata.SMBMetadata{}); !errors.As(err, &mismatch) {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+	if _, err := os.Stat(destination); !os.IsNotExist(err) {
+		t.Errorf("expected the file to be removed: %v", err)
+	}
+}
+
+// TestSMBGatherer_Gather_Errors tests failing to connect to servers
+func TestSMBGatherer_Gather_Errors(t *testing.T) {
+	g := &SMBGatherer{Timeout: time.Second}
+	dir := t.TempDir()
+
+	var denied *gogather.HostDeniedError
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{HostPolicy: &gogather.HostPolicy{DeniedHosts: []string{"127.0.0.1"}}})
+	if _, err := g.Gather(ctx, "smb://127.0.0.1/config/policies", dir); !errors.As(err, &denied) {
+		t.Errorf("expected the host policy to deny the server, got %v", err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	if _, err := g.Gather(context.Background(), "smb://"+addr+"/config/policies", dir); err == nil || !strings.HasPrefix(err.Error(), "failed to connect to "+addr) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestSMBGatherer_initiator tests choosing the account sessions are authenticated as
+func TestSMBGatherer_initiator(t *testing.T) {
+	auth := gogather.HostCredentials{"fileserver": {Username: `CORP\svc-config`, Password: "secret"}}
+	tests := []struct {
+		name     string
+		source   string
+		auth     gogather.AuthProvider
+		user     string
+		domain   string
+		password string
+	}{
+		{name: "source", source: "smb://CORP;reader:pw@fileserver/config", auth: auth, user: "reader", domain: "CORP", password: "pw"},
+		{name: "credentials", source: "smb://fileserver/config", auth: auth, user: "svc-config", domain: "CORP", password: "secret"},
+		{name: "user of credentials", source: "smb://svc-config@fileserver/config", auth: auth, user: "svc-config", domain: "DEFAULT", password: "secret"},
+		{name: "other user", source: "smb://reader@fileserver/config", auth: auth, user: "reader", domain: "DEFAULT"},
+		{name: "guest", source: "smb://fileserver/config", user: "Guest", domain: "DEFAULT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := parseSource(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Auth: tt.auth})
+			i, err := (&SMBGatherer{Domain: "DEFAULT"}).initiator(ctx, loc)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if i.User != tt.user || i.Domain != tt.domain || i.Password != tt.password {
+				t.Errorf("unexpected account: %s\\%s %s", i.Domain, i.User, i.Password)
+			}
+		})
+	}
+}
+
+// TestParseSource tests parsing SMB sources
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		source string
+		host   string
+		port   string
+		share  string
+		path   string
+		user   string
+		domain string
+		err    string
+	}{
+		{source: "smb://fileserver.example.com/config/policies/main.rego", host: "fileserver.example.com", port: "445", share: "config", path: "policies/main.rego"},
+		{source: "smb://CORP;reader@fileserver:1445/config/policies/", host: "fileserver", port: "1445", share: "config", path: "policies", user: "reader", domain: "CORP"},
+		{source: "smb://fileserver/config", host: "fileserver", port: "445", share: "config", path: "."},
+		{source: "smb://fileserver/config/../../etc", host: "fileserver", port: "445", share: "config", path: "etc"},
+		{source: "smb://fileserver", err: "source must be of the form smb://server/share/path: smb://fileserver"},
+		{source: "smb://fileserver/config?ref=main", err: "unsupported parameters of smb://fileserver/config?ref=main"},
+		{source: "https://fileserver/config", err: "unsupported SMB source: https://fileserver/config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			l, err := parseSource(tt.source)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if l.host != tt.host || l.port != tt.port || l.share != tt.share || l.path != tt.path || l.user != tt.user || l.domain != tt.domain {
+				t.Errorf("unexpected location: %+v", l)
+			}
+		})
+	}
+}
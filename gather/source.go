@@ -0,0 +1,101 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/gather/file"
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// gatherSource gathers a go-getter style source with gatherer. Git handles subdirectories
+// itself; the content of other sources is gathered to a staging directory first, expanded if
+// the archive parameter asks for it and the gatherer cannot do so itself, and the subdirectory
+// is then copied to the destination.
+func gatherSource(ctx context.Context, gatherer Gatherer, protocol gogather.URIType, source string, src *gogather.Source, destination string) (metadata.Metadata, error) {
+	o := gogather.OptionsFromContext(ctx)
+	if protocol == gogather.GitURI {
+		return gatherer.Gather(ctx, (&gogather.Source{Forced: src.Forced, URL: src.URL, Subdir: src.Subdir}).String(), destination)
+	}
+
+	base := baseSource(protocol, src)
+	expand := (protocol == gogather.HTTPURI || protocol == gogather.GitHubReleaseURI || protocol == gogather.GitLabURI || protocol == gogather.GoogleDriveURI) && o.Archive != "" && o.Archive != "false"
+	if src.Subdir == "" && !expand {
+		return gatherer.Gather(ctx, base, destination)
+	}
+	if src.Subdir != "" && !filepath.IsLocal(src.Subdir) {
+		return nil, fmt.Errorf("subdirectory %s escapes the source", src.Subdir)
+	}
+
+	startedAt := time.Now()
+	stage, err := gogather.MkdirTemp(ctx, "go-gather-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(stage)
+
+	m, err := gatherer.Gather(ctx, base, filepath.Join(stage, "source"))
+	if err != nil {
+		return nil, err
+	}
+	fields := m.Get()
+	content, _ := fields["destination"].(string)
+
+	// The copies below must not count towards the size of the gather again.
+	copyOptions := gogather.GatherOptions{Logger: o.Logger, Include: o.Include, Exclude: o.Exclude, Archive: "false"}
+	if expand {
+		expanded := filepath.Join(stage, "expanded")
+		ctx := gogather.ContextWithOptions(ctx, gogather.GatherOptions{Logger: o.Logger, MaxSize: o.MaxSize, Archive: o.Archive})
+		if _, err := (&file.FileGatherer{}).Gather(ctx, content, expanded); err != nil {
+			return nil, err
+		}
+		content = expanded
+	}
+	if src.Subdir != "" {
+		content = filepath.Join(content, src.Subdir)
+		if _, err := os.Stat(content); err != nil {
+			return nil, fmt.Errorf("failed to find subdirectory %s: %w", src.Subdir, err)
+		}
+	}
+
+	result, err := (&file.FileGatherer{}).Gather(gogather.ContextWithOptions(ctx, copyOptions), content, destination)
+	if err != nil {
+		return nil, err
+	}
+
+	gathererName, _ := fields["gatherer"].(string)
+	resolved, _ := fields["resolvedURI"].(string)
+	if resolved != "" {
+		resolved = (&gogather.Source{URL: resolved, Subdir: src.Subdir, Archive: src.Archive, Checksum: src.Checksum}).String()
+	}
+	return setCommon(result, metadata.NewCommon(gathererName, gogather.RedactURL(source), resolved, destination, startedAt)), nil
+}
+
+// baseSource returns src without its subdirectory, archive and checksum, in the form the gatherer
+// of protocol understands. The HTTP gatherer does not understand forced protocol prefixes.
+func baseSource(protocol gogather.URIType, src *gogather.Source) string {
+	if src.Forced != "" && protocol != gogather.HTTPURI {
+		return src.Forced + "::" + src.URL
+	}
+	return src.URL
+}
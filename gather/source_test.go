@@ -0,0 +1,200 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/gather/github"
+	fileMetadata "github.com/enterprise-contract/go-gather/metadata/file"
+)
+
+// writeZip writes a zip archive holding files to path.
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGather_GoGetterSyntax tests gathering sources written in the syntax of hashicorp/go-getter
+func TestGather_GoGetterSyntax(t *testing.T) {
+	ctx := context.Background()
+	archive := filepath.Join(t.TempDir(), "bundle.zip")
+	writeZip(t, archive, map[string]string{"policy/main.rego": "package main", "README.md": "# Bundle"})
+
+	t.Run("FileSubdir", func(t *testing.T) {
+		source := "file::" + archive + "//policy"
+		destination := filepath.Join(t.TempDir(), "out")
+		m, err := Gather(ctx, source, destination)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if b, err := os.ReadFile(filepath.Join(destination, "main.rego")); err != nil || string(b) != "package main" {
+			t.Errorf("expected the subdirectory to be gathered: %q, %v", b, err)
+		}
+		if _, err := os.Stat(filepath.Join(destination, "README.md")); !os.IsNotExist(err) {
+			t.Errorf("expected README.md to be left out: %v", err)
+		}
+		dm, ok := m.(*fileMetadata.DirectoryMetadata)
+		if !ok {
+			t.Fatalf("unexpected metadata type: %T", m)
+		}
+		if dm.SourceURI != source || dm.Destination != destination || dm.ResolvedURI != "file::"+archive+"//policy" || dm.TreeHash == "" {
+			t.Errorf("unexpected metadata: %+v", dm)
+		}
+	})
+
+	t.Run("ArchiveFalse", func(t *testing.T) {
+		destination := filepath.Join(t.TempDir(), "bundle.zip")
+		if _, err := Gather(ctx, archive+"?archive=false", destination); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info, err := os.Stat(destination); err != nil || info.IsDir() {
+			t.Errorf("expected the archive to be copied as is: %v", err)
+		}
+	})
+
+	t.Run("Checksum", func(t *testing.T) {
+		source := filepath.Join(writeSourceDir(t), "main.rego")
+		digest := "512843855fcc92a51c810b1b58e0731c01eac9a6a23c157bfa02aad71edffbe7"
+		if _, err := Gather(ctx, source+"?checksum=sha256:"+digest, filepath.Join(t.TempDir(), "main.rego")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		_, err := Gather(ctx, source+"?checksum=sha256:"+strings.Repeat("0", 64), filepath.Join(t.TempDir(), "main.rego"))
+		var mismatch *gogather.ChecksumMismatchError
+		if !errors.As(err, &mismatch) || mismatch.Actual != digest {
+			t.Errorf("expected a ChecksumMismatchError, got: %v", err)
+		}
+
+		if _, err := Gather(ctx, source+"?checksum=sha256:abc", t.TempDir()); err == nil {
+			t.Error("expected an error for an invalid checksum")
+		}
+	})
+
+	t.Run("HTTPArchive", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.RawQuery != "" {
+				t.Errorf("unexpected query: %s", r.URL.RawQuery)
+			}
+			http.ServeFile(w, r, archive)
+		}))
+		defer server.Close()
+
+		destination := filepath.Join(t.TempDir(), "out")
+		m, err := Gather(ctx, "http::"+server.URL+"/bundle//policy?archive=zip", destination)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if b, err := os.ReadFile(filepath.Join(destination, "main.rego")); err != nil || string(b) != "package main" {
+			t.Errorf("expected the subdirectory of the archive to be gathered: %q, %v", b, err)
+		}
+		if resolved := m.Get()["resolvedURI"]; resolved != server.URL+"/bundle//policy?archive=zip" {
+			t.Errorf("unexpected resolved URI: %v", resolved)
+		}
+		if gatherer := m.Get()["gatherer"]; gatherer != "http" {
+			t.Errorf("unexpected gatherer: %v", gatherer)
+		}
+	})
+
+	t.Run("HTTPPinned", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "package main")
+		}))
+		defer server.Close()
+
+		source := server.URL + "/main.rego"
+		m, err := Gather(ctx, source, filepath.Join(t.TempDir(), "main.rego"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		pinned, err := m.GetPinnedURL(source)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.HasPrefix(pinned, "http::"+source+"?checksum=sha256:") {
+			t.Errorf("unexpected pinned URL: %s", pinned)
+		}
+
+		// The pinned URL can be gathered again, verifying the checksum.
+		destination := filepath.Join(t.TempDir(), "main.rego")
+		if _, err := Gather(ctx, pinned, destination); err != nil {
+			t.Fatalf("failed to gather the pinned URL %s: %v", pinned, err)
+		}
+		if b, err := os.ReadFile(destination); err != nil || string(b) != "package main" {
+			t.Errorf("unexpected content: %q, %v", b, err)
+		}
+	})
+
+	t.Run("GitHubReleaseArchive", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/repos/org/repo/releases/tags/v1.0.0" {
+				fmt.Fprintf(w, `{"id": 1, "tag_name": "v1.0.0", "assets": [{"id": 2, "name": "bundle.zip", "url": "http://%s/assets/2"}]}`, r.Host)
+				return
+			}
+			http.ServeFile(w, r, archive)
+		}))
+		defer server.Close()
+		defer func(g Gatherer) { protocolHandlers["GitHubReleaseURI"] = g }(protocolHandlers["GitHubReleaseURI"])
+		protocolHandlers["GitHubReleaseURI"] = &github.GitHubReleaseGatherer{BaseURL: server.URL}
+
+		destination := filepath.Join(t.TempDir(), "out")
+		m, err := Gather(ctx, "github-release://org/repo/v1.0.0/bundle.zip//policy?archive=zip", destination)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if b, err := os.ReadFile(filepath.Join(destination, "main.rego")); err != nil || string(b) != "package main" {
+			t.Errorf("expected the subdirectory of the asset to be gathered: %q, %v", b, err)
+		}
+		if resolved, _ := m.Get()["resolvedURI"].(string); !strings.HasPrefix(resolved, "github-release://org/repo/v1.0.0/bundle.zip//policy?checksum=sha256:") {
+			t.Errorf("unexpected resolved URI: %v", resolved)
+		}
+	})
+
+	t.Run("SubdirEscape", func(t *testing.T) {
+		_, err := Gather(ctx, archive+"//../etc", t.TempDir())
+		if err == nil || !strings.Contains(err.Error(), "escapes") {
+			t.Errorf("expected an error, got: %v", err)
+		}
+	})
+}
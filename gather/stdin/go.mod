@@ -0,0 +1,12 @@
+module github.com/enterprise-contract/go-gather/gather/stdin
+
+go 1.22.5
+
+require (
+	github.com/enterprise-contract/go-gather v0.0.3
+	github.com/enterprise-contract/go-gather/expander v0.0.1
+	github.com/enterprise-contract/go-gather/metadata v0.0.2
+	github.com/enterprise-contract/go-gather/metadata/stdin v0.0.1
+	github.com/klauspost/compress v1.18.0
+	github.com/ulikunitz/xz v0.5.12
+)
@@ -0,0 +1,342 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package stdin provides functionality for gathering the content piped to standard input, so that
+// go-gather can sit at the end of a shell pipeline. It includes an implementation of the Gatherer
+// interface, StdinGatherer, which saves standard input as the destination file, or expands it
+// into the destination directory if it is an archive.
+//
+// Sources are either "-" or stdin://. Archives are recognized by their content: tarballs, plain
+// or compressed with gzip, bzip2, xz or zstd, zip and 7z archives. The Archive gather option, set
+// with the archive parameter of the source, names the format of the archive instead, or disables
+// the expansion with "false".
+//
+// Example usage:
+//
+//	g := &stdin.StdinGatherer{}
+//	m, err := g.Gather(context.Background(), "-", "/tmp/policy")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package stdin
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/expander"
+	"github.com/enterprise-contract/go-gather/metadata"
+	stdinMetadata "github.com/enterprise-contract/go-gather/metadata/stdin"
+)
+
+// sniffSize is the number of leading bytes of standard input inspected to recognize archives.
+const sniffSize = 64 << 10
+
+// InputError wraps the errors of gathers of standard input. They are never retryable, as the
+// input consumed by the failed gather cannot be read again.
+type InputError struct {
+	Err error
+}
+
+func (e *InputError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports false, see InputError.
+func (e *InputError) Retryable() bool {
+	return false
+}
+
+// StdinGatherer saves the content piped to standard input.
+type StdinGatherer struct {
+	// Reader, if set, is read instead of standard input, e.g. to gather the output of a command
+	// run by the caller.
+	Reader io.Reader
+	// ExpanderOptions configures the expander of archives, e.g. to limit the number of files they
+	// may contain.
+	ExpanderOptions []expander.Option
+	// Logger receives diagnostic messages. When nil, the logger of the gather options is used.
+	Logger *slog.Logger
+}
+
+// Gather reads standard input until its end and saves it as the destination file. If the content
+// is an archive, or the Archive gather option names its format, it is expanded into the destination
+// directory instead, keeping the files selected by the Include and Exclude patterns of the gather
+// options. Tarballs are expanded while they are read; other archives are first written to a
+// scratch directory. Standard input can only be read once, so errors are returned as an
+// InputError, which is not retryable.
+func (g *StdinGatherer) Gather(ctx context.Context, source, destination string) (_ metadata.Metadata, err error) {
+	defer func() {
+		if err != nil {
+			err = &InputError{Err: err}
+		}
+		err = gogather.RedactError(err)
+		gogather.FinishProgress(ctx, err)
+	}()
+	startedAt := time.Now()
+
+	if err := parseSource(source); err != nil {
+		return nil, err
+	}
+	in := g.Reader
+	if in == nil {
+		in = os.Stdin
+	}
+	br := bufio.NewReaderSize(&contextReader{ctx: ctx, r: in}, sniffSize)
+
+	format := gogather.OptionsFromContext(ctx).Archive
+	if format == "" {
+		head, err := br.Peek(sniffSize)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read standard input: %w", err)
+		}
+		format = detectFormat(head)
+	}
+	gogather.Logger(ctx, g.Logger).Debug("reading standard input", "format", format, "destination", destination)
+
+	m := &stdinMetadata.StdinMetadata{}
+	h := sha256.New()
+	r := &hashingReader{r: br, h: h, n: &m.Size}
+	gogather.StartProgress(ctx, -1, -1)
+	if format == "" || format == "false" {
+		if err := g.save(ctx, r, destination); err != nil {
+			return nil, err
+		}
+		m.SHA256 = hex.EncodeToString(h.Sum(nil))
+	} else {
+		m.Format = format
+		digests := gogather.NewDigests()
+		if err := g.expand(ctx, r, format, destination, digests); err != nil {
+			return nil, err
+		}
+		m.SHA256 = hex.EncodeToString(h.Sum(nil))
+		if m.TreeHash, err = metadata.TreeHashWithDigests(destination, digests.SHA256); err != nil {
+			return nil, err
+		}
+		if err := gogather.VerifyChecksumDigest(ctx, destination, "sha256", m.TreeHash); err != nil {
+			return nil, err
+		}
+	}
+
+	resolved, _ := m.GetPinnedURL(gogather.RedactURL(source))
+	m.Common = metadata.NewCommon(stdinMetadata.Type, gogather.RedactURL(source), resolved, destination, startedAt)
+	return m, nil
+}
+
+// save copies r to the destination file and verifies it against the checksum of the gather
+// options.
+func (g *StdinGatherer) save(ctx context.Context, r io.Reader, destination string) error {
+	if strings.HasSuffix(destination, string(os.PathSeparator)) || strings.HasSuffix(destination, "/") {
+		return fmt.Errorf("destination %s must name the file to save standard input as", destination)
+	}
+	if fi, err := os.Stat(destination); err == nil && fi.IsDir() {
+		return fmt.Errorf("destination %s must name the file to save standard input as", destination)
+	}
+	if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	verifier, err := gogather.NewChecksumVerifier(ctx)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(destination)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	_, err = io.Copy(out, verifier.Reader(gogather.WrapReader(ctx, r)))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save standard input: %w", err)
+	}
+	if err := verifier.Verify(destination); err != nil {
+		_ = os.Remove(destination)
+		return err
+	}
+	gogather.CountItems(ctx, 1)
+	return nil
+}
+
+// expand expands the archive of the given format read from r into the destination directory.
+// Archives that cannot be expanded while they are read are written to a scratch directory first.
+// The rest of r, e.g. the padding following the end of a tarball, is read to the end so that the
+// digest of the content is complete. The digests of the expanded files are recorded in digests.
+func (g *StdinGatherer) expand(ctx context.Context, r io.Reader, format, destination string, digests *gogather.Digests) error {
+	e, err := expander.NewExpander(format, g.expanderOptions(ctx, digests)...)
+	if err != nil {
+		return err
+	}
+	if se, ok := e.(expander.StreamExpander); ok {
+		if err := se.ExpandStream(ctx, r, destination, 0755); err != nil {
+			return fmt.Errorf("failed to expand standard input: %w", err)
+		}
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			return fmt.Errorf("failed to read standard input: %w", err)
+		}
+	} else {
+		tmp, err := gogather.MkdirTemp(ctx, "stdin-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		archive := filepath.Join(tmp, "stdin."+format)
+		out, err := os.Create(archive)
+		if err != nil {
+			return fmt.Errorf("failed to create file: %w", err)
+		}
+		_, err = io.Copy(out, r)
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read standard input: %w", err)
+		}
+		if err := e.Expand(destination, archive, true, 0755); err != nil {
+			return fmt.Errorf("failed to expand standard input: %w", err)
+		}
+	}
+	return gogather.CountWrittenDir(ctx, destination)
+}
+
+// expanderOptions returns the ExpanderOptions extended with the filters and the size limit of the
+// gather options carried by ctx, recording the digests of the expanded files in digests.
+func (g *StdinGatherer) expanderOptions(ctx context.Context, digests *gogather.Digests) []expander.Option {
+	o := gogather.OptionsFromContext(ctx)
+	return append(slices.Clone(g.ExpanderOptions), func(c *expander.Config) {
+		c.Options.Digests = digests
+		c.Options.Include = append(c.Options.Include, o.Include...)
+		c.Options.Exclude = append(c.Options.Exclude, o.Exclude...)
+		if o.MaxSize > 0 && (c.FileSizeLimit <= 0 || c.FileSizeLimit > o.MaxSize) {
+			c.FileSizeLimit = o.MaxSize
+		}
+	})
+}
+
+// compressions lists the compression formats tarballs are recognized in, by their magic numbers.
+var compressions = []struct {
+	magic     []byte
+	format    string
+	newReader func(io.Reader) (io.Reader, error)
+}{
+	{[]byte{0x1f, 0x8b}, "tar.gz", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+	{[]byte("BZh"), "tar.bz2", func(r io.Reader) (io.Reader, error) { return bzip2.NewReader(r), nil }},
+	{[]byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, "tar.xz", func(r io.Reader) (io.Reader, error) { return xz.NewReader(r) }},
+	{[]byte{0x28, 0xb5, 0x2f, 0xfd}, "tar.zst", func(r io.Reader) (io.Reader, error) {
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}},
+}
+
+// detectFormat returns the archive format of the content starting with head, or an empty string
+// if it is not an archive. Compressed content is only an archive if it compresses a tarball.
+func detectFormat(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return "zip"
+	case bytes.HasPrefix(head, []byte("7z\xbc\xaf\x27\x1c")):
+		return "7z"
+	case isTar(head):
+		return "tar"
+	}
+	for _, c := range compressions {
+		if !bytes.HasPrefix(head, c.magic) {
+			continue
+		}
+		r, err := c.newReader(bytes.NewReader(head))
+		if err != nil {
+			return ""
+		}
+		if closer, ok := r.(io.Closer); ok {
+			defer closer.Close()
+		}
+		block := make([]byte, 512)
+		n, _ := io.ReadFull(r, block)
+		if isTar(block[:n]) {
+			return c.format
+		}
+		return ""
+	}
+	return ""
+}
+
+// isTar reports whether block starts with the header of a POSIX or GNU tarball.
+func isTar(block []byte) bool {
+	return len(block) >= 262 && string(block[257:262]) == "ustar"
+}
+
+// hashingReader passes the bytes read from r to h and adds their number to n.
+type hashingReader struct {
+	r io.Reader
+	h hash.Hash
+	n *int64
+}
+
+func (r *hashingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	*r.n += int64(n)
+	return n, err
+}
+
+// contextReader fails reads with the context error once the context is done, so that a gather
+// reading a pipe that is slow to close can be cancelled between reads.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// parseSource checks that source is "-" or stdin://, optionally prefixed with "stdin::".
+func parseSource(source string) error {
+	switch strings.TrimPrefix(source, "stdin::") {
+	case "-", "stdin://":
+		return nil
+	}
+	return fmt.Errorf("unsupported stdin source: %s, expected - or stdin://", gogather.RedactURL(source))
+}
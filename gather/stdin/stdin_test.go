@@ -0,0 +1,284 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package stdin
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	stdinMetadata "github.com/enterprise-contract/go-gather/metadata/stdin"
+)
+
+// files are the files of the archives of the tests.
+var files = map[string]string{
+	"policy/main.rego": "package main\n",
+	"README.md":        "# Policies\n",
+}
+
+// newTar returns a tarball of files.
+func newTar(t *testing.T) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// compress returns data compressed with the writer w returns.
+func compress(t *testing.T, data []byte, w func(io.Writer) (io.WriteCloser, error)) []byte {
+	var buf bytes.Buffer
+	cw, err := w(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := cw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gzipWriter(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil }
+
+// newZip returns a zip archive of files.
+func newZip(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// checkFiles checks that dir holds files.
+func checkFiles(t *testing.T, dir string) {
+	t.Helper()
+	for name, content := range files {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(data) != content {
+			t.Errorf("unexpected content of %s: %q, %v", name, data, err)
+		}
+	}
+}
+
+// TestStdinGatherer_Gather tests saving standard input as a file
+func TestStdinGatherer_Gather(t *testing.T) {
+	destination := filepath.Join(t.TempDir(), "data", "policy.json")
+	g := &StdinGatherer{Reader: strings.NewReader(`{"rules":[]}`)}
+
+	m, err := g.Gather(context.Background(), "-", destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, err := os.ReadFile(destination); err != nil || string(data) != `{"rules":[]}` {
+		t.Fatalf("unexpected content: %q, %v", data, err)
+	}
+	sum := sha256.Sum256([]byte(`{"rules":[]}`))
+	sm := m.(*stdinMetadata.StdinMetadata)
+	if sm.Size != 12 || sm.SHA256 != hex.EncodeToString(sum[:]) || sm.Format != "" || sm.TreeHash != "" {
+		t.Errorf("unexpected metadata: %+v", sm)
+	}
+	if sm.ResolvedURI != "-?checksum=sha256:"+sm.SHA256 || sm.Destination != destination {
+		t.Errorf("unexpected metadata: %+v", sm)
+	}
+}
+
+// TestStdinGatherer_Gather_Archive tests expanding archives piped to standard input
+func TestStdinGatherer_Gather_Archive(t *testing.T) {
+	tarball := newTar(t)
+	tests := []struct {
+		name    string
+		data    []byte
+		archive string
+		format  string
+	}{
+		{name: "tar", data: tarball, format: "tar"},
+		{name: "tar.gz", data: compress(t, tarball, gzipWriter), format: "tar.gz"},
+		{name: "tar.xz", data: compress(t, tarball, func(w io.Writer) (io.WriteCloser, error) { return xz.NewWriter(w) }), format: "tar.xz"},
+		{name: "tar.zst", data: compress(t, tarball, func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) }), format: "tar.zst"},
+		{name: "zip", data: newZip(t), format: "zip"},
+		{name: "named format", data: compress(t, tarball, gzipWriter), archive: "tgz", format: "tgz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			destination := filepath.Join(t.TempDir(), "policies")
+			ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Archive: tt.archive})
+			m, err := (&StdinGatherer{Reader: bytes.NewReader(tt.data)}).Gather(ctx, "stdin://", destination)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			checkFiles(t, destination)
+			sum := sha256.Sum256(tt.data)
+			sm := m.(*stdinMetadata.StdinMetadata)
+			if sm.Format != tt.format || sm.Size != int64(len(tt.data)) || sm.SHA256 != hex.EncodeToString(sum[:]) || sm.TreeHash == "" {
+				t.Errorf("unexpected metadata: %+v", sm)
+			}
+		})
+	}
+}
+
+// TestStdinGatherer_Gather_Filter tests selecting the files of an archive to expand
+func TestStdinGatherer_Gather_Filter(t *testing.T) {
+	destination := t.TempDir()
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Include: []string{"**.rego"}})
+	if _, err := (&StdinGatherer{Reader: bytes.NewReader(newTar(t))}).Gather(ctx, "-", destination); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(destination, "policy", "main.rego")); err != nil {
+		t.Errorf("expected main.rego to be expanded: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(destination, "README.md")); !os.IsNotExist(err) {
+		t.Errorf("expected README.md to be excluded, got %v", err)
+	}
+}
+
+// TestStdinGatherer_Gather_NoArchive tests saving archives and compressed files as they are
+func TestStdinGatherer_Gather_NoArchive(t *testing.T) {
+	for name, tt := range map[string]struct {
+		data    []byte
+		archive string
+	}{
+		"disabled":        {data: compress(t, newTar(t), gzipWriter), archive: "false"},
+		"compressed file": {data: compress(t, []byte("plain text"), gzipWriter)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			destination := filepath.Join(t.TempDir(), "out.gz")
+			ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Archive: tt.archive})
+			if _, err := (&StdinGatherer{Reader: bytes.NewReader(tt.data)}).Gather(ctx, "-", destination); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if data, err := os.ReadFile(destination); err != nil || !bytes.Equal(data, tt.data) {
+				t.Errorf("unexpected content: %v", err)
+			}
+		})
+	}
+}
+
+// TestStdinGatherer_Gather_Errors tests the failures of gathering standard input
+func TestStdinGatherer_Gather_Errors(t *testing.T) {
+	dir := t.TempDir()
+	g := func(data string) *StdinGatherer { return &StdinGatherer{Reader: strings.NewReader(data)} }
+	ctx := func(o gogather.GatherOptions) context.Context {
+		return gogather.ContextWithOptions(context.Background(), o)
+	}
+
+	if _, err := g("data").Gather(context.Background(), "-", dir); err == nil {
+		t.Error("expected an error for a directory destination")
+	}
+	if _, err := g("data").Gather(context.Background(), "file:///dev/stdin", filepath.Join(dir, "out")); err == nil {
+		t.Error("expected an error for an unsupported source")
+	}
+	if _, err := g("0123456789").Gather(ctx(gogather.GatherOptions{MaxSize: 5}), "-", filepath.Join(dir, "large")); !errors.Is(err, gogather.ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	if _, err := g(string(newTar(t))).Gather(ctx(gogather.GatherOptions{MaxSize: 5}), "-", filepath.Join(dir, "large-archive")); !errors.Is(err, gogather.ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	var mismatch *gogather.ChecksumMismatchError
+	if _, err := g("data").Gather(ctx(gogather.GatherOptions{Checksum: "sha256:" + strings.Repeat("0", 64)}), "-", filepath.Join(dir, "mismatch")); !errors.As(err, &mismatch) {
+		t.Errorf("expected ChecksumMismatchError, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "mismatch")); !os.IsNotExist(err) {
+		t.Errorf("expected the mismatching file to be removed, got %v", err)
+	}
+	var inputErr *InputError
+	if _, err := g("\x1f\x8b truncated").Gather(ctx(gogather.GatherOptions{Archive: "tar.gz"}), "-", filepath.Join(dir, "invalid")); !errors.As(err, &inputErr) || inputErr.Retryable() {
+		t.Errorf("expected a non-retryable InputError, got %v", err)
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := g("data").Gather(canceled, "-", filepath.Join(dir, "canceled")); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+// TestDetectFormat tests recognizing archives by their content
+func TestDetectFormat(t *testing.T) {
+	tarball := newTar(t)
+	tests := []struct {
+		name     string
+		head     []byte
+		expected string
+	}{
+		{name: "tar", head: tarball, expected: "tar"},
+		{name: "gzip tarball", head: compress(t, tarball, gzipWriter), expected: "tar.gz"},
+		{name: "truncated gzip tarball", head: compress(t, tarball, gzipWriter)[:20], expected: ""},
+		{name: "zip", head: newZip(t), expected: "zip"},
+		{name: "7z", head: []byte("7z\xbc\xaf\x27\x1c\x00\x04"), expected: "7z"},
+		{name: "bzip2 garbage", head: []byte("BZh9 not really"), expected: ""},
+		{name: "text", head: []byte("package main\n"), expected: ""},
+		{name: "empty", head: nil, expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectFormat(tt.head); got != tt.expected {
+				t.Errorf("unexpected format: got %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestParseSource tests parsing stdin sources
+func TestParseSource(t *testing.T) {
+	for source, valid := range map[string]bool{
+		"-":             true,
+		"stdin://":      true,
+		"stdin::-":      true,
+		"stdin://file":  false,
+		"-?archive=zip": false,
+		"/dev/stdin":    false,
+		"":              false,
+	} {
+		if err := parseSource(source); (err == nil) != valid {
+			t.Errorf("unexpected result for %q: %v", source, err)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package torrent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// maxBencodeDepth bounds the nesting of bencoded lists and dictionaries, so that hostile input
+// cannot exhaust the stack.
+const maxBencodeDepth = 32
+
+// decodeBencode decodes the bencoded value at the start of data, returning it along with the
+// number of bytes it spans. Integers are decoded as int64, strings as string, lists as []any and
+// dictionaries as map[string]any. Data may continue after the value, e.g. with the payload of a
+// metadata message.
+func decodeBencode(data []byte) (any, int, error) {
+	return decodeValue(data, 0, 0)
+}
+
+func decodeValue(data []byte, pos, depth int) (any, int, error) {
+	if pos >= len(data) {
+		return nil, 0, errors.New("unexpected end of bencoded data")
+	}
+	if depth > maxBencodeDepth {
+		return nil, 0, errors.New("bencoded data is nested too deeply")
+	}
+	switch c := data[pos]; {
+	case c == 'i':
+		end := bytes.IndexByte(data[pos:], 'e')
+		if end < 0 {
+			return nil, 0, errors.New("unterminated bencoded integer")
+		}
+		n, err := strconv.ParseInt(string(data[pos+1:pos+end]), 10, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid bencoded integer: %w", err)
+		}
+		return n, pos + end + 1, nil
+	case c >= '0' && c <= '9':
+		s, next, err := decodeString(data, pos)
+		return s, next, err
+	case c == 'l':
+		list := []any{}
+		pos++
+		for pos < len(data) && data[pos] != 'e' {
+			v, next, err := decodeValue(data, pos, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			list = append(list, v)
+			pos = next
+		}
+		if pos >= len(data) {
+			return nil, 0, errors.New("unterminated bencoded list")
+		}
+		return list, pos + 1, nil
+	case c == 'd':
+		dict := map[string]any{}
+		pos++
+		for pos < len(data) && data[pos] != 'e' {
+			key, next, err := decodeString(data, pos)
+			if err != nil {
+				return nil, 0, err
+			}
+			v, next, err := decodeValue(data, next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			dict[key] = v
+			pos = next
+		}
+		if pos >= len(data) {
+			return nil, 0, errors.New("unterminated bencoded dictionary")
+		}
+		return dict, pos + 1, nil
+	default:
+		return nil, 0, fmt.Errorf("invalid bencoded value starting with %q", c)
+	}
+}
+
+// decodeString decodes the bencoded string at pos, of the form <length>:<bytes>.
+func decodeString(data []byte, pos int) (string, int, error) {
+	colon := bytes.IndexByte(data[pos:], ':')
+	if colon < 0 {
+		return "", 0, errors.New("invalid bencoded string")
+	}
+	n, err := strconv.Atoi(string(data[pos : pos+colon]))
+	if err != nil || n < 0 {
+		return "", 0, fmt.Errorf("invalid bencoded string length %q", data[pos:pos+colon])
+	}
+	start := pos + colon + 1
+	if n > len(data)-start {
+		return "", 0, errors.New("bencoded string exceeds the data")
+	}
+	return string(data[start : start+n]), start + n, nil
+}
+
+// rawDictValue returns the bencoded bytes of the value of key in the bencoded dictionary data,
+// e.g. the info dictionary of a metainfo file, whose SHA-1 digest is the infohash of the torrent.
+func rawDictValue(data []byte, key string) ([]byte, error) {
+	if len(data) == 0 || data[0] != 'd' {
+		return nil, errors.New("bencoded data is not a dictionary")
+	}
+	pos := 1
+	for pos < len(data) && data[pos] != 'e' {
+		k, start, err := decodeString(data, pos)
+		if err != nil {
+			return nil, err
+		}
+		_, end, err := decodeValue(data, start, 1)
+		if err != nil {
+			return nil, err
+		}
+		if k == key {
+			return data[start:end], nil
+		}
+		pos = end
+	}
+	return nil, fmt.Errorf("dictionary has no %s key", key)
+}
+
+// encodeBencode encodes v, a string, []byte, int, int64, []any or map[string]any, as bencode.
+// Dictionary keys are sorted, as the encoding requires.
+func encodeBencode(v any) []byte {
+	var buf bytes.Buffer
+	writeBencode(&buf, v)
+	return buf.Bytes()
+}
+
+func writeBencode(buf *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case string:
+		buf.WriteString(strconv.Itoa(len(v)) + ":" + v)
+	case []byte:
+		buf.WriteString(strconv.Itoa(len(v)) + ":")
+		buf.Write(v)
+	case int:
+		buf.WriteString("i" + strconv.Itoa(v) + "e")
+	case int64:
+		buf.WriteString("i" + strconv.FormatInt(v, 10) + "e")
+	case []any:
+		buf.WriteByte('l')
+		for _, e := range v {
+			writeBencode(buf, e)
+		}
+		buf.WriteByte('e')
+	case map[string]any:
+		buf.WriteByte('d')
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			writeBencode(buf, k)
+			writeBencode(buf, v[k])
+		}
+		buf.WriteByte('e')
+	default:
+		panic(fmt.Sprintf("cannot bencode %T", v))
+	}
+}
+
+// dictInt returns the integer value of key in d, and whether it is an integer.
+func dictInt(d map[string]any, key string) (int64, bool) {
+	n, ok := d[key].(int64)
+	return n, ok
+}
+
+// dictString returns the string value of key in d, and whether it is a string.
+func dictString(d map[string]any, key string) (string, bool) {
+	s, ok := d[key].(string)
+	return s, ok
+}
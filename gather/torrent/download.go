@@ -0,0 +1,579 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package torrent
+
+import (
+	"context"
+	"crypto/sha1" // #nosec G505 -- BitTorrent v1 identifies torrents and pieces by SHA-1
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+const (
+	// maxRequests is the number of block requests kept outstanding with a peer.
+	maxRequests = 16
+	// maxStalls is the number of rounds of connecting to the peers of a torrent that may pass
+	// without progress before the download fails.
+	maxStalls = 3
+)
+
+var (
+	// peerTimeout is how long a peer may leave requests unanswered before it is dropped.
+	peerTimeout = 2 * time.Minute
+	// retryDelay is the delay between the rounds of connecting to the peers of a torrent.
+	retryDelay = 5 * time.Second
+)
+
+// errBadPiece is returned for pieces that do not match their digest.
+var errBadPiece = errors.New("piece does not match its digest")
+
+// storage writes the pieces of a torrent to its files that are selected.
+type storage struct {
+	info *info
+	// paths are the local paths of the files of the torrent, empty for those not selected.
+	paths []string
+
+	mu    sync.Mutex
+	files map[int]*os.File
+}
+
+// newStorage returns the storage of the files of in selected by selected, saved as the file
+// destination for single-file torrents or below the directory destination otherwise. Selected
+// empty files are created right away, as no piece covers them.
+func newStorage(in *info, destination string, selected []bool) (*storage, error) {
+	s := &storage{info: in, paths: make([]string, len(in.files)), files: map[int]*os.File{}}
+	for i, f := range in.files {
+		switch {
+		case !selected[i]:
+			continue
+		case in.multi:
+			s.paths[i] = filepath.Join(destination, filepath.FromSlash(f.path))
+		default:
+			s.paths[i] = destination
+		}
+		if f.length == 0 {
+			if _, err := s.open(i); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// open returns the open file i, creating it if needed.
+func (s *storage) open(i int) (*os.File, error) {
+	if f, ok := s.files[i]; ok {
+		return f, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.paths[i]), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	f, err := os.OpenFile(s.paths[i], os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	s.files[i] = f
+	return f, nil
+}
+
+// segments calls fn for each part of the files of the torrent covered by length bytes at begin of
+// piece index, passing the index of the file, the offset within the file and the offset within
+// the piece.
+func (s *storage) segments(index int, begin, length int64, fn func(file int, fileOffset, pieceOffset, n int64) error) error {
+	start := int64(index)*s.info.pieceLength + begin
+	end := start + length
+	for i, f := range s.info.files {
+		lo, hi := max(start, f.offset), min(end, f.offset+f.length)
+		if lo >= hi {
+			continue
+		}
+		if err := fn(i, lo-f.offset, lo-start, hi-lo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wanted reports whether piece index covers a selected file.
+func (s *storage) wanted(index int) bool {
+	want := false
+	_ = s.segments(index, 0, s.info.pieceSize(index), func(file int, _, _, _ int64) error {
+		want = want || s.paths[file] != ""
+		return nil
+	})
+	return want
+}
+
+// writePiece writes the parts of the verified piece index covering selected files, returning the
+// number of bytes written.
+func (s *storage) writePiece(index int, data []byte) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var written int64
+	err := s.segments(index, 0, int64(len(data)), func(file int, fileOffset, pieceOffset, n int64) error {
+		if s.paths[file] == "" {
+			return nil
+		}
+		f, err := s.open(file)
+		if err != nil {
+			return err
+		}
+		if _, err := f.WriteAt(data[pieceOffset:pieceOffset+n], fileOffset); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+		written += n
+		return nil
+	})
+	return written, err
+}
+
+// readBlock reads length bytes at begin of the downloaded piece index, for uploading it. It fails
+// if the piece covers files that are not selected.
+func (s *storage) readBlock(index int, begin, length int64) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data := make([]byte, length)
+	err := s.segments(index, begin, length, func(file int, fileOffset, pieceOffset, n int64) error {
+		if s.paths[file] == "" {
+			return errors.New("piece covers a file that is not gathered")
+		}
+		f, err := s.open(file)
+		if err != nil {
+			return err
+		}
+		_, err = f.ReadAt(data[pieceOffset:pieceOffset+n], fileOffset)
+		return err
+	})
+	return data, err
+}
+
+// close closes the open files.
+func (s *storage) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for i, f := range s.files {
+		errs = append(errs, f.Close())
+		delete(s.files, i)
+	}
+	return errors.Join(errs...)
+}
+
+// swarm downloads the pieces of a torrent from its peers.
+type swarm struct {
+	g        *TorrentGatherer
+	info     *info
+	storage  *storage
+	infoHash [sha1.Size]byte
+	peerID   [sha1.Size]byte
+	trackers []string
+	peers    []string
+
+	mu sync.Mutex
+	// wanted, have and pending record the pieces to download, those downloaded and those being
+	// downloaded.
+	wanted, have, pending []bool
+	remaining             int
+	downloaded, uploaded  int64
+	// progressed is set when a piece is downloaded in the current round.
+	progressed bool
+	// sources are the addresses of the peers pieces were downloaded from.
+	sources map[string]bool
+	conns   map[*peerConn]bool
+	// cancel ends the current round, e.g. once the download completes.
+	cancel context.CancelFunc
+	err    error
+}
+
+// newSwarm returns a swarm downloading the pieces of in covering the files selected in st.
+func newSwarm(g *TorrentGatherer, in *info, st *storage, infoHash, peerID [sha1.Size]byte, trackers, peers []string) *swarm {
+	s := &swarm{
+		g: g, info: in, storage: st, infoHash: infoHash, peerID: peerID, trackers: trackers, peers: peers,
+		wanted: make([]bool, len(in.pieces)), have: make([]bool, len(in.pieces)), pending: make([]bool, len(in.pieces)),
+		sources: map[string]bool{}, conns: map[*peerConn]bool{},
+	}
+	for i := range in.pieces {
+		if st.wanted(i) {
+			s.wanted[i] = true
+			s.remaining++
+		}
+	}
+	return s
+}
+
+// run downloads the wanted pieces in rounds: each round announces to the trackers and connects to
+// the peers they return, and to the static peers, until they have nothing more to offer. The
+// download fails once maxStalls rounds in a row download nothing.
+func (s *swarm) run(ctx context.Context) error {
+	stalls := 0
+	event := "started"
+	for s.remaining > 0 {
+		addrs, discoverErr := s.discover(ctx, event)
+		event = ""
+
+		round, cancel := context.WithCancel(ctx)
+		s.mu.Lock()
+		s.progressed, s.cancel = false, cancel
+		s.mu.Unlock()
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, s.g.maxPeers())
+		for _, addr := range addrs {
+			wg.Add(1)
+			go func(addr string) {
+				defer wg.Done()
+				select {
+				case sem <- struct{}{}:
+				case <-round.Done():
+					return
+				}
+				defer func() { <-sem }()
+				s.servePeer(round, addr)
+			}(addr)
+		}
+		wg.Wait()
+		cancel()
+
+		s.mu.Lock()
+		err, progressed, remaining := s.err, s.progressed, s.remaining
+		s.mu.Unlock()
+		switch {
+		case err != nil:
+			return err
+		case remaining == 0:
+			return nil
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case progressed:
+			stalls = 0
+		default:
+			stalls++
+		}
+		if stalls >= maxStalls {
+			if len(addrs) == 0 && discoverErr != nil {
+				return fmt.Errorf("failed to find peers: %w", discoverErr)
+			}
+			return fmt.Errorf("download stalled with %d of %d pieces missing", remaining, len(s.info.pieces))
+		}
+		select {
+		case <-time.After(retryDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// discover returns the addresses of the peers of the swarm, announcing the state of the download
+// with event.
+func (s *swarm) discover(ctx context.Context, event string) ([]string, error) {
+	s.mu.Lock()
+	req := announceRequest{infoHash: s.infoHash, peerID: s.peerID, downloaded: s.downloaded, uploaded: s.uploaded, event: event}
+	for i, want := range s.wanted {
+		if want && !s.have[i] {
+			req.left += s.info.pieceSize(i)
+		}
+	}
+	s.mu.Unlock()
+	return s.g.discover(ctx, req, s.trackers, s.peers)
+}
+
+// pick reserves a wanted piece that has not been downloaded, is not being downloaded and that the
+// peer has.
+func (s *swarm) pick(has []bool) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.wanted {
+		if s.wanted[i] && !s.have[i] && !s.pending[i] && has[i] {
+			s.pending[i] = true
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// needs reports whether the peer has a wanted piece that has not been downloaded.
+func (s *swarm) needs(has []bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.wanted {
+		if s.wanted[i] && !s.have[i] && has[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// release returns the reserved piece index to the pieces to download.
+func (s *swarm) release(index int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[index] = false
+}
+
+// store verifies the downloaded piece index and writes it to the storage. Once all wanted pieces
+// are stored, the round ends, or, when seeding, ends after the seeding duration.
+func (s *swarm) store(ctx context.Context, addr string, index int, data []byte) error {
+	if sha1.Sum(data) != s.info.pieces[index] {
+		s.release(index)
+		return errBadPiece
+	}
+	n, err := s.storage.writePiece(index, data)
+	if err == nil {
+		err = gogather.CountWritten(ctx, n)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.fail(err)
+		return err
+	}
+	s.pending[index] = false
+	s.have[index] = true
+	s.remaining--
+	s.downloaded += int64(len(data))
+	s.progressed = true
+	s.sources[addr] = true
+	if s.g.Seed > 0 {
+		payload := binary.BigEndian.AppendUint32(nil, uint32(index))
+		for p := range s.conns {
+			_ = p.send(msgHave, payload)
+		}
+	}
+	if s.remaining == 0 {
+		if s.g.Seed > 0 {
+			time.AfterFunc(s.g.Seed, s.cancel)
+		} else {
+			s.cancel()
+		}
+	}
+	return nil
+}
+
+// fail records the error ending the download and ends the round. It must be called with mu held.
+func (s *swarm) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+	s.cancel()
+}
+
+// pieceDownload is a piece being downloaded from a peer.
+type pieceDownload struct {
+	index       int
+	data        []byte
+	received    []bool
+	requested   int64
+	outstanding int
+	missing     int
+}
+
+func newPieceDownload(index int, size int64) *pieceDownload {
+	blocks := int((size + blockSize - 1) / blockSize)
+	return &pieceDownload{index: index, data: make([]byte, size), received: make([]bool, blocks), missing: blocks}
+}
+
+// requestMore requests the next blocks of the piece, keeping maxRequests outstanding.
+func (d *pieceDownload) requestMore(p *peerConn) error {
+	for d.outstanding < maxRequests && d.requested < int64(len(d.data)) {
+		length := min(blockSize, int64(len(d.data))-d.requested)
+		if err := p.sendRequest(msgRequest, d.index, d.requested, length); err != nil {
+			return err
+		}
+		d.requested += length
+		d.outstanding++
+	}
+	return nil
+}
+
+// receive stores the block at begin of the piece, reporting whether it was requested.
+func (d *pieceDownload) receive(begin int64, block []byte) bool {
+	if begin%blockSize != 0 || begin >= d.requested || begin+int64(len(block)) > int64(len(d.data)) {
+		return false
+	}
+	b := int(begin / blockSize)
+	if d.received[b] || int64(len(block)) != min(blockSize, int64(len(d.data))-begin) {
+		return false
+	}
+	copy(d.data[begin:], block)
+	d.received[b] = true
+	d.outstanding--
+	d.missing--
+	return true
+}
+
+// servePeer downloads pieces from the peer at addr until it has nothing more to offer, fails or
+// the round ends. When seeding, it also uploads the downloaded pieces the peer requests.
+func (s *swarm) servePeer(ctx context.Context, addr string) {
+	log := gogather.Logger(ctx, s.g.Logger).With("peer", addr)
+	p, err := dialPeer(ctx, addr, s.infoHash, s.peerID)
+	if err != nil {
+		log.Debug("failed to connect to peer", "error", err)
+		return
+	}
+	defer p.close()
+	stop := context.AfterFunc(ctx, p.close)
+	defer stop()
+
+	s.mu.Lock()
+	s.conns[p] = true
+	var bitfield []byte
+	if s.g.Seed > 0 {
+		bitfield = make([]byte, (len(s.have)+7)/8)
+		for i, have := range s.have {
+			if have {
+				bitfield[i/8] |= 0x80 >> (i % 8)
+			}
+		}
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, p)
+		s.mu.Unlock()
+	}()
+
+	msgs := make(chan message, maxRequests)
+	go p.readLoop(msgs)
+	if bitfield != nil {
+		_ = p.send(msgBitfield, bitfield)
+	}
+	if err := p.send(msgInterested, nil); err != nil {
+		return
+	}
+
+	has := make([]bool, len(s.info.pieces))
+	known, choked, unchoked := false, true, false
+	var cur *pieceDownload
+	defer func() {
+		if cur != nil {
+			s.release(cur.index)
+		}
+	}()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	last := time.Now()
+
+	for {
+		if cur == nil && !choked {
+			if i, ok := s.pick(has); ok {
+				cur, last = newPieceDownload(i, s.info.pieceSize(i)), time.Now()
+			}
+		}
+		if cur != nil {
+			if err := cur.requestMore(p); err != nil {
+				return
+			}
+		} else if known && s.g.Seed == 0 && !s.needs(has) {
+			return
+		}
+
+		var m message
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if time.Since(last) > peerTimeout {
+				log.Debug("dropping unresponsive peer")
+				return
+			}
+			continue
+		case m, ok = <-msgs:
+			if !ok {
+				return
+			}
+		}
+
+		switch m.id {
+		case msgChoke:
+			choked = true
+			if cur != nil {
+				s.release(cur.index)
+				cur = nil
+			}
+		case msgUnchoke:
+			choked = false
+		case msgInterested:
+			if s.g.Seed > 0 && !unchoked {
+				unchoked = p.send(msgUnchoke, nil) == nil
+			}
+		case msgHave:
+			if len(m.payload) == 4 {
+				if i := binary.BigEndian.Uint32(m.payload); int(i) < len(has) {
+					has[i], known = true, true
+				}
+			}
+		case msgBitfield:
+			for i := range has {
+				if i/8 < len(m.payload) && m.payload[i/8]&(0x80>>(i%8)) != 0 {
+					has[i] = true
+				}
+			}
+			known = true
+		case msgRequest:
+			if unchoked && len(m.payload) == 12 {
+				s.upload(p, m.payload)
+			}
+		case msgPiece:
+			if cur == nil || len(m.payload) < 8 || int(binary.BigEndian.Uint32(m.payload)) != cur.index {
+				continue
+			}
+			if !cur.receive(int64(binary.BigEndian.Uint32(m.payload[4:])), m.payload[8:]) {
+				continue
+			}
+			last = time.Now()
+			if cur.missing > 0 {
+				continue
+			}
+			index, data := cur.index, cur.data
+			cur = nil
+			if err := s.store(ctx, addr, index, data); err != nil {
+				log.Debug("failed to store piece", "piece", index, "error", err)
+				return
+			}
+		}
+	}
+}
+
+// upload sends the block a peer requests with payload, if it has been downloaded.
+func (s *swarm) upload(p *peerConn, payload []byte) {
+	index := int(binary.BigEndian.Uint32(payload))
+	begin := int64(binary.BigEndian.Uint32(payload[4:]))
+	length := int64(binary.BigEndian.Uint32(payload[8:]))
+	s.mu.Lock()
+	have := index < len(s.have) && s.have[index]
+	s.mu.Unlock()
+	if !have || length <= 0 || length > 2*blockSize || begin+length > s.info.pieceSize(index) {
+		return
+	}
+	block, err := s.storage.readBlock(index, begin, length)
+	if err != nil {
+		return
+	}
+	if p.send(msgPiece, append(payload[:8:8], block...)) == nil {
+		s.mu.Lock()
+		s.uploaded += length
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,9 @@
+module github.com/enterprise-contract/go-gather/gather/torrent
+
+go 1.22.5
+
+require (
+	github.com/enterprise-contract/go-gather v0.0.3
+	github.com/enterprise-contract/go-gather/metadata v0.0.2
+	github.com/enterprise-contract/go-gather/metadata/torrent v0.0.1
+)
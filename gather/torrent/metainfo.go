@@ -0,0 +1,257 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package torrent
+
+import (
+	"crypto/sha1" // #nosec G505 -- BitTorrent v1 identifies torrents and pieces by SHA-1
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// maxPieceLength bounds the length of the pieces of a torrent, so that hostile metadata cannot
+// make the gatherer allocate huge buffers.
+const maxPieceLength = 64 << 20
+
+// fileEntry is a file of a torrent.
+type fileEntry struct {
+	// path is the slash separated path of the file, relative to the directory of a multi-file
+	// torrent, or its name for a single-file torrent.
+	path string
+	// length is the size of the file in bytes.
+	length int64
+	// offset is the offset of the file in the concatenation of the files of the torrent, which
+	// the pieces are cut from.
+	offset int64
+	// pad marks padding files, which only align the next file to a piece and are not saved.
+	pad bool
+}
+
+// info is the info dictionary of a torrent, which describes its files and pieces.
+type info struct {
+	name        string
+	pieceLength int64
+	pieces      [][sha1.Size]byte
+	files       []fileEntry
+	// length is the total size of the files.
+	length int64
+	// multi is set for torrents holding a directory of files.
+	multi bool
+}
+
+// pieceSize returns the length of piece i, which is shorter than pieceLength for the last piece.
+func (in *info) pieceSize(i int) int64 {
+	return min(in.pieceLength, in.length-int64(i)*in.pieceLength)
+}
+
+// parseInfo parses the bencoded info dictionary raw, checking that its files stay within the
+// destination and that its pieces cover them.
+func parseInfo(raw []byte) (*info, error) {
+	v, n, err := decodeBencode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode torrent info: %w", err)
+	}
+	d, ok := v.(map[string]any)
+	if !ok || n != len(raw) {
+		return nil, errors.New("torrent info is not a dictionary")
+	}
+
+	in := &info{}
+	in.name, _ = dictString(d, "name")
+	if !validName(in.name) {
+		return nil, fmt.Errorf("invalid torrent name %q", in.name)
+	}
+	in.pieceLength, _ = dictInt(d, "piece length")
+	if in.pieceLength <= 0 || in.pieceLength > maxPieceLength {
+		return nil, fmt.Errorf("invalid piece length %d", in.pieceLength)
+	}
+	pieces, _ := dictString(d, "pieces")
+	if len(pieces)%sha1.Size != 0 {
+		return nil, errors.New("invalid torrent pieces")
+	}
+	for i := 0; i < len(pieces); i += sha1.Size {
+		in.pieces = append(in.pieces, [sha1.Size]byte([]byte(pieces[i:i+sha1.Size])))
+	}
+
+	if length, ok := dictInt(d, "length"); ok {
+		if length < 0 {
+			return nil, fmt.Errorf("invalid torrent length %d", length)
+		}
+		in.files = []fileEntry{{path: in.name, length: length}}
+		in.length = length
+	} else {
+		files, ok := d["files"].([]any)
+		if !ok || len(files) == 0 {
+			return nil, errors.New("torrent info has neither a length nor files")
+		}
+		in.multi = true
+		seen := map[string]bool{}
+		for _, f := range files {
+			fd, ok := f.(map[string]any)
+			if !ok {
+				return nil, errors.New("invalid torrent file")
+			}
+			length, ok := dictInt(fd, "length")
+			if !ok || length < 0 {
+				return nil, errors.New("invalid torrent file length")
+			}
+			elems, _ := fd["path"].([]any)
+			var parts []string
+			for _, e := range elems {
+				s, ok := e.(string)
+				if !ok || !validName(s) {
+					return nil, fmt.Errorf("invalid torrent file path %v", elems)
+				}
+				parts = append(parts, s)
+			}
+			if len(parts) == 0 {
+				return nil, errors.New("torrent file has no path")
+			}
+			p := path.Join(parts...)
+			if seen[p] {
+				return nil, fmt.Errorf("duplicate torrent file %s", p)
+			}
+			seen[p] = true
+			attr, _ := dictString(fd, "attr")
+			in.files = append(in.files, fileEntry{path: p, length: length, offset: in.length, pad: strings.Contains(attr, "p")})
+			in.length += length
+		}
+	}
+
+	if want := (in.length + in.pieceLength - 1) / in.pieceLength; int64(len(in.pieces)) != want {
+		return nil, fmt.Errorf("torrent has %d pieces, expected %d", len(in.pieces), want)
+	}
+	return in, nil
+}
+
+// validName reports whether s is a valid file name, or path element, of a torrent.
+func validName(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, "/\\\x00")
+}
+
+// metainfo is the content of a .torrent file.
+type metainfo struct {
+	infoHash [sha1.Size]byte
+	info     *info
+	trackers []string
+}
+
+// parseMetainfo parses the content of a .torrent file.
+func parseMetainfo(data []byte) (*metainfo, error) {
+	v, _, err := decodeBencode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode torrent: %w", err)
+	}
+	d, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("torrent is not a dictionary")
+	}
+	raw, err := rawDictValue(data, "info")
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode torrent: %w", err)
+	}
+	in, err := parseInfo(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &metainfo{infoHash: sha1.Sum(raw), info: in}
+	// The tiers of announce-list are tried in turn, falling back to announce.
+	if tiers, ok := d["announce-list"].([]any); ok {
+		for _, tier := range tiers {
+			urls, _ := tier.([]any)
+			for _, u := range urls {
+				if s, ok := u.(string); ok && s != "" {
+					m.trackers = appendUnique(m.trackers, s)
+				}
+			}
+		}
+	}
+	if s, ok := dictString(d, "announce"); ok && s != "" {
+		m.trackers = appendUnique(m.trackers, s)
+	}
+	return m, nil
+}
+
+// magnet is a parsed magnet link.
+type magnet struct {
+	infoHash [sha1.Size]byte
+	name     string
+	trackers []string
+	peers    []string
+}
+
+// parseMagnet parses a magnet link of the form magnet:?xt=urn:btih:<infohash>, with the
+// optional dn, tr and x.pe parameters naming the torrent, its trackers and peers. The infohash is
+// hex or base32 encoded.
+func parseMagnet(s string) (*magnet, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse magnet link: %w", err)
+	}
+	if u.Scheme != "magnet" {
+		return nil, fmt.Errorf("not a magnet link: %s", s)
+	}
+	q := u.Query()
+	m := &magnet{name: q.Get("dn"), trackers: q["tr"], peers: q["x.pe"]}
+	found := false
+	for _, xt := range q["xt"] {
+		if h, ok := strings.CutPrefix(xt, "urn:btih:"); ok {
+			if m.infoHash, err = parseInfoHash(h); err != nil {
+				return nil, err
+			}
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("magnet link has no BitTorrent infohash: %s", s)
+	}
+	return m, nil
+}
+
+// parseInfoHash parses a hex or base32 encoded infohash.
+func parseInfoHash(s string) ([sha1.Size]byte, error) {
+	var h [sha1.Size]byte
+	var b []byte
+	var err error
+	switch len(s) {
+	case 2 * sha1.Size:
+		b, err = hex.DecodeString(s)
+	case 32:
+		b, err = base32.StdEncoding.DecodeString(strings.ToUpper(s))
+	default:
+		err = errors.New("invalid length")
+	}
+	if err != nil {
+		return h, fmt.Errorf("invalid infohash %s: %w", s, err)
+	}
+	copy(h[:], b)
+	return h, nil
+}
+
+// appendUnique appends s to list unless it already holds it.
+func appendUnique(list []string, s string) []string {
+	if slices.Contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
@@ -0,0 +1,110 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package torrent
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestBencode tests decoding and encoding bencoded values
+func TestBencode(t *testing.T) {
+	data := "d4:listli1ei-2e3:abce4:name4:test3:numi42ee"
+	v, n, err := decodeBencode([]byte(data + "trailing"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]any{"list": []any{int64(1), int64(-2), "abc"}, "name": "test", "num": int64(42)}
+	if !reflect.DeepEqual(v, expected) || n != len(data) {
+		t.Errorf("unexpected value: got %v (%d bytes), want %v", v, n, expected)
+	}
+	if got := string(encodeBencode(v)); got != data {
+		t.Errorf("unexpected encoding: got %s, want %s", got, data)
+	}
+
+	raw, err := rawDictValue([]byte(data), "list")
+	if err != nil || string(raw) != "li1ei-2e3:abce" {
+		t.Errorf("unexpected raw value: %s, %v", raw, err)
+	}
+
+	for _, invalid := range []string{"", "i12", "ixe", "5:abc", "l1:a", "d1:ai1e", "di1ei1ee", "x", strings.Repeat("l", 100) + strings.Repeat("e", 100)} {
+		if _, _, err := decodeBencode([]byte(invalid)); err == nil {
+			t.Errorf("expected an error decoding %q", invalid)
+		}
+	}
+}
+
+// TestParseMetainfo tests parsing .torrent files
+func TestParseMetainfo(t *testing.T) {
+	tt := newTestTorrent(t, "policy", 4, testFile{path: "policy/a.rego", content: "aaaaa"}, testFile{path: "policy/b.rego", content: "bb"})
+	data := []byte("d8:announce20:udp://tracker.test:113:announce-listll19:http://tracker.testel20:udp://tracker.test:1ee4:info" + string(tt.info) + "e")
+	mi, err := parseMetainfo(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mi.infoHash != tt.infoHash {
+		t.Errorf("unexpected infohash: %x", mi.infoHash)
+	}
+	if expected := []string{"http://tracker.test", "udp://tracker.test:1"}; !reflect.DeepEqual(mi.trackers, expected) {
+		t.Errorf("unexpected trackers: got %v, want %v", mi.trackers, expected)
+	}
+	in := mi.info
+	if in.name != "policy" || !in.multi || in.length != 7 || len(in.pieces) != 2 || in.pieceSize(1) != 3 {
+		t.Errorf("unexpected info: %+v", in)
+	}
+	if expected := []fileEntry{{path: "policy/a.rego", length: 5}, {path: "policy/b.rego", length: 2, offset: 5}}; !reflect.DeepEqual(in.files, expected) {
+		t.Errorf("unexpected files: got %+v, want %+v", in.files, expected)
+	}
+}
+
+// TestParseInfo_Invalid tests rejecting info dictionaries with invalid names, files or pieces
+func TestParseInfo_Invalid(t *testing.T) {
+	pieces := strings.Repeat("x", 20)
+	tests := map[string]map[string]any{
+		"name":           {"name": "..", "piece length": 4, "pieces": pieces, "length": 4},
+		"piece length":   {"name": "a", "piece length": 0, "pieces": pieces, "length": 4},
+		"pieces":         {"name": "a", "piece length": 4, "pieces": "x", "length": 4},
+		"piece count":    {"name": "a", "piece length": 4, "pieces": pieces, "length": 5},
+		"no files":       {"name": "a", "piece length": 4, "pieces": pieces},
+		"escaping path":  {"name": "a", "piece length": 4, "pieces": pieces, "files": []any{map[string]any{"length": 4, "path": []any{"..", "b"}}}},
+		"separator":      {"name": "a", "piece length": 4, "pieces": pieces, "files": []any{map[string]any{"length": 4, "path": []any{"b/c"}}}},
+		"duplicate file": {"name": "a", "piece length": 4, "pieces": pieces, "files": []any{map[string]any{"length": 2, "path": []any{"b"}}, map[string]any{"length": 2, "path": []any{"b"}}}},
+	}
+	for name, d := range tests {
+		t.Run(name, func(t *testing.T) {
+			if in, err := parseInfo(encodeBencode(d)); err == nil {
+				t.Errorf("expected an error, got %+v", in)
+			}
+		})
+	}
+}
+
+// TestParseMagnet tests parsing magnet links
+func TestParseMagnet(t *testing.T) {
+	m, err := parseMagnet("magnet:?xt=urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK&dn=policy&tr=udp%3A%2F%2Ftracker.test%3A1&tr=http%3A%2F%2Ftracker.test&x.pe=10.0.0.1%3A6881")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := hex.EncodeToString(m.infoHash[:]); got != "c12fe1c06bba254a9dc9f519b335aa7c1367a88a" {
+		t.Errorf("unexpected infohash: %s", got)
+	}
+	if m.name != "policy" || !reflect.DeepEqual(m.trackers, []string{"udp://tracker.test:1", "http://tracker.test"}) || !reflect.DeepEqual(m.peers, []string{"10.0.0.1:6881"}) {
+		t.Errorf("unexpected magnet: %+v", m)
+	}
+}
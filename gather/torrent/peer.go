@@ -0,0 +1,265 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package torrent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1" // #nosec G505 -- BitTorrent v1 identifies torrents and pieces by SHA-1
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// The IDs of the messages of the peer wire protocol, see BEP 3 and BEP 10.
+const (
+	msgChoke         = 0
+	msgUnchoke       = 1
+	msgInterested    = 2
+	msgNotInterested = 3
+	msgHave          = 4
+	msgBitfield      = 5
+	msgRequest       = 6
+	msgPiece         = 7
+	msgCancel        = 8
+	msgExtended      = 20
+)
+
+const (
+	// protocol is the protocol string of the handshake.
+	protocol = "BitTorrent protocol"
+	// blockSize is the size of the blocks pieces are requested in.
+	blockSize = 16 << 10
+	// maxMessageLength bounds the length of the messages read from peers.
+	maxMessageLength = 4 << 20
+	// utMetadataID is the ID peers send metadata messages to the gatherer with, see BEP 9.
+	utMetadataID = 1
+	// maxMetadataSize bounds the size of the info dictionaries downloaded from peers.
+	maxMetadataSize = 16 << 20
+)
+
+// dialTimeout bounds the duration of connecting to a peer and of the handshake.
+var dialTimeout = 10 * time.Second
+
+// message is a message of the peer wire protocol. Keep-alive messages are not returned.
+type message struct {
+	id      byte
+	payload []byte
+}
+
+// peerConn is a connection to a peer.
+type peerConn struct {
+	addr string
+	conn net.Conn
+	r    *bufio.Reader
+	// mu serializes writes, which the gatherer sends from several goroutines when seeding.
+	mu sync.Mutex
+	// extended is set if the peer supports the extension protocol of BEP 10.
+	extended bool
+}
+
+// dialPeer connects to the peer at addr and exchanges handshakes for the torrent infoHash.
+func dialPeer(ctx context.Context, addr string, infoHash, peerID [sha1.Size]byte) (*peerConn, error) {
+	ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	handshake := make([]byte, 0, 68)
+	handshake = append(handshake, byte(len(protocol)))
+	handshake = append(handshake, protocol...)
+	reserved := make([]byte, 8)
+	reserved[5] |= 0x10 // extension protocol
+	handshake = append(handshake, reserved...)
+	handshake = append(handshake, infoHash[:]...)
+	handshake = append(handshake, peerID[:]...)
+	if _, err := conn.Write(handshake); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	p := &peerConn{addr: addr, conn: conn, r: bufio.NewReaderSize(conn, 64<<10)}
+	reply := make([]byte, 68)
+	if _, err := io.ReadFull(p.r, reply); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read handshake: %w", err)
+	}
+	if reply[0] != byte(len(protocol)) || string(reply[1:20]) != protocol {
+		conn.Close()
+		return nil, errors.New("unsupported protocol")
+	}
+	if !bytes.Equal(reply[28:48], infoHash[:]) {
+		conn.Close()
+		return nil, errors.New("peer serves another torrent")
+	}
+	p.extended = reply[25]&0x10 != 0
+	_ = conn.SetDeadline(time.Time{})
+	return p, nil
+}
+
+// close closes the connection.
+func (p *peerConn) close() {
+	p.conn.Close()
+}
+
+// send sends the message id with the payload.
+func (p *peerConn) send(id byte, payload []byte) error {
+	buf := make([]byte, 5+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(1+len(payload)))
+	buf[4] = id
+	copy(buf[5:], payload)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := p.conn.Write(buf)
+	return err
+}
+
+// sendExtended sends the extension message id, see BEP 10, with the bencoded dict followed by
+// data.
+func (p *peerConn) sendExtended(id byte, dict map[string]any, data []byte) error {
+	payload := append([]byte{id}, encodeBencode(dict)...)
+	return p.send(msgExtended, append(payload, data...))
+}
+
+// sendRequest requests length bytes at begin of piece index, or cancels the request.
+func (p *peerConn) sendRequest(id byte, index int, begin, length int64) error {
+	payload := make([]byte, 12)
+	binary.BigEndian.PutUint32(payload, uint32(index))
+	binary.BigEndian.PutUint32(payload[4:], uint32(begin))
+	binary.BigEndian.PutUint32(payload[8:], uint32(length))
+	return p.send(id, payload)
+}
+
+// read reads the next message, skipping keep-alive messages.
+func (p *peerConn) read() (message, error) {
+	for {
+		var length uint32
+		if err := binary.Read(p.r, binary.BigEndian, &length); err != nil {
+			return message{}, err
+		}
+		if length == 0 {
+			continue
+		}
+		if length > maxMessageLength {
+			return message{}, fmt.Errorf("message of %d bytes exceeds the limit", length)
+		}
+		buf := make([]byte, length)
+		if _, err := io.ReadFull(p.r, buf); err != nil {
+			return message{}, err
+		}
+		return message{id: buf[0], payload: buf[1:]}, nil
+	}
+}
+
+// readLoop reads messages into msgs until reading fails, then closes msgs.
+func (p *peerConn) readLoop(msgs chan<- message) {
+	defer close(msgs)
+	for {
+		m, err := p.read()
+		if err != nil {
+			return
+		}
+		msgs <- m
+	}
+}
+
+// fetchMetadata downloads the info dictionary of the torrent infoHash from the peer, see BEP 9,
+// and checks it against the infohash.
+func (p *peerConn) fetchMetadata(ctx context.Context, infoHash [sha1.Size]byte) ([]byte, error) {
+	if !p.extended {
+		return nil, errors.New("peer does not support the extension protocol")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = p.conn.SetDeadline(deadline)
+		defer p.conn.SetDeadline(time.Time{})
+	}
+	if err := p.sendExtended(0, map[string]any{"m": map[string]any{"ut_metadata": utMetadataID}}, nil); err != nil {
+		return nil, err
+	}
+
+	var peerID int64
+	var size int64
+	var metadata []byte
+	for {
+		m, err := p.read()
+		if err != nil {
+			return nil, err
+		}
+		if m.id != msgExtended || len(m.payload) == 0 {
+			continue
+		}
+		v, n, err := decodeBencode(m.payload[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid extension message: %w", err)
+		}
+		d, _ := v.(map[string]any)
+
+		switch m.payload[0] {
+		case 0:
+			// The extension handshake, naming the ID of the metadata messages of the peer.
+			exts, _ := d["m"].(map[string]any)
+			peerID, _ = dictInt(exts, "ut_metadata")
+			size, _ = dictInt(d, "metadata_size")
+			if peerID <= 0 || peerID > 255 || size <= 0 || size > maxMetadataSize {
+				return nil, errors.New("peer does not serve the metadata")
+			}
+			metadata = make([]byte, 0, size)
+			if err := p.sendExtended(byte(peerID), map[string]any{"msg_type": 0, "piece": 0}, nil); err != nil {
+				return nil, err
+			}
+		case utMetadataID:
+			if metadata == nil {
+				continue
+			}
+			msgType, _ := dictInt(d, "msg_type")
+			piece, _ := dictInt(d, "piece")
+			if msgType == 2 {
+				return nil, errors.New("peer rejected the metadata request")
+			}
+			if msgType != 1 || piece != int64(len(metadata)/blockSize) {
+				continue
+			}
+			data := m.payload[1+n:]
+			if len(data) > blockSize || int64(len(metadata)+len(data)) > size {
+				return nil, errors.New("invalid metadata piece")
+			}
+			metadata = append(metadata, data...)
+			if int64(len(metadata)) == size {
+				if sha1.Sum(metadata) != infoHash {
+					return nil, errors.New("metadata does not match the infohash")
+				}
+				return metadata, nil
+			}
+			if len(data) != blockSize {
+				return nil, errors.New("short metadata piece")
+			}
+			if err := p.sendExtended(byte(peerID), map[string]any{"msg_type": 0, "piece": piece + 1}, nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
@@ -0,0 +1,518 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package torrent provides functionality for gathering the content of BitTorrent torrents. It
+// includes an implementation of the Gatherer interface, TorrentGatherer, an embedded client which
+// downloads the pieces of a torrent from its peers, verifies them against the piece digests of the
+// torrent and saves its files to the destination.
+//
+// Sources are either magnet links, magnet:?xt=urn:btih:<infohash>, or the location of a .torrent
+// file prefixed with torrent::, e.g. torrent::https://example.com/policy.torrent or
+// torrent::/path/to/policy.torrent. The btih parameter of a .torrent source pins the infohash the
+// torrent must have. Peers are found with the HTTP and UDP trackers of the torrent and of the
+// gatherer, and the static peers of the magnet link and of the gatherer; the DHT is not supported.
+// The infohash identifies the content, so the pinned URL of a gather is the source carrying it.
+//
+// The gatherer is not registered for these sources by default, as it connects to arbitrary peers;
+// register it with gather.RegisterGatherer to enable them. It never accepts connections and only
+// uploads to the peers it connects to while seeding, which is disabled by default.
+//
+// Example usage:
+//
+//	g := &torrent.TorrentGatherer{}
+//	m, err := g.Gather(context.Background(), "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&tr=udp%3A%2F%2Ftracker.example.com%3A6969", "/tmp/policy")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package torrent
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1" // #nosec G505 -- BitTorrent v1 identifies torrents and pieces by SHA-1
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+	torrentMetadata "github.com/enterprise-contract/go-gather/metadata/torrent"
+)
+
+// maxTorrentSize limits the size of .torrent files.
+const maxTorrentSize = 16 << 20
+
+// TorrentGatherer downloads the content of torrents from their peers.
+type TorrentGatherer struct {
+	// Client is the HTTP client .torrent files are downloaded and HTTP trackers are announced to
+	// with. It is configured for the gather options, see gogather.HTTPClient.
+	Client http.Client
+	// Trackers lists the URLs of trackers announced to in addition to those of the torrent.
+	Trackers []string
+	// Peers lists the addresses, as host:port, of peers connected to in addition to those the
+	// trackers return, e.g. a seed of the organization.
+	Peers []string
+	// MaxPeers limits the number of peers connected to at once. Zero means 30.
+	MaxPeers int
+	// Seed is how long pieces are uploaded to the connected peers that request them once the
+	// download completes, delaying the end of the gather. Zero disables uploading entirely.
+	Seed time.Duration
+	// Logger receives diagnostic messages. When nil, the logger of the gather options is used.
+	Logger *slog.Logger
+}
+
+// location is a parsed source.
+type location struct {
+	// magnet is the magnet link of the source, if it is one.
+	magnet *magnet
+	// torrent is the URL or local path of the .torrent file of the source otherwise.
+	torrent string
+	// infoHash is the infohash the .torrent file must have, if pinned.
+	infoHash *[sha1.Size]byte
+}
+
+// Gather downloads the torrent of source to the destination. The only file of a single-file
+// torrent is saved as the destination file, under its own name if the destination is an existing
+// directory or ends with a separator. The files of a multi-file torrent are saved below the
+// destination directory, without the directory named after the torrent; the Include and Exclude
+// patterns of the gather options select the files to download.
+func (g *TorrentGatherer) Gather(ctx context.Context, source, destination string) (_ metadata.Metadata, err error) {
+	defer func() {
+		err = gogather.RedactError(err)
+		gogather.FinishProgress(ctx, err)
+	}()
+	startedAt := time.Now()
+
+	loc, err := parseSource(source)
+	if err != nil {
+		return nil, err
+	}
+	peerID, err := newPeerID()
+	if err != nil {
+		return nil, err
+	}
+
+	var infoHash [sha1.Size]byte
+	var in *info
+	var trackers, peers []string
+	if loc.magnet != nil {
+		infoHash = loc.magnet.infoHash
+		trackers, peers = loc.magnet.trackers, loc.magnet.peers
+	} else {
+		mi, err := g.readTorrent(ctx, loc.torrent)
+		if err != nil {
+			return nil, err
+		}
+		if loc.infoHash != nil && *loc.infoHash != mi.infoHash {
+			return nil, fmt.Errorf("infohash of %s does not match: %x != %x", gogather.RedactURL(loc.torrent), mi.infoHash, *loc.infoHash)
+		}
+		infoHash, in, trackers = mi.infoHash, mi.info, mi.trackers
+	}
+	for _, t := range g.Trackers {
+		trackers = appendUnique(trackers, t)
+	}
+	for _, p := range g.Peers {
+		peers = appendUnique(peers, p)
+	}
+	if len(trackers) == 0 && len(peers) == 0 {
+		return nil, fmt.Errorf("no trackers or peers for %s", gogather.RedactURL(source))
+	}
+	if in == nil {
+		if in, err = g.fetchInfo(ctx, infoHash, peerID, trackers, peers); err != nil {
+			return nil, err
+		}
+	}
+
+	if !in.multi {
+		if strings.HasSuffix(destination, string(os.PathSeparator)) || strings.HasSuffix(destination, "/") {
+			destination = filepath.Join(destination, in.name)
+		} else if fi, err := os.Stat(destination); err == nil && fi.IsDir() {
+			destination = filepath.Join(destination, in.name)
+		}
+	}
+	selected, err := selectFiles(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	m := &torrentMetadata.TorrentMetadata{InfoHash: hex.EncodeToString(infoHash[:]), Name: in.name, PieceLength: in.pieceLength}
+	for i, f := range in.files {
+		if selected[i] {
+			m.Files++
+			m.Size += f.length
+		}
+	}
+	if err := gogather.CheckWritten(ctx, m.Size); err != nil {
+		return nil, err
+	}
+	gogather.StartProgress(ctx, m.Size, m.Files)
+
+	gogather.Logger(ctx, g.Logger).Debug("downloading torrent", "infohash", m.InfoHash, "name", in.name, "files", m.Files, "size", m.Size, "destination", destination)
+	st, err := newStorage(in, destination, selected)
+	if err != nil {
+		return nil, err
+	}
+	s := newSwarm(g, in, st, infoHash, peerID, trackers, peers)
+	err = s.run(ctx)
+	if cerr := st.close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download torrent %s: %w", m.InfoHash, err)
+	}
+	m.Peers, m.Uploaded = len(s.sources), s.uploaded
+	gogather.CountItems(ctx, m.Files)
+
+	if in.multi {
+		if err := os.MkdirAll(destination, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if m.TreeHash, err = metadata.TreeHash(destination); err != nil {
+			return nil, err
+		}
+		if err := gogather.VerifyChecksumDigest(ctx, destination, "sha256", m.TreeHash); err != nil {
+			return nil, err
+		}
+	} else {
+		if m.SHA256, err = fileDigest(destination); err != nil {
+			return nil, err
+		}
+		if err := gogather.VerifyChecksum(ctx, destination); err != nil {
+			return nil, err
+		}
+	}
+
+	resolved, _ := m.GetPinnedURL(gogather.RedactURL(source))
+	m.Common = metadata.NewCommon(torrentMetadata.Type, gogather.RedactURL(source), resolved, destination, startedAt)
+	return m, nil
+}
+
+// selectFiles reports which files of the torrent are downloaded: those of a multi-file torrent
+// that the Include and Exclude patterns of the gather options match, and the file of a
+// single-file torrent. Padding files are never downloaded.
+func selectFiles(ctx context.Context, in *info) ([]bool, error) {
+	o := gogather.OptionsFromContext(ctx)
+	filter, err := gogather.NewPathFilter(o.Include, o.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]bool, len(in.files))
+	found := false
+	for i, f := range in.files {
+		selected[i] = !f.pad && (!in.multi || filter.Match(f.path))
+		found = found || selected[i]
+	}
+	if !found {
+		return nil, fmt.Errorf("no files of torrent %s match the filters", in.name)
+	}
+	return selected, nil
+}
+
+// readTorrent reads the .torrent file at the URL or local path.
+func (g *TorrentGatherer) readTorrent(ctx context.Context, location string) (*metainfo, error) {
+	var r io.ReadCloser
+	if u, err := url.Parse(location); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		resp, err := g.get(ctx, location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download torrent %s: %w", gogather.RedactURL(location), err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to download torrent %s: %w", gogather.RedactURL(location), &gogather.StatusError{StatusCode: resp.StatusCode})
+		}
+		r = resp.Body
+	} else {
+		if err := gogather.CheckHost(ctx, "file", ""); err != nil {
+			return nil, err
+		}
+		f, err := os.Open(location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open torrent: %w", err)
+		}
+		r = f
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(io.LimitReader(r, maxTorrentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read torrent %s: %w", gogather.RedactURL(location), err)
+	}
+	if len(data) > maxTorrentSize {
+		return nil, fmt.Errorf("torrent %s exceeds %d bytes", gogather.RedactURL(location), maxTorrentSize)
+	}
+	return parseMetainfo(data)
+}
+
+// fetchInfo downloads the info dictionary of the torrent infoHash from its peers, trying them
+// concurrently in rounds until one serves it.
+func (g *TorrentGatherer) fetchInfo(ctx context.Context, infoHash, peerID [sha1.Size]byte, trackers, peers []string) (*info, error) {
+	// The size of the torrent is unknown until its info dictionary is downloaded, but trackers
+	// must not take the gatherer for a seed.
+	req := announceRequest{infoHash: infoHash, peerID: peerID, left: 1, event: "started"}
+	var lastErr error
+	for round := 0; round < maxStalls; round++ {
+		if round > 0 {
+			select {
+			case <-time.After(retryDelay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		addrs, err := g.discover(ctx, req, trackers, peers)
+		req.event = ""
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		raw, err := g.fetchInfoFrom(ctx, addrs, infoHash, peerID)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		in, err := parseInfo(raw)
+		if err != nil {
+			return nil, err
+		}
+		return in, nil
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no peers found")
+	}
+	return nil, fmt.Errorf("failed to download the metadata of torrent %x: %w", infoHash, lastErr)
+}
+
+// fetchInfoFrom downloads the info dictionary of the torrent infoHash from the first of the peers
+// at addrs serving it.
+func (g *TorrentGatherer) fetchInfoFrom(ctx context.Context, addrs []string, infoHash, peerID [sha1.Size]byte) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var raw []byte
+	var errs []error
+	sem := make(chan struct{}, g.maxPeers())
+	for _, addr := range addrs {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			peerCtx, cancelPeer := context.WithTimeout(ctx, peerTimeout)
+			defer cancelPeer()
+			p, err := dialPeer(peerCtx, addr, infoHash, peerID)
+			if err == nil {
+				stop := context.AfterFunc(peerCtx, p.close)
+				var data []byte
+				data, err = p.fetchMetadata(peerCtx, infoHash)
+				stop()
+				p.close()
+				if err == nil {
+					mu.Lock()
+					if raw == nil {
+						raw = data
+					}
+					mu.Unlock()
+					cancel()
+					return
+				}
+			}
+			gogather.Logger(ctx, g.Logger).Debug("failed to download metadata", "peer", addr, "error", err)
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}(addr)
+	}
+	wg.Wait()
+
+	if raw != nil {
+		return raw, nil
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("no peers found")
+	}
+	return nil, errs[0]
+}
+
+// discover returns the addresses of the static peers and of the peers the trackers return, which
+// the host policy allows. If there are none, it returns the first error of the trackers.
+func (g *TorrentGatherer) discover(ctx context.Context, req announceRequest, trackers, peers []string) ([]string, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
+	addrs := append([]string{}, peers...)
+	for _, tracker := range trackers {
+		wg.Add(1)
+		go func(tracker string) {
+			defer wg.Done()
+			found, err := g.announce(ctx, tracker, req)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				gogather.Logger(ctx, g.Logger).Debug("failed to announce", "tracker", gogather.RedactURL(tracker), "error", err)
+				errs = append(errs, err)
+				return
+			}
+			for _, p := range found {
+				addrs = appendUnique(addrs, p)
+			}
+		}(tracker)
+	}
+	wg.Wait()
+
+	var allowed []string
+	for _, addr := range addrs {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			continue
+		}
+		if err := gogather.CheckHost(ctx, "bittorrent", host); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		allowed = append(allowed, addr)
+	}
+	gogather.Logger(ctx, g.Logger).Debug("found peers", "peers", len(allowed))
+	if len(allowed) == 0 && len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return allowed, nil
+}
+
+// maxPeers returns the number of peers connected to at once.
+func (g *TorrentGatherer) maxPeers() int {
+	if g.MaxPeers > 0 {
+		return g.MaxPeers
+	}
+	return 30
+}
+
+// get sends a GET request for rawURL after checking its host against the host policy of the
+// gather options.
+func (g *TorrentGatherer) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if err := gogather.CheckHost(ctx, req.URL.Scheme, req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Go-Gather")
+
+	client, err := gogather.HTTPClient(ctx, &g.Client)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// newPeerID returns a random peer ID, in the Azureus style naming the client.
+func newPeerID() ([sha1.Size]byte, error) {
+	var id [sha1.Size]byte
+	n := copy(id[:], "-GG0001-")
+	if _, err := rand.Read(id[n:]); err != nil {
+		return id, fmt.Errorf("failed to generate peer ID: %w", err)
+	}
+	return id, nil
+}
+
+// fileDigest returns the hex encoded SHA256 digest of the file at path.
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// parseSource parses a magnet link or a torrent:: source naming the URL or local path of a
+// .torrent file, with the optional btih parameter pinning its infohash.
+func parseSource(source string) (*location, error) {
+	s := strings.TrimPrefix(source, "torrent::")
+	if strings.HasPrefix(s, "magnet:") {
+		m, err := parseMagnet(s)
+		if err != nil {
+			return nil, err
+		}
+		return &location{magnet: m}, nil
+	}
+	if s == source {
+		return nil, fmt.Errorf("unsupported torrent source: %s", source)
+	}
+
+	loc := &location{}
+	if u, err := url.Parse(s); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		q := u.Query()
+		if h := q.Get("btih"); h != "" {
+			infoHash, err := parseInfoHash(h)
+			if err != nil {
+				return nil, err
+			}
+			loc.infoHash = &infoHash
+			q.Del("btih")
+			u.RawQuery = q.Encode()
+		}
+		loc.torrent = u.String()
+		return loc, nil
+	}
+
+	path, query, _ := strings.Cut(strings.TrimPrefix(s, "file://"), "?")
+	if query != "" {
+		q, err := url.ParseQuery(query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse source %s: %w", source, err)
+		}
+		for key := range q {
+			if key != "btih" {
+				return nil, fmt.Errorf("unsupported parameters of %s", source)
+			}
+		}
+		infoHash, err := parseInfoHash(q.Get("btih"))
+		if err != nil {
+			return nil, err
+		}
+		loc.infoHash = &infoHash
+	}
+	if path == "" {
+		return nil, fmt.Errorf("torrent source has no location: %s", source)
+	}
+	loc.torrent = path
+	return loc, nil
+}
@@ -0,0 +1,456 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package torrent
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1" // #nosec G505 -- BitTorrent v1 identifies torrents and pieces by SHA-1
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	torrentMetadata "github.com/enterprise-contract/go-gather/metadata/torrent"
+)
+
+func init() {
+	retryDelay = 10 * time.Millisecond
+}
+
+// testFile is a file of a test torrent.
+type testFile struct {
+	path    string
+	content string
+	pad     bool
+}
+
+// testTorrent is a torrent served by a fakeSeeder.
+type testTorrent struct {
+	info     []byte
+	infoHash [sha1.Size]byte
+	content  []byte
+}
+
+// newTestTorrent returns a torrent of the files, a single-file torrent if there is only one file
+// and it has no path separator.
+func newTestTorrent(t *testing.T, name string, pieceLength int, files ...testFile) *testTorrent {
+	t.Helper()
+	tt := &testTorrent{}
+	d := map[string]any{"name": name, "piece length": pieceLength}
+	if len(files) == 1 && !strings.Contains(files[0].path, "/") {
+		d["length"] = len(files[0].content)
+		tt.content = []byte(files[0].content)
+	} else {
+		var list []any
+		for _, f := range files {
+			var elems []any
+			for _, e := range strings.Split(f.path, "/") {
+				elems = append(elems, e)
+			}
+			fd := map[string]any{"length": len(f.content), "path": elems}
+			if f.pad {
+				fd["attr"] = "p"
+			}
+			list = append(list, fd)
+			tt.content = append(tt.content, f.content...)
+		}
+		d["files"] = list
+	}
+	var pieces []byte
+	for i := 0; i < len(tt.content); i += pieceLength {
+		sum := sha1.Sum(tt.content[i:min(i+pieceLength, len(tt.content))])
+		pieces = append(pieces, sum[:]...)
+	}
+	d["pieces"] = string(pieces)
+	tt.info = encodeBencode(d)
+	tt.infoHash = sha1.Sum(tt.info)
+	return tt
+}
+
+// file returns the content of the .torrent file of the torrent, announcing to the trackers.
+func (tt *testTorrent) file(trackers ...string) []byte {
+	out := []byte("d")
+	if len(trackers) > 0 {
+		out = append(out, encodeBencode("announce")...)
+		out = append(out, encodeBencode(trackers[0])...)
+	}
+	out = append(out, encodeBencode("info")...)
+	out = append(out, tt.info...)
+	return append(out, 'e')
+}
+
+// magnet returns a magnet link of the torrent with the peer at addr.
+func (tt *testTorrent) magnet(addr string) string {
+	return "magnet:?xt=urn:btih:" + hex.EncodeToString(tt.infoHash[:]) + "&dn=test&x.pe=" + addr
+}
+
+// fakeSeeder is a peer serving a torrent.
+type fakeSeeder struct {
+	t       *testing.T
+	torrent *testTorrent
+	// corrupt makes the seeder serve invalid pieces.
+	corrupt bool
+
+	mu sync.Mutex
+	// served counts the blocks served.
+	served int
+}
+
+// blocksServed returns the number of blocks served.
+func (s *fakeSeeder) blocksServed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.served
+}
+
+// start serves the torrent on a local port, returning its address.
+func (s *fakeSeeder) start() string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func (s *fakeSeeder) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	handshake := make([]byte, 68)
+	if _, err := io.ReadFull(r, handshake); err != nil || string(handshake[28:48]) != string(s.torrent.infoHash[:]) {
+		return
+	}
+	copy(handshake[48:], "-FS0001-000000000000")
+	if _, err := conn.Write(handshake); err != nil {
+		return
+	}
+	p := &peerConn{conn: conn, r: r}
+
+	pieces := (len(s.torrent.content) + pieceLength(s.torrent) - 1) / pieceLength(s.torrent)
+	bitfield := make([]byte, (pieces+7)/8)
+	for i := 0; i < pieces; i++ {
+		bitfield[i/8] |= 0x80 >> (i % 8)
+	}
+	_ = p.send(msgBitfield, bitfield)
+	_ = p.send(msgUnchoke, nil)
+
+	for {
+		m, err := p.read()
+		if err != nil {
+			return
+		}
+		switch m.id {
+		case msgRequest:
+			index := int(binary.BigEndian.Uint32(m.payload))
+			begin := int(binary.BigEndian.Uint32(m.payload[4:]))
+			length := int(binary.BigEndian.Uint32(m.payload[8:]))
+			off := index*pieceLength(s.torrent) + begin
+			block := append([]byte{}, s.torrent.content[off:off+length]...)
+			if s.corrupt {
+				block[0] ^= 0xff
+			}
+			_ = p.send(msgPiece, append(m.payload[:8:8], block...))
+			s.mu.Lock()
+			s.served++
+			s.mu.Unlock()
+		case msgExtended:
+			v, _, err := decodeBencode(m.payload[1:])
+			if err != nil {
+				return
+			}
+			d, _ := v.(map[string]any)
+			switch m.payload[0] {
+			case 0:
+				_ = p.sendExtended(0, map[string]any{"m": map[string]any{"ut_metadata": 3}, "metadata_size": len(s.torrent.info)}, nil)
+			case 3:
+				piece, _ := dictInt(d, "piece")
+				data := s.torrent.info[piece*blockSize : min(int(piece+1)*blockSize, len(s.torrent.info))]
+				_ = p.sendExtended(utMetadataID, map[string]any{"msg_type": 1, "piece": piece, "total_size": len(s.torrent.info)}, data)
+			}
+		}
+	}
+}
+
+func pieceLength(tt *testTorrent) int {
+	in, err := parseInfo(tt.info)
+	if err != nil {
+		panic(err)
+	}
+	return int(in.pieceLength)
+}
+
+// writeTorrent saves the .torrent file of the torrent, returning its path.
+func writeTorrent(t *testing.T, tt *testTorrent, trackers ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.torrent")
+	if err := os.WriteFile(path, tt.file(trackers...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestTorrentGatherer_Gather_File tests gathering a single-file torrent from a static peer
+func TestTorrentGatherer_Gather_File(t *testing.T) {
+	content := strings.Repeat("package main\n", 5000)
+	tt := newTestTorrent(t, "policy.rego", 32<<10, testFile{path: "policy.rego", content: content})
+	g := &TorrentGatherer{Peers: []string{(&fakeSeeder{t: t, torrent: tt}).start()}}
+	dir := t.TempDir()
+
+	source := "torrent::" + writeTorrent(t, tt)
+	m, err := g.Gather(context.Background(), source, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "policy.rego"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Error("unexpected content")
+	}
+
+	tm := m.(*torrentMetadata.TorrentMetadata)
+	sum := sha256.Sum256([]byte(content))
+	infoHash := hex.EncodeToString(tt.infoHash[:])
+	if tm.InfoHash != infoHash || tm.Name != "policy.rego" || tm.Files != 1 || tm.Size != int64(len(content)) || tm.Peers != 1 || tm.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("unexpected metadata: %+v", tm)
+	}
+	if tm.ResolvedURI != source+"?btih="+infoHash {
+		t.Errorf("unexpected resolved URI: %s", tm.ResolvedURI)
+	}
+
+	// The pinned source gathers the same torrent.
+	if _, err := g.Gather(context.Background(), tm.ResolvedURI, filepath.Join(t.TempDir(), "policy.rego")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestTorrentGatherer_Gather_Directory tests gathering the files of a multi-file torrent selected
+// by the filters of the gather options, announcing to an HTTP tracker
+func TestTorrentGatherer_Gather_Directory(t *testing.T) {
+	tt := newTestTorrent(t, "policy", 16<<10,
+		testFile{path: "policy/main.rego", content: strings.Repeat("a", 20000)},
+		testFile{path: ".pad/1", content: strings.Repeat("\x00", 12768), pad: true},
+		testFile{path: "policy/data.json", content: "{}"},
+		testFile{path: "docs/README.md", content: strings.Repeat("b", 40000)},
+		testFile{path: "policy/empty.rego"},
+	)
+	seeder := &fakeSeeder{t: t, torrent: tt}
+	addr := seeder.start()
+	host, port, _ := net.SplitHostPort(addr)
+	tracker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("info_hash") != string(tt.infoHash[:]) || r.URL.Query().Get("key") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, "d5:peersld2:ip%d:%s4:porti%seeee", len(host), host, port)
+	}))
+	defer tracker.Close()
+
+	dir := t.TempDir()
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Include: []string{"policy/**"}})
+	m, err := (&TorrentGatherer{}).Gather(ctx, "torrent::"+writeTorrent(t, tt, tracker.URL+"/announce?key=secret"), dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, want := range map[string]string{"policy/main.rego": strings.Repeat("a", 20000), "policy/data.json": "{}", "policy/empty.rego": ""} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("unexpected content of %s", name)
+		}
+	}
+	for _, name := range []string{"docs", ".pad"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected %s not to be gathered: %v", name, err)
+		}
+	}
+	// The pieces are a single block each, and the last two only cover the excluded file.
+	if n := seeder.blocksServed(); n != 3 {
+		t.Errorf("expected 3 pieces to be downloaded, got %d", n)
+	}
+	if tm := m.(*torrentMetadata.TorrentMetadata); tm.Files != 3 || tm.Size != 20002 || tm.TreeHash == "" {
+		t.Errorf("unexpected metadata: %+v", tm)
+	}
+}
+
+// TestTorrentGatherer_Gather_Magnet tests gathering a magnet link, downloading the metadata of
+// the torrent from the peer
+func TestTorrentGatherer_Gather_Magnet(t *testing.T) {
+	content := strings.Repeat("x", 100)
+	var files []testFile
+	// Enough files for the metadata to span several pieces.
+	for i := 0; i < 1000; i++ {
+		files = append(files, testFile{path: fmt.Sprintf("policy/%04d-%s.rego", i, strings.Repeat("n", 20)), content: content})
+	}
+	tt := newTestTorrent(t, "policy", 16<<10, files...)
+	if len(tt.info) <= blockSize {
+		t.Fatalf("expected metadata of more than one piece, got %d bytes", len(tt.info))
+	}
+	addr := (&fakeSeeder{t: t, torrent: tt}).start()
+
+	dir := t.TempDir()
+	m, err := (&TorrentGatherer{}).Gather(context.Background(), tt.magnet(addr), dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "policy", "0999-"+strings.Repeat("n", 20)+".rego"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Error("unexpected content")
+	}
+	if tm := m.(*torrentMetadata.TorrentMetadata); tm.Files != 1000 || !strings.HasPrefix(tm.ResolvedURI, "magnet:?xt=urn:btih:"+hex.EncodeToString(tt.infoHash[:])) {
+		t.Errorf("unexpected metadata: %+v", tm)
+	}
+}
+
+// TestTorrentGatherer_Gather_Errors tests the errors of gathers
+func TestTorrentGatherer_Gather_Errors(t *testing.T) {
+	prev := peerTimeout
+	peerTimeout = time.Second
+	t.Cleanup(func() { peerTimeout = prev })
+
+	tt := newTestTorrent(t, "policy.rego", 16<<10, testFile{path: "policy.rego", content: strings.Repeat("a", 40000)})
+	path := writeTorrent(t, tt)
+	corrupt := (&fakeSeeder{t: t, torrent: tt, corrupt: true}).start()
+	seeder := (&fakeSeeder{t: t, torrent: tt}).start()
+
+	t.Run("no peers", func(t *testing.T) {
+		_, err := (&TorrentGatherer{}).Gather(context.Background(), "torrent::"+path, t.TempDir())
+		if err == nil || !strings.Contains(err.Error(), "no trackers or peers") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("infohash mismatch", func(t *testing.T) {
+		g := &TorrentGatherer{Peers: []string{seeder}}
+		_, err := g.Gather(context.Background(), "torrent::"+path+"?btih="+strings.Repeat("0", 40), t.TempDir())
+		if err == nil || !strings.Contains(err.Error(), "does not match") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("corrupt pieces", func(t *testing.T) {
+		g := &TorrentGatherer{Peers: []string{corrupt}}
+		_, err := g.Gather(context.Background(), "torrent::"+path, t.TempDir())
+		if err == nil || !strings.Contains(err.Error(), "download stalled") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		g := &TorrentGatherer{Peers: []string{seeder}}
+		ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{MaxSize: 1000})
+		if _, err := g.Gather(ctx, "torrent::"+path, t.TempDir()); !errors.Is(err, gogather.ErrTooLarge) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		g := &TorrentGatherer{Peers: []string{seeder}}
+		ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Checksum: "sha256:" + strings.Repeat("0", 64)})
+		var mismatch *gogather.ChecksumMismatchError
+		if _, err := g.Gather(ctx, "torrent::"+path, t.TempDir()); !errors.As(err, &mismatch) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("denied peer", func(t *testing.T) {
+		g := &TorrentGatherer{Peers: []string{seeder}}
+		ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{HostPolicy: &gogather.HostPolicy{DeniedHosts: []string{"127.0.0.1"}}})
+		var denied *gogather.HostDeniedError
+		if _, err := g.Gather(ctx, "torrent::"+path, t.TempDir()); !errors.As(err, &denied) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+// TestParseSource tests parsing the sources of the gatherer
+func TestParseSource(t *testing.T) {
+	infoHash := "c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+	tests := []struct {
+		name     string
+		source   string
+		magnet   bool
+		torrent  string
+		infoHash string
+		err      bool
+	}{
+		{name: "magnet", source: "magnet:?xt=urn:btih:" + infoHash, magnet: true},
+		{name: "prefixed magnet", source: "torrent::magnet:?xt=urn:btih:" + infoHash, magnet: true},
+		{name: "https", source: "torrent::https://example.com/policy.torrent?key=1", torrent: "https://example.com/policy.torrent?key=1"},
+		{name: "https pinned", source: "torrent::https://example.com/policy.torrent?btih=" + infoHash, torrent: "https://example.com/policy.torrent", infoHash: infoHash},
+		{name: "path", source: "torrent::/tmp/policy.torrent", torrent: "/tmp/policy.torrent"},
+		{name: "file URL pinned", source: "torrent::file:///tmp/policy.torrent?btih=" + infoHash, torrent: "/tmp/policy.torrent", infoHash: infoHash},
+		{name: "unprefixed", source: "/tmp/policy.torrent", err: true},
+		{name: "no infohash", source: "magnet:?dn=policy", err: true},
+		{name: "invalid infohash", source: "magnet:?xt=urn:btih:abc", err: true},
+		{name: "unknown parameter", source: "torrent::/tmp/policy.torrent?ref=main", err: true},
+		{name: "no location", source: "torrent::", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := parseSource(tt.source)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", loc)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (loc.magnet != nil) != tt.magnet || loc.torrent != tt.torrent {
+				t.Errorf("unexpected location: %+v", loc)
+			}
+			if got := ""; loc.infoHash != nil {
+				got = hex.EncodeToString(loc.infoHash[:])
+				if got != tt.infoHash {
+					t.Errorf("unexpected infohash: got %s, want %s", got, tt.infoHash)
+				}
+			} else if tt.infoHash != "" {
+				t.Errorf("expected infohash %s", tt.infoHash)
+			}
+		})
+	}
+}
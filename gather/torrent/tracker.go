@@ -0,0 +1,237 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package torrent
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1" // #nosec G505 -- BitTorrent v1 identifies torrents and pieces by SHA-1
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// announcePort is the port announced to trackers. The gatherer does not accept connections, but
+// trackers require a port.
+const announcePort = 6881
+
+// announceTimeout bounds the duration of an announce to a tracker.
+var announceTimeout = 15 * time.Second
+
+// announceRequest holds the state of the download reported to trackers.
+type announceRequest struct {
+	infoHash, peerID           [sha1.Size]byte
+	downloaded, left, uploaded int64
+	event                      string
+}
+
+// TrackerError is returned when a tracker rejects an announce.
+type TrackerError struct {
+	Reason string
+}
+
+func (e *TrackerError) Error() string {
+	return "tracker failure: " + e.Reason
+}
+
+// announce asks the tracker at rawURL for the addresses of the peers of the torrent, over HTTP(S)
+// or UDP.
+func (g *TorrentGatherer) announce(ctx context.Context, rawURL string, req announceRequest) ([]string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tracker %s: %w", gogather.RedactURL(rawURL), err)
+	}
+	if err := gogather.CheckHost(ctx, u.Scheme, u.Hostname()); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, announceTimeout)
+	defer cancel()
+	switch u.Scheme {
+	case "http", "https":
+		return g.announceHTTP(ctx, u, req)
+	case "udp":
+		return announceUDP(ctx, u, req)
+	}
+	return nil, fmt.Errorf("unsupported tracker %s", gogather.RedactURL(rawURL))
+}
+
+// announceHTTP announces to an HTTP tracker, see BEP 3 and BEP 23.
+func (g *TorrentGatherer) announceHTTP(ctx context.Context, u *url.URL, req announceRequest) ([]string, error) {
+	// The infohash and peer ID are raw bytes, which url.Values would encode the same way, but
+	// the existing query, e.g. the passkey of a private tracker, must be kept as is.
+	q := "info_hash=" + url.QueryEscape(string(req.infoHash[:])) +
+		"&peer_id=" + url.QueryEscape(string(req.peerID[:])) +
+		"&port=" + strconv.Itoa(announcePort) +
+		"&uploaded=" + strconv.FormatInt(req.uploaded, 10) +
+		"&downloaded=" + strconv.FormatInt(req.downloaded, 10) +
+		"&left=" + strconv.FormatInt(req.left, 10) +
+		"&compact=1"
+	if req.event != "" {
+		q += "&event=" + req.event
+	}
+	announceURL := *u
+	if announceURL.RawQuery != "" {
+		q = announceURL.RawQuery + "&" + q
+	}
+	announceURL.RawQuery = q
+
+	resp, err := g.get(ctx, announceURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to announce to %s: %w", gogather.RedactURL(u.String()), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to announce to %s: %w", gogather.RedactURL(u.String()), &gogather.StatusError{StatusCode: resp.StatusCode})
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to announce to %s: %w", gogather.RedactURL(u.String()), err)
+	}
+	v, _, err := decodeBencode(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the response of %s: %w", gogather.RedactURL(u.String()), err)
+	}
+	d, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid response of %s", gogather.RedactURL(u.String()))
+	}
+	if reason, ok := dictString(d, "failure reason"); ok {
+		return nil, &TrackerError{Reason: reason}
+	}
+
+	var peers []string
+	switch p := d["peers"].(type) {
+	case string:
+		peers = compactPeers([]byte(p), net.IPv4len)
+	case []any:
+		for _, e := range p {
+			pd, _ := e.(map[string]any)
+			ip, _ := dictString(pd, "ip")
+			port, _ := dictInt(pd, "port")
+			if ip != "" && port > 0 && port < 1<<16 {
+				peers = append(peers, net.JoinHostPort(ip, strconv.FormatInt(port, 10)))
+			}
+		}
+	}
+	if p, ok := dictString(d, "peers6"); ok {
+		peers = append(peers, compactPeers([]byte(p), net.IPv6len)...)
+	}
+	return peers, nil
+}
+
+// udpProtocolID is the magic constant of the connect requests of the UDP tracker protocol.
+const udpProtocolID = 0x41727101980
+
+// announceUDP announces to a UDP tracker, see BEP 15. UDP trackers are not reached through the
+// proxy of the gather options.
+func announceUDP(ctx context.Context, u *url.URL, req announceRequest) ([]string, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", u.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", u.Host, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	connect := make([]byte, 16)
+	binary.BigEndian.PutUint64(connect, udpProtocolID)
+	resp, err := udpTransact(conn, connect, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", u.Host, err)
+	}
+	if len(resp) < 16 {
+		return nil, fmt.Errorf("short connect response of %s", u.Host)
+	}
+	connectionID := binary.BigEndian.Uint64(resp[8:])
+
+	events := map[string]uint32{"completed": 1, "started": 2, "stopped": 3}
+	announce := make([]byte, 98)
+	binary.BigEndian.PutUint64(announce, connectionID)
+	copy(announce[16:], req.infoHash[:])
+	copy(announce[36:], req.peerID[:])
+	binary.BigEndian.PutUint64(announce[56:], uint64(req.downloaded))
+	binary.BigEndian.PutUint64(announce[64:], uint64(req.left))
+	binary.BigEndian.PutUint64(announce[72:], uint64(req.uploaded))
+	binary.BigEndian.PutUint32(announce[80:], events[req.event])
+	binary.BigEndian.PutUint32(announce[92:], 0xffffffff) // as many peers as the tracker sends
+	binary.BigEndian.PutUint16(announce[96:], announcePort)
+	if resp, err = udpTransact(conn, announce, 1); err != nil {
+		return nil, fmt.Errorf("failed to announce to %s: %w", u.Host, err)
+	}
+	if len(resp) < 20 {
+		return nil, fmt.Errorf("short announce response of %s", u.Host)
+	}
+	size := net.IPv4len
+	if addr, ok := conn.RemoteAddr().(*net.UDPAddr); ok && addr.IP.To4() == nil {
+		size = net.IPv6len
+	}
+	return compactPeers(resp[20:], size), nil
+}
+
+// udpTransact sends a request of the given action over conn, filling in the action and a random
+// transaction ID, and returns the matching response.
+func udpTransact(conn net.Conn, request []byte, action uint32) ([]byte, error) {
+	var txID [4]byte
+	if _, err := rand.Read(txID[:]); err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint32(request[8:], action)
+	copy(request[12:], txID[:])
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		resp := buf[:n]
+		if n < 8 || [4]byte(resp[4:8]) != txID {
+			continue
+		}
+		switch binary.BigEndian.Uint32(resp) {
+		case action:
+			return resp, nil
+		case 3:
+			return nil, &TrackerError{Reason: string(resp[8:])}
+		}
+		return nil, errors.New("unexpected response")
+	}
+}
+
+// compactPeers decodes the compact peer list data, of addresses of size bytes followed by a two
+// byte port.
+func compactPeers(data []byte, size int) []string {
+	var peers []string
+	for i := 0; i+size+2 <= len(data); i += size + 2 {
+		ip := net.IP(data[i : i+size])
+		port := binary.BigEndian.Uint16(data[i+size:])
+		peers = append(peers, net.JoinHostPort(ip.String(), strconv.Itoa(int(port))))
+	}
+	return peers
+}
@@ -0,0 +1,115 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package torrent
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// TestAnnounce_HTTP tests announcing to HTTP trackers returning compact peer lists
+func TestAnnounce_HTTP(t *testing.T) {
+	var query string
+	tracker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		if r.URL.Query().Get("passkey") == "" {
+			_, _ = w.Write([]byte("d14:failure reason12:unregisterede"))
+			return
+		}
+		peers := string([]byte{10, 0, 0, 1, 0x1a, 0xe1})
+		peers6 := string(append(net.ParseIP("fd00::1").To16(), 0x1a, 0xe2))
+		_, _ = w.Write(encodeBencode(map[string]any{"interval": 1800, "peers": peers, "peers6": peers6}))
+	}))
+	defer tracker.Close()
+
+	req := announceRequest{left: 100, event: "started"}
+	copy(req.infoHash[:], "01234567890123456789")
+	peers, err := (&TorrentGatherer{}).announce(context.Background(), tracker.URL+"/announce?passkey=abc", req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"10.0.0.1:6881", "[fd00::1]:6882"}; !reflect.DeepEqual(peers, expected) {
+		t.Errorf("unexpected peers: got %v, want %v", peers, expected)
+	}
+	if expected := "passkey=abc&info_hash=01234567890123456789&peer_id=%00%00%00%00%00%00%00%00%00%00%00%00%00%00%00%00%00%00%00%00&port=6881&uploaded=0&downloaded=0&left=100&compact=1&event=started"; query != expected {
+		t.Errorf("unexpected query: got %s, want %s", query, expected)
+	}
+
+	var trackerErr *TrackerError
+	if _, err := (&TorrentGatherer{}).announce(context.Background(), tracker.URL+"/announce", req); !errors.As(err, &trackerErr) || trackerErr.Reason != "unregistered" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{HostPolicy: &gogather.HostPolicy{DeniedHosts: []string{"127.0.0.1"}}})
+	var denied *gogather.HostDeniedError
+	if _, err := (&TorrentGatherer{}).announce(ctx, tracker.URL+"/announce?passkey=abc", req); !errors.As(err, &denied) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestAnnounce_UDP tests announcing to UDP trackers
+func TestAnnounce_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	const connectionID = 0x1234
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := buf[:n]
+			resp := make([]byte, 8, 32)
+			copy(resp, req[8:16]) // action and transaction ID
+			switch binary.BigEndian.Uint32(req[8:]) {
+			case 0:
+				if binary.BigEndian.Uint64(req) != udpProtocolID {
+					continue
+				}
+				resp = binary.BigEndian.AppendUint64(resp, connectionID)
+			case 1:
+				if binary.BigEndian.Uint64(req) != connectionID || n != 98 || binary.BigEndian.Uint32(req[80:]) != 2 {
+					continue
+				}
+				resp = binary.BigEndian.AppendUint32(resp, 1800)
+				resp = binary.BigEndian.AppendUint64(resp, 1)
+				resp = append(resp, 10, 0, 0, 2, 0x1a, 0xe1)
+			}
+			_, _ = conn.WriteTo(resp, addr)
+		}
+	}()
+
+	peers, err := (&TorrentGatherer{}).announce(context.Background(), "udp://"+conn.LocalAddr().String()+"/announce", announceRequest{event: "started"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"10.0.0.2:6881"}; !reflect.DeepEqual(peers, expected) {
+		t.Errorf("unexpected peers: got %v, want %v", peers, expected)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// Validator is implemented by Gatherers that can check a source before gathering it.
+type Validator interface {
+	// Validate checks that source can be gathered, e.g. that its host is reachable and accepts
+	// the credentials of the gather options, without transferring its content.
+	Validate(ctx context.Context, source string) error
+}
+
+// Validate classifies source like Gather and checks that it can be gathered without transferring
+// its content or writing anything, so that callers can fail fast before preparing destinations.
+// Sources are checked by the Validate method of their Gatherer, or else by resolving them, see
+// Resolve. Sources of Gatherers implementing neither are only classified.
+func Validate(ctx context.Context, source string, opts ...Option) error {
+	o, src, srcProtocol, gatherer, err := prepare(ctx, source, opts)
+	if err != nil {
+		return err
+	}
+	o.Log().Debug("validating source", "source", gogather.RedactURL(source), "protocol", srcProtocol.String())
+
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+	ctx = gogather.ContextWithOptions(ctx, o)
+	if err := gogather.RunHooks(ctx, hookEvent(gogather.BeforeResolve, source, "", nil)); err != nil {
+		return err
+	}
+	switch g := gatherer.(type) {
+	case Validator:
+		err = g.Validate(ctx, baseSource(srcProtocol, src))
+	case Resolver:
+		_, err = g.Resolve(ctx, baseSource(srcProtocol, src))
+	}
+	return gogather.RedactError(err)
+}
@@ -0,0 +1,63 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// TestValidate tests checking sources without gathering them
+func TestValidate(t *testing.T) {
+	dir := writeSourceDir(t)
+	if err := Validate(context.Background(), dir); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := Validate(context.Background(), filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("unexpected %s request", r.Method)
+		}
+		if r.URL.Path != "/file.txt" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	if err := Validate(context.Background(), server.URL+"/file.txt"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := Validate(context.Background(), server.URL+"/private.txt"); err == nil {
+		t.Error("expected an error for a rejected request")
+	}
+
+	policy := &gogather.HostPolicy{AllowedSchemes: []string{"https"}}
+	if err := Validate(context.Background(), server.URL+"/file.txt", WithHostPolicy(policy)); err == nil {
+		t.Error("expected an error for a denied scheme")
+	}
+	if err := Validate(context.Background(), "ftp://example.com/file"); err == nil {
+		t.Error("expected an error for an unsupported source")
+	}
+}
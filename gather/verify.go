@@ -0,0 +1,71 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// VerifyingGatherer is a Gatherer that verifies the content the wrapped Gatherer wrote to the
+// destination against the checksum of the gather options, unless the wrapped Gatherer verified it
+// itself. Files are hashed with the algorithm of the checksum, and directories are compared by
+// their sha256 tree hash, see metadata.TreeHash. A mismatch fails the gather with a
+// gogather.ChecksumMismatchError.
+type VerifyingGatherer struct {
+	Gatherer Gatherer
+}
+
+// NewVerifyingGatherer returns a VerifyingGatherer wrapping g.
+func NewVerifyingGatherer(g Gatherer) *VerifyingGatherer {
+	return &VerifyingGatherer{Gatherer: g}
+}
+
+// Gather gathers the source using the wrapped Gatherer and verifies what it wrote.
+func (v *VerifyingGatherer) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
+	m, err := v.Gatherer.Gather(ctx, source, destination)
+	if err != nil || gogather.OptionsFromContext(ctx).Checksum == "" || gogather.ChecksumVerified(ctx) {
+		return m, err
+	}
+
+	path, _ := m.Get()["destination"].(string)
+	if path == "" {
+		path = destination
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine destination kind: %w", err)
+	}
+	if !info.IsDir() {
+		if err := gogather.VerifyChecksum(ctx, path); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	treeHash, err := metadata.TreeHash(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := gogather.VerifyChecksumDigest(ctx, path, "sha256", treeHash); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,74 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gather
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// TestVerifyingGatherer tests verifying the content written by gatherers that do not verify it
+// themselves
+func TestVerifyingGatherer(t *testing.T) {
+	g := NewVerifyingGatherer(&digestGatherer{size: 3})
+
+	reference := t.TempDir()
+	if _, err := (&digestGatherer{size: 3}).Gather(context.Background(), "a", reference); err != nil {
+		t.Fatal(err)
+	}
+	treeHash, err := metadata.TreeHash(reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Checksum: "sha256:" + treeHash})
+	if _, err := g.Gather(ctx, "a", filepath.Join(t.TempDir(), "out")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx = gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{Checksum: "sha256:" + strings.Repeat("0", 64)})
+	_, err = g.Gather(ctx, "a", filepath.Join(t.TempDir(), "out"))
+	var mismatch *gogather.ChecksumMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Actual != treeHash {
+		t.Errorf("expected a ChecksumMismatchError, got: %v", err)
+	}
+}
+
+// TestGather_WithChecksum tests verifying gathered directories by their tree hash
+func TestGather_WithChecksum(t *testing.T) {
+	source := writeSourceDir(t)
+	treeHash, err := metadata.TreeHash(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Gather(context.Background(), source, filepath.Join(t.TempDir(), "out"), WithChecksum("sha256:"+treeHash)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = Gather(context.Background(), source, filepath.Join(t.TempDir(), "out"), WithChecksum("sha256:"+strings.Repeat("0", 64)))
+	var mismatch *gogather.ChecksumMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Errorf("expected a ChecksumMismatchError, got: %v", err)
+	}
+}
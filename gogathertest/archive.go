@@ -0,0 +1,159 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogathertest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// archiveTime is the modification time of the files of archives, which keeps them reproducible.
+var archiveTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ArchiveServer is an HTTP server serving archives of a set of files, in the format named by the
+// extension of the requested path, for the HTTP gatherer: ".tar", ".tar.gz" or ".tgz", and ".zip",
+// e.g. srv.URL+"/bundle.tar.gz". Requests for other paths serve the file of that name, if any.
+// The requests are recorded with their path as the source.
+type ArchiveServer struct {
+	*httptest.Server
+	Recorder
+
+	files map[string]string
+}
+
+// NewArchiveServer starts a server for files, mapping slash separated paths to their content,
+// that is closed when the test completes.
+func NewArchiveServer(t testing.TB, files map[string]string) *ArchiveServer {
+	s := &ArchiveServer{files: files}
+	s.Server = httptest.NewServer(s)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// ServeHTTP serves the archive or the file named by the path of req.
+func (s *ArchiveServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.record(Call{Source: req.URL.Path})
+	name := strings.TrimPrefix(req.URL.Path, "/")
+	var (
+		data []byte
+		err  error
+	)
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		data, err = TarGz(s.files)
+	case strings.HasSuffix(name, ".tar"):
+		data, err = Tar(s.files)
+	case strings.HasSuffix(name, ".zip"):
+		data, err = Zip(s.files)
+	default:
+		content, ok := s.files[name]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		data = []byte(content)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, req, path.Base(name), archiveTime, bytes.NewReader(data))
+}
+
+// sortedNames returns the names of files in lexical order, so that archives of the same files
+// are identical.
+func sortedNames(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Tar returns a tar archive of files, mapping slash separated paths to their content. Archives of
+// the same files are identical.
+func Tar(files map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeTar(&buf, files); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// TarGz returns a gzip compressed tar archive of files, mapping slash separated paths to their
+// content. Archives of the same files are identical.
+func TarGz(files map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := writeTar(gz, files); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeTar writes a tar archive of files to w.
+func writeTar(w io.Writer, files map[string]string) error {
+	tw := tar.NewWriter(w)
+	for _, name := range sortedNames(files) {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), ModTime: archiveTime, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if _, err := io.WriteString(tw, files[name]); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// Zip returns a zip archive of files, mapping slash separated paths to their content. Archives of
+// the same files are identical.
+func Zip(files map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range sortedNames(files) {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: archiveTime})
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if _, err := io.WriteString(f, files[name]); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
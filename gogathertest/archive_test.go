@@ -0,0 +1,114 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogathertest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// TestArchiveServer tests serving the files in each archive format
+func TestArchiveServer(t *testing.T) {
+	files := map[string]string{"policy/main.rego": "package main", "data.json": "{}"}
+	s := NewArchiveServer(t, files)
+
+	for _, name := range []string{"bundle.tar", "bundle.tar.gz", "bundle.tgz"} {
+		_, body := get(t, s.URL+"/"+name)
+		var r io.Reader = bytes.NewReader(body)
+		if name != "bundle.tar" {
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				t.Fatalf("failed to decompress %s: %v", name, err)
+			}
+			r = gz
+		}
+		got := map[string]string{}
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("failed to read %s: %v", name, err)
+			}
+			data, _ := io.ReadAll(tr)
+			got[hdr.Name] = string(data)
+		}
+		assertFiles(t, name, files, got)
+	}
+
+	_, body := get(t, s.URL+"/bundle.zip")
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("failed to read zip: %v", err)
+	}
+	got := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		got[f.Name] = string(data)
+	}
+	assertFiles(t, "bundle.zip", files, got)
+
+	if _, body := get(t, s.URL+"/policy/main.rego"); string(body) != "package main" {
+		t.Errorf("unexpected file: %q", body)
+	}
+	if resp, _ := get(t, s.URL+"/missing.rego"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a missing file not to be found, got %s", resp.Status)
+	}
+	if calls := s.Calls(); len(calls) != 6 || calls[0].Source != "/bundle.tar" {
+		t.Errorf("unexpected calls: %v", calls)
+	}
+}
+
+// TestTarGz tests that archives of the same files are identical
+func TestTarGz(t *testing.T) {
+	first, err := TarGz(map[string]string{"a": "1", "b": "2", "c/d": "3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := TarGz(map[string]string{"c/d": "3", "b": "2", "a": "1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Error("expected identical archives")
+	}
+}
+
+// assertFiles compares the files read from the archive name.
+func assertFiles(t *testing.T, name string, want, got map[string]string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("unexpected files in %s: %v", name, got)
+	}
+	for file, content := range want {
+		if got[file] != content {
+			t.Errorf("unexpected content of %s in %s: %q", file, name, got[file])
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogathertest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// ErrUnexpectedSource is returned by FakeGatherer for sources it has no response for.
+var ErrUnexpectedSource = errors.New("unexpected source")
+
+// Response is the response of FakeGatherer to a source.
+type Response struct {
+	// Data is written to the destination file, unless FS is set.
+	Data []byte
+	// FS is copied into the destination directory, e.g. an fstest.MapFS.
+	FS fs.FS
+	// Metadata is returned by the gather. If nil, a *Metadata describing the written content is
+	// returned.
+	Metadata metadata.Metadata
+	// Err fails the gather without writing anything.
+	Err error
+}
+
+// FakeGatherer is an in-memory Gatherer that writes the content of its responses to the
+// destination instead of downloading it, and records its calls. It is safe for concurrent use as
+// long as Responses is not modified.
+type FakeGatherer struct {
+	// Responses maps sources to the responses of the gatherer.
+	Responses map[string]Response
+	// Default, if set, is the response to sources without a response of their own. Otherwise
+	// gathering them fails with ErrUnexpectedSource.
+	Default *Response
+	Recorder
+}
+
+// Gather records the call and writes the content of the response to source to destination.
+func (g *FakeGatherer) Gather(ctx context.Context, source, destination string) (metadata.Metadata, error) {
+	g.record(Call{Source: source, Destination: destination})
+	startedAt := time.Now()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	resp, ok := g.Responses[source]
+	if !ok && g.Default != nil {
+		resp, ok = *g.Default, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnexpectedSource, source)
+	}
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+
+	m := &Metadata{}
+	if resp.FS != nil {
+		if err := copyFS(destination, resp.FS); err != nil {
+			return nil, err
+		}
+		var err error
+		if m.TreeHash, err = metadata.TreeHash(destination); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(destination, resp.Data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write file: %w", err)
+		}
+		sum := sha256.Sum256(resp.Data)
+		m.SHA256 = hex.EncodeToString(sum[:])
+	}
+	if resp.Metadata != nil {
+		return resp.Metadata, nil
+	}
+
+	resolved, _ := m.GetPinnedURL(source)
+	m.Common = metadata.NewCommon("fake", source, resolved, destination, startedAt)
+	return m, nil
+}
+
+// copyFS copies the regular files and directories of fsys into the directory dir.
+func copyFS(dir string, fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(path))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	})
+}
+
+// Metadata is the metadata FakeGatherer returns for responses without metadata of their own.
+type Metadata struct {
+	metadata.Common
+	// SHA256 is the hex encoded SHA256 digest of a gathered file.
+	SHA256 string `json:"sha256,omitempty"`
+	// TreeHash is the metadata.TreeHash of a gathered directory.
+	TreeHash string `json:"treeHash,omitempty"`
+}
+
+func (m Metadata) Get() map[string]any {
+	fields := m.Common.Fields()
+	if m.SHA256 != "" {
+		fields["sha256"] = m.SHA256
+	}
+	if m.TreeHash != "" {
+		fields["treeHash"] = m.TreeHash
+	}
+	return fields
+}
+
+// GetPinnedURL returns the URL with the tree hash of the gathered directory, or else the digest of
+// the gathered file, appended as a "checksum=sha256:<digest>" query parameter, replacing any
+// checksum the URL already has. It returns an error if the URL is empty or neither digest is set.
+func (m Metadata) GetPinnedURL(u string) (string, error) {
+	if len(u) == 0 {
+		return "", fmt.Errorf("empty URL")
+	}
+	digest := m.TreeHash
+	if digest == "" {
+		digest = m.SHA256
+	}
+	if digest == "" {
+		return "", fmt.Errorf("digest not set")
+	}
+
+	base, query, _ := strings.Cut(u, "?")
+	params := []string{}
+	for _, p := range strings.Split(query, "&") {
+		if p != "" && !strings.HasPrefix(p, "checksum=") {
+			params = append(params, p)
+		}
+	}
+	params = append(params, "checksum=sha256:"+digest)
+	return base + "?" + strings.Join(params, "&"), nil
+}
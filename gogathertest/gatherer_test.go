@@ -0,0 +1,150 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogathertest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// TestFakeGatherer_Gather tests writing the content of the responses and recording the calls
+func TestFakeGatherer_Gather(t *testing.T) {
+	g := &FakeGatherer{Responses: map[string]Response{
+		"https://example.com/file.txt": {Data: []byte("content")},
+		"git::https://example.com/org/repo": {FS: fstest.MapFS{
+			"main.rego":      {Data: []byte("package main")},
+			"lib/util.rego":  {Data: []byte("package lib")},
+			"lib/empty.json": {Data: []byte("{}")},
+		}},
+	}}
+	dir := t.TempDir()
+
+	m, err := g.Gather(context.Background(), "https://example.com/file.txt", filepath.Join(dir, "file.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "file.txt")); err != nil || string(data) != "content" {
+		t.Fatalf("unexpected content: %q, %v", data, err)
+	}
+	fm := m.(*Metadata)
+	if fm.SHA256 != "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73" || fm.Gatherer != "fake" {
+		t.Errorf("unexpected metadata: %+v", fm)
+	}
+	if fm.ResolvedURI != "https://example.com/file.txt?checksum=sha256:"+fm.SHA256 {
+		t.Errorf("unexpected resolved URI: %s", fm.ResolvedURI)
+	}
+
+	m, err = g.Gather(context.Background(), "git::https://example.com/org/repo", filepath.Join(dir, "repo"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "repo", "lib", "util.rego")); err != nil || string(data) != "package lib" {
+		t.Fatalf("unexpected content: %q, %v", data, err)
+	}
+	if treeHash, _ := metadata.TreeHash(filepath.Join(dir, "repo")); m.(*Metadata).TreeHash != treeHash {
+		t.Errorf("unexpected tree hash: %s", m.(*Metadata).TreeHash)
+	}
+
+	g.AssertCalls(t,
+		Call{Source: "https://example.com/file.txt", Destination: filepath.Join(dir, "file.txt")},
+		Call{Source: "git::https://example.com/org/repo", Destination: filepath.Join(dir, "repo")},
+	)
+	g.AssertCalled(t, "git::https://example.com/org/repo")
+}
+
+// TestFakeGatherer_Gather_Errors tests failing gathers
+func TestFakeGatherer_Gather_Errors(t *testing.T) {
+	failure := errors.New("connection refused")
+	g := &FakeGatherer{Responses: map[string]Response{"https://example.com/down": {Err: failure}}}
+	dir := t.TempDir()
+
+	if _, err := g.Gather(context.Background(), "https://example.com/down", filepath.Join(dir, "down")); !errors.Is(err, failure) {
+		t.Errorf("expected the error of the response, got %v", err)
+	}
+	if _, err := g.Gather(context.Background(), "https://example.com/other", filepath.Join(dir, "other")); !errors.Is(err, ErrUnexpectedSource) {
+		t.Errorf("expected an unexpected source, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := g.Gather(ctx, "https://example.com/down", filepath.Join(dir, "down")); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the gather to be canceled, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected nothing to be written: %v", entries)
+	}
+	if calls := g.Calls(); len(calls) != 3 {
+		t.Errorf("expected the failed gathers to be recorded: %v", calls)
+	}
+
+	g.Reset()
+	g.AssertNotCalled(t)
+}
+
+// TestFakeGatherer_Gather_Default tests responding to every source with the default response
+func TestFakeGatherer_Gather_Default(t *testing.T) {
+	want := &Metadata{SHA256: "custom"}
+	g := &FakeGatherer{Default: &Response{Data: []byte("default"), Metadata: want}}
+	destination := filepath.Join(t.TempDir(), "file.txt")
+
+	m, err := g.Gather(context.Background(), "s3://bucket/file.txt", destination)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m != want {
+		t.Errorf("expected the metadata of the response, got %+v", m)
+	}
+	if data, _ := os.ReadFile(destination); string(data) != "default" {
+		t.Errorf("unexpected content: %q", data)
+	}
+}
+
+// TestRecorder_AssertCalls tests that mismatched calls fail the test
+func TestRecorder_AssertCalls(t *testing.T) {
+	r := &Recorder{}
+	r.record(Call{Source: "a", Destination: "b"})
+
+	for name, assert := range map[string]func(testing.TB){
+		"calls":      func(tb testing.TB) { r.AssertCalls(tb, Call{Source: "a", Destination: "c"}) },
+		"no calls":   func(tb testing.TB) { r.AssertCalls(tb) },
+		"called":     func(tb testing.TB) { r.AssertCalled(tb, "c") },
+		"not called": func(tb testing.TB) { r.AssertNotCalled(tb) },
+	} {
+		ft := &fakeT{TB: t}
+		assert(ft)
+		if !ft.failed {
+			t.Errorf("expected the %s assertion to fail", name)
+		}
+	}
+}
+
+// fakeT records whether a test failed instead of failing it.
+type fakeT struct {
+	testing.TB
+	failed bool
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.failed = true
+}
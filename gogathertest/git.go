@@ -0,0 +1,122 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogathertest
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// GitRepo is a git repository in a temporary directory, which the git gatherer clones from its
+// file:// URL.
+type GitRepo struct {
+	// Dir is the directory of the worktree of the repository.
+	Dir  string
+	repo *git.Repository
+	time time.Time
+}
+
+// NewGitRepo initializes an empty repository in a temporary directory that is removed when the
+// test completes. The directory is named "repo.git", as the git gatherer appends ".git" to the
+// paths of the repositories it clones.
+func NewGitRepo(t testing.TB) *GitRepo {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "repo.git")
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("failed to initialize repository: %v", err)
+	}
+	return &GitRepo{Dir: dir, repo: repo, time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+// Commit writes files, mapping slash separated paths to their content, to the worktree and commits
+// them with message. Files not named are kept as they are. Commits have fixed, increasing times,
+// so that repositories built the same way have the same commits. It returns the commit hash.
+func (r *GitRepo) Commit(t testing.TB, message string, files map[string]string) string {
+	t.Helper()
+	w, err := r.repo.Worktree()
+	if err != nil {
+		t.Fatalf("failed to open worktree: %v", err)
+	}
+	// The files are added in a fixed order, which keeps the commits reproducible.
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(r.Dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		if _, err := w.Add(name); err != nil {
+			t.Fatalf("failed to add %s: %v", name, err)
+		}
+	}
+
+	r.time = r.time.Add(time.Minute)
+	sig := &object.Signature{Name: "go-gather", Email: "go-gather@example.com", When: r.time}
+	hash, err := w.Commit(message, &git.CommitOptions{Author: sig, Committer: sig, AllowEmptyCommits: true})
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	return hash.String()
+}
+
+// Tag creates the lightweight tag name pointing to the HEAD commit.
+func (r *GitRepo) Tag(t testing.TB, name string) {
+	t.Helper()
+	head, err := r.repo.Head()
+	if err != nil {
+		t.Fatalf("failed to resolve HEAD: %v", err)
+	}
+	if _, err := r.repo.CreateTag(name, head.Hash(), nil); err != nil {
+		t.Fatalf("failed to create tag %s: %v", name, err)
+	}
+}
+
+// Branch creates the branch name pointing to the HEAD commit and checks it out, so that the next
+// commits are made on it.
+func (r *GitRepo) Branch(t testing.TB, name string) {
+	t.Helper()
+	w, err := r.repo.Worktree()
+	if err != nil {
+		t.Fatalf("failed to open worktree: %v", err)
+	}
+	if err := w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(name), Create: true}); err != nil {
+		t.Fatalf("failed to create branch %s: %v", name, err)
+	}
+}
+
+// Source returns the source of the repository for the git gatherer, selecting ref, if not empty.
+func (r *GitRepo) Source(ref string) string {
+	source := "git::file://" + filepath.ToSlash(r.Dir)
+	if ref != "" {
+		source += "?ref=" + ref
+	}
+	return source
+}
@@ -0,0 +1,64 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogathertest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// TestGitRepo tests cloning the commits, tags and branches of a repository
+func TestGitRepo(t *testing.T) {
+	r := NewGitRepo(t)
+	first := r.Commit(t, "first", map[string]string{"policy/main.rego": "package main", "README.md": "v1"})
+	r.Tag(t, "v1.0.0")
+	r.Branch(t, "feature")
+	second := r.Commit(t, "second", map[string]string{"README.md": "v2"})
+
+	if again := NewGitRepo(t).Commit(t, "first", map[string]string{"README.md": "v1", "policy/main.rego": "package main"}); again != first {
+		t.Errorf("expected the same commit for the same files, got %s and %s", first, again)
+	}
+	if got := r.Source("v1.0.0"); got != "git::file://"+filepath.ToSlash(r.Dir)+"?ref=v1.0.0" {
+		t.Errorf("unexpected source: %s", got)
+	}
+
+	dir := filepath.Join(t.TempDir(), "clone")
+	clone, err := git.PlainClone(dir, false, &git.CloneOptions{URL: strings.TrimPrefix(r.Source(""), "git::")})
+	if err != nil {
+		t.Fatalf("failed to clone: %v", err)
+	}
+	for ref, want := range map[plumbing.ReferenceName]string{
+		plumbing.NewTagReferenceName("v1.0.0"):               first,
+		plumbing.NewRemoteReferenceName("origin", "feature"): second,
+	} {
+		got, err := clone.Reference(ref, true)
+		if err != nil {
+			t.Fatalf("failed to resolve %s: %v", ref, err)
+		}
+		if got.Hash().String() != want {
+			t.Errorf("expected %s at %s, got %s", ref, want, got.Hash())
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "policy", "main.rego")); err != nil || string(data) != "package main" {
+		t.Errorf("unexpected content: %q, %v", data, err)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package gogathertest provides test doubles for code that gathers and saves content with
+// go-gather, so that it can be tested without network access. It includes an in-memory
+// FakeGatherer and FakeSaver, which record their calls for assertions, and fixtures serving
+// content to the real gatherers: GitRepo builds a git repository in a temporary directory,
+// Registry serves OCI artifacts and ArchiveServer serves archives over HTTP.
+//
+// Example usage:
+//
+//	g := &gogathertest.FakeGatherer{Responses: map[string]gogathertest.Response{
+//	  "git::https://github.com/org/policies": {FS: fstest.MapFS{"main.rego": {Data: []byte("package main")}}},
+//	}}
+//	if err := gather.RegisterGatherer(gogather.GitURI, g); err != nil {
+//	  t.Fatal(err)
+//	}
+//	// ... exercise the code under test ...
+//	g.AssertCalls(t, gogathertest.Call{Source: "git::https://github.com/org/policies", Destination: dir})
+package gogathertest
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// Call is a recorded call of a fake. Calls of FakeGatherer record the source and the destination,
+// and calls of FakeSaver the destination and the saved data.
+type Call struct {
+	Source      string
+	Destination string
+	Data        []byte
+}
+
+func (c Call) String() string {
+	if c.Data != nil {
+		return fmt.Sprintf("%s <- %q", c.Destination, c.Data)
+	}
+	return fmt.Sprintf("%s -> %s", c.Source, c.Destination)
+}
+
+// Recorder records the calls of a fake. It is safe for concurrent use.
+type Recorder struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+// record appends c to the recorded calls.
+func (r *Recorder) record(c Call) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, c)
+}
+
+// Calls returns the recorded calls in the order they were made.
+func (r *Recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.calls)
+}
+
+// Reset forgets the recorded calls.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = nil
+}
+
+// AssertCalls fails the test unless the recorded calls are exactly want, in order.
+func (r *Recorder) AssertCalls(t testing.TB, want ...Call) {
+	t.Helper()
+	got := r.Calls()
+	if slices.EqualFunc(got, want, equalCalls) {
+		return
+	}
+	t.Errorf("unexpected calls:\ngot  %v\nwant %v", got, want)
+}
+
+// AssertCalled fails the test unless a call with target as its source or destination was recorded.
+func (r *Recorder) AssertCalled(t testing.TB, target string) {
+	t.Helper()
+	calls := r.Calls()
+	for _, c := range calls {
+		if c.Source == target || c.Destination == target {
+			return
+		}
+	}
+	t.Errorf("expected a call for %s, got %v", target, calls)
+}
+
+// AssertNotCalled fails the test if any call was recorded.
+func (r *Recorder) AssertNotCalled(t testing.TB) {
+	t.Helper()
+	if calls := r.Calls(); len(calls) > 0 {
+		t.Errorf("expected no calls, got %v", calls)
+	}
+}
+
+// equalCalls reports whether the calls a and b are equal.
+func equalCalls(a, b Call) bool {
+	return a.Source == b.Source && a.Destination == b.Destination && bytes.Equal(a.Data, b.Data)
+}
@@ -0,0 +1,183 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogathertest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// The media types of the artifacts pushed to a Registry.
+const (
+	ManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	ConfigMediaType   = "application/vnd.oci.empty.v1+json"
+	LayerMediaType    = "application/vnd.oci.image.layer.v1.tar"
+)
+
+// Registry is an in-memory OCI registry serving the pull endpoints of the OCI distribution
+// specification over plain HTTP on the loopback interface, which the OCI gatherer connects to
+// without TLS. Artifacts are added with Push.
+type Registry struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string]map[string]string
+}
+
+// descriptor is an OCI content descriptor.
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int               `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// manifest is an OCI image manifest.
+type manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        descriptor   `json:"config"`
+	Layers        []descriptor `json:"layers"`
+}
+
+// NewRegistry starts a registry that is closed when the test completes.
+func NewRegistry(t testing.TB) *Registry {
+	r := &Registry{blobs: map[string][]byte{}, manifests: map[string]map[string]string{}}
+	r.Server = httptest.NewServer(r)
+	t.Cleanup(r.Close)
+	return r
+}
+
+// Host returns the host and port of the registry, e.g. "127.0.0.1:40000".
+func (r *Registry) Host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+// Push stores an artifact holding files, mapping file names to their content, in repository and
+// tags it with tag, if not empty, like "oras push" does: each file is a layer with its name as the
+// title annotation. It returns the digest of the manifest of the artifact.
+func (r *Registry) Push(t testing.TB, repository, tag string, files map[string]string) string {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The layers are ordered by name, so that pushing the same files yields the same digest.
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	m := manifest{SchemaVersion: 2, MediaType: ManifestMediaType, Config: r.addBlob(ConfigMediaType, []byte("{}"))}
+	for _, name := range names {
+		d := r.addBlob(LayerMediaType, []byte(files[name]))
+		d.Annotations = map[string]string{"org.opencontainers.image.title": name}
+		m.Layers = append(m.Layers, d)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("failed to encode manifest: %v", err)
+	}
+
+	digest := r.addBlob(ManifestMediaType, data).Digest
+	if r.manifests[repository] == nil {
+		r.manifests[repository] = map[string]string{}
+	}
+	r.manifests[repository][digest] = digest
+	if tag != "" {
+		r.manifests[repository][tag] = digest
+	}
+	return digest
+}
+
+// Source returns the source of the artifact at ref, a tag or a digest, in repository for the OCI
+// gatherer.
+func (r *Registry) Source(repository, ref string) string {
+	if strings.Contains(ref, ":") {
+		return "oci::" + r.Host() + "/" + repository + "@" + ref
+	}
+	return "oci::" + r.Host() + "/" + repository + ":" + ref
+}
+
+// addBlob stores data and returns its descriptor.
+func (r *Registry) addBlob(mediaType string, data []byte) descriptor {
+	sum := sha256.Sum256(data)
+	d := descriptor{MediaType: mediaType, Digest: "sha256:" + hex.EncodeToString(sum[:]), Size: len(data)}
+	r.blobs[d.Digest] = data
+	return d
+}
+
+// ServeHTTP serves the manifests and blobs of the repositories. Manifests are looked up by tag or
+// digest.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if req.URL.Path == "/v2/" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	var (
+		data      []byte
+		mediaType = "application/octet-stream"
+	)
+	if i := strings.LastIndex(path, "/manifests/"); i >= 0 {
+		digest, ok := r.manifests[path[:i]][path[i+len("/manifests/"):]]
+		if !ok {
+			writeRegistryError(w, "MANIFEST_UNKNOWN")
+			return
+		}
+		data, mediaType = r.blobs[digest], ManifestMediaType
+	} else if i := strings.LastIndex(path, "/blobs/"); i >= 0 && r.manifests[path[:i]] != nil {
+		var ok bool
+		if data, ok = r.blobs[path[i+len("/blobs/"):]]; !ok {
+			writeRegistryError(w, "BLOB_UNKNOWN")
+			return
+		}
+	} else {
+		writeRegistryError(w, "NAME_UNKNOWN")
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Docker-Content-Digest", "sha256:"+hex.EncodeToString(sum[:]))
+	if req.Method == http.MethodGet {
+		_, _ = w.Write(data)
+	}
+}
+
+// writeRegistryError responds with a not found error with the code of the distribution
+// specification.
+func writeRegistryError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = w.Write([]byte(`{"errors":[{"code":"` + code + `","message":"not found"}]}`))
+}
@@ -0,0 +1,77 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogathertest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// TestRegistry tests pulling pushed artifacts by tag and digest
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(t)
+	digest := r.Push(t, "org/policy", "latest", map[string]string{"b.rego": "package b", "a.rego": "package a"})
+	if again := r.Push(t, "org/other", "", map[string]string{"a.rego": "package a", "b.rego": "package b"}); again != digest {
+		t.Errorf("expected the same digest for the same files, got %s and %s", digest, again)
+	}
+	if got := r.Source("org/policy", "latest"); got != "oci::"+r.Host()+"/org/policy:latest" {
+		t.Errorf("unexpected source: %s", got)
+	}
+	if got := r.Source("org/policy", digest); got != "oci::"+r.Host()+"/org/policy@"+digest {
+		t.Errorf("unexpected source: %s", got)
+	}
+
+	for _, ref := range []string{"latest", digest} {
+		resp, body := get(t, r.URL+"/v2/org/policy/manifests/"+ref)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Docker-Content-Digest") != digest {
+			t.Fatalf("unexpected response for %s: %s, %s", ref, resp.Status, resp.Header.Get("Docker-Content-Digest"))
+		}
+		var m manifest
+		if err := json.Unmarshal(body, &m); err != nil {
+			t.Fatalf("failed to decode manifest: %v", err)
+		}
+		if len(m.Layers) != 2 || m.Layers[0].Annotations["org.opencontainers.image.title"] != "a.rego" {
+			t.Fatalf("unexpected layers: %+v", m.Layers)
+		}
+		if _, blob := get(t, r.URL+"/v2/org/policy/blobs/"+m.Layers[1].Digest); string(blob) != "package b" {
+			t.Errorf("unexpected blob: %q", blob)
+		}
+	}
+
+	for _, path := range []string{"/v2/org/policy/manifests/missing", "/v2/org/policy/blobs/sha256:0", "/v2/org/missing/blobs/" + digest} {
+		if resp, _ := get(t, r.URL+path); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected %s not to be found, got %s", path, resp.Status)
+		}
+	}
+}
+
+// get fetches url and returns the response with its body.
+func get(t *testing.T, url string) (*http.Response, []byte) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", url, err)
+	}
+	return resp, body
+}
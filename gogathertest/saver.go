@@ -0,0 +1,69 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogathertest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// FakeSaver is an in-memory Saver that keeps the saved data and records its calls. The zero value
+// is ready to use. It is safe for concurrent use.
+type FakeSaver struct {
+	// Err, if set, fails every save after the data was read and the call recorded.
+	Err error
+	Recorder
+
+	mu    sync.Mutex
+	saved map[string][]byte
+}
+
+// Save records the call with the data read from data.
+func (s *FakeSaver) Save(ctx context.Context, data io.Reader, destination string) error {
+	b, err := io.ReadAll(data)
+	if err != nil {
+		return fmt.Errorf("failed to read data: %w", err)
+	}
+	if b == nil {
+		b = []byte{}
+	}
+	s.record(Call{Destination: destination, Data: b})
+	if s.Err != nil {
+		return s.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.saved == nil {
+		s.saved = map[string][]byte{}
+	}
+	s.saved[destination] = b
+	return nil
+}
+
+// Saved returns the data last saved to destination, and whether any save to it succeeded.
+func (s *FakeSaver) Saved(destination string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.saved[destination]
+	return b, ok
+}
@@ -0,0 +1,66 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogathertest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestFakeSaver_Save tests keeping the saved data and recording the calls
+func TestFakeSaver_Save(t *testing.T) {
+	s := &FakeSaver{}
+	if err := s.Save(context.Background(), strings.NewReader("first"), "out/file.txt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Save(context.Background(), strings.NewReader("second"), "out/file.txt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.Save(context.Background(), strings.NewReader(""), "out/empty.txt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if data, ok := s.Saved("out/file.txt"); !ok || string(data) != "second" {
+		t.Errorf("unexpected saved data: %q, %v", data, ok)
+	}
+	if data, ok := s.Saved("out/empty.txt"); !ok || len(data) != 0 {
+		t.Errorf("unexpected saved data: %q, %v", data, ok)
+	}
+	if _, ok := s.Saved("out/missing.txt"); ok {
+		t.Error("expected nothing to be saved to out/missing.txt")
+	}
+	s.AssertCalls(t,
+		Call{Destination: "out/file.txt", Data: []byte("first")},
+		Call{Destination: "out/file.txt", Data: []byte("second")},
+		Call{Destination: "out/empty.txt", Data: []byte{}},
+	)
+}
+
+// TestFakeSaver_Save_Error tests failing saves
+func TestFakeSaver_Save_Error(t *testing.T) {
+	failure := errors.New("disk full")
+	s := &FakeSaver{Err: failure}
+	if err := s.Save(context.Background(), strings.NewReader("data"), "file.txt"); !errors.Is(err, failure) {
+		t.Errorf("expected the error of the saver, got %v", err)
+	}
+	if _, ok := s.Saved("file.txt"); ok {
+		t.Error("expected a failed save not to be kept")
+	}
+	s.AssertCalled(t, "file.txt")
+}
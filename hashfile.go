@@ -0,0 +1,71 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// mmapThreshold is the size from which HashFile memory-maps files. Reading smaller files through
+// a buffer is about as fast.
+var mmapThreshold int64 = 64 << 20
+
+// mmapWindow is the size of the windows HashFile maps files in, which bounds the address space
+// taken by very large files. It is a multiple of the page size of every platform.
+var mmapWindow int64 = 256 << 20
+
+// HashFile writes the content of f to h, e.g. a hash.Hash computing its digest, and returns the
+// number of bytes written. Regular files are hashed from their start, whatever their offset, and
+// other files, e.g. pipes, from their current position. Files of at least 64 MiB are memory-mapped on the
+// platforms supporting it, so that they are hashed without being copied through a buffer. Other
+// files, and the part of a file that cannot be mapped, are read in chunks through a pooled buffer,
+// see CopySized.
+func HashFile(h io.Writer, f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat %s: %w", f.Name(), err)
+	}
+
+	if !info.Mode().IsRegular() {
+		n, err := CopySized(h, f, -1)
+		if err != nil {
+			return n, fmt.Errorf("failed to read %s: %w", f.Name(), err)
+		}
+		return n, nil
+	}
+
+	var n int64
+	if info.Size() >= mmapThreshold {
+		if n, err = hashMapped(h, f, info.Size()); err != nil {
+			return n, err
+		}
+		if n == info.Size() {
+			return n, nil
+		}
+	}
+	if _, err := f.Seek(n, io.SeekStart); err != nil {
+		return n, fmt.Errorf("failed to read %s: %w", f.Name(), err)
+	}
+
+	m, err := CopySized(h, f, info.Size()-n)
+	if err != nil {
+		return n + m, fmt.Errorf("failed to read %s: %w", f.Name(), err)
+	}
+	return n + m, nil
+}
@@ -0,0 +1,110 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withMmap sets the size from which files are memory-mapped, and the size of the windows they are
+// mapped in, for the test.
+func withMmap(tb testing.TB, threshold, window int64) {
+	tb.Helper()
+	oldThreshold, oldWindow := mmapThreshold, mmapWindow
+	mmapThreshold, mmapWindow = threshold, window
+	tb.Cleanup(func() { mmapThreshold, mmapWindow = oldThreshold, oldWindow })
+}
+
+// writeData writes size bytes of varying content to a file and returns its path.
+func writeData(tb testing.TB, size int) string {
+	tb.Helper()
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	path := filepath.Join(tb.TempDir(), "data")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+// TestHashFile tests hashing files read in chunks and memory-mapped in windows
+func TestHashFile(t *testing.T) {
+	page := os.Getpagesize()
+	withMmap(t, int64(2*page), int64(page))
+
+	for _, size := range []int{0, 100, 2*page - 1, 2 * page, 3*page + 17} {
+		t.Run(fmt.Sprintf("size=%d", size), func(t *testing.T) {
+			path := writeData(t, size)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			// Files are hashed from their start, whatever their offset.
+			if _, err := f.Seek(int64(size/2), io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			h := sha256.New()
+			n, err := HashFile(h, f)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := sha256.Sum256(data)
+			if n != int64(size) || !bytes.Equal(h.Sum(nil), want[:]) {
+				t.Errorf("unexpected digest of %d bytes: %x", n, h.Sum(nil))
+			}
+		})
+	}
+}
+
+// BenchmarkHashFile measures hashing a large file read in chunks and memory-mapped
+func BenchmarkHashFile(b *testing.B) {
+	path := writeData(b, 32<<20)
+	for _, mapped := range []bool{false, true} {
+		b.Run(fmt.Sprintf("mapped=%v", mapped), func(b *testing.B) {
+			threshold := int64(64 << 30)
+			if mapped {
+				threshold = 0
+			}
+			withMmap(b, threshold, mmapWindow)
+			b.SetBytes(32 << 20)
+			for i := 0; i < b.N; i++ {
+				f, err := os.Open(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := HashFile(sha256.New(), f); err != nil {
+					b.Fatal(err)
+				}
+				f.Close()
+			}
+		})
+	}
+}
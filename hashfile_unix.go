@@ -0,0 +1,69 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build unix
+
+package gogather
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"syscall"
+)
+
+// hashMapped writes the first size bytes of f to h from memory-mapped windows of mmapWindow bytes,
+// and returns the number of bytes written. It stops without an error at the first window that
+// cannot be mapped, so that the rest of f can be read instead.
+func hashMapped(h io.Writer, f *os.File, size int64) (n int64, err error) {
+	// Reading the pages of a file truncated while it is mapped faults, which fails the hashing
+	// instead of crashing the program.
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(interface{ Addr() uintptr }); !ok {
+				panic(r)
+			}
+			err = fmt.Errorf("failed to read %s: %v", f.Name(), r)
+		}
+	}()
+
+	for n < size {
+		length := min(size-n, mmapWindow)
+		mapped, err := hashWindow(h, f, n, int(length))
+		if !mapped || err != nil {
+			return n, err
+		}
+		n += length
+	}
+	return n, nil
+}
+
+// hashWindow writes the length bytes of f at offset to h from a memory mapping, and reports
+// whether they could be mapped.
+func hashWindow(h io.Writer, f *os.File, offset int64, length int) (bool, error) {
+	data, err := syscall.Mmap(int(f.Fd()), offset, length, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return false, nil
+	}
+	defer func() { _ = syscall.Munmap(data) }()
+
+	if _, err := h.Write(data); err != nil {
+		return true, err
+	}
+	return true, nil
+}
@@ -0,0 +1,101 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// HookStage identifies the point of a gather at which a Hook is called.
+type HookStage int
+
+const (
+	// BeforeResolve hooks are called once the source is classified, before it is contacted.
+	BeforeResolve HookStage = iota
+	// AfterDownload hooks are called once the gatherer of the protocol has downloaded the content,
+	// e.g. to scan it. They are not called for content served from the content cache.
+	AfterDownload
+	// BeforeSave hooks are called before the content is written to the destination, with the
+	// content staged at Path, e.g. to enforce a content policy.
+	BeforeSave
+	// AfterComplete hooks are called when the gather finishes, whether it succeeded or not, e.g.
+	// to audit it.
+	AfterComplete
+)
+
+func (s HookStage) String() string {
+	switch s {
+	case BeforeResolve:
+		return "before-resolve"
+	case AfterDownload:
+		return "after-download"
+	case BeforeSave:
+		return "before-save"
+	case AfterComplete:
+		return "after-complete"
+	}
+	return fmt.Sprintf("HookStage(%d)", int(s))
+}
+
+// HookEvent describes the gather a Hook is called for.
+type HookEvent struct {
+	// Stage is the point of the gather the hook is called at.
+	Stage HookStage
+	// Source is the source of the gather, with its credentials redacted.
+	Source string
+	// Destination is the destination of the gather.
+	Destination string
+	// Path is the location of the gathered content: where it was downloaded to after the
+	// download, where it is staged before it is saved, and where it was saved to on completion.
+	// It is empty before the source is resolved and when the gather failed.
+	Path string
+	// Metadata is the metadata.Metadata of the gathered content, if any.
+	Metadata any
+	// Err is the error the gather failed with, for AfterComplete hooks.
+	Err error
+}
+
+// Hook is called at a stage of the gathers it is registered for, see GatherOptions.Hooks. Returning
+// an error fails the gather, except for the AfterComplete hooks of failed gathers.
+type Hook func(ctx context.Context, e HookEvent) error
+
+// Hooks holds the hooks registered for each stage of a gather, called in order of registration.
+type Hooks map[HookStage][]Hook
+
+// With returns a copy of h with hook registered for stage.
+func (h Hooks) With(stage HookStage, hook Hook) Hooks {
+	c := maps.Clone(h)
+	if c == nil {
+		c = Hooks{}
+	}
+	c[stage] = append(slices.Clone(c[stage]), hook)
+	return c
+}
+
+// RunHooks calls the hooks registered for e.Stage in the gather options carried by ctx, stopping at
+// the first that fails.
+func RunHooks(ctx context.Context, e HookEvent) error {
+	for _, hook := range OptionsFromContext(ctx).Hooks[e.Stage] {
+		if err := hook(ctx, e); err != nil {
+			return fmt.Errorf("%s hook failed: %w", e.Stage, err)
+		}
+	}
+	return nil
+}
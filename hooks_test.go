@@ -0,0 +1,58 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestRunHooks tests calling the hooks registered for a stage in order
+func TestRunHooks(t *testing.T) {
+	var calls []string
+	hook := func(name string, err error) Hook {
+		return func(_ context.Context, e HookEvent) error {
+			calls = append(calls, name+":"+e.Stage.String())
+			return err
+		}
+	}
+
+	base := Hooks{}.With(BeforeSave, hook("first", nil))
+	hooks := base.With(BeforeSave, hook("second", nil)).With(AfterComplete, hook("third", nil))
+	if len(base[BeforeSave]) != 1 {
+		t.Errorf("expected With to leave the hooks it extends unchanged, got %d hooks", len(base[BeforeSave]))
+	}
+
+	ctx := ContextWithOptions(context.Background(), GatherOptions{Hooks: hooks})
+	if err := RunHooks(ctx, HookEvent{Stage: BeforeSave}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(calls, ",") != "first:before-save,second:before-save" {
+		t.Errorf("unexpected calls: %v", calls)
+	}
+
+	errRejected := errors.New("rejected")
+	ctx = ContextWithOptions(context.Background(), GatherOptions{Hooks: hooks.With(BeforeResolve, hook("failing", errRejected))})
+	if err := RunHooks(ctx, HookEvent{Stage: BeforeResolve}); !errors.Is(err, errRejected) || err.Error() != "before-resolve hook failed: rejected" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := RunHooks(context.Background(), HookEvent{Stage: AfterDownload}); err != nil {
+		t.Errorf("unexpected error without hooks: %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// HostPolicy restricts the schemes and hosts gatherers may contact, e.g. to keep sources supplied
+// by untrusted users from reaching internal services or cloud metadata endpoints. Gatherers check
+// it before any network call, and the HTTP clients of gatherers also check every redirect, see
+// HTTPClient.
+type HostPolicy struct {
+	// AllowedSchemes lists the schemes gatherers may use, e.g. "https", "ssh" or "file". When
+	// empty, every scheme is allowed.
+	AllowedSchemes []string
+	// AllowedHosts lists the hosts gatherers may contact. When empty, every host is allowed. A
+	// pattern is either a host name or IP address, matched exactly, or "*." followed by a domain,
+	// matching every host below that domain, e.g. "*.example.com".
+	AllowedHosts []string
+	// DeniedHosts lists the hosts gatherers may not contact, using the same patterns as
+	// AllowedHosts. Denials take precedence over AllowedHosts.
+	DeniedHosts []string
+	// DenyPrivateNetworks denies hosts that are, or resolve to, loopback, link-local, e.g. the
+	// 169.254.169.254 cloud metadata endpoint, private or unspecified addresses. Transports
+	// configured for the gather options also check every address they connect to, so that hosts
+	// cannot resolve to such addresses after they were checked, e.g. with DNS rebinding, see
+	// GatherOptions.ConfigureTransport.
+	DenyPrivateNetworks bool
+}
+
+// HostDeniedError is returned when the HostPolicy of a gather denies contacting a host.
+type HostDeniedError struct {
+	Scheme string
+	Host   string
+	Reason string
+}
+
+func (e *HostDeniedError) Error() string {
+	if e.Host == "" {
+		return fmt.Sprintf("access to %s denied: %s", e.Scheme, e.Reason)
+	}
+	return fmt.Sprintf("access to %s://%s denied: %s", e.Scheme, e.Host, e.Reason)
+}
+
+// lookupIPAddr resolves host names for DenyPrivateNetworks.
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// Check returns a HostDeniedError if the policy denies contacting host with scheme. The host must
+// not include a port. An empty host, e.g. of a local file, is only checked against the schemes.
+func (p *HostPolicy) Check(ctx context.Context, scheme, host string) error {
+	if p == nil {
+		return nil
+	}
+	scheme = strings.ToLower(scheme)
+	host = strings.ToLower(strings.TrimSuffix(strings.Trim(host, "[]"), "."))
+
+	if len(p.AllowedSchemes) > 0 && !slices.ContainsFunc(p.AllowedSchemes, func(s string) bool { return strings.EqualFold(s, scheme) }) {
+		return &HostDeniedError{Scheme: scheme, Host: host, Reason: "scheme not allowed"}
+	}
+	if host == "" {
+		return nil
+	}
+	if matchHost(p.DeniedHosts, host) {
+		return &HostDeniedError{Scheme: scheme, Host: host, Reason: "host denied"}
+	}
+	if len(p.AllowedHosts) > 0 && !matchHost(p.AllowedHosts, host) {
+		return &HostDeniedError{Scheme: scheme, Host: host, Reason: "host not allowed"}
+	}
+	if p.DenyPrivateNetworks {
+		return checkPublic(ctx, scheme, host)
+	}
+	return nil
+}
+
+// matchHost reports whether host matches any of the patterns.
+func matchHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+		if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		} else if pattern == host {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPublic returns a HostDeniedError if host is, or resolves to, an address that is not
+// publicly routable. Hosts that cannot be resolved are left for the network call to fail on.
+func checkPublic(ctx context.Context, scheme, host string) error {
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = append(addrs, addr)
+	} else if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return &HostDeniedError{Scheme: scheme, Host: host, Reason: "private network address"}
+	} else if resolved, err := lookupIPAddr(ctx, host); err == nil {
+		for _, ip := range resolved {
+			if addr, ok := netip.AddrFromSlice(ip.IP); ok {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+
+	for _, addr := range addrs {
+		if err := checkAddr(scheme, host, addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAddr returns a HostDeniedError if addr, an address of host, is not publicly routable.
+func checkAddr(scheme, host string, addr netip.Addr) error {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsPrivate() || addr.IsUnspecified() {
+		return &HostDeniedError{Scheme: scheme, Host: host, Reason: fmt.Sprintf("private network address %s", addr)}
+	}
+	return nil
+}
+
+// denyPrivateNetworks reports whether the policy denies private network addresses.
+func (p *HostPolicy) denyPrivateNetworks() bool {
+	return p != nil && p.DenyPrivateNetworks
+}
+
+// dialTimeout and dialKeepAlive are those of the dialer of http.DefaultTransport.
+const (
+	dialTimeout   = 30 * time.Second
+	dialKeepAlive = 30 * time.Second
+)
+
+// denyPrivateDials makes t check the address of every connection it opens, once its host is
+// resolved, so that a host resolving to a public address when checked cannot be connected to at a
+// private one. Connections to the proxies of t are not checked, as they resolve the hosts of the
+// requests themselves.
+func denyPrivateDials(t *http.Transport) {
+	var proxies sync.Map
+	if proxy := t.Proxy; proxy != nil {
+		t.Proxy = func(r *http.Request) (*url.URL, error) {
+			u, err := proxy(r)
+			if u != nil {
+				proxies.Store(proxyAddr(u), true)
+			}
+			return u, err
+		}
+	}
+	dial := t.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: dialTimeout, KeepAlive: dialKeepAlive}).DialContext
+	}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if _, ok := proxies.Load(addr); ok {
+			return dial(ctx, network, addr)
+		}
+		host, _, _ := net.SplitHostPort(addr)
+		d := &net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: dialKeepAlive,
+			Control: func(_, address string, _ syscall.RawConn) error {
+				ap, err := netip.ParseAddrPort(address)
+				if err != nil {
+					return err
+				}
+				return checkAddr(network, host, ap.Addr())
+			},
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+}
+
+// proxyAddr returns the address a transport connects to for the proxy u.
+func proxyAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// CheckHost returns a HostDeniedError if the HostPolicy of the gather options carried by ctx
+// denies contacting host with scheme, see HostPolicy.Check.
+func CheckHost(ctx context.Context, scheme, host string) error {
+	return OptionsFromContext(ctx).HostPolicy.Check(ctx, scheme, host)
+}
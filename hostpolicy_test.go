@@ -0,0 +1,129 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestHostPolicy_Check tests allowing and denying schemes and hosts
+func TestHostPolicy_Check(t *testing.T) {
+	lookupIPAddr = func(_ context.Context, host string) ([]net.IPAddr, error) {
+		switch host {
+		case "internal.example.com":
+			return []net.IPAddr{{IP: net.ParseIP("10.0.0.1")}}, nil
+		case "public.example.com":
+			return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	t.Cleanup(func() { lookupIPAddr = net.DefaultResolver.LookupIPAddr })
+
+	policy := &HostPolicy{
+		AllowedSchemes:      []string{"https", "file"},
+		AllowedHosts:        []string{"quay.io", "*.example.com", "169.254.169.254"},
+		DeniedHosts:         []string{"blocked.example.com"},
+		DenyPrivateNetworks: true,
+	}
+	tests := []struct {
+		scheme string
+		host   string
+		denied bool
+	}{
+		{scheme: "https", host: "quay.io"},
+		{scheme: "HTTPS", host: "QUAY.IO."},
+		{scheme: "https", host: "public.example.com"},
+		{scheme: "file", host: ""},
+		{scheme: "http", host: "quay.io", denied: true},
+		{scheme: "https", host: "example.com", denied: true},
+		{scheme: "https", host: "ghcr.io", denied: true},
+		{scheme: "https", host: "blocked.example.com", denied: true},
+		{scheme: "https", host: "internal.example.com", denied: true},
+		{scheme: "https", host: "169.254.169.254", denied: true},
+		{scheme: "https", host: "x.localhost.example.com"},
+	}
+	for _, tt := range tests {
+		err := policy.Check(context.Background(), tt.scheme, tt.host)
+		var denied *HostDeniedError
+		if tt.denied != errors.As(err, &denied) {
+			t.Errorf("unexpected result for %s://%s: %v", tt.scheme, tt.host, err)
+		}
+	}
+
+	if err := (&HostPolicy{DenyPrivateNetworks: true}).Check(context.Background(), "https", "[::1]"); err == nil {
+		t.Error("expected the loopback address to be denied")
+	}
+	if err := (&HostPolicy{DenyPrivateNetworks: true}).Check(context.Background(), "https", "localhost"); err == nil {
+		t.Error("expected localhost to be denied")
+	}
+	if err := (*HostPolicy)(nil).Check(context.Background(), "ftp", "anything"); err != nil {
+		t.Errorf("expected a nil policy to allow everything: %v", err)
+	}
+}
+
+// TestCheckHost tests checking hosts against the policy of the gather options
+func TestCheckHost(t *testing.T) {
+	ctx := ContextWithOptions(context.Background(), GatherOptions{HostPolicy: &HostPolicy{DeniedHosts: []string{"evil.example.com"}}})
+	if err := CheckHost(ctx, "https", "evil.example.com"); err == nil || err.Error() != "access to https://evil.example.com denied: host denied" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := CheckHost(context.Background(), "https", "evil.example.com"); err != nil {
+		t.Errorf("unexpected error without a policy: %v", err)
+	}
+}
+
+// TestGatherOptions_Transport_DenyPrivateNetworks tests checking the addresses transports connect
+// to, whatever the host resolved to when it was checked, except for proxies
+func TestGatherOptions_Transport_DenyPrivateNetworks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	get := func(o GatherOptions, url string) error {
+		rt, err := o.Transport(http.DefaultTransport)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := (&http.Client{Transport: rt}).Do(req)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	policy := &HostPolicy{DenyPrivateNetworks: true}
+	var denied *HostDeniedError
+	if err := get(GatherOptions{HostPolicy: policy}, server.URL); !errors.As(err, &denied) || denied.Reason != "private network address 127.0.0.1" {
+		t.Errorf("expected the connection to be denied, got %v", err)
+	}
+	if err := get(GatherOptions{HostPolicy: policy, Proxy: server.URL}, "http://example.com/"); err != nil {
+		t.Errorf("expected the connection to the proxy to be allowed, got %v", err)
+	}
+	if err := get(GatherOptions{}, server.URL); err != nil {
+		t.Errorf("unexpected error without a policy: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// StatusError is returned when a server responds to an HTTP request of a gatherer with an
+// unexpected status.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("response code error: %d", e.StatusCode)
+}
+
+// Retryable reports whether the request may succeed when retried, i.e. whether the status is 408
+// Request Timeout, 429 Too Many Requests or a server error.
+func (e *StatusError) Retryable() bool {
+	return e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// maxRedirects is the number of redirects HTTPClient follows by default, as net/http does.
+const maxRedirects = 10
+
+// HTTPClient returns a copy of base for the gather carried by ctx: its transport, or
+// http.DefaultTransport, is configured with the proxy and TLS settings of the gather options, see
+// GatherOptions.Transport, and every redirect is checked against their host policy before it is
+// followed, see CheckHost. Redirects are then checked by the CheckRedirect of base, if any, or
+// limited to 10 as net/http does. A nil base stands for http.DefaultClient.
+func HTTPClient(ctx context.Context, base *http.Client) (*http.Client, error) {
+	if base == nil {
+		base = http.DefaultClient
+	}
+	client := *base
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	var err error
+	if client.Transport, err = OptionsFromContext(ctx).Transport(transport); err != nil {
+		return nil, err
+	}
+	checkRedirect := base.CheckRedirect
+	client.CheckRedirect = func(r *http.Request, via []*http.Request) error {
+		if checkRedirect == nil {
+			return CheckRedirect(r, via)
+		}
+		if err := CheckHost(r.Context(), r.URL.Scheme, r.URL.Hostname()); err != nil {
+			return err
+		}
+		return checkRedirect(r, via)
+	}
+	return &client, nil
+}
+
+// CheckRedirect checks the redirect to r against the host policy of the gather options carried by
+// its context, see CheckHost, and limits redirects to 10 as net/http does. It is the CheckRedirect
+// of clients shared by gathers, see ContextTransport.
+func CheckRedirect(r *http.Request, via []*http.Request) error {
+	if err := CheckHost(r.Context(), r.URL.Scheme, r.URL.Hostname()); err != nil {
+		return err
+	}
+	if len(via) >= maxRedirects {
+		return errors.New("stopped after 10 redirects")
+	}
+	return nil
+}
+
+// ContextTransport returns a transport sending every request with base configured for the gather
+// options carried by the context of the request, see GatherOptions.Transport. It is for clients
+// shared by gathers with different options, e.g. those libraries install globally, which cannot be
+// configured for each gather with HTTPClient. The configured transports are reused by the requests
+// of gathers with the same transport options.
+func ContextTransport(base http.RoundTripper) http.RoundTripper {
+	return &contextTransport{base: base}
+}
+
+type contextTransport struct {
+	base http.RoundTripper
+	// transports maps the transportKey of options to base configured for them.
+	transports sync.Map
+}
+
+// transportKey holds the options GatherOptions.Transport configures transports with.
+type transportKey struct {
+	proxy               string
+	insecure            bool
+	denyPrivateNetworks bool
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	o := OptionsFromContext(req.Context())
+	key := transportKey{o.Proxy, o.InsecureSkipTLSVerify, o.HostPolicy.denyPrivateNetworks()}
+	rt, ok := t.transports.Load(key)
+	if !ok {
+		configured, err := o.Transport(t.base)
+		if err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+		rt, _ = t.transports.LoadOrStore(key, configured)
+	}
+	return rt.(http.RoundTripper).RoundTrip(req)
+}
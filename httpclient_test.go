@@ -0,0 +1,131 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestStatusError_Retryable tests telling transient from permanent response statuses
+func TestStatusError_Retryable(t *testing.T) {
+	for status, want := range map[int]bool{
+		http.StatusRequestTimeout:      true,
+		http.StatusTooManyRequests:     true,
+		http.StatusInternalServerError: true,
+		http.StatusBadGateway:          true,
+		http.StatusNotFound:            false,
+		http.StatusUnauthorized:        false,
+	} {
+		if got := (&StatusError{StatusCode: status}).Retryable(); got != want {
+			t.Errorf("expected Retryable() of %d to be %v, got %v", status, want, got)
+		}
+	}
+}
+
+// TestHTTPClient tests checking the redirects followed by the clients of gatherers
+func TestHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/external":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/once":
+			http.Redirect(w, r, "/done", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	get := func(ctx context.Context, base *http.Client, path string) error {
+		client, err := HTTPClient(ctx, base)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	ctx := ContextWithOptions(context.Background(), GatherOptions{HostPolicy: &HostPolicy{DeniedHosts: []string{"169.254.169.254"}}})
+	var denied *HostDeniedError
+	if err := get(ctx, nil, "/external"); !errors.As(err, &denied) || denied.Host != "169.254.169.254" {
+		t.Errorf("expected the redirect to be denied, got %v", err)
+	}
+
+	if err := get(context.Background(), nil, "/loop"); err == nil || !strings.Contains(err.Error(), "stopped after 10 redirects") {
+		t.Errorf("expected the redirects to be limited, got %v", err)
+	}
+
+	base := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	if err := get(context.Background(), base, "/once"); err != nil {
+		t.Errorf("expected the redirect check of the base client to stop at the redirect, got %v", err)
+	}
+	if base.Transport != nil {
+		t.Errorf("expected the base client to be left unchanged")
+	}
+}
+
+// TestContextTransport tests configuring the transport of a shared client for the gather options
+// of each request
+func TestContextTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/external" {
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: ContextTransport(http.DefaultTransport), CheckRedirect: CheckRedirect}
+	get := func(ctx context.Context, path string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	if err := get(context.Background(), "/"); err != nil {
+		t.Errorf("unexpected error without a policy: %v", err)
+	}
+	ctx := ContextWithOptions(context.Background(), GatherOptions{HostPolicy: &HostPolicy{DenyPrivateNetworks: true}})
+	var denied *HostDeniedError
+	if err := get(ctx, "/"); !errors.As(err, &denied) {
+		t.Errorf("expected the connection to be denied, got %v", err)
+	}
+	ctx = ContextWithOptions(context.Background(), GatherOptions{HostPolicy: &HostPolicy{DeniedHosts: []string{"169.254.169.254"}}})
+	if err := get(ctx, "/external"); !errors.As(err, &denied) || denied.Host != "169.254.169.254" {
+		t.Errorf("expected the redirect to be denied, got %v", err)
+	}
+	if err := get(context.Background(), "/"); err != nil {
+		t.Errorf("expected the policies of other requests not to apply, got %v", err)
+	}
+}
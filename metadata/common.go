@@ -0,0 +1,82 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import "time"
+
+// Common holds the fields shared by all metadata types, describing the gather that produced the
+// metadata. The metadata types embed it and the gatherers populate it, so that consumers can rely
+// on these fields regardless of the protocol used.
+type Common struct {
+	// SourceURI is the source as passed to the gatherer.
+	SourceURI string `json:"sourceURI,omitempty"`
+	// ResolvedURI is the source the data was gathered from after resolution, e.g. after
+	// following redirects or pinning a reference to a commit or digest.
+	ResolvedURI string `json:"resolvedURI,omitempty"`
+	// Destination is where the data was gathered to.
+	Destination string `json:"destination,omitempty"`
+	// StartedAt is the time the gather started.
+	StartedAt time.Time `json:"startedAt"`
+	// Duration is the time the gather took.
+	Duration time.Duration `json:"duration,omitempty"`
+	// Gatherer names the gatherer that produced the metadata, e.g. "git".
+	Gatherer string `json:"gatherer,omitempty"`
+	// Unchanged reports that the destination already held the content of the source from a
+	// prior gather, so nothing was gathered.
+	Unchanged bool `json:"unchanged,omitempty"`
+}
+
+// NewCommon returns the common fields of a gather of source to destination by gatherer that
+// started at startedAt and completes now.
+func NewCommon(gatherer, source, resolved, destination string, startedAt time.Time) Common {
+	return Common{
+		SourceURI:   source,
+		ResolvedURI: resolved,
+		Destination: destination,
+		StartedAt:   startedAt,
+		Duration:    time.Since(startedAt),
+		Gatherer:    gatherer,
+	}
+}
+
+// Fields returns the fields that are set, keyed as in their JSON encoding, for inclusion in the
+// result of Get.
+func (c Common) Fields() map[string]any {
+	fields := map[string]any{}
+	if c.SourceURI != "" {
+		fields["sourceURI"] = c.SourceURI
+	}
+	if c.ResolvedURI != "" {
+		fields["resolvedURI"] = c.ResolvedURI
+	}
+	if c.Destination != "" {
+		fields["destination"] = c.Destination
+	}
+	if !c.StartedAt.IsZero() {
+		fields["startedAt"] = c.StartedAt
+	}
+	if c.Duration != 0 {
+		fields["duration"] = c.Duration
+	}
+	if c.Gatherer != "" {
+		fields["gatherer"] = c.Gatherer
+	}
+	if c.Unchanged {
+		fields["unchanged"] = true
+	}
+	return fields
+}
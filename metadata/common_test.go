@@ -0,0 +1,58 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestNewCommon tests populating the common fields of a gather
+func TestNewCommon(t *testing.T) {
+	started := time.Now().Add(-time.Second)
+	c := NewCommon("git", "git::example.com/repo", "git::example.com/repo?ref=abc", "/tmp/repo", started)
+
+	if c.Gatherer != "git" || c.SourceURI != "git::example.com/repo" || c.ResolvedURI != "git::example.com/repo?ref=abc" || c.Destination != "/tmp/repo" || !c.StartedAt.Equal(started) {
+		t.Errorf("unexpected common fields: %+v", c)
+	}
+	if c.Duration < time.Second {
+		t.Errorf("unexpected duration: %s", c.Duration)
+	}
+}
+
+// TestCommon_Fields tests that only the fields that are set are described
+func TestCommon_Fields(t *testing.T) {
+	if fields := (Common{}).Fields(); len(fields) != 0 {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+
+	started := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := Common{SourceURI: "src", ResolvedURI: "resolved", Destination: "dst", StartedAt: started, Duration: time.Second, Gatherer: "file", Unchanged: true}
+	expected := map[string]any{
+		"sourceURI":   "src",
+		"resolvedURI": "resolved",
+		"destination": "dst",
+		"startedAt":   started,
+		"duration":    time.Second,
+		"gatherer":    "file",
+		"unchanged":   true,
+	}
+	if fields := c.Fields(); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("unexpected fields: got %v, want %v", fields, expected)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"reflect"
+	"sort"
+)
+
+// identityFields are the fields of Get that identify the gathered content, e.g. the commit of a
+// git repository or the digest of an OCI artifact.
+var identityFields = []string{"latest_commit", "digest", "sha", "sha256", "tree_hash", "inventory"}
+
+// volatileFields are the fields of Get that differ between gathers of the same content and are
+// therefore not reported as changes.
+var volatileFields = map[string]bool{"startedAt": true, "duration": true, "timestamp": true}
+
+// Change describes a field that differs between two gather results.
+type Change struct {
+	// Field is the key of the field in Get.
+	Field string
+	// Old is the value of the field in the first result, or nil if it is not set.
+	Old any
+	// New is the value of the field in the second result, or nil if it is not set.
+	New any
+}
+
+// Difference describes how two gather results compare.
+type Difference struct {
+	// Equivalent reports whether both results identify the same content: they are of the same
+	// type, record at least one identifying field, such as a commit, digest or SHA, and agree on
+	// all of them.
+	Equivalent bool
+	// Changes lists the fields that differ, sorted by field. Timing fields are not included.
+	Changes []Change
+}
+
+// Diff compares two gather results, typically of the same source, e.g. to detect whether the
+// source has drifted since it was last gathered.
+func Diff(a, b Metadata) Difference {
+	if a == nil || b == nil {
+		return Difference{}
+	}
+
+	fa, fb := a.Get(), b.Get()
+	d := Difference{Equivalent: reflect.TypeOf(a) == reflect.TypeOf(b)}
+
+	identified := false
+	for _, field := range identityFields {
+		va, oka := fa[field]
+		vb, okb := fb[field]
+		if !oka && !okb {
+			continue
+		}
+		if !oka || !okb || !reflect.DeepEqual(va, vb) {
+			d.Equivalent = false
+		}
+		identified = true
+	}
+	if !identified {
+		d.Equivalent = false
+	}
+
+	for field, va := range fa {
+		if volatileFields[field] {
+			continue
+		}
+		if vb, ok := fb[field]; !ok || !reflect.DeepEqual(va, vb) {
+			d.Changes = append(d.Changes, Change{Field: field, Old: va, New: vb})
+		}
+	}
+	for field, vb := range fb {
+		if _, ok := fa[field]; !ok && !volatileFields[field] {
+			d.Changes = append(d.Changes, Change{Field: field, New: vb})
+		}
+	}
+	sort.Slice(d.Changes, func(i, j int) bool {
+		return d.Changes[i].Field < d.Changes[j].Field
+	})
+
+	return d
+}
@@ -0,0 +1,104 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type mapMetadata map[string]any
+
+func (m mapMetadata) Get() map[string]any {
+	return m
+}
+
+func (m mapMetadata) GetPinnedURL(u string) (string, error) {
+	return u, nil
+}
+
+// TestDiff tests comparing gather results
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name       string
+		a, b       Metadata
+		equivalent bool
+		changes    []Change
+	}{
+		{
+			name:       "same commit",
+			a:          mapMetadata{"latest_commit": "abc", "startedAt": time.Unix(1, 0), "destination": "/tmp/a"},
+			b:          mapMetadata{"latest_commit": "abc", "startedAt": time.Unix(2, 0), "destination": "/tmp/a"},
+			equivalent: true,
+		},
+		{
+			name:       "different commit",
+			a:          mapMetadata{"latest_commit": "abc"},
+			b:          mapMetadata{"latest_commit": "def"},
+			equivalent: false,
+			changes:    []Change{{Field: "latest_commit", Old: "abc", New: "def"}},
+		},
+		{
+			name:       "same digest at another destination",
+			a:          mapMetadata{"digest": "sha256:abc", "destination": "/tmp/a"},
+			b:          mapMetadata{"digest": "sha256:abc", "destination": "/tmp/b", "resolvedURI": "oci::registry.io/repo@sha256:abc"},
+			equivalent: true,
+			changes: []Change{
+				{Field: "destination", Old: "/tmp/a", New: "/tmp/b"},
+				{Field: "resolvedURI", New: "oci::registry.io/repo@sha256:abc"},
+			},
+		},
+		{
+			name:       "missing identity",
+			a:          mapMetadata{"sha": "abc"},
+			b:          mapMetadata{},
+			equivalent: false,
+			changes:    []Change{{Field: "sha", Old: "abc"}},
+		},
+		{
+			name:       "no identity",
+			a:          mapMetadata{"path": "/tmp/a"},
+			b:          mapMetadata{"path": "/tmp/a"},
+			equivalent: false,
+		},
+		{
+			name:       "different types",
+			a:          mapMetadata{"digest": "abc"},
+			b:          &testMetadata{Digest: "abc"},
+			equivalent: false,
+		},
+		{
+			name:       "nil",
+			a:          mapMetadata{"digest": "abc"},
+			b:          nil,
+			equivalent: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Diff(tt.a, tt.b)
+			if d.Equivalent != tt.equivalent {
+				t.Errorf("expected equivalent to be %v", tt.equivalent)
+			}
+			if !reflect.DeepEqual(d.Changes, tt.changes) {
+				t.Errorf("unexpected changes: got %+v, want %+v", d.Changes, tt.changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TypeKey is the key under which serialized metadata records its type.
+const TypeKey = "type"
+
+// Decoder decodes the JSON encoding of a metadata type.
+type Decoder func(data []byte) (Metadata, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Decoder{}
+)
+
+// Register registers the decoder for the metadata type recorded as kind, e.g. "git". Registering
+// a type that is already registered replaces its decoder.
+func Register(kind string, decoder Decoder) error {
+	if kind == "" {
+		return fmt.Errorf("metadata type is empty")
+	}
+	if decoder == nil {
+		return fmt.Errorf("decoder for metadata type %s is nil", kind)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[kind] = decoder
+	return nil
+}
+
+// Types returns the registered metadata types, sorted.
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]string, 0, len(registry))
+	for kind := range registry {
+		types = append(types, kind)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Decode converts the serialized form of metadata, e.g. as read from a lockfile, into its typed
+// form based on the type recorded under TypeKey.
+func Decode(m map[string]any) (Metadata, error) {
+	kind, ok := m[TypeKey].(string)
+	if !ok || kind == "" {
+		return nil, fmt.Errorf("metadata type not set")
+	}
+
+	registryMu.RLock()
+	decoder, ok := registry[kind]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported metadata type: %s", kind)
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s metadata: %w", kind, err)
+	}
+	md, err := decoder(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s metadata: %w", kind, err)
+	}
+	return md, nil
+}
+
+// Unmarshal decodes metadata serialized as JSON or YAML.
+func Unmarshal(data []byte) (Metadata, error) {
+	var m map[string]any
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	return Decode(m)
+}
+
+// MarshalYAML encodes the metadata as YAML. The metadata types encode as JSON with
+// encoding/json.
+func MarshalYAML(md Metadata) ([]byte, error) {
+	data, err := json.Marshal(md)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	return yaml.Marshal(m)
+}
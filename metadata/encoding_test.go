@@ -0,0 +1,126 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type testMetadata struct {
+	Digest string `json:"digest"`
+}
+
+func (m *testMetadata) Get() map[string]any {
+	return map[string]any{"digest": m.Digest}
+}
+
+func (m *testMetadata) GetPinnedURL(u string) (string, error) {
+	return u + "@" + m.Digest, nil
+}
+
+func (m *testMetadata) MarshalJSON() ([]byte, error) {
+	type plain testMetadata
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		*plain
+	}{"test", (*plain)(m)})
+}
+
+func registerTestMetadata(t *testing.T) {
+	t.Helper()
+	if err := Register("test", func(data []byte) (Metadata, error) {
+		m := &testMetadata{}
+		return m, json.Unmarshal(data, m)
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "test")
+		registryMu.Unlock()
+	})
+}
+
+// TestUnmarshal tests round-tripping metadata through JSON and YAML
+func TestUnmarshal(t *testing.T) {
+	registerTestMetadata(t)
+	md := &testMetadata{Digest: "sha256:abc"}
+
+	jsonData, err := json.Marshal(md)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	yamlData, err := MarshalYAML(md)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(yamlData), "type: test") {
+		t.Errorf("unexpected YAML: %s", yamlData)
+	}
+
+	for _, data := range [][]byte{jsonData, yamlData} {
+		got, err := Unmarshal(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if m, ok := got.(*testMetadata); !ok || *m != *md {
+			t.Errorf("unexpected metadata: %#v", got)
+		}
+	}
+}
+
+// TestDecode tests decoding metadata from its serialized form
+func TestDecode(t *testing.T) {
+	registerTestMetadata(t)
+
+	got, err := Decode(map[string]any{"type": "test", "digest": "sha256:abc"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m, ok := got.(*testMetadata); !ok || m.Digest != "sha256:abc" {
+		t.Errorf("unexpected metadata: %#v", got)
+	}
+
+	tests := []struct {
+		name string
+		m    map[string]any
+		err  string
+	}{
+		{name: "missing type", m: map[string]any{"digest": "sha256:abc"}, err: "metadata type not set"},
+		{name: "unsupported type", m: map[string]any{"type": "unknown"}, err: "unsupported metadata type: unknown"},
+		{name: "invalid field", m: map[string]any{"type": "test", "digest": 1}, err: "failed to decode test metadata: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.m); err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("unexpected error: got %v, want %s", err, tt.err)
+			}
+		})
+	}
+}
+
+// TestRegister tests that invalid registrations are rejected
+func TestRegister(t *testing.T) {
+	if err := Register("", func([]byte) (Metadata, error) { return nil, nil }); err == nil {
+		t.Error("expected an error for an empty type")
+	}
+	if err := Register("test", nil); err == nil {
+		t.Error("expected an error for a nil decoder")
+	}
+}
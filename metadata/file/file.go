@@ -41,31 +41,85 @@
 package file
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
 	"strings"
 	"time"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+const (
+	// FileType is the type FileMetadata is serialized as.
+	FileType = "file"
+	// DirectoryType is the type DirectoryMetadata is serialized as.
+	DirectoryType = "directory"
 )
 
+func init() {
+	register(FileType, func(data []byte) (metadata.Metadata, error) {
+		m := &FileMetadata{}
+		return m, json.Unmarshal(data, m)
+	})
+	register(DirectoryType, func(data []byte) (metadata.Metadata, error) {
+		m := &DirectoryMetadata{}
+		return m, json.Unmarshal(data, m)
+	})
+}
+
+func register(kind string, decoder metadata.Decoder) {
+	if err := metadata.Register(kind, decoder); err != nil {
+		panic(err)
+	}
+}
+
 type FileMetadata struct {
-	Size      int64
-	Path      string
-	Timestamp time.Time
-	SHA       string
+	metadata.Common
+	Size      int64     `json:"size"`
+	Path      string    `json:"path"`
+	Timestamp time.Time `json:"timestamp"`
+	SHA       string    `json:"sha"`
 }
 
 type DirectoryMetadata struct {
-	Size      int64
-	Path      string
-	Timestamp time.Time
+	metadata.Common
+	Size      int64     `json:"size"`
+	Path      string    `json:"path"`
+	Timestamp time.Time `json:"timestamp"`
+	// TreeHash is the metadata.TreeHash of the directory, which identifies its content.
+	TreeHash string `json:"tree_hash,omitempty"`
+	// Inventory lists the files of the directory, if requested from the gatherer.
+	Inventory *metadata.Inventory `json:"inventory,omitempty"`
+}
+
+// MarshalJSON encodes the metadata as JSON, recording its type for metadata.Decode.
+func (m FileMetadata) MarshalJSON() ([]byte, error) {
+	type plain FileMetadata
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{FileType, plain(m)})
+}
+
+// MarshalJSON encodes the metadata as JSON, recording its type for metadata.Decode.
+func (m DirectoryMetadata) MarshalJSON() ([]byte, error) {
+	type plain DirectoryMetadata
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{DirectoryType, plain(m)})
 }
 
 func (m *FileMetadata) Get() map[string]any {
-	return map[string]any{
+	fields := m.Common.Fields()
+	maps.Copy(fields, map[string]any{
 		"size":      m.Size,
 		"path":      m.Path,
 		"timestamp": m.Timestamp,
 		"sha":       m.SHA,
-	}
+	})
+	return fields
 }
 
 func (m FileMetadata) GetPinnedURL(u string) (string, error) {
@@ -79,11 +133,19 @@ func (m FileMetadata) GetPinnedURL(u string) (string, error) {
 }
 
 func (m *DirectoryMetadata) Get() map[string]any {
-	return map[string]any{
+	fields := m.Common.Fields()
+	maps.Copy(fields, map[string]any{
 		"size":      m.Size,
 		"path":      m.Path,
 		"timestamp": m.Timestamp,
+	})
+	if m.TreeHash != "" {
+		fields["tree_hash"] = m.TreeHash
+	}
+	if m.Inventory != nil {
+		fields["inventory"] = m.Inventory
 	}
+	return fields
 }
 
 func (m DirectoryMetadata) GetPinnedURL(u string) (string, error) {
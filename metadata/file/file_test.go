@@ -16,6 +16,7 @@
 package file
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -267,3 +268,38 @@ func TestDirectoryMetadata_GetPinnedUrl(t *testing.T) {
 		})
 	}
 }
+
+// TestFileMetadata_JSON tests round-tripping file and directory metadata through JSON
+func TestFileMetadata_JSON(t *testing.T) {
+	testTime := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		metadata metadata.Metadata
+	}{
+		{name: "file", metadata: &FileMetadata{Size: 100, Path: "/path/to/file", Timestamp: testTime, SHA: "ef4e93945f5b3d481abe655d6ce3870132994c0bd5840e312d7ac97cde021050"}},
+		{name: "directory", metadata: &DirectoryMetadata{Size: 100, Path: "/path/to/dir", Timestamp: testTime}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.metadata)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := metadata.Unmarshal(data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			switch want := tt.metadata.(type) {
+			case *FileMetadata:
+				if m, ok := got.(*FileMetadata); !ok || *m != *want {
+					t.Errorf("unexpected metadata: got %#v, want %#v", got, want)
+				}
+			case *DirectoryMetadata:
+				if m, ok := got.(*DirectoryMetadata); !ok || *m != *want {
+					t.Errorf("unexpected metadata: got %#v, want %#v", got, want)
+				}
+			}
+		})
+	}
+}
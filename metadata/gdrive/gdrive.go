@@ -0,0 +1,141 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gdrive
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// Type is the type GoogleDriveMetadata is serialized as.
+const Type = "gdrive"
+
+func init() {
+	if err := metadata.Register(Type, func(data []byte) (metadata.Metadata, error) {
+		m := &GoogleDriveMetadata{}
+		return m, json.Unmarshal(data, m)
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// GoogleDriveMetadata describes a file, or a folder, downloaded from Google Drive.
+type GoogleDriveMetadata struct {
+	metadata.Common
+	// ID is the ID of the file or folder.
+	ID string `json:"id"`
+	// Name is the name of the file or folder.
+	Name string `json:"name"`
+	// MimeType is the MIME type of the file, e.g. "application/vnd.google-apps.folder" for a
+	// folder.
+	MimeType string `json:"mimeType"`
+	// ExportMimeType is the MIME type a Google Docs, Sheets or Slides file was exported as.
+	ExportMimeType string `json:"exportMimeType,omitempty"`
+	// Version is the version of the file or folder, which increases with every change.
+	Version int64 `json:"version"`
+	// ModifiedTime is the time the file or folder was last modified.
+	ModifiedTime time.Time `json:"modifiedTime"`
+	// Size is the size of the downloaded file, or the total size of the files of the folder, in
+	// bytes.
+	Size int64 `json:"size"`
+	// SHA256 is the hex encoded SHA256 digest of a downloaded file.
+	SHA256 string `json:"sha256,omitempty"`
+	// TreeHash is the metadata.TreeHash of a downloaded folder.
+	TreeHash string `json:"treeHash,omitempty"`
+	// Files lists the files downloaded from a folder.
+	Files []File `json:"files,omitempty"`
+}
+
+// File describes a file downloaded from a folder.
+type File struct {
+	// ID is the ID of the file.
+	ID string `json:"id"`
+	// Path is the path of the file relative to the folder.
+	Path string `json:"path"`
+	// MimeType is the MIME type of the file.
+	MimeType string `json:"mimeType"`
+	// ExportMimeType is the MIME type the file was exported as, if it is a Google Docs, Sheets or
+	// Slides file.
+	ExportMimeType string `json:"exportMimeType,omitempty"`
+	// Version is the version of the file, which increases with every change.
+	Version int64 `json:"version"`
+}
+
+// MarshalJSON encodes the metadata as JSON, recording its type for metadata.Decode.
+func (m GoogleDriveMetadata) MarshalJSON() ([]byte, error) {
+	type plain GoogleDriveMetadata
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{Type, plain(m)})
+}
+
+func (m GoogleDriveMetadata) Get() map[string]any {
+	fields := m.Common.Fields()
+	maps.Copy(fields, map[string]any{
+		"id":           m.ID,
+		"name":         m.Name,
+		"mimeType":     m.MimeType,
+		"version":      m.Version,
+		"modifiedTime": m.ModifiedTime,
+		"size":         m.Size,
+	})
+	if m.ExportMimeType != "" {
+		fields["exportMimeType"] = m.ExportMimeType
+	}
+	if m.SHA256 != "" {
+		fields["sha256"] = m.SHA256
+	}
+	if m.TreeHash != "" {
+		fields["treeHash"] = m.TreeHash
+	}
+	if len(m.Files) > 0 {
+		fields["files"] = m.Files
+	}
+	return fields
+}
+
+// GetPinnedURL returns the URL with the digest of the downloaded file, or the tree hash of the
+// downloaded folder, appended as a "checksum=sha256:<digest>" query parameter, replacing any
+// checksum the URL already has. It returns an error if the URL is empty or neither digest is set.
+func (m GoogleDriveMetadata) GetPinnedURL(u string) (string, error) {
+	if len(u) == 0 {
+		return "", fmt.Errorf("empty URL")
+	}
+	digest := m.SHA256
+	if digest == "" {
+		digest = m.TreeHash
+	}
+	if digest == "" {
+		return "", fmt.Errorf("digest not set")
+	}
+
+	base, query, _ := strings.Cut(u, "?")
+	params := []string{}
+	for _, p := range strings.Split(query, "&") {
+		if p != "" && !strings.HasPrefix(p, "checksum=") {
+			params = append(params, p)
+		}
+	}
+	params = append(params, "checksum=sha256:"+digest)
+	return base + "?" + strings.Join(params, "&"), nil
+}
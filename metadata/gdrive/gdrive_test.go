@@ -0,0 +1,105 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gdrive
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// TestGoogleDriveMetadata_Get tests the fields reported for a folder
+func TestGoogleDriveMetadata_Get(t *testing.T) {
+	modified := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	files := []File{{ID: "2", Path: "policy.rego", MimeType: "text/plain", Version: 3}}
+	m := GoogleDriveMetadata{ID: "1", Name: "policies", MimeType: "application/vnd.google-apps.folder", Version: 5, ModifiedTime: modified, Size: 42, TreeHash: "def", Files: files}
+	expected := map[string]any{
+		"id":           "1",
+		"name":         "policies",
+		"mimeType":     "application/vnd.google-apps.folder",
+		"version":      int64(5),
+		"modifiedTime": modified,
+		"size":         int64(42),
+		"treeHash":     "def",
+		"files":        files,
+	}
+	if got := m.Get(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected fields: got %v, want %v", got, expected)
+	}
+}
+
+// TestGoogleDriveMetadata_GetPinnedURL tests pinning sources to the digest of the downloaded content
+func TestGoogleDriveMetadata_GetPinnedURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		metadata GoogleDriveMetadata
+		expected string
+		err      string
+	}{
+		{name: "file", url: "gdrive://1a2b3c", metadata: GoogleDriveMetadata{SHA256: "abc"}, expected: "gdrive://1a2b3c?checksum=sha256:abc"},
+		{name: "folder", url: "https://drive.google.com/drive/folders/1a2b3c", metadata: GoogleDriveMetadata{TreeHash: "def"}, expected: "https://drive.google.com/drive/folders/1a2b3c?checksum=sha256:def"},
+		{name: "pinned", url: "gdrive://1a2b3c?format=csv&checksum=sha256:old", metadata: GoogleDriveMetadata{SHA256: "abc"}, expected: "gdrive://1a2b3c?format=csv&checksum=sha256:abc"},
+		{name: "no digest", url: "gdrive://1a2b3c", err: "digest not set"},
+		{name: "empty", metadata: GoogleDriveMetadata{SHA256: "abc"}, err: "empty URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.metadata.GetPinnedURL(tt.url)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("unexpected URL: got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestGoogleDriveMetadata_Unmarshal tests that the metadata is decoded as GoogleDriveMetadata
+func TestGoogleDriveMetadata_Unmarshal(t *testing.T) {
+	m := &GoogleDriveMetadata{
+		Common:       metadata.Common{SourceURI: "gdrive://1a2b3c", Destination: "/tmp/policies"},
+		ID:           "1a2b3c",
+		Name:         "policies",
+		MimeType:     "application/vnd.google-apps.folder",
+		ModifiedTime: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Size:         3,
+		TreeHash:     "def",
+		Files:        []File{{ID: "4d5e6f", Path: "config", MimeType: "application/vnd.google-apps.spreadsheet", ExportMimeType: "text/csv", Version: 7}},
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := metadata.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, m) {
+		t.Errorf("unexpected metadata: got %+v, want %+v", decoded, m)
+	}
+}
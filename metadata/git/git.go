@@ -36,20 +36,59 @@
 package git
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
+	"net/url"
 	"strings"
+
+	"github.com/enterprise-contract/go-gather/metadata"
 )
 
+// Type is the type GitMetadata is serialized as.
+const Type = "git"
+
+func init() {
+	if err := metadata.Register(Type, func(data []byte) (metadata.Metadata, error) {
+		m := &GitMetadata{}
+		return m, json.Unmarshal(data, m)
+	}); err != nil {
+		panic(err)
+	}
+}
+
 // GitMetadata is a struct that represents the metadata of a git repository.
 // It has fields for size, path, timestamp, and commits.
 type GitMetadata struct {
-	LatestCommit string
+	metadata.Common
+	LatestCommit string `json:"latest_commit"`
+	// TreeHash is the metadata.TreeHash of the cloned worktree, which identifies its content.
+	TreeHash string `json:"tree_hash,omitempty"`
+	// Inventory lists the files of the cloned repository, if requested from the gatherer.
+	Inventory *metadata.Inventory `json:"inventory,omitempty"`
+}
+
+// MarshalJSON encodes the metadata as JSON, recording its type for metadata.Decode.
+func (m GitMetadata) MarshalJSON() ([]byte, error) {
+	type plain GitMetadata
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{Type, plain(m)})
 }
 
 func (m GitMetadata) Get() map[string]any {
-	return map[string]any{
+	fields := m.Common.Fields()
+	maps.Copy(fields, map[string]any{
 		"latest_commit": m.LatestCommit,
+	})
+	if m.TreeHash != "" {
+		fields["tree_hash"] = m.TreeHash
 	}
+	if m.Inventory != nil {
+		fields["inventory"] = m.Inventory
+	}
+	return fields
 }
 
 func (m GitMetadata) GetLatestCommit() string {
@@ -63,6 +102,18 @@ func (m GitMetadata) GetPinnedURL(u string) (string, error) {
 	if m.LatestCommit == "" {
 		return "", fmt.Errorf("latest commit not set")
 	}
+	// Snapshots gathered from the archive API of a forge are pinned as such, so that gathering
+	// the pinned URL downloads an archive again rather than cloning the repository.
+	if rest, ok := strings.CutPrefix(u, "forge::"); ok {
+		p, err := url.Parse(rest)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse URL: %w", err)
+		}
+		q := p.Query()
+		q.Set("ref", m.LatestCommit)
+		p.RawQuery = q.Encode()
+		return "forge::" + p.String(), nil
+	}
 	for _, scheme := range []string{"git::", "git://", "https://"} {
 		u = strings.TrimPrefix(u, scheme)
 	}
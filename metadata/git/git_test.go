@@ -16,6 +16,7 @@
 package git
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 
@@ -189,6 +190,15 @@ func TestGetPinnedURL(t *testing.T) {
 			expected: "git::test-url.git//path/to/file?ref=ghi789",
 			hasError: false,
 		},
+		{
+			name: "Forge URL keeps its prefix and parameters",
+			url:  "forge::https://git.example.com/org/repo//path?provider=gitlab&ref=main",
+			metadata: &GitMetadata{
+				LatestCommit: "ghi789",
+			},
+			expected: "forge::https://git.example.com/org/repo//path?provider=gitlab&ref=ghi789",
+			hasError: false,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -207,3 +217,19 @@ func TestGetPinnedURL(t *testing.T) {
 		})
 	}
 }
+
+// TestGitMetadata_JSON tests round-tripping git metadata through JSON
+func TestGitMetadata_JSON(t *testing.T) {
+	m := GitMetadata{LatestCommit: plumbing.ComputeHash(plumbing.AnyObject, []byte("hash1")).String()}
+
+	data, err := json.Marshal(m)
+	assert.NoError(t, err)
+	var fields map[string]any
+	assert.NoError(t, json.Unmarshal(data, &fields))
+	assert.Equal(t, "git", fields["type"])
+	assert.Equal(t, m.LatestCommit, fields["latest_commit"])
+
+	got, err := metadata.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, &m, got)
+}
@@ -0,0 +1,111 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// Type is the type GitHubReleaseMetadata is serialized as.
+const Type = "github-release"
+
+func init() {
+	if err := metadata.Register(Type, func(data []byte) (metadata.Metadata, error) {
+		m := &GitHubReleaseMetadata{}
+		return m, json.Unmarshal(data, m)
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// GitHubReleaseMetadata describes an asset downloaded from a GitHub release.
+type GitHubReleaseMetadata struct {
+	metadata.Common
+	// Owner is the user or organization owning the repository.
+	Owner string `json:"owner"`
+	// Repository is the name of the repository.
+	Repository string `json:"repository"`
+	// Tag is the tag of the release, e.g. "v1.0.0".
+	Tag string `json:"tag"`
+	// ReleaseID is the ID of the release.
+	ReleaseID int64 `json:"releaseID"`
+	// Asset is the name of the asset.
+	Asset string `json:"asset"`
+	// AssetID is the ID of the asset.
+	AssetID int64 `json:"assetID"`
+	// ContentType is the content type of the asset.
+	ContentType string `json:"contentType,omitempty"`
+	// Size is the size of the asset in bytes.
+	Size int64 `json:"size"`
+	// SHA256 is the hex encoded SHA256 digest of the asset.
+	SHA256 string `json:"sha256"`
+}
+
+// MarshalJSON encodes the metadata as JSON, recording its type for metadata.Decode.
+func (m GitHubReleaseMetadata) MarshalJSON() ([]byte, error) {
+	type plain GitHubReleaseMetadata
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{Type, plain(m)})
+}
+
+func (m GitHubReleaseMetadata) Get() map[string]any {
+	fields := m.Common.Fields()
+	maps.Copy(fields, map[string]any{
+		"owner":      m.Owner,
+		"repository": m.Repository,
+		"tag":        m.Tag,
+		"releaseID":  m.ReleaseID,
+		"asset":      m.Asset,
+		"assetID":    m.AssetID,
+		"size":       m.Size,
+		"sha256":     m.SHA256,
+	})
+	if m.ContentType != "" {
+		fields["contentType"] = m.ContentType
+	}
+	return fields
+}
+
+// GetPinnedURL returns the URL with the digest of the asset appended as a
+// "checksum=sha256:<digest>" query parameter, replacing any checksum the URL already has, so that
+// gathering it fails if the asset, or the tag of its release, has changed. It returns an error if
+// the URL is empty or the digest is not set.
+func (m GitHubReleaseMetadata) GetPinnedURL(u string) (string, error) {
+	if len(u) == 0 {
+		return "", fmt.Errorf("empty URL")
+	}
+	if m.SHA256 == "" {
+		return "", fmt.Errorf("digest not set")
+	}
+
+	base, query, _ := strings.Cut(u, "?")
+	params := []string{}
+	for _, p := range strings.Split(query, "&") {
+		if p != "" && !strings.HasPrefix(p, "checksum=") {
+			params = append(params, p)
+		}
+	}
+	params = append(params, "checksum=sha256:"+m.SHA256)
+	return base + "?" + strings.Join(params, "&"), nil
+}
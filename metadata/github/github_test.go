@@ -0,0 +1,101 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package github
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// TestGitHubReleaseMetadata_Get tests the fields reported for an asset
+func TestGitHubReleaseMetadata_Get(t *testing.T) {
+	m := GitHubReleaseMetadata{Owner: "org", Repository: "repo", Tag: "v1.0.0", ReleaseID: 1, Asset: "bundle.tar.gz", AssetID: 2, Size: 42, SHA256: "abc"}
+	expected := map[string]any{
+		"owner":      "org",
+		"repository": "repo",
+		"tag":        "v1.0.0",
+		"releaseID":  int64(1),
+		"asset":      "bundle.tar.gz",
+		"assetID":    int64(2),
+		"size":       int64(42),
+		"sha256":     "abc",
+	}
+	if got := m.Get(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected fields: got %v, want %v", got, expected)
+	}
+}
+
+// TestGitHubReleaseMetadata_GetPinnedURL tests pinning sources to the digest of the asset
+func TestGitHubReleaseMetadata_GetPinnedURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		metadata GitHubReleaseMetadata
+		expected string
+		err      string
+	}{
+		{name: "asset", url: "github-release://org/repo/v1.0.0/bundle.tar.gz", metadata: GitHubReleaseMetadata{SHA256: "abc"}, expected: "github-release://org/repo/v1.0.0/bundle.tar.gz?checksum=sha256:abc"},
+		{name: "pinned", url: "github-release://org/repo/v1.0.0/bundle.tar.gz?checksum=sha256:old", metadata: GitHubReleaseMetadata{SHA256: "abc"}, expected: "github-release://org/repo/v1.0.0/bundle.tar.gz?checksum=sha256:abc"},
+		{name: "no digest", url: "github-release://org/repo/v1.0.0/bundle.tar.gz", err: "digest not set"},
+		{name: "empty", metadata: GitHubReleaseMetadata{SHA256: "abc"}, err: "empty URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.metadata.GetPinnedURL(tt.url)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("unexpected URL: got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestGitHubReleaseMetadata_Unmarshal tests that the metadata is decoded as GitHubReleaseMetadata
+func TestGitHubReleaseMetadata_Unmarshal(t *testing.T) {
+	m := &GitHubReleaseMetadata{
+		Common:      metadata.Common{SourceURI: "github-release://org/repo/v1.0.0/bundle.tar.gz", Destination: "/tmp/bundle.tar.gz"},
+		Owner:       "org",
+		Repository:  "repo",
+		Tag:         "v1.0.0",
+		Asset:       "bundle.tar.gz",
+		ContentType: "application/gzip",
+		Size:        3,
+		SHA256:      "abc",
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := metadata.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, m) {
+		t.Errorf("unexpected metadata: got %+v, want %+v", decoded, m)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gitlab
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// Type is the type GitLabMetadata is serialized as.
+const Type = "gitlab"
+
+func init() {
+	if err := metadata.Register(Type, func(data []byte) (metadata.Metadata, error) {
+		m := &GitLabMetadata{}
+		return m, json.Unmarshal(data, m)
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// GitLabMetadata describes a file downloaded from a GitLab project: an asset of a release or a
+// file of a generic package.
+type GitLabMetadata struct {
+	metadata.Common
+	// Project is the path of the project, e.g. "group/subgroup/project".
+	Project string `json:"project"`
+	// Tag is the tag of the release the asset belongs to.
+	Tag string `json:"tag,omitempty"`
+	// Package is the name of the generic package the file belongs to.
+	Package string `json:"package,omitempty"`
+	// Version is the version of the generic package.
+	Version string `json:"version,omitempty"`
+	// File is the name of the asset or package file.
+	File string `json:"file"`
+	// Size is the size of the file in bytes.
+	Size int64 `json:"size"`
+	// SHA256 is the hex encoded SHA256 digest of the file.
+	SHA256 string `json:"sha256"`
+}
+
+// MarshalJSON encodes the metadata as JSON, recording its type for metadata.Decode.
+func (m GitLabMetadata) MarshalJSON() ([]byte, error) {
+	type plain GitLabMetadata
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{Type, plain(m)})
+}
+
+func (m GitLabMetadata) Get() map[string]any {
+	fields := m.Common.Fields()
+	maps.Copy(fields, map[string]any{
+		"project": m.Project,
+		"file":    m.File,
+		"size":    m.Size,
+		"sha256":  m.SHA256,
+	})
+	if m.Tag != "" {
+		fields["tag"] = m.Tag
+	}
+	if m.Package != "" {
+		fields["package"] = m.Package
+		fields["version"] = m.Version
+	}
+	return fields
+}
+
+// GetPinnedURL returns the URL with the digest of the file appended as a
+// "checksum=sha256:<digest>" query parameter, replacing any checksum the URL already has, so that
+// gathering it fails if the file has changed. It returns an error if the URL is empty or the digest
+// is not set.
+func (m GitLabMetadata) GetPinnedURL(u string) (string, error) {
+	if len(u) == 0 {
+		return "", fmt.Errorf("empty URL")
+	}
+	if m.SHA256 == "" {
+		return "", fmt.Errorf("digest not set")
+	}
+
+	base, query, _ := strings.Cut(u, "?")
+	params := []string{}
+	for _, p := range strings.Split(query, "&") {
+		if p != "" && !strings.HasPrefix(p, "checksum=") {
+			params = append(params, p)
+		}
+	}
+	params = append(params, "checksum=sha256:"+m.SHA256)
+	return base + "?" + strings.Join(params, "&"), nil
+}
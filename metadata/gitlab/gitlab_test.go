@@ -0,0 +1,109 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gitlab
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// TestGitLabMetadata_Get tests the fields reported for release assets and package files
+func TestGitLabMetadata_Get(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata GitLabMetadata
+		expected map[string]any
+	}{
+		{
+			name:     "release",
+			metadata: GitLabMetadata{Project: "group/project", Tag: "v1.0.0", File: "bundle.tar.gz", Size: 42, SHA256: "abc"},
+			expected: map[string]any{"project": "group/project", "tag": "v1.0.0", "file": "bundle.tar.gz", "size": int64(42), "sha256": "abc"},
+		},
+		{
+			name:     "package",
+			metadata: GitLabMetadata{Project: "group/project", Package: "policies", Version: "1.0.0", File: "bundle.tar.gz", Size: 42, SHA256: "abc"},
+			expected: map[string]any{"project": "group/project", "package": "policies", "version": "1.0.0", "file": "bundle.tar.gz", "size": int64(42), "sha256": "abc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.metadata.Get(); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("unexpected fields: got %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestGitLabMetadata_GetPinnedURL tests pinning sources to the digest of the file
+func TestGitLabMetadata_GetPinnedURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		metadata GitLabMetadata
+		expected string
+		err      string
+	}{
+		{name: "file", url: "gitlab://gitlab.com/group/project/-/releases/v1.0.0/bundle.tar.gz", metadata: GitLabMetadata{SHA256: "abc"}, expected: "gitlab://gitlab.com/group/project/-/releases/v1.0.0/bundle.tar.gz?checksum=sha256:abc"},
+		{name: "pinned", url: "gitlab://gitlab.com/group/project/-/packages/generic/policies/1.0.0/bundle.tar.gz?checksum=sha256:old", metadata: GitLabMetadata{SHA256: "abc"}, expected: "gitlab://gitlab.com/group/project/-/packages/generic/policies/1.0.0/bundle.tar.gz?checksum=sha256:abc"},
+		{name: "no digest", url: "gitlab://gitlab.com/group/project/-/releases/v1.0.0/bundle.tar.gz", err: "digest not set"},
+		{name: "empty", metadata: GitLabMetadata{SHA256: "abc"}, err: "empty URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.metadata.GetPinnedURL(tt.url)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("unexpected URL: got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestGitLabMetadata_Unmarshal tests that the metadata is decoded as GitLabMetadata
+func TestGitLabMetadata_Unmarshal(t *testing.T) {
+	m := &GitLabMetadata{
+		Common:  metadata.Common{SourceURI: "gitlab://gitlab.com/group/project/-/packages/generic/policies/1.0.0/bundle.tar.gz", Destination: "/tmp/bundle.tar.gz"},
+		Project: "group/project",
+		Package: "policies",
+		Version: "1.0.0",
+		File:    "bundle.tar.gz",
+		Size:    3,
+		SHA256:  "abc",
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := metadata.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, m) {
+		t.Errorf("unexpected metadata: got %+v, want %+v", decoded, m)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package hdfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// Type is the type HDFSMetadata is serialized as.
+const Type = "hdfs"
+
+func init() {
+	if err := metadata.Register(Type, func(data []byte) (metadata.Metadata, error) {
+		m := &HDFSMetadata{}
+		return m, json.Unmarshal(data, m)
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// HDFSMetadata describes a file or directory gathered from HDFS.
+type HDFSMetadata struct {
+	metadata.Common
+	// Path is the absolute path of the file or directory in HDFS.
+	Path string `json:"path"`
+	// Files is the number of files gathered.
+	Files int `json:"files"`
+	// Size is the total size of the files gathered.
+	Size int64 `json:"size"`
+	// ModifiedTime is the time the file or directory was last modified.
+	ModifiedTime time.Time `json:"modifiedTime"`
+	// SHA256 is the hex encoded SHA256 digest of a gathered file.
+	SHA256 string `json:"sha256,omitempty"`
+	// TreeHash is the metadata.TreeHash of a gathered directory.
+	TreeHash string `json:"treeHash,omitempty"`
+}
+
+// MarshalJSON encodes the metadata as JSON, recording its type for metadata.Decode.
+func (m HDFSMetadata) MarshalJSON() ([]byte, error) {
+	type plain HDFSMetadata
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{Type, plain(m)})
+}
+
+func (m HDFSMetadata) Get() map[string]any {
+	fields := m.Common.Fields()
+	maps.Copy(fields, map[string]any{
+		"path":         m.Path,
+		"files":        m.Files,
+		"size":         m.Size,
+		"modifiedTime": m.ModifiedTime,
+	})
+	if m.SHA256 != "" {
+		fields["sha256"] = m.SHA256
+	}
+	if m.TreeHash != "" {
+		fields["treeHash"] = m.TreeHash
+	}
+	return fields
+}
+
+// GetPinnedURL returns the URL with the tree hash of the gathered directory, or else the digest of
+// the gathered file, appended as a "checksum=sha256:<digest>" query parameter, replacing any
+// checksum the URL already has. HDFS paths are mutable, so gathering the pinned URL again fails
+// if the content changed. It returns an error if the URL is empty or neither digest is set.
+func (m HDFSMetadata) GetPinnedURL(u string) (string, error) {
+	if len(u) == 0 {
+		return "", fmt.Errorf("empty URL")
+	}
+	digest := m.TreeHash
+	if digest == "" {
+		digest = m.SHA256
+	}
+	if digest == "" {
+		return "", fmt.Errorf("digest not set")
+	}
+
+	base, query, _ := strings.Cut(u, "?")
+	params := []string{}
+	for _, p := range strings.Split(query, "&") {
+		if p != "" && !strings.HasPrefix(p, "checksum=") {
+			params = append(params, p)
+		}
+	}
+	params = append(params, "checksum=sha256:"+digest)
+	return base + "?" + strings.Join(params, "&"), nil
+}
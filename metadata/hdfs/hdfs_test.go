@@ -0,0 +1,105 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package hdfs
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// TestHDFSMetadata_Get tests the fields reported for a directory gathered from HDFS
+func TestHDFSMetadata_Get(t *testing.T) {
+	modified := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := HDFSMetadata{
+		Path:         "/data/reference",
+		Files:        2,
+		Size:         512,
+		ModifiedTime: modified,
+		TreeHash:     "def",
+	}
+	expected := map[string]any{
+		"path":         "/data/reference",
+		"files":        2,
+		"size":         int64(512),
+		"modifiedTime": modified,
+		"treeHash":     "def",
+	}
+	if got := m.Get(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected fields: got %v, want %v", got, expected)
+	}
+}
+
+// TestHDFSMetadata_GetPinnedURL tests pinning sources to the digest of the content
+func TestHDFSMetadata_GetPinnedURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		metadata HDFSMetadata
+		expected string
+		err      string
+	}{
+		{name: "file", url: "hdfs://namenode/data/a.csv", metadata: HDFSMetadata{SHA256: "abc"}, expected: "hdfs://namenode/data/a.csv?checksum=sha256:abc"},
+		{name: "directory", url: "hdfs://namenode/data?delegation=token", metadata: HDFSMetadata{TreeHash: "def"}, expected: "hdfs://namenode/data?delegation=token&checksum=sha256:def"},
+		{name: "pinned", url: "hdfs://namenode/data/a.csv?checksum=sha256:old", metadata: HDFSMetadata{SHA256: "abc"}, expected: "hdfs://namenode/data/a.csv?checksum=sha256:abc"},
+		{name: "no digest", url: "hdfs://namenode/data", err: "digest not set"},
+		{name: "empty", metadata: HDFSMetadata{SHA256: "abc"}, err: "empty URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.metadata.GetPinnedURL(tt.url)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("unexpected URL: got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestHDFSMetadata_Unmarshal tests that the metadata is decoded as HDFSMetadata
+func TestHDFSMetadata_Unmarshal(t *testing.T) {
+	m := &HDFSMetadata{
+		Common:       metadata.Common{SourceURI: "hdfs://namenode/data/a.csv", Destination: "/tmp/a.csv"},
+		Path:         "/data/a.csv",
+		Files:        1,
+		Size:         3,
+		ModifiedTime: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		SHA256:       "abc",
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := metadata.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, m) {
+		t.Errorf("unexpected metadata: got %+v, want %+v", decoded, m)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"strings"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// Type is the type HelmMetadata is serialized as.
+const Type = "helm"
+
+func init() {
+	if err := metadata.Register(Type, func(data []byte) (metadata.Metadata, error) {
+		m := &HelmMetadata{}
+		return m, json.Unmarshal(data, m)
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// HelmMetadata describes a chart downloaded from a Helm chart repository.
+type HelmMetadata struct {
+	metadata.Common
+	// Repository is the URL of the chart repository.
+	Repository string `json:"repository"`
+	// Chart is the name of the chart.
+	Chart string `json:"chart"`
+	// Version is the version of the chart.
+	Version string `json:"version"`
+	// AppVersion is the version of the application the chart deploys, as recorded in the index
+	// of the repository.
+	AppVersion string `json:"appVersion,omitempty"`
+	// URL is the URL the chart archive was downloaded from.
+	URL string `json:"url"`
+	// Size is the size of the chart archive in bytes.
+	Size int64 `json:"size"`
+	// SHA256 is the hex encoded SHA256 digest of the chart archive.
+	SHA256 string `json:"sha256"`
+	// TreeHash is the metadata.TreeHash of the directory the chart was expanded into, empty when
+	// the chart archive was saved as is.
+	TreeHash string `json:"treeHash,omitempty"`
+}
+
+// MarshalJSON encodes the metadata as JSON, recording its type for metadata.Decode.
+func (m HelmMetadata) MarshalJSON() ([]byte, error) {
+	type plain HelmMetadata
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{Type, plain(m)})
+}
+
+func (m HelmMetadata) Get() map[string]any {
+	fields := m.Common.Fields()
+	maps.Copy(fields, map[string]any{
+		"repository": m.Repository,
+		"chart":      m.Chart,
+		"version":    m.Version,
+		"url":        m.URL,
+		"size":       m.Size,
+		"sha256":     m.SHA256,
+	})
+	if m.AppVersion != "" {
+		fields["appVersion"] = m.AppVersion
+	}
+	if m.TreeHash != "" {
+		fields["treeHash"] = m.TreeHash
+	}
+	return fields
+}
+
+// GetPinnedURL returns the URL with the version of the chart set as the "version" query parameter
+// and the digest of the chart archive appended as a "checksum=sha256:<digest>" query parameter,
+// replacing any version or checksum the URL already has. It returns an error if the URL is empty
+// or the digest is not set.
+func (m HelmMetadata) GetPinnedURL(u string) (string, error) {
+	if len(u) == 0 {
+		return "", fmt.Errorf("empty URL")
+	}
+	if m.SHA256 == "" {
+		return "", fmt.Errorf("digest not set")
+	}
+
+	base, query, _ := strings.Cut(u, "?")
+	params := []string{}
+	for _, p := range strings.Split(query, "&") {
+		if p != "" && !strings.HasPrefix(p, "checksum=") && !strings.HasPrefix(p, "version=") {
+			params = append(params, p)
+		}
+	}
+	if m.Version != "" {
+		params = append(params, "version="+url.QueryEscape(m.Version))
+	}
+	params = append(params, "checksum=sha256:"+m.SHA256)
+	return base + "?" + strings.Join(params, "&"), nil
+}
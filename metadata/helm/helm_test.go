@@ -0,0 +1,110 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package helm
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// TestHelmMetadata_Get tests the fields reported for an expanded chart
+func TestHelmMetadata_Get(t *testing.T) {
+	m := HelmMetadata{
+		Repository: "https://charts.example.com",
+		Chart:      "policies",
+		Version:    "1.2.3",
+		AppVersion: "2.0",
+		URL:        "https://charts.example.com/policies-1.2.3.tgz",
+		Size:       42,
+		SHA256:     "abc",
+		TreeHash:   "def",
+	}
+	expected := map[string]any{
+		"repository": "https://charts.example.com",
+		"chart":      "policies",
+		"version":    "1.2.3",
+		"appVersion": "2.0",
+		"url":        "https://charts.example.com/policies-1.2.3.tgz",
+		"size":       int64(42),
+		"sha256":     "abc",
+		"treeHash":   "def",
+	}
+	if got := m.Get(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected fields: got %v, want %v", got, expected)
+	}
+}
+
+// TestHelmMetadata_GetPinnedURL tests pinning sources to the version and digest of the chart
+func TestHelmMetadata_GetPinnedURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		metadata HelmMetadata
+		expected string
+		err      string
+	}{
+		{name: "latest", url: "helm::https://charts.example.com/policies", metadata: HelmMetadata{Version: "1.2.3", SHA256: "abc"}, expected: "helm::https://charts.example.com/policies?version=1.2.3&checksum=sha256:abc"},
+		{name: "version", url: "helm::https://charts.example.com/policies?version=1.2.3", metadata: HelmMetadata{Version: "1.2.3", SHA256: "abc"}, expected: "helm::https://charts.example.com/policies?version=1.2.3&checksum=sha256:abc"},
+		{name: "pinned", url: "helm::https://charts.example.com/policies?checksum=sha256:old&version=1.2.3", metadata: HelmMetadata{Version: "1.2.3+build.1", SHA256: "abc"}, expected: "helm::https://charts.example.com/policies?version=1.2.3%2Bbuild.1&checksum=sha256:abc"},
+		{name: "no digest", url: "helm::https://charts.example.com/policies", err: "digest not set"},
+		{name: "empty", metadata: HelmMetadata{SHA256: "abc"}, err: "empty URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.metadata.GetPinnedURL(tt.url)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("unexpected URL: got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestHelmMetadata_Unmarshal tests that the metadata is decoded as HelmMetadata
+func TestHelmMetadata_Unmarshal(t *testing.T) {
+	m := &HelmMetadata{
+		Common:     metadata.Common{SourceURI: "helm::https://charts.example.com/policies", Destination: "/tmp/policies"},
+		Repository: "https://charts.example.com",
+		Chart:      "policies",
+		Version:    "1.2.3",
+		URL:        "https://charts.example.com/policies-1.2.3.tgz",
+		Size:       3,
+		SHA256:     "abc",
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := metadata.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, m) {
+		t.Errorf("unexpected metadata: got %+v, want %+v", decoded, m)
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/enterprise-contract/go-gather/metadata/http
 
 go 1.22.5
+
+require github.com/enterprise-contract/go-gather/metadata v0.0.2
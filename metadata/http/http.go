@@ -17,32 +17,85 @@
 package http
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
 	"strings"
+
+	"github.com/enterprise-contract/go-gather/metadata"
 )
 
+// Type is the type HTTPMetadata is serialized as.
+const Type = "http"
+
+func init() {
+	if err := metadata.Register(Type, func(data []byte) (metadata.Metadata, error) {
+		var m HTTPMetadata
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// HTTPMetadata describes a file downloaded over HTTP. The path the file was saved to is held by
+// the Destination field of the embedded metadata.Common.
 type HTTPMetadata struct {
-	StatusCode    int
-	ContentLength int64
-	Destination   string
-	Headers       map[string][]string
+	metadata.Common
+	StatusCode    int                 `json:"statusCode"`
+	ContentLength int64               `json:"contentLength"`
+	Headers       map[string][]string `json:"headers"`
+	// SHA256 is the hex encoded SHA256 digest of the downloaded content.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// MarshalJSON encodes the metadata as JSON, recording its type for metadata.Decode.
+func (m HTTPMetadata) MarshalJSON() ([]byte, error) {
+	type plain HTTPMetadata
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{Type, plain(m)})
 }
 
 func (m HTTPMetadata) Get() map[string]any {
-	return map[string]any{
+	fields := m.Common.Fields()
+	maps.Copy(fields, map[string]any{
 		"statusCode":    m.StatusCode,
 		"contentLength": m.ContentLength,
 		"destination":   m.Destination,
 		"headers":       m.Headers,
-	}
+		"sha256":        m.SHA256,
+	})
+	return fields
 }
 
+// GetPinnedURL returns the URL in its "http::" form, keeping its scheme, with the digest of the
+// downloaded content appended as a "checksum=sha256:<digest>" query parameter, replacing any
+// checksum the URL already has, e.g. "http::https://example.com/file.txt?checksum=sha256:abc".
+// URLs without a scheme are taken to use https. It returns an error if the URL is empty or the
+// digest is not set.
 func (m HTTPMetadata) GetPinnedURL(u string) (string, error) {
 	if len(u) == 0 {
 		return "", fmt.Errorf("empty URL")
 	}
-	for _, scheme := range []string{"http://", "https://", "http::"} {
-		u = strings.TrimPrefix(u, scheme)
+	if m.SHA256 == "" {
+		return "", fmt.Errorf("sha256 digest not set")
+	}
+	u = strings.TrimPrefix(u, "http::")
+	if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		u = "https://" + u
+	}
+
+	base, query, _ := strings.Cut(u, "?")
+	params := []string{}
+	for _, p := range strings.Split(query, "&") {
+		if p != "" && !strings.HasPrefix(p, "checksum=") {
+			params = append(params, p)
+		}
 	}
-	return "http::" + u, nil
+	params = append(params, "checksum=sha256:"+m.SHA256)
+	return "http::" + base + "?" + strings.Join(params, "&"), nil
 }
@@ -17,8 +17,11 @@
 package http
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
+
+	"github.com/enterprise-contract/go-gather/metadata"
 )
 
 func TestHTTPMetadata_Get(t *testing.T) {
@@ -26,8 +29,9 @@ func TestHTTPMetadata_Get(t *testing.T) {
 	metadata := HTTPMetadata{
 		StatusCode:    200,
 		ContentLength: 1024,
-		Destination:   "https://example.com",
+		Common:        metadata.Common{Destination: "https://example.com"},
 		Headers:       map[string][]string{"Content-Type": {"text/plain"}},
+		SHA256:        "abc123",
 	}
 
 	// Call the Get method
@@ -39,6 +43,7 @@ func TestHTTPMetadata_Get(t *testing.T) {
 		"contentLength": int64(1024),
 		"destination":   "https://example.com",
 		"headers":       map[string][]string{"Content-Type": {"text/plain"}},
+		"sha256":        "abc123",
 	}
 
 	if !reflect.DeepEqual(result, expected) {
@@ -46,10 +51,11 @@ func TestHTTPMetadata_Get(t *testing.T) {
 	}
 }
 
-func TestFileMetadata_GetPinnedURL(t *testing.T) {
+func TestHTTPMetadata_GetPinnedURL(t *testing.T) {
 	tests := []struct {
 		name          string
 		url           string
+		sha256        string
 		expectedURL   string
 		expectError   bool
 		expectedError error
@@ -57,12 +63,41 @@ func TestFileMetadata_GetPinnedURL(t *testing.T) {
 		{
 			name:        "valid URL",
 			url:         "http://example.com",
-			expectedURL: "http::example.com",
+			sha256:      "abc123",
+			expectedURL: "http::http://example.com?checksum=sha256:abc123",
+			expectError: false,
+		},
+		{
+			name:        "URL with query",
+			url:         "https://example.com/file.txt?version=2&checksum=md5:def456",
+			sha256:      "abc123",
+			expectedURL: "http::https://example.com/file.txt?version=2&checksum=sha256:abc123",
+			expectError: false,
+		},
+		{
+			name:        "forced URL",
+			url:         "http::https://example.com/file.txt?checksum=sha256:def456",
+			sha256:      "abc123",
+			expectedURL: "http::https://example.com/file.txt?checksum=sha256:abc123",
+			expectError: false,
+		},
+		{
+			name:        "URL without scheme",
+			url:         "example.com/file.txt",
+			sha256:      "abc123",
+			expectedURL: "http::https://example.com/file.txt?checksum=sha256:abc123",
 			expectError: false,
 		},
 		{
 			name:        "empty URL",
 			url:         "",
+			sha256:      "abc123",
+			expectedURL: "",
+			expectError: true,
+		},
+		{
+			name:        "digest not set",
+			url:         "http://example.com",
 			expectedURL: "",
 			expectError: true,
 		},
@@ -70,7 +105,7 @@ func TestFileMetadata_GetPinnedURL(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			m := HTTPMetadata{}
+			m := HTTPMetadata{SHA256: tt.sha256}
 			gotURL, err := m.GetPinnedURL(tt.url)
 			if (err != nil) != tt.expectError {
 				t.Errorf("GetPinnedURL() error = %v, expectError %v", err, tt.expectError)
@@ -82,3 +117,25 @@ func TestFileMetadata_GetPinnedURL(t *testing.T) {
 		})
 	}
 }
+
+// TestHTTPMetadata_JSON tests round-tripping HTTP metadata through JSON
+func TestHTTPMetadata_JSON(t *testing.T) {
+	m := HTTPMetadata{
+		StatusCode:    200,
+		ContentLength: 1024,
+		Common:        metadata.Common{Destination: "/path/to/file"},
+		Headers:       map[string][]string{"Content-Type": {"text/plain"}},
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := metadata.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("unexpected metadata: got %#v, want %#v", got, m)
+	}
+}
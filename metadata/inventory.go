@@ -0,0 +1,131 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Inventory lists the files of gathered content, e.g. as a lightweight software bill of materials.
+type Inventory struct {
+	// Files lists the regular files of the content, sorted by path.
+	Files []InventoryFile `json:"files"`
+}
+
+// InventoryFile describes a file of gathered content.
+type InventoryFile struct {
+	// Path is the slash separated path of the file relative to the root of the content.
+	Path string `json:"path"`
+	// Size is the size of the file in bytes.
+	Size int64 `json:"size"`
+	// SHA256 is the hex encoded SHA256 digest of the file.
+	SHA256 string `json:"sha256"`
+}
+
+// NewInventory returns the inventory of the regular files in the directory dir. Version control
+// metadata, i.e. ".git" directories, is not included.
+func NewInventory(dir string) (*Inventory, error) {
+	inv := &Inventory{Files: []InventoryFile{}}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		size, sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		inv.Files = append(inv.Files, InventoryFile{Path: filepath.ToSlash(rel), Size: size, SHA256: hex.EncodeToString(sum)})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create inventory of %s: %w", dir, err)
+	}
+	return inv, nil
+}
+
+// hashFile returns the size and SHA256 digest of the file at path.
+func hashFile(path string) (int64, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, nil, err
+	}
+	return size, h.Sum(nil), nil
+}
+
+// SPDX encodes the inventory as an SPDX 2.3 JSON document called name. The document namespace is
+// derived from the content of the inventory, so that identical content yields the same namespace.
+func (inv *Inventory) SPDX(name string, created time.Time) ([]byte, error) {
+	type checksum struct {
+		Algorithm     string `json:"algorithm"`
+		ChecksumValue string `json:"checksumValue"`
+	}
+	type spdxFile struct {
+		FileName  string     `json:"fileName"`
+		SPDXID    string     `json:"SPDXID"`
+		Checksums []checksum `json:"checksums"`
+	}
+
+	h := sha256.New()
+	files := make([]spdxFile, 0, len(inv.Files))
+	for i, f := range inv.Files {
+		fmt.Fprintf(h, "%s %s\n", f.SHA256, f.Path)
+		files = append(files, spdxFile{
+			FileName:  "./" + f.Path,
+			SPDXID:    fmt.Sprintf("SPDXRef-File-%d", i+1),
+			Checksums: []checksum{{Algorithm: "SHA256", ChecksumValue: f.SHA256}},
+		})
+	}
+
+	return json.MarshalIndent(map[string]any{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              name,
+		"documentNamespace": "https://github.com/enterprise-contract/go-gather/spdx/" + hex.EncodeToString(h.Sum(nil)),
+		"creationInfo": map[string]any{
+			"created":  created.UTC().Format(time.RFC3339),
+			"creators": []string{"Tool: go-gather"},
+		},
+		"files": files,
+	}, "", "  ")
+}
@@ -0,0 +1,98 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestNewInventory tests listing the files of a directory
+func TestNewInventory(t *testing.T) {
+	dir := t.TempDir()
+	for path, content := range map[string]string{
+		"b.txt":       "bar",
+		"sub/a.txt":   "foo",
+		".git/config": "ignored",
+	} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("b.txt", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	inv, err := NewInventory(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []InventoryFile{
+		{Path: "b.txt", Size: 3, SHA256: "fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9"},
+		{Path: "sub/a.txt", Size: 3, SHA256: "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"},
+	}
+	if !reflect.DeepEqual(inv.Files, expected) {
+		t.Errorf("unexpected files: got %+v, want %+v", inv.Files, expected)
+	}
+
+	if _, err := NewInventory(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+// TestInventory_SPDX tests encoding an inventory as an SPDX document
+func TestInventory_SPDX(t *testing.T) {
+	inv := &Inventory{Files: []InventoryFile{{Path: "a.txt", Size: 3, SHA256: "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"}}}
+
+	data, err := inv.SPDX("policy", time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var doc struct {
+		SPDXVersion  string `json:"spdxVersion"`
+		Name         string `json:"name"`
+		CreationInfo struct {
+			Created string `json:"created"`
+		} `json:"creationInfo"`
+		Files []struct {
+			FileName  string `json:"fileName"`
+			SPDXID    string `json:"SPDXID"`
+			Checksums []struct {
+				Algorithm     string `json:"algorithm"`
+				ChecksumValue string `json:"checksumValue"`
+			} `json:"checksums"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if doc.SPDXVersion != "SPDX-2.3" || doc.Name != "policy" || doc.CreationInfo.Created != "2022-01-01T12:00:00Z" {
+		t.Errorf("unexpected document: %s", data)
+	}
+	if len(doc.Files) != 1 || doc.Files[0].FileName != "./a.txt" || doc.Files[0].SPDXID != "SPDXRef-File-1" ||
+		doc.Files[0].Checksums[0].Algorithm != "SHA256" || doc.Files[0].Checksums[0].ChecksumValue != inv.Files[0].SHA256 {
+		t.Errorf("unexpected files: %s", data)
+	}
+}
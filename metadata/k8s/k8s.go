@@ -0,0 +1,119 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// Type is the type KubernetesMetadata is serialized as.
+const Type = "kubernetes"
+
+func init() {
+	if err := metadata.Register(Type, func(data []byte) (metadata.Metadata, error) {
+		m := &KubernetesMetadata{}
+		return m, json.Unmarshal(data, m)
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// KubernetesMetadata describes the keys of a ConfigMap or Secret materialized as files.
+type KubernetesMetadata struct {
+	metadata.Common
+	// Server is the URL of the API server of the cluster.
+	Server string `json:"server"`
+	// Namespace is the namespace of the object.
+	Namespace string `json:"namespace"`
+	// Kind is the kind of the object, either "ConfigMap" or "Secret".
+	Kind string `json:"kind"`
+	// Name is the name of the object.
+	Name string `json:"name"`
+	// UID is the unique ID of the object.
+	UID string `json:"uid"`
+	// ResourceVersion is the version of the object that was gathered.
+	ResourceVersion string `json:"resourceVersion"`
+	// Keys are the keys that were materialized as files.
+	Keys []string `json:"keys"`
+	// Size is the total size of the files in bytes.
+	Size int64 `json:"size"`
+	// SHA256 is the hex encoded SHA256 digest of a single key materialized as a file.
+	SHA256 string `json:"sha256,omitempty"`
+	// TreeHash is the metadata.TreeHash of the directory the keys were materialized in.
+	TreeHash string `json:"treeHash,omitempty"`
+}
+
+// MarshalJSON encodes the metadata as JSON, recording its type for metadata.Decode.
+func (m KubernetesMetadata) MarshalJSON() ([]byte, error) {
+	type plain KubernetesMetadata
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{Type, plain(m)})
+}
+
+func (m KubernetesMetadata) Get() map[string]any {
+	fields := m.Common.Fields()
+	maps.Copy(fields, map[string]any{
+		"server":          m.Server,
+		"namespace":       m.Namespace,
+		"kind":            m.Kind,
+		"name":            m.Name,
+		"uid":             m.UID,
+		"resourceVersion": m.ResourceVersion,
+		"keys":            m.Keys,
+		"size":            m.Size,
+	})
+	if m.SHA256 != "" {
+		fields["sha256"] = m.SHA256
+	}
+	if m.TreeHash != "" {
+		fields["treeHash"] = m.TreeHash
+	}
+	return fields
+}
+
+// GetPinnedURL returns the URL with the digest of the materialized key, or the tree hash of the
+// materialized keys, appended as a "checksum=sha256:<digest>" query parameter, replacing any
+// checksum the URL already has. It returns an error if the URL is empty or neither digest is set.
+func (m KubernetesMetadata) GetPinnedURL(u string) (string, error) {
+	if len(u) == 0 {
+		return "", fmt.Errorf("empty URL")
+	}
+	digest := m.SHA256
+	if digest == "" {
+		digest = m.TreeHash
+	}
+	if digest == "" {
+		return "", fmt.Errorf("digest not set")
+	}
+
+	base, query, _ := strings.Cut(u, "?")
+	params := []string{}
+	for _, p := range strings.Split(query, "&") {
+		if p != "" && !strings.HasPrefix(p, "checksum=") {
+			params = append(params, p)
+		}
+	}
+	params = append(params, "checksum=sha256:"+digest)
+	return base + "?" + strings.Join(params, "&"), nil
+}
@@ -0,0 +1,113 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package k8s
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// TestKubernetesMetadata_Get tests the fields reported for a materialized ConfigMap
+func TestKubernetesMetadata_Get(t *testing.T) {
+	m := KubernetesMetadata{
+		Server:          "https://cluster.example.com:6443",
+		Namespace:       "policies",
+		Kind:            "ConfigMap",
+		Name:            "release",
+		UID:             "1a2b",
+		ResourceVersion: "42",
+		Keys:            []string{"a.rego", "b.rego"},
+		Size:            18,
+		TreeHash:        "def",
+	}
+	expected := map[string]any{
+		"server":          "https://cluster.example.com:6443",
+		"namespace":       "policies",
+		"kind":            "ConfigMap",
+		"name":            "release",
+		"uid":             "1a2b",
+		"resourceVersion": "42",
+		"keys":            []string{"a.rego", "b.rego"},
+		"size":            int64(18),
+		"treeHash":        "def",
+	}
+	if got := m.Get(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected fields: got %v, want %v", got, expected)
+	}
+}
+
+// TestKubernetesMetadata_GetPinnedURL tests pinning sources to the digest of the materialized keys
+func TestKubernetesMetadata_GetPinnedURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		metadata KubernetesMetadata
+		expected string
+		err      string
+	}{
+		{name: "key", url: "k8s://policies/secret/release/token", metadata: KubernetesMetadata{SHA256: "abc"}, expected: "k8s://policies/secret/release/token?checksum=sha256:abc"},
+		{name: "object", url: "k8s://policies/configmap/release?context=prod", metadata: KubernetesMetadata{TreeHash: "def"}, expected: "k8s://policies/configmap/release?context=prod&checksum=sha256:def"},
+		{name: "pinned", url: "k8s://policies/configmap/release?checksum=sha256:old", metadata: KubernetesMetadata{TreeHash: "def"}, expected: "k8s://policies/configmap/release?checksum=sha256:def"},
+		{name: "no digest", url: "k8s://policies/configmap/release", err: "digest not set"},
+		{name: "empty", metadata: KubernetesMetadata{SHA256: "abc"}, err: "empty URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.metadata.GetPinnedURL(tt.url)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("unexpected URL: got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestKubernetesMetadata_Unmarshal tests that the metadata is decoded as KubernetesMetadata
+func TestKubernetesMetadata_Unmarshal(t *testing.T) {
+	m := &KubernetesMetadata{
+		Common:    metadata.Common{SourceURI: "k8s://policies/secret/release/token", Destination: "/tmp/token"},
+		Server:    "https://cluster.example.com:6443",
+		Namespace: "policies",
+		Kind:      "Secret",
+		Name:      "release",
+		Keys:      []string{"token"},
+		Size:      3,
+		SHA256:    "abc",
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := metadata.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, m) {
+		t.Errorf("unexpected metadata: got %+v, want %+v", decoded, m)
+	}
+}
@@ -17,11 +17,19 @@
 // Package metadata provides functionality for generating metadata.
 // It includes a Metadata interface that contains a Get method
 // for describing metadata.
+//
+// Metadata can be persisted as JSON or YAML, e.g. in lockfiles, and reloaded into its typed form
+// with Unmarshal or Decode. The serialized form records the metadata type under the "type" key;
+// the metadata packages register a Decoder for their types when they are imported.
 
 package metadata
 
 // Metadata is an interface that all metadata types will satisfy.
 type Metadata interface {
+	// Get returns a description of the metadata.
 	Get() map[string]any
-	GetPinnedURL(string) (string, error) // GetPinnedURL returns a URL with the artifact digest appended.
+	// GetPinnedURL returns the given source URL pinned to the gathered artifact, e.g. with the
+	// commit or digest appended, so that gathering it again yields the same content. It returns
+	// an error if the URL is empty or the metadata lacks the information needed to pin it.
+	GetPinnedURL(string) (string, error)
 }
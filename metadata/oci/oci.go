@@ -17,18 +17,51 @@
 package oci
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
 	"strings"
+
+	"github.com/enterprise-contract/go-gather/metadata"
 )
 
+// Type is the type OCIMetadata is serialized as.
+const Type = "oci"
+
+func init() {
+	if err := metadata.Register(Type, func(data []byte) (metadata.Metadata, error) {
+		m := &OCIMetadata{}
+		return m, json.Unmarshal(data, m)
+	}); err != nil {
+		panic(err)
+	}
+}
+
 type OCIMetadata struct {
-	Digest string
+	metadata.Common
+	Digest string `json:"digest"`
+	// Inventory lists the files of the pulled artifact, if requested from the gatherer.
+	Inventory *metadata.Inventory `json:"inventory,omitempty"`
+}
+
+// MarshalJSON encodes the metadata as JSON, recording its type for metadata.Decode.
+func (o OCIMetadata) MarshalJSON() ([]byte, error) {
+	type plain OCIMetadata
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{Type, plain(o)})
 }
 
 func (o OCIMetadata) Get() map[string]any {
-	return map[string]any{
+	fields := o.Common.Fields()
+	maps.Copy(fields, map[string]any{
 		"digest": o.Digest,
+	})
+	if o.Inventory != nil {
+		fields["inventory"] = o.Inventory
 	}
+	return fields
 }
 
 // GetDigest returns the digest of the artifact.
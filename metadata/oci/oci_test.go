@@ -17,6 +17,7 @@
 package oci
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"testing"
@@ -240,3 +241,19 @@ func TestGetPinnedURL(t *testing.T) {
 		})
 	}
 }
+
+// TestOCIMetadata_JSON tests round-tripping OCI metadata through JSON
+func TestOCIMetadata_JSON(t *testing.T) {
+	o := OCIMetadata{Digest: "sha256:fa93b01658e3a5a1686dc3ae55f170d8de487006fb53a28efcd12ab0710a2e5f"}
+
+	data, err := json.Marshal(o)
+	assert.NoError(t, err)
+	var fields map[string]any
+	assert.NoError(t, json.Unmarshal(data, &fields))
+	assert.Equal(t, "oci", fields["type"])
+	assert.Equal(t, o.Digest, fields["digest"])
+
+	got, err := metadata.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, &o, got)
+}
@@ -0,0 +1,11 @@
+module github.com/enterprise-contract/go-gather/metadata/provenance
+
+go 1.22.5
+
+require (
+	github.com/enterprise-contract/go-gather/metadata v0.0.2
+	github.com/enterprise-contract/go-gather/metadata/file v0.0.1
+	github.com/enterprise-contract/go-gather/metadata/git v0.0.2
+	github.com/enterprise-contract/go-gather/metadata/http v0.0.1
+	github.com/enterprise-contract/go-gather/metadata/oci v0.0.3
+)
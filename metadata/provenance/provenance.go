@@ -0,0 +1,300 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package provenance generates SLSA provenance for gathered content in the form of in-toto
+// statements, for supply-chain audit trails.
+//
+// The statement produced by NewStatement describes the gathered content as its subject, identified
+// by its digest, and the pinned source it was gathered from as its material. Statements can be
+// signed with Sign, which wraps them in a DSSE envelope.
+//
+// Example usage:
+//
+//	m, err := gather.Gather(ctx, "git::https://github.com/org/repo", "/tmp/repo")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	s, err := provenance.NewStatement(m)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	envelope, err := provenance.Sign(ctx, s, signer)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+package provenance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+	"github.com/enterprise-contract/go-gather/metadata/file"
+	"github.com/enterprise-contract/go-gather/metadata/git"
+	"github.com/enterprise-contract/go-gather/metadata/http"
+	"github.com/enterprise-contract/go-gather/metadata/oci"
+)
+
+const (
+	// StatementType is the type of in-toto statements.
+	StatementType = "https://in-toto.io/Statement/v0.1"
+	// PredicateType is the type of SLSA provenance predicates.
+	PredicateType = "https://slsa.dev/provenance/v0.2"
+	// PayloadType is the DSSE payload type of signed statements.
+	PayloadType = "application/vnd.in-toto+json"
+	// DefaultBuilderID identifies go-gather as the builder of the gathered content.
+	DefaultBuilderID = "https://github.com/enterprise-contract/go-gather"
+	// BuildType describes the gather that produced the content.
+	BuildType = "https://github.com/enterprise-contract/go-gather/gather@v1"
+)
+
+// Statement is an in-toto statement holding SLSA provenance.
+type Statement struct {
+	Type          string    `json:"_type"`
+	Subject       []Subject `json:"subject"`
+	PredicateType string    `json:"predicateType"`
+	Predicate     Predicate `json:"predicate"`
+}
+
+// Subject identifies an artifact by its name and digests.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Predicate is a SLSA provenance predicate.
+type Predicate struct {
+	Builder   Builder        `json:"builder"`
+	BuildType string         `json:"buildType"`
+	Metadata  *BuildMetadata `json:"metadata,omitempty"`
+	Materials []Material     `json:"materials,omitempty"`
+}
+
+// Builder identifies the entity that produced the subject.
+type Builder struct {
+	ID string `json:"id"`
+}
+
+// BuildMetadata records when the subject was produced.
+type BuildMetadata struct {
+	BuildStartedOn  *time.Time `json:"buildStartedOn,omitempty"`
+	BuildFinishedOn *time.Time `json:"buildFinishedOn,omitempty"`
+}
+
+// Material identifies an input of the gather, i.e. the pinned source.
+type Material struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// NewStatement returns a statement describing the gather that produced md. The subject is the
+// gathered content at the destination, identified by its digest, and the material is the source
+// pinned by md. It returns an error if the digest of the gathered content cannot be determined.
+func NewStatement(md metadata.Metadata) (*Statement, error) {
+	common := commonOf(md)
+
+	name := common.Destination
+	if m, ok := md.(*file.FileMetadata); ok && name == "" {
+		name = m.Path
+	}
+	if name == "" {
+		return nil, fmt.Errorf("metadata has no destination")
+	}
+
+	digest, err := subjectDigest(md, name)
+	if err != nil {
+		return nil, err
+	}
+
+	material := Material{URI: common.ResolvedURI, Digest: sourceDigest(md)}
+	if material.URI == "" && common.SourceURI != "" {
+		if pinned, err := md.GetPinnedURL(common.SourceURI); err == nil {
+			material.URI = pinned
+		} else {
+			material.URI = common.SourceURI
+		}
+	}
+
+	s := &Statement{
+		Type:          StatementType,
+		Subject:       []Subject{{Name: name, Digest: digest}},
+		PredicateType: PredicateType,
+		Predicate: Predicate{
+			Builder:   Builder{ID: DefaultBuilderID},
+			BuildType: BuildType,
+		},
+	}
+	if material.URI != "" {
+		s.Predicate.Materials = []Material{material}
+	}
+	if !common.StartedAt.IsZero() {
+		finished := common.StartedAt.Add(common.Duration)
+		s.Predicate.Metadata = &BuildMetadata{BuildStartedOn: &common.StartedAt, BuildFinishedOn: &finished}
+	}
+	return s, nil
+}
+
+// commonOf returns the common fields of md.
+func commonOf(md metadata.Metadata) metadata.Common {
+	switch m := md.(type) {
+	case *file.FileMetadata:
+		return m.Common
+	case *file.DirectoryMetadata:
+		return m.Common
+	case *git.GitMetadata:
+		return m.Common
+	case *oci.OCIMetadata:
+		return m.Common
+	case http.HTTPMetadata:
+		return m.Common
+	}
+	return metadata.Common{}
+}
+
+// subjectDigest returns the digest of the gathered content at destination. The digest recorded in
+// md is used if there is one, otherwise the content is hashed if it is a single file.
+func subjectDigest(md metadata.Metadata, destination string) (map[string]string, error) {
+	switch m := md.(type) {
+	case *file.FileMetadata:
+		if m.SHA != "" {
+			return map[string]string{"sha256": m.SHA}, nil
+		}
+	case *oci.OCIMetadata:
+		if alg, encoded, ok := strings.Cut(m.Digest, ":"); ok {
+			return map[string]string{alg: encoded}, nil
+		}
+	case *file.DirectoryMetadata:
+		if m.TreeHash != "" {
+			return map[string]string{"dirHash": m.TreeHash}, nil
+		}
+	case *git.GitMetadata:
+		if m.LatestCommit != "" {
+			return map[string]string{"gitCommit": m.LatestCommit}, nil
+		}
+	case http.HTTPMetadata:
+		if m.SHA256 != "" {
+			return map[string]string{"sha256": m.SHA256}, nil
+		}
+	}
+
+	sha, err := fileDigest(destination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine the digest of %s: %w", destination, err)
+	}
+	return map[string]string{"sha256": sha}, nil
+}
+
+// sourceDigest returns the digest identifying the source in md, if it records one.
+func sourceDigest(md metadata.Metadata) map[string]string {
+	switch m := md.(type) {
+	case *git.GitMetadata:
+		if m.LatestCommit != "" {
+			return map[string]string{"gitCommit": m.LatestCommit}
+		}
+	case *oci.OCIMetadata:
+		if alg, encoded, ok := strings.Cut(m.Digest, ":"); ok {
+			return map[string]string{alg: encoded}
+		}
+	case http.HTTPMetadata:
+		if m.SHA256 != "" {
+			return map[string]string{"sha256": m.SHA256}
+		}
+	}
+	return nil
+}
+
+// fileDigest returns the hex encoded SHA256 digest of the regular file at destination.
+func fileDigest(destination string) (string, error) {
+	path := destination
+	if u, err := url.Parse(destination); err == nil && u.Scheme == "file" {
+		path = u.Path
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("not a regular file")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Signer signs statements.
+type Signer interface {
+	// KeyID identifies the key used for signing. It may be empty.
+	KeyID() string
+	// Sign returns the signature of data.
+	Sign(ctx context.Context, data []byte) ([]byte, error)
+}
+
+// Envelope is a DSSE envelope holding a signed statement.
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     string      `json:"payload"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+// Signature is a signature of an envelope payload.
+type Signature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   string `json:"sig"`
+}
+
+// Sign signs the statement with signer and returns it wrapped in a DSSE envelope. The signature
+// covers the pre-authentication encoding of the payload, as defined by the DSSE specification.
+func Sign(ctx context.Context, s *Statement, signer Signer) (*Envelope, error) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode statement: %w", err)
+	}
+
+	sig, err := signer.Sign(ctx, PAE(PayloadType, payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign statement: %w", err)
+	}
+
+	return &Envelope{
+		PayloadType: PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []Signature{{KeyID: signer.KeyID(), Sig: base64.StdEncoding.EncodeToString(sig)}},
+	}, nil
+}
+
+// PAE returns the DSSE pre-authentication encoding of payload, which is the data that is signed.
+func PAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
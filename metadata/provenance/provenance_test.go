@@ -0,0 +1,211 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package provenance
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+	"github.com/enterprise-contract/go-gather/metadata/file"
+	"github.com/enterprise-contract/go-gather/metadata/git"
+	"github.com/enterprise-contract/go-gather/metadata/http"
+	"github.com/enterprise-contract/go-gather/metadata/oci"
+)
+
+// TestNewStatement tests generating statements from the metadata of the different gatherers
+func TestNewStatement(t *testing.T) {
+	destination := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(destination, []byte("test content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	started := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		metadata  metadata.Metadata
+		subject   Subject
+		materials []Material
+	}{
+		{
+			name: "file",
+			metadata: &file.FileMetadata{
+				Common: metadata.Common{SourceURI: "/src/file.txt", ResolvedURI: "file::/src/file.txt", Destination: destination},
+				Path:   destination,
+				SHA:    "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72",
+			},
+			subject:   Subject{Name: destination, Digest: map[string]string{"sha256": "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72"}},
+			materials: []Material{{URI: "file::/src/file.txt"}},
+		},
+		{
+			name: "git",
+			metadata: &git.GitMetadata{
+				Common:       metadata.Common{SourceURI: "git::https://example.com/org/repo", Destination: "/tmp/repo"},
+				LatestCommit: "4a5b6c",
+			},
+			subject:   Subject{Name: "/tmp/repo", Digest: map[string]string{"gitCommit": "4a5b6c"}},
+			materials: []Material{{URI: "git::example.com/org/repo?ref=4a5b6c", Digest: map[string]string{"gitCommit": "4a5b6c"}}},
+		},
+		{
+			name: "directory",
+			metadata: &file.DirectoryMetadata{
+				Common:   metadata.Common{SourceURI: "/src/dir", Destination: "/tmp/dir"},
+				TreeHash: "361a42",
+			},
+			subject:   Subject{Name: "/tmp/dir", Digest: map[string]string{"dirHash": "361a42"}},
+			materials: []Material{{URI: "file::/src/dir"}},
+		},
+		{
+			name: "oci",
+			metadata: &oci.OCIMetadata{
+				Common: metadata.Common{ResolvedURI: "oci::registry.io/repo@sha256:abc", Destination: "/tmp/bundle"},
+				Digest: "sha256:abc",
+			},
+			subject:   Subject{Name: "/tmp/bundle", Digest: map[string]string{"sha256": "abc"}},
+			materials: []Material{{URI: "oci::registry.io/repo@sha256:abc", Digest: map[string]string{"sha256": "abc"}}},
+		},
+		{
+			name: "http",
+			metadata: http.HTTPMetadata{
+				Common: metadata.Common{SourceURI: "https://example.com/file.txt", ResolvedURI: "https://example.com/file.txt", Destination: destination, StartedAt: started, Duration: time.Second},
+			},
+			subject:   Subject{Name: destination, Digest: map[string]string{"sha256": "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72"}},
+			materials: []Material{{URI: "https://example.com/file.txt"}},
+		},
+		{
+			name: "http with digest",
+			metadata: http.HTTPMetadata{
+				Common: metadata.Common{ResolvedURI: "https://example.com/file.txt", Destination: "/tmp/file.txt"},
+				SHA256: "abc",
+			},
+			subject:   Subject{Name: "/tmp/file.txt", Digest: map[string]string{"sha256": "abc"}},
+			materials: []Material{{URI: "https://example.com/file.txt", Digest: map[string]string{"sha256": "abc"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewStatement(tt.metadata)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s.Type != StatementType || s.PredicateType != PredicateType || s.Predicate.Builder.ID != DefaultBuilderID {
+				t.Errorf("unexpected statement: %+v", s)
+			}
+			if !reflect.DeepEqual(s.Subject, []Subject{tt.subject}) {
+				t.Errorf("unexpected subject: got %+v, want %+v", s.Subject, tt.subject)
+			}
+			if !reflect.DeepEqual(s.Predicate.Materials, tt.materials) {
+				t.Errorf("unexpected materials: got %+v, want %+v", s.Predicate.Materials, tt.materials)
+			}
+		})
+	}
+}
+
+// TestNewStatement_BuildMetadata tests that the gather timing is recorded
+func TestNewStatement_BuildMetadata(t *testing.T) {
+	started := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	s, err := NewStatement(&oci.OCIMetadata{
+		Common: metadata.Common{Destination: "/tmp/bundle", StartedAt: started, Duration: time.Second},
+		Digest: "sha256:abc",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m := s.Predicate.Metadata; m == nil || !m.BuildStartedOn.Equal(started) || !m.BuildFinishedOn.Equal(started.Add(time.Second)) {
+		t.Errorf("unexpected build metadata: %+v", m)
+	}
+}
+
+// TestNewStatement_Error tests that statements cannot be generated without a subject digest
+func TestNewStatement_Error(t *testing.T) {
+	if _, err := NewStatement(&file.DirectoryMetadata{Common: metadata.Common{Destination: t.TempDir()}}); err == nil {
+		t.Error("expected an error for a directory without a digest")
+	}
+	if _, err := NewStatement(&oci.OCIMetadata{Digest: "sha256:abc"}); err == nil || err.Error() != "metadata has no destination" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+type ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+func (s *ed25519Signer) KeyID() string {
+	return "test"
+}
+
+func (s *ed25519Signer) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, data), nil
+}
+
+// TestSign tests signing a statement into a DSSE envelope
+func TestSign(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStatement(&oci.OCIMetadata{Common: metadata.Common{Destination: "/tmp/bundle"}, Digest: "sha256:abc"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	envelope, err := Sign(context.Background(), s, &ed25519Signer{key: priv})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if envelope.PayloadType != PayloadType || len(envelope.Signatures) != 1 || envelope.Signatures[0].KeyID != "test" {
+		t.Fatalf("unexpected envelope: %+v", envelope)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(envelope.Signatures[0].Sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(pub, PAE(PayloadType, payload), sig) {
+		t.Error("signature does not verify")
+	}
+
+	var decoded Statement
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Subject, s.Subject) {
+		t.Errorf("unexpected payload subject: %+v", decoded.Subject)
+	}
+}
+
+// TestPAE tests the DSSE pre-authentication encoding
+func TestPAE(t *testing.T) {
+	// Test vector from the DSSE specification.
+	got := string(PAE("http://example.com/HelloWorld", []byte("hello world")))
+	want := "DSSEv1 29 http://example.com/HelloWorld 11 hello world"
+	if got != want {
+		t.Errorf("unexpected encoding: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package rsync
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// TestRsyncMetadata_Get tests the fields reported for a directory synchronized from a daemon
+func TestRsyncMetadata_Get(t *testing.T) {
+	m := RsyncMetadata{Host: "mirror.example.com", Module: "policies", Path: "release", Transferred: 2, Size: 42, TreeHash: "def"}
+	expected := map[string]any{
+		"host":        "mirror.example.com",
+		"module":      "policies",
+		"path":        "release",
+		"transferred": 2,
+		"size":        int64(42),
+		"treeHash":    "def",
+	}
+	if got := m.Get(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected fields: got %v, want %v", got, expected)
+	}
+}
+
+// TestRsyncMetadata_GetPinnedURL tests pinning sources to the digest of the synchronized content
+func TestRsyncMetadata_GetPinnedURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		metadata RsyncMetadata
+		expected string
+		err      string
+	}{
+		{name: "file", url: "rsync://mirror.example.com/policies/bundle.tar.gz", metadata: RsyncMetadata{SHA256: "abc"}, expected: "rsync://mirror.example.com/policies/bundle.tar.gz?checksum=sha256:abc"},
+		{name: "directory", url: "rsync+ssh://user@example.com/srv/policies/", metadata: RsyncMetadata{TreeHash: "def"}, expected: "rsync+ssh://user@example.com/srv/policies/?checksum=sha256:def"},
+		{name: "pinned", url: "rsync://mirror.example.com/policies/bundle.tar.gz?checksum=sha256:old", metadata: RsyncMetadata{SHA256: "abc"}, expected: "rsync://mirror.example.com/policies/bundle.tar.gz?checksum=sha256:abc"},
+		{name: "no digest", url: "rsync://mirror.example.com/policies/bundle.tar.gz", err: "digest not set"},
+		{name: "empty", metadata: RsyncMetadata{SHA256: "abc"}, err: "empty URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.metadata.GetPinnedURL(tt.url)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("unexpected URL: got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestRsyncMetadata_Unmarshal tests that the metadata is decoded as RsyncMetadata
+func TestRsyncMetadata_Unmarshal(t *testing.T) {
+	m := &RsyncMetadata{
+		Common:      metadata.Common{SourceURI: "rsync://mirror.example.com/policies/", Destination: "/tmp/policies"},
+		Host:        "mirror.example.com",
+		Module:      "policies",
+		Transferred: 1,
+		Size:        3,
+		TreeHash:    "def",
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := metadata.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, m) {
+		t.Errorf("unexpected metadata: got %+v, want %+v", decoded, m)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package s3
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"strings"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// Type is the type S3Metadata is serialized as.
+const Type = "s3"
+
+func init() {
+	if err := metadata.Register(Type, func(data []byte) (metadata.Metadata, error) {
+		m := &S3Metadata{}
+		return m, json.Unmarshal(data, m)
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// S3Metadata describes an object, or the objects below a prefix, downloaded from an S3 bucket.
+type S3Metadata struct {
+	metadata.Common
+	Bucket string `json:"bucket"`
+	// Key is the key of the object, or the prefix of the objects, ending with a slash.
+	Key string `json:"key"`
+	// ETag is the entity tag of the object, as reported by S3. It is not set for prefixes.
+	ETag string `json:"etag,omitempty"`
+	// VersionID is the version of the object, if versioning is enabled for the bucket. It is
+	// not set for prefixes.
+	VersionID string `json:"versionId,omitempty"`
+	// Size is the size of the object, or the total size of the objects, in bytes.
+	Size int64 `json:"size"`
+	// Objects lists the objects downloaded from a prefix.
+	Objects []Object `json:"objects,omitempty"`
+}
+
+// Object describes an object downloaded from a prefix.
+type Object struct {
+	Key       string `json:"key"`
+	ETag      string `json:"etag,omitempty"`
+	VersionID string `json:"versionId,omitempty"`
+	Size      int64  `json:"size"`
+}
+
+// MarshalJSON encodes the metadata as JSON, recording its type for metadata.Decode.
+func (m S3Metadata) MarshalJSON() ([]byte, error) {
+	type plain S3Metadata
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{Type, plain(m)})
+}
+
+func (m S3Metadata) Get() map[string]any {
+	fields := m.Common.Fields()
+	maps.Copy(fields, map[string]any{
+		"bucket":    m.Bucket,
+		"key":       m.Key,
+		"etag":      m.ETag,
+		"versionId": m.VersionID,
+		"size":      m.Size,
+	})
+	if len(m.Objects) > 0 {
+		fields["objects"] = m.Objects
+	}
+	return fields
+}
+
+// GetPinnedURL returns the URL with the version of the object set as its "version" query
+// parameter, replacing any version the URL already has. It returns an error if the URL is empty or
+// the version is not set, i.e. for prefixes and objects of buckets without versioning.
+func (m S3Metadata) GetPinnedURL(u string) (string, error) {
+	if len(u) == 0 {
+		return "", fmt.Errorf("empty URL")
+	}
+	if m.VersionID == "" {
+		return "", fmt.Errorf("version ID not set")
+	}
+
+	base, query, _ := strings.Cut(u, "?")
+	params := []string{}
+	for _, p := range strings.Split(query, "&") {
+		if p != "" && !strings.HasPrefix(p, "version=") {
+			params = append(params, p)
+		}
+	}
+	params = append(params, "version="+url.QueryEscape(m.VersionID))
+	return base + "?" + strings.Join(params, "&"), nil
+}
@@ -0,0 +1,96 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package s3
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// TestS3Metadata_Get tests the fields reported for an object
+func TestS3Metadata_Get(t *testing.T) {
+	m := S3Metadata{Bucket: "bucket", Key: "path/to/file.json", ETag: `"abc"`, VersionID: "v1", Size: 42}
+	expected := map[string]any{
+		"bucket":    "bucket",
+		"key":       "path/to/file.json",
+		"etag":      `"abc"`,
+		"versionId": "v1",
+		"size":      int64(42),
+	}
+	if got := m.Get(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected fields: got %v, want %v", got, expected)
+	}
+}
+
+// TestS3Metadata_GetPinnedURL tests pinning sources to the version of the object
+func TestS3Metadata_GetPinnedURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		version  string
+		expected string
+		err      string
+	}{
+		{name: "object", url: "s3://bucket/file.json", version: "v1", expected: "s3://bucket/file.json?version=v1"},
+		{name: "custom endpoint", url: "s3::https://minio.example.com/bucket/file.json?region=eu-west-1", version: "v1", expected: "s3::https://minio.example.com/bucket/file.json?region=eu-west-1&version=v1"},
+		{name: "pinned", url: "s3://bucket/file.json?version=v0", version: "v1+/", expected: "s3://bucket/file.json?version=v1%2B%2F"},
+		{name: "no version", url: "s3://bucket/file.json", err: "version ID not set"},
+		{name: "empty", url: "", version: "v1", err: "empty URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := S3Metadata{VersionID: tt.version}.GetPinnedURL(tt.url)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("unexpected URL: got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestS3Metadata_Decode tests that the metadata is decoded as S3Metadata
+func TestS3Metadata_Decode(t *testing.T) {
+	m := &S3Metadata{
+		Common:  metadata.Common{SourceURI: "s3://bucket/policies/", Destination: "/tmp/policies"},
+		Bucket:  "bucket",
+		Key:     "policies/",
+		Size:    3,
+		Objects: []Object{{Key: "policies/main.rego", ETag: `"abc"`, Size: 3}},
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := metadata.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, m) {
+		t.Errorf("unexpected metadata: got %+v, want %+v", decoded, m)
+	}
+}
@@ -0,0 +1,5 @@
+module github.com/enterprise-contract/go-gather/metadata/sftp
+
+go 1.22.5
+
+require github.com/enterprise-contract/go-gather/metadata v0.0.2
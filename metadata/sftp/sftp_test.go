@@ -0,0 +1,97 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package sftp
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// TestSFTPMetadata_Get tests the fields reported for a file
+func TestSFTPMetadata_Get(t *testing.T) {
+	modTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := SFTPMetadata{Path: "/srv/bundle.tar.gz", Size: 42, ModTime: modTime, SHA256: "abc"}
+	expected := map[string]any{
+		"path":    "/srv/bundle.tar.gz",
+		"size":    int64(42),
+		"modTime": modTime,
+		"sha256":  "abc",
+	}
+	if got := m.Get(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected fields: got %v, want %v", got, expected)
+	}
+}
+
+// TestSFTPMetadata_GetPinnedURL tests pinning sources to the digest of the downloaded content
+func TestSFTPMetadata_GetPinnedURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		metadata SFTPMetadata
+		expected string
+		err      string
+	}{
+		{name: "file", url: "sftp://user@example.com/srv/file.txt", metadata: SFTPMetadata{SHA256: "abc"}, expected: "sftp://user@example.com/srv/file.txt?checksum=sha256:abc"},
+		{name: "directory", url: "user@example.com:policies", metadata: SFTPMetadata{TreeHash: "def"}, expected: "user@example.com:policies?checksum=sha256:def"},
+		{name: "pinned", url: "scp://user@example.com:/srv/file.txt?checksum=sha256:old", metadata: SFTPMetadata{SHA256: "abc"}, expected: "scp://user@example.com:/srv/file.txt?checksum=sha256:abc"},
+		{name: "no digest", url: "sftp://user@example.com/srv/file.txt", err: "digest not set"},
+		{name: "empty", metadata: SFTPMetadata{SHA256: "abc"}, err: "empty URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.metadata.GetPinnedURL(tt.url)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("unexpected URL: got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestSFTPMetadata_Unmarshal tests that the metadata is decoded as SFTPMetadata
+func TestSFTPMetadata_Unmarshal(t *testing.T) {
+	m := &SFTPMetadata{
+		Common:   metadata.Common{SourceURI: "user@example.com:policies", Destination: "/tmp/policies"},
+		Path:     "policies",
+		Size:     3,
+		ModTime:  time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		TreeHash: "def",
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := metadata.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, m) {
+		t.Errorf("unexpected metadata: got %+v, want %+v", decoded, m)
+	}
+}
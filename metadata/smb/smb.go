@@ -0,0 +1,112 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package smb
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// Type is the type SMBMetadata is serialized as.
+const Type = "smb"
+
+func init() {
+	if err := metadata.Register(Type, func(data []byte) (metadata.Metadata, error) {
+		m := &SMBMetadata{}
+		return m, json.Unmarshal(data, m)
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// SMBMetadata describes a file, or a directory, downloaded from an SMB file share.
+type SMBMetadata struct {
+	metadata.Common
+	// Share is the name of the share on the server.
+	Share string `json:"share"`
+	// Path is the path of the file or directory within the share.
+	Path string `json:"path"`
+	// Files is the number of files downloaded.
+	Files int `json:"files"`
+	// Size is the size of the file, or the total size of the files of the directory, in bytes.
+	Size int64 `json:"size"`
+	// ModTime is the modification time of the file or directory on the server.
+	ModTime time.Time `json:"modTime"`
+	// SHA256 is the hex encoded SHA256 digest of a file.
+	SHA256 string `json:"sha256,omitempty"`
+	// TreeHash is the metadata.TreeHash of a directory.
+	TreeHash string `json:"treeHash,omitempty"`
+}
+
+// MarshalJSON encodes the metadata as JSON, recording its type for metadata.Decode.
+func (m SMBMetadata) MarshalJSON() ([]byte, error) {
+	type plain SMBMetadata
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{Type, plain(m)})
+}
+
+func (m SMBMetadata) Get() map[string]any {
+	fields := m.Common.Fields()
+	maps.Copy(fields, map[string]any{
+		"share":   m.Share,
+		"path":    m.Path,
+		"files":   m.Files,
+		"size":    m.Size,
+		"modTime": m.ModTime,
+	})
+	if m.SHA256 != "" {
+		fields["sha256"] = m.SHA256
+	}
+	if m.TreeHash != "" {
+		fields["treeHash"] = m.TreeHash
+	}
+	return fields
+}
+
+// GetPinnedURL returns the URL with the tree hash of the downloaded directory, or else the digest
+// of the downloaded file, appended as a "checksum=sha256:<digest>" query parameter, replacing any
+// checksum the URL already has. Files on shares are mutable, so gathering the pinned URL again
+// fails if the content changed. It returns an error if the URL is empty or neither digest is set.
+func (m SMBMetadata) GetPinnedURL(u string) (string, error) {
+	if len(u) == 0 {
+		return "", fmt.Errorf("empty URL")
+	}
+	digest := m.TreeHash
+	if digest == "" {
+		digest = m.SHA256
+	}
+	if digest == "" {
+		return "", fmt.Errorf("digest not set")
+	}
+
+	base, query, _ := strings.Cut(u, "?")
+	params := []string{}
+	for _, p := range strings.Split(query, "&") {
+		if p != "" && !strings.HasPrefix(p, "checksum=") {
+			params = append(params, p)
+		}
+	}
+	params = append(params, "checksum=sha256:"+digest)
+	return base + "?" + strings.Join(params, "&"), nil
+}
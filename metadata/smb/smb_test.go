@@ -0,0 +1,108 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package smb
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// TestSMBMetadata_Get tests the fields reported for a file downloaded from a share
+func TestSMBMetadata_Get(t *testing.T) {
+	modified := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := SMBMetadata{
+		Share:   "config",
+		Path:    "policies/policy.yaml",
+		Files:   1,
+		Size:    512,
+		ModTime: modified,
+		SHA256:  "abc",
+	}
+	expected := map[string]any{
+		"share":   "config",
+		"path":    "policies/policy.yaml",
+		"files":   1,
+		"size":    int64(512),
+		"modTime": modified,
+		"sha256":  "abc",
+	}
+	if got := m.Get(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected fields: got %v, want %v", got, expected)
+	}
+}
+
+// TestSMBMetadata_GetPinnedURL tests pinning sources to the digest of the content
+func TestSMBMetadata_GetPinnedURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		metadata SMBMetadata
+		expected string
+		err      string
+	}{
+		{name: "file", url: "smb://fileserver/config/policy.yaml", metadata: SMBMetadata{SHA256: "abc"}, expected: "smb://fileserver/config/policy.yaml?checksum=sha256:abc"},
+		{name: "directory", url: "smb://fileserver/config/policies", metadata: SMBMetadata{TreeHash: "def", SHA256: "abc"}, expected: "smb://fileserver/config/policies?checksum=sha256:def"},
+		{name: "pinned", url: "smb://fileserver/config/policy.yaml?checksum=sha256:old", metadata: SMBMetadata{SHA256: "abc"}, expected: "smb://fileserver/config/policy.yaml?checksum=sha256:abc"},
+		{name: "no digest", url: "smb://fileserver/config", err: "digest not set"},
+		{name: "empty", metadata: SMBMetadata{SHA256: "abc"}, err: "empty URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.metadata.GetPinnedURL(tt.url)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("unexpected URL: got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestSMBMetadata_Unmarshal tests that the metadata is decoded as SMBMetadata
+func TestSMBMetadata_Unmarshal(t *testing.T) {
+	m := &SMBMetadata{
+		Common:   metadata.Common{SourceURI: "smb://fileserver/config/policies", Destination: "/tmp/policies"},
+		Share:    "config",
+		Path:     "policies",
+		Files:    2,
+		Size:     3,
+		ModTime:  time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		TreeHash: "def",
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := metadata.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, m) {
+		t.Errorf("unexpected metadata: got %+v, want %+v", decoded, m)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package stdin
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// Type is the type StdinMetadata is serialized as.
+const Type = "stdin"
+
+func init() {
+	if err := metadata.Register(Type, func(data []byte) (metadata.Metadata, error) {
+		m := &StdinMetadata{}
+		return m, json.Unmarshal(data, m)
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// StdinMetadata describes the content read from standard input.
+type StdinMetadata struct {
+	metadata.Common
+	// Format is the format of the archive the content was expanded from, e.g. "tar.gz", or empty
+	// if the content was saved as is.
+	Format string `json:"format,omitempty"`
+	// Size is the number of bytes read from standard input.
+	Size int64 `json:"size"`
+	// SHA256 is the hex encoded SHA256 digest of the bytes read from standard input.
+	SHA256 string `json:"sha256"`
+	// TreeHash is the metadata.TreeHash of the directory the archive was expanded in.
+	TreeHash string `json:"treeHash,omitempty"`
+}
+
+// MarshalJSON encodes the metadata as JSON, recording its type for metadata.Decode.
+func (m StdinMetadata) MarshalJSON() ([]byte, error) {
+	type plain StdinMetadata
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{Type, plain(m)})
+}
+
+func (m StdinMetadata) Get() map[string]any {
+	fields := m.Common.Fields()
+	maps.Copy(fields, map[string]any{
+		"size":   m.Size,
+		"sha256": m.SHA256,
+	})
+	if m.Format != "" {
+		fields["format"] = m.Format
+	}
+	if m.TreeHash != "" {
+		fields["treeHash"] = m.TreeHash
+	}
+	return fields
+}
+
+// GetPinnedURL returns the URL with the tree hash of the expanded archive, or else the digest of
+// the content, appended as a "checksum=sha256:<digest>" query parameter, replacing any checksum
+// the URL already has, so that gathering it again verifies that the same content is piped in. It
+// returns an error if the URL is empty or neither digest is set.
+func (m StdinMetadata) GetPinnedURL(u string) (string, error) {
+	if len(u) == 0 {
+		return "", fmt.Errorf("empty URL")
+	}
+	digest := m.TreeHash
+	if digest == "" {
+		digest = m.SHA256
+	}
+	if digest == "" {
+		return "", fmt.Errorf("digest not set")
+	}
+
+	base, query, _ := strings.Cut(u, "?")
+	params := []string{}
+	for _, p := range strings.Split(query, "&") {
+		if p != "" && !strings.HasPrefix(p, "checksum=") {
+			params = append(params, p)
+		}
+	}
+	params = append(params, "checksum=sha256:"+digest)
+	return base + "?" + strings.Join(params, "&"), nil
+}
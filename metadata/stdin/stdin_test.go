@@ -0,0 +1,98 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package stdin
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// TestStdinMetadata_Get tests the fields reported for an archive expanded from standard input
+func TestStdinMetadata_Get(t *testing.T) {
+	m := StdinMetadata{
+		Format:   "tar.gz",
+		Size:     512,
+		SHA256:   "abc",
+		TreeHash: "def",
+	}
+	expected := map[string]any{
+		"format":   "tar.gz",
+		"size":     int64(512),
+		"sha256":   "abc",
+		"treeHash": "def",
+	}
+	if got := m.Get(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected fields: got %v, want %v", got, expected)
+	}
+}
+
+// TestStdinMetadata_GetPinnedURL tests pinning sources to the digest of the content
+func TestStdinMetadata_GetPinnedURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		metadata StdinMetadata
+		expected string
+		err      string
+	}{
+		{name: "file", url: "-", metadata: StdinMetadata{SHA256: "abc"}, expected: "-?checksum=sha256:abc"},
+		{name: "archive", url: "stdin://?archive=tar.gz", metadata: StdinMetadata{SHA256: "abc", TreeHash: "def"}, expected: "stdin://?archive=tar.gz&checksum=sha256:def"},
+		{name: "pinned", url: "-?checksum=sha256:old", metadata: StdinMetadata{SHA256: "abc"}, expected: "-?checksum=sha256:abc"},
+		{name: "no digest", url: "-", err: "digest not set"},
+		{name: "empty", metadata: StdinMetadata{SHA256: "abc"}, err: "empty URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.metadata.GetPinnedURL(tt.url)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("unexpected URL: got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestStdinMetadata_Unmarshal tests that the metadata is decoded as StdinMetadata
+func TestStdinMetadata_Unmarshal(t *testing.T) {
+	m := &StdinMetadata{
+		Common: metadata.Common{SourceURI: "-", Destination: "/tmp/policy.json"},
+		Size:   3,
+		SHA256: "abc",
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := metadata.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, m) {
+		t.Errorf("unexpected metadata: got %+v, want %+v", decoded, m)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package torrent
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+// Type is the type TorrentMetadata is serialized as.
+const Type = "torrent"
+
+func init() {
+	if err := metadata.Register(Type, func(data []byte) (metadata.Metadata, error) {
+		m := &TorrentMetadata{}
+		return m, json.Unmarshal(data, m)
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// TorrentMetadata describes the content gathered from a torrent.
+type TorrentMetadata struct {
+	metadata.Common
+	// InfoHash is the hex encoded infohash of the torrent.
+	InfoHash string `json:"infoHash"`
+	// Name is the name of the torrent.
+	Name string `json:"name"`
+	// Files is the number of files of the torrent that were gathered.
+	Files int `json:"files"`
+	// Size is the total size of the files that were gathered.
+	Size int64 `json:"size"`
+	// PieceLength is the length of the pieces of the torrent.
+	PieceLength int64 `json:"pieceLength"`
+	// Peers is the number of peers pieces were downloaded from.
+	Peers int `json:"peers"`
+	// Uploaded is the number of bytes uploaded to other peers while seeding.
+	Uploaded int64 `json:"uploaded"`
+	// SHA256 is the hex encoded SHA256 digest of the file of a single-file torrent.
+	SHA256 string `json:"sha256,omitempty"`
+	// TreeHash is the metadata.TreeHash of the directory the files of a multi-file torrent were
+	// saved in.
+	TreeHash string `json:"treeHash,omitempty"`
+}
+
+// MarshalJSON encodes the metadata as JSON, recording its type for metadata.Decode.
+func (m TorrentMetadata) MarshalJSON() ([]byte, error) {
+	type plain TorrentMetadata
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{Type, plain(m)})
+}
+
+func (m TorrentMetadata) Get() map[string]any {
+	fields := m.Common.Fields()
+	maps.Copy(fields, map[string]any{
+		"infoHash":    m.InfoHash,
+		"name":        m.Name,
+		"files":       m.Files,
+		"size":        m.Size,
+		"pieceLength": m.PieceLength,
+		"peers":       m.Peers,
+		"uploaded":    m.Uploaded,
+	})
+	if m.SHA256 != "" {
+		fields["sha256"] = m.SHA256
+	}
+	if m.TreeHash != "" {
+		fields["treeHash"] = m.TreeHash
+	}
+	return fields
+}
+
+// GetPinnedURL returns the URL naming the torrent by its infohash, which identifies its content:
+// the BitTorrent xt parameter of a magnet link is replaced with the hex encoded infohash, and a
+// "btih=<infohash>" query parameter is set on other URLs, so that gathering the URL again verifies
+// that the .torrent file still describes the same content. It returns an error if the URL is empty
+// or the infohash is not set.
+func (m TorrentMetadata) GetPinnedURL(u string) (string, error) {
+	if len(u) == 0 {
+		return "", fmt.Errorf("empty URL")
+	}
+	if m.InfoHash == "" {
+		return "", fmt.Errorf("infohash not set")
+	}
+
+	magnet := strings.HasPrefix(strings.TrimPrefix(u, "torrent::"), "magnet:")
+	base, query, _ := strings.Cut(u, "?")
+	params := []string{}
+	for _, p := range strings.Split(query, "&") {
+		if p != "" && !strings.HasPrefix(p, "btih=") && !(magnet && strings.HasPrefix(p, "xt=urn:btih:")) {
+			params = append(params, p)
+		}
+	}
+	if magnet {
+		params = append([]string{"xt=urn:btih:" + m.InfoHash}, params...)
+	} else {
+		params = append(params, "btih="+m.InfoHash)
+	}
+	return base + "?" + strings.Join(params, "&"), nil
+}
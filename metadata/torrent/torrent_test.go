@@ -0,0 +1,111 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package torrent
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/enterprise-contract/go-gather/metadata"
+)
+
+const infoHash = "c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+
+// TestTorrentMetadata_Get tests the fields reported for a multi-file torrent
+func TestTorrentMetadata_Get(t *testing.T) {
+	m := TorrentMetadata{
+		InfoHash:    infoHash,
+		Name:        "policy",
+		Files:       2,
+		Size:        512,
+		PieceLength: 16384,
+		Peers:       1,
+		TreeHash:    "def",
+	}
+	expected := map[string]any{
+		"infoHash":    infoHash,
+		"name":        "policy",
+		"files":       2,
+		"size":        int64(512),
+		"pieceLength": int64(16384),
+		"peers":       1,
+		"uploaded":    int64(0),
+		"treeHash":    "def",
+	}
+	if got := m.Get(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected fields: got %v, want %v", got, expected)
+	}
+}
+
+// TestTorrentMetadata_GetPinnedURL tests pinning sources to the infohash of the torrent
+func TestTorrentMetadata_GetPinnedURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		metadata TorrentMetadata
+		expected string
+		err      string
+	}{
+		{name: "magnet", url: "magnet:?xt=urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK&dn=policy", metadata: TorrentMetadata{InfoHash: infoHash}, expected: "magnet:?xt=urn:btih:" + infoHash + "&dn=policy"},
+		{name: "prefixed magnet", url: "torrent::magnet:?dn=policy&xt=urn:btih:" + infoHash, metadata: TorrentMetadata{InfoHash: infoHash}, expected: "torrent::magnet:?xt=urn:btih:" + infoHash + "&dn=policy"},
+		{name: "torrent", url: "torrent::https://example.com/policy.torrent", metadata: TorrentMetadata{InfoHash: infoHash}, expected: "torrent::https://example.com/policy.torrent?btih=" + infoHash},
+		{name: "pinned", url: "torrent::/tmp/policy.torrent?btih=old", metadata: TorrentMetadata{InfoHash: infoHash}, expected: "torrent::/tmp/policy.torrent?btih=" + infoHash},
+		{name: "no infohash", url: "torrent::/tmp/policy.torrent", err: "infohash not set"},
+		{name: "empty", metadata: TorrentMetadata{InfoHash: infoHash}, err: "empty URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.metadata.GetPinnedURL(tt.url)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("unexpected URL: got %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestTorrentMetadata_Unmarshal tests that the metadata is decoded as TorrentMetadata
+func TestTorrentMetadata_Unmarshal(t *testing.T) {
+	m := &TorrentMetadata{
+		Common:   metadata.Common{SourceURI: "magnet:?xt=urn:btih:" + infoHash, Destination: "/tmp/policy.json"},
+		InfoHash: infoHash,
+		Name:     "policy.json",
+		Files:    1,
+		Size:     3,
+		SHA256:   "abc",
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := metadata.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, m) {
+		t.Errorf("unexpected metadata: got %+v, want %+v", decoded, m)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// TreeHash returns the hex encoded Merkle tree hash of the directory dir, which identifies its
+// content independently of timestamps and ownership. The hash of a directory is the SHA256 digest
+// of its entries sorted by name, each on a line of the form "<mode> <hash> <name>", where the
+// mode is 100644 for regular files, 100755 for executable files, 120000 for symbolic links and
+// 040000 for directories, and the hash is the SHA256 digest of the content of a file, of the
+// target of a link or the tree hash of a directory. Version control metadata, i.e. ".git"
+// directories, and other file types are not included.
+func TreeHash(dir string) (string, error) {
+	return TreeHashWithDigests(dir, nil)
+}
+
+// TreeHashWithDigests returns the TreeHash of the directory dir, taking the SHA256 digests of the
+// regular files from digest, if set, e.g. the digests recorded while the files were written, so
+// that only the files digest reports no digest for are read.
+func TreeHashWithDigests(dir string, digest func(path string) ([]byte, bool)) (string, error) {
+	sum, err := treeHash(dir, digest)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute tree hash of %s: %w", dir, err)
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+func treeHash(dir string, digest func(path string) ([]byte, bool)) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	h := sha256.New()
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+
+		var mode string
+		var sum []byte
+		switch t := e.Type(); {
+		case t.IsDir():
+			if e.Name() == ".git" {
+				continue
+			}
+			mode = "040000"
+			sum, err = treeHash(path, digest)
+		case t&fs.ModeSymlink != 0:
+			mode = "120000"
+			var target string
+			if target, err = os.Readlink(path); err == nil {
+				s := sha256.Sum256([]byte(filepath.ToSlash(target)))
+				sum = s[:]
+			}
+		case t.IsRegular():
+			mode = "100644"
+			var info fs.FileInfo
+			if info, err = e.Info(); err == nil && info.Mode().Perm()&0111 != 0 {
+				mode = "100755"
+			}
+			if err == nil {
+				var ok bool
+				if digest != nil {
+					sum, ok = digest(path)
+				}
+				if !ok {
+					_, sum, err = hashFile(path)
+				}
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(h, "%s %x %s\n", mode, sum, e.Name())
+	}
+	return h.Sum(nil), nil
+}
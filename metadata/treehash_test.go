@@ -0,0 +1,130 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func mustTreeHash(t *testing.T, dir string) string {
+	t.Helper()
+	hash, err := TreeHash(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return hash
+}
+
+// TestTreeHash tests the tree hash of a known directory
+func TestTreeHash(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"sub/a.txt": "foo", ".git/config": "ignored"})
+
+	if hash := mustTreeHash(t, dir); hash != "361a424e2c5bcab4c0070b33a687f97a3fd029c3ee34d484457150f8b3072d78" {
+		t.Errorf("unexpected tree hash: %s", hash)
+	}
+
+	if _, err := TreeHash(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+// TestTreeHash_Changes tests which changes affect the tree hash
+func TestTreeHash_Changes(t *testing.T) {
+	files := map[string]string{"a.txt": "foo", "sub/b.txt": "bar"}
+	dir := t.TempDir()
+	writeTree(t, dir, files)
+	hash := mustTreeHash(t, dir)
+
+	t.Run("Timestamps", func(t *testing.T) {
+		other := t.TempDir()
+		writeTree(t, other, files)
+		old := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		if err := os.Chtimes(filepath.Join(other, "a.txt"), old, old); err != nil {
+			t.Fatal(err)
+		}
+		if h := mustTreeHash(t, other); h != hash {
+			t.Errorf("expected timestamps not to change the hash")
+		}
+	})
+
+	t.Run("Content", func(t *testing.T) {
+		other := t.TempDir()
+		writeTree(t, other, map[string]string{"a.txt": "foo", "sub/b.txt": "baz"})
+		if h := mustTreeHash(t, other); h == hash {
+			t.Errorf("expected content to change the hash")
+		}
+	})
+
+	t.Run("Name", func(t *testing.T) {
+		other := t.TempDir()
+		writeTree(t, other, map[string]string{"a.txt": "foo", "sub/c.txt": "bar"})
+		if h := mustTreeHash(t, other); h == hash {
+			t.Errorf("expected names to change the hash")
+		}
+	})
+
+	t.Run("Symlink", func(t *testing.T) {
+		other := t.TempDir()
+		writeTree(t, other, files)
+		if err := os.Symlink("a.txt", filepath.Join(other, "link")); err != nil {
+			t.Fatal(err)
+		}
+		if h := mustTreeHash(t, other); h == hash {
+			t.Errorf("expected links to change the hash")
+		}
+	})
+}
+
+// TestTreeHashWithDigests tests taking the digests of files from a lookup
+func TestTreeHashWithDigests(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.txt": "foo", "sub/b.txt": "bar"})
+	hash := mustTreeHash(t, dir)
+
+	none := func(string) ([]byte, bool) { return nil, false }
+	if h, err := TreeHashWithDigests(dir, none); err != nil || h != hash {
+		t.Errorf("expected the tree hash without digests, got %s: %v", h, err)
+	}
+
+	var looked []string
+	fake := func(path string) ([]byte, bool) {
+		looked = append(looked, path)
+		return make([]byte, 32), true
+	}
+	if h, err := TreeHashWithDigests(dir, fake); err != nil || h == hash {
+		t.Errorf("expected the digests to be used, got %s: %v", h, err)
+	}
+	if len(looked) != 2 {
+		t.Errorf("unexpected lookups: %v", looked)
+	}
+}
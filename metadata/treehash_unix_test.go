@@ -0,0 +1,39 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build unix
+
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestTreeHash_Mode tests that the executable bit changes the tree hash
+func TestTreeHash_Mode(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"run.sh": "echo"})
+	hash := mustTreeHash(t, dir)
+
+	if err := os.Chmod(filepath.Join(dir, "run.sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if h := mustTreeHash(t, dir); h == hash {
+		t.Errorf("expected the executable bit to change the hash")
+	}
+}
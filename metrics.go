@@ -0,0 +1,46 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"context"
+	"time"
+)
+
+// Metrics receives measurements of gathers, e.g. to export them to a monitoring system. The
+// metrics/prometheus module provides an implementation exporting them to Prometheus.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// ObserveGather is called when a gather finishes, with the protocol of its source, the error
+	// it failed with, if any, the number of bytes it wrote to the destination and its duration.
+	ObserveGather(protocol URIType, err error, bytes int64, duration time.Duration)
+	// ObserveCacheLookup is called when the content cache is looked up for a pinned source, with
+	// whether the content was found in it.
+	ObserveCacheLookup(hit bool)
+}
+
+// Written returns the number of bytes the gather carried by ctx has written to the destination so
+// far, see CountWritten.
+func Written(ctx context.Context) int64 {
+	s, ok := stateFromContext(ctx)
+	if !ok {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.written
+}
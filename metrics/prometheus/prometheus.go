@@ -0,0 +1,130 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package prometheus provides functionality for exporting the metrics of gathers to Prometheus.
+//
+// This package contains the PrometheusMetrics type, which implements the gogather.Metrics
+// interface with Prometheus collectors:
+//   - gogather_gathers_total counts the gathers by protocol and outcome.
+//   - gogather_gather_duration_seconds observes the duration of the gathers by protocol and outcome.
+//   - gogather_bytes_total counts the bytes written to the destinations by protocol.
+//   - gogather_cache_lookups_total counts the lookups of the content cache by result.
+//
+// The protocol label is one of "git", "http", "file", "oci", "s3", "sftp", "githubrelease",
+// "gitlab", "googledrive", "rsync", "helm", "kubernetes", "stdin", "torrent", "hdfs", "smb",
+// "forge" or "unknown", the outcome label one of "success", "canceled" or "error", and the result
+// label either "hit" or "miss".
+//
+// Example usage:
+//
+//	m, err := prometheus.NewPrometheusMetrics(prom.DefaultRegisterer)
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	_, err = gather.Gather(ctx, source, destination, gather.WithMetrics(m))
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Namespace is the prefix of the names of the metrics.
+const Namespace = "gogather"
+
+// PrometheusMetrics exports the measurements of gathers to Prometheus. It is safe for concurrent use.
+type PrometheusMetrics struct {
+	gathers      *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	bytes        *prometheus.CounterVec
+	cacheLookups *prometheus.CounterVec
+}
+
+// NewPrometheusMetrics returns a PrometheusMetrics whose collectors are registered with reg.
+func NewPrometheusMetrics(reg prometheus.Registerer) (*PrometheusMetrics, error) {
+	m := &PrometheusMetrics{
+		gathers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "gathers_total",
+			Help:      "Number of gathers by protocol and outcome.",
+		}, []string{"protocol", "outcome"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "gather_duration_seconds",
+			Help:      "Duration of gathers by protocol and outcome.",
+			Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+		}, []string{"protocol", "outcome"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "bytes_total",
+			Help:      "Number of bytes written to the destinations by protocol.",
+		}, []string{"protocol"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "cache_lookups_total",
+			Help:      "Number of lookups of the content cache by result.",
+		}, []string{"result"}),
+	}
+
+	for _, c := range []prometheus.Collector{m.gathers, m.duration, m.bytes, m.cacheLookups} {
+		if err := reg.Register(c); err != nil {
+			return nil, fmt.Errorf("failed to register metrics: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// ObserveGather implements the gogather.Metrics interface.
+func (m *PrometheusMetrics) ObserveGather(protocol gogather.URIType, err error, bytes int64, duration time.Duration) {
+	p := protocolLabel(protocol)
+	o := outcome(err)
+	m.gathers.WithLabelValues(p, o).Inc()
+	m.duration.WithLabelValues(p, o).Observe(duration.Seconds())
+	if bytes > 0 {
+		m.bytes.WithLabelValues(p).Add(float64(bytes))
+	}
+}
+
+// ObserveCacheLookup implements the gogather.Metrics interface.
+func (m *PrometheusMetrics) ObserveCacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cacheLookups.WithLabelValues(result).Inc()
+}
+
+// protocolLabel returns the label of protocol, e.g. "git" for gogather.GitURI.
+func protocolLabel(protocol gogather.URIType) string {
+	return strings.ToLower(strings.TrimSuffix(protocol.String(), "URI"))
+}
+
+// outcome returns the label of the outcome of a gather that failed with err, if it is not nil.
+func outcome(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	}
+	return "error"
+}
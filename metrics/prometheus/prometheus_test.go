@@ -0,0 +1,107 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ gogather.Metrics = &PrometheusMetrics{}
+
+// collect returns the values of the counters and the sample counts of the histograms of reg, keyed
+// by their name and labels, e.g. `gogather_gathers_total{protocol="git",outcome="success"}`.
+func collect(t *testing.T, reg *prometheus.Registry) map[string]float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]float64{}
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			labels := make([]string, 0, len(m.GetLabel()))
+			for _, l := range m.GetLabel() {
+				labels = append(labels, fmt.Sprintf("%s=%q", l.GetName(), l.GetValue()))
+			}
+			key := f.GetName() + "{" + strings.Join(labels, ",") + "}"
+			if h := m.GetHistogram(); h != nil {
+				values[key] = float64(h.GetSampleCount())
+			} else {
+				values[key] = m.GetCounter().GetValue()
+			}
+		}
+	}
+	return values
+}
+
+// TestPrometheusMetrics tests exporting the measurements of gathers
+func TestPrometheusMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := NewPrometheusMetrics(reg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m.ObserveGather(gogather.GitURI, nil, 100, time.Second)
+	m.ObserveGather(gogather.GitURI, nil, 50, time.Second)
+	m.ObserveGather(gogather.OCIURI, fmt.Errorf("failed: %w", context.Canceled), 10, time.Second)
+	m.ObserveGather(gogather.HTTPURI, errors.New("response code error: 404"), 0, time.Second)
+	m.ObserveCacheLookup(true)
+	m.ObserveCacheLookup(false)
+	m.ObserveCacheLookup(false)
+
+	want := map[string]float64{
+		`gogather_gathers_total{outcome="success",protocol="git"}`:            2,
+		`gogather_gathers_total{outcome="canceled",protocol="oci"}`:           1,
+		`gogather_gathers_total{outcome="error",protocol="http"}`:             1,
+		`gogather_gather_duration_seconds{outcome="success",protocol="git"}`:  2,
+		`gogather_gather_duration_seconds{outcome="canceled",protocol="oci"}`: 1,
+		`gogather_gather_duration_seconds{outcome="error",protocol="http"}`:   1,
+		`gogather_bytes_total{protocol="git"}`:                                150,
+		`gogather_bytes_total{protocol="oci"}`:                                10,
+		`gogather_cache_lookups_total{result="hit"}`:                          1,
+		`gogather_cache_lookups_total{result="miss"}`:                         2,
+	}
+	got := collect(t, reg)
+	if len(got) != len(want) {
+		t.Errorf("unexpected metrics: %v", got)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("unexpected value of %s: %v, want %v", key, got[key], value)
+		}
+	}
+}
+
+// TestNewPrometheusMetrics_Error tests that the collectors cannot be registered twice
+func TestNewPrometheusMetrics_Error(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if _, err := NewPrometheusMetrics(reg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := NewPrometheusMetrics(reg); err == nil {
+		t.Error("expected an error registering the collectors twice")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// SourceDateEpochEnv is the environment variable setting the modification time of normalized
+// content, as seconds since the Unix epoch, see https://reproducible-builds.org/specs/source-date-epoch/.
+const SourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// defaultNormalizedTime is the modification time of normalized content when SOURCE_DATE_EPOCH is
+// not set. It is the earliest time zip archives can record, so that normalized content can be
+// archived without its times being altered.
+var defaultNormalizedTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// NormalizedTime returns the modification time Normalize sets: the time SOURCE_DATE_EPOCH is set
+// to, or else 1980-01-01T00:00:00Z.
+func NormalizedTime() (time.Time, error) {
+	v, ok := os.LookupEnv(SourceDateEpochEnv)
+	if !ok || v == "" {
+		return defaultNormalizedTime, nil
+	}
+	secs, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s: %w", SourceDateEpochEnv, err)
+	}
+	return time.Unix(secs, 0).UTC(), nil
+}
+
+// Normalize rewrites the gathered file or directory at path so that gathers of the same content
+// produce identical trees, whatever the protocol, the host or the time of the gather: the
+// modification times of files and directories are set to NormalizedTime, the permissions of
+// directories and executable files to 0755 and of other files to 0644, and volatile content is
+// removed, i.e. version control metadata, which records the times of the clone, and files other
+// than regular files, directories and symbolic links. This is the content metadata.TreeHash
+// covers. Directories are processed in lexical order. Symbolic links are kept as they are, as
+// their times cannot be set portably.
+func Normalize(path string) error {
+	t, err := NormalizedTime()
+	if err != nil {
+		return err
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("failed to normalize %s: %w", path, err)
+	}
+	if err := normalize(path, info.Mode(), t); err != nil {
+		return fmt.Errorf("failed to normalize %s: %w", path, err)
+	}
+	return nil
+}
+
+// normalize normalizes the file of the given mode at path, and the content of directories before
+// the directories themselves, as removing their entries changes their times.
+func normalize(path string, mode fs.FileMode, t time.Time) error {
+	switch {
+	case mode.IsDir():
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			p := filepath.Join(path, e.Name())
+			if e.IsDir() && e.Name() == ".git" {
+				if err := os.RemoveAll(p); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := normalize(p, e.Type(), t); err != nil {
+				return err
+			}
+		}
+		if err := os.Chmod(path, 0755); err != nil {
+			return err
+		}
+	case mode&fs.ModeSymlink != 0:
+		return nil
+	case mode.IsRegular():
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		perm := fs.FileMode(0644)
+		if info.Mode().Perm()&0111 != 0 {
+			perm = 0755
+		}
+		if err := os.Chmod(path, perm); err != nil {
+			return err
+		}
+	default:
+		return os.Remove(path)
+	}
+	return os.Chtimes(path, t, t)
+}
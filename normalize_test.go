@@ -0,0 +1,44 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestNormalize_SourceDateEpoch tests normalizing the time of a file to SOURCE_DATE_EPOCH
+func TestNormalize_SourceDateEpoch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte("content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(SourceDateEpochEnv, "1700000000")
+	if err := Normalize(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.ModTime().Unix() != 1700000000 {
+		t.Errorf("unexpected file: %v, %v", info, err)
+	}
+
+	t.Setenv(SourceDateEpochEnv, "yesterday")
+	if err := Normalize(path); err == nil {
+		t.Error("expected an invalid SOURCE_DATE_EPOCH to fail")
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build unix
+
+package gogather
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestNormalize tests normalizing the times and permissions of a directory and removing volatile
+// files
+func TestNormalize(t *testing.T) {
+	t.Setenv(SourceDateEpochEnv, "")
+	dir := t.TempDir()
+	for name, mode := range map[string]os.FileMode{"main.rego": 0600, "bin/run.sh": 0700, ".git/index": 0644} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("main.rego", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mkfifo(filepath.Join(dir, "fifo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Normalize(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, mode := range map[string]os.FileMode{".": os.ModeDir | 0755, "bin": os.ModeDir | 0755, "main.rego": 0644, "bin/run.sh": 0755} {
+		info, err := os.Lstat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info.Mode() != mode || !info.ModTime().Equal(want) {
+			t.Errorf("unexpected %s: %v, %v", name, info.Mode(), info.ModTime())
+		}
+	}
+	for _, name := range []string{".git", "fifo"} {
+		if _, err := os.Lstat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", name, err)
+		}
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "link")); err != nil || target != "main.rego" {
+		t.Errorf("expected the link to be kept, got %q, %v", target, err)
+	}
+}
@@ -0,0 +1,572 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// GatherOptions holds the settings shared by all gatherers. They are passed to the gatherers
+// through the context with ContextWithOptions, and read by them with OptionsFromContext.
+type GatherOptions struct {
+	// Timeout bounds the duration of the gather. Zero means no timeout.
+	Timeout time.Duration
+	// MaxSize is the maximum number of bytes a gather may write to the destination, whichever
+	// protocol it gathers. Gathers exceeding it fail with an error matching ErrTooLarge. Zero
+	// means no limit.
+	MaxSize int64
+	// Progress, if set, receives reports of the progress of the gather.
+	Progress Progress
+	// Logger receives diagnostic messages. When nil, messages are discarded.
+	Logger *slog.Logger
+	// Auth, if set, supplies the credentials for the hosts gatherers contact.
+	Auth AuthProvider
+	// Include lists glob patterns of the paths, relative to the destination, to keep. When
+	// empty, every path is kept. "*" matches within a single path element and "**" matches
+	// across elements, e.g. "policy/**.rego".
+	Include []string
+	// Exclude lists glob patterns of the paths to drop, using the same syntax as Include.
+	// Exclusions take precedence over inclusions.
+	Exclude []string
+	// DryRun validates the source without gathering it.
+	DryRun bool
+	// Archive overrides the detection of archives by the gatherers that expand them: "false"
+	// disables the expansion, any other value is the format of the archive, see
+	// expander.Formats. It is set from the archive parameter of go-getter style sources.
+	Archive string
+	// Checksum is the expected checksum of the gathered content, see ParseChecksum. Files are
+	// verified by their content and directories by their sha256 tree hash. It is set from the
+	// checksum parameter of go-getter style sources.
+	Checksum string
+	// CacheDir, if set, is the directory of the content cache gather.Gather serves pinned sources
+	// from, see gather.Cache.
+	CacheDir string
+	// CacheMaxSize bounds the size of the content cache in bytes. Zero means no limit.
+	CacheMaxSize int64
+	// NoCache bypasses lookups in the content cache. Gathered content is still stored in it.
+	NoCache bool
+	// HostPolicy, if set, restricts the schemes and hosts the gatherers may contact.
+	HostPolicy *HostPolicy
+	// FS, if set, is the filesystem gather.Gather writes the gathered content to, with the
+	// destination being a slash separated path within it. When nil, content is written to the
+	// local disk.
+	FS WriteFS
+	// Metrics, if set, receives measurements of the gathers performed by gather.Gather.
+	Metrics Metrics
+	// Proxy, if set, is the URL of the proxy the gatherers contacting hosts over HTTP connect
+	// through. When empty, the proxy is taken from the environment, e.g. HTTPS_PROXY.
+	Proxy string
+	// InsecureSkipTLSVerify disables the verification of the TLS certificates of the hosts the
+	// gatherers contact.
+	InsecureSkipTLSVerify bool
+	// TempDir, if set, is the directory gatherers create their scratch directories in, e.g. to
+	// clone a repository before copying a subdirectory of it. When empty, the default directory
+	// for temporary files is used, see os.TempDir.
+	TempDir string
+	// KeepPartial keeps whatever a failed gather wrote to the destination. By default,
+	// gather.Gather rolls failed gathers back, see gather.RollbackGatherer.
+	KeepPartial bool
+	// Hooks are called at the stages of the gathers performed by gather.Gather, e.g. to audit or
+	// scan the gathered content, see HookStage.
+	Hooks Hooks
+	// Deterministic normalizes the content written by gather.Gather, so that gathers of the same
+	// pinned source produce identical trees, e.g. for reproducible builds of policy bundles, see
+	// Normalize.
+	Deterministic bool
+}
+
+// Progress receives reports of the progress of a gather. The gatherers of every protocol report
+// through it, so that the same interface serves all of them. Its methods are never called
+// concurrently for a gather.
+type Progress interface {
+	// Start is called when the gather starts with the expected number of bytes and items, e.g.
+	// files or layers, to write. Either is -1 when it is unknown.
+	Start(totalBytes int64, totalItems int)
+	// Update is called with the number of bytes and items written so far.
+	Update(bytes int64, items int)
+	// Done is called when the gather finishes, with the error it failed with, if any.
+	Done(err error)
+}
+
+// ProgressFunc is a Progress implemented by a function called with the number of bytes written
+// so far. It ignores the start and the end of the gather.
+type ProgressFunc func(written int64)
+
+// Start implements the Progress interface.
+func (f ProgressFunc) Start(int64, int) {}
+
+// Update implements the Progress interface.
+func (f ProgressFunc) Update(bytes int64, _ int) {
+	f(bytes)
+}
+
+// Done implements the Progress interface.
+func (f ProgressFunc) Done(error) {}
+
+// Credentials authenticate a gatherer with a host.
+type Credentials struct {
+	// Username is the user to authenticate as. When empty, Password is used as a bearer token
+	// where the protocol supports it.
+	Username string
+	// Password is the password or token of the user.
+	Password string
+}
+
+// AuthProvider supplies credentials for the hosts gatherers contact.
+type AuthProvider interface {
+	// Credentials returns the credentials for host, or nil if there are none.
+	Credentials(ctx context.Context, host string) (*Credentials, error)
+}
+
+// AuthProviderFunc is an AuthProvider implemented by a function.
+type AuthProviderFunc func(ctx context.Context, host string) (*Credentials, error)
+
+// Credentials implements the AuthProvider interface.
+func (f AuthProviderFunc) Credentials(ctx context.Context, host string) (*Credentials, error) {
+	return f(ctx, host)
+}
+
+// ErrTooLarge is matched by the errors of gathers failing because their content exceeds a size
+// limit, e.g. a MaxSizeError, whichever protocol they gather.
+var ErrTooLarge = errors.New("gathered content is too large")
+
+// MaxSizeError is returned when a gather writes more than the MaxSize bytes it is allowed to.
+type MaxSizeError struct {
+	Limit int64
+}
+
+func (e *MaxSizeError) Error() string {
+	return fmt.Sprintf("gathered content exceeds the %d byte limit", e.Limit)
+}
+
+// Is reports whether target is ErrTooLarge.
+func (e *MaxSizeError) Is(target error) bool {
+	return target == ErrTooLarge
+}
+
+type optionsKey struct{}
+
+// gatherState holds the options of a gather and its progress so far.
+type gatherState struct {
+	o GatherOptions
+
+	mu       sync.Mutex
+	written  int64
+	items    int
+	started  bool
+	finished bool
+	verified bool
+}
+
+// ContextWithOptions returns a copy of ctx carrying the gather options o. The bytes counted by
+// WrapReader are tracked per returned context, so each gather should use a context of its own.
+func ContextWithOptions(ctx context.Context, o GatherOptions) context.Context {
+	return context.WithValue(ctx, optionsKey{}, &gatherState{o: o})
+}
+
+// stateFromContext returns the state of the gather carried by ctx, and whether there is one.
+func stateFromContext(ctx context.Context) (*gatherState, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	s, ok := ctx.Value(optionsKey{}).(*gatherState)
+	return s, ok
+}
+
+// OptionsFromContext returns the gather options carried by ctx, or the package-wide defaults if
+// there are none, see DefaultConfig.
+func OptionsFromContext(ctx context.Context) GatherOptions {
+	if s, ok := stateFromContext(ctx); ok {
+		return s.o
+	}
+	return applyDefaults(GatherOptions{})
+}
+
+// Log returns the logger of the options, which discards messages if none is set.
+func (o GatherOptions) Log() *slog.Logger {
+	if o.Logger == nil {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return o.Logger
+}
+
+// Logger returns logger if it is set, e.g. on the gatherer, and the logger of the gather options
+// carried by ctx otherwise.
+func Logger(ctx context.Context, logger *slog.Logger) *slog.Logger {
+	if logger != nil {
+		return logger
+	}
+	return OptionsFromContext(ctx).Log()
+}
+
+// MkdirTemp creates a new scratch directory for the gather carried by ctx in the TempDir of its
+// options, creating TempDir if needed, see os.MkdirTemp. The caller must remove the directory
+// when done with it, whether the gather succeeds or not.
+func MkdirTemp(ctx context.Context, pattern string) (string, error) {
+	dir, err := tempDir(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	tmp, err := os.MkdirTemp(dir, pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	return tmp, nil
+}
+
+// CreateTemp creates a new scratch file for the gather carried by ctx in the TempDir of its
+// options, creating TempDir if needed, see os.CreateTemp. The caller must close and remove the
+// file when done with it.
+func CreateTemp(ctx context.Context, pattern string) (*os.File, error) {
+	dir, err := tempDir(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	return f, nil
+}
+
+// tempDir returns the TempDir of the options carried by ctx, creating it if needed, or "" for the
+// default directory for temporary files.
+func tempDir(ctx context.Context) (string, error) {
+	dir := OptionsFromContext(ctx).TempDir
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// Credentials returns the credentials for host from the Auth provider, or nil if no provider is
+// set or it has no credentials for the host.
+func (o GatherOptions) Credentials(ctx context.Context, host string) (*Credentials, error) {
+	if o.Auth == nil {
+		return nil, nil
+	}
+	c, err := o.Auth.Credentials(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials for %s: %w", host, err)
+	}
+	return c, nil
+}
+
+// Prune removes the files below the gathered directory dir that are not selected by the Include
+// and Exclude patterns, see PathFilter.Prune.
+func (o GatherOptions) Prune(dir string) error {
+	f, err := NewPathFilter(o.Include, o.Exclude)
+	if err != nil {
+		return err
+	}
+	return f.Prune(dir)
+}
+
+// VerifyChecksum verifies the file at path against the Checksum of the gather options carried by
+// ctx, if set, and records that the gather was verified. It returns a ChecksumMismatchError if the
+// file does not match.
+func VerifyChecksum(ctx context.Context, path string) error {
+	s, ok := stateFromContext(ctx)
+	if !ok || s.o.Checksum == "" {
+		return nil
+	}
+	c, err := ParseChecksum(s.o.Checksum)
+	if err != nil {
+		return err
+	}
+	if err := c.VerifyFile(path); err != nil {
+		return err
+	}
+	s.markVerified()
+	return nil
+}
+
+// ChecksumVerifier verifies content against the Checksum of the gather options while the content
+// is written, so that it does not have to be read back, see NewChecksumVerifier.
+type ChecksumVerifier struct {
+	state    *gatherState
+	checksum Checksum
+	h        hash.Hash
+}
+
+// NewChecksumVerifier returns a ChecksumVerifier for the Checksum of the gather options carried by
+// ctx. Without a checksum, the verifier verifies nothing.
+func NewChecksumVerifier(ctx context.Context) (*ChecksumVerifier, error) {
+	s, ok := stateFromContext(ctx)
+	if !ok || s.o.Checksum == "" {
+		return &ChecksumVerifier{}, nil
+	}
+	c, err := ParseChecksum(s.o.Checksum)
+	if err != nil {
+		return nil, err
+	}
+	return &ChecksumVerifier{state: s, checksum: c, h: checksumAlgorithms[c.Algorithm]()}, nil
+}
+
+// Reader returns r, hashing the content read from it for the verification.
+func (v *ChecksumVerifier) Reader(r io.Reader) io.Reader {
+	if v.h == nil {
+		return r
+	}
+	return io.TeeReader(r, v.h)
+}
+
+// Verify compares the digest of the content read through Reader, which was gathered to path,
+// against the checksum, and records that the gather was verified. It returns a
+// ChecksumMismatchError if the content does not match.
+func (v *ChecksumVerifier) Verify(path string) error {
+	if v.h == nil {
+		return nil
+	}
+	if actual := hex.EncodeToString(v.h.Sum(nil)); actual != v.checksum.Value {
+		return &ChecksumMismatchError{Path: path, Expected: v.checksum, Actual: actual}
+	}
+	v.state.markVerified()
+	return nil
+}
+
+// VerifyChecksumDigest compares the hex encoded digest of the content at path, computed with the
+// hash algorithm, e.g. the sha256 tree hash of a directory, against the Checksum of the gather
+// options carried by ctx, if set, and records that the gather was verified. It returns a
+// ChecksumMismatchError if the digest does not match.
+func VerifyChecksumDigest(ctx context.Context, path, algorithm, digest string) error {
+	s, ok := stateFromContext(ctx)
+	if !ok || s.o.Checksum == "" {
+		return nil
+	}
+	c, err := ParseChecksum(s.o.Checksum)
+	if err != nil {
+		return err
+	}
+	if c.Algorithm != algorithm {
+		return fmt.Errorf("a %s checksum cannot be verified for %s, expected a %s checksum", c.Algorithm, path, algorithm)
+	}
+	if digest != c.Value {
+		return &ChecksumMismatchError{Path: path, Expected: c, Actual: digest}
+	}
+	s.markVerified()
+	return nil
+}
+
+// ChecksumVerified reports whether the gather carried by ctx has been verified against the
+// Checksum of its options.
+func ChecksumVerified(ctx context.Context) bool {
+	s, ok := stateFromContext(ctx)
+	if !ok {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.verified
+}
+
+// markVerified records that the gather was verified against its checksum.
+func (s *gatherState) markVerified() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.verified = true
+}
+
+// StartProgress reports the start of the gather carried by ctx, with the expected number of bytes
+// and items to write, or -1 if unknown, to the Progress of its options. Only the first call for a
+// gather is reported.
+func StartProgress(ctx context.Context, totalBytes int64, totalItems int) {
+	s, ok := stateFromContext(ctx)
+	if !ok || s.o.Progress == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+	s.o.Progress.Start(totalBytes, totalItems)
+}
+
+// FinishProgress reports the end of the gather carried by ctx, which failed with err if it is not
+// nil, to the Progress of its options. Only the first call for a gather is reported.
+func FinishProgress(ctx context.Context, err error) {
+	s, ok := stateFromContext(ctx)
+	if !ok || s.o.Progress == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		return
+	}
+	s.finished = true
+	s.o.Progress.Done(err)
+}
+
+// CountWritten records that the gather carried by ctx has written n more bytes to the
+// destination. It reports the progress and returns a MaxSizeError once more than MaxSize bytes
+// have been written in total. The bytes are then passed to the throttle of ctx, if any, see
+// ContextWithThrottle. It is safe for concurrent use.
+func CountWritten(ctx context.Context, n int64) error {
+	return count(ctx, n, 0)
+}
+
+// CheckWritten returns a MaxSizeError if the gather carried by ctx would exceed its MaxSize by
+// writing n more bytes, without recording them. Gatherers that know the size of the content ahead,
+// e.g. from a Content-Length header, use it to fail before transferring it.
+func CheckWritten(ctx context.Context, n int64) error {
+	s, ok := stateFromContext(ctx)
+	if !ok || s.o.MaxSize <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.written+n > s.o.MaxSize {
+		return &MaxSizeError{Limit: s.o.MaxSize}
+	}
+	return nil
+}
+
+// CountItems records that the gather carried by ctx has completed writing n more items, e.g.
+// files or layers, and reports the progress.
+func CountItems(ctx context.Context, n int) {
+	_ = count(ctx, 0, n)
+}
+
+// count records the bytes and items written by the gather carried by ctx, see CountWritten, and
+// passes the bytes to the throttle of ctx, if any.
+func count(ctx context.Context, n int64, items int) error {
+	if err := record(ctx, n, items); err != nil {
+		return err
+	}
+	if throttle := throttleFromContext(ctx); throttle != nil && n > 0 {
+		return throttle(ctx, n)
+	}
+	return nil
+}
+
+// record records the bytes and items written by the gather carried by ctx and reports the
+// progress.
+func record(ctx context.Context, n int64, items int) error {
+	s, ok := stateFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.written += n
+	s.items += items
+	if s.o.MaxSize > 0 && s.written > s.o.MaxSize {
+		return &MaxSizeError{Limit: s.o.MaxSize}
+	}
+	if s.o.Progress != nil && (n > 0 || items > 0) {
+		s.o.Progress.Update(s.written, s.items)
+	}
+	return nil
+}
+
+// CountWrittenDir records the regular files below dir and their size as written, see CountWritten
+// and CountItems. It is meant for gatherers that cannot observe the data while writing it.
+func CountWrittenDir(ctx context.Context, dir string) error {
+	if _, ok := stateFromContext(ctx); !ok && throttleFromContext(ctx) == nil {
+		return nil
+	}
+	var (
+		size  int64
+		files int
+	)
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		files++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to determine the size of %s: %w", dir, err)
+	}
+	return count(ctx, size, files)
+}
+
+type throttleKey struct{}
+
+// Throttle is called with the number of bytes a gather has just written to the destination. It may
+// block to limit the rate the gather writes at. Returning an error fails the gather.
+type Throttle func(ctx context.Context, n int64) error
+
+// ContextWithThrottle returns a copy of ctx whose gathers pass the number of bytes they write to
+// throttle, after the throttle already carried by ctx, if any.
+func ContextWithThrottle(ctx context.Context, throttle Throttle) context.Context {
+	if parent := throttleFromContext(ctx); parent != nil {
+		inner := throttle
+		throttle = func(ctx context.Context, n int64) error {
+			if err := parent(ctx, n); err != nil {
+				return err
+			}
+			return inner(ctx, n)
+		}
+	}
+	return context.WithValue(ctx, throttleKey{}, throttle)
+}
+
+// throttleFromContext returns the throttle carried by ctx, or nil if there is none.
+func throttleFromContext(ctx context.Context) Throttle {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(throttleKey{}).(Throttle)
+	return t
+}
+
+// WrapReader wraps r, which yields data a gatherer writes to the destination, so that the data
+// read from it is counted as written, see CountWritten.
+func WrapReader(ctx context.Context, r io.Reader) io.Reader {
+	s, ok := stateFromContext(ctx)
+	if (!ok || (s.o.Progress == nil && s.o.MaxSize <= 0 && s.o.Metrics == nil)) && throttleFromContext(ctx) == nil {
+		return r
+	}
+	return &countingReader{ctx: ctx, r: r}
+}
+
+// countingReader counts the data read through it as written by the gather carried by ctx.
+type countingReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if cerr := CountWritten(r.ctx, int64(n)); cerr != nil {
+		return n, cerr
+	}
+	return n, err
+}
@@ -0,0 +1,296 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestOptionsFromContext tests passing gather options through a context
+func TestOptionsFromContext(t *testing.T) {
+	if o := OptionsFromContext(context.Background()); o.MaxSize != 0 || o.DryRun {
+		t.Errorf("expected zero options, got %+v", o)
+	}
+
+	ctx := ContextWithOptions(context.Background(), GatherOptions{MaxSize: 10, DryRun: true})
+	if o := OptionsFromContext(ctx); o.MaxSize != 10 || !o.DryRun {
+		t.Errorf("unexpected options: %+v", o)
+	}
+	if OptionsFromContext(ctx).Log() == nil {
+		t.Error("expected a logger")
+	}
+}
+
+// TestWrapReader tests enforcing the maximum size and reporting progress
+func TestWrapReader(t *testing.T) {
+	var progress []int64
+	ctx := ContextWithOptions(context.Background(), GatherOptions{
+		MaxSize:  8,
+		Progress: ProgressFunc(func(written int64) { progress = append(progress, written) }),
+	})
+
+	if _, err := io.ReadAll(WrapReader(ctx, strings.NewReader("hello"))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(progress) == 0 || progress[len(progress)-1] != 5 {
+		t.Errorf("unexpected progress: %v", progress)
+	}
+
+	// The limit applies to all data read during the gather.
+	_, err := io.ReadAll(WrapReader(ctx, strings.NewReader("world")))
+	var sizeErr *MaxSizeError
+	if !errors.As(err, &sizeErr) || sizeErr.Limit != 8 {
+		t.Errorf("expected a MaxSizeError, got %v", err)
+	}
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+
+	r := strings.NewReader("unwrapped")
+	if WrapReader(context.Background(), r) != r {
+		t.Error("expected the reader to be returned as is without options")
+	}
+}
+
+// TestCheckWritten tests failing ahead of writing content that would exceed the maximum size
+func TestCheckWritten(t *testing.T) {
+	ctx := ContextWithOptions(context.Background(), GatherOptions{MaxSize: 8})
+	if err := CountWritten(ctx, 5); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := CheckWritten(ctx, 3); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := CheckWritten(ctx, 4); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	if err := CheckWritten(context.Background(), 1<<40); err != nil {
+		t.Errorf("unexpected error without a limit: %v", err)
+	}
+}
+
+// TestMkdirTemp tests creating scratch directories in the TempDir of the gather options
+func TestMkdirTemp(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "scratch")
+	ctx := ContextWithOptions(context.Background(), GatherOptions{TempDir: dir})
+	tmp, err := MkdirTemp(ctx, "test-")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filepath.Dir(tmp) != dir || !strings.HasPrefix(filepath.Base(tmp), "test-") {
+		t.Errorf("unexpected temporary directory: %s", tmp)
+	}
+
+	tmp, err = MkdirTemp(context.Background(), "test-")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+	if filepath.Dir(tmp) != filepath.Clean(os.TempDir()) {
+		t.Errorf("expected the default directory for temporary files, got %s", tmp)
+	}
+}
+
+// TestCreateTemp tests creating scratch files in the TempDir of the gather options
+func TestCreateTemp(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "scratch")
+	ctx := ContextWithOptions(context.Background(), GatherOptions{TempDir: dir})
+	f, err := CreateTemp(ctx, "test-")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Close()
+	if filepath.Dir(f.Name()) != dir || !strings.HasPrefix(filepath.Base(f.Name()), "test-") {
+		t.Errorf("unexpected temporary file: %s", f.Name())
+	}
+
+	f, err = CreateTemp(context.Background(), "test-")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if filepath.Dir(f.Name()) != filepath.Clean(os.TempDir()) {
+		t.Errorf("expected the default directory for temporary files, got %s", f.Name())
+	}
+}
+
+// TestContextWithThrottle tests passing written bytes to the throttles of a context
+func TestContextWithThrottle(t *testing.T) {
+	var calls []string
+	throttle := func(name string, err error) Throttle {
+		return func(_ context.Context, n int64) error {
+			calls = append(calls, name+":"+strconv.FormatInt(n, 10))
+			return err
+		}
+	}
+
+	ctx := ContextWithThrottle(context.Background(), throttle("outer", nil))
+	ctx = ContextWithThrottle(ctx, throttle("inner", nil))
+	if _, err := io.ReadAll(WrapReader(ctx, strings.NewReader("hello"))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(calls, ",") != "outer:5,inner:5" {
+		t.Errorf("unexpected throttle calls: %v", calls)
+	}
+
+	limited := errors.New("limited")
+	ctx = ContextWithThrottle(context.Background(), throttle("failing", limited))
+	if err := CountWritten(ctx, 1); !errors.Is(err, limited) {
+		t.Errorf("expected the throttle error, got %v", err)
+	}
+}
+
+// TestGatherOptions_Credentials tests looking up credentials from the auth provider
+func TestGatherOptions_Credentials(t *testing.T) {
+	ctx := context.Background()
+	if c, err := (GatherOptions{}).Credentials(ctx, "example.com"); c != nil || err != nil {
+		t.Errorf("expected no credentials, got %+v, %v", c, err)
+	}
+
+	o := GatherOptions{Auth: AuthProviderFunc(func(_ context.Context, host string) (*Credentials, error) {
+		if host == "broken.example.com" {
+			return nil, errors.New("unavailable")
+		}
+		return &Credentials{Username: "user", Password: host}, nil
+	})}
+	if c, err := o.Credentials(ctx, "example.com"); err != nil || c.Password != "example.com" {
+		t.Errorf("unexpected credentials: %+v, %v", c, err)
+	}
+	if _, err := o.Credentials(ctx, "broken.example.com"); err == nil {
+		t.Error("expected an error, but got nil")
+	}
+}
+
+// TestGatherOptions_Prune tests removing filtered files from a gathered directory
+func TestGatherOptions_Prune(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"main.rego", "README.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := (GatherOptions{Exclude: []string{"*.md"}}).Prune(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "README.md")); !os.IsNotExist(err) {
+		t.Errorf("expected README.md to be removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "main.rego")); err != nil {
+		t.Errorf("expected main.rego to be kept: %v", err)
+	}
+}
+
+// TestCountWrittenDir tests counting the size of a gathered directory as written
+func TestCountWrittenDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &recordingProgress{}
+	ctx := ContextWithOptions(context.Background(), GatherOptions{Progress: p})
+	if err := CountWrittenDir(ctx, dir); err != nil || p.bytes != 5 || p.items != 1 {
+		t.Errorf("expected 5 bytes and 1 item written, got %d and %d (%v)", p.bytes, p.items, err)
+	}
+
+	ctx = ContextWithOptions(context.Background(), GatherOptions{MaxSize: 4})
+	var sizeErr *MaxSizeError
+	if err := CountWrittenDir(ctx, dir); !errors.As(err, &sizeErr) {
+		t.Errorf("expected a MaxSizeError, got %v", err)
+	}
+}
+
+// TestLogger tests that a logger set on a gatherer takes precedence over the gather options
+func TestLogger(t *testing.T) {
+	own := slog.New(slog.NewTextHandler(io.Discard, nil))
+	fromOptions := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := ContextWithOptions(context.Background(), GatherOptions{Logger: fromOptions})
+
+	if Logger(ctx, own) != own {
+		t.Error("expected the logger of the gatherer")
+	}
+	if Logger(ctx, nil) != fromOptions {
+		t.Error("expected the logger of the gather options")
+	}
+	if Logger(context.Background(), nil) == nil {
+		t.Error("expected a logger")
+	}
+}
+
+// recordingProgress records the progress reported to it.
+type recordingProgress struct {
+	totalBytes int64
+	totalItems int
+	bytes      int64
+	items      int
+	starts     int
+	dones      []error
+}
+
+func (p *recordingProgress) Start(totalBytes int64, totalItems int) {
+	p.starts++
+	p.totalBytes, p.totalItems = totalBytes, totalItems
+}
+
+func (p *recordingProgress) Update(bytes int64, items int) {
+	p.bytes, p.items = bytes, items
+}
+
+func (p *recordingProgress) Done(err error) {
+	p.dones = append(p.dones, err)
+}
+
+// TestProgress tests reporting the start, the updates and the end of a gather
+func TestProgress(t *testing.T) {
+	p := &recordingProgress{}
+	ctx := ContextWithOptions(context.Background(), GatherOptions{Progress: p})
+
+	StartProgress(ctx, 10, 2)
+	StartProgress(ctx, -1, -1)
+	if err := CountWritten(ctx, 4); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	CountItems(ctx, 1)
+	failure := errors.New("failure")
+	FinishProgress(ctx, failure)
+	FinishProgress(ctx, nil)
+
+	if p.starts != 1 || p.totalBytes != 10 || p.totalItems != 2 {
+		t.Errorf("expected a single start, got %+v", p)
+	}
+	if p.bytes != 4 || p.items != 1 {
+		t.Errorf("unexpected progress: %+v", p)
+	}
+	if len(p.dones) != 1 || p.dones[0] != failure {
+		t.Errorf("expected a single end, got %v", p.dones)
+	}
+
+	// Reporting without options does nothing.
+	StartProgress(context.Background(), 1, 1)
+	CountItems(context.Background(), 1)
+	FinishProgress(context.Background(), nil)
+}
@@ -0,0 +1,156 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// PathFilter selects slash separated paths, e.g. archive members or the files of a gathered
+// directory, by glob patterns. In the patterns "*" matches within a single path element, "**"
+// matches across elements and "?" matches a single character, e.g. "policies/**.rego".
+type PathFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// NewPathFilter returns a PathFilter selecting the paths that match any of the include patterns,
+// or every path if there are none, and none of the exclude patterns.
+func NewPathFilter(include, exclude []string) (*PathFilter, error) {
+	f := &PathFilter{}
+	for _, p := range include {
+		re, err := compileGlob(p)
+		if err != nil {
+			return nil, err
+		}
+		f.include = append(f.include, re)
+	}
+	for _, p := range exclude {
+		re, err := compileGlob(p)
+		if err != nil {
+			return nil, err
+		}
+		f.exclude = append(f.exclude, re)
+	}
+	return f, nil
+}
+
+// Active reports whether any filtering is configured.
+func (f *PathFilter) Active() bool {
+	return len(f.include) > 0 || len(f.exclude) > 0
+}
+
+// Match reports whether the path name is selected.
+func (f *PathFilter) Match(name string) bool {
+	name = NormalizePath(name)
+	for _, re := range f.exclude {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, re := range f.include {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prune removes the files below dir whose path relative to dir is not selected. Version control
+// metadata in ".git" directories is kept.
+func (f *PathFilter) Prune(dir string) error {
+	if !f.Active() {
+		return nil
+	}
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if f.Match(filepath.ToSlash(rel)) {
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			return fmt.Errorf("failed to remove filtered file (%s): %w", p, err)
+		}
+		return nil
+	})
+}
+
+// NormalizePath returns the path name in a canonical slash separated form without leading "./"
+// or trailing "/".
+func NormalizePath(name string) string {
+	name = strings.TrimSuffix(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+	return strings.TrimPrefix(name, "/")
+}
+
+// compileGlob translates a glob pattern into an anchored regular expression.
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	p := NormalizePath(pattern)
+	if p == "" {
+		return nil, fmt.Errorf("invalid filter pattern: %q", pattern)
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '*':
+			if i+1 < len(p) && p[i+1] == '*' {
+				i++
+				// "**/" also matches zero directories, e.g. "a/**/b" matches "a/b".
+				if i+1 < len(p) && p[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
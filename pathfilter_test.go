@@ -0,0 +1,76 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestPathFilter_Match tests selecting paths by glob patterns
+func TestPathFilter_Match(t *testing.T) {
+	f, err := NewPathFilter([]string{"policy/**.rego"}, []string{"**_test.rego"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, expected := range map[string]bool{
+		"policy/lib/main.rego":      true,
+		"./policy/main.rego":        true,
+		"policy/lib/main_test.rego": false,
+		"README.md":                 false,
+	} {
+		if got := f.Match(name); got != expected {
+			t.Errorf("Match(%q) = %v, want %v", name, got, expected)
+		}
+	}
+
+	if _, err := NewPathFilter(nil, []string{""}); err == nil {
+		t.Error("expected an error for an empty pattern, but got nil")
+	}
+}
+
+// TestPathFilter_Prune tests removing the files that are not selected from a directory
+func TestPathFilter_Prune(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"policy/main.rego", "policy/main_test.rego", "README.md", ".git/config"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := NewPathFilter([]string{"policy/**"}, []string{"**_test.rego"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := f.Prune(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, kept := range map[string]bool{
+		"policy/main.rego":      true,
+		"policy/main_test.rego": false,
+		"README.md":             false,
+		".git/config":           true,
+	} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != kept {
+			t.Errorf("expected %s to be kept: %v, got: %v", name, kept, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Redacted replaces credentials removed from URLs by RedactURL.
+const Redacted = "REDACTED"
+
+// sensitiveParams lists, in lower case, the query parameters that carry credentials, e.g. the
+// tokens of authenticated downloads or the signatures of presigned S3, GCS and Azure URLs.
+var sensitiveParams = []string{
+	"access_token", "api_key", "apikey", "awsaccesskeyid", "client_secret", "id_token", "key",
+	"password", "refresh_token", "secret", "sig", "signature", "token", "x-amz-credential",
+	"x-amz-security-token", "x-amz-signature", "x-goog-credential", "x-goog-signature",
+}
+
+// urlPattern matches URLs embedded in text, e.g. in error messages.
+var urlPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// RedactURL returns rawURL with its credentials replaced by Redacted: the password of the
+// userinfo, the whole userinfo of HTTP(S) URLs, where tokens are often passed as the user name,
+// and the values of query parameters carrying tokens or signatures. A go-getter style
+// "<protocol>::" prefix is preserved. Strings that do not parse as URLs are returned unchanged.
+func RedactURL(rawURL string) string {
+	forced, rest, ok := strings.Cut(rawURL, "::")
+	if !ok || strings.Contains(forced, "/") {
+		forced, rest = "", rawURL
+	} else {
+		forced += "::"
+	}
+
+	u, err := url.Parse(rest)
+	if err != nil || u.Opaque != "" {
+		return rawURL
+	}
+
+	changed := false
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), Redacted)
+			changed = true
+		} else if u.Scheme == "http" || u.Scheme == "https" {
+			u.User = url.User(Redacted)
+			changed = true
+		}
+	}
+
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		for i, p := range params {
+			key, _, _ := strings.Cut(p, "=")
+			if k, err := url.QueryUnescape(key); err == nil && slices.Contains(sensitiveParams, strings.ToLower(k)) {
+				params[i] = key + "=" + Redacted
+				changed = true
+			}
+		}
+		u.RawQuery = strings.Join(params, "&")
+	}
+
+	if !changed {
+		return rawURL
+	}
+	return forced + u.String()
+}
+
+// Redact returns s with the credentials of every URL it contains redacted, see RedactURL.
+func Redact(s string) string {
+	return urlPattern.ReplaceAllStringFunc(s, RedactURL)
+}
+
+// redactedError is an error whose message has been redacted. It unwraps to the original error so
+// that callers can still inspect it with errors.Is and errors.As.
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// RedactError returns err with the credentials of the URLs in its message redacted, see Redact.
+// Gatherers apply it to the errors they return, as errors wrapped from HTTP clients, git and
+// registries often quote the URL they failed on.
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := Redact(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{err: err, msg: msg}
+}
@@ -0,0 +1,129 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package azblob provides functionality for saving data to Azure Blob Storage.
+//
+// This package contains the AzureBlobSaver type, which implements the Saver interface for
+// azblob://container/path destinations. Data is streamed to the container as a block blob:
+// blocks are staged as they are read and committed once the stream ends, so large content
+// does not need to be buffered in full before it is written. Requests are authenticated with
+// DefaultAzureCredential unless a credential is provided.
+//
+// Example usage:
+//
+//	s := &azblob.AzureBlobSaver{AccountName: "myaccount"}
+//	err := s.Save(context.Background(), data, "azblob://container/path/to/blob.json")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package azblob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+// AzureBlobSaver handles saving data to Azure Blob Storage containers.
+type AzureBlobSaver struct {
+	// AccountName is the name of the storage account, used to build the service URL
+	// https://<AccountName>.blob.core.windows.net when ServiceURL is empty.
+	AccountName string
+	// ServiceURL overrides the blob service URL, e.g. to use a sovereign cloud or the
+	// Azurite emulator.
+	ServiceURL string
+	// Credential provides the tokens used to authenticate requests. If nil,
+	// DefaultAzureCredential is used.
+	Credential azcore.TokenCredential
+	// BlockSize is the size of each staged block. If zero, the SDK default of 1 MiB is used.
+	BlockSize int64
+	// Concurrency is the number of blocks staged in parallel. If zero, blocks are staged
+	// one at a time.
+	Concurrency int
+	// ClientOptions configures the underlying client, e.g. its retry policy or transport.
+	ClientOptions *azblob.ClientOptions
+}
+
+// Save implements the Saver interface for Azure Blob Storage destinations.
+func (s *AzureBlobSaver) Save(ctx context.Context, data io.Reader, destination string) error {
+	container, blob, err := parseDestination(destination)
+	if err != nil {
+		return err
+	}
+
+	client, err := s.client()
+	if err != nil {
+		return err
+	}
+
+	_, err = client.UploadStream(ctx, container, blob, data, &azblob.UploadStreamOptions{
+		BlockSize:   s.BlockSize,
+		Concurrency: s.Concurrency,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload to %s: %w", destination, err)
+	}
+	return nil
+}
+
+// client creates a blob service client for the configured account.
+func (s *AzureBlobSaver) client() (*azblob.Client, error) {
+	serviceURL := s.ServiceURL
+	if serviceURL == "" {
+		if s.AccountName == "" {
+			return nil, errors.New("either an account name or a service URL is required")
+		}
+		serviceURL = fmt.Sprintf("https://%s.blob.core.windows.net/", s.AccountName)
+	}
+
+	cred := s.Credential
+	if cred == nil {
+		var err error
+		if cred, err = azidentity.NewDefaultAzureCredential(nil); err != nil {
+			return nil, fmt.Errorf("failed to create default credential: %w", err)
+		}
+	}
+
+	client, err := azblob.NewClient(serviceURL, cred, s.ClientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blob client: %w", err)
+	}
+	return client, nil
+}
+
+// parseDestination splits an azblob://container/path destination into its container and blob name.
+func parseDestination(destination string) (string, string, error) {
+	dst, err := url.Parse(destination)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse destination URI: %w", err)
+	}
+	if dst.Scheme != "azblob" {
+		return "", "", fmt.Errorf("unsupported destination scheme: %s", dst.Scheme)
+	}
+
+	blob := strings.TrimPrefix(dst.Path, "/")
+	if dst.Host == "" || blob == "" {
+		return "", "", fmt.Errorf("destination must be of the form azblob://container/path: %s", destination)
+	}
+	return dst.Host, blob, nil
+}
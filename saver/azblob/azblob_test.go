@@ -0,0 +1,195 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package azblob
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+// staticCredential is a TokenCredential that always returns the same token.
+type staticCredential struct{}
+
+func (staticCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// denyCredential is a TokenCredential that returns a token the fake service rejects.
+type denyCredential struct{}
+
+func (denyCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "wrong", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// fakeBlobService is a minimal blob service that supports uploading block blobs.
+type fakeBlobService struct {
+	mu     sync.Mutex
+	blobs  map[string][]byte
+	blocks map[string][]byte
+	staged int
+}
+
+func (f *fakeBlobService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if got := r.Header.Get("Authorization"); got != "Bearer token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	body, _ := io.ReadAll(r.Body)
+
+	switch r.URL.Query().Get("comp") {
+	case "block":
+		f.blocks[r.URL.Query().Get("blockid")] = body
+		f.staged++
+	case "blocklist":
+		var list struct {
+			Latest []string `xml:"Latest"`
+		}
+		if err := xml.Unmarshal(body, &list); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var data []byte
+		for _, id := range list.Latest {
+			data = append(data, f.blocks[id]...)
+		}
+		f.blobs[name] = data
+	case "":
+		f.blobs[name] = body
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func newTestSaver(t *testing.T) (*AzureBlobSaver, *fakeBlobService) {
+	f := &fakeBlobService{blobs: map[string][]byte{}, blocks: map[string][]byte{}}
+	srv := httptest.NewTLSServer(f)
+	t.Cleanup(srv.Close)
+
+	opts := &azblob.ClientOptions{}
+	opts.Transport = srv.Client()
+	opts.Retry.MaxRetries = -1
+	return &AzureBlobSaver{ServiceURL: srv.URL, Credential: staticCredential{}, ClientOptions: opts}, f
+}
+
+// TestAzureBlobSaver_Save tests saving a blob that fits into a single block.
+func TestAzureBlobSaver_Save(t *testing.T) {
+	s, f := newTestSaver(t)
+
+	if err := s.Save(context.Background(), strings.NewReader("test data"), "azblob://container/path/to/blob.txt"); err != nil {
+		t.Fatalf("failed to save blob: %v", err)
+	}
+
+	if got := string(f.blobs["container/path/to/blob.txt"]); got != "test data" {
+		t.Errorf("unexpected saved data: got %q, want %q", got, "test data")
+	}
+	if f.staged != 0 {
+		t.Errorf("expected no staged blocks, got %d", f.staged)
+	}
+}
+
+// TestAzureBlobSaver_SaveStaged tests that content larger than the block size is staged in blocks
+// and committed.
+func TestAzureBlobSaver_SaveStaged(t *testing.T) {
+	s, f := newTestSaver(t)
+	s.BlockSize = 1024 * 1024
+	s.Concurrency = 2
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), 160*1024) // 2.5 MiB, three blocks
+	if err := s.Save(context.Background(), bytes.NewReader(data), "azblob://container/large.bin"); err != nil {
+		t.Fatalf("failed to save blob: %v", err)
+	}
+
+	if f.staged != 3 {
+		t.Errorf("unexpected number of staged blocks: got %d, want 3", f.staged)
+	}
+	if !bytes.Equal(f.blobs["container/large.bin"], data) {
+		t.Errorf("saved data does not match: got %d bytes, want %d", len(f.blobs["container/large.bin"]), len(data))
+	}
+}
+
+// TestAzureBlobSaver_SaveErrors tests the errors returned for missing configuration and failed requests.
+func TestAzureBlobSaver_SaveErrors(t *testing.T) {
+	s := &AzureBlobSaver{Credential: staticCredential{}}
+	err := s.Save(context.Background(), strings.NewReader("data"), "azblob://container/blob")
+	if err == nil || err.Error() != "either an account name or a service URL is required" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	s, _ = newTestSaver(t)
+	s.Credential = denyCredential{}
+	err = s.Save(context.Background(), strings.NewReader("data"), "azblob://container/blob")
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to upload to azblob://container/blob") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestParseDestination tests splitting destinations into container and blob name.
+func TestParseDestination(t *testing.T) {
+	tests := []struct {
+		destination string
+		container   string
+		blob        string
+		err         string
+	}{
+		{destination: "azblob://container/blob", container: "container", blob: "blob"},
+		{destination: "azblob://container/path/to/blob.json", container: "container", blob: "path/to/blob.json"},
+		{destination: "azblob://container", err: "destination must be of the form azblob://container/path: azblob://container"},
+		{destination: "s3://bucket/key", err: "unsupported destination scheme: s3"},
+		{destination: ":", err: "failed to parse destination URI: parse \":\": missing protocol scheme"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.destination, func(t *testing.T) {
+			container, blob, err := parseDestination(tt.destination)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if container != tt.container || blob != tt.blob {
+				t.Errorf("unexpected result: got %s/%s, want %s/%s", container, blob, tt.container, tt.blob)
+			}
+		})
+	}
+}
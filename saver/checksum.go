@@ -0,0 +1,89 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package saver
+
+import (
+	"context"
+	"crypto"
+	_ "crypto/sha256" // register crypto.SHA256
+	_ "crypto/sha512" // register crypto.SHA512
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ChecksumSaver wraps a Saver and computes the digest of the data while it is being saved, so that
+// the saved content does not have to be read back to compute it. It is safe for concurrent use if
+// the wrapped Saver is.
+type ChecksumSaver struct {
+	// Saver saves the data.
+	Saver Saver
+	// Hash is the hash function used to compute the digest, e.g. crypto.SHA256 or
+	// crypto.SHA512. Defaults to crypto.SHA256.
+	Hash crypto.Hash
+
+	mu      sync.Mutex
+	digests map[string]string
+}
+
+// NewChecksumSaver returns a ChecksumSaver that saves data with s and computes its digest with hash.
+func NewChecksumSaver(s Saver, hash crypto.Hash) *ChecksumSaver {
+	return &ChecksumSaver{Saver: s, Hash: hash}
+}
+
+// Save implements the Saver interface. The digest of the saved data can be retrieved with Digest.
+func (c *ChecksumSaver) Save(ctx context.Context, data io.Reader, destination string) error {
+	_, err := c.SaveWithDigest(ctx, data, destination)
+	return err
+}
+
+// SaveWithDigest saves data to destination and returns the hex encoded digest of the saved data.
+func (c *ChecksumSaver) SaveWithDigest(ctx context.Context, data io.Reader, destination string) (string, error) {
+	hash := c.Hash
+	if hash == 0 {
+		hash = crypto.SHA256
+	}
+	if !hash.Available() {
+		return "", fmt.Errorf("unsupported hash function: %s", hash)
+	}
+
+	h := hash.New()
+	if err := c.Saver.Save(ctx, io.TeeReader(data, h), destination); err != nil {
+		return "", err
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.digests == nil {
+		c.digests = map[string]string{}
+	}
+	c.digests[destination] = digest
+
+	return digest, nil
+}
+
+// Digest returns the hex encoded digest of the data last saved to destination, and whether any
+// data was saved to it.
+func (c *ChecksumSaver) Digest(destination string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	digest, ok := c.digests[destination]
+	return digest, ok
+}
@@ -0,0 +1,104 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package saver
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// recordingSaver records the data saved to each destination.
+type recordingSaver struct {
+	saved map[string]string
+	err   error
+}
+
+func (r *recordingSaver) Save(ctx context.Context, data io.Reader, destination string) error {
+	if r.err != nil {
+		return r.err
+	}
+	b, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	if r.saved == nil {
+		r.saved = map[string]string{}
+	}
+	r.saved[destination] = string(b)
+	return nil
+}
+
+// TestChecksumSaver tests that the digest of the saved data is computed with the configured hash.
+func TestChecksumSaver(t *testing.T) {
+	tests := []struct {
+		name string
+		hash crypto.Hash
+		want string
+	}{
+		{name: "default", want: "916f0027a575074ce72a331777c3478d6513f786a591bd892da1a577bf2335f9"},
+		{name: "sha256", hash: crypto.SHA256, want: "916f0027a575074ce72a331777c3478d6513f786a591bd892da1a577bf2335f9"},
+		{name: "sha512", hash: crypto.SHA512, want: "0e1e21ecf105ec853d24d728867ad70613c21663a4693074b2a3619c1bd39d66b588c33723bb466c72424e80e3ca63c249078ab347bab9428500e7ee43059d0d"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recordingSaver{}
+			c := NewChecksumSaver(r, tt.hash)
+
+			digest, err := c.SaveWithDigest(context.Background(), strings.NewReader("test data"), "a.txt")
+			if err != nil {
+				t.Fatalf("failed to save: %v", err)
+			}
+			if digest != tt.want {
+				t.Errorf("unexpected digest: got %s, want %s", digest, tt.want)
+			}
+			if r.saved["a.txt"] != "test data" {
+				t.Errorf("unexpected saved data: %q", r.saved["a.txt"])
+			}
+
+			if err := c.Save(context.Background(), strings.NewReader("test data"), "b.txt"); err != nil {
+				t.Fatalf("failed to save: %v", err)
+			}
+			if got, ok := c.Digest("b.txt"); !ok || got != tt.want {
+				t.Errorf("unexpected digest for b.txt: %s, %v", got, ok)
+			}
+			if _, ok := c.Digest("missing"); ok {
+				t.Error("expected no digest for a missing destination")
+			}
+		})
+	}
+}
+
+// TestChecksumSaver_Errors tests that errors of the wrapped saver and unavailable hashes are reported.
+func TestChecksumSaver_Errors(t *testing.T) {
+	c := NewChecksumSaver(&recordingSaver{err: errors.New("save error")}, crypto.SHA256)
+	if _, err := c.SaveWithDigest(context.Background(), strings.NewReader("data"), "a.txt"); err == nil || err.Error() != "save error" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, ok := c.Digest("a.txt"); ok {
+		t.Error("expected no digest after a failed save")
+	}
+
+	c = NewChecksumSaver(&recordingSaver{}, crypto.MD4)
+	if _, err := c.SaveWithDigest(context.Background(), strings.NewReader("data"), "a.txt"); err == nil || err.Error() != "unsupported hash function: MD4" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package saver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// Opener is implemented by savers that can read back the data saved to a destination.
+type Opener interface {
+	// Open returns the data saved to destination. If nothing was saved to it, the returned error
+	// wraps fs.ErrNotExist.
+	Open(ctx context.Context, destination string) (io.ReadCloser, error)
+}
+
+// DedupSaver wraps a Saver and skips saving data the destination already holds, e.g. as a building
+// block for incremental gathers. The wrapped Saver must implement Opener so that the data it holds
+// can be compared with the data to save. The data to save is buffered in a temporary file until the
+// comparison completes, in the TempDir of the gather options carried by the context, see
+// gogather.CreateTemp.
+type DedupSaver struct {
+	// Saver saves the data.
+	Saver Saver
+}
+
+// NewDedupSaver returns a DedupSaver that saves changed data with s.
+func NewDedupSaver(s Saver) *DedupSaver {
+	return &DedupSaver{Saver: s}
+}
+
+// Save implements the Saver interface.
+func (d *DedupSaver) Save(ctx context.Context, data io.Reader, destination string) error {
+	_, err := d.SaveWithResult(ctx, data, destination)
+	return err
+}
+
+// SaveWithResult saves data to destination unless the destination already holds identical data, in
+// which case the returned result reports it as unchanged.
+func (d *DedupSaver) SaveWithResult(ctx context.Context, data io.Reader, destination string) (*Result, error) {
+	opener, ok := d.Saver.(Opener)
+	if !ok {
+		return nil, fmt.Errorf("saver %T cannot read back saved data", d.Saver)
+	}
+
+	start := time.Now()
+	path := destination
+	if r, ok := d.Saver.(PathResolver); ok {
+		resolved, err := r.ResolvePath(destination)
+		if err != nil {
+			return nil, err
+		}
+		path = resolved
+	}
+
+	tmp, err := gogather.CreateTemp(ctx, "go-gather-dedup-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data: %w", err)
+	}
+
+	existing, err := existingDigest(ctx, opener, destination)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && bytes.Equal(existing, h.Sum(nil)) {
+		return &Result{Path: path, Size: size, Duration: time.Since(start), Unchanged: true}, nil
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind data: %w", err)
+	}
+	if err := d.Saver.Save(ctx, tmp, destination); err != nil {
+		return nil, err
+	}
+
+	return &Result{Path: path, Size: size, Duration: time.Since(start)}, nil
+}
+
+// existingDigest returns the SHA256 digest of the data destination holds, or nil if it holds none.
+func existingDigest(ctx context.Context, opener Opener, destination string) ([]byte, error) {
+	r, err := opener.Open(ctx, destination)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open destination: %w", err)
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, fmt.Errorf("failed to read destination: %w", err)
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,86 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package saver
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/enterprise-contract/go-gather/saver/file"
+)
+
+// countingFileSaver counts the saves passed to the FileSaver it embeds.
+type countingFileSaver struct {
+	*file.FileSaver
+	saves int
+}
+
+func (c *countingFileSaver) Save(ctx context.Context, data io.Reader, destination string) error {
+	c.saves++
+	return c.FileSaver.Save(ctx, data, destination)
+}
+
+// TestDedupSaver tests that identical data is not saved again.
+func TestDedupSaver(t *testing.T) {
+	destination := filepath.Join(t.TempDir(), "test.txt")
+	c := &countingFileSaver{FileSaver: &file.FileSaver{}}
+	d := NewDedupSaver(c)
+
+	for i, tt := range []struct {
+		content   string
+		unchanged bool
+	}{
+		{content: "test data"},
+		{content: "test data", unchanged: true},
+		{content: "other data"},
+	} {
+		result, err := SaveWithResult(context.Background(), d, strings.NewReader(tt.content), destination)
+		if err != nil {
+			t.Fatalf("%d: failed to save: %v", i, err)
+		}
+		if result.Unchanged != tt.unchanged {
+			t.Errorf("%d: unexpected unchanged: got %t, want %t", i, result.Unchanged, tt.unchanged)
+		}
+		if result.Path != destination || result.Size != int64(len(tt.content)) {
+			t.Errorf("%d: unexpected result: %+v", i, result)
+		}
+
+		savedData, err := os.ReadFile(destination)
+		if err != nil {
+			t.Fatalf("%d: failed to read saved file: %v", i, err)
+		}
+		if string(savedData) != tt.content {
+			t.Errorf("%d: unexpected saved data: got %s, want %s", i, savedData, tt.content)
+		}
+	}
+
+	if c.saves != 2 {
+		t.Errorf("unexpected number of saves: got %d, want 2", c.saves)
+	}
+}
+
+// TestDedupSaver_NoOpener tests that savers that cannot read back saved data are rejected.
+func TestDedupSaver_NoOpener(t *testing.T) {
+	err := NewDedupSaver(&recordingSaver{}).Save(context.Background(), strings.NewReader("test data"), "a.txt")
+	if err == nil || err.Error() != "saver *saver.recordingSaver cannot read back saved data" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package saver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// EncryptSaver wraps a Saver and encrypts the data with age (https://age-encryption.org) while it
+// is being saved, e.g. to gather sensitive configuration into shared storage. The saved data can
+// only be decrypted with an identity matching one of the recipients, e.g. with the age command line
+// tool.
+type EncryptSaver struct {
+	// Saver saves the encrypted data.
+	Saver Saver
+	// Recipients are the recipients the data is encrypted to, e.g. as returned by
+	// age.ParseX25519Recipient or age.NewScryptRecipient for a passphrase.
+	Recipients []age.Recipient
+	// Armor saves the encrypted data PEM encoded rather than in the binary format.
+	Armor bool
+}
+
+// NewEncryptSaver returns an EncryptSaver that saves the data encrypted to recipients with s.
+func NewEncryptSaver(s Saver, recipients ...age.Recipient) *EncryptSaver {
+	return &EncryptSaver{Saver: s, Recipients: recipients}
+}
+
+// Save implements the Saver interface.
+func (e *EncryptSaver) Save(ctx context.Context, data io.Reader, destination string) error {
+	if len(e.Recipients) == 0 {
+		return errors.New("no encryption recipients configured")
+	}
+
+	return saveTransformed(ctx, e.Saver, data, destination, func(w io.Writer) (io.WriteCloser, error) {
+		if !e.Armor {
+			return e.encrypt(w)
+		}
+		a := armor.NewWriter(w)
+		ew, err := e.encrypt(a)
+		if err != nil {
+			return nil, err
+		}
+		return &armoredWriter{WriteCloser: ew, armor: a}, nil
+	})
+}
+
+func (e *EncryptSaver) encrypt(w io.Writer) (io.WriteCloser, error) {
+	ew, err := age.Encrypt(w, e.Recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt data: %w", err)
+	}
+	return ew, nil
+}
+
+// armoredWriter closes the PEM encoder after the encrypting writer it wraps.
+type armoredWriter struct {
+	io.WriteCloser
+	armor io.Closer
+}
+
+func (a *armoredWriter) Close() error {
+	if err := a.WriteCloser.Close(); err != nil {
+		return err
+	}
+	return a.armor.Close()
+}
@@ -0,0 +1,81 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package saver
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// TestEncryptSaver tests that the saved data can be decrypted with the recipient's identity.
+func TestEncryptSaver(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+
+	for _, armored := range []bool{false, true} {
+		r := &recordingSaver{}
+		e := NewEncryptSaver(r, identity.Recipient())
+		e.Armor = armored
+
+		if err := e.Save(context.Background(), strings.NewReader("test data"), "secret.age"); err != nil {
+			t.Fatalf("failed to save: %v", err)
+		}
+
+		saved := r.saved["secret.age"]
+		if strings.Contains(saved, "test data") {
+			t.Fatal("saved data is not encrypted")
+		}
+		if got := strings.HasPrefix(saved, armor.Header); got != armored {
+			t.Errorf("unexpected armor: got %t, want %t", got, armored)
+		}
+
+		var src io.Reader = strings.NewReader(saved)
+		if armored {
+			src = armor.NewReader(src)
+		}
+		dr, err := age.Decrypt(src, identity)
+		if err != nil {
+			t.Fatalf("failed to decrypt: %v", err)
+		}
+		b, err := io.ReadAll(dr)
+		if err != nil {
+			t.Fatalf("failed to read decrypted data: %v", err)
+		}
+		if string(b) != "test data" {
+			t.Errorf("unexpected decrypted data: %q", b)
+		}
+	}
+}
+
+// TestEncryptSaver_NoRecipients tests that saving without recipients fails.
+func TestEncryptSaver_NoRecipients(t *testing.T) {
+	r := &recordingSaver{}
+	err := NewEncryptSaver(r).Save(context.Background(), strings.NewReader("test data"), "secret.age")
+	if err == nil || err.Error() != "no encryption recipients configured" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(r.saved) != 0 {
+		t.Error("expected nothing to be saved")
+	}
+}
@@ -33,13 +33,43 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+
+	gogather "github.com/enterprise-contract/go-gather"
 )
 
-// FileSaver handles saving data to local filesystem paths.
-type FileSaver struct{}
+// FileSaver handles saving data to local filesystem paths. The data is written to a temporary
+// file next to the destination, which is renamed into place once it is complete, so that the
+// destination never holds partially written data. A destination that is a symlink is resolved
+// first, so that the file it points to is replaced and the symlink is kept.
+type FileSaver struct {
+	// Sync flushes the file and its directory to stable storage before Save returns, so that
+	// the saved data survives a system crash.
+	Sync bool
+	// FileMode holds the permission bits of saved files, e.g. 0600. Defaults to the permissions
+	// of the file being replaced, if any, and to 0666 otherwise. The permissions are reduced by
+	// the process umask unless IgnoreUmask is set, except for those kept from a replaced file.
+	FileMode os.FileMode
+	// DirMode holds the permission bits of the destination directories created by Save, e.g.
+	// 0700. Defaults to 0755. Directories that already exist are left unchanged.
+	DirMode os.FileMode
+	// IgnoreUmask applies FileMode and DirMode exactly as configured, instead of reducing them
+	// by the process umask.
+	IgnoreUmask bool
+}
+
+const (
+	// DefaultFileMode holds the permission bits of saved files if FileMode is not set.
+	DefaultFileMode os.FileMode = 0666
+	// DefaultDirMode holds the permission bits of created directories if DirMode is not set.
+	DefaultDirMode os.FileMode = 0755
+)
 
 // Save implements the Saver interface for file destinations.
 func (fs *FileSaver) Save(ctx context.Context, data io.Reader, destination string) error {
@@ -48,23 +78,250 @@ func (fs *FileSaver) Save(ctx context.Context, data io.Reader, destination strin
 	if err != nil {
 		return fmt.Errorf("failed to parse destination URI: %w", err)
 	}
+	// Replacing a symlink would turn it into a regular file, so the file it points to is
+	// replaced instead.
+	path, err := resolveSymlinks(dst.Path)
+	if err != nil {
+		return err
+	}
 
 	// Ensure the destination directory exists.
-	if err := os.MkdirAll(filepath.Dir(dst.Path), 0755); err != nil {
+	dir := filepath.Dir(path)
+	if err := mkdirAll(dir, fs.dirMode(), fs.IgnoreUmask); err != nil {
 		return fmt.Errorf("failed to create destination directory: %w", err)
 	}
 
-	// Create the destination file.
-	f, err := os.Create(dst.Path)
+	// Fail before consuming the data if the destination cannot be replaced by a file, and keep
+	// the permissions of the file it replaces unless FileMode is set.
+	mode, exact := fs.fileMode(), fs.IgnoreUmask
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return &os.PathError{Op: "open", Path: dst.Path, Err: syscall.EISDIR}
+		}
+		if fs.FileMode == 0 {
+			mode, exact = info.Mode().Perm(), true
+		}
+	}
+
+	// Create a temporary file next to the destination.
+	f, err := createTemp(path, mode)
 	if err != nil {
 		return err
 	}
+	defer os.Remove(f.Name())
 	defer f.Close()
 
+	if exact {
+		if err := os.Chmod(f.Name(), mode); err != nil {
+			return fmt.Errorf("failed to change file mode: %w", err)
+		}
+	}
+
 	// Write the data to the file.
-	_, err = io.Copy(f, data)
+	_, err = gogather.Copy(f, data)
+	if err != nil {
+		return fmt.Errorf("failed to write data to file: %w", err)
+	}
+
+	if fs.Sync {
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("failed to sync file: %w", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+
+	// Move the complete file into place.
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename temporary file: %w", err)
+	}
+
+	if fs.Sync {
+		if err := syncDir(dir); err != nil {
+			return fmt.Errorf("failed to sync destination directory: %w", err)
+		}
+	}
+	return nil
+}
+
+func (fs *FileSaver) fileMode() os.FileMode {
+	if fs.FileMode == 0 {
+		return DefaultFileMode
+	}
+	return fs.FileMode.Perm()
+}
+
+func (fs *FileSaver) dirMode() os.FileMode {
+	if fs.DirMode == 0 {
+		return DefaultDirMode
+	}
+	return fs.DirMode.Perm()
+}
+
+// mkdirAll creates the directory dir along with any missing parents using perm. If exact is set,
+// the permissions of the created directories are set to perm regardless of the process umask.
+func mkdirAll(dir string, perm os.FileMode, exact bool) error {
+	if !exact {
+		return os.MkdirAll(dir, perm)
+	}
+
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); !os.IsNotExist(err) {
+			break
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+
+	if err := os.MkdirAll(dir, perm); err != nil {
+		return err
+	}
+	for _, d := range missing {
+		if err := os.Chmod(d, perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolvePath returns the absolute path of the file data is saved to for destination.
+func (fs *FileSaver) ResolvePath(destination string) (string, error) {
+	dst, err := url.Parse(destination)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse destination URI: %w", err)
+	}
+	return filepath.Abs(dst.Path)
+}
+
+// Open opens the file saved to destination for reading.
+func (fs *FileSaver) Open(ctx context.Context, destination string) (io.ReadCloser, error) {
+	dst, err := url.Parse(destination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse destination URI: %w", err)
+	}
+	return os.Open(dst.Path)
+}
+
+// OffsetMismatchError is returned by Append when the size of the destination file does not match
+// the offset the data is appended at.
+type OffsetMismatchError struct {
+	Path   string
+	Offset int64
+	Size   int64
+}
+
+func (e *OffsetMismatchError) Error() string {
+	return fmt.Sprintf("cannot append to %s at offset %d: file size is %d", e.Path, e.Offset, e.Size)
+}
+
+// Append writes data to the end of the partially written destination file, e.g. to resume an
+// interrupted download. The size of the file must match offset, otherwise an OffsetMismatchError is
+// returned. Unlike Save, the data is written to the destination in place. An offset of 0 saves the
+// data as Save does, replacing any existing file.
+func (fs *FileSaver) Append(ctx context.Context, data io.Reader, destination string, offset int64) error {
+	if offset < 0 {
+		return fmt.Errorf("invalid offset: %d", offset)
+	}
+	if offset == 0 {
+		return fs.Save(ctx, data, destination)
+	}
+
+	dst, err := url.Parse(destination)
+	if err != nil {
+		return fmt.Errorf("failed to parse destination URI: %w", err)
+	}
+
+	f, err := os.OpenFile(dst.Path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
 	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("cannot append to %s: not a regular file", dst.Path)
+	}
+	if info.Size() != offset {
+		return &OffsetMismatchError{Path: dst.Path, Offset: offset, Size: info.Size()}
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek file: %w", err)
+	}
+
+	if _, err := gogather.Copy(f, data); err != nil {
 		return fmt.Errorf("failed to write data to file: %w", err)
 	}
+
+	if fs.Sync {
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("failed to sync file: %w", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
 	return nil
 }
+
+// maxSymlinks bounds the number of symlinks resolveSymlinks follows, as Linux does.
+const maxSymlinks = 40
+
+// resolveSymlinks returns the path of the file the symlink at path points to, following chains of
+// symlinks, or path itself if it is not a symlink. Unlike filepath.EvalSymlinks, the file the last
+// symlink points to need not exist, as writing through a dangling symlink creates it.
+func resolveSymlinks(path string) (string, error) {
+	for i := 0; i < maxSymlinks; i++ {
+		info, err := os.Lstat(path)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			return path, nil
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve destination: %w", err)
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		path = target
+	}
+	return "", fmt.Errorf("failed to resolve destination: %w", &os.PathError{Op: "readlink", Path: path, Err: syscall.ELOOP})
+}
+
+// createTemp creates a new temporary file with the permissions perm in the directory of path.
+// Unlike os.CreateTemp, the permissions are reduced by the process umask, as os.Create does.
+// Errors refer to path rather than the name of the temporary file.
+func createTemp(path string, perm os.FileMode) (*os.File, error) {
+	dir, base := filepath.Split(path)
+	for i := 0; ; i++ {
+		name := filepath.Join(dir, "."+base+".tmp"+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) && i < 10000 {
+			continue
+		}
+		if pe, ok := err.(*os.PathError); ok {
+			pe.Path = path
+		}
+		return f, err
+	}
+}
+
+// syncDir flushes the directory entries of dir to stable storage. Directories cannot be synced on
+// Windows, where this does nothing.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -19,8 +19,12 @@ package file
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -146,3 +150,127 @@ func TestFileSaver_CopyError(t *testing.T) {
 		os.RemoveAll(destination)
 	})
 }
+
+// TestFileSaver_Atomic tests that a failed save leaves an existing destination untouched and no temporary files behind.
+func TestFileSaver_Atomic(t *testing.T) {
+	dir := t.TempDir()
+	destination := filepath.Join(dir, "test.txt")
+	if err := os.WriteFile(destination, []byte("original"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	fs := &FileSaver{}
+	data := io.MultiReader(strings.NewReader("partial"), &mockErrorReader{})
+	if err := fs.Save(context.Background(), data, destination); err == nil {
+		t.Fatal("expected an error, but got nil")
+	}
+
+	savedData, err := os.ReadFile(destination)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(savedData) != "original" {
+		t.Errorf("unexpected file content: got %s, want original", savedData)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the destination in the directory, got %d entries", len(entries))
+	}
+}
+
+// TestFileSaver_Sync tests saving with Sync enabled, replacing an existing file.
+func TestFileSaver_Sync(t *testing.T) {
+	destination := filepath.Join(t.TempDir(), "sub", "test.txt")
+
+	fs := &FileSaver{Sync: true}
+	for _, content := range []string{"first", "second"} {
+		if err := fs.Save(context.Background(), strings.NewReader(content), destination); err != nil {
+			t.Fatalf("failed to save file: %v", err)
+		}
+
+		savedData, err := os.ReadFile(destination)
+		if err != nil {
+			t.Fatalf("failed to read saved file: %v", err)
+		}
+		if string(savedData) != content {
+			t.Errorf("unexpected saved data: got %s, want %s", savedData, content)
+		}
+	}
+}
+
+// TestFileSaver_DirectoryDestination tests that saving to an existing directory fails without reading the data.
+func TestFileSaver_DirectoryDestination(t *testing.T) {
+	dir := t.TempDir()
+
+	fs := &FileSaver{}
+	err := fs.Save(context.Background(), &mockErrorReader{}, dir)
+	if err == nil {
+		t.Fatal("expected an error, but got nil")
+	}
+
+	expectedErrorMessage := fmt.Sprintf("open %s: is a directory", dir)
+	if err.Error() != expectedErrorMessage {
+		t.Errorf("unexpected error message: got %s, want %s", err.Error(), expectedErrorMessage)
+	}
+}
+
+// TestFileSaver_Append tests the Append method of the FileSaver type.
+func TestFileSaver_Append(t *testing.T) {
+	destination := filepath.Join(t.TempDir(), "test.txt")
+
+	fs := &FileSaver{}
+	if err := fs.Append(context.Background(), strings.NewReader("hello "), destination, 0); err != nil {
+		t.Fatalf("failed to save file: %v", err)
+	}
+	if err := fs.Append(context.Background(), strings.NewReader("world"), destination, 6); err != nil {
+		t.Fatalf("failed to append to file: %v", err)
+	}
+
+	savedData, err := os.ReadFile(destination)
+	if err != nil {
+		t.Fatalf("failed to read saved file: %v", err)
+	}
+	if string(savedData) != "hello world" {
+		t.Errorf("unexpected saved data: got %s, want hello world", savedData)
+	}
+
+	// Appending at an offset other than the file size fails without changing the file.
+	var offsetErr *OffsetMismatchError
+	err = fs.Append(context.Background(), strings.NewReader("!"), destination, 6)
+	if !errors.As(err, &offsetErr) || offsetErr.Offset != 6 || offsetErr.Size != 11 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedErrorMessage := fmt.Sprintf("cannot append to %s at offset 6: file size is 11", destination)
+	if err.Error() != expectedErrorMessage {
+		t.Errorf("unexpected error message: got %s, want %s", err.Error(), expectedErrorMessage)
+	}
+
+	// An offset of 0 replaces the file.
+	if err := fs.Append(context.Background(), strings.NewReader("again"), destination, 0); err != nil {
+		t.Fatalf("failed to save file: %v", err)
+	}
+	savedData, err = os.ReadFile(destination)
+	if err != nil {
+		t.Fatalf("failed to read saved file: %v", err)
+	}
+	if string(savedData) != "again" {
+		t.Errorf("unexpected saved data: got %s, want again", savedData)
+	}
+}
+
+// TestFileSaver_AppendMissingFile tests the Append method of the FileSaver type when the destination does not exist.
+func TestFileSaver_AppendMissingFile(t *testing.T) {
+	destination := filepath.Join(t.TempDir(), "test.txt")
+
+	fs := &FileSaver{}
+	if err := fs.Append(context.Background(), strings.NewReader("world"), destination, 6); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := fs.Append(context.Background(), strings.NewReader("world"), destination, -1); err == nil {
+		t.Error("expected an error for a negative offset")
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build unix
+
+package file
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// TestFileSaver_Modes tests the Save method of the FileSaver type with configured file and directory modes.
+func TestFileSaver_Modes(t *testing.T) {
+	old := syscall.Umask(0077)
+	defer syscall.Umask(old)
+
+	tests := []struct {
+		name     string
+		fs       *FileSaver
+		wantFile os.FileMode
+		wantDir  os.FileMode
+	}{
+		{name: "defaults", fs: &FileSaver{}, wantFile: 0600, wantDir: 0700},
+		{name: "restricted", fs: &FileSaver{FileMode: 0640, DirMode: 0750}, wantFile: 0600, wantDir: 0700},
+		{name: "ignore umask", fs: &FileSaver{FileMode: 0640, DirMode: 0750, IgnoreUmask: true}, wantFile: 0640, wantDir: 0750},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "a", "b")
+			destination := filepath.Join(dir, "test.txt")
+
+			if err := tt.fs.Save(context.Background(), strings.NewReader("test data"), destination); err != nil {
+				t.Fatalf("failed to save file: %v", err)
+			}
+
+			info, err := os.Stat(destination)
+			if err != nil {
+				t.Fatalf("failed to stat saved file: %v", err)
+			}
+			if info.Mode().Perm() != tt.wantFile {
+				t.Errorf("unexpected file mode: got %o, want %o", info.Mode().Perm(), tt.wantFile)
+			}
+
+			for _, d := range []string{dir, filepath.Dir(dir)} {
+				info, err := os.Stat(d)
+				if err != nil {
+					t.Fatalf("failed to stat directory: %v", err)
+				}
+				if info.Mode().Perm() != tt.wantDir {
+					t.Errorf("unexpected directory mode of %s: got %o, want %o", d, info.Mode().Perm(), tt.wantDir)
+				}
+			}
+		})
+	}
+}
+
+// TestFileSaver_ExistingMode tests that saving over a file keeps its permissions unless FileMode is set.
+func TestFileSaver_ExistingMode(t *testing.T) {
+	old := syscall.Umask(0022)
+	defer syscall.Umask(old)
+
+	destination := filepath.Join(t.TempDir(), "test.txt")
+	if err := os.WriteFile(destination, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// The permissions are kept exactly, whatever the umask.
+	if err := os.Chmod(destination, 0604); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (&FileSaver{}).Save(context.Background(), strings.NewReader("new"), destination); err != nil {
+		t.Fatalf("failed to save file: %v", err)
+	}
+	if info, err := os.Stat(destination); err != nil || info.Mode().Perm() != 0604 {
+		t.Errorf("expected the file mode to be kept: %v, %v", info, err)
+	}
+
+	if err := (&FileSaver{FileMode: 0640}).Save(context.Background(), strings.NewReader("new"), destination); err != nil {
+		t.Fatalf("failed to save file: %v", err)
+	}
+	if info, err := os.Stat(destination); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("expected the configured file mode: %v, %v", info, err)
+	}
+}
+
+// TestFileSaver_Symlink tests that saving to a symlink replaces the file it points to and keeps the symlink.
+func TestFileSaver_Symlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.txt")
+	if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Symlink("target.txt", link); err != nil {
+		t.Fatal(err)
+	}
+	chain := filepath.Join(t.TempDir(), "chain.txt")
+	if err := os.Symlink(link, chain); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (&FileSaver{}).Save(context.Background(), strings.NewReader("new"), chain); err != nil {
+		t.Fatalf("failed to save file: %v", err)
+	}
+	for _, l := range []string{link, chain} {
+		if info, err := os.Lstat(l); err != nil || info.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("expected %s to be kept as a symlink: %v, %v", l, info, err)
+		}
+	}
+	if data, err := os.ReadFile(target); err != nil || string(data) != "new" {
+		t.Errorf("unexpected content of the target: %q, %v", data, err)
+	}
+
+	// Saving to a dangling symlink creates the file it points to.
+	dangling := filepath.Join(dir, "dangling.txt")
+	if err := os.Symlink("missing.txt", dangling); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&FileSaver{}).Save(context.Background(), strings.NewReader("new"), dangling); err != nil {
+		t.Fatalf("failed to save file: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "missing.txt")); err != nil || string(data) != "new" {
+		t.Errorf("unexpected content of the created target: %q, %v", data, err)
+	}
+
+	loop := filepath.Join(dir, "loop.txt")
+	if err := os.Symlink("loop.txt", loop); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&FileSaver{}).Save(context.Background(), strings.NewReader("new"), loop); !errors.Is(err, syscall.ELOOP) {
+		t.Errorf("expected a symlink loop to fail, got %v", err)
+	}
+}
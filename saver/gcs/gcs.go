@@ -0,0 +1,287 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package gcs provides functionality for saving data to Google Cloud Storage.
+//
+// This package contains the GCSSaver type, which implements the Saver interface for
+// gs://bucket/object destinations. Data is streamed to the bucket in chunks using a
+// resumable upload session, so large content does not need to be buffered in full
+// before it is written. Requests are authenticated with Application Default Credentials
+// unless a token source is provided.
+//
+// Example usage:
+//
+//	s := &gcs.GCSSaver{}
+//	err := s.Save(context.Background(), data, "gs://bucket/path/to/object.json")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package gcs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// DefaultEndpoint is the Google Cloud Storage endpoint used when none is configured.
+	DefaultEndpoint = "https://storage.googleapis.com"
+	// DefaultChunkSize is the size of the chunks uploaded in a resumable session when none is
+	// configured.
+	DefaultChunkSize = 16 * chunkAlignment
+	// chunkAlignment is the granularity required by Cloud Storage for every chunk but the last.
+	chunkAlignment = 256 * 1024
+	// readWriteScope is the OAuth2 scope requested for Application Default Credentials.
+	readWriteScope = "https://www.googleapis.com/auth/devstorage.read_write"
+	// statusResumeIncomplete is returned by Cloud Storage for each chunk accepted before the last.
+	statusResumeIncomplete = 308
+)
+
+// GCSSaver handles saving data to Google Cloud Storage buckets.
+type GCSSaver struct {
+	// TokenSource provides the OAuth2 tokens used to authenticate requests. If nil,
+	// Application Default Credentials are used.
+	TokenSource oauth2.TokenSource
+	// Endpoint overrides the Cloud Storage endpoint, e.g. to use an emulator. Defaults to
+	// DefaultEndpoint.
+	Endpoint string
+	// ChunkSize is the size of each chunk of the resumable upload. It must be a multiple of
+	// 256 KiB. Defaults to DefaultChunkSize.
+	ChunkSize int
+}
+
+// Save implements the Saver interface for Google Cloud Storage destinations.
+func (s *GCSSaver) Save(ctx context.Context, data io.Reader, destination string) error {
+	bucket, object, err := parseDestination(destination)
+	if err != nil {
+		return err
+	}
+
+	chunkSize := s.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize < 0 || chunkSize%chunkAlignment != 0 {
+		return fmt.Errorf("chunk size must be a multiple of %d: %d", chunkAlignment, chunkSize)
+	}
+
+	client, err := s.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	session, err := s.startSession(ctx, client, bucket, object)
+	if err != nil {
+		return fmt.Errorf("failed to start upload to %s: %w", destination, err)
+	}
+
+	if err := uploadChunks(ctx, client, session, data, chunkSize); err != nil {
+		return fmt.Errorf("failed to upload to %s: %w", destination, err)
+	}
+	return nil
+}
+
+// client returns an HTTP client that authenticates requests with the configured token source, or
+// with Application Default Credentials.
+func (s *GCSSaver) client(ctx context.Context) (*http.Client, error) {
+	ts := s.TokenSource
+	if ts == nil {
+		var err error
+		if ts, err = google.DefaultTokenSource(ctx, readWriteScope); err != nil {
+			return nil, fmt.Errorf("failed to find default credentials: %w", err)
+		}
+	}
+	return oauth2.NewClient(ctx, ts), nil
+}
+
+// startSession initiates a resumable upload of object to bucket and returns the session URI.
+func (s *GCSSaver) startSession(ctx context.Context, client *http.Client, bucket, object string) (string, error) {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=resumable&name=%s", strings.TrimSuffix(endpoint, "/"), url.PathEscape(bucket), url.QueryEscape(object))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", responseError(resp)
+	}
+
+	session := resp.Header.Get("Location")
+	if session == "" {
+		return "", errors.New("no session URI in response")
+	}
+	return session, nil
+}
+
+// uploadChunks reads data in chunks of chunkSize bytes and uploads each of them to the resumable
+// session. The total size is only sent with the last chunk, so the size of data need not be known
+// in advance.
+func uploadChunks(ctx context.Context, client *http.Client, session string, data io.Reader, chunkSize int) error {
+	r := bufio.NewReader(data)
+	buf := make([]byte, chunkSize)
+	var offset int64
+
+	for {
+		n, err := io.ReadFull(r, buf)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("failed to read data: %w", err)
+		}
+
+		last := n < chunkSize
+		if !last {
+			if _, err := r.Peek(1); errors.Is(err, io.EOF) {
+				last = true
+			} else if err != nil {
+				return fmt.Errorf("failed to read data: %w", err)
+			}
+		}
+
+		if err := uploadChunk(ctx, client, session, buf[:n], offset, last); err != nil {
+			return err
+		}
+		offset += int64(n)
+
+		if last {
+			return nil
+		}
+	}
+}
+
+// uploadChunk uploads chunk, which starts at offset in the object. When last is set the upload is
+// finalized. If Cloud Storage persists only part of the chunk, the remainder is sent again.
+func uploadChunk(ctx context.Context, client *http.Client, session string, chunk []byte, offset int64, last bool) error {
+	for {
+		end := offset + int64(len(chunk))
+		total := "*"
+		if last {
+			total = strconv.FormatInt(end, 10)
+		}
+		contentRange := fmt.Sprintf("bytes %d-%d/%s", offset, end-1, total)
+		if len(chunk) == 0 {
+			contentRange = "bytes */" + total
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, session, bytes.NewReader(chunk))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Range", contentRange)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		var respErr error
+		if s := resp.StatusCode; s != http.StatusOK && s != http.StatusCreated && s != statusResumeIncomplete {
+			respErr = responseError(resp)
+		}
+		resp.Body.Close()
+		if respErr != nil {
+			return respErr
+		}
+
+		if resp.StatusCode != statusResumeIncomplete {
+			if !last {
+				return errors.New("upload finalized before all data was sent")
+			}
+			return nil
+		}
+		if len(chunk) == 0 {
+			return errors.New("upload was not finalized")
+		}
+
+		next, err := persistedOffset(resp.Header.Get("Range"))
+		if err != nil {
+			return err
+		}
+		if next <= offset || next > end {
+			return fmt.Errorf("chunk at offset %d was not persisted: %s", offset, resp.Header.Get("Range"))
+		}
+		if next == end {
+			if last {
+				return errors.New("upload was not finalized")
+			}
+			return nil
+		}
+		chunk = chunk[next-offset:]
+		offset = next
+	}
+}
+
+// persistedOffset returns the offset following the last byte persisted by Cloud Storage, as reported
+// in the Range header of a resume incomplete response, e.g. "bytes=0-262143".
+func persistedOffset(header string) (int64, error) {
+	if header == "" {
+		return 0, nil
+	}
+	_, end, ok := strings.Cut(strings.TrimPrefix(header, "bytes="), "-")
+	if !ok {
+		return 0, fmt.Errorf("invalid range header: %s", header)
+	}
+	n, err := strconv.ParseInt(end, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid range header: %s", header)
+	}
+	return n + 1, nil
+}
+
+// responseError builds an error from an unsuccessful response, including the start of its body.
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if msg := strings.TrimSpace(string(body)); msg != "" {
+		return fmt.Errorf("unexpected response status %s: %s", resp.Status, msg)
+	}
+	return fmt.Errorf("unexpected response status %s", resp.Status)
+}
+
+// parseDestination splits a gs://bucket/object destination into its bucket and object name.
+func parseDestination(destination string) (string, string, error) {
+	dst, err := url.Parse(destination)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse destination URI: %w", err)
+	}
+	if dst.Scheme != "gs" {
+		return "", "", fmt.Errorf("unsupported destination scheme: %s", dst.Scheme)
+	}
+
+	object := strings.TrimPrefix(dst.Path, "/")
+	if dst.Host == "" || object == "" {
+		return "", "", fmt.Errorf("destination must be of the form gs://bucket/object: %s", destination)
+	}
+	return dst.Host, object, nil
+}
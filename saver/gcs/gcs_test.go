@@ -0,0 +1,229 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+// fakeGCS is a minimal Cloud Storage server that supports resumable uploads.
+type fakeGCS struct {
+	t       *testing.T
+	mu      sync.Mutex
+	url     string
+	objects map[string][]byte
+	pending map[string][]byte
+	names   map[string]string
+	chunks  int
+	// partial, if set, persists only half of the first chunk of each session.
+	partial bool
+}
+
+func newFakeGCS(t *testing.T) *fakeGCS {
+	f := &fakeGCS{t: t, objects: map[string][]byte{}, pending: map[string][]byte{}, names: map[string]string{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	f.url = srv.URL
+	return f
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if got := r.Header.Get("Authorization"); got != "Bearer token" {
+		http.Error(w, "unauthorized: "+got, http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/upload/storage/v1/b/"):
+		if r.URL.Query().Get("uploadType") != "resumable" {
+			http.Error(w, "unsupported upload type", http.StatusBadRequest)
+			return
+		}
+		bucket := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/upload/storage/v1/b/"), "/o")
+		id := fmt.Sprintf("/session/%d", len(f.names))
+		f.names[id] = bucket + "/" + r.URL.Query().Get("name")
+		f.pending[id] = []byte{}
+		w.Header().Set("Location", f.url+id)
+	case r.Method == http.MethodPut:
+		data, ok := f.pending[r.URL.Path]
+		if !ok {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.chunks++
+
+		spec := strings.TrimPrefix(r.Header.Get("Content-Range"), "bytes ")
+		rng, total, _ := strings.Cut(spec, "/")
+		if rng != "*" {
+			start, _, _ := strings.Cut(rng, "-")
+			if offset, _ := strconv.Atoi(start); offset != len(data) {
+				http.Error(w, "unexpected offset "+start, http.StatusBadRequest)
+				return
+			}
+			if f.partial && len(data) == 0 && len(body) > 1 {
+				body = body[:len(body)/2]
+				total = "*"
+			}
+			data = append(data, body...)
+			f.pending[r.URL.Path] = data
+		}
+
+		if total == "*" {
+			if len(data) > 0 {
+				w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(data)-1))
+			}
+			w.WriteHeader(statusResumeIncomplete)
+			return
+		}
+		if n, _ := strconv.Atoi(total); n != len(data) {
+			http.Error(w, "size mismatch", http.StatusBadRequest)
+			return
+		}
+		f.objects[f.names[r.URL.Path]] = data
+		delete(f.pending, r.URL.Path)
+		fmt.Fprint(w, "{}")
+	default:
+		http.Error(w, "unsupported request", http.StatusNotImplemented)
+	}
+}
+
+func newTestSaver(endpoint string) *GCSSaver {
+	return &GCSSaver{
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}),
+		Endpoint:    endpoint,
+		ChunkSize:   chunkAlignment,
+	}
+}
+
+// TestGCSSaver_Save tests saving objects of various sizes through a resumable upload.
+func TestGCSSaver_Save(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		chunks int
+	}{
+		{name: "empty", size: 0, chunks: 1},
+		{name: "small", size: 10, chunks: 1},
+		{name: "exact chunk", size: chunkAlignment, chunks: 1},
+		{name: "multiple chunks", size: 2*chunkAlignment + 100, chunks: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeGCS(t)
+			data := bytes.Repeat([]byte("x"), tt.size)
+
+			if err := newTestSaver(f.url).Save(context.Background(), bytes.NewReader(data), "gs://bucket/path/to/object.bin"); err != nil {
+				t.Fatalf("failed to save object: %v", err)
+			}
+
+			got, ok := f.objects["bucket/path/to/object.bin"]
+			if !ok {
+				t.Fatal("object was not saved")
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("saved data does not match: got %d bytes, want %d", len(got), len(data))
+			}
+			if f.chunks != tt.chunks {
+				t.Errorf("unexpected number of chunks: got %d, want %d", f.chunks, tt.chunks)
+			}
+		})
+	}
+}
+
+// TestGCSSaver_SavePartialChunk tests that the remainder of a partially persisted chunk is sent again.
+func TestGCSSaver_SavePartialChunk(t *testing.T) {
+	f := newFakeGCS(t)
+	f.partial = true
+	data := bytes.Repeat([]byte("0123456789"), chunkAlignment/5)
+
+	if err := newTestSaver(f.url).Save(context.Background(), bytes.NewReader(data), "gs://bucket/object.bin"); err != nil {
+		t.Fatalf("failed to save object: %v", err)
+	}
+
+	if !bytes.Equal(f.objects["bucket/object.bin"], data) {
+		t.Errorf("saved data does not match: got %d bytes, want %d", len(f.objects["bucket/object.bin"]), len(data))
+	}
+}
+
+// TestGCSSaver_SaveErrors tests the errors returned for invalid configuration and failed requests.
+func TestGCSSaver_SaveErrors(t *testing.T) {
+	f := newFakeGCS(t)
+
+	s := newTestSaver(f.url)
+	s.ChunkSize = 1000
+	err := s.Save(context.Background(), strings.NewReader("data"), "gs://bucket/object")
+	if err == nil || err.Error() != "chunk size must be a multiple of 262144: 1000" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	s = newTestSaver(f.url)
+	s.TokenSource = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "wrong"})
+	err = s.Save(context.Background(), strings.NewReader("data"), "gs://bucket/object")
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to start upload to gs://bucket/object: unexpected response status 401 Unauthorized") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestParseDestination tests splitting destinations into bucket and object name.
+func TestParseDestination(t *testing.T) {
+	tests := []struct {
+		destination string
+		bucket      string
+		object      string
+		err         string
+	}{
+		{destination: "gs://bucket/object", bucket: "bucket", object: "object"},
+		{destination: "gs://bucket/path/to/object.json", bucket: "bucket", object: "path/to/object.json"},
+		{destination: "gs://bucket", err: "destination must be of the form gs://bucket/object: gs://bucket"},
+		{destination: "s3://bucket/key", err: "unsupported destination scheme: s3"},
+		{destination: ":", err: "failed to parse destination URI: parse \":\": missing protocol scheme"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.destination, func(t *testing.T) {
+			bucket, object, err := parseDestination(tt.destination)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if bucket != tt.bucket || object != tt.object {
+				t.Errorf("unexpected result: got %s/%s, want %s/%s", bucket, object, tt.bucket, tt.object)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+module github.com/enterprise-contract/go-gather/saver/gcs
+
+go 1.22.5
+
+require golang.org/x/oauth2 v0.24.0
+
+require cloud.google.com/go/compute/metadata v0.3.0 // indirect
@@ -0,0 +1,63 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package saver
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GzipSaver wraps a Saver and gzip compresses the data while it is being saved to destinations
+// ending in ".gz", e.g. to archive large gathered logs or bundles. Data saved to other destinations
+// is passed through unchanged.
+type GzipSaver struct {
+	// Saver saves the data.
+	Saver Saver
+	// Level is the gzip compression level, from gzip.BestSpeed to gzip.BestCompression.
+	// Defaults to gzip.DefaultCompression.
+	Level int
+}
+
+// NewGzipSaver returns a GzipSaver that saves the compressed data with s.
+func NewGzipSaver(s Saver) *GzipSaver {
+	return &GzipSaver{Saver: s}
+}
+
+// Save implements the Saver interface.
+func (g *GzipSaver) Save(ctx context.Context, data io.Reader, destination string) error {
+	if !strings.HasSuffix(strings.ToLower(destination), ".gz") {
+		return g.Saver.Save(ctx, data, destination)
+	}
+
+	level := g.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	zw, err := gzip.NewWriterLevel(nil, level)
+	if err != nil {
+		return fmt.Errorf("failed to create gzip writer: %w", err)
+	}
+
+	return saveTransformed(ctx, g.Saver, data, destination, func(w io.Writer) (io.WriteCloser, error) {
+		zw.Reset(w)
+		return zw, nil
+	})
+}
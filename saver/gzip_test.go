@@ -0,0 +1,86 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package saver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestGzipSaver tests that data saved to ".gz" destinations is compressed.
+func TestGzipSaver(t *testing.T) {
+	r := &recordingSaver{}
+	g := NewGzipSaver(r)
+
+	if err := g.Save(context.Background(), strings.NewReader("test data"), "logs.txt.GZ"); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	zr, err := gzip.NewReader(strings.NewReader(r.saved["logs.txt.GZ"]))
+	if err != nil {
+		t.Fatalf("saved data is not gzip compressed: %v", err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("failed to decompress: %v", err)
+	}
+	if string(b) != "test data" {
+		t.Errorf("unexpected decompressed data: %q", b)
+	}
+
+	if err := g.Save(context.Background(), strings.NewReader("test data"), "logs.txt"); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if r.saved["logs.txt"] != "test data" {
+		t.Errorf("unexpected saved data: %q", r.saved["logs.txt"])
+	}
+}
+
+// TestGzipSaver_InvalidLevel tests that an invalid compression level is rejected.
+func TestGzipSaver_InvalidLevel(t *testing.T) {
+	g := &GzipSaver{Saver: &recordingSaver{}, Level: 42}
+	if err := g.Save(context.Background(), strings.NewReader("test data"), "a.gz"); err == nil {
+		t.Error("expected an error for an invalid compression level")
+	}
+}
+
+// TestGzipSaver_Errors tests that read and save errors are returned.
+func TestGzipSaver_Errors(t *testing.T) {
+	readErr := errors.New("read error")
+	g := NewGzipSaver(&recordingSaver{})
+	if err := g.Save(context.Background(), io.MultiReader(strings.NewReader("test"), &errReader{readErr}), "a.gz"); !errors.Is(err, readErr) {
+		t.Errorf("unexpected error: got %v, want %v", err, readErr)
+	}
+
+	saveErr := errors.New("save error")
+	g = NewGzipSaver(&recordingSaver{err: saveErr})
+	if err := g.Save(context.Background(), bytes.NewReader(make([]byte, 1<<20)), "a.gz"); !errors.Is(err, saveErr) {
+		t.Errorf("unexpected error: got %v, want %v", err, saveErr)
+	}
+}
+
+type errReader struct {
+	err error
+}
+
+func (e *errReader) Read([]byte) (int, error) {
+	return 0, e.err
+}
@@ -0,0 +1,228 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package http provides functionality for uploading data to HTTP endpoints.
+//
+// This package contains the HTTPSaver type, which implements the Saver interface for
+// http:// and https:// destinations. The data is uploaded with a PUT request, which is
+// understood by WebDAV shares and most generic upload endpoints.
+//
+// Example usage:
+//
+//	s := &http.HTTPSaver{Username: "user", Password: "secret", Retries: 3}
+//	err := s.Save(context.Background(), data, "https://dav.example.com/files/bundle.tar.gz")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// DefaultRetryDelay is the delay before the first retry when none is configured. The delay doubles
+// with every further attempt.
+const DefaultRetryDelay = time.Second
+
+// HTTPSaver handles uploading data to HTTP endpoints with PUT requests.
+type HTTPSaver struct {
+	// Client is the HTTP client used for the upload. If nil, http.DefaultClient is used.
+	Client *http.Client
+	// Username and Password, if set, authenticate the upload with HTTP basic authentication.
+	Username string
+	Password string
+	// Token, if set, authenticates the upload with a bearer token.
+	Token string
+	// Header holds additional headers sent with the upload, e.g. Content-Type.
+	Header http.Header
+	// Retries is the number of times a failed upload is retried. Uploads are retried on
+	// network errors, 5xx responses and 429 Too Many Requests.
+	Retries int
+	// RetryDelay is the delay before the first retry. Defaults to DefaultRetryDelay.
+	RetryDelay time.Duration
+	// Chunked streams the data using chunked transfer encoding instead of sending a
+	// Content-Length. Without it, or when retries are enabled, data that cannot be seeked
+	// is buffered in a temporary file before it is uploaded.
+	Chunked bool
+	// Logger, if set, receives a message for every upload that is retried.
+	Logger *slog.Logger
+}
+
+// Save implements the Saver interface for HTTP destinations.
+func (s *HTTPSaver) Save(ctx context.Context, data io.Reader, destination string) error {
+	dst, err := url.Parse(destination)
+	if err != nil {
+		return fmt.Errorf("failed to parse destination URI: %w", err)
+	}
+	if dst.Scheme != "http" && dst.Scheme != "https" {
+		return fmt.Errorf("unsupported destination scheme: %s", dst.Scheme)
+	}
+
+	body, start, size, cleanup, err := s.prepareBody(ctx, data)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	delay := s.RetryDelay
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+
+	for attempt := 0; ; attempt++ {
+		retry, err := s.put(ctx, destination, body, size)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.Retries {
+			return gogather.RedactError(fmt.Errorf("failed to upload to %s: %w", destination, err))
+		}
+		if s.Logger != nil {
+			s.Logger.Info("retrying upload", "destination", gogather.RedactURL(destination), "attempt", attempt+1, "delay", delay, "error", gogather.RedactError(err))
+		}
+
+		if _, err := body.Seek(start, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to rewind data: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// prepareBody returns the body to upload, the offset at which it starts and its size. When retries
+// are enabled the body must be replayable, and when chunked transfer is disabled its size must be
+// known, so data that is not an io.ReadSeeker is then buffered in a temporary file, created in the
+// TempDir of the gather options carried by ctx, see gogather.CreateTemp. A size of -1
+// requests chunked transfer.
+func (s *HTTPSaver) prepareBody(ctx context.Context, data io.Reader) (io.ReadSeeker, int64, int64, func(), error) {
+	nop := func() {}
+
+	if rs, ok := data.(io.ReadSeeker); ok {
+		start, err := rs.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, 0, 0, nop, fmt.Errorf("failed to determine data size: %w", err)
+		}
+		if s.Chunked {
+			return rs, start, -1, nop, nil
+		}
+		end, err := rs.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, 0, 0, nop, fmt.Errorf("failed to determine data size: %w", err)
+		}
+		if _, err := rs.Seek(start, io.SeekStart); err != nil {
+			return nil, 0, 0, nop, fmt.Errorf("failed to determine data size: %w", err)
+		}
+		return rs, start, end - start, nop, nil
+	}
+
+	if s.Chunked && s.Retries == 0 {
+		return onceSeeker{data}, 0, -1, nop, nil
+	}
+
+	f, err := gogather.CreateTemp(ctx, "go-gather-upload-")
+	if err != nil {
+		return nil, 0, 0, nop, err
+	}
+	cleanup := func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	size, err := io.Copy(f, data)
+	if err != nil {
+		cleanup()
+		return nil, 0, 0, nop, fmt.Errorf("failed to buffer data: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, 0, 0, nop, fmt.Errorf("failed to rewind data: %w", err)
+	}
+	if s.Chunked {
+		size = -1
+	}
+	return f, 0, size, cleanup, nil
+}
+
+// put sends a single PUT request. It reports whether a failed request may be retried.
+func (s *HTTPSaver) put(ctx context.Context, destination string, body io.ReadSeeker, size int64) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, destination, io.NopCloser(body))
+	if err != nil {
+		return false, err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("User-Agent", "Go-Gather")
+	if s.Username != "" || s.Password != "" {
+		req.SetBasicAuth(s.Username, s.Password)
+	}
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("unexpected response status %s", resp.Status)
+	if m := strings.TrimSpace(string(msg)); m != "" {
+		err = fmt.Errorf("%w: %s", err, m)
+	}
+	return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, err
+}
+
+// onceSeeker wraps a reader that is only read once, so that it can be passed where an io.ReadSeeker
+// is expected. Seeking fails.
+type onceSeeker struct {
+	io.Reader
+}
+
+func (onceSeeker) Seek(int64, int) (int64, error) {
+	return 0, errors.New("data cannot be rewound")
+}
@@ -0,0 +1,190 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// upload records a request received by the test server.
+type upload struct {
+	body             string
+	contentLength    int64
+	transferEncoding []string
+	header           http.Header
+}
+
+// uploadServer records uploads and responds with the given status codes in turn, then with 201 Created.
+func uploadServer(t *testing.T, statuses ...int) (*httptest.Server, *[]upload) {
+	var mu sync.Mutex
+	var uploads []upload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		uploads = append(uploads, upload{body: string(body), contentLength: r.ContentLength, transferEncoding: r.TransferEncoding, header: r.Header})
+
+		if len(uploads) <= len(statuses) {
+			w.WriteHeader(statuses[len(uploads)-1])
+			io.WriteString(w, "try again")
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &uploads
+}
+
+// TestHTTPSaver_Save tests uploading seekable data with a Content-Length and credentials.
+func TestHTTPSaver_Save(t *testing.T) {
+	srv, uploads := uploadServer(t)
+
+	s := &HTTPSaver{Username: "user", Password: "secret", Header: http.Header{"Content-Type": {"application/json"}}}
+	if err := s.Save(context.Background(), strings.NewReader(`{"a": 1}`), srv.URL+"/files/data.json"); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	if len(*uploads) != 1 {
+		t.Fatalf("unexpected number of uploads: %d", len(*uploads))
+	}
+	u := (*uploads)[0]
+	if u.body != `{"a": 1}` || u.contentLength != 8 {
+		t.Errorf("unexpected upload: body %q, content length %d", u.body, u.contentLength)
+	}
+	if user, pass, ok := (&http.Request{Header: u.header}).BasicAuth(); !ok || user != "user" || pass != "secret" {
+		t.Errorf("unexpected basic auth: %s:%s", user, pass)
+	}
+	if got := u.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("unexpected content type: %s", got)
+	}
+}
+
+// TestHTTPSaver_SaveToken tests that a bearer token is sent.
+func TestHTTPSaver_SaveToken(t *testing.T) {
+	srv, uploads := uploadServer(t)
+
+	s := &HTTPSaver{Token: "token"}
+	if err := s.Save(context.Background(), strings.NewReader("data"), srv.URL+"/data"); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	if got := (*uploads)[0].header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("unexpected authorization header: %s", got)
+	}
+}
+
+// TestHTTPSaver_SaveStream tests uploading data that cannot be seeked, with and without chunked transfer.
+func TestHTTPSaver_SaveStream(t *testing.T) {
+	tests := []struct {
+		name          string
+		chunked       bool
+		contentLength int64
+	}{
+		{name: "buffered", chunked: false, contentLength: 9},
+		{name: "chunked", chunked: true, contentLength: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, uploads := uploadServer(t)
+
+			s := &HTTPSaver{Chunked: tt.chunked}
+			data := io.MultiReader(strings.NewReader("test "), strings.NewReader("data"))
+			if err := s.Save(context.Background(), data, srv.URL+"/data"); err != nil {
+				t.Fatalf("failed to save: %v", err)
+			}
+
+			u := (*uploads)[0]
+			if u.body != "test data" {
+				t.Errorf("unexpected body: %q", u.body)
+			}
+			if u.contentLength != tt.contentLength {
+				t.Errorf("unexpected content length: got %d, want %d", u.contentLength, tt.contentLength)
+			}
+			if chunked := len(u.transferEncoding) > 0 && u.transferEncoding[0] == "chunked"; chunked != tt.chunked {
+				t.Errorf("unexpected transfer encoding: %v", u.transferEncoding)
+			}
+		})
+	}
+}
+
+// TestHTTPSaver_SaveRetries tests that failed uploads are retried with the complete data.
+func TestHTTPSaver_SaveRetries(t *testing.T) {
+	srv, uploads := uploadServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+
+	var logs bytes.Buffer
+	s := &HTTPSaver{Retries: 2, RetryDelay: time.Millisecond, Chunked: true, Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	data := io.MultiReader(bytes.NewReader([]byte("test data")))
+	if err := s.Save(context.Background(), data, srv.URL+"/data"); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if n := strings.Count(logs.String(), "retrying upload"); n != 2 {
+		t.Errorf("unexpected number of retries logged: %d\n%s", n, logs.String())
+	}
+
+	if len(*uploads) != 3 {
+		t.Fatalf("unexpected number of uploads: got %d, want 3", len(*uploads))
+	}
+	for i, u := range *uploads {
+		if u.body != "test data" {
+			t.Errorf("unexpected body of attempt %d: %q", i+1, u.body)
+		}
+	}
+}
+
+// TestHTTPSaver_SaveErrors tests that errors are reported and only retried when the failure is transient.
+func TestHTTPSaver_SaveErrors(t *testing.T) {
+	srv, uploads := uploadServer(t, http.StatusForbidden)
+
+	s := &HTTPSaver{Retries: 2, RetryDelay: time.Millisecond}
+	err := s.Save(context.Background(), strings.NewReader("data"), srv.URL+"/data")
+	want := "failed to upload to " + srv.URL + "/data: unexpected response status 403 Forbidden: try again"
+	if err == nil || err.Error() != want {
+		t.Errorf("unexpected error: got %v, want %s", err, want)
+	}
+	if len(*uploads) != 1 {
+		t.Errorf("unexpected number of uploads: got %d, want 1", len(*uploads))
+	}
+
+	srv, uploads = uploadServer(t, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	err = s.Save(context.Background(), strings.NewReader("data"), srv.URL+"/data")
+	if err == nil || !strings.Contains(err.Error(), "502 Bad Gateway") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(*uploads) != 3 {
+		t.Errorf("unexpected number of uploads: got %d, want 3", len(*uploads))
+	}
+
+	err = s.Save(context.Background(), strings.NewReader("data"), "ftp://example.com/data")
+	if err == nil || err.Error() != "unsupported destination scheme: ftp" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
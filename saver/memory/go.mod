@@ -0,0 +1,5 @@
+module github.com/enterprise-contract/go-gather/saver/memory
+
+go 1.22.5
+
+require github.com/enterprise-contract/go-gather v0.0.3
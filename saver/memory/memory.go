@@ -0,0 +1,145 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package memory provides functionality for saving data in memory.
+//
+// This package contains the MemorySaver type, which implements the Saver interface by
+// keeping the saved data in memory, keyed by destination. It allows gathered content to be
+// post-processed without touching the disk.
+//
+// Example usage:
+//
+//	ms := &memory.MemorySaver{MaxSize: 10 << 20}
+//	err := ms.Save(context.Background(), data, "policy.json")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	content, _ := ms.Bytes("policy.json")
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// SizeLimitError is returned when saving data would grow the total size held by a MemorySaver
+// beyond its MaxSize.
+type SizeLimitError struct {
+	Limit int64
+}
+
+func (e *SizeLimitError) Error() string {
+	return fmt.Sprintf("saved data exceeds the %d byte limit", e.Limit)
+}
+
+// Is reports whether target is gogather.ErrTooLarge.
+func (e *SizeLimitError) Is(target error) bool {
+	return target == gogather.ErrTooLarge
+}
+
+// MemorySaver handles saving data in memory. The zero value is ready to use. It is safe for
+// concurrent use.
+type MemorySaver struct {
+	// MaxSize limits the total number of bytes held across all destinations. Zero means
+	// no limit.
+	MaxSize int64
+
+	mu    sync.Mutex
+	files map[string]*bytes.Buffer
+	size  int64
+}
+
+// Save implements the Saver interface by reading data into memory. Saving to a destination
+// that was saved before replaces its content. If the size limit is exceeded, nothing is saved
+// and a *SizeLimitError is returned.
+func (m *MemorySaver) Save(ctx context.Context, data io.Reader, destination string) error {
+	r := data
+	if m.MaxSize > 0 {
+		// Allow reading one byte past the limit to detect that it is exceeded.
+		r = io.LimitReader(data, m.MaxSize+1)
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := buf.ReadFrom(r); err != nil {
+		return fmt.Errorf("failed to read data: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var previous int64
+	if prev, ok := m.files[destination]; ok {
+		previous = int64(prev.Len())
+	}
+	if m.MaxSize > 0 && m.size-previous+int64(buf.Len()) > m.MaxSize {
+		return &SizeLimitError{Limit: m.MaxSize}
+	}
+
+	if m.files == nil {
+		m.files = map[string]*bytes.Buffer{}
+	}
+	m.files[destination] = buf
+	m.size += int64(buf.Len()) - previous
+	return nil
+}
+
+// Bytes returns the data saved to destination and whether anything was saved to it.
+func (m *MemorySaver) Bytes(destination string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buf, ok := m.files[destination]
+	if !ok {
+		return nil, false
+	}
+	return bytes.Clone(buf.Bytes()), true
+}
+
+// Destinations returns the sorted destinations data was saved to.
+func (m *MemorySaver) Destinations() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	destinations := make([]string, 0, len(m.files))
+	for d := range m.files {
+		destinations = append(destinations, d)
+	}
+	sort.Strings(destinations)
+	return destinations
+}
+
+// Size returns the total number of bytes held.
+func (m *MemorySaver) Size() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.size
+}
+
+// Reset discards all saved data.
+func (m *MemorySaver) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.files = nil
+	m.size = 0
+}
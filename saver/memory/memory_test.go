@@ -0,0 +1,136 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+type mockErrorReader struct{}
+
+func (r *mockErrorReader) Read(p []byte) (n int, err error) {
+	return 0, fmt.Errorf("read error")
+}
+
+// TestMemorySaver_Save tests saving data to several destinations and reading it back.
+func TestMemorySaver_Save(t *testing.T) {
+	m := &MemorySaver{}
+
+	for dst, data := range map[string]string{"a.txt": "first", "dir/b.txt": "second"} {
+		if err := m.Save(context.Background(), strings.NewReader(data), dst); err != nil {
+			t.Fatalf("failed to save %s: %v", dst, err)
+		}
+	}
+	if err := m.Save(context.Background(), strings.NewReader("replaced"), "a.txt"); err != nil {
+		t.Fatalf("failed to save a.txt: %v", err)
+	}
+
+	if got, ok := m.Bytes("a.txt"); !ok || string(got) != "replaced" {
+		t.Errorf("unexpected data for a.txt: %q, %v", got, ok)
+	}
+	if got, ok := m.Bytes("dir/b.txt"); !ok || string(got) != "second" {
+		t.Errorf("unexpected data for dir/b.txt: %q, %v", got, ok)
+	}
+	if _, ok := m.Bytes("missing"); ok {
+		t.Error("expected no data for a missing destination")
+	}
+	if got := m.Destinations(); !reflect.DeepEqual(got, []string{"a.txt", "dir/b.txt"}) {
+		t.Errorf("unexpected destinations: %v", got)
+	}
+	if got := m.Size(); got != int64(len("replaced")+len("second")) {
+		t.Errorf("unexpected size: %d", got)
+	}
+
+	m.Reset()
+	if got := m.Destinations(); len(got) != 0 || m.Size() != 0 {
+		t.Errorf("expected no data after reset, got %v", got)
+	}
+}
+
+// TestMemorySaver_MaxSize tests that the size limit applies to the total of all destinations.
+func TestMemorySaver_MaxSize(t *testing.T) {
+	m := &MemorySaver{MaxSize: 10}
+
+	if err := m.Save(context.Background(), strings.NewReader("123456"), "a"); err != nil {
+		t.Fatalf("failed to save a: %v", err)
+	}
+
+	err := m.Save(context.Background(), strings.NewReader("12345"), "b")
+	var sizeErr *SizeLimitError
+	if !errors.As(err, &sizeErr) || sizeErr.Limit != 10 {
+		t.Fatalf("expected a SizeLimitError, got %v", err)
+	}
+	if !errors.Is(err, gogather.ErrTooLarge) {
+		t.Errorf("expected the error to match ErrTooLarge: %v", err)
+	}
+	if err.Error() != "saved data exceeds the 10 byte limit" {
+		t.Errorf("unexpected error message: %s", err)
+	}
+	if _, ok := m.Bytes("b"); ok {
+		t.Error("expected nothing to be saved when the limit is exceeded")
+	}
+
+	if err := m.Save(context.Background(), strings.NewReader("1234"), "b"); err != nil {
+		t.Errorf("failed to save data up to the limit: %v", err)
+	}
+	// Replacing a destination only counts the difference in size.
+	if err := m.Save(context.Background(), strings.NewReader("12"), "a"); err != nil {
+		t.Errorf("failed to replace a: %v", err)
+	}
+	if got := m.Size(); got != 6 {
+		t.Errorf("unexpected size: %d", got)
+	}
+}
+
+// TestMemorySaver_ReadError tests that read errors are reported.
+func TestMemorySaver_ReadError(t *testing.T) {
+	m := &MemorySaver{}
+
+	err := m.Save(context.Background(), &mockErrorReader{}, "a")
+	if err == nil || err.Error() != "failed to read data: read error" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestMemorySaver_Concurrent tests that the saver can be used from several goroutines.
+func TestMemorySaver_Concurrent(t *testing.T) {
+	m := &MemorySaver{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := m.Save(context.Background(), strings.NewReader("data"), fmt.Sprintf("file-%d", i)); err != nil {
+				t.Errorf("failed to save: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := m.Size(); got != 40 {
+		t.Errorf("unexpected size: %d", got)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package saver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// QuotaExceededError is returned when the data saved through a QuotaSaver exceeds its quota.
+type QuotaExceededError struct {
+	Quota int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("saved data exceeds the %d byte quota", e.Quota)
+}
+
+// Is reports whether target is gogather.ErrTooLarge.
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == gogather.ErrTooLarge
+}
+
+// QuotaSaver wraps a Saver and tracks the cumulative number of bytes saved through it, e.g. across
+// all sources of a gather. Once the quota is exceeded, the save in progress and any later saves fail
+// with a QuotaExceededError. It is safe for concurrent use if the wrapped Saver is.
+type QuotaSaver struct {
+	// Saver saves the data.
+	Saver Saver
+	// Quota is the maximum number of bytes that may be saved. Zero or less disables the quota.
+	Quota int64
+
+	used atomic.Int64
+}
+
+// NewQuotaSaver returns a QuotaSaver that saves at most quota bytes with s.
+func NewQuotaSaver(s Saver, quota int64) *QuotaSaver {
+	return &QuotaSaver{Saver: s, Quota: quota}
+}
+
+// Save implements the Saver interface.
+func (q *QuotaSaver) Save(ctx context.Context, data io.Reader, destination string) error {
+	if q.Quota > 0 && q.used.Load() > q.Quota {
+		return &QuotaExceededError{Quota: q.Quota}
+	}
+	return q.Saver.Save(ctx, &quotaReader{r: data, q: q}, destination)
+}
+
+// Used returns the number of bytes saved so far.
+func (q *QuotaSaver) Used() int64 {
+	return q.used.Load()
+}
+
+// quotaReader counts the bytes read through it against the quota of q.
+type quotaReader struct {
+	r io.Reader
+	q *QuotaSaver
+}
+
+func (r *quotaReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if used := r.q.used.Add(int64(n)); r.q.Quota > 0 && used > r.q.Quota {
+		return n, &QuotaExceededError{Quota: r.q.Quota}
+	}
+	return n, err
+}
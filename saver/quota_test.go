@@ -0,0 +1,71 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package saver
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// TestQuotaSaver tests that saves fail once the cumulative quota is exceeded.
+func TestQuotaSaver(t *testing.T) {
+	r := &recordingSaver{}
+	q := NewQuotaSaver(r, 10)
+
+	if err := q.Save(context.Background(), strings.NewReader("test data"), "a.txt"); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if q.Used() != 9 {
+		t.Errorf("unexpected used bytes: got %d, want 9", q.Used())
+	}
+
+	var quotaErr *QuotaExceededError
+	err := q.Save(context.Background(), strings.NewReader("more data"), "b.txt")
+	if !errors.As(err, &quotaErr) || quotaErr.Quota != 10 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !errors.Is(err, gogather.ErrTooLarge) {
+		t.Errorf("expected the error to match ErrTooLarge: %v", err)
+	}
+	if err.Error() != "saved data exceeds the 10 byte quota" {
+		t.Errorf("unexpected error message: %s", err)
+	}
+	if _, ok := r.saved["b.txt"]; ok {
+		t.Error("expected the save exceeding the quota to fail")
+	}
+
+	if err := q.Save(context.Background(), strings.NewReader(""), "c.txt"); !errors.As(err, &quotaErr) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestQuotaSaver_Unlimited tests that a quota of zero disables the check.
+func TestQuotaSaver_Unlimited(t *testing.T) {
+	q := NewQuotaSaver(&recordingSaver{}, 0)
+	for i := 0; i < 3; i++ {
+		if err := q.Save(context.Background(), strings.NewReader("test data"), "a.txt"); err != nil {
+			t.Fatalf("failed to save: %v", err)
+		}
+	}
+	if q.Used() != 27 {
+		t.Errorf("unexpected used bytes: got %d, want 27", q.Used())
+	}
+}
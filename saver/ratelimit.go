@@ -0,0 +1,78 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package saver
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimitSaver wraps a Saver and limits the rate at which data is passed to it, e.g. to throttle
+// writes to a slow network filesystem independently of the speed the data is gathered at. The limit
+// is shared by all saves in progress. It is safe for concurrent use if the wrapped Saver is.
+type RateLimitSaver struct {
+	// Saver saves the data.
+	Saver Saver
+	// BytesPerSecond is the maximum number of bytes saved per second. Zero or less disables the
+	// limit.
+	BytesPerSecond int
+
+	once    sync.Once
+	limiter *rate.Limiter
+}
+
+// NewRateLimitSaver returns a RateLimitSaver that saves at most bytesPerSecond bytes per second with s.
+func NewRateLimitSaver(s Saver, bytesPerSecond int) *RateLimitSaver {
+	return &RateLimitSaver{Saver: s, BytesPerSecond: bytesPerSecond}
+}
+
+// Save implements the Saver interface. Save fails if ctx is done while it waits for the rate limit.
+func (r *RateLimitSaver) Save(ctx context.Context, data io.Reader, destination string) error {
+	if r.BytesPerSecond <= 0 {
+		return r.Saver.Save(ctx, data, destination)
+	}
+
+	r.once.Do(func() {
+		r.limiter = rate.NewLimiter(rate.Limit(r.BytesPerSecond), r.BytesPerSecond)
+	})
+	return r.Saver.Save(ctx, &rateLimitedReader{ctx: ctx, r: data, limiter: r.limiter}, destination)
+}
+
+// rateLimitedReader waits for the limiter before returning the data read.
+type rateLimitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	// Reads cannot exceed the burst of the limiter, which is one second's worth of data.
+	if burst := r.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.limiter.WaitN(r.ctx, n); werr != nil {
+			return 0, werr
+		}
+	}
+	return n, err
+}
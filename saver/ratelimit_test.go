@@ -0,0 +1,66 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package saver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestRateLimitSaver tests that saves are throttled to the configured rate.
+func TestRateLimitSaver(t *testing.T) {
+	r := &recordingSaver{}
+	l := NewRateLimitSaver(r, 1000)
+
+	start := time.Now()
+	if err := l.Save(context.Background(), bytes.NewReader(make([]byte, 1500)), "a.txt"); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	// The first 1000 bytes are within the burst, the remaining 500 bytes take half a second.
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("save was not throttled: took %s", elapsed)
+	}
+	if len(r.saved["a.txt"]) != 1500 {
+		t.Errorf("unexpected saved size: %d", len(r.saved["a.txt"]))
+	}
+}
+
+// TestRateLimitSaver_Canceled tests that waiting for the rate limit stops when the context is canceled.
+func TestRateLimitSaver_Canceled(t *testing.T) {
+	l := NewRateLimitSaver(&recordingSaver{}, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := l.Save(ctx, bytes.NewReader(make([]byte, 100)), "a.txt"); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestRateLimitSaver_Unlimited tests that a rate of zero disables the limit.
+func TestRateLimitSaver_Unlimited(t *testing.T) {
+	r := &recordingSaver{}
+	if err := NewRateLimitSaver(r, 0).Save(context.Background(), bytes.NewReader(make([]byte, 1<<20)), "a.txt"); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if len(r.saved["a.txt"]) != 1<<20 {
+		t.Errorf("unexpected saved size: %d", len(r.saved["a.txt"]))
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package saver
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/enterprise-contract/go-gather/saver/azblob"
+	"github.com/enterprise-contract/go-gather/saver/file"
+	"github.com/enterprise-contract/go-gather/saver/gcs"
+	"github.com/enterprise-contract/go-gather/saver/http"
+	"github.com/enterprise-contract/go-gather/saver/s3"
+	"github.com/enterprise-contract/go-gather/saver/sftp"
+	"github.com/enterprise-contract/go-gather/saver/writer"
+)
+
+// Factory creates a saver for a destination protocol.
+type Factory func() Saver
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{
+		"file":    fileFactory,
+		"FileURI": fileFactory,
+		"s3": func() Saver {
+			return &s3.S3Saver{}
+		},
+		"gs": func() Saver {
+			return &gcs.GCSSaver{}
+		},
+		"azblob": func() Saver {
+			return &azblob.AzureBlobSaver{}
+		},
+		"http":    httpFactory,
+		"https":   httpFactory,
+		"HTTPURI": httpFactory,
+		"sftp": func() Saver {
+			return &sftp.SFTPSaver{}
+		},
+		writer.Stdout: func() Saver {
+			return &writer.WriterSaver{}
+		},
+	}
+)
+
+func fileFactory() Saver {
+	return &file.FileSaver{}
+}
+
+func httpFactory() Saver {
+	return &http.HTTPSaver{}
+}
+
+// RegisterSaver registers the factory for the destination protocol, e.g. "db" for a saver that
+// stores data as database blobs. Registering a protocol that is already registered replaces its
+// factory, which allows the built-in savers to be overridden.
+func RegisterSaver(protocol string, factory Factory) error {
+	if protocol == "" {
+		return fmt.Errorf("protocol is empty")
+	}
+	if factory == nil {
+		return fmt.Errorf("factory for protocol %s is nil", protocol)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[protocol] = factory
+	return nil
+}
+
+// Protocols returns the registered destination protocols, sorted.
+func Protocols() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	protocols := make([]string, 0, len(registry))
+	for protocol := range registry {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+	return protocols
+}
+
+// NewSaver returns a Saver instance based on the destination protocol.
+func NewSaver(protocol string) (Saver, error) {
+	registryMu.RLock()
+	factory, ok := registry[protocol]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported protocol: %s", protocol)
+	}
+	return factory(), nil
+}
@@ -0,0 +1,90 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package saver
+
+import (
+	"context"
+	"io"
+	"slices"
+	"testing"
+)
+
+type dbSaver struct{}
+
+func (*dbSaver) Save(ctx context.Context, data io.Reader, destination string) error {
+	return nil
+}
+
+// TestRegisterSaver tests registering a saver for a custom protocol
+func TestRegisterSaver(t *testing.T) {
+	if err := RegisterSaver("db", func() Saver { return &dbSaver{} }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "db")
+		registryMu.Unlock()
+	})
+
+	s, err := NewSaver("db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := s.(*dbSaver); !ok {
+		t.Errorf("unexpected saver type: got %T, want *dbSaver", s)
+	}
+
+	if protocols := Protocols(); !slices.Contains(protocols, "db") || !slices.IsSorted(protocols) {
+		t.Errorf("unexpected protocols: %v", protocols)
+	}
+}
+
+// TestRegisterSaver_Override tests replacing the factory of a built-in protocol
+func TestRegisterSaver_Override(t *testing.T) {
+	registryMu.RLock()
+	original := registry["file"]
+	registryMu.RUnlock()
+	t.Cleanup(func() {
+		if err := RegisterSaver("file", original); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	if err := RegisterSaver("file", func() Saver { return &dbSaver{} }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, err := NewSaver("file")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := s.(*dbSaver); !ok {
+		t.Errorf("unexpected saver type: got %T, want *dbSaver", s)
+	}
+}
+
+// TestRegisterSaver_Invalid tests that invalid registrations are rejected
+func TestRegisterSaver_Invalid(t *testing.T) {
+	if err := RegisterSaver("", func() Saver { return &dbSaver{} }); err == nil || err.Error() != "protocol is empty" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := RegisterSaver("db", nil); err == nil || err.Error() != "factory for protocol db is nil" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := NewSaver("db"); err == nil {
+		t.Error("expected an error for an unregistered protocol")
+	}
+}
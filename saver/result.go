@@ -0,0 +1,85 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package saver
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Result describes the outcome of a save.
+type Result struct {
+	// Path is the location the data was saved to. For savers implementing PathResolver this is
+	// the resolved path, e.g. the absolute path of a saved file, otherwise the destination.
+	Path string
+	// Size is the number of bytes saved.
+	Size int64
+	// Duration is the time the save took.
+	Duration time.Duration
+	// Unchanged reports that the destination already held the data, so it was not written.
+	Unchanged bool
+}
+
+// ResultSaver is implemented by savers that report the result of a save themselves.
+type ResultSaver interface {
+	Saver
+	SaveWithResult(ctx context.Context, data io.Reader, destination string) (*Result, error)
+}
+
+// PathResolver is implemented by savers that can resolve a destination to the location the data
+// is saved to.
+type PathResolver interface {
+	ResolvePath(destination string) (string, error)
+}
+
+// SaveWithResult saves data to destination with s and returns the result of the save, so that
+// callers do not have to inspect the destination afterwards.
+func SaveWithResult(ctx context.Context, s Saver, data io.Reader, destination string) (*Result, error) {
+	if r, ok := s.(ResultSaver); ok {
+		return r.SaveWithResult(ctx, data, destination)
+	}
+
+	path := destination
+	if r, ok := s.(PathResolver); ok {
+		resolved, err := r.ResolvePath(destination)
+		if err != nil {
+			return nil, err
+		}
+		path = resolved
+	}
+
+	counter := &countingReader{r: data}
+	start := time.Now()
+	if err := s.Save(ctx, counter, destination); err != nil {
+		return nil, err
+	}
+
+	return &Result{Path: path, Size: counter.n, Duration: time.Since(start)}, nil
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
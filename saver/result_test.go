@@ -0,0 +1,67 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package saver
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/enterprise-contract/go-gather/saver/file"
+)
+
+// TestSaveWithResult tests that the result reports the resolved path and size of the saved data.
+func TestSaveWithResult(t *testing.T) {
+	dir := t.TempDir()
+	want := filepath.Join(dir, "test.txt")
+
+	result, err := SaveWithResult(context.Background(), &file.FileSaver{}, strings.NewReader("test data"), filepath.Join(dir, "sub", "..", "test.txt"))
+	if err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if result.Path != want {
+		t.Errorf("unexpected path: got %s, want %s", result.Path, want)
+	}
+	if result.Size != 9 {
+		t.Errorf("unexpected size: got %d, want 9", result.Size)
+	}
+	if result.Duration <= 0 {
+		t.Errorf("unexpected duration: %s", result.Duration)
+	}
+	if _, err := os.Stat(result.Path); err != nil {
+		t.Errorf("saved file not found: %v", err)
+	}
+}
+
+// TestSaveWithResult_Unresolved tests that the destination is reported for savers that do not resolve paths.
+func TestSaveWithResult_Unresolved(t *testing.T) {
+	result, err := SaveWithResult(context.Background(), &recordingSaver{}, strings.NewReader("test data"), "a.txt")
+	if err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if result.Path != "a.txt" || result.Size != 9 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	saveErr := errors.New("save error")
+	if _, err := SaveWithResult(context.Background(), &recordingSaver{err: saveErr}, strings.NewReader("test data"), "a.txt"); !errors.Is(err, saveErr) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
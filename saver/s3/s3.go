@@ -0,0 +1,144 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package s3 provides functionality for saving data to Amazon S3 and S3 compatible object storage.
+//
+// This package contains the S3Saver type, which implements the Saver interface for
+// s3://bucket/key destinations. Data is streamed to the bucket using a multipart upload,
+// so large content does not need to be buffered in full before it is written.
+//
+// Example usage:
+//
+//	s := &s3.S3Saver{Region: "us-east-1"}
+//	err := s.Save(context.Background(), data, "s3://bucket/path/to/object.json")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Saver handles saving data to S3 buckets. The zero value uses the region and
+// credentials resolved from the environment and shared configuration files.
+type S3Saver struct {
+	// Region is the AWS region of the bucket. If empty, the region is taken from the
+	// environment or the shared configuration.
+	Region string
+	// Endpoint overrides the S3 endpoint, e.g. to use an S3 compatible service such as
+	// MinIO.
+	Endpoint string
+	// UsePathStyle addresses buckets as part of the path rather than the host name, which
+	// most S3 compatible services require.
+	UsePathStyle bool
+	// Credentials provides the credentials used to sign requests. If nil, the default
+	// credential chain is used.
+	Credentials aws.CredentialsProvider
+	// Anonymous sends unsigned requests, e.g. to buckets that allow anonymous uploads,
+	// without looking up credentials. It takes precedence over Credentials.
+	Anonymous bool
+	// PartSize is the size of each part of the multipart upload. If zero, the upload
+	// manager default is used. The minimum is 5 MiB.
+	PartSize int64
+	// Concurrency is the number of parts uploaded in parallel. If zero, the upload
+	// manager default is used.
+	Concurrency int
+}
+
+// Save implements the Saver interface for S3 destinations.
+func (s *S3Saver) Save(ctx context.Context, data io.Reader, destination string) error {
+	bucket, key, err := parseDestination(destination)
+	if err != nil {
+		return err
+	}
+
+	client, err := s.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		if s.PartSize > 0 {
+			u.PartSize = s.PartSize
+		}
+		if s.Concurrency > 0 {
+			u.Concurrency = s.Concurrency
+		}
+	})
+
+	_, err = uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload to %s: %w", destination, err)
+	}
+	return nil
+}
+
+// client creates an S3 client using the default configuration with the options of the saver applied.
+func (s *S3Saver) client(ctx context.Context) (*s3.Client, error) {
+	var opts []func(*config.LoadOptions) error
+	if s.Region != "" {
+		opts = append(opts, config.WithRegion(s.Region))
+	}
+	switch {
+	case s.Anonymous:
+		opts = append(opts, config.WithCredentialsProvider(aws.AnonymousCredentials{}))
+	case s.Credentials != nil:
+		opts = append(opts, config.WithCredentialsProvider(s.Credentials))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if s.Endpoint != "" {
+			o.BaseEndpoint = aws.String(s.Endpoint)
+		}
+		o.UsePathStyle = s.UsePathStyle
+	}), nil
+}
+
+// parseDestination splits an s3://bucket/key destination into its bucket and key.
+func parseDestination(destination string) (string, string, error) {
+	dst, err := url.Parse(destination)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse destination URI: %w", err)
+	}
+	if dst.Scheme != "s3" {
+		return "", "", fmt.Errorf("unsupported destination scheme: %s", dst.Scheme)
+	}
+
+	key := strings.TrimPrefix(dst.Path, "/")
+	if dst.Host == "" || key == "" {
+		return "", "", fmt.Errorf("destination must be of the form s3://bucket/key: %s", destination)
+	}
+	return dst.Host, key, nil
+}
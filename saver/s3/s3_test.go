@@ -0,0 +1,217 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// fakeS3 is a minimal S3 compatible server that supports PutObject and multipart uploads
+// using path style addressing. It counts the requests that are not signed.
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	parts    map[string]map[int][]byte
+	uploads  int
+	unsigned int
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	f := &fakeS3{objects: map[string][]byte{}, parts: map[string]map[int][]byte{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") == "" {
+		f.unsigned++
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	q := r.URL.Query()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		f.uploads++
+		id := strconv.Itoa(f.uploads)
+		f.parts[id] = map[int][]byte{}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		f.parts[q.Get("uploadId")][n] = body
+		w.Header().Set("ETag", fmt.Sprintf("\"part-%d\"", n))
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		parts := f.parts[q.Get("uploadId")]
+		numbers := make([]int, 0, len(parts))
+		for n := range parts {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+		var data []byte
+		for _, n := range numbers {
+			data = append(data, parts[n]...)
+		}
+		f.objects[key] = data
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+		w.Header().Set("ETag", "\"object\"")
+	default:
+		http.Error(w, "unsupported request", http.StatusNotImplemented)
+	}
+}
+
+func newTestSaver(endpoint string) *S3Saver {
+	return &S3Saver{
+		Region:       "us-east-1",
+		Endpoint:     endpoint,
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider("key", "secret", ""),
+	}
+}
+
+// TestS3Saver_Save tests saving a small object with a single request.
+func TestS3Saver_Save(t *testing.T) {
+	f, srv := newFakeS3(t)
+
+	err := newTestSaver(srv.URL).Save(context.Background(), strings.NewReader("test data"), "s3://bucket/path/to/file.txt")
+	if err != nil {
+		t.Fatalf("failed to save object: %v", err)
+	}
+
+	if got := string(f.objects["bucket/path/to/file.txt"]); got != "test data" {
+		t.Errorf("unexpected saved data: got %q, want %q", got, "test data")
+	}
+	if f.uploads != 0 {
+		t.Errorf("expected a single request upload, got %d multipart uploads", f.uploads)
+	}
+}
+
+// TestS3Saver_SaveAnonymous tests sending unsigned requests to buckets that allow anonymous uploads.
+func TestS3Saver_SaveAnonymous(t *testing.T) {
+	f, srv := newFakeS3(t)
+
+	s := newTestSaver(srv.URL)
+	s.Anonymous = true
+	if err := s.Save(context.Background(), strings.NewReader("test data"), "s3://bucket/file.txt"); err != nil {
+		t.Fatalf("failed to save object: %v", err)
+	}
+
+	if got := string(f.objects["bucket/file.txt"]); got != "test data" {
+		t.Errorf("unexpected saved data: got %q, want %q", got, "test data")
+	}
+	if f.unsigned != 1 {
+		t.Errorf("expected an unsigned request, got %d", f.unsigned)
+	}
+}
+
+// TestS3Saver_SaveMultipart tests that content larger than the part size is uploaded in parts.
+func TestS3Saver_SaveMultipart(t *testing.T) {
+	f, srv := newFakeS3(t)
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), 700*1024) // 11.2 MiB, three 5 MiB parts
+	s := newTestSaver(srv.URL)
+	s.PartSize = 5 * 1024 * 1024
+	s.Concurrency = 2
+
+	if err := s.Save(context.Background(), bytes.NewReader(data), "s3://bucket/large.bin"); err != nil {
+		t.Fatalf("failed to save object: %v", err)
+	}
+
+	if f.uploads != 1 {
+		t.Errorf("expected one multipart upload, got %d", f.uploads)
+	}
+	if got := len(f.parts["1"]); got != 3 {
+		t.Errorf("unexpected number of parts: got %d, want 3", got)
+	}
+	if !bytes.Equal(f.objects["bucket/large.bin"], data) {
+		t.Errorf("saved data does not match: got %d bytes, want %d", len(f.objects["bucket/large.bin"]), len(data))
+	}
+}
+
+// TestS3Saver_UploadError tests that a failed upload is reported.
+func TestS3Saver_UploadError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, "<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>")
+	}))
+	defer srv.Close()
+
+	s := newTestSaver(srv.URL)
+	err := s.Save(context.Background(), strings.NewReader("test data"), "s3://bucket/file.txt")
+	if err == nil {
+		t.Fatal("expected an error, but got nil")
+	}
+	if !strings.Contains(err.Error(), "failed to upload to s3://bucket/file.txt") || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestParseDestination tests splitting destinations into bucket and key.
+func TestParseDestination(t *testing.T) {
+	tests := []struct {
+		destination string
+		bucket      string
+		key         string
+		err         string
+	}{
+		{destination: "s3://bucket/key", bucket: "bucket", key: "key"},
+		{destination: "s3://bucket/path/to/key.json", bucket: "bucket", key: "path/to/key.json"},
+		{destination: "s3://bucket", err: "destination must be of the form s3://bucket/key: s3://bucket"},
+		{destination: "s3://bucket/", err: "destination must be of the form s3://bucket/key: s3://bucket/"},
+		{destination: "gs://bucket/key", err: "unsupported destination scheme: gs"},
+		{destination: ":", err: "failed to parse destination URI: parse \":\": missing protocol scheme"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.destination, func(t *testing.T) {
+			bucket, key, err := parseDestination(tt.destination)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("unexpected error: got %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if bucket != tt.bucket || key != tt.key {
+				t.Errorf("unexpected result: got %s/%s, want %s/%s", bucket, key, tt.bucket, tt.key)
+			}
+		})
+	}
+}
@@ -21,8 +21,17 @@
 // and a destination string specifying the destination where the data should be saved. It returns an error if the save operation fails.
 //
 // The NewSaver function takes a protocol string as input and returns a Saver instance based on the specified protocol.
-// Currently, the only supported protocol is "file", which creates a FileSaver instance for saving data to a file.
-// If an unsupported protocol is provided, NewSaver returns an error.
+// The supported protocols are:
+//   - "file", which creates a FileSaver instance for saving data to a file.
+//   - "s3", which creates an S3Saver instance for saving data to an S3 bucket.
+//   - "gs", which creates a GCSSaver instance for saving data to a Google Cloud Storage bucket.
+//   - "azblob", which creates an AzureBlobSaver instance for saving data to an Azure Blob Storage container.
+//   - "http" or "https", which create an HTTPSaver instance for uploading data with a PUT request.
+//   - "sftp", which creates an SFTPSaver instance for saving data to an SFTP server.
+//   - "-", which creates a WriterSaver instance for writing data to standard output.
+//
+// If an unsupported protocol is provided, NewSaver returns an error. Additional protocols can be
+// supported by registering a factory with RegisterSaver.
 //
 // Example usage:
 //
@@ -38,10 +47,7 @@ package saver
 
 import (
 	"context"
-	"fmt"
 	"io"
-
-	"github.com/enterprise-contract/go-gather/saver/file"
 )
 
 // Saver is an interface for saving data to a destination.
@@ -49,12 +55,11 @@ type Saver interface {
 	Save(ctx context.Context, data io.Reader, destination string) error
 }
 
-// NewSaver returns a Saver instance based on the destination protocol.
-func NewSaver(protocol string) (Saver, error) {
-	switch protocol {
-	case "file", "FileURI":
-		return &file.FileSaver{}, nil
-	default:
-		return nil, fmt.Errorf("unsupported protocol: %s", protocol)
-	}
+// AppendSaver is implemented by savers that can continue a partially written destination, e.g. to
+// resume an interrupted download.
+type AppendSaver interface {
+	Saver
+	// Append writes data to destination starting at offset, which must match the number of bytes
+	// the destination already holds. An offset of 0 saves data as Save does.
+	Append(ctx context.Context, data io.Reader, destination string, offset int64) error
 }
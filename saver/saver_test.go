@@ -20,7 +20,13 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/enterprise-contract/go-gather/saver/azblob"
 	"github.com/enterprise-contract/go-gather/saver/file"
+	"github.com/enterprise-contract/go-gather/saver/gcs"
+	"github.com/enterprise-contract/go-gather/saver/http"
+	"github.com/enterprise-contract/go-gather/saver/s3"
+	"github.com/enterprise-contract/go-gather/saver/sftp"
+	"github.com/enterprise-contract/go-gather/saver/writer"
 )
 
 func TestNewSaver(t *testing.T) {
@@ -35,7 +41,67 @@ func TestNewSaver(t *testing.T) {
 		t.Errorf("unexpected saver type: got %T, want *file.FileSaver", saver)
 	}
 
-	// Test case 2: unsupported protocol
+	// Test case 2: protocol is "s3"
+	saver, err = NewSaver("s3")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	_, ok = saver.(*s3.S3Saver)
+	if !ok {
+		t.Errorf("unexpected saver type: got %T, want *s3.S3Saver", saver)
+	}
+
+	// Test case 3: protocol is "gs"
+	saver, err = NewSaver("gs")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	_, ok = saver.(*gcs.GCSSaver)
+	if !ok {
+		t.Errorf("unexpected saver type: got %T, want *gcs.GCSSaver", saver)
+	}
+
+	// Test case 4: protocol is "azblob"
+	saver, err = NewSaver("azblob")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	_, ok = saver.(*azblob.AzureBlobSaver)
+	if !ok {
+		t.Errorf("unexpected saver type: got %T, want *azblob.AzureBlobSaver", saver)
+	}
+
+	// Test case 5: protocol is "https"
+	saver, err = NewSaver("https")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	_, ok = saver.(*http.HTTPSaver)
+	if !ok {
+		t.Errorf("unexpected saver type: got %T, want *http.HTTPSaver", saver)
+	}
+
+	// Test case 6: protocol is "sftp"
+	saver, err = NewSaver("sftp")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	_, ok = saver.(*sftp.SFTPSaver)
+	if !ok {
+		t.Errorf("unexpected saver type: got %T, want *sftp.SFTPSaver", saver)
+	}
+
+	// Test case 7: protocol is "-"
+	saver, err = NewSaver("-")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	_, ok = saver.(*writer.WriterSaver)
+	if !ok {
+		t.Errorf("unexpected saver type: got %T, want *writer.WriterSaver", saver)
+	}
+
+	// Test case 8: unsupported protocol
 	protocol = "unsupported"
 	_, err = NewSaver(protocol)
 	expectedErr := fmt.Errorf("unsupported protocol: %s", protocol)
@@ -45,3 +111,5 @@ func TestNewSaver(t *testing.T) {
 		t.Errorf("unexpected error: got %v, want %v", err, expectedErr)
 	}
 }
+
+var _ AppendSaver = &file.FileSaver{}
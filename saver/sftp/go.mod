@@ -0,0 +1,13 @@
+module github.com/enterprise-contract/go-gather/saver/sftp
+
+go 1.22.5
+
+require (
+	github.com/pkg/sftp v1.13.7
+	golang.org/x/crypto v0.32.0
+)
+
+require (
+	github.com/kr/fs v0.1.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+)
@@ -0,0 +1,214 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package sftp provides functionality for saving data to SFTP servers.
+//
+// This package contains the SFTPSaver type, which implements the Saver interface for
+// sftp://user@host/path destinations. Connections are authenticated with the configured
+// private keys or, when none are configured, with the keys held by the SSH agent.
+//
+// Example usage:
+//
+//	s := &sftp.SFTPSaver{KeyFile: "/home/user/.ssh/id_ed25519"}
+//	err := s.Save(context.Background(), data, "sftp://user@example.com/upload/bundle.tar.gz")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package sftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// DefaultTimeout is the time allowed for establishing the SSH connection when none is configured.
+const DefaultTimeout = 30 * time.Second
+
+// SFTPSaver handles saving data to SFTP servers.
+type SFTPSaver struct {
+	// Signers holds the private keys used to authenticate. If empty, the key in KeyFile is
+	// used, and if that is not set either, the keys held by the SSH agent are used.
+	Signers []ssh.Signer
+	// KeyFile is the path of a PEM encoded private key used to authenticate.
+	KeyFile string
+	// Passphrase decrypts KeyFile when it is encrypted.
+	Passphrase string
+	// HostKeyCallback verifies the host key of the server. If nil, the host key is verified
+	// against the known_hosts file of the current user.
+	HostKeyCallback ssh.HostKeyCallback
+	// Timeout is the time allowed for establishing the SSH connection. Defaults to
+	// DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Save implements the Saver interface for SFTP destinations.
+func (s *SFTPSaver) Save(ctx context.Context, data io.Reader, destination string) error {
+	dst, err := url.Parse(destination)
+	if err != nil {
+		return fmt.Errorf("failed to parse destination URI: %w", err)
+	}
+	if dst.Scheme != "sftp" {
+		return fmt.Errorf("unsupported destination scheme: %s", dst.Scheme)
+	}
+	if dst.Hostname() == "" || dst.Path == "" || dst.Path == "/" {
+		return fmt.Errorf("destination must be of the form sftp://user@host/path: %s", destination)
+	}
+
+	config, closeAgent, err := s.clientConfig(dst)
+	if err != nil {
+		return err
+	}
+	defer closeAgent()
+
+	addr := net.JoinHostPort(dst.Hostname(), "22")
+	if dst.Port() != "" {
+		addr = dst.Host
+	}
+
+	conn, err := (&net.Dialer{Timeout: config.Timeout}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to establish SSH connection to %s: %w", addr, err)
+	}
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
+	defer sshClient.Close()
+
+	// Closing the connection aborts a transfer in progress when the context is cancelled.
+	stop := context.AfterFunc(ctx, func() { sshClient.Close() })
+	defer stop()
+
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		return fmt.Errorf("failed to start SFTP session: %w", err)
+	}
+	defer client.Close()
+
+	if err := client.MkdirAll(path.Dir(dst.Path)); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	f, err := client.Create(dst.Path)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.ReadFrom(data); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to write data to file: %w", err)
+	}
+	return f.Close()
+}
+
+// clientConfig builds the SSH client configuration for the destination. The returned function closes
+// the connection to the SSH agent, if one was opened.
+func (s *SFTPSaver) clientConfig(dst *url.URL) (*ssh.ClientConfig, func(), error) {
+	nop := func() {}
+
+	username := dst.User.Username()
+	if username == "" {
+		u, err := user.Current()
+		if err != nil {
+			return nil, nop, fmt.Errorf("failed to determine user name: %w", err)
+		}
+		username = u.Username
+	}
+
+	hostKeyCallback := s.HostKeyCallback
+	if hostKeyCallback == nil {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nop, fmt.Errorf("failed to locate known_hosts: %w", err)
+		}
+		if hostKeyCallback, err = knownhosts.New(filepath.Join(home, ".ssh", "known_hosts")); err != nil {
+			return nil, nop, fmt.Errorf("failed to load known_hosts: %w", err)
+		}
+	}
+
+	auth, closeAgent, err := s.authMethod()
+	if err != nil {
+		return nil, nop, err
+	}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &ssh.ClientConfig{
+		User:            username,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         timeout,
+	}, closeAgent, nil
+}
+
+// authMethod returns the public key authentication method using the configured keys, or the SSH
+// agent when no keys are configured. The returned function closes the connection to the SSH agent.
+func (s *SFTPSaver) authMethod() (ssh.AuthMethod, func(), error) {
+	nop := func() {}
+
+	if len(s.Signers) > 0 {
+		return ssh.PublicKeys(s.Signers...), nop, nil
+	}
+
+	if s.KeyFile != "" {
+		key, err := os.ReadFile(s.KeyFile)
+		if err != nil {
+			return nil, nop, fmt.Errorf("failed to read private key: %w", err)
+		}
+		var signer ssh.Signer
+		if s.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(s.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, nop, fmt.Errorf("failed to parse private key (%s): %w", s.KeyFile, err)
+		}
+		return ssh.PublicKeys(signer), nop, nil
+	}
+
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, nop, errors.New("no private key configured and SSH_AUTH_SOCK is not set")
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, nop, fmt.Errorf("failed to connect to SSH agent: %w", err)
+	}
+	return ssh.PublicKeysCallback(agent.NewClient(conn).Signers), func() { conn.Close() }, nil
+}
@@ -0,0 +1,236 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// newKey generates an ed25519 private key and its signer.
+func newKey(t *testing.T) (ed25519.PrivateKey, ssh.Signer) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	return key, signer
+}
+
+// startServer starts an SSH server that serves the sftp subsystem from the local filesystem to
+// clients authenticating as user "test" with the authorized key. It returns the server address and
+// a host key callback accepting the server.
+func startServer(t *testing.T, authorized ssh.PublicKey) (string, ssh.HostKeyCallback) {
+	_, hostKey := newKey(t)
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "test" && bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unauthorized")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(conn, config)
+		}
+	}()
+
+	return l.Addr().String(), ssh.FixedHostKey(hostKey.PublicKey())
+}
+
+func serveConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					go func() {
+						server, err := sftp.NewServer(channel)
+						if err == nil {
+							server.Serve()
+						}
+						channel.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// TestSFTPSaver_Save tests saving a file with a configured signer.
+func TestSFTPSaver_Save(t *testing.T) {
+	_, signer := newKey(t)
+	addr, hostKeyCallback := startServer(t, signer.PublicKey())
+	dst := filepath.Join(t.TempDir(), "sub", "dir", "file.txt")
+
+	s := &SFTPSaver{Signers: []ssh.Signer{signer}, HostKeyCallback: hostKeyCallback}
+	if err := s.Save(context.Background(), strings.NewReader("test data"), "sftp://test@"+addr+dst); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	data, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("failed to read saved file: %v", err)
+	}
+	if string(data) != "test data" {
+		t.Errorf("unexpected saved data: %q", data)
+	}
+}
+
+// TestSFTPSaver_SaveKeyFile tests saving a file authenticating with an encrypted private key file.
+func TestSFTPSaver_SaveKeyFile(t *testing.T) {
+	key, signer := newKey(t)
+	addr, hostKeyCallback := startServer(t, signer.PublicKey())
+
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(key, "", []byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	dst := filepath.Join(t.TempDir(), "file.txt")
+
+	s := &SFTPSaver{KeyFile: keyFile, Passphrase: "secret", HostKeyCallback: hostKeyCallback}
+	if err := s.Save(context.Background(), strings.NewReader("test data"), "sftp://test@"+addr+dst); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "test data" {
+		t.Errorf("unexpected saved data: %q", data)
+	}
+
+	s.Passphrase = "wrong"
+	err = s.Save(context.Background(), strings.NewReader("test data"), "sftp://test@"+addr+dst)
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to parse private key") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestSFTPSaver_SaveAgent tests saving a file authenticating with the keys held by the SSH agent.
+func TestSFTPSaver_SaveAgent(t *testing.T) {
+	key, signer := newKey(t)
+	addr, hostKeyCallback := startServer(t, signer.PublicKey())
+
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatalf("failed to add key to agent: %v", err)
+	}
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", sock)
+
+	dst := filepath.Join(t.TempDir(), "file.txt")
+	s := &SFTPSaver{HostKeyCallback: hostKeyCallback}
+	if err := s.Save(context.Background(), strings.NewReader("test data"), "sftp://test@"+addr+dst); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "test data" {
+		t.Errorf("unexpected saved data: %q", data)
+	}
+}
+
+// TestSFTPSaver_SaveErrors tests the errors returned for invalid destinations and failed authentication.
+func TestSFTPSaver_SaveErrors(t *testing.T) {
+	_, signer := newKey(t)
+	_, other := newKey(t)
+	addr, hostKeyCallback := startServer(t, signer.PublicKey())
+
+	s := &SFTPSaver{Signers: []ssh.Signer{other}, HostKeyCallback: hostKeyCallback}
+	err := s.Save(context.Background(), strings.NewReader("data"), "sftp://test@"+addr+"/tmp/file.txt")
+	if err == nil || !strings.Contains(err.Error(), "unable to authenticate") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	s = &SFTPSaver{Signers: []ssh.Signer{signer}, HostKeyCallback: ssh.FixedHostKey(other.PublicKey())}
+	err = s.Save(context.Background(), strings.NewReader("data"), "sftp://test@"+addr+"/tmp/file.txt")
+	if err == nil || !strings.Contains(err.Error(), "host key mismatch") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		destination string
+		err         string
+	}{
+		{destination: "ftp://host/file", err: "unsupported destination scheme: ftp"},
+		{destination: "sftp://host", err: "destination must be of the form sftp://user@host/path: sftp://host"},
+		{destination: "sftp:///file", err: "destination must be of the form sftp://user@host/path: sftp:///file"},
+	}
+	for _, tt := range tests {
+		err := s.Save(context.Background(), strings.NewReader("data"), tt.destination)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("unexpected error for %s: got %v, want %s", tt.destination, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package tar provides functionality for saving data into a tar archive.
+//
+// This package contains the TarSaver type, which implements the Saver interface by
+// appending each saved file as a member of a single tar archive, so that a directory
+// gather produces a portable archive instead of a tree of files. Archives whose name
+// ends in ".gz" or ".tgz" are gzip compressed.
+//
+// Example usage:
+//
+//	ts, err := tar.NewTarSaver("/path/to/bundle.tar.gz")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	ts.Root = "/path/to/bundle"
+//	err = ts.Save(context.Background(), data, "/path/to/bundle/policy/main.rego")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+//	if err := ts.Close(); err != nil {
+//	  log.Fatal(err)
+//	}
+package tar
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// TarSaver handles saving data as members of a single tar archive. It is safe for concurrent
+// use, and must be closed to complete the archive.
+type TarSaver struct {
+	// Root is removed from the start of destinations to form the member names, e.g. the
+	// directory a gather would otherwise have written to. Destinations outside of Root, or
+	// all destinations when Root is empty, are stored with their leading separator removed.
+	Root string
+	// Mode is the permission of the archive members. Defaults to 0644.
+	Mode os.FileMode
+
+	mu     sync.Mutex
+	f      *os.File
+	gz     *gzip.Writer
+	tw     *tar.Writer
+	closed bool
+}
+
+// NewTarSaver creates the archive at path, gzip compressing it when path ends in ".gz" or ".tgz".
+func NewTarSaver(path string) (*TarSaver, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	ts := &TarSaver{f: f}
+	var w io.Writer = f
+	if strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".tgz") {
+		ts.gz = gzip.NewWriter(f)
+		w = ts.gz
+	}
+	ts.tw = tar.NewWriter(w)
+	return ts, nil
+}
+
+// Save implements the Saver interface by appending data to the archive as a member named after
+// destination. The data is buffered in a temporary file to determine its size before it is written,
+// created in the TempDir of the gather options carried by ctx, see gogather.CreateTemp.
+func (ts *TarSaver) Save(ctx context.Context, data io.Reader, destination string) error {
+	name, err := ts.memberName(destination)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := gogather.CreateTemp(ctx, "go-gather-tar-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, data)
+	if err != nil {
+		return fmt.Errorf("failed to read data: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind data: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	mode := ts.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return errors.New("archive is closed")
+	}
+
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     int64(mode.Perm()),
+		ModTime:  time.Now(),
+	}
+	if err := ts.tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write archive member header (%s): %w", name, err)
+	}
+	if _, err := io.Copy(ts.tw, tmp); err != nil {
+		return fmt.Errorf("failed to write archive member (%s): %w", name, err)
+	}
+	return nil
+}
+
+// Close completes the archive and closes the underlying file.
+func (ts *TarSaver) Close() error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return nil
+	}
+	ts.closed = true
+
+	err := ts.tw.Close()
+	if ts.gz != nil {
+		err = errors.Join(err, ts.gz.Close())
+	}
+	err = errors.Join(err, ts.f.Close())
+	if err != nil {
+		return fmt.Errorf("failed to close archive: %w", err)
+	}
+	return nil
+}
+
+// memberName returns the name of the archive member for destination.
+func (ts *TarSaver) memberName(destination string) (string, error) {
+	name := destination
+	if ts.Root != "" {
+		if rel, err := filepath.Rel(ts.Root, destination); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			name = rel
+		}
+	}
+
+	name = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+	if name == "" {
+		return "", fmt.Errorf("invalid archive member name: %s", destination)
+	}
+	return name, nil
+}
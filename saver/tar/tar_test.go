@@ -0,0 +1,151 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package tar
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	gogather "github.com/enterprise-contract/go-gather"
+)
+
+// readArchive returns the members of the archive at path mapped to their content.
+func readArchive(t *testing.T, path string, compressed bool) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if compressed {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("failed to open gzip stream: %v", err)
+		}
+		r = gz
+	}
+
+	members := map[string]string{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read archive: %v", err)
+		}
+		if hdr.Mode != 0644 {
+			t.Errorf("unexpected mode of %s: %o", hdr.Name, hdr.Mode)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("failed to read member %s: %v", hdr.Name, err)
+		}
+		members[hdr.Name] = string(data)
+	}
+	return members
+}
+
+// TestTarSaver_Save tests saving files into plain and gzip compressed archives.
+func TestTarSaver_Save(t *testing.T) {
+	for _, name := range []string{"bundle.tar", "bundle.tar.gz", "bundle.tgz"} {
+		t.Run(name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), "out", name)
+			ts, err := NewTarSaver(archive)
+			if err != nil {
+				t.Fatalf("failed to create saver: %v", err)
+			}
+			ts.Root = "/gather/root"
+
+			files := map[string]string{
+				"/gather/root/a.txt":            "first",
+				"/gather/root/policy/main.rego": "second",
+				"/elsewhere/c.txt":              "third",
+				"relative/../d.txt":             "fourth",
+			}
+			for dst, data := range files {
+				if err := ts.Save(context.Background(), strings.NewReader(data), dst); err != nil {
+					t.Fatalf("failed to save %s: %v", dst, err)
+				}
+			}
+			if err := ts.Close(); err != nil {
+				t.Fatalf("failed to close saver: %v", err)
+			}
+
+			want := map[string]string{
+				"a.txt":            "first",
+				"policy/main.rego": "second",
+				"elsewhere/c.txt":  "third",
+				"d.txt":            "fourth",
+			}
+			if got := readArchive(t, archive, name != "bundle.tar"); !reflect.DeepEqual(got, want) {
+				t.Errorf("unexpected archive members: got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// TestTarSaver_SaveAfterClose tests that saving fails once the archive is closed.
+func TestTarSaver_SaveAfterClose(t *testing.T) {
+	ts, err := NewTarSaver(filepath.Join(t.TempDir(), "bundle.tar"))
+	if err != nil {
+		t.Fatalf("failed to create saver: %v", err)
+	}
+	if err := ts.Close(); err != nil {
+		t.Fatalf("failed to close saver: %v", err)
+	}
+	if err := ts.Close(); err != nil {
+		t.Errorf("expected closing twice to succeed, got %v", err)
+	}
+
+	err = ts.Save(context.Background(), strings.NewReader("data"), "a.txt")
+	if err == nil || err.Error() != "archive is closed" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = ts.Save(context.Background(), strings.NewReader("data"), "/")
+	if err == nil || err.Error() != "invalid archive member name: /" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// TestTarSaver_TempDir tests buffering data in the TempDir of the gather options.
+func TestTarSaver_TempDir(t *testing.T) {
+	ts, err := NewTarSaver(filepath.Join(t.TempDir(), "bundle.tar"))
+	if err != nil {
+		t.Fatalf("failed to create saver: %v", err)
+	}
+	defer ts.Close()
+
+	dir := filepath.Join(t.TempDir(), "scratch")
+	ctx := gogather.ContextWithOptions(context.Background(), gogather.GatherOptions{TempDir: dir})
+	if err := ts.Save(ctx, strings.NewReader("package main"), "main.rego"); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Errorf("expected the data to be buffered in and removed from %s: %v, %v", dir, entries, err)
+	}
+}
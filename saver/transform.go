@@ -0,0 +1,48 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package saver
+
+import (
+	"context"
+	"io"
+)
+
+// saveTransformed saves data to destination with s after passing it through the writer returned by
+// newWriter, e.g. to compress or encrypt it. The writer is closed once all of data has been written.
+func saveTransformed(ctx context.Context, s Saver, data io.Reader, destination string, newWriter func(io.Writer) (io.WriteCloser, error)) error {
+	pr, pw := io.Pipe()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w, err := newWriter(pw)
+		if err == nil {
+			_, err = io.Copy(w, data)
+			if cerr := w.Close(); err == nil {
+				err = cerr
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+
+	err := s.Save(ctx, pr, destination)
+	// Unblock the writing goroutine in case the saver stopped reading early.
+	pr.CloseWithError(io.ErrClosedPipe)
+	<-done
+
+	return err
+}
@@ -0,0 +1,3 @@
+module github.com/enterprise-contract/go-gather/saver/writer
+
+go 1.22.5
@@ -0,0 +1,76 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package writer provides functionality for saving data to an io.Writer.
+//
+// This package contains the WriterSaver type, which implements the Saver interface by
+// copying the data to a caller-provided io.Writer, or to standard output when the
+// destination is "-". This allows gathered content to be piped into another process.
+//
+// Example usage:
+//
+//	ws := &writer.WriterSaver{}
+//	err := ws.Save(context.Background(), data, "-")
+//	if err != nil {
+//	  log.Fatal(err)
+//	}
+package writer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Stdout is the destination that selects standard output.
+const Stdout = "-"
+
+// WriterSaver handles saving data to an io.Writer.
+type WriterSaver struct {
+	// Writer receives the data for any destination. If nil, only the Stdout destination
+	// is supported and the data is written to os.Stdout.
+	Writer io.Writer
+}
+
+// Save implements the Saver interface by copying data to the writer.
+func (ws *WriterSaver) Save(ctx context.Context, data io.Reader, destination string) error {
+	w := ws.Writer
+	if w == nil {
+		if destination != Stdout {
+			return fmt.Errorf("no writer configured for destination: %s", destination)
+		}
+		w = os.Stdout
+	}
+
+	if _, err := io.Copy(w, contextReader{ctx: ctx, r: data}); err != nil {
+		return fmt.Errorf("failed to write data: %w", err)
+	}
+	return nil
+}
+
+// contextReader stops reading once its context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,90 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package writer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestWriterSaver_Save tests that data is written to the configured writer.
+func TestWriterSaver_Save(t *testing.T) {
+	var buf bytes.Buffer
+	ws := &WriterSaver{Writer: &buf}
+
+	if err := ws.Save(context.Background(), strings.NewReader("first "), "a.txt"); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if err := ws.Save(context.Background(), strings.NewReader("second"), Stdout); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	if got := buf.String(); got != "first second" {
+		t.Errorf("unexpected data: %q", got)
+	}
+}
+
+// TestWriterSaver_SaveStdout tests that data is written to standard output when no writer is configured.
+func TestWriterSaver_SaveStdout(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	defer r.Close()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	err = (&WriterSaver{}).Save(context.Background(), strings.NewReader("test data"), Stdout)
+	w.Close()
+	if err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read stdout: %v", err)
+	}
+	if string(got) != "test data" {
+		t.Errorf("unexpected data: %q", got)
+	}
+}
+
+// TestWriterSaver_SaveErrors tests the errors returned when no writer is available or the context is done.
+func TestWriterSaver_SaveErrors(t *testing.T) {
+	err := (&WriterSaver{}).Save(context.Background(), strings.NewReader("data"), "file.txt")
+	if err == nil || err.Error() != "no writer configured for destination: file.txt" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var buf bytes.Buffer
+	err = (&WriterSaver{Writer: &buf}).Save(ctx, strings.NewReader("data"), Stdout)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing to be written, got %q", buf.String())
+	}
+}
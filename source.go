@@ -0,0 +1,165 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// forcedProtocolPattern matches a source prefixed with a forced protocol, e.g. "git::".
+var forcedProtocolPattern = regexp.MustCompile(`^([A-Za-z0-9]+)::(.+)$`)
+
+// Source is a source written in the syntax of hashicorp/go-getter: an optional forced protocol
+// prefix, a URL or path, an optional subdirectory separated from the path by "//", and the
+// "archive" and "checksum" query parameters, e.g.
+// "http::https://example.com/bundle.tgz//policy?archive=tar.gz&checksum=sha256:abc...".
+type Source struct {
+	// Forced is the protocol forced with a "<protocol>::" prefix, e.g. "git", or empty.
+	Forced string
+	// URL is the URL or path of the source without the forced protocol, the subdirectory, and
+	// the archive and checksum query parameters. Other query parameters, e.g. the git "ref",
+	// are kept.
+	URL string
+	// Subdir is the subdirectory of the gathered content to keep, or empty to keep everything.
+	Subdir string
+	// Archive is the value of the archive query parameter: the format of the archive to
+	// expand, e.g. "zip" or "tar.gz", or "false" to disable the expansion of archives.
+	Archive string
+	// Checksum is the value of the checksum query parameter, the expected digest of the
+	// gathered file, see ParseChecksum.
+	Checksum string
+
+	// The fields below are derived from URL by ParseSource for the gatherers, and are not used
+	// by String.
+
+	// Type is the protocol of the source, as classified by ClassifyURI.
+	Type URIType
+	// BaseURL is URL without its query, e.g. "https://github.com/org/repo.git".
+	BaseURL string
+	// Ref is the value of the git "ref" query parameter, e.g. a branch, tag or commit.
+	Ref string
+	// Depth is the value of the git "depth" query parameter, the number of commits to clone, or
+	// zero for the full history.
+	Depth int
+	// QueryParams holds the query parameters of URL other than ref and depth.
+	QueryParams url.Values
+}
+
+// ParseSource splits source into its go-getter style components and classifies it. Sources that
+// use none of them are returned as the URL of the Source. Only a leading "<protocol>::" prefix
+// forces a protocol, so "::" may appear elsewhere, e.g. in tokens. For compatibility, a
+// subdirectory may also follow the value of the ref or depth parameter, e.g. "?ref=main//policy".
+func ParseSource(source string) (*Source, error) {
+	s := &Source{QueryParams: url.Values{}}
+	rest := source
+	if m := forcedProtocolPattern.FindStringSubmatch(rest); m != nil {
+		s.Forced, rest = m[1], m[2]
+	}
+
+	var query string
+	rest, query, _ = strings.Cut(rest, "?")
+
+	// A subdirectory is separated by a double slash following the scheme, if any.
+	start := 0
+	if i := strings.Index(rest, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	if i := strings.Index(rest[start:], "//"); i >= 0 {
+		rest, s.Subdir = rest[:start+i], rest[start+i+len("//"):]
+	}
+
+	var kept []string
+	if query != "" {
+		for _, param := range strings.Split(query, "&") {
+			key, value, _ := strings.Cut(param, "=")
+			value, err := url.QueryUnescape(value)
+			if err == nil {
+				key, err = url.QueryUnescape(key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the %s parameter of %s: %w", key, RedactURL(source), err)
+			}
+			switch key {
+			case "archive":
+				s.Archive = value
+				continue
+			case "checksum":
+				s.Checksum = value
+				continue
+			case "ref", "depth":
+				if v, subdir, ok := strings.Cut(value, "//"); ok && s.Subdir == "" {
+					value, s.Subdir = v, subdir
+					param = key + "=" + url.QueryEscape(v)
+				}
+				if key == "ref" {
+					s.Ref = value
+				} else if s.Depth, err = strconv.Atoi(value); err != nil {
+					return nil, fmt.Errorf("failed to parse the %s parameter of %s: %w", key, RedactURL(source), err)
+				}
+			case "":
+				continue
+			default:
+				s.QueryParams.Add(key, value)
+			}
+			kept = append(kept, param)
+		}
+	}
+
+	s.URL, s.BaseURL = rest, rest
+	if len(kept) > 0 {
+		s.URL += "?" + strings.Join(kept, "&")
+	}
+
+	t, err := ClassifyURI(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to classify source URI: %w", err)
+	}
+	s.Type = t
+	return s, nil
+}
+
+// String returns the source in go-getter syntax.
+func (s *Source) String() string {
+	var b strings.Builder
+	if s.Forced != "" {
+		b.WriteString(s.Forced + "::")
+	}
+	base, query, _ := strings.Cut(s.URL, "?")
+	b.WriteString(base)
+	if s.Subdir != "" {
+		b.WriteString("//" + s.Subdir)
+	}
+
+	var params []string
+	if query != "" {
+		params = append(params, query)
+	}
+	if s.Archive != "" {
+		params = append(params, "archive="+url.QueryEscape(s.Archive))
+	}
+	if s.Checksum != "" {
+		params = append(params, "checksum="+url.QueryEscape(s.Checksum))
+	}
+	if len(params) > 0 {
+		b.WriteString("?" + strings.Join(params, "&"))
+	}
+	return b.String()
+}
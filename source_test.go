@@ -0,0 +1,100 @@
+// Copyright The Enterprise Contract Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package gogather
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// TestParseSource tests splitting go-getter style sources into their components
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		source string
+		want   Source
+	}{
+		{source: "/tmp/policy", want: Source{URL: "/tmp/policy", Type: FileURI, BaseURL: "/tmp/policy", QueryParams: url.Values{}}},
+		{source: "file:///tmp/policy", want: Source{URL: "file:///tmp/policy", Type: FileURI, BaseURL: "file:///tmp/policy", QueryParams: url.Values{}}},
+		{
+			source: "file::/tmp/bundle.zip//policy",
+			want:   Source{Forced: "file", URL: "/tmp/bundle.zip", Subdir: "policy", Type: FileURI, BaseURL: "/tmp/bundle.zip", QueryParams: url.Values{}},
+		},
+		{
+			source: "git::https://github.com/org/repo//policy/lib?ref=v1.0.0&depth=1",
+			want: Source{
+				Forced: "git", URL: "https://github.com/org/repo?ref=v1.0.0&depth=1", Subdir: "policy/lib",
+				Type: GitURI, BaseURL: "https://github.com/org/repo", Ref: "v1.0.0", Depth: 1, QueryParams: url.Values{},
+			},
+		},
+		{
+			source: "github.com/org/repo?ref=main//policy",
+			want: Source{
+				URL: "github.com/org/repo?ref=main", Subdir: "policy",
+				Type: GitURI, BaseURL: "github.com/org/repo", Ref: "main", QueryParams: url.Values{},
+			},
+		},
+		{
+			source: "https://example.com/bundle.tgz//policy?archive=tar.gz&checksum=sha256%3Aabc",
+			want: Source{
+				URL: "https://example.com/bundle.tgz", Subdir: "policy", Archive: "tar.gz", Checksum: "sha256:abc",
+				Type: HTTPURI, BaseURL: "https://example.com/bundle.tgz", QueryParams: url.Values{},
+			},
+		},
+		{
+			source: "https://example.com/file.txt?token=a::b&archive=false",
+			want: Source{
+				URL: "https://example.com/file.txt?token=a::b", Archive: "false",
+				Type: HTTPURI, BaseURL: "https://example.com/file.txt", QueryParams: url.Values{"token": {"a::b"}},
+			},
+		},
+		{
+			source: "oci::registry.io/org/bundle:v1",
+			want:   Source{Forced: "oci", URL: "registry.io/org/bundle:v1", Type: OCIURI, BaseURL: "registry.io/org/bundle:v1", QueryParams: url.Values{}},
+		},
+		{
+			source: "git@github.com:org/repo.git//policy",
+			want:   Source{URL: "git@github.com:org/repo.git", Subdir: "policy", Type: GitURI, BaseURL: "git@github.com:org/repo.git", QueryParams: url.Values{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			got, err := ParseSource(tt.source)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("unexpected source: got %+v, want %+v", *got, tt.want)
+			}
+			reparsed, err := ParseSource(got.String())
+			if err != nil || !reflect.DeepEqual(reparsed, got) {
+				t.Errorf("source %s does not round trip: %+v, %v", got.String(), reparsed, err)
+			}
+		})
+	}
+
+	if _, err := ParseSource("https://example.com/file.txt?checksum=%zz"); err == nil {
+		t.Error("expected an error for an invalid query parameter")
+	}
+	if _, err := ParseSource("https://github.com/org/repo.git?depth=squiggle"); err == nil {
+		t.Error("expected an error for an invalid depth")
+	}
+	if _, err := ParseSource("ftp://example.com/file.txt"); err == nil {
+		t.Error("expected an error for an unsupported protocol")
+	}
+}